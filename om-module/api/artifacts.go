@@ -0,0 +1,255 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/artifacts"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/Parz1val02/OM_module/internal/metriccatalog"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// bundleGrafanaURL is used for the dashboard links of the archived
+// educational page; archives are usually opened on the lab host.
+const bundleGrafanaURL = "http://localhost:3000"
+
+// WriteBundle archives the current state of the module as a new bundle in
+// the artifact store. reason is recorded in the manifest.
+func (h *Handlers) WriteBundle(ctx context.Context, reason string) (artifacts.Manifest, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "artifacts.write_bundle")
+	defer span.End()
+
+	files, err := h.bundleFiles(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return artifacts.Manifest{}, err
+	}
+	host, _ := os.Hostname()
+	m, err := artifacts.Write(ctx, h.artifacts, reason, map[string]string{
+		"project":    h.project,
+		"generation": h.snap.ActiveGeneration(),
+		"host":       host,
+	}, files)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return m, err
+	}
+	h.written.Add(h.artifacts.Location(m.ArchiveKey()))
+	span.SetAttributes(
+		attribute.String("artifacts.bundle", m.ID),
+		attribute.Int("artifacts.files", len(m.Files)),
+		attribute.Int64("artifacts.size_bytes", m.SizeBytes),
+	)
+	return m, nil
+}
+
+// bundleFiles collects what a bundle contains: the API views of every
+// enabled subsystem, the metrics registry, the Loki label contract, the
+// educational page and the dashboard files.
+func (h *Handlers) bundleFiles(ctx context.Context) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	add := func(name string, v any) error {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		files[name] = b
+		return nil
+	}
+
+	view := h.snap.View()
+	views := map[string]any{
+		"topology.json":    h.buildTopology(ctx, view),
+		"status.json":      h.startupStatus(),
+		"loki-labels.json": logschema.Build(view.Services()),
+	}
+	if h.capManager != nil {
+		s := h.capManager.Status()
+		views["capture.json"] = captureStatusResponse{
+			Running: s.Running, Interface: s.Interface, Generation: s.Generation,
+			PacketsTotal: s.PacketsTotal, Packets4G: s.Packets4G, Packets5G: s.Packets5G,
+			RestartCount: s.RestartCount, UptimeSeconds: s.UptimeSeconds,
+		}
+	}
+	if h.causes != nil {
+		views["causes.json"] = causesResponse{Enabled: true, Causes: h.causes.Summary("")}
+	}
+	if h.sbi != nil {
+		views["sbi.json"] = captureSBIResponse{Enabled: true, Pairs: h.sbi.Summary()}
+	}
+	if h.milestones != nil {
+		views["milestones.json"] = milestonesResponse{Enabled: true, Status: h.milestones.Status()}
+	}
+	if h.qos != nil {
+		views["qos.json"] = qosResponse{Enabled: true, Flows: h.qos.Flows()}
+	}
+	if h.security != nil {
+		views["nas-security.json"] = nasSecurityResponse{
+			Enabled: true, AKASteps: []akaStep{}, Procedures: h.security.Procedures(""),
+		}
+	}
+	if h.handovers != nil {
+		views["handovers.json"] = handoversResponse{
+			Enabled: true, Matrix: h.handovers.Matrix(""), Handovers: h.handovers.Handovers(""),
+		}
+	}
+	if h.synthetic != nil {
+		views["synthetic.json"] = h.syntheticStatus()
+	}
+	if h.errorBudgets != nil {
+		views["error-budget.json"] = h.errorBudgetStatus()
+	}
+	if h.slo != nil {
+		views["slo.json"] = h.sloStatus()
+	}
+	if h.metricBuffer != nil {
+		views["metric-buffer.json"] = h.metricBufferStatus()
+	}
+	if catalog, err := h.metricsCatalog(); err == nil {
+		views["metrics-catalog.json"] = metricsCatalogResponse{
+			Total: len(catalog), Categories: metriccatalog.Categories(catalog), Metrics: catalog,
+		}
+	}
+	for name, v := range views {
+		if err := add(name, v); err != nil {
+			return nil, err
+		}
+	}
+
+	mfs, err := h.reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	var metrics bytes.Buffer
+	enc := expfmt.NewEncoder(&metrics, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return nil, err
+		}
+	}
+	files["metrics.prom"] = metrics.Bytes()
+
+	var page bytes.Buffer
+	if err := h.renderEducational(&page, bundleGrafanaURL, h.education(), time.Now()); err != nil {
+		return nil, err
+	}
+	files["educational.html"] = page.Bytes()
+
+	if h.dashboards != nil {
+		paths, _ := filepath.Glob(filepath.Join(h.dashboards.Dir(), "*.json"))
+		for _, p := range paths {
+			if b, err := os.ReadFile(p); err == nil {
+				files["dashboards/"+filepath.Base(p)] = b
+			}
+		}
+	}
+	return files, nil
+}
+
+// --- /api/artifacts ------------------------------------------------------
+
+type artifactsResponse struct {
+	Enabled bool                 `json:"enabled"`
+	Store   string               `json:"store,omitempty"`
+	Bundles []artifacts.Manifest `json:"bundles"`
+}
+
+// handleArtifacts lists the bundles (GET) or archives a new one (POST).
+func (h *Handlers) handleArtifacts(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http."+r.Method+" /api/artifacts")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+		resp := artifactsResponse{Bundles: []artifacts.Manifest{}}
+		if h.artifacts != nil {
+			list, err := artifacts.List(ctx, h.artifacts)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			resp.Enabled = true
+			resp.Store = h.artifacts.String()
+			resp.Bundles = list
+		}
+		span.SetAttributes(attribute.Int("artifacts.bundles", len(resp.Bundles)))
+		writeJSON(w, r, resp)
+
+	case http.MethodPost:
+		if h.artifacts == nil {
+			http.Error(w, "artifact store disabled", http.StatusServiceUnavailable)
+			return
+		}
+		m, err := h.WriteBundle(ctx, "manual")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(m)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleArtifact serves GET /api/artifacts/{id} (the manifest) and
+// GET /api/artifacts/{id}/bundle.tar.gz (the archive).
+func (h *Handlers) handleArtifact(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/artifacts/{id}")
+	defer span.End()
+
+	id, file, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/artifacts/"), "/")
+	switch {
+	case id == "":
+		h.handleArtifacts(w, r)
+		return
+	case r.Method != http.MethodGet:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	case file != "" && file != "bundle.tar.gz":
+		http.NotFound(w, r)
+		return
+	case h.artifacts == nil:
+		http.Error(w, "artifact store disabled", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("artifacts.bundle", id))
+
+	m, err := artifacts.Load(ctx, h.artifacts, id)
+	if err == nil && file != "" {
+		var data []byte
+		if data, err = h.artifacts.Get(ctx, m.ArchiveKey()); err == nil {
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", `attachment; filename="om-bundle-`+m.ID+`.tar.gz"`)
+			_, _ = w.Write(data)
+			return
+		}
+	}
+	switch {
+	case errors.Is(err, artifacts.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		writeJSON(w, r, m)
+	}
+}
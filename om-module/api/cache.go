@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// responseCache keeps encoded JSON responses that are derived only from the
+// collector snapshot, tagged with the snapshot version they were built from.
+// A new snapshot invalidates them. Concurrent requests for a stale entry are
+// coalesced: one of them rebuilds it while the others wait for the result.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	mu      sync.Mutex
+	built   bool
+	version uint64
+	value   any
+	body    []byte
+	etag    string
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{entries: make(map[string]*cacheEntry)}
+}
+
+// get returns the cached response for key if it was built from version, or
+// calls build and caches its result.
+func (c *responseCache) get(key string, version uint64, build func() any) (value any, body []byte, etag string, err error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.built && e.version == version {
+		return e.value, e.body, e.etag, nil
+	}
+
+	v := build()
+	b, err := encodeJSON(v)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	e.built, e.version, e.value, e.body, e.etag = true, version, v, b, etagOf(b)
+	return e.value, e.body, e.etag, nil
+}
+
+// encodeJSON encodes v exactly like json.Encoder, trailing newline included.
+func encodeJSON(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func etagOf(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// writeJSON encodes v and writes it with an ETag, answering 304 Not Modified
+// when the client already holds the same body.
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := encodeJSON(v)
+	if err != nil {
+		http.Error(w, "encode failed", http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, body, etagOf(body))
+}
+
+func writeBody(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// etagMatches implements the weak comparison If-None-Match uses.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+// --- /metrics/cadvisor -----------------------------------------------------
+
+// handleCAdvisorMetrics serves the container stats under cAdvisor's names
+// for the om-cadvisor scrape job. Without the compatibility exporter it
+// serves nothing, so the job stays up.
+func (h *Handlers) handleCAdvisorMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.cadvisor == nil {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		return
+	}
+	h.cadvisor.ServeHTTP(w, r)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/cardinality"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/metrics/cardinality ----------------------------------------------
+
+type cardinalityResponse struct {
+	Enabled bool `json:"enabled"`
+	cardinality.Status
+}
+
+// handleCardinality serves the head series of the largest metric families
+// against their budget, the values of the labels of those near or over it
+// and the labels limited so far.
+func (h *Handlers) handleCardinality(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/metrics/cardinality")
+	defer span.End()
+
+	resp := cardinalityResponse{Status: cardinality.Status{Families: []cardinality.Family{}, Limits: []cardinality.Limit{}}}
+	if h.cardinality != nil {
+		resp = cardinalityResponse{Enabled: true, Status: h.cardinality.Status()}
+	}
+	span.SetAttributes(attribute.Int("cardinality.head_series", resp.HeadSeries),
+		attribute.Int("cardinality.limits", len(resp.Limits)))
+
+	writeJSON(w, r, resp)
+}
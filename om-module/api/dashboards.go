@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/querylint"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/dashboards -----------------------------------------------------
+
+type dashboardsResponse struct {
+	Enabled    bool                   `json:"enabled"`
+	Dir        string                 `json:"dir,omitempty"`
+	Dashboards []dashboards.Dashboard `json:"dashboards"`
+}
+
+func (h *Handlers) handleDashboards(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/dashboards")
+	defer span.End()
+
+	resp := dashboardsResponse{Dashboards: []dashboards.Dashboard{}}
+	if h.dashboards != nil {
+		list, err := h.dashboards.List()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Enabled = true
+		resp.Dir = h.dashboards.Dir()
+		resp.Dashboards = list
+	}
+	span.SetAttributes(attribute.Int("dashboards.count", len(resp.Dashboards)))
+
+	writeJSON(w, r, resp)
+}
+
+type dashboardResponse struct {
+	Dashboard dashboards.Dashboard   `json:"dashboard"`
+	Grafana   *grafana.DashboardMeta `json:"grafana,omitempty"`
+	Error     string                 `json:"grafana_error,omitempty"`
+}
+
+// handleDashboard serves GET /api/dashboards/{uid} (file metadata plus the
+// copy Grafana runs), POST /api/dashboards/{uid}/reload and GET
+// /api/dashboards/lint.
+func (h *Handlers) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	uid, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/dashboards/"), "/")
+	switch {
+	case uid == "":
+		h.handleDashboards(w, r)
+	case uid == "lint" && action == "":
+		h.handleDashboardLint(w, r)
+	case action == "" && r.Method == http.MethodGet:
+		h.showDashboard(w, r, uid)
+	case action == "reload" && r.Method == http.MethodPost:
+		h.reloadDashboard(w, r, uid)
+	case action == "" || action == "reload":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handlers) showDashboard(w http.ResponseWriter, r *http.Request, uid string) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/dashboards/{uid}")
+	defer span.End()
+	span.SetAttributes(attribute.String("dashboard.uid", uid))
+
+	d, _, ok := h.loadDashboard(w, uid)
+	if !ok {
+		return
+	}
+	resp := dashboardResponse{Dashboard: d}
+	if h.grafana != nil {
+		meta, err := h.grafana.GetDashboard(ctx, uid)
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Grafana = &meta
+		}
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// reloadDashboard pushes one dashboard file to Grafana. Provisioned
+// dashboards (the default) cannot be saved through the API, so Grafana is
+// asked to re-read its provisioning directory; others are uploaded directly.
+func (h *Handlers) reloadDashboard(w http.ResponseWriter, r *http.Request, uid string) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.POST /api/dashboards/{uid}/reload")
+	defer span.End()
+	span.SetAttributes(attribute.String("dashboard.uid", uid))
+
+	if h.grafana == nil {
+		http.Error(w, "Grafana client disabled", http.StatusServiceUnavailable)
+		return
+	}
+	d, raw, ok := h.loadDashboard(w, uid)
+	if !ok {
+		return
+	}
+
+	meta, err := h.grafana.GetDashboard(ctx, uid)
+	switch {
+	case err == nil && !meta.Provisioned:
+		if h.folder.UID != "" {
+			_, err = h.grafana.EnsureFolder(ctx, h.folder.UID, h.folder.Title)
+		}
+		if err == nil {
+			err = h.grafana.UploadDashboard(ctx, raw, h.folder.UID, "Reloaded from "+d.File+" by the O&M module")
+		}
+	case err == nil || grafana.IsNotFound(err):
+		err = h.grafana.ReloadDashboardProvisioning(ctx)
+	}
+	if err == nil {
+		meta, err = h.grafana.GetDashboard(ctx, uid)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "grafana: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	span.SetAttributes(attribute.Int("dashboard.version", meta.Version))
+
+	writeJSON(w, r, dashboardResponse{Dashboard: d, Grafana: &meta})
+}
+
+// --- /api/dashboards/lint ------------------------------------------------
+
+type dashboardLintResponse struct {
+	Enabled bool `json:"enabled"`
+	// Pending is set until the first pass has finished.
+	Pending bool `json:"pending,omitempty"`
+	querylint.Report
+}
+
+// handleDashboardLint serves the report of the last dashboard query lint.
+func (h *Handlers) handleDashboardLint(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/dashboards/lint")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := dashboardLintResponse{Report: querylint.Report{
+		Checked:  map[string]int{},
+		Skipped:  []string{},
+		Problems: []querylint.Problem{},
+	}}
+	if h.lint != nil {
+		resp.Enabled = true
+		if rep, ok := h.lint.Last(); ok {
+			resp.Report = rep
+		} else {
+			resp.Pending = true
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("lint.errors", resp.Errors),
+		attribute.Int("lint.warnings", resp.Warnings),
+	)
+
+	writeJSON(w, r, resp)
+}
+
+// loadDashboard returns the inventory entry for uid and its model, writing
+// the error response itself when there is none.
+func (h *Handlers) loadDashboard(w http.ResponseWriter, uid string) (dashboards.Dashboard, json.RawMessage, bool) {
+	if h.dashboards == nil {
+		http.Error(w, "dashboard inventory disabled", http.StatusServiceUnavailable)
+		return dashboards.Dashboard{}, nil, false
+	}
+	d, raw, err := h.dashboards.Load(uid)
+	switch {
+	case errors.Is(err, dashboards.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return d, nil, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return d, nil, false
+	}
+	return d, raw, true
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/artifacts"
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/logbuffer"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// debugSources are the inputs of debug bundles that only main knows about.
+type debugSources struct {
+	config      any               // effective configuration, already redacted
+	logs        *logbuffer.Buffer // recent module log lines
+	configFiles map[string]string // bundle name → path
+}
+
+// --- /api/debug/bundle ---------------------------------------------------
+
+type debugVersions struct {
+	GoVersion    string            `json:"go_version"`
+	Module       string            `json:"module"`
+	Version      string            `json:"version"`
+	Revision     string            `json:"vcs_revision,omitempty"`
+	RevisionTime string            `json:"vcs_time,omitempty"`
+	Modified     bool              `json:"vcs_modified,omitempty"`
+	Dependencies map[string]string `json:"dependencies"`
+	Containers   map[string]string `json:"containers"` // name → image
+}
+
+type debugHealthHistory struct {
+	Events []collector.HealthEvent `json:"events"`
+}
+
+// handleDebugBundle assembles a support bundle — module logs, effective
+// configuration, status, health history, topology and versions — and
+// serves it as a tar.gz download, for students to attach to a report.
+func (h *Handlers) handleDebugBundle(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/debug/bundle")
+	defer span.End()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	now := time.Now().UTC()
+	files, err := h.debugBundleFiles(ctx)
+	var archive []byte
+	var entries []artifacts.File
+	if err == nil {
+		archive, entries, err = artifacts.Pack(files, now)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "debug bundle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("debug_bundle.files", len(entries)),
+		attribute.Int("debug_bundle.size_bytes", len(archive)),
+	)
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="om-debug-`+now.Format("20060102T150405Z")+`.tar.gz"`)
+	_, _ = w.Write(archive)
+}
+
+func (h *Handlers) debugBundleFiles(ctx context.Context) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	views := map[string]any{
+		"topology.json":       h.buildTopology(ctx, h.snap.View()),
+		"status.json":         h.startupStatus(),
+		"health-history.json": debugHealthHistory{Events: h.snap.HealthHistory()},
+		"versions.json":       h.versions(),
+	}
+	if h.capManager != nil {
+		s := h.capManager.Status()
+		views["capture.json"] = captureStatusResponse{
+			Running: s.Running, Interface: s.Interface, Generation: s.Generation,
+			PacketsTotal: s.PacketsTotal, Packets4G: s.Packets4G, Packets5G: s.Packets5G,
+			RestartCount: s.RestartCount, UptimeSeconds: s.UptimeSeconds,
+		}
+	}
+	if h.runtime != nil {
+		views["runtime.json"] = debugResponse{Enabled: true, Sample: h.runtime.Last()}
+	}
+	if h.debug.config != nil {
+		views["config/effective.json"] = h.debug.config
+	}
+	for name, v := range views {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[name] = b
+	}
+
+	for name, path := range h.debug.configFiles {
+		if b, err := os.ReadFile(path); err == nil {
+			files[filepath.ToSlash(filepath.Join("config", name))] = b
+		}
+	}
+	if h.debug.logs != nil {
+		files["logs/om-module.log"] = h.redactor.Text(h.debug.logs.Bytes())
+	}
+	return files, nil
+}
+
+// versions reports the module build and the image of every container.
+func (h *Handlers) versions() debugVersions {
+	v := debugVersions{
+		GoVersion:    runtime.Version(),
+		Dependencies: map[string]string{},
+		Containers:   map[string]string{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v.Module, v.Version = info.Main.Path, info.Main.Version
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				v.Revision = s.Value
+			case "vcs.time":
+				v.RevisionTime = s.Value
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+		for _, dep := range info.Deps {
+			v.Dependencies[dep.Path] = dep.Version
+		}
+	}
+	for name, cd := range h.snap.All() {
+		v.Containers[name] = cd.Image
+	}
+	return v
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/exposure"
+	"github.com/Parz1val02/OM_module/internal/ims"
+	"github.com/Parz1val02/OM_module/internal/insights"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/qos"
+	"github.com/Parz1val02/OM_module/internal/roaming"
+)
+
+// EducationOptions selects the teaching aids added to API responses and to
+// the /educational/ page. Intro courses get everything; advanced courses can
+// turn the hand-holding down.
+type EducationOptions struct {
+	// Notes: meanings and descriptions — cause meanings, milestone
+	// descriptions, 5QI/QCI typical uses, SIP and NAS security message
+	// explanations, N32 security, exposure API descriptions.
+	Notes bool
+	// Hints: the testbed misconfiguration that usually produces a cause, an
+	// N6 finding or a metric anomaly.
+	Hints bool
+	// Spec: 3GPP / IETF specification references.
+	Spec bool
+	// Flows: message-by-message walkthroughs (recent SIP messages at /ims,
+	// authentication steps at /nas/security).
+	Flows bool
+}
+
+// Education presets accepted by ParseEducationOptions and the "level" query
+// parameter.
+var educationPresets = map[string]EducationOptions{
+	"all":      {Notes: true, Hints: true, Spec: true, Flows: true},
+	"intro":    {Notes: true, Hints: true, Spec: true, Flows: true},
+	"advanced": {Spec: true},
+	"none":     {},
+}
+
+// ParseEducationOptions parses EDUCATIONAL_FEATURES: a preset ("intro",
+// "advanced", "all", "none") or a comma-separated list of toggles
+// ("notes,hints,spec,flows").
+func ParseEducationOptions(s string) (EducationOptions, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if o, ok := educationPresets[s]; ok {
+		return o, nil
+	}
+	var o EducationOptions
+	for _, f := range strings.Split(s, ",") {
+		p, ok := o.toggle(strings.TrimSpace(f))
+		if !ok {
+			return o, fmt.Errorf("unknown educational feature %q (want notes, hints, spec, flows or a preset)", f)
+		}
+		*p = true
+	}
+	return o, nil
+}
+
+func (o *EducationOptions) toggle(name string) (*bool, bool) {
+	switch name {
+	case "notes":
+		return &o.Notes, true
+	case "hints":
+		return &o.Hints, true
+	case "spec":
+		return &o.Spec, true
+	case "flows":
+		return &o.Flows, true
+	}
+	return nil, false
+}
+
+// withQuery applies per-request overrides: "level" selects a preset, then
+// "notes", "hints", "spec" and "flows" (true/false) override single toggles,
+// e.g. /causes?level=advanced&hints=true.
+func (o EducationOptions) withQuery(q url.Values) EducationOptions {
+	if p, ok := educationPresets[strings.ToLower(q.Get("level"))]; ok {
+		o = p
+	}
+	for _, name := range []string{"notes", "hints", "spec", "flows"} {
+		if v, err := strconv.ParseBool(q.Get(name)); err == nil {
+			p, _ := o.toggle(name)
+			*p = v
+		}
+	}
+	return o
+}
+
+// education returns the default educational content.
+func (h *Handlers) education() EducationOptions {
+	return *h.edu.Load()
+}
+
+// String lists the enabled toggles, for the startup banner.
+func (o EducationOptions) String() string {
+	var on []string
+	for _, name := range []string{"notes", "hints", "spec", "flows"} {
+		if p, _ := o.toggle(name); *p {
+			on = append(on, name)
+		}
+	}
+	if len(on) == 0 {
+		return "none"
+	}
+	return strings.Join(on, ",")
+}
+
+// --- Response filters --------------------------------------------------------
+
+func (o EducationOptions) causes(in []pipeline.CauseSummary) []pipeline.CauseSummary {
+	for i := range in {
+		if !o.Notes {
+			in[i].Meaning = ""
+		}
+		if !o.Hints {
+			in[i].Hint = ""
+		}
+		if !o.Spec {
+			in[i].Spec = ""
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) milestones(st milestone.Status) milestone.Status {
+	if o.Notes {
+		return st
+	}
+	for _, list := range [][]milestone.Milestone{st.Achieved, st.Pending} {
+		for i := range list {
+			list[i].Description = ""
+		}
+	}
+	return st
+}
+
+func (o EducationOptions) qosFlows(in []qos.Flow) []qos.Flow {
+	if !o.Notes {
+		for i := range in {
+			in[i].Class.Examples = ""
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) nasSecurity(in []pipeline.SecurityProcedure) []pipeline.SecurityProcedure {
+	for i := range in {
+		if !o.Flows {
+			in[i].Steps = []pipeline.SecurityStep{}
+		}
+		if !o.Notes {
+			for j := range in[i].Steps {
+				in[i].Steps[j].Explanation = ""
+			}
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) imsSummary(s ims.Summary) ims.Summary {
+	if !o.Flows {
+		s.Recent = []ims.Event{}
+	}
+	if o.Notes {
+		return s
+	}
+	for i := range s.Registrations {
+		s.Registrations[i].Explanation = ""
+	}
+	for i := range s.Calls {
+		s.Calls[i].Explanation = ""
+	}
+	for i := range s.Recent {
+		s.Recent[i].Explanation = ""
+	}
+	return s
+}
+
+func (o EducationOptions) roaming(in []roaming.Result) []roaming.Result {
+	if !o.Notes {
+		for i := range in {
+			in[i].N32.Explanation = ""
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) exposure(s exposure.Status) exposure.Status {
+	if !o.Notes {
+		for i := range s.APIs {
+			s.APIs[i].Description = ""
+		}
+	}
+	return s
+}
+
+func (o EducationOptions) n6(in []n6.Result) []n6.Result {
+	if !o.Hints {
+		for i := range in {
+			for j := range in[i].Findings {
+				in[i].Findings[j].Hint = ""
+			}
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) insights(in []insights.Card) []insights.Card {
+	for i := range in {
+		if !o.Notes {
+			in[i].Meaning = ""
+		}
+		if !o.Hints {
+			in[i].Hint = ""
+		}
+		if !o.Spec {
+			in[i].Spec = ""
+		}
+	}
+	return in
+}
+
+func (o EducationOptions) readings(in []insights.Reading) []insights.Reading {
+	for i := range in {
+		if !o.Notes {
+			in[i].Meaning = ""
+		}
+		if !o.Hints {
+			in[i].Hint = ""
+		}
+		if !o.Spec {
+			in[i].Spec = ""
+		}
+	}
+	return in
+}
+
+// Course trims institution content (EDUCATIONAL_PROVIDERS) like the
+// built-in content: notes and links follow Notes, hints Hints and
+// specifications Spec.
+func (o EducationOptions) Course(in []educontent.Item) []educontent.Item {
+	var out []educontent.Item
+	for _, it := range in {
+		switch {
+		case it.Kind == educontent.KindHint && !o.Hints,
+			it.Kind == educontent.KindSpec && !o.Spec,
+			(it.Kind == educontent.KindNote || it.Kind == educontent.KindLink) && !o.Notes:
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// spec returns ref when specification references are enabled.
+func (o EducationOptions) spec(ref string) string {
+	if o.Spec {
+		return ref
+	}
+	return ""
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/metriccatalog"
+	"github.com/Parz1val02/OM_module/internal/metricnames"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/qos"
+	"github.com/Parz1val02/OM_module/internal/roaming"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+//go:embed templates/educational.html
+var templateFS embed.FS
+
+var educationalTmpl = template.Must(template.New("educational.html").Funcs(template.FuncMap{
+	"serviceClass": func(g collector.ServiceGroup) string {
+		switch {
+		case g.Running == g.Replicas:
+			return "up"
+		case g.Running > 0:
+			return "partial"
+		}
+		return "down"
+	},
+}).ParseFS(templateFS, "templates/educational.html"))
+
+// educationalDomains is the left-to-right order of the topology diagram.
+var educationalDomains = []struct{ name, title string }{
+	{collector.DomainRAN, "RAN"},
+	{collector.DomainCore, "Core"},
+	{collector.DomainIMS, "IMS"},
+	{collector.DomainInfra, "Infraestructura"},
+	{collector.DomainObservability, "Observabilidad"},
+}
+
+// educationalDashboards are the dashboards the page links to, with the
+// kinds of NF they show; a dashboard with no kinds is about the whole lab.
+// A lab without any of the kinds still gets the link, marked as not in the
+// deployment: the dashboard exists, it is only empty.
+var educationalDashboards = []struct {
+	uid, title string
+	kinds      []collector.NFKind
+}{
+	{"topology", "Topología en vivo", nil},
+	{"4g-core", "EPC — 4G Core", []collector.NFKind{collector.KindMME, collector.KindSGWC, collector.KindSGWU, collector.KindHSS, collector.KindPCRF}},
+	{"5g-core", "5GC — 5G Core", []collector.NFKind{collector.KindAMF, collector.KindSMF, collector.KindUPF}},
+	{"qos-bearers", "QoS & Bearers", []collector.NFKind{collector.KindSMF, collector.KindSGWC}},
+	{"nas-security", "NAS Security", []collector.NFKind{collector.KindAMF, collector.KindMME}},
+	{"handover", "Handover", []collector.NFKind{collector.KindGNB, collector.KindENB}},
+	{"roaming", "Roaming — SEPP / N32", []collector.NFKind{collector.KindSEPP}},
+	{"exposure", "Exposure APIs — NEF", []collector.NFKind{collector.KindNEF}},
+	{"n6", "Acceso a internet — N6 / SGi", []collector.NFKind{collector.KindUPF}},
+	{"logging-pipeline", "Logging Pipeline Health", nil},
+	{"log-volume", "Log Volume", nil},
+	{"monitoring-stack", "Monitoring Stack Health", nil},
+	{"exporters", "Contenedores y host (cAdvisor / node_exporter)", nil},
+}
+
+// missingFrom returns the NFs dashboard uid shows, in upper case, when the
+// deployment runs none of them, and "" when it runs one or the dashboard
+// is not one of educationalDashboards.
+func missingFrom(uid string, d collector.Deployment) string {
+	for _, db := range educationalDashboards {
+		if db.uid != uid || db.kinds == nil || d.Has(db.kinds...) {
+			continue
+		}
+		names := make([]string, len(db.kinds))
+		for i, k := range db.kinds {
+			names[i] = strings.ToUpper(string(k))
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+type educationalDashboard struct {
+	UID   string
+	Title string
+	// Missing names the NFs the dashboard is about when the lab runs none.
+	Missing string
+}
+
+// educationalInterface is a reference point of the architecture and whether
+// the lab has it.
+type educationalInterface struct {
+	collector.ReferencePoint
+	Present bool
+}
+
+type educationalDomain struct {
+	Title    string
+	Services []collector.ServiceGroup
+}
+
+type educationalPage struct {
+	Generated  string
+	Project    string
+	Generation string
+	GrafanaURL string
+	Edu        EducationOptions
+	Domains    []educationalDomain
+	Deployment collector.Deployment
+	Interfaces []educationalInterface
+	Dashboards []educationalDashboard
+	Capture    *captureStatusResponse
+	Milestones *milestone.Status
+	QoSEnabled bool
+	QoSFlows   []qos.Flow
+	QoSSpec    string
+	Causes     []pipeline.CauseSummary // nil when the cause analyzer is disabled
+	Glossary   []metriccatalog.Metric  // nil when notes are off
+	Names      []metricnames.Name      // nil when notes are off
+
+	// SEPPs are the SEPP services of a roaming lab (nil without one) and
+	// Roaming the last check of each SEPP container.
+	SEPPs       []collector.ServiceGroup
+	Roaming     []roaming.Result
+	RoamingSpec string
+
+	// N6 is the last check of each UPF's path to the data network (nil
+	// when the N6 checks are disabled).
+	N6     []n6.Result
+	N6Spec string
+}
+
+// NotDeployed reports whether the lab lacks the NF nf names, for the
+// template; names that are no kind of the lab, or several, are deployed.
+func (p educationalPage) NotDeployed(nf string) bool {
+	k := collector.ParseNFKind(nf)
+	return k != collector.KindUnknown && !p.Deployment.Has(k)
+}
+
+// GenerationNotDeployed reports whether the lab lacks a core of
+// generation g, for the template.
+func (p educationalPage) GenerationNotDeployed(g string) bool {
+	return !p.Deployment.HasGeneration(g)
+}
+
+// --- /educational/ -------------------------------------------------------
+
+func (h *Handlers) handleEducational(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /educational/")
+	defer span.End()
+
+	// Grafana is published on port 3000 of the same host the student used
+	// to reach the module.
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = hostname
+	}
+
+	edu := h.education().withQuery(r.URL.Query())
+	var buf bytes.Buffer
+	if err := h.renderEducational(&buf, "http://"+net.JoinHostPort(host, "3000"), edu, time.Now()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "render failed", http.StatusInternalServerError)
+		return
+	}
+	span.SetAttributes(attribute.Int("educational.bytes", buf.Len()))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// --- /educational/mode ---------------------------------------------------
+
+type educationalModeResponse struct {
+	// Features is the default in EDUCATIONAL_FEATURES form.
+	Features string `json:"features"`
+	Notes    bool   `json:"notes"`
+	Hints    bool   `json:"hints"`
+	Spec     bool   `json:"spec"`
+	Flows    bool   `json:"flows"`
+}
+
+// handleEducationalMode reports the default educational content and, on
+// POST, switches it with the same parameters a request can override it
+// with ("level", then "notes", "hints", "spec", "flows"), e.g. POST
+// /educational/mode?level=advanced before an exam. The switch lasts until
+// the module restarts; the offline page is rewritten with it.
+func (h *Handlers) handleEducationalMode(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http."+r.Method+" /educational/mode")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		if level := q.Get("level"); level != "" {
+			if _, ok := educationPresets[strings.ToLower(level)]; !ok {
+				http.Error(w, "level must be one of all, intro, advanced, none", http.StatusBadRequest)
+				return
+			}
+		}
+		edu := h.education().withQuery(q)
+		h.edu.Store(&edu)
+		if h.regen != nil {
+			h.regen.Trigger("educational")
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	edu := h.education()
+	span.SetAttributes(attribute.String("educational.features", edu.String()))
+	writeJSON(w, r, educationalModeResponse{
+		Features: edu.String(),
+		Notes:    edu.Notes,
+		Hints:    edu.Hints,
+		Spec:     edu.Spec,
+		Flows:    edu.Flows,
+	})
+}
+
+// WriteEducational renders the educational page for offline viewing and
+// stages it in tx as dir/index.html; the commit replaces the file
+// atomically, so a browser never sees a partial page. grafanaURL is used
+// for the dashboard links; the page carries the default educational
+// content.
+func (h *Handlers) WriteEducational(tx *output.Txn, dir, grafanaURL string) error {
+	var buf bytes.Buffer
+	if err := h.renderEducational(&buf, grafanaURL, h.education(), time.Now()); err != nil {
+		return err
+	}
+	tx.WriteFile(filepath.Join(dir, "index.html"), buf.Bytes(), 0o644)
+	return nil
+}
+
+// EducationalInputs renders the page WriteEducational writes without its
+// timestamp, so a scheduler can tell whether rewriting it would change
+// anything.
+func (h *Handlers) EducationalInputs(grafanaURL string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := h.renderEducational(&buf, grafanaURL, h.education(), time.Time{})
+	return buf.Bytes(), err
+}
+
+// renderEducational renders the page; a zero generated time leaves the
+// timestamp out.
+func (h *Handlers) renderEducational(w io.Writer, grafanaURL string, edu EducationOptions, generated time.Time) error {
+	view := h.snap.View()
+	page := educationalPage{
+		Project:    h.project,
+		Generation: view.ActiveGeneration(),
+		GrafanaURL: grafanaURL,
+		Edu:        edu,
+		Deployment: view.Deployment(),
+	}
+	if !generated.IsZero() {
+		page.Generated = generated.Format("2006-01-02 15:04:05")
+	}
+
+	services := view.Services()
+	byDomain := make(map[string][]collector.ServiceGroup)
+	for _, g := range services {
+		byDomain[g.Domain] = append(byDomain[g.Domain], g)
+	}
+	for _, d := range educationalDomains {
+		// IMS is an optional add-on; only draw it when the lab runs one.
+		if d.name == collector.DomainIMS && len(byDomain[d.name]) == 0 {
+			continue
+		}
+		page.Domains = append(page.Domains, educationalDomain{Title: d.title, Services: byDomain[d.name]})
+	}
+	for _, rp := range collector.ReferencePoints() {
+		page.Interfaces = append(page.Interfaces, educationalInterface{ReferencePoint: rp, Present: page.Deployment.HasReferencePoint(rp)})
+	}
+	for _, db := range educationalDashboards {
+		page.Dashboards = append(page.Dashboards, educationalDashboard{
+			UID:     db.uid,
+			Title:   db.title,
+			Missing: missingFrom(db.uid, page.Deployment),
+		})
+	}
+
+	if h.capManager != nil {
+		s := h.capManager.Status()
+		page.Capture = &captureStatusResponse{
+			Running:      s.Running,
+			Interface:    s.Interface,
+			Generation:   s.Generation,
+			PacketsTotal: s.PacketsTotal,
+			Packets4G:    s.Packets4G,
+			Packets5G:    s.Packets5G,
+			Paused:       s.Paused,
+		}
+	}
+	if h.milestones != nil {
+		st := h.milestones.Status()
+		page.Milestones = &st
+	}
+	if h.qos != nil {
+		page.QoSEnabled = true
+		page.QoSFlows = h.qos.Flows()
+		page.QoSSpec = edu.spec(qos.FiveQISpec + " · " + qos.QCISpec)
+	}
+	if h.causes != nil {
+		page.Causes = edu.causes(h.causes.Summary(""))
+	}
+	for _, g := range services {
+		if g.NF == roaming.NFSEPP {
+			page.SEPPs = append(page.SEPPs, g)
+		}
+	}
+	if page.SEPPs != nil && h.roaming != nil {
+		page.Roaming = edu.roaming(h.roaming.Results())
+		page.RoamingSpec = edu.spec(roaming.Spec)
+	}
+	if h.n6 != nil {
+		page.N6 = edu.n6(h.n6.Results())
+		page.N6Spec = edu.spec(n6.Spec)
+	}
+	if edu.Notes {
+		// A gather error only leaves the glossary out.
+		page.Glossary, _ = h.metricsCatalog()
+		page.Names = h.names.All()
+	}
+
+	return educationalTmpl.Execute(w, page)
+}
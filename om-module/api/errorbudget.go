@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/errorbudget"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/logs/error-budget ----------------------------------------------
+
+type errorBudgetResponse struct {
+	Enabled bool `json:"enabled"`
+	errorbudget.Status
+}
+
+func (h *Handlers) handleErrorBudget(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/error-budget")
+	defer span.End()
+
+	resp := h.errorBudgetStatus()
+	span.SetAttributes(attribute.Int("error_budget.nfs", len(resp.Budgets)))
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) errorBudgetStatus() errorBudgetResponse {
+	if h.errorBudgets == nil {
+		return errorBudgetResponse{Status: errorbudget.Status{Budgets: []errorbudget.Budget{}}}
+	}
+	return errorBudgetResponse{Enabled: true, Status: h.errorBudgets.Status()}
+}
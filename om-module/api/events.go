@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Parz1val02/OM_module/internal/labevents"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxEventsBody bounds the body of POST /api/events; maxEventsBatch the
+// events in it.
+const (
+	maxEventsBody  = 1 << 20
+	maxEventsBatch = 100
+)
+
+// --- /api/events -----------------------------------------------------------
+
+type eventsResponse struct {
+	Enabled bool `json:"enabled"`
+	labevents.Status
+}
+
+// handleEvents serves the events reported by lab scripts, newest first
+// (GET ?type=&source=&limit=), or records new ones (POST, an event or an
+// array of events as JSON).
+func (h *Handlers) handleEvents(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http."+r.Method+" /api/events")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit := 0
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		resp := eventsResponse{Status: labevents.Status{Events: []labevents.Event{}}}
+		if h.labEvents != nil {
+			resp = eventsResponse{Enabled: true, Status: h.labEvents.Status(q.Get("type"), q.Get("source"), limit)}
+		}
+		span.SetAttributes(attribute.Int("lab_events.returned", len(resp.Events)))
+		writeJSON(w, r, resp)
+
+	case http.MethodPost:
+		if h.labEvents == nil {
+			http.Error(w, "lab events disabled", http.StatusServiceUnavailable)
+			return
+		}
+		events, err := readEvents(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i, e := range events {
+			if err := labevents.Validate(e); err != nil {
+				http.Error(w, fmt.Sprintf("event %d: %v", i, err), http.StatusBadRequest)
+				return
+			}
+		}
+		recorded := make([]labevents.Event, 0, len(events))
+		for i, e := range events {
+			e, err := h.labEvents.Record(e)
+			switch {
+			case errors.Is(err, labevents.ErrTooMany):
+				http.Error(w, fmt.Sprintf("event %d: %v", i, err), http.StatusTooManyRequests)
+				return
+			case err != nil:
+				http.Error(w, fmt.Sprintf("event %d: %v", i, err), http.StatusInternalServerError)
+				return
+			}
+			recorded = append(recorded, e)
+		}
+		span.SetAttributes(attribute.Int("lab_events.recorded", len(recorded)))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(recorded)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// readEvents decodes the body of POST /api/events: one event, or an array
+// of them.
+func readEvents(w http.ResponseWriter, r *http.Request) ([]labevents.Event, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventsBody))
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimSpace(body)
+	var events []labevents.Event
+	if bytes.HasPrefix(body, []byte("[")) {
+		err = json.Unmarshal(body, &events)
+	} else {
+		var e labevents.Event
+		err = json.Unmarshal(body, &e)
+		events = []labevents.Event{e}
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("invalid request: %v", err)
+	case len(events) == 0:
+		return nil, errors.New("no events")
+	case len(events) > maxEventsBatch:
+		return nil, fmt.Errorf("more than %d events", maxEventsBatch)
+	}
+	return events, nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/exporters ------------------------------------------------------
+
+type exportersResponse struct {
+	// ExternalContainerStats is true while cAdvisor provides the container
+	// resource metrics instead of the module.
+	ExternalContainerStats bool                 `json:"external_container_stats"`
+	Exporters              []collector.Exporter `json:"exporters"`
+	// ScrapeConfig is a Prometheus scrape_configs fragment for the running
+	// exporters, for setups that do not use the docker_sd jobs of
+	// prometheus/configs/prometheus.yml.
+	ScrapeConfig string `json:"scrape_config,omitempty"`
+}
+
+func (h *Handlers) handleExporters(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/exporters")
+	defer span.End()
+
+	exporters := h.snap.Exporters()
+	resp := exportersResponse{
+		ExternalContainerStats: h.snap.ExternalContainerStats(),
+		Exporters:              exporters,
+		ScrapeConfig:           scrapeConfig(exporters),
+	}
+	span.SetAttributes(
+		attribute.Int("exporters.detected", len(exporters)),
+		attribute.Bool("exporters.external_container_stats", resp.ExternalContainerStats),
+	)
+
+	writeJSON(w, r, resp)
+}
+
+// scrapeConfig renders one scrape job per exporter kind with the running
+// containers of that kind as targets. Job names match the docker_sd jobs of
+// the repository's prometheus.yml, so the dashboards work with either.
+func scrapeConfig(exporters []collector.Exporter) string {
+	var b strings.Builder
+	for i := 0; i < len(exporters); {
+		kind := exporters[i].Kind
+		var targets []string
+		for ; i < len(exporters) && exporters[i].Kind == kind; i++ {
+			if e := exporters[i]; e.Running() {
+				targets = append(targets, fmt.Sprintf("%q", fmt.Sprintf("%s:%d", e.Container, e.Port)))
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  - job_name: %s\n", kind)
+		fmt.Fprintf(&b, "    static_configs:\n")
+		fmt.Fprintf(&b, "      - targets: [%s]\n", strings.Join(targets, ", "))
+		fmt.Fprintf(&b, "    relabel_configs:\n")
+		fmt.Fprintf(&b, "      - source_labels: [__address__]\n")
+		fmt.Fprintf(&b, "        regex: \"(.+):\\\\d+\"\n")
+		fmt.Fprintf(&b, "        target_label: container\n")
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "scrape_configs:\n" + b.String()
+}
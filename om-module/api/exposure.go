@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/exposure"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /exposure -----------------------------------------------------------
+
+type exposureResponse struct {
+	Enabled    bool              `json:"enabled"`
+	Components []topologyService `json:"components"`
+	exposure.Status
+	Spec string `json:"spec,omitempty"`
+}
+
+// handleExposure lists the NEF services of an IoT lab with the invocations
+// of their northbound APIs and the event exposure subscriptions made
+// through them.
+func (h *Handlers) handleExposure(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /exposure")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := exposureResponse{
+		Enabled:    h.exposure != nil,
+		Spec:       edu.spec(exposure.Spec),
+		Components: []topologyService{},
+		Status:     exposure.Status{NFs: []string{}, APIs: []exposure.API{}, Recent: []exposure.Invocation{}},
+	}
+	for _, g := range h.snap.Services() {
+		if g.NF != exposure.NFNEF {
+			continue
+		}
+		resp.Components = append(resp.Components, topologyService{
+			ComposeProject: g.ComposeProject, Service: g.Service,
+			Domain: g.Domain, NF: g.NF, Generation: g.Generation,
+			Owner: g.Owner, Contact: g.Contact, Description: g.Description,
+			Replicas: g.Replicas, Running: g.Running, Containers: g.Containers,
+		})
+	}
+	if h.exposure != nil {
+		resp.Status = edu.exposure(h.exposure.Status())
+	}
+	span.SetAttributes(
+		attribute.Int("exposure.components", len(resp.Components)),
+		attribute.Int("exposure.apis", len(resp.APIs)),
+	)
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/featureflags"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/flags ------------------------------------------------------------
+
+type flagsResponse struct {
+	Enabled bool `json:"enabled"`
+	// Lab is the name percentage rollouts are decided on.
+	Lab   string              `json:"lab,omitempty"`
+	Flags []featureflags.Flag `json:"flags"`
+}
+
+func (h *Handlers) handleFlags(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/flags")
+	defer span.End()
+
+	resp := flagsResponse{Flags: h.flags.Flags()}
+	if h.flags != nil {
+		resp.Enabled, resp.Lab = true, h.flags.Lab()
+	}
+	span.SetAttributes(attribute.Int("flags.count", len(resp.Flags)))
+
+	writeJSON(w, r, resp)
+}
+
+// handleFlag serves one flag: GET shows it, POST ?enabled=true|false
+// overrides it until the module restarts and DELETE drops the override.
+func (h *Handlers) handleFlag(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/flags/")
+	if name == "" {
+		h.handleFlags(w, r)
+		return
+	}
+	_, span := tracing.Tracer().Start(r.Context(), "http."+r.Method+" /api/flags/{name}")
+	defer span.End()
+	span.SetAttributes(attribute.String("flag.name", name))
+
+	if h.flags == nil {
+		http.Error(w, "feature flags disabled", http.StatusServiceUnavailable)
+		return
+	}
+	var (
+		flag featureflags.Flag
+		ok   bool
+	)
+	switch r.Method {
+	case http.MethodGet:
+		for _, f := range h.flags.Flags() {
+			if f.Name == name {
+				flag, ok = f, true
+			}
+		}
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		flag, ok = h.flags.Override(name, enabled)
+	case http.MethodDelete:
+		flag, ok = h.flags.Reset(name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	span.SetAttributes(attribute.Bool("flag.enabled", flag.Enabled), attribute.String("flag.source", flag.Source))
+
+	writeJSON(w, r, flag)
+}
+
+// --- /api/version ----------------------------------------------------------
+
+type versionResponse struct {
+	debugVersions
+	FeatureFlags []featureflags.Flag `json:"feature_flags"`
+}
+
+// handleVersion serves the module build, the images of the containers and
+// the feature flags of the lab.
+func (h *Handlers) handleVersion(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/version")
+	defer span.End()
+
+	resp := versionResponse{debugVersions: h.versions(), FeatureFlags: h.flags.Flags()}
+	span.SetAttributes(attribute.String("version.module", resp.Version))
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /flows ---------------------------------------------------------------
+
+type flowsResponse struct {
+	Enabled bool            `json:"enabled"`
+	Flows   []pipeline.Flow `json:"flows"`
+}
+
+func (h *Handlers) handleFlows(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /flows")
+	defer span.End()
+
+	resp := flowsResponse{Flows: []pipeline.Flow{}}
+	if h.flows != nil {
+		resp.Enabled = true
+		resp.Flows = h.flows.Flows(r.URL.Query().Get("generation"), r.URL.Query().Get("imsi"))
+	}
+	span.SetAttributes(attribute.Int("flows.count", len(resp.Flows)))
+
+	writeJSON(w, r, resp)
+}
+
+// flowFormats are the renderings of /flows/{id}?format=, besides json.
+var flowFormats = map[string]struct {
+	contentType string
+	render      func(pipeline.Flow) string
+}{
+	"mermaid":  {"text/plain; charset=utf-8", pipeline.Flow.Mermaid},
+	"plantuml": {"text/plain; charset=utf-8", pipeline.Flow.PlantUML},
+	"svg":      {"image/svg+xml", pipeline.Flow.SVG},
+}
+
+func (h *Handlers) handleFlow(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /flows/{id}")
+	defer span.End()
+
+	id := strings.TrimPrefix(r.URL.Path, "/flows/")
+	format := r.URL.Query().Get("format")
+	switch {
+	case id == "":
+		h.handleFlows(w, r)
+		return
+	case h.flows == nil:
+		http.Error(w, "session flows disabled", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("flows.id", id), attribute.String("flows.format", format))
+
+	f, ok := h.flows.Flow(id)
+	if !ok {
+		http.Error(w, "unknown flow "+id, http.StatusNotFound)
+		return
+	}
+	if format == "" || format == "json" {
+		writeJSON(w, r, f)
+		return
+	}
+	out, ok := flowFormats[format]
+	if !ok {
+		http.Error(w, "format must be json, mermaid, plantuml or svg", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", out.contentType)
+	_, _ = w.Write([]byte(out.render(f)))
+}
@@ -0,0 +1,23 @@
+//go:build ignore
+
+// gen_openapi writes openapi.json, the OpenAPI description the module
+// serves on /api/openapi.json, from the response types of the api
+// package. Run it with `go generate ./api`.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/Parz1val02/OM_module/api"
+)
+
+func main() {
+	spec, err := api.OpenAPI()
+	if err != nil {
+		log.Fatalf("openapi: %v", err)
+	}
+	if err := os.WriteFile("openapi.json", append(spec, '\n'), 0o644); err != nil {
+		log.Fatalf("openapi: %v", err)
+	}
+}
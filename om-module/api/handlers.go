@@ -1,13 +1,57 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/Parz1val02/OM_module/internal/artifacts"
 	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/cardinality"
+	"github.com/Parz1val02/OM_module/internal/cluster"
 	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/errorbudget"
+	"github.com/Parz1val02/OM_module/internal/exposure"
+	"github.com/Parz1val02/OM_module/internal/featureflags"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/health"
+	"github.com/Parz1val02/OM_module/internal/ims"
+	"github.com/Parz1val02/OM_module/internal/incident"
+	"github.com/Parz1val02/OM_module/internal/insights"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/labevents"
+	"github.com/Parz1val02/OM_module/internal/labsession"
+	"github.com/Parz1val02/OM_module/internal/lease"
+	"github.com/Parz1val02/OM_module/internal/logaudit"
+	"github.com/Parz1val02/OM_module/internal/logbuffer"
+	"github.com/Parz1val02/OM_module/internal/logexport"
+	"github.com/Parz1val02/OM_module/internal/logsampling"
+	"github.com/Parz1val02/OM_module/internal/metricbuffer"
+	"github.com/Parz1val02/OM_module/internal/metricnames"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/promtail"
+	"github.com/Parz1val02/OM_module/internal/qos"
+	"github.com/Parz1val02/OM_module/internal/querylint"
+	"github.com/Parz1val02/OM_module/internal/ranconfig"
+	"github.com/Parz1val02/OM_module/internal/readiness"
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/Parz1val02/OM_module/internal/regen"
+	"github.com/Parz1val02/OM_module/internal/roaming"
+	"github.com/Parz1val02/OM_module/internal/runtimestats"
+	"github.com/Parz1val02/OM_module/internal/simmetrics"
+	"github.com/Parz1val02/OM_module/internal/slo"
+	"github.com/Parz1val02/OM_module/internal/soak"
+	"github.com/Parz1val02/OM_module/internal/subscribers"
+	"github.com/Parz1val02/OM_module/internal/synthetic"
+	"github.com/Parz1val02/OM_module/internal/targets"
 	"github.com/Parz1val02/OM_module/internal/tracing"
+	"github.com/Parz1val02/OM_module/internal/troubleshoot"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/attribute"
@@ -16,33 +60,313 @@ import (
 
 // Handlers bundles the HTTP handler dependencies.
 type Handlers struct {
-	snap       *collector.Snapshot
-	project    string
-	reg        *prometheus.Registry
-	capManager *capture.Manager
+	snap         *collector.Snapshot
+	project      string
+	reg          *prometheus.Registry
+	capManager   *capture.Manager
+	sbi          *pipeline.SBIAnalyzer
+	causes       *pipeline.CauseAnalyzer
+	milestones   *milestone.Engine
+	qos          *qos.Tracker
+	security     *pipeline.SecurityAnalyzer
+	handovers    *pipeline.HandoverAnalyzer
+	flows        *pipeline.FlowRecorder
+	cluster      *cluster.Aggregator
+	ims          *ims.Analyzer
+	imsProber    *ims.Prober
+	dashboards   *dashboards.Inventory
+	grafana      *grafana.Client
+	folder       grafana.Folder
+	runtime      *runtimestats.Monitor
+	synthetic    *synthetic.Runner
+	artifacts    artifacts.Store
+	errorBudgets *errorbudget.Tracker
+	deps         *readiness.Report
+	edu          atomic.Pointer[EducationOptions]
+	cache        *responseCache
+	written      *output.Manifest
+	regen        *regen.Scheduler
+	names        *metricnames.Map
+	incidents    *incident.Querier
+	sampling     *logsampling.Reporter
+	roaming      *roaming.Prober
+	exposure     *exposure.Watcher
+	promtail     *promtail.Manager
+	n6           *n6.Prober
+	soak         *soak.Soak
+	subscribers  *subscribers.Watcher
+	insights     *insights.Engine
+	course       *educontent.Providers
+	kpis         *kpi.Prometheus
+	lint         *querylint.Linter
+	health       *health.Evaluator
+	healthChecks *health.Prober
+	slo          *slo.Evaluator
+	metricBuffer *metricbuffer.Buffer
+	cardinality  *cardinality.Manager
+	redactor     *redact.Redactor
+	lease        *lease.Lease
+	targets      *targets.Checker
+	flags        *featureflags.Set
+	logAudit     *logaudit.Auditor
+	ranConfig    *ranconfig.Checker
+	logExport    *logexport.Exporter
+	troubleshoot *troubleshoot.Engine
+	sessions     *labsession.Manager
+	labEvents    *labevents.Recorder
+	simulated    *simmetrics.Fallback
+	cadvisor     http.Handler
+	debug        debugSources
 }
 
-// New creates a Handlers instance.
-func New(
-	snap *collector.Snapshot,
-	project string,
-	reg *prometheus.Registry,
-	capManager *capture.Manager,
-) *Handlers {
-	return &Handlers{
-		snap:       snap,
-		project:    project,
-		reg:        reg,
-		capManager: capManager,
+// Options are the dependencies of the handlers. Snapshot, Project,
+// Registry and Deps are required. Every other field may be left zero when
+// its subsystem is disabled; its endpoints then answer that it is.
+type Options struct {
+	Snapshot *collector.Snapshot
+	Project  string
+	Registry *prometheus.Registry
+	// Deps is the outcome of the startup readiness phase.
+	Deps *readiness.Report
+	// Education is the default educational content; requests can override
+	// it (see EducationOptions) and POST /educational/mode switches it.
+	Education EducationOptions
+
+	Capture      *capture.Manager
+	SBI          *pipeline.SBIAnalyzer
+	Causes       *pipeline.CauseAnalyzer
+	Milestones   *milestone.Engine
+	QoS          *qos.Tracker
+	Security     *pipeline.SecurityAnalyzer
+	Handovers    *pipeline.HandoverAnalyzer
+	Flows        *pipeline.FlowRecorder // per-UE flows of /flows
+	Cluster      *cluster.Aggregator
+	IMS          *ims.Analyzer
+	IMSProber    *ims.Prober
+	Runtime      *runtimestats.Monitor
+	Synthetic    *synthetic.Runner
+	Artifacts    artifacts.Store
+	ErrorBudgets *errorbudget.Tracker
+
+	Dashboards    *dashboards.Inventory
+	DashboardLint *querylint.Linter // /api/dashboards/lint
+	Grafana       *grafana.Client
+	// DashboardFolder is the Grafana folder the provisioned dashboards are
+	// in, so uploads of POST /api/dashboards/{uid}/reload do not move them.
+	DashboardFolder grafana.Folder
+
+	// Manifest records the files written by WriteBundle and WriteStateDump.
+	Manifest *output.Manifest
+	// Regen regenerates the files derived from the topology (/api/regen).
+	Regen *regen.Scheduler
+	// MetricNames are the friendly titles of raw metric names, for the
+	// glossary and /api/metrics/names.
+	MetricNames *metricnames.Map
+	// Incidents queries Loki and Prometheus for /api/incident/review;
+	// without it, reviews only have what the module saw itself.
+	Incidents *incident.Querier
+	// Troubleshooter holds the symptom decision trees of /api/troubleshoot.
+	Troubleshooter *troubleshoot.Engine
+	// KPIs is the Prometheus the live KPIs of /api/kpi are evaluated by.
+	KPIs *kpi.Prometheus
+	// Targets compares the intended and scraped Prometheus targets.
+	Targets *targets.Checker
+
+	Roaming     *roaming.Prober   // /roaming and the educational page
+	N6          *n6.Prober        // /n6 and the educational page
+	Exposure    *exposure.Watcher // NEF log watcher of /exposure
+	Subscribers *subscribers.Watcher
+	RANConfig   *ranconfig.Checker // /api/config/consistency
+
+	Promtail    *promtail.Manager // /logging/*
+	LogSampling *logsampling.Reporter
+	LogAudit    *logaudit.Auditor   // Open5GS logger configurations
+	LogExport   *logexport.Exporter // /api/logs/files
+	// Redactor is applied to /api/logs/redaction and to the module log of
+	// debug bundles.
+	Redactor *redact.Redactor
+
+	Insights *insights.Engine
+	// Course is the institution's own content for the insight cards and the
+	// walkthroughs of /nas/security and /ims.
+	Course *educontent.Providers
+
+	Health       *health.Evaluator
+	HealthChecks *health.Prober
+	SLO          *slo.Evaluator
+	MetricBuffer *metricbuffer.Buffer
+	Cardinality  *cardinality.Manager
+	// SimulatedMetrics stands in for the NFs whose metrics endpoint does
+	// not answer (/metrics/simulated, /api/metrics/simulated).
+	SimulatedMetrics *simmetrics.Fallback
+	// CAdvisorMetrics serves the cAdvisor-compatible container metrics
+	// (exporter.NewCAdvisor) on /metrics/cadvisor.
+	CAdvisorMetrics http.Handler
+
+	// FeatureFlags are shown by /api/flags, /api/version and /status.
+	FeatureFlags *featureflags.Set
+	Lease        *lease.Lease // the instance lease this module holds
+	Soak         *soak.Soak
+	Sessions     *labsession.Manager
+	LabEvents    *labevents.Recorder
+
+	// DebugConfig (redact credentials first), ModuleLogs and ConfigFiles,
+	// by name in the bundle, go into debug bundles.
+	DebugConfig any
+	ModuleLogs  *logbuffer.Buffer
+	ConfigFiles map[string]string
+}
+
+// New returns the handlers of the dependencies in opts.
+func New(opts Options) *Handlers {
+	h := &Handlers{
+		snap:         opts.Snapshot,
+		project:      opts.Project,
+		reg:          opts.Registry,
+		deps:         opts.Deps,
+		capManager:   opts.Capture,
+		sbi:          opts.SBI,
+		causes:       opts.Causes,
+		milestones:   opts.Milestones,
+		qos:          opts.QoS,
+		security:     opts.Security,
+		handovers:    opts.Handovers,
+		flows:        opts.Flows,
+		cluster:      opts.Cluster,
+		ims:          opts.IMS,
+		imsProber:    opts.IMSProber,
+		runtime:      opts.Runtime,
+		synthetic:    opts.Synthetic,
+		artifacts:    opts.Artifacts,
+		errorBudgets: opts.ErrorBudgets,
+		dashboards:   opts.Dashboards,
+		lint:         opts.DashboardLint,
+		grafana:      opts.Grafana,
+		folder:       opts.DashboardFolder,
+		written:      opts.Manifest,
+		regen:        opts.Regen,
+		names:        opts.MetricNames,
+		incidents:    opts.Incidents,
+		troubleshoot: opts.Troubleshooter,
+		kpis:         opts.KPIs,
+		targets:      opts.Targets,
+		roaming:      opts.Roaming,
+		n6:           opts.N6,
+		exposure:     opts.Exposure,
+		subscribers:  opts.Subscribers,
+		ranConfig:    opts.RANConfig,
+		promtail:     opts.Promtail,
+		sampling:     opts.LogSampling,
+		logAudit:     opts.LogAudit,
+		logExport:    opts.LogExport,
+		redactor:     opts.Redactor,
+		insights:     opts.Insights,
+		course:       opts.Course,
+		health:       opts.Health,
+		healthChecks: opts.HealthChecks,
+		slo:          opts.SLO,
+		metricBuffer: opts.MetricBuffer,
+		cardinality:  opts.Cardinality,
+		simulated:    opts.SimulatedMetrics,
+		cadvisor:     opts.CAdvisorMetrics,
+		flags:        opts.FeatureFlags,
+		lease:        opts.Lease,
+		soak:         opts.Soak,
+		sessions:     opts.Sessions,
+		labEvents:    opts.LabEvents,
+		debug:        debugSources{config: opts.DebugConfig, logs: opts.ModuleLogs, configFiles: opts.ConfigFiles},
+		cache:        newResponseCache(),
 	}
+	h.edu.Store(&opts.Education)
+	return h
+}
+
+// Mux is what Register wires the routes onto; *http.ServeMux is one.
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
 }
 
 // Register wires all routes onto mux.
-func (h *Handlers) Register(mux *http.ServeMux) {
-	mux.Handle("/metrics", promhttp.HandlerFor(h.reg, promhttp.HandlerOpts{}))
+func (h *Handlers) Register(mux Mux) {
+	mux.Handle("/metrics", promhttp.HandlerFor(h.reg, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	mux.HandleFunc("/metrics/simulated", h.handleSimulatedMetrics)
+	mux.HandleFunc("/metrics/cadvisor", h.handleCAdvisorMetrics)
 	mux.HandleFunc("/topology", h.handleTopology)
 	mux.HandleFunc("/ping", h.handlePing)
+	mux.HandleFunc("/status", h.handleStatus)
 	mux.HandleFunc("/capture/status", h.handleCaptureStatus)
+	mux.HandleFunc("/capture/sbi", h.handleCaptureSBI)
+	mux.HandleFunc("/causes", h.handleCauses)
+	mux.HandleFunc("/milestones", h.handleMilestones)
+	mux.HandleFunc("/milestones/reset", h.handleMilestonesReset)
+	mux.HandleFunc("/qos", h.handleQoS)
+	mux.HandleFunc("/nas/security", h.handleNASSecurity)
+	mux.HandleFunc("/handovers", h.handleHandovers)
+	mux.HandleFunc("/flows", h.handleFlows)
+	mux.HandleFunc("/flows/", h.handleFlow)
+	mux.HandleFunc("/educational/", h.handleEducational)
+	mux.HandleFunc("/educational/insights", h.handleInsights)
+	mux.HandleFunc("/educational/mode", h.handleEducationalMode)
+	mux.HandleFunc("/cluster", h.handleCluster)
+	mux.HandleFunc("/ims", h.handleIMS)
+	mux.HandleFunc("/roaming", h.handleRoaming)
+	mux.HandleFunc("/exposure", h.handleExposure)
+	mux.HandleFunc("/n6", h.handleN6)
+	mux.HandleFunc("/logging/status", h.handleLoggingStatus)
+	mux.HandleFunc("/logging/start", h.handleLoggingAction(promtail.ActionStart))
+	mux.HandleFunc("/logging/stop", h.handleLoggingAction(promtail.ActionStop))
+	mux.HandleFunc("/logging/restart", h.handleLoggingAction(promtail.ActionRestart))
+	mux.HandleFunc("/logging/reload", h.handleLoggingAction(promtail.ActionReload))
+	mux.HandleFunc("/api/dashboards", h.handleDashboards)
+	mux.HandleFunc("/api/dashboards/", h.handleDashboard)
+	mux.HandleFunc("/api/exporters", h.handleExporters)
+	mux.HandleFunc("/api/metrics/catalog", h.handleMetricsCatalog)
+	mux.HandleFunc("/api/metrics/names", h.handleMetricNames)
+	mux.HandleFunc("/api/metrics/buffer", h.handleMetricBuffer)
+	mux.HandleFunc("/api/metrics/cardinality", h.handleCardinality)
+	mux.HandleFunc("/api/metrics/simulated", h.handleSimulatedMetricsStatus)
+	mux.HandleFunc("/api/logs/error-budget", h.handleErrorBudget)
+	mux.HandleFunc("/api/logs/sampling", h.handleLogSampling)
+	mux.HandleFunc("/api/logs/redaction", h.handleRedaction)
+	mux.HandleFunc("/api/logs/audit", h.handleLogAudit)
+	mux.HandleFunc("/api/logs/audit/fix", h.handleLogAuditFix)
+	mux.HandleFunc("/api/logs/files", h.handleLogFiles)
+	mux.HandleFunc("/api/logs/files/", h.handleLogFile)
+	mux.HandleFunc("/api/logs/parse", h.handleLogParse)
+	mux.HandleFunc("/api/config/consistency", h.handleRANConfig)
+	mux.HandleFunc("/api/lease", h.handleLease)
+	mux.HandleFunc("/api/subscribers/drift", h.handleSubscriberDrift)
+	mux.HandleFunc("/api/health", h.handleHealth)
+	mux.HandleFunc("/api/health/checks", h.handleHealthChecks)
+	mux.HandleFunc("/api/slo", h.handleSLO)
+	mux.HandleFunc("/api/kpi", h.handleKPIs)
+	mux.HandleFunc("/api/targets", h.handleTargets)
+	mux.HandleFunc("/api/flags", h.handleFlags)
+	mux.HandleFunc("/api/flags/", h.handleFlag)
+	mux.HandleFunc("/api/version", h.handleVersion)
+	mux.HandleFunc("/api/openapi.json", h.handleOpenAPI)
+	mux.HandleFunc("/api/docs", h.handleAPIDocs)
+	mux.Handle("/api/docs/static/", swaggerAssets())
+	mux.HandleFunc("/api/kpi/", h.handleKPI)
+	mux.HandleFunc("/api/incident/review", h.handleIncidentReview)
+	mux.HandleFunc("/api/troubleshoot", h.handleTroubleshoot)
+	mux.HandleFunc("/api/sessions", h.handleSessions)
+	mux.HandleFunc("/api/sessions/close", h.handleSessionClose)
+	mux.HandleFunc("/api/sessions/", h.handleSessionReport)
+	mux.HandleFunc("/api/events", h.handleEvents)
+	mux.HandleFunc("/api/regen", h.handleRegen)
+	mux.HandleFunc("/api/soak", h.handleSoak)
+	mux.HandleFunc("/api/soak/start", h.handleSoakStart)
+	mux.HandleFunc("/api/loki/labels", h.handleLokiLabels)
+	mux.HandleFunc("/api/loki/labels/check", h.handleLokiLabelsCheck)
+	mux.HandleFunc("/api/artifacts", h.handleArtifacts)
+	mux.HandleFunc("/api/artifacts/", h.handleArtifact)
+	mux.HandleFunc("/synthetic", h.handleSynthetic)
+	mux.HandleFunc("/synthetic/run", h.handleSyntheticRun)
+	mux.HandleFunc("/internal/debug", h.handleDebug)
+	mux.HandleFunc("/api/debug/bundle", h.handleDebugBundle)
+	mux.HandleFunc("/api/debug/state", h.handleStateDump)
 }
 
 // --- /ping ---------------------------------------------------------------
@@ -57,14 +381,38 @@ func (h *Handlers) handlePing(w http.ResponseWriter, r *http.Request) {
 // --- /topology -----------------------------------------------------------
 
 type topologyContainer struct {
-	Name       string  `json:"name"`
-	State      string  `json:"state"`
-	Image      string  `json:"image"`
-	Domain     string  `json:"domain"`
-	NF         string  `json:"nf"`
-	Generation string  `json:"generation"`
-	Project    string  `json:"project"`
-	Health     float64 `json:"health_status"`
+	Name           string  `json:"name"`
+	State          string  `json:"state"`
+	Image          string  `json:"image"`
+	Domain         string  `json:"domain"`
+	NF             string  `json:"nf"`
+	NFKind         string  `json:"nf_kind"`
+	NFKindSource   string  `json:"nf_kind_source,omitempty"`
+	Generation     string  `json:"generation"`
+	Project        string  `json:"project"`
+	ComposeProject string  `json:"compose_project"`
+	Service        string  `json:"service"`
+	Replica        int     `json:"replica"`
+	Component      string  `json:"component"`
+	Owner          string  `json:"owner,omitempty"`
+	Contact        string  `json:"contact,omitempty"`
+	Description    string  `json:"description,omitempty"`
+	Health         float64 `json:"health_status"`
+}
+
+type topologyService struct {
+	ComposeProject string   `json:"compose_project"`
+	Service        string   `json:"service"`
+	Domain         string   `json:"domain"`
+	NF             string   `json:"nf"`
+	NFKind         string   `json:"nf_kind"`
+	Generation     string   `json:"generation"`
+	Owner          string   `json:"owner,omitempty"`
+	Contact        string   `json:"contact,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	Replicas       int      `json:"replicas"`
+	Running        int      `json:"running"`
+	Containers     []string `json:"containers"`
 }
 
 type topologyResponse struct {
@@ -75,14 +423,40 @@ type topologyResponse struct {
 	Running    int                 `json:"running"`
 	Stopped    int                 `json:"stopped"`
 	Containers []topologyContainer `json:"containers"`
+	Services   []topologyService   `json:"services"`
+	Links      []collector.Link    `json:"links"`
 }
 
+// handleTopology serves the topology from the response cache; it is only
+// rebuilt when the collector stores a new snapshot.
 func (h *Handlers) handleTopology(w http.ResponseWriter, r *http.Request) {
 	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /topology")
 	defer span.End()
 
+	cached := true
+	view := h.snap.View()
+	v, body, etag, err := h.cache.get("topology", view.Version, func() any {
+		cached = false
+		return h.buildTopology(ctx, view)
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "encode failed", http.StatusInternalServerError)
+		return
+	}
+	span.SetAttributes(attribute.Bool("topology.cached", cached))
+	if v.(topologyResponse).Status == "degraded" {
+		span.SetStatus(codes.Error, "one or more containers stopped")
+	}
+	writeBody(w, r, body, etag)
+}
+
+// buildTopology describes view; containers and services come from the same
+// collection cycle.
+func (h *Handlers) buildTopology(ctx context.Context, view *collector.View) topologyResponse {
 	_, snapSpan := tracing.Tracer().Start(ctx, "topology.read_snapshot")
-	all := h.snap.All()
+	all := view.All()
 	snapSpan.SetAttributes(attribute.Int("snapshot.container_count", len(all)))
 	snapSpan.End()
 
@@ -105,7 +479,27 @@ func (h *Handlers) handleTopology(w http.ResponseWriter, r *http.Request) {
 		resp.Containers = append(resp.Containers, topologyContainer{
 			Name: cd.Name, State: cd.State, Image: cd.Image,
 			Domain: cd.Domain, NF: cd.NF, Generation: cd.Generation,
-			Project: cd.Project, Health: cd.HealthValue(),
+			NFKind: string(cd.NFKind), NFKindSource: cd.NFKindSource,
+			Project: cd.Project, ComposeProject: cd.ComposeProject,
+			Service: cd.Service, Replica: cd.Replica, Component: cd.Component,
+			Owner: cd.Owner, Contact: cd.Contact, Description: cd.Description,
+			Health: cd.HealthValue(),
+		})
+	}
+
+	resp.Links = collector.Links(all)
+	if resp.Links == nil {
+		resp.Links = []collector.Link{}
+	}
+
+	groups := view.Services()
+	resp.Services = make([]topologyService, 0, len(groups))
+	for _, g := range groups {
+		resp.Services = append(resp.Services, topologyService{
+			ComposeProject: g.ComposeProject, Service: g.Service,
+			Domain: g.Domain, NF: g.NF, NFKind: string(g.NFKind), Generation: g.Generation,
+			Owner: g.Owner, Contact: g.Contact, Description: g.Description,
+			Replicas: g.Replicas, Running: g.Running, Containers: g.Containers,
 		})
 	}
 
@@ -113,15 +507,12 @@ func (h *Handlers) handleTopology(w http.ResponseWriter, r *http.Request) {
 		attribute.Int("topology.total", resp.Total),
 		attribute.Int("topology.running", resp.Running),
 		attribute.Int("topology.stopped", resp.Stopped),
+		attribute.Int("topology.services", len(resp.Services)),
 		attribute.String("topology.status", resp.Status),
 	)
-	if resp.Status == "degraded" {
-		span.SetStatus(codes.Error, "one or more containers stopped")
-	}
 	buildSpan.End()
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	return resp
 }
 
 // --- /capture/status -----------------------------------------------------
@@ -136,6 +527,8 @@ type captureStatusResponse struct {
 	RestartCount  uint64  `json:"restart_count"`
 	UptimeSeconds float64 `json:"uptime_seconds"`
 	ActiveProcs   int     `json:"active_procedures"`
+	// Paused is true while the capture feature flag is off.
+	Paused bool `json:"paused,omitempty"`
 }
 
 func (h *Handlers) handleCaptureStatus(w http.ResponseWriter, r *http.Request) {
@@ -155,9 +548,223 @@ func (h *Handlers) handleCaptureStatus(w http.ResponseWriter, r *http.Request) {
 			Packets5G:     s.Packets5G,
 			RestartCount:  s.RestartCount,
 			UptimeSeconds: s.UptimeSeconds,
+			Paused:        s.Paused,
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	writeJSON(w, r, resp)
+}
+
+// --- /capture/sbi --------------------------------------------------------
+
+type captureSBIResponse struct {
+	Enabled bool                      `json:"enabled"`
+	Pairs   []pipeline.SBIPairSummary `json:"pairs"`
+}
+
+func (h *Handlers) handleCaptureSBI(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /capture/sbi")
+	defer span.End()
+
+	resp := captureSBIResponse{Pairs: []pipeline.SBIPairSummary{}}
+	if h.sbi != nil {
+		resp.Enabled = true
+		resp.Pairs = h.sbi.Summary()
+	}
+	span.SetAttributes(attribute.Int("sbi.pairs", len(resp.Pairs)))
+
+	writeJSON(w, r, resp)
+}
+
+// --- /causes -------------------------------------------------------------
+
+type causesResponse struct {
+	Enabled bool                    `json:"enabled"`
+	Causes  []pipeline.CauseSummary `json:"causes"`
+}
+
+func (h *Handlers) handleCauses(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /causes")
+	defer span.End()
+
+	resp := causesResponse{Causes: []pipeline.CauseSummary{}}
+	if h.causes != nil {
+		resp.Enabled = true
+		edu := h.education().withQuery(r.URL.Query())
+		resp.Causes = edu.causes(h.causes.Summary(r.URL.Query().Get("generation")))
+	}
+	span.SetAttributes(attribute.Int("causes.count", len(resp.Causes)))
+
+	writeJSON(w, r, resp)
+}
+
+// --- /milestones ---------------------------------------------------------
+
+type milestonesResponse struct {
+	Enabled bool `json:"enabled"`
+	milestone.Status
+}
+
+func (h *Handlers) handleMilestones(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /milestones")
+	defer span.End()
+
+	var resp milestonesResponse
+	if h.milestones != nil {
+		resp.Enabled = true
+		resp.Status = h.education().withQuery(r.URL.Query()).milestones(h.milestones.Status())
+		span.SetAttributes(attribute.Int("milestones.achieved", len(resp.Achieved)))
+	}
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) handleMilestonesReset(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.POST /milestones/reset")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.milestones == nil {
+		http.Error(w, "milestone engine disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	h.milestones.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- /qos ----------------------------------------------------------------
+
+type qosResponse struct {
+	Enabled bool       `json:"enabled"`
+	Spec    []string   `json:"spec,omitempty"`
+	Flows   []qos.Flow `json:"flows"`
+}
+
+func (h *Handlers) handleQoS(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /qos")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := qosResponse{Flows: []qos.Flow{}}
+	if h.qos != nil {
+		resp.Enabled = true
+		resp.Flows = edu.qosFlows(h.qos.Flows())
+	}
+	if edu.Spec {
+		resp.Spec = []string{qos.FiveQISpec, qos.QCISpec}
+	}
+	span.SetAttributes(attribute.Int("qos.flows", len(resp.Flows)))
+
+	writeJSON(w, r, resp)
+}
+
+// --- /cluster ------------------------------------------------------------
+
+type clusterResponse struct {
+	Enabled bool `json:"enabled"`
+	cluster.Overview
+}
+
+func (h *Handlers) handleCluster(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /cluster")
+	defer span.End()
+
+	resp := clusterResponse{Overview: cluster.Overview{Peers: []cluster.PeerStatus{}}}
+	if h.cluster != nil {
+		resp.Enabled = true
+		resp.Overview = h.cluster.Overview()
+		span.SetAttributes(
+			attribute.Int("cluster.peers", len(resp.Peers)),
+			attribute.Int("cluster.peers_up", resp.PeersUp),
+		)
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// --- /ims ----------------------------------------------------------------
+
+type imsResponse struct {
+	Enabled    bool              `json:"enabled"`
+	Components []topologyService `json:"components"`
+	Probes     []ims.ProbeResult `json:"probes"`
+	Spec       string            `json:"spec,omitempty"`
+	Course     []educontent.Item `json:"course,omitempty"` // institution content on SIP
+	ims.Summary
+}
+
+func (h *Handlers) handleIMS(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /ims")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := imsResponse{
+		Enabled:    h.ims != nil || h.imsProber != nil,
+		Spec:       edu.spec(ims.Spec),
+		Components: []topologyService{},
+		Probes:     []ims.ProbeResult{},
+		Summary: ims.Summary{
+			Registrations: []ims.Registration{},
+			Calls:         []ims.Call{},
+			Recent:        []ims.Event{},
+		},
+	}
+	for _, g := range h.snap.Services() {
+		if g.Domain != collector.DomainIMS {
+			continue
+		}
+		resp.Components = append(resp.Components, topologyService{
+			ComposeProject: g.ComposeProject, Service: g.Service,
+			Domain: g.Domain, NF: g.NF, Generation: g.Generation,
+			Owner: g.Owner, Contact: g.Contact, Description: g.Description,
+			Replicas: g.Replicas, Running: g.Running, Containers: g.Containers,
+		})
+	}
+	if h.imsProber != nil {
+		resp.Probes = h.imsProber.Results()
+	}
+	if h.ims != nil {
+		resp.Summary = edu.imsSummary(h.ims.Summary())
+	}
+	if edu.Flows {
+		resp.Course = edu.Course(h.course.Content(educontent.Topic{Kind: educontent.TopicFlow, Name: educontent.FlowSIP}))
+	}
+	span.SetAttributes(
+		attribute.Int("ims.components", len(resp.Components)),
+		attribute.Int("ims.registrations", len(resp.Registrations)),
+		attribute.Int("ims.calls", len(resp.Calls)),
+	)
+
+	writeJSON(w, r, resp)
+}
+
+// --- /internal/debug -----------------------------------------------------
+
+type debugResponse struct {
+	Enabled bool `json:"enabled"`
+	runtimestats.Sample
+}
+
+func (h *Handlers) handleDebug(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /internal/debug")
+	defer span.End()
+
+	resp := debugResponse{Sample: runtimestats.Sample{
+		BySubsystem: map[string]int{},
+		Suspects:    []runtimestats.Suspect{},
+	}}
+	if h.runtime != nil {
+		resp.Enabled = true
+		resp.Sample = h.runtime.Last()
+		span.SetAttributes(
+			attribute.Int("runtime.goroutines", resp.Goroutines),
+			attribute.Int("runtime.leak_suspects", len(resp.Suspects)),
+		)
+	}
+
+	writeJSON(w, r, resp)
 }
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /handovers -----------------------------------------------------------
+
+type handoversResponse struct {
+	Enabled bool `json:"enabled"`
+	// Matrix counts finished attempts per source and target cell since the
+	// module started; om_handover_attempts_total has the same data.
+	Matrix    []pipeline.HandoverPair `json:"matrix"`
+	Handovers []pipeline.Handover     `json:"handovers"`
+}
+
+func (h *Handlers) handleHandovers(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /handovers")
+	defer span.End()
+
+	generation := r.URL.Query().Get("generation")
+	resp := handoversResponse{Matrix: []pipeline.HandoverPair{}, Handovers: []pipeline.Handover{}}
+	if h.handovers != nil {
+		resp.Enabled = true
+		resp.Matrix = h.handovers.Matrix(generation)
+		resp.Handovers = h.handovers.Handovers(generation)
+	}
+	span.SetAttributes(
+		attribute.Int("handovers.pairs", len(resp.Matrix)),
+		attribute.Int("handovers.attempts", len(resp.Handovers)),
+	)
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/health"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/health ---------------------------------------------------------
+
+// handleHealth serves the health of every component (up, degraded with the
+// SLOs it misses, or down) and the testbed rollup.
+func (h *Handlers) handleHealth(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/health")
+	defer span.End()
+
+	if h.health == nil {
+		http.Error(w, "health rollup disabled", http.StatusServiceUnavailable)
+		return
+	}
+	resp := h.health.Status()
+	span.SetAttributes(
+		attribute.String("health.status", string(resp.Status)),
+		attribute.Int("health.degraded", resp.Degraded),
+		attribute.Int("health.down", resp.Down),
+	)
+	if resp.Status == health.StatusDown {
+		span.SetStatus(codes.Error, "a core NF is down")
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// --- /api/health/checks ---------------------------------------------------
+
+type healthChecksResponse struct {
+	Enabled bool           `json:"enabled"`
+	Failing int            `json:"failing"`
+	Error   string         `json:"error,omitempty"`
+	Checks  []health.Check `json:"checks"`
+}
+
+// handleHealthChecks lists the active checks of every running container,
+// generated or from the health checks file, with their last results.
+func (h *Handlers) handleHealthChecks(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/health/checks")
+	defer span.End()
+
+	resp := healthChecksResponse{Checks: []health.Check{}}
+	if h.healthChecks != nil {
+		resp.Enabled, resp.Error = true, h.healthChecks.Error()
+		if all := h.healthChecks.All(); all != nil {
+			resp.Checks = all
+		}
+	}
+	for _, c := range resp.Checks {
+		if c.OK != nil && !*c.OK {
+			resp.Failing++
+		}
+	}
+	span.SetAttributes(attribute.Int("health.checks", len(resp.Checks)), attribute.Int("health.checks_failing", resp.Failing))
+
+	writeJSON(w, r, resp)
+}
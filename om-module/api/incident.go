@@ -0,0 +1,332 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/incident"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Review windows: the default when none is given, the half-width around
+// ?at= and the longest window a review covers.
+const (
+	reviewDefaultWindow = 15 * time.Minute
+	reviewDefaultAround = 5 * time.Minute
+	reviewMaxWindow     = 24 * time.Hour
+	reviewTopMessages   = 5
+)
+
+//go:embed templates/incident.md
+var incidentFS embed.FS
+
+var incidentTmpl = template.Must(template.New("incident.md").Funcs(template.FuncMap{
+	"cell":  markdownCell,
+	"value": formatSignalValue,
+	"clock": func(ts string) string {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t.Local().Format("15:04:05")
+		}
+		return ts
+	},
+}).ParseFS(incidentFS, "templates/incident.md"))
+
+// --- /api/incident/review ------------------------------------------------
+
+type incidentRestart struct {
+	Container string   `json:"container"`
+	NF        string   `json:"nf,omitempty"`
+	Count     int      `json:"count"`
+	Times     []string `json:"times"`
+}
+
+type incidentLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// incidentGap is a part of the review that could not be assembled.
+type incidentGap struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+type incidentReview struct {
+	From      string                     `json:"from"`
+	To        string                     `json:"to"`
+	Duration  string                     `json:"duration"`
+	Health    []collector.HealthEvent    `json:"health_transitions"`
+	Restarts  []incidentRestart          `json:"restarts"`
+	Errors    []incident.ComponentErrors `json:"errors"`
+	ErrorsCut bool                       `json:"errors_truncated,omitempty"`
+	Anomalies []incident.Anomaly         `json:"anomalies"`
+	Links     []incidentLink             `json:"links"`
+	Gaps      []incidentGap              `json:"gaps"`
+}
+
+// ErrorLines is the number of error lines in the review.
+func (rv incidentReview) ErrorLines() int {
+	n := 0
+	for _, c := range rv.Errors {
+		n += c.Lines
+	}
+	return n
+}
+
+// handleIncidentReview assembles what happened in a time window: ?from=
+// and ?to=, or ?at= with ?around= on each side. Times are RFC 3339, Unix
+// seconds or a local clock time ("14:32", the last one that has passed).
+// ?format=md, or Accept: text/markdown, returns it as markdown.
+func (h *Handlers) handleIncidentReview(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/incident/review")
+	defer span.End()
+
+	from, to, err := reviewWindow(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = hostname
+	}
+	rv := h.incidentReview(ctx, from, to, "http://"+net.JoinHostPort(host, "3000"))
+	span.SetAttributes(
+		attribute.String("incident.from", rv.From),
+		attribute.String("incident.to", rv.To),
+		attribute.Int("incident.health_transitions", len(rv.Health)),
+		attribute.Int("incident.error_lines", rv.ErrorLines()),
+		attribute.Int("incident.anomalies", len(rv.Anomalies)),
+		attribute.Int("incident.gaps", len(rv.Gaps)),
+	)
+
+	if r.URL.Query().Get("format") == "md" || strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		var buf bytes.Buffer
+		if err := incidentTmpl.Execute(&buf, rv); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			http.Error(w, "render failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="incident-%s.md"`, from.UTC().Format("20060102T1504Z")))
+		_, _ = buf.WriteTo(w)
+		return
+	}
+	writeJSON(w, r, rv)
+}
+
+func (h *Handlers) incidentReview(ctx context.Context, from, to time.Time, grafanaURL string) incidentReview {
+	rv := incidentReview{
+		From:      from.UTC().Format(time.RFC3339),
+		To:        to.UTC().Format(time.RFC3339),
+		Duration:  to.Sub(from).String(),
+		Health:    []collector.HealthEvent{},
+		Restarts:  []incidentRestart{},
+		Errors:    []incident.ComponentErrors{},
+		Anomalies: []incident.Anomaly{},
+		Links:     []incidentLink{},
+		Gaps:      []incidentGap{},
+	}
+
+	// Health transitions and restarts come from the collector's history,
+	// which only reaches back to the module start and its last changes.
+	restarts := map[string]*incidentRestart{}
+	var order []string
+	gone := map[string]bool{}
+	history := h.snap.HealthHistory()
+	for _, ev := range history {
+		at, err := time.Parse(time.RFC3339, ev.Time)
+		if err != nil || at.Before(from) || at.After(to) {
+			continue
+		}
+		rv.Health = append(rv.Health, ev)
+		if ev.To == "" {
+			gone[ev.Container] = true
+			continue
+		}
+		// Back to running from a stopped state, or recreated after being
+		// removed (compose up --force-recreate).
+		if ev.To == "running" && (ev.From != "" || gone[ev.Container]) {
+			rs := restarts[ev.Container]
+			if rs == nil {
+				rs = &incidentRestart{Container: ev.Container, NF: ev.NF, Times: []string{}}
+				restarts[ev.Container] = rs
+				order = append(order, ev.Container)
+			}
+			rs.Count++
+			rs.Times = append(rs.Times, ev.Time)
+			delete(gone, ev.Container)
+		}
+	}
+	for _, name := range order {
+		rv.Restarts = append(rv.Restarts, *restarts[name])
+	}
+	if len(history) > 0 && history[0].Time > rv.From {
+		rv.Gaps = append(rv.Gaps, incidentGap{"health", "the health history starts at " + history[0].Time})
+	}
+
+	if h.incidents.HasLoki() {
+		errs, cut, err := h.incidents.ErrorLines(ctx, from, to, reviewTopMessages)
+		if err != nil {
+			rv.Gaps = append(rv.Gaps, incidentGap{"loki", err.Error()})
+		} else {
+			rv.Errors, rv.ErrorsCut = errs, cut
+		}
+	} else {
+		rv.Gaps = append(rv.Gaps, incidentGap{"loki", "disabled or not ready"})
+	}
+
+	if h.incidents.HasPrometheus() {
+		anomalies, err := h.incidents.Anomalies(ctx, from, to)
+		if err != nil {
+			rv.Gaps = append(rv.Gaps, incidentGap{"prometheus", err.Error()})
+		} else {
+			rv.Anomalies = anomalies
+		}
+	} else {
+		rv.Gaps = append(rv.Gaps, incidentGap{"prometheus", "disabled or not ready"})
+	}
+
+	rv.Links = h.incidentLinks(grafanaURL, from, to)
+	return rv
+}
+
+// incidentLinks opens the error lines in Grafana Explore and every dashboard
+// of the inventory on the review window.
+func (h *Handlers) incidentLinks(grafanaURL string, from, to time.Time) []incidentLink {
+	fromMS := strconv.FormatInt(from.UnixMilli(), 10)
+	toMS := strconv.FormatInt(to.UnixMilli(), 10)
+
+	explore, _ := json.Marshal(map[string]any{
+		"datasource": dashboards.LokiUID,
+		"queries":    []map[string]string{{"refId": "A", "expr": incident.ErrorSelector}},
+		"range":      map[string]string{"from": fromMS, "to": toMS},
+	})
+	links := []incidentLink{{
+		Title: "Error log lines (Explore)",
+		URL:   grafanaURL + "/explore?orgId=1&left=" + url.QueryEscape(string(explore)),
+	}}
+
+	if h.dashboards == nil {
+		return links
+	}
+	list, err := h.dashboards.List()
+	if err != nil {
+		return links
+	}
+	for _, d := range list {
+		links = append(links, incidentLink{
+			Title: d.Title,
+			URL:   grafanaURL + "/d/" + url.PathEscape(d.UID) + "?from=" + fromMS + "&to=" + toMS,
+		})
+	}
+	return links
+}
+
+// reviewWindow reads the window of a review from the query.
+func reviewWindow(q url.Values, now time.Time) (from, to time.Time, err error) {
+	parse := func(key string) (time.Time, bool, error) {
+		s := q.Get(key)
+		if s == "" {
+			return time.Time{}, false, nil
+		}
+		t, err := parseReviewTime(s, now)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%s: %w", key, err)
+		}
+		return t, true, nil
+	}
+
+	if at, ok, err := parse("at"); err != nil {
+		return from, to, err
+	} else if ok {
+		around := reviewDefaultAround
+		if s := q.Get("around"); s != "" {
+			if around, err = time.ParseDuration(s); err != nil || around <= 0 {
+				return from, to, fmt.Errorf("around: invalid duration %q", s)
+			}
+		}
+		from, to = at.Add(-around), at.Add(around)
+	} else {
+		var hasFrom, hasTo bool
+		if from, hasFrom, err = parse("from"); err != nil {
+			return from, to, err
+		}
+		if to, hasTo, err = parse("to"); err != nil {
+			return from, to, err
+		}
+		switch {
+		case !hasTo && !hasFrom:
+			to = now
+			from = to.Add(-reviewDefaultWindow)
+		case !hasTo:
+			to = now
+		case !hasFrom:
+			from = to.Add(-reviewDefaultWindow)
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("the window must end after it starts")
+	}
+	if to.Sub(from) > reviewMaxWindow {
+		return from, to, fmt.Errorf("the window is longer than %s", reviewMaxWindow)
+	}
+	return from, to, nil
+}
+
+// parseReviewTime accepts RFC 3339, Unix seconds, or a local clock time
+// ("14:32", "14:32:10") meaning its last occurrence before now.
+func parseReviewTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		c, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day(), c.Hour(), c.Minute(), c.Second(), 0, now.Location())
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (RFC 3339, Unix seconds or hh:mm)", s)
+}
+
+// markdownCell makes s safe inside a markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func formatSignalValue(unit string, v float64) string {
+	switch unit {
+	case "percent":
+		return fmt.Sprintf("%.1f %%", v)
+	case "bytes":
+		return fmt.Sprintf("%.0f MiB", v/(1<<20))
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/insights"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /educational/insights -----------------------------------------------
+
+// defaultLiveWindow is the window of the live readings without ?window=.
+const defaultLiveWindow = time.Hour
+
+type insightsResponse struct {
+	Enabled bool `json:"enabled"`
+	// Deployment is what the lab runs, which the cards are checked against.
+	Deployment collector.Deployment `json:"deployment"`
+	// Live are the KPIs of the running generation, evaluated for this
+	// request; empty without Prometheus or a single running core.
+	Live []insights.Reading `json:"live"`
+	insights.Status
+}
+
+// handleInsights returns the learning cards generated for the latest metric
+// anomalies, newest first. The "level", "notes", "hints" and "spec" query
+// parameters trim them like the other educational content. Cards follow
+// the deployment: the card of a container the lab no longer has is marked
+// not_in_deployment, and a card's dashboard is dropped when the lab runs
+// none of the NFs it shows.
+//
+// Live readings put the current KPIs of the lab in words ("Your AMF has
+// processed 42 registration requests in the last hour"), over ?window=
+// (default 1h) for ?generation= (default the generation whose core is
+// running), with what a value past its threshold usually means.
+func (h *Handlers) handleInsights(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /educational/insights")
+	defer span.End()
+
+	q := r.URL.Query()
+	window := defaultLiveWindow
+	if s := q.Get("window"); s != "" {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil || window < kpi.MinWindow || window > kpi.MaxWindow {
+			http.Error(w, "window must be a duration between "+kpi.MinWindow.String()+" and "+kpi.MaxWindow.String()+", e.g. ?window=1h", http.StatusBadRequest)
+			return
+		}
+	}
+	generation := strings.ToLower(q.Get("generation"))
+	if generation == "" {
+		generation = h.snap.ActiveGeneration()
+	}
+
+	resp := insightsResponse{Status: insights.Status{Cards: []insights.Card{}}}
+	if h.insights != nil {
+		resp = insightsResponse{Enabled: true, Status: h.insights.Status()}
+	}
+	resp.Live = []insights.Reading{}
+	if h.kpis != nil && generation != "" {
+		resp.Live = insights.Live(ctx, h.kpis, generation, window)
+	}
+	view := h.snap.View()
+	resp.Deployment = view.Deployment()
+	containers := view.All()
+	edu := h.education().withQuery(q)
+	resp.Cards = edu.insights(resp.Cards)
+	resp.Live = edu.readings(resp.Live)
+	for i := range resp.Cards {
+		c := &resp.Cards[i]
+		c.Course = edu.Course(h.course.Content(educontent.Topic{Kind: educontent.TopicSignal, Name: c.Signal}))
+		c.NotDeployed = containers[c.Container] == nil
+		if missingFrom(c.Dashboard, resp.Deployment) != "" {
+			c.Dashboard = ""
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("insights.cards", len(resp.Cards)),
+		attribute.Int("insights.active", resp.Active),
+		attribute.Int("insights.live", len(resp.Live)),
+	)
+
+	writeJSON(w, r, resp)
+}
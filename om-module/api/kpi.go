@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// defaultKPIWindow is the window of /api/kpi/{name} without ?window=.
+const defaultKPIWindow = 5 * time.Minute
+
+// --- /api/kpi ------------------------------------------------------------
+
+type kpiListEntry struct {
+	kpi.LiveDefinition
+	Generations []string `json:"generations"`
+}
+
+type kpiListResponse struct {
+	Enabled bool           `json:"enabled"`
+	KPIs    []kpiListEntry `json:"kpis"`
+}
+
+// handleKPIs lists the live KPIs and the generations each is defined for.
+func (h *Handlers) handleKPIs(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/kpi")
+	defer span.End()
+
+	resp := kpiListResponse{Enabled: h.kpis != nil, KPIs: make([]kpiListEntry, 0, len(kpi.LiveDefinitions))}
+	for _, d := range kpi.LiveDefinitions {
+		resp.KPIs = append(resp.KPIs, kpiListEntry{LiveDefinition: d, Generations: d.Generations()})
+	}
+
+	writeJSON(w, r, resp)
+}
+
+// --- /api/kpi/{name} -----------------------------------------------------
+
+// handleKPI evaluates one live KPI: ?window= (default 5m) and ?generation=
+// (default the generation whose core is running).
+func (h *Handlers) handleKPI(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/kpi/{name}")
+	defer span.End()
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/kpi/"), "/")
+	span.SetAttributes(attribute.String("kpi.name", name))
+	d, ok := kpi.LookupLive(name)
+	if !ok {
+		http.Error(w, "unknown KPI "+name+" (GET /api/kpi lists them)", http.StatusNotFound)
+		return
+	}
+	if h.kpis == nil {
+		http.Error(w, "live KPIs disabled (no Prometheus)", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	window := defaultKPIWindow
+	if s := q.Get("window"); s != "" {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil || window < kpi.MinWindow || window > kpi.MaxWindow {
+			http.Error(w, "window must be a duration between "+kpi.MinWindow.String()+" and "+kpi.MaxWindow.String()+", e.g. ?window=5m", http.StatusBadRequest)
+			return
+		}
+	}
+	generation := strings.ToLower(q.Get("generation"))
+	if generation == "" {
+		generation = h.snap.ActiveGeneration()
+	}
+	if generation == "" {
+		http.Error(w, "no single core generation is running: pass ?generation=4g or 5g", http.StatusBadRequest)
+		return
+	}
+	if _, ok := d.Query(generation, window); !ok {
+		http.Error(w, name+" is not defined for "+generation+" (generations: "+strings.Join(d.Generations(), ", ")+")", http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("kpi.generation", generation), attribute.String("kpi.window", window.String()))
+
+	v, err := h.kpis.Eval(ctx, d, generation, window)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	span.SetAttributes(attribute.Bool("kpi.has_value", v.Value != nil))
+
+	writeJSON(w, r, v)
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/labsession"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+//go:embed templates/session.md
+var sessionFS embed.FS
+
+var sessionTmpl = template.Must(template.New("session.md").Funcs(template.FuncMap{
+	"cell": markdownCell,
+	"join": strings.Join,
+	"clock": func(ts string) string {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t.Local().Format("15:04:05")
+		}
+		return ts
+	},
+}).ParseFS(sessionFS, "templates/session.md"))
+
+type sessionReport struct {
+	Session     labsession.Session
+	Duration    string
+	Milestones  []milestone.Milestone
+	Causes      []pipeline.CauseSummary
+	Flows       int
+	FlowIMSIs   int
+	Bundle      string
+	BundleError string
+	Review      string
+}
+
+// SessionReport renders the report of an ended session: the milestones,
+// causes and flows of the session, and the incident review of its window.
+// With an artifact store, the state of the module is archived as a bundle
+// too. It is the labsession.Reporter of the module.
+func (h *Handlers) SessionReport(ctx context.Context, s labsession.Session) ([]byte, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "labsession.report")
+	defer span.End()
+	span.SetAttributes(attribute.String("lab_session.id", s.ID))
+
+	from, err := time.Parse(time.RFC3339, s.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(time.RFC3339, s.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+	during := func(ts string) bool {
+		t, err := time.Parse(time.RFC3339, ts)
+		return err == nil && !t.Before(from) && !t.After(to)
+	}
+
+	rp := sessionReport{Session: s, Duration: to.Sub(from).Round(time.Second).String()}
+	if h.milestones != nil {
+		for _, m := range h.milestones.Status().Achieved {
+			if during(m.AchievedAt) {
+				rp.Milestones = append(rp.Milestones, m)
+			}
+		}
+	}
+	if h.causes != nil {
+		for _, c := range h.causes.Summary("") {
+			if during(c.LastSeen) {
+				rp.Causes = append(rp.Causes, c)
+			}
+		}
+	}
+	if h.flows != nil {
+		imsis := make(map[string]bool)
+		for _, f := range h.flows.Flows("", "") {
+			if during(f.StartedAt) {
+				rp.Flows++
+				if f.IMSI != "" {
+					imsis[f.IMSI] = true
+				}
+			}
+		}
+		rp.FlowIMSIs = len(imsis)
+	}
+	if h.artifacts != nil {
+		if m, err := h.WriteBundle(ctx, "session "+s.ID); err != nil {
+			rp.BundleError = err.Error()
+		} else {
+			rp.Bundle = m.ID
+		}
+	}
+
+	var review bytes.Buffer
+	if err := incidentTmpl.Execute(&review, h.incidentReview(ctx, from, to, bundleGrafanaURL)); err != nil {
+		return nil, err
+	}
+	rp.Review = demoteHeadings(review.String())
+
+	var buf bytes.Buffer
+	if err := sessionTmpl.Execute(&buf, rp); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// demoteHeadings moves the headings of a markdown document one level
+// down, to embed it as a section.
+func demoteHeadings(md string) string {
+	lines := strings.Split(md, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, "#") {
+			lines[i] = "#" + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// --- /api/sessions ---------------------------------------------------------
+
+type sessionsResponse struct {
+	Enabled bool `json:"enabled"`
+	labsession.Status
+}
+
+// handleSessions serves the open and past lab sessions (GET) or opens one
+// (POST ?name=&duration=2h&groups=g1,g2).
+func (h *Handlers) handleSessions(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http."+r.Method+" /api/sessions")
+	defer span.End()
+
+	switch r.Method {
+	case http.MethodGet:
+		resp := sessionsResponse{Status: labsession.Status{Sessions: []labsession.Session{}}}
+		if h.sessions != nil {
+			resp = sessionsResponse{Enabled: true, Status: h.sessions.Status()}
+		}
+		span.SetAttributes(attribute.Bool("lab_session.active", resp.Active != nil))
+		writeJSON(w, r, resp)
+
+	case http.MethodPost:
+		if h.sessions == nil {
+			http.Error(w, "lab sessions disabled", http.StatusServiceUnavailable)
+			return
+		}
+		q := r.URL.Query()
+		var d time.Duration
+		if v := q.Get("duration"); v != "" {
+			var err error
+			if d, err = time.ParseDuration(v); err != nil {
+				http.Error(w, "duration: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var groups []string
+		if v := q.Get("groups"); v != "" {
+			groups = strings.Split(v, ",")
+		}
+		s, err := h.sessions.Open(ctx, q.Get("name"), d, groups)
+		switch {
+		case errors.Is(err, labsession.ErrActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		span.SetAttributes(attribute.String("lab_session.id", s.ID))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(s)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSessionClose ends the open lab session (POST); its report follows
+// in the background.
+func (h *Handlers) handleSessionClose(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.POST /api/sessions/close")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.sessions == nil {
+		http.Error(w, "lab sessions disabled", http.StatusServiceUnavailable)
+		return
+	}
+	s, err := h.sessions.Close(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	span.SetAttributes(attribute.String("lab_session.id", s.ID))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// handleSessionReport serves the report of a past session,
+// /api/sessions/{id}/report, as markdown.
+func (h *Handlers) handleSessionReport(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/sessions/{id}/report")
+	defer span.End()
+
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/report")
+	switch {
+	case !ok || id == "" || strings.Contains(id, "/"):
+		http.NotFound(w, r)
+		return
+	case h.sessions == nil:
+		http.Error(w, "lab sessions disabled", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("lab_session.id", id))
+
+	f, ok := h.sessions.Report(id)
+	if !ok {
+		http.Error(w, "no report for session "+id, http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="session-`+id+`.md"`)
+	_, _ = io.Copy(w, f)
+}
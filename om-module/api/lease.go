@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/lease"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/lease ------------------------------------------------------------
+
+type leaseResponse struct {
+	Enabled bool `json:"enabled"`
+	lease.Status
+}
+
+// handleLease serves the instance lease: who holds it, until when, and
+// which module it was taken over from.
+func (h *Handlers) handleLease(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/lease")
+	defer span.End()
+
+	var resp leaseResponse
+	if h.lease != nil {
+		resp = leaseResponse{Enabled: true, Status: h.lease.Status()}
+	}
+	span.SetAttributes(attribute.Bool("lease.enabled", resp.Enabled), attribute.Bool("lease.taken_over", resp.TakenOver != nil))
+
+	writeJSON(w, r, resp)
+}
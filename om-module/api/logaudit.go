@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/logaudit"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/logs/audit -------------------------------------------------------
+
+type logAuditResponse struct {
+	Enabled bool `json:"enabled"`
+	logaudit.Status
+}
+
+// handleLogAudit lists the Open5GS containers with where they log and why
+// their logs do not reach Loki, those that do not first.
+func (h *Handlers) handleLogAudit(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/audit")
+	defer span.End()
+
+	resp := logAuditResponse{Status: logaudit.Status{Components: []logaudit.Component{}}}
+	if h.logAudit != nil {
+		resp = logAuditResponse{Enabled: true, Status: h.logAudit.Status()}
+	}
+	span.SetAttributes(attribute.Int("log_audit.components", len(resp.Components)),
+		attribute.Int("log_audit.collected", resp.Collected))
+
+	writeJSON(w, r, resp)
+}
+
+// handleLogAuditFix rewrites the logger section of one container
+// (?container=) to log to the shared log volume at ?level= (the
+// configured LOG_AUDIT_LEVEL when absent) and restarts it.
+func (h *Handlers) handleLogAuditFix(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.POST /api/logs/audit/fix")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.logAudit == nil {
+		http.Error(w, "log audit disabled", http.StatusServiceUnavailable)
+		return
+	}
+	container := r.URL.Query().Get("container")
+	level := strings.ToLower(r.URL.Query().Get("level"))
+	if container == "" {
+		http.Error(w, "container is required", http.StatusBadRequest)
+		return
+	}
+	if level != "" && !logaudit.ValidLevel(level) {
+		http.Error(w, "level must be one of "+strings.Join(logaudit.Levels, ", "), http.StatusBadRequest)
+		return
+	}
+	span.SetAttributes(attribute.String("log_audit.container", container), attribute.String("log_audit.level", level))
+
+	c, err := h.logAudit.Fix(ctx, container, level)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, logaudit.ErrUnknown):
+			status = http.StatusNotFound
+		case errors.Is(err, logaudit.ErrNotFixable):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, r, c)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/logexport"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/logs/files -------------------------------------------------------
+
+type logFilesResponse struct {
+	Enabled bool `json:"enabled"`
+	logexport.Status
+	Files []logexport.File `json:"files"`
+}
+
+// handleLogFiles lists the exported log files, current and rotated, with
+// how far each Open5GS log has been exported.
+func (h *Handlers) handleLogFiles(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/files")
+	defer span.End()
+
+	resp := logFilesResponse{Status: logexport.Status{Components: []logexport.Component{}}, Files: []logexport.File{}}
+	if h.logExport != nil {
+		files, err := h.logExport.Files()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp = logFilesResponse{Enabled: true, Status: h.logExport.Status(), Files: files}
+		if resp.Files == nil {
+			resp.Files = []logexport.File{}
+		}
+	}
+	span.SetAttributes(attribute.Int("log_export.files", len(resp.Files)))
+
+	writeJSON(w, r, resp)
+}
+
+// handleLogFile downloads one exported file, /api/logs/files/5g/amf.jsonl.
+func (h *Handlers) handleLogFile(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/files/{name}")
+	defer span.End()
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/logs/files/")
+	switch {
+	case name == "":
+		h.handleLogFiles(w, r)
+		return
+	case h.logExport == nil:
+		http.Error(w, "log export disabled", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("log_export.file", name))
+
+	f, err := h.logExport.Open(name)
+	switch {
+	case errors.Is(err, logexport.ErrNotFound):
+		http.Error(w, "unknown log file "+name, http.StatusNotFound)
+		return
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(name, "/", "-")+`"`)
+	_, _ = io.Copy(w, f)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/promtail"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /logging/status -----------------------------------------------------
+
+type loggingResponse struct {
+	Enabled bool `json:"enabled"`
+	promtail.Status
+}
+
+// handleLoggingStatus reports each Promtail container: its Docker state,
+// whether it answers /ready, and the restarts the module made after
+// configuration changes or on request.
+func (h *Handlers) handleLoggingStatus(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /logging/status")
+	defer span.End()
+
+	resp := h.loggingStatus()
+	ready := 0
+	for _, a := range resp.Agents {
+		if a.Ready {
+			ready++
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("logging.agents", len(resp.Agents)),
+		attribute.Int("logging.ready", ready),
+	)
+
+	writeJSON(w, r, resp)
+}
+
+// --- /logging/{start,stop,restart,reload} --------------------------------
+
+// handleLoggingAction returns the handler of one action on the Promtail
+// containers. The action runs in the background; waiting for the
+// containers to be ready takes longer than the server's write timeout, so
+// clients poll GET /logging/status until its action is empty.
+func (h *Handlers) handleLoggingAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Tracer().Start(r.Context(), "http.POST /logging/"+action)
+		defer span.End()
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if h.promtail == nil {
+			http.Error(w, "promtail management disabled", http.StatusServiceUnavailable)
+			return
+		}
+		var started bool
+		switch action {
+		case promtail.ActionStart:
+			started = h.promtail.Start()
+		case promtail.ActionStop:
+			started = h.promtail.Stop()
+		case promtail.ActionRestart:
+			started = h.promtail.Trigger()
+		case promtail.ActionReload:
+			started = h.promtail.Reload()
+		}
+		span.SetAttributes(attribute.String("logging.action", action), attribute.Bool("logging.action_started", started))
+		if !started {
+			http.Error(w, "another promtail action is already running", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(h.loggingStatus())
+	}
+}
+
+func (h *Handlers) loggingStatus() loggingResponse {
+	if h.promtail == nil {
+		return loggingResponse{Status: promtail.Status{Agents: []promtail.Agent{}}}
+	}
+	return loggingResponse{Enabled: true, Status: h.promtail.Status()}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/logimport"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxParseBody bounds the body of /api/logs/parse.
+const maxParseBody = 4 << 20
+
+// --- /api/logs/parse -------------------------------------------------------
+
+// parseRequest is a batch of lines to parse. Expect, when given, holds one
+// expectation per line and turns the request into an assertion.
+type parseRequest struct {
+	Generation string             `json:"generation"`
+	Lines      []string           `json:"lines"`
+	Expect     []logimport.Expect `json:"expect,omitempty"`
+}
+
+type parseResult struct {
+	logimport.Line
+	Mismatches []logimport.Mismatch `json:"mismatches,omitempty"`
+}
+
+type parseResponse struct {
+	Generation string `json:"generation"`
+	logimport.Coverage
+	Results []parseResult `json:"results"`
+	// Checked is 0 without expectations; Passed is whether every checked
+	// line came out as expected.
+	Checked int  `json:"checked"`
+	Failed  int  `json:"failed"`
+	Passed  bool `json:"passed"`
+}
+
+// handleLogParse runs the stages of the Promtail pipeline over a batch of
+// lines, without shipping them: a JSON parseRequest, or plain text, one
+// line per line, with ?generation=. Lines with expectations are checked
+// against them.
+func (h *Handlers) handleLogParse(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.POST /api/logs/parse")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := readParseRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Generation != "4g" && req.Generation != "5g" {
+		http.Error(w, "generation must be 4g or 5g", http.StatusBadRequest)
+		return
+	}
+	if len(req.Expect) > 0 && len(req.Expect) != len(req.Lines) {
+		http.Error(w, fmt.Sprintf("%d expectation(s) for %d line(s)", len(req.Expect), len(req.Lines)), http.StatusBadRequest)
+		return
+	}
+
+	resp := parseResponse{Generation: req.Generation, Results: make([]parseResult, 0, len(req.Lines)), Passed: true}
+	for i, line := range req.Lines {
+		res := parseResult{Line: logimport.Parse(req.Generation, line)}
+		resp.Add(res.Line)
+		if len(req.Expect) > 0 {
+			resp.Checked++
+			if res.Mismatches = res.Check(req.Expect[i]); len(res.Mismatches) > 0 {
+				resp.Failed++
+				resp.Passed = false
+			}
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	span.SetAttributes(attribute.String("log_parse.generation", req.Generation),
+		attribute.Int("log_parse.lines", resp.Lines),
+		attribute.Int("log_parse.failed", resp.Failed))
+
+	writeJSON(w, r, resp)
+}
+
+// readParseRequest decodes the body of /api/logs/parse.
+func readParseRequest(w http.ResponseWriter, r *http.Request) (parseRequest, error) {
+	body := http.MaxBytesReader(w, r.Body, maxParseBody)
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if media == "text/plain" {
+		req := parseRequest{Generation: r.URL.Query().Get("generation"), Lines: []string{}}
+		sc := bufio.NewScanner(body)
+		sc.Buffer(make([]byte, 0, 64<<10), maxParseBody)
+		for sc.Scan() {
+			if line := strings.TrimRight(sc.Text(), "\r"); strings.TrimSpace(line) != "" {
+				req.Lines = append(req.Lines, line)
+			}
+		}
+		return req, sc.Err()
+	}
+
+	var req parseRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request: %v", err)
+	}
+	if req.Generation == "" {
+		req.Generation = r.URL.Query().Get("generation")
+	}
+	if req.Lines == nil {
+		req.Lines = []string{}
+	}
+	return req, nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/logsampling"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/logs/sampling --------------------------------------------------
+
+type logSamplingResponse struct {
+	Enabled bool `json:"enabled"`
+	logsampling.Status
+}
+
+// handleLogSampling lists the rate limits of the log pipeline and the
+// latest summaries of what they dropped.
+func (h *Handlers) handleLogSampling(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/sampling")
+	defer span.End()
+
+	resp := h.logSamplingStatus()
+	span.SetAttributes(attribute.Int("log_sampling.recent", len(resp.Recent)))
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) logSamplingStatus() logSamplingResponse {
+	if h.sampling == nil {
+		return logSamplingResponse{Status: logsampling.Status{Rules: []logsampling.Rule{}, Recent: []logsampling.Suppression{}}}
+	}
+	return logSamplingResponse{Enabled: true, Status: h.sampling.Status()}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/loki/labels ----------------------------------------------------
+
+// handleLokiLabels serves the Loki label contract for the current topology.
+func (h *Handlers) handleLokiLabels(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/loki/labels")
+	defer span.End()
+
+	schema := logschema.Build(h.snap.Services())
+	span.SetAttributes(attribute.Int("loki.components", len(schema.Components)))
+
+	w.Header().Set("Content-Disposition", `inline; filename="loki-labels.json"`)
+	writeJSON(w, r, schema)
+}
+
+type lokiQueryCheck struct {
+	Dashboard string              `json:"dashboard,omitempty"`
+	Panel     string              `json:"panel,omitempty"`
+	Expr      string              `json:"expr"`
+	Problems  []logschema.Problem `json:"problems"`
+}
+
+type lokiCheckResponse struct {
+	Valid   bool             `json:"valid"`
+	Checked int              `json:"checked"`
+	Queries []lokiQueryCheck `json:"queries"` // only the queries with problems
+}
+
+// handleLokiLabelsCheck validates LogQL against the contract: the query in
+// ?expr= when given, otherwise every Loki target of the dashboard inventory.
+func (h *Handlers) handleLokiLabelsCheck(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/loki/labels/check")
+	defer span.End()
+
+	schema := logschema.Build(h.snap.Services())
+	resp := lokiCheckResponse{Queries: []lokiQueryCheck{}}
+	check := func(q lokiQueryCheck) {
+		resp.Checked++
+		if q.Problems = schema.Check(q.Expr); len(q.Problems) > 0 {
+			resp.Queries = append(resp.Queries, q)
+		}
+	}
+
+	if expr := r.URL.Query().Get("expr"); expr != "" {
+		check(lokiQueryCheck{Expr: expr})
+	} else {
+		if h.dashboards == nil {
+			http.Error(w, "dashboard inventory disabled; pass ?expr=", http.StatusServiceUnavailable)
+			return
+		}
+		list, err := h.dashboards.List()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, d := range list {
+			_, raw, err := h.dashboards.Load(d.UID)
+			if err != nil {
+				continue
+			}
+			queries, err := dashboards.Queries(raw)
+			if err != nil {
+				continue
+			}
+			for _, q := range queries {
+				if q.DatasourceType == "loki" {
+					check(lokiQueryCheck{Dashboard: d.UID, Panel: q.Panel, Expr: q.Expr})
+				}
+			}
+		}
+	}
+	resp.Valid = len(resp.Queries) == 0
+	span.SetAttributes(
+		attribute.Int("loki.queries_checked", resp.Checked),
+		attribute.Int("loki.queries_invalid", len(resp.Queries)),
+	)
+
+	writeJSON(w, r, resp)
+}
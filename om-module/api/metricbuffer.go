@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/metricbuffer"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/metrics/buffer ---------------------------------------------------
+
+type metricBufferResponse struct {
+	Enabled bool `json:"enabled"`
+	metricbuffer.Status
+}
+
+// handleMetricBuffer serves whether Prometheus is up, what the module
+// buffered while it was not and how much of it was backfilled.
+func (h *Handlers) handleMetricBuffer(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/metrics/buffer")
+	defer span.End()
+
+	resp := h.metricBufferStatus()
+	span.SetAttributes(attribute.Bool("metric_buffer.prometheus_up", resp.PrometheusUp),
+		attribute.Int("metric_buffer.pending_samples", resp.PendingSamples))
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) metricBufferStatus() metricBufferResponse {
+	if h.metricBuffer == nil {
+		return metricBufferResponse{}
+	}
+	return metricBufferResponse{Enabled: true, Status: h.metricBuffer.Status()}
+}
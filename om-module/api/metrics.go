@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/metriccatalog"
+	"github.com/Parz1val02/OM_module/internal/metricnames"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// --- /api/metrics/catalog ------------------------------------------------
+
+type metricsCatalogResponse struct {
+	Total      int                      `json:"total"`
+	Categories []metriccatalog.Category `json:"categories"`
+	Metrics    []metriccatalog.Metric   `json:"metrics"`
+}
+
+// handleMetricsCatalog lists every exported metric with its type, help,
+// category, components and labels. ?q= searches names, help, labels and
+// components; ?category= narrows to one category. Categories always count
+// the whole catalog.
+func (h *Handlers) handleMetricsCatalog(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/metrics/catalog")
+	defer span.End()
+
+	all, err := h.metricsCatalog()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, "gather metrics: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query()
+	resp := metricsCatalogResponse{
+		Total:      len(all),
+		Categories: metriccatalog.Categories(all),
+		Metrics:    metriccatalog.Filter(all, q.Get("q"), q.Get("category")),
+	}
+	span.SetAttributes(attribute.Int("metrics.total", resp.Total), attribute.Int("metrics.matched", len(resp.Metrics)))
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) metricsCatalog() ([]metriccatalog.Metric, error) {
+	mfs, err := h.reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return metriccatalog.Build(mfs), nil
+}
+
+// --- /api/metrics/names --------------------------------------------------
+
+type metricNamesResponse struct {
+	Total int                `json:"total"`
+	Names []metricnames.Name `json:"names"`
+}
+
+// handleMetricNames lists the friendly titles and descriptions of raw
+// metric names, sorted by NF. ?nf= narrows to one NF; ?metric= returns the
+// entry of one metric.
+func (h *Handlers) handleMetricNames(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/metrics/names")
+	defer span.End()
+
+	q := r.URL.Query()
+	all := h.names.All()
+	resp := metricNamesResponse{Total: len(all), Names: make([]metricnames.Name, 0, len(all))}
+	for _, n := range all {
+		if nf := strings.ToLower(q.Get("nf")); nf != "" && n.NF != nf {
+			continue
+		}
+		if metric := q.Get("metric"); metric != "" && n.Metric != metric {
+			continue
+		}
+		resp.Names = append(resp.Names, n)
+	}
+	span.SetAttributes(attribute.Int("metric_names.total", resp.Total), attribute.Int("metric_names.matched", len(resp.Names)))
+
+	writeJSON(w, r, resp)
+}
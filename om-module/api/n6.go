@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /n6 -----------------------------------------------------------------
+
+type n6Response struct {
+	Enabled bool `json:"enabled"`
+	// DNs are the data network containers of the lab (om.nf "dn").
+	DNs  []topologyService `json:"dns"`
+	UPFs []n6.Result       `json:"upfs"`
+	// Problems counts the findings over every UPF; zero means the path
+	// from the UE pools to the internet works up to the UPF.
+	Problems int    `json:"problems"`
+	Spec     string `json:"spec,omitempty"`
+}
+
+// handleN6 reports, for every UPF, whether UE traffic can leave towards the
+// data network: IP forwarding, the NAT of each UE pool, the NAT table and
+// the reachability of the internet and the DN containers, with the
+// findings that explain "attached but no internet".
+func (h *Handlers) handleN6(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /n6")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := n6Response{
+		Enabled: h.n6 != nil,
+		Spec:    edu.spec(n6.Spec),
+		DNs:     []topologyService{},
+		UPFs:    []n6.Result{},
+	}
+	for _, g := range h.snap.Services() {
+		if g.NF != n6.NFDN {
+			continue
+		}
+		resp.DNs = append(resp.DNs, topologyService{
+			ComposeProject: g.ComposeProject, Service: g.Service,
+			Domain: g.Domain, NF: g.NF, Generation: g.Generation,
+			Owner: g.Owner, Contact: g.Contact, Description: g.Description,
+			Replicas: g.Replicas, Running: g.Running, Containers: g.Containers,
+		})
+	}
+	if h.n6 != nil {
+		resp.UPFs = edu.n6(h.n6.Results())
+	}
+	for _, u := range resp.UPFs {
+		resp.Problems += len(u.Findings)
+	}
+	span.SetAttributes(
+		attribute.Int("n6.upfs", len(resp.UPFs)),
+		attribute.Int("n6.problems", resp.Problems),
+	)
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /nas/security -------------------------------------------------------
+
+// akaStep is one step of the 5G-AKA walkthrough returned with the
+// procedures. Only the NAS steps can be seen in the capture; the SBI ones
+// (AUSF ↔ UDM) are listed so the sequence is complete.
+type akaStep struct {
+	Step        int    `json:"step"`
+	Between     string `json:"between"`
+	Message     string `json:"message"`
+	Explanation string `json:"explanation,omitempty"`
+	Spec        string `json:"spec,omitempty"`
+}
+
+var akaSteps = []akaStep{
+	{1, "AMF → AUSF", "Nausf_UEAuthentication_Authenticate (SUCI)",
+		"The AMF asks the home network to authenticate the UE identified by its SUCI.",
+		"3GPP TS 33.501 §6.1.3.2"},
+	{2, "AUSF → UDM", "Nudm_UEAuthentication_Get",
+		"The UDM de-conceals the SUCI into the SUPI and computes an authentication vector (RAND, AUTN, XRES*, K_AUSF) from K and OPc.",
+		"3GPP TS 33.501 §6.1.3.2"},
+	{3, "AMF → UE", "Authentication Request (RAND, AUTN)",
+		"The AUSF keeps XRES* and K_AUSF; the AMF only forwards RAND and AUTN to the UE, together with the ngKSI of the new context.",
+		"3GPP TS 24.501 §5.4.1.3"},
+	{4, "UE → AMF", "Authentication Response (RES*)",
+		"The USIM checks the MAC in AUTN (the network knows K) and the SQN (the challenge is fresh), then computes RES*. On a mismatch it sends Authentication Failure instead.",
+		"3GPP TS 33.102 §6.3.3"},
+	{5, "AMF → AUSF", "Nausf_UEAuthentication_Authenticate (RES*)",
+		"The AMF checks HRES* against HXRES* and the AUSF compares RES* with XRES*; on success the AUSF returns the SUPI and K_SEAF.",
+		"3GPP TS 33.501 §6.1.3.2"},
+	{6, "AMF → UE", "Security Mode Command (NEA, NIA)",
+		"The AMF derives K_AMF and the NAS keys, selects a ciphering (NEA) and integrity (NIA) algorithm from the UE's security capabilities and sends them integrity-protected.",
+		"3GPP TS 33.501 §6.7.2"},
+	{7, "UE → AMF", "Security Mode Complete",
+		"The UE derives the same keys and answers protected with them. From here on NAS is integrity-protected and, unless NEA0 was chosen, ciphered — and no longer readable in the capture.",
+		"3GPP TS 24.501 §5.4.2"},
+}
+
+type nasSecurityResponse struct {
+	Enabled    bool                         `json:"enabled"`
+	AKASteps   []akaStep                    `json:"aka_steps"`
+	Procedures []pipeline.SecurityProcedure `json:"procedures"`
+	Course     []educontent.Item            `json:"course,omitempty"` // institution content on 5G-AKA
+}
+
+func (h *Handlers) handleNASSecurity(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /nas/security")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := nasSecurityResponse{AKASteps: []akaStep{}, Procedures: []pipeline.SecurityProcedure{}}
+	if h.security != nil {
+		resp.Enabled = true
+		resp.Procedures = edu.nasSecurity(h.security.Procedures(r.URL.Query().Get("generation")))
+	}
+	if edu.Notes || edu.Spec {
+		for _, s := range akaSteps {
+			if !edu.Notes {
+				s.Explanation = ""
+			}
+			s.Spec = edu.spec(s.Spec)
+			resp.AKASteps = append(resp.AKASteps, s)
+		}
+	}
+	if edu.Flows {
+		resp.Course = edu.Course(h.course.Content(educontent.Topic{Kind: educontent.TopicFlow, Name: educontent.FlowAKA}))
+	}
+	span.SetAttributes(attribute.Int("nas_security.procedures", len(resp.Procedures)))
+
+	writeJSON(w, r, resp)
+}
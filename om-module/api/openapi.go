@@ -0,0 +1,538 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Parz1val02/OM_module/internal/artifacts"
+	"github.com/Parz1val02/OM_module/internal/featureflags"
+	"github.com/Parz1val02/OM_module/internal/health"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/labevents"
+	"github.com/Parz1val02/OM_module/internal/labsession"
+	"github.com/Parz1val02/OM_module/internal/logaudit"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"github.com/Parz1val02/OM_module/internal/troubleshoot"
+)
+
+//go:generate go run gen_openapi.go
+
+// openapiJSON is the OpenAPI 3 description of the JSON API that
+// gen_openapi.go writes from OpenAPI; `go generate ./api` refreshes it
+// after a response type or a route changes.
+//
+//go:embed openapi.json
+var openapiJSON []byte
+
+//go:embed templates/swagger.html
+var swaggerPage []byte
+
+// swaggerUI holds swagger-ui-dist, vendored under static/swagger-ui by
+// `make swagger-ui` so that /api/docs needs no Internet access.
+//
+//go:embed static/swagger-ui
+var swaggerUI embed.FS
+
+//go:embed templates/apidocs.html
+var apiDocsFS embed.FS
+
+// apiDocsTmpl lists the operations when Swagger UI is not vendored.
+var apiDocsTmpl = template.Must(template.ParseFS(apiDocsFS, "templates/apidocs.html"))
+
+// --- /api/openapi.json -------------------------------------------------------
+
+// handleOpenAPI serves the OpenAPI description, from which clients in
+// other languages are generated (openapi-generator, openapi-python-client).
+func (h *Handlers) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/openapi.json")
+	defer span.End()
+
+	writeBody(w, r, openapiJSON, etagOf(openapiJSON))
+}
+
+// --- /api/docs ---------------------------------------------------------------
+
+// handleAPIDocs serves Swagger UI on /api/openapi.json, or a list of the
+// operations in a build without the vendored Swagger UI.
+func (h *Handlers) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/docs")
+	defer span.End()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := fs.Stat(swaggerUI, "static/swagger-ui/swagger-ui-bundle.js"); err == nil {
+		_, _ = w.Write(swaggerPage)
+		return
+	}
+	type row struct{ Method, Path, Tag, Summary string }
+	rows := make([]row, 0, len(operations))
+	for _, op := range operations {
+		rows = append(rows, row{op.method, op.path, op.tag, op.summary})
+	}
+	if err := apiDocsTmpl.Execute(w, rows); err != nil {
+		span.RecordError(err)
+	}
+}
+
+// swaggerAssets serves the vendored Swagger UI files on /api/docs/static/.
+func swaggerAssets() http.Handler {
+	static, _ := fs.Sub(swaggerUI, "static/swagger-ui")
+	return http.StripPrefix("/api/docs/static/", http.FileServerFS(static))
+}
+
+// operation is one documented route. response is a value of the type the
+// route writes as JSON, a oneOf of them when it writes one of several, nil
+// for a response of contentType or no body;
+// request, of the JSON body it reads, if any, and plain whether it reads a
+// text/plain body too.
+type operation struct {
+	method, path, tag, summary string
+	params                     []param
+	request                    any
+	plain                      bool
+	status                     int
+	response                   any
+	contentType                string
+	errors                     []int
+}
+
+// oneOf lists the types a route may write, e.g. a listing without a
+// query parameter and a result with it.
+type oneOf []any
+
+// param is a path or query parameter.
+type param struct {
+	name, in, description string
+	typ                   string // string | integer | boolean
+	enum                  []string
+}
+
+// Parameters several routes share.
+var (
+	generationParam = param{name: "generation", in: "query", typ: "string", enum: []string{"4g", "5g"},
+		description: "Only this generation (default: all)."}
+	flagParam       = param{name: "name", in: "path", typ: "string", description: "Flag name."}
+	artifactParam   = param{name: "id", in: "path", typ: "string", description: "Bundle id."}
+	educationParams = []param{
+		{name: "level", in: "query", typ: "string", enum: []string{"intro", "advanced", "all", "none"},
+			description: "Teaching aids preset for this request (default: EDUCATIONAL_FEATURES)."},
+		{name: "notes", in: "query", typ: "boolean", description: "Meanings and descriptions."},
+		{name: "hints", in: "query", typ: "boolean", description: "The misconfiguration that usually causes a finding."},
+		{name: "spec", in: "query", typ: "boolean", description: "3GPP / IETF specification references."},
+		{name: "flows", in: "query", typ: "boolean", description: "Message-by-message walkthroughs."},
+	}
+)
+
+// errorDescriptions describe the plain-text error responses.
+var errorDescriptions = map[int]string{
+	http.StatusBadRequest:          "Invalid parameter",
+	http.StatusNotFound:            "Unknown name",
+	http.StatusConflict:            "Another action is running",
+	http.StatusTooManyRequests:     "Rate limited",
+	http.StatusInternalServerError: "Module failed",
+	http.StatusBadGateway:          "Upstream (Prometheus, Grafana, artifact store) failed",
+	http.StatusServiceUnavailable:  "Subsystem disabled",
+}
+
+// withEducation appends the teaching aid parameters to params.
+func withEducation(params ...param) []param {
+	return append(params, educationParams...)
+}
+
+// operations are the routes the OpenAPI description covers, every route
+// Register wires; TestOpenAPICoversRoutes fails on one missing.
+var operations = []operation{
+	// Topology and status
+	{method: "GET", path: "/ping", tag: "topology", summary: "Liveness check; answers pong.", contentType: "text/plain"},
+	{method: "GET", path: "/topology", tag: "topology", summary: "Every testbed container and Compose service with its state, health and owner.", response: topologyResponse{}},
+	{method: "GET", path: "/status", tag: "topology", summary: "Whether the module started with all its dependencies, its feature flags and subsystems.", response: statusResponse{}},
+	{method: "GET", path: "/capture/status", tag: "topology", summary: "State of the packet capture.", response: captureStatusResponse{}},
+	{method: "GET", path: "/capture/sbi", tag: "topology", summary: "SBI requests and responses paired per NF pair.", response: captureSBIResponse{}},
+	{method: "GET", path: "/cluster", tag: "topology", summary: "The other O&M modules of a multi-host lab.", response: clusterResponse{}},
+	{method: "GET", path: "/api/exporters", tag: "topology", summary: "The cAdvisor and node_exporter containers the collector detected.", response: exportersResponse{}},
+	{method: "GET", path: "/api/version", tag: "topology", summary: "Module build, container images and feature flags.", response: versionResponse{}},
+	{method: "GET", path: "/api/subscribers/drift", tag: "topology", summary: "Duplicate and malformed subscribers of the last check and the latest drift events.", response: subscriberDriftResponse{}},
+
+	// Health
+	{method: "GET", path: "/api/health", tag: "health", summary: "Health of every component and the testbed rollup.", response: health.Rollup{}, errors: []int{503}},
+	{method: "GET", path: "/api/health/checks", tag: "health", summary: "Results of the active health checks.", response: healthChecksResponse{}},
+	{method: "GET", path: "/api/slo", tag: "health", summary: "Service level objectives and their error budgets.", response: sloResponse{}},
+	{method: "GET", path: "/api/config/consistency", tag: "health", summary: "PLMN, TAC, S-NSSAI and core address of every gNB and eNB checked against the AMF or MME, mismatches first.", response: ranConfigResponse{}},
+	{method: "GET", path: "/api/targets", tag: "health", summary: "Intended scrape targets merged with the scrape state Prometheus reports, mismatches first.", params: []param{
+		{name: "job", in: "query", typ: "string", description: "Only targets of this job."},
+		{name: "state", in: "query", typ: "string", enum: []string{"ok", "down", "stale", "missing", "unexpected"}, description: "Only targets in this state."},
+		{name: "q", in: "query", typ: "string", description: "Part of the job, address or container."},
+		{name: "limit", in: "query", typ: "integer", description: "Page size (default 50, at most 500)."},
+		{name: "offset", in: "query", typ: "integer", description: "Targets skipped."},
+	}, response: targetsResponse{}, errors: []int{400, 502, 503}},
+	{method: "GET", path: "/api/kpi", tag: "health", summary: "The live KPIs and the generations each is defined for.", response: kpiListResponse{}},
+	{method: "GET", path: "/api/kpi/{name}", tag: "health", summary: "One live KPI evaluated by Prometheus; value is null without data.", params: []param{
+		{name: "name", in: "path", typ: "string", description: "KPI name, e.g. attach_success_rate."},
+		{name: "window", in: "query", typ: "string", description: "Duration the KPI is evaluated over (default 5m)."},
+		{name: "generation", in: "query", typ: "string", enum: []string{"4g", "5g"}, description: "Generation (default: the one running)."},
+	}, response: kpi.LiveValue{}, errors: []int{400, 404, 502, 503}},
+	{method: "GET", path: "/api/incident/review", tag: "health", summary: "What happened in a time window: health transitions, restarts, errors, anomalies and dashboard links.", params: []param{
+		{name: "from", in: "query", typ: "string", description: "Start of the window: RFC 3339, Unix seconds or a clock time such as 14:32."},
+		{name: "to", in: "query", typ: "string", description: "End of the window (default: now)."},
+		{name: "at", in: "query", typ: "string", description: "Centre of the window, instead of from and to."},
+		{name: "around", in: "query", typ: "string", description: "Duration on each side of at."},
+		{name: "format", in: "query", typ: "string", enum: []string{"json", "md"}, description: "Response format (default json); md, or Accept: text/markdown, returns markdown."},
+	}, response: incidentReview{}, errors: []int{400}},
+	{method: "GET", path: "/api/troubleshoot", tag: "health", summary: "The troubleshooting symptoms without symptom, and with it the diagnosis of the live lab.", params: []param{
+		{name: "symptom", in: "query", typ: "string", description: "Symptom to diagnose, e.g. ue_cannot_attach."},
+		{name: "generation", in: "query", typ: "string", enum: []string{"4g", "5g"}, description: "Generation (default: the one running)."},
+		{name: "imsi", in: "query", typ: "string", description: "Subscriber to diagnose."},
+	}, response: oneOf{troubleshootResponse{}, troubleshoot.Diagnosis{}}, errors: []int{400, 503}},
+	{method: "GET", path: "/synthetic", tag: "health", summary: "The synthetic end-to-end test and its last result.", response: syntheticResponse{}},
+	{method: "POST", path: "/synthetic/run", tag: "health", summary: "Run the synthetic test now; poll /synthetic until running is false.", status: 202, response: syntheticResponse{}, errors: []int{409, 503}},
+	{method: "GET", path: "/api/soak", tag: "health", summary: "The soak test in progress or the last one.", response: soakResponse{}},
+	{method: "POST", path: "/api/soak/start", tag: "health", summary: "Start a soak test.", params: []param{
+		{name: "duration", in: "query", typ: "string", description: "How long it runs, e.g. 8h."},
+	}, status: 202, response: soakResponse{}, errors: []int{400, 409, 503}},
+
+	// Log pipeline
+	{method: "GET", path: "/logging/status", tag: "logging", summary: "Promtail agents and the action in progress.", response: loggingResponse{}},
+	{method: "POST", path: "/logging/start", tag: "logging", summary: "Start Promtail; poll /logging/status until action is empty.", status: 202, response: loggingResponse{}, errors: []int{409, 503}},
+	{method: "POST", path: "/logging/stop", tag: "logging", summary: "Stop Promtail.", status: 202, response: loggingResponse{}, errors: []int{409, 503}},
+	{method: "POST", path: "/logging/restart", tag: "logging", summary: "Restart Promtail with a regenerated configuration.", status: 202, response: loggingResponse{}, errors: []int{409, 503}},
+	{method: "POST", path: "/logging/reload", tag: "logging", summary: "Reload the Promtail configuration.", status: 202, response: loggingResponse{}, errors: []int{409, 503}},
+	{method: "GET", path: "/api/logs/error-budget", tag: "logging", summary: "Log error budgets per NF.", response: errorBudgetResponse{}},
+	{method: "GET", path: "/api/logs/sampling", tag: "logging", summary: "Log rate limits and the latest summaries of the lines they dropped.", response: logSamplingResponse{}},
+	{method: "GET", path: "/api/logs/redaction", tag: "logging", summary: "Redaction rules and how often each matched.", response: redactionResponse{}},
+	{method: "GET", path: "/api/logs/audit", tag: "logging", summary: "Log configuration audit of every component.", response: logAuditResponse{}},
+	{method: "GET", path: "/api/logs/files", tag: "logging", summary: "Local log export and its files.", response: logFilesResponse{}},
+	{method: "POST", path: "/api/logs/parse", tag: "logging", summary: "Run the Promtail pipeline stages over a batch of lines, optionally checking them against expectations.", params: []param{
+		{name: "generation", in: "query", typ: "string", enum: []string{"4g", "5g"}, description: "Generation of the lines, for plain-text bodies."},
+	}, request: parseRequest{}, plain: true, response: parseResponse{}, errors: []int{400}},
+	{method: "POST", path: "/api/logs/audit/fix", tag: "logging", summary: "Set the log level of a component to fix its audit finding.", params: []param{
+		{name: "container", in: "query", typ: "string", description: "Container to fix (required)."},
+		{name: "level", in: "query", typ: "string", enum: logaudit.Levels, description: "Log level (default: the recommended one)."},
+	}, response: logaudit.Component{}, errors: []int{400, 404, 409, 502, 503}},
+	{method: "GET", path: "/api/logs/files/{name}", tag: "logging", summary: "Download one exported log file.", params: []param{
+		{name: "name", in: "path", typ: "string", description: "File name as listed by /api/logs/files, e.g. 5g/amf.jsonl."},
+	}, contentType: "application/x-ndjson", errors: []int{404, 500, 503}},
+	{method: "GET", path: "/api/loki/labels", tag: "logging", summary: "The Loki label contract of the current topology.", response: logschema.Schema{}},
+	{method: "GET", path: "/api/loki/labels/check", tag: "logging", summary: "LogQL checked against the label contract, only the queries with problems listed.", params: []param{
+		{name: "expr", in: "query", typ: "string", description: "Query to check (default: every Loki query of the dashboards)."},
+	}, response: lokiCheckResponse{}, errors: []int{500, 503}},
+
+	// Metrics
+	{method: "GET", path: "/metrics", tag: "metrics", summary: "The module's own metrics for Prometheus.", contentType: "text/plain"},
+	{method: "GET", path: "/metrics/simulated", tag: "metrics", summary: "Simulated NF series for the om-simulated scrape job; empty without the fallback.", contentType: "text/plain"},
+	{method: "GET", path: "/metrics/cadvisor", tag: "metrics", summary: "Container stats under cAdvisor's names for the om-cadvisor scrape job; empty without the exporter.", contentType: "text/plain"},
+	{method: "GET", path: "/api/metrics/catalog", tag: "metrics", summary: "Every metric the module exports, by category.", params: []param{
+		{name: "q", in: "query", typ: "string", description: "Part of the name or help text."},
+		{name: "category", in: "query", typ: "string", description: "Only metrics of this category."},
+	}, response: metricsCatalogResponse{}, errors: []int{500}},
+	{method: "GET", path: "/api/metrics/names", tag: "metrics", summary: "Friendly titles and descriptions of raw NF metric names.", params: []param{
+		{name: "nf", in: "query", typ: "string", description: "Only metrics of this NF."},
+		{name: "metric", in: "query", typ: "string", description: "Only this metric."},
+	}, response: metricNamesResponse{}},
+	{method: "GET", path: "/api/metrics/buffer", tag: "metrics", summary: "Whether Prometheus is up and what the module buffered and backfilled while it was not.", response: metricBufferResponse{}},
+	{method: "GET", path: "/api/metrics/cardinality", tag: "metrics", summary: "Head series of the largest metric families against their budget, and the labels limited.", response: cardinalityResponse{}},
+	{method: "GET", path: "/api/metrics/simulated", tag: "metrics", summary: "NFs with a sample, whether their endpoint answers and whether they are simulated.", response: simulatedMetricsResponse{}},
+
+	// Dashboards
+	{method: "GET", path: "/api/dashboards", tag: "dashboards", summary: "The provisioned Grafana dashboards.", response: dashboardsResponse{}, errors: []int{500}},
+	{method: "GET", path: "/api/dashboards/lint", tag: "dashboards", summary: "Report of the last dashboard query lint.", response: dashboardLintResponse{}},
+	{method: "GET", path: "/api/dashboards/{uid}", tag: "dashboards", summary: "One dashboard file and the copy Grafana runs.", params: []param{
+		{name: "uid", in: "path", typ: "string", description: "Dashboard UID."},
+	}, response: dashboardResponse{}, errors: []int{404, 503}},
+	{method: "POST", path: "/api/dashboards/{uid}/reload", tag: "dashboards", summary: "Push the dashboard file to Grafana.", params: []param{
+		{name: "uid", in: "path", typ: "string", description: "Dashboard UID."},
+	}, response: dashboardResponse{}, errors: []int{404, 502, 503}},
+
+	// Educational
+	{method: "GET", path: "/educational/", tag: "educational", summary: "Educational page of the running lab.", params: educationParams, contentType: "text/html"},
+	{method: "GET", path: "/educational/mode", tag: "educational", summary: "Default teaching aids.", response: educationalModeResponse{}},
+	{method: "POST", path: "/educational/mode", tag: "educational", summary: "Switch the default teaching aids until the module restarts.", params: educationParams, response: educationalModeResponse{}, errors: []int{400}},
+	{method: "GET", path: "/educational/insights", tag: "educational", summary: "Insight cards explaining what the lab is doing, and its live KPIs in words.", params: withEducation(
+		param{name: "window", in: "query", typ: "string", description: "Window of the live readings (default: 1h)."},
+		param{name: "generation", in: "query", typ: "string", enum: []string{"4g", "5g"}, description: "Generation of the live readings (default: the running core)."},
+	), response: insightsResponse{}, errors: []int{400}},
+	{method: "GET", path: "/causes", tag: "educational", summary: "NAS and NGAP/S1AP causes seen in the capture.", params: withEducation(generationParam), response: causesResponse{}},
+	{method: "GET", path: "/milestones", tag: "educational", summary: "Lab milestones of the current session.", params: educationParams, response: milestonesResponse{}},
+	{method: "POST", path: "/milestones/reset", tag: "educational", summary: "Start a new milestone session.", status: 204, errors: []int{503}},
+	{method: "GET", path: "/qos", tag: "educational", summary: "Per-UE QoS flows (5G) and EPS bearers (4G).", params: educationParams, response: qosResponse{}},
+	{method: "GET", path: "/nas/security", tag: "educational", summary: "NAS authentication and security mode procedures.", params: withEducation(generationParam), response: nasSecurityResponse{}},
+	{method: "GET", path: "/handovers", tag: "educational", summary: "Handovers and the cell-to-cell matrix.", params: []param{generationParam}, response: handoversResponse{}},
+	{method: "GET", path: "/flows", tag: "educational", summary: "Recorded session flows.", params: []param{
+		generationParam,
+		{name: "imsi", in: "query", typ: "string", description: "Only flows of this subscriber."},
+	}, response: flowsResponse{}},
+	{method: "GET", path: "/flows/{id}", tag: "educational", summary: "One session flow, as JSON or as a sequence diagram.", params: []param{
+		{name: "id", in: "path", typ: "string", description: "Flow id."},
+		{name: "format", in: "query", typ: "string", enum: []string{"json", "mermaid", "plantuml", "svg"}, description: "Response format (default json)."},
+	}, response: pipeline.Flow{}, errors: []int{400, 404, 503}},
+	{method: "GET", path: "/ims", tag: "educational", summary: "IMS components, registrations and calls.", params: educationParams, response: imsResponse{}},
+	{method: "GET", path: "/roaming", tag: "educational", summary: "SEPP checks of a roaming lab.", params: educationParams, response: roamingResponse{}},
+	{method: "GET", path: "/exposure", tag: "educational", summary: "NEF exposure APIs and their subscriptions.", params: educationParams, response: exposureResponse{}},
+	{method: "GET", path: "/n6", tag: "educational", summary: "Path of each UPF to the data network.", params: educationParams, response: n6Response{}},
+	{method: "GET", path: "/api/events", tag: "educational", summary: "Events reported by the lab scripts, newest first.", params: []param{
+		{name: "type", in: "query", typ: "string", description: "Only events of this type, e.g. ue_started."},
+		{name: "source", in: "query", typ: "string", description: "Only events of this source."},
+		{name: "limit", in: "query", typ: "integer", description: "At most this many events (default: all kept)."},
+	}, response: eventsResponse{}, errors: []int{400}},
+	{method: "POST", path: "/api/events", tag: "educational", summary: "Report an event of a lab script (UE started, SDR attached, scenario step), or an array of them; each is counted, sent to Loki and annotated on the dashboards.",
+		request: labevents.Event{}, status: 201, response: []labevents.Event{}, errors: []int{400, 429, 503}},
+	{method: "GET", path: "/api/sessions", tag: "educational", summary: "The open and past lab sessions.", response: sessionsResponse{}},
+	{method: "POST", path: "/api/sessions", tag: "educational", summary: "Open a lab session.", params: []param{
+		{name: "name", in: "query", typ: "string", description: "Session name."},
+		{name: "duration", in: "query", typ: "string", description: "Planned length, e.g. 2h."},
+		{name: "groups", in: "query", typ: "string", description: "Comma-separated student groups."},
+	}, status: 201, response: labsession.Session{}, errors: []int{400, 409, 503}},
+	{method: "POST", path: "/api/sessions/close", tag: "educational", summary: "End the open lab session; its report follows in the background.", response: labsession.Session{}, errors: []int{409, 503}},
+	{method: "GET", path: "/api/sessions/{id}/report", tag: "educational", summary: "Report of a past lab session.", params: []param{
+		{name: "id", in: "path", typ: "string", description: "Session id."},
+	}, contentType: "text/markdown", errors: []int{404, 503}},
+
+	// Administration
+	{method: "GET", path: "/api/flags", tag: "admin", summary: "Feature flags and where each value comes from.", response: flagsResponse{}},
+	{method: "GET", path: "/api/flags/{name}", tag: "admin", summary: "One feature flag.", params: []param{flagParam}, response: featureflags.Flag{}, errors: []int{404, 503}},
+	{method: "POST", path: "/api/flags/{name}", tag: "admin", summary: "Override a feature flag until the module restarts.", params: []param{
+		flagParam,
+		{name: "enabled", in: "query", typ: "boolean", description: "New value (required)."},
+	}, response: featureflags.Flag{}, errors: []int{400, 404, 503}},
+	{method: "DELETE", path: "/api/flags/{name}", tag: "admin", summary: "Drop the override of a feature flag.", params: []param{flagParam}, response: featureflags.Flag{}, errors: []int{404, 503}},
+	{method: "GET", path: "/api/lease", tag: "admin", summary: "Who holds the instance lease, until when, and whom it was taken over from.", response: leaseResponse{}},
+	{method: "GET", path: "/api/regen", tag: "admin", summary: "Configuration regeneration jobs.", response: regenResponse{}},
+	{method: "GET", path: "/api/artifacts", tag: "admin", summary: "Archived bundles of the artifact store.", response: artifactsResponse{}, errors: []int{502}},
+	{method: "POST", path: "/api/artifacts", tag: "admin", summary: "Archive a new bundle.", status: 201, response: artifacts.Manifest{}, errors: []int{502, 503}},
+	{method: "GET", path: "/api/artifacts/{id}", tag: "admin", summary: "Manifest of one bundle.", params: []param{artifactParam}, response: artifacts.Manifest{}, errors: []int{404, 502, 503}},
+	{method: "GET", path: "/api/artifacts/{id}/bundle.tar.gz", tag: "admin", summary: "Archive of one bundle.", params: []param{artifactParam}, contentType: "application/gzip", errors: []int{404, 502, 503}},
+	{method: "GET", path: "/api/debug/bundle", tag: "admin", summary: "Support bundle of module logs, configuration, status, health history, topology and versions.", contentType: "application/gzip", errors: []int{500}},
+	{method: "GET", path: "/api/debug/state", tag: "admin", summary: "The state dump SIGUSR1 writes to a file.", response: stateDump{}},
+	{method: "GET", path: "/internal/debug", tag: "admin", summary: "Goroutines, memory and leak suspects of the module.", response: debugResponse{}},
+	{method: "GET", path: "/api/openapi.json", tag: "admin", summary: "This OpenAPI description.", response: map[string]any{}},
+	{method: "GET", path: "/api/docs", tag: "admin", summary: "Swagger UI on this description.", contentType: "text/html"},
+	{method: "GET", path: "/api/docs/static/{file}", tag: "admin", summary: "The Swagger UI files embedded in the module.", params: []param{
+		{name: "file", in: "path", typ: "string", description: "File name, e.g. swagger-ui-bundle.js."},
+	}, contentType: "application/octet-stream", errors: []int{404}},
+}
+
+// OpenAPI returns the OpenAPI 3 description of operations, the schemas
+// derived from the Go types of their responses. gen_openapi.go writes it
+// to openapi.json.
+func OpenAPI() ([]byte, error) {
+	g := schemaGen{schemas: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+	for _, op := range operations {
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case op.response != nil:
+			resp["content"] = map[string]any{"application/json": map[string]any{"schema": g.responseSchema(op.response)}}
+		case op.contentType != "":
+			resp["content"] = map[string]any{op.contentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		}
+		responses := map[string]any{strconv.Itoa(status): resp}
+		for _, code := range op.errors {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": errorDescriptions[code],
+				"content":     map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}},
+			}
+		}
+
+		o := map[string]any{
+			"tags":        []string{op.tag},
+			"summary":     op.summary,
+			"operationId": operationID(op),
+			"responses":   responses,
+		}
+		if len(op.params) > 0 {
+			var params []map[string]any
+			for _, p := range op.params {
+				s := map[string]any{"type": p.typ}
+				if len(p.enum) > 0 {
+					s["enum"] = p.enum
+				}
+				params = append(params, map[string]any{
+					"name": p.name, "in": p.in, "description": p.description,
+					"required": p.in == "path", "schema": s,
+				})
+			}
+			o["parameters"] = params
+		}
+		if op.request != nil {
+			content := map[string]any{
+				"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.request))},
+			}
+			if op.plain {
+				content["text/plain"] = map[string]any{"schema": map[string]any{"type": "string"}}
+			}
+			o["requestBody"] = map[string]any{"required": true, "content": content}
+		}
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]any)
+		}
+		paths[op.path][strings.ToLower(op.method)] = o
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "O&M module API",
+			"version":     "1",
+			"description": "Topology, health, log pipeline, metrics, dashboards, educational and administration endpoints of the O&M module. Errors are plain text.",
+		},
+		"servers": []map[string]any{{"url": "/"}},
+		"tags": []map[string]any{
+			{"name": "topology", "description": "Testbed containers and module status"},
+			{"name": "health", "description": "Component health, SLOs, scrape targets and live KPIs"},
+			{"name": "logging", "description": "Promtail and the log pipeline"},
+			{"name": "metrics", "description": "Scrape endpoints and what the module knows of the metrics"},
+			{"name": "dashboards", "description": "Grafana dashboards"},
+			{"name": "educational", "description": "What the capture saw, with optional teaching aids, and lab sessions"},
+			{"name": "admin", "description": "Feature flags, lease, artifacts and debugging"},
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// operationID names op after its method and path, e.g. getApiKpiName.
+func operationID(op operation) string {
+	id := strings.ToLower(op.method)
+	for _, part := range strings.FieldsFunc(op.path, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		id += exportedName(part)
+	}
+	return id
+}
+
+// schemaGen derives JSON schemas from Go types as encoding/json encodes
+// them; named structs become components referenced by $ref.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// responseSchema is the schema of v, or of each of its types for a oneOf.
+func (g *schemaGen) responseSchema(v any) map[string]any {
+	alts, ok := v.(oneOf)
+	if !ok {
+		return g.schema(reflect.TypeOf(v))
+	}
+	var schemas []any
+	for _, alt := range alts {
+		schemas = append(schemas, g.schema(reflect.TypeOf(alt)))
+	}
+	return map[string]any{"oneOf": schemas}
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+	var s map[string]any
+	switch {
+	case t == timeType:
+		s = map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // placeholder against recursion
+			g.schemas[name] = g.object(t)
+		}
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if nullable {
+			return map[string]any{"allOf": []any{ref}, "nullable": true}
+		}
+		return ref
+	case t.Kind() == reflect.Struct:
+		s = g.object(t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s = map[string]any{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		s = map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case t.Kind() == reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.String:
+		s = map[string]any{"type": "string"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+		if t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64 {
+			s["format"] = "int64"
+		}
+	default: // interfaces: any JSON value
+		s = map[string]any{}
+	}
+	if nullable {
+		s["nullable"] = true
+	}
+	return s
+}
+
+// object is the schema of a struct, the fields of embedded structs
+// promoted as encoding/json does.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	g.fields(t, props)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+}
+
+// schemaName names a component after its Go type: the api package's own
+// response types by their name, others prefixed with their package
+// (health.Rollup → HealthRollup), so generated classes do not collide.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "api" {
+		return exportedName(t.Name())
+	}
+	return exportedName(pkg) + exportedName(t.Name())
+}
+
+func exportedName(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// routes records the patterns Register wires while serving them.
+type routes struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (r *routes) Handle(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.Handle(pattern, handler)
+}
+
+func (r *routes) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.HandleFunc(pattern, handler)
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+// TestOpenAPICoversRoutes fails on a route Register wires that no operation
+// documents, and on an operation no route serves.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	mux := &routes{ServeMux: http.NewServeMux()}
+	New(Options{Snapshot: collector.NewSnapshot(), Project: "open5gs", Registry: prometheus.NewRegistry()}).Register(mux)
+
+	for _, pattern := range mux.patterns {
+		documented := false
+		for _, op := range operations {
+			if op.path == pattern || strings.HasSuffix(pattern, "/") && strings.HasPrefix(op.path, pattern) {
+				documented = true
+				break
+			}
+		}
+		if !documented {
+			t.Errorf("route %s is not in the OpenAPI description; add it to operations", pattern)
+		}
+	}
+
+	for _, op := range operations {
+		req := httptest.NewRequest(op.method, pathParam.ReplaceAllString(op.path, "x"), nil)
+		if _, pattern := mux.Handler(req); pattern == "" || pattern == "/" {
+			t.Errorf("%s %s is documented but no route serves it", op.method, op.path)
+		}
+	}
+}
+
+// TestOpenAPIGenerated fails when openapi.json is older than operations.
+func TestOpenAPIGenerated(t *testing.T) {
+	spec, err := OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(spec, '\n'), openapiJSON) {
+		t.Error("openapi.json is stale; run go generate ./api")
+	}
+}
+
+// TestAPIDocsOffline fails when /api/docs loads anything from outside the
+// module.
+func TestAPIDocsOffline(t *testing.T) {
+	mux := http.NewServeMux()
+	New(Options{Snapshot: collector.NewSnapshot(), Project: "open5gs", Registry: prometheus.NewRegistry()}).Register(mux)
+
+	get := func(path string) string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d", path, rec.Code)
+		}
+		return rec.Body.String()
+	}
+	if page := get("/api/docs"); strings.Contains(page, "https://") {
+		t.Errorf("/api/docs loads a remote resource:\n%s", page)
+	}
+	get("/api/docs/static/README.md")
+}
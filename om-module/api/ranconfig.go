@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/ranconfig"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/config/consistency -----------------------------------------------
+
+type ranConfigResponse struct {
+	Enabled bool `json:"enabled"`
+	ranconfig.Status
+}
+
+// handleRANConfig lists the gNBs and eNBs with the values of their
+// configuration the AMF (MME) they connect to does not serve, the
+// inconsistent ones first.
+func (h *Handlers) handleRANConfig(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/config/consistency")
+	defer span.End()
+
+	resp := ranConfigResponse{Status: ranconfig.Status{RAN: []ranconfig.RAN{}, Core: []ranconfig.Node{}}}
+	if h.ranConfig != nil {
+		resp = ranConfigResponse{Enabled: true, Status: h.ranConfig.Status()}
+	}
+	span.SetAttributes(attribute.Int("ran_config.nodes", len(resp.RAN)),
+		attribute.Int("ran_config.inconsistent", resp.Inconsistent))
+
+	writeJSON(w, r, resp)
+}
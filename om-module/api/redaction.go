@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/logs/redaction ---------------------------------------------------
+
+type redactionResponse struct {
+	Enabled bool `json:"enabled"`
+	redact.Status
+}
+
+// handleRedaction serves the redaction rules with their hits; in dry-run
+// mode, also the last values each rule would have replaced.
+func (h *Handlers) handleRedaction(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/logs/redaction")
+	defer span.End()
+
+	resp := redactionResponse{Status: redact.Status{Rules: []redact.RuleStatus{}}}
+	if h.redactor != nil {
+		resp = redactionResponse{Enabled: true, Status: h.redactor.Status()}
+	}
+	span.SetAttributes(attribute.Int("redaction.rules", len(resp.Rules)), attribute.Bool("redaction.dry_run", resp.DryRun))
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/regen"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/regen ----------------------------------------------------------
+
+type regenResponse struct {
+	Jobs []regen.JobStatus `json:"jobs"`
+}
+
+func (h *Handlers) handleRegen(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/regen")
+	defer span.End()
+
+	resp := regenResponse{Jobs: []regen.JobStatus{}}
+	if h.regen != nil {
+		resp.Jobs = h.regen.Status()
+	}
+	span.SetAttributes(attribute.Int("regen.jobs", len(resp.Jobs)))
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/roaming"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /roaming ------------------------------------------------------------
+
+type roamingResponse struct {
+	Enabled    bool              `json:"enabled"`
+	Components []topologyService `json:"components"`
+	SEPPs      []roaming.Result  `json:"sepps"`
+	Spec       string            `json:"spec,omitempty"`
+}
+
+// handleRoaming lists the SEPP services of a roaming lab with the last
+// check of their SBI and N32 ports.
+func (h *Handlers) handleRoaming(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /roaming")
+	defer span.End()
+
+	edu := h.education().withQuery(r.URL.Query())
+	resp := roamingResponse{
+		Enabled:    h.roaming != nil,
+		Spec:       edu.spec(roaming.Spec),
+		Components: []topologyService{},
+		SEPPs:      []roaming.Result{},
+	}
+	for _, g := range h.snap.Services() {
+		if g.NF != roaming.NFSEPP {
+			continue
+		}
+		resp.Components = append(resp.Components, topologyService{
+			ComposeProject: g.ComposeProject, Service: g.Service,
+			Domain: g.Domain, NF: g.NF, Generation: g.Generation,
+			Owner: g.Owner, Contact: g.Contact, Description: g.Description,
+			Replicas: g.Replicas, Running: g.Running, Containers: g.Containers,
+		})
+	}
+	if h.roaming != nil {
+		resp.SEPPs = edu.roaming(h.roaming.Results())
+	}
+	span.SetAttributes(
+		attribute.Int("roaming.components", len(resp.Components)),
+		attribute.Int("roaming.sepps", len(resp.SEPPs)),
+	)
+
+	writeJSON(w, r, resp)
+}
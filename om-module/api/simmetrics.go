@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/simmetrics"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /metrics/simulated ----------------------------------------------------
+
+// handleSimulatedMetrics serves the simulated series for the om-simulated
+// scrape job. Without the fallback it serves nothing, so the job stays up.
+func (h *Handlers) handleSimulatedMetrics(w http.ResponseWriter, r *http.Request) {
+	if h.simulated == nil {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		return
+	}
+	h.simulated.Handler().ServeHTTP(w, r)
+}
+
+// --- /api/metrics/simulated ------------------------------------------------
+
+type simulatedMetricsResponse struct {
+	Enabled bool `json:"enabled"`
+	simmetrics.Status
+}
+
+// handleSimulatedMetricsStatus lists the NFs with a sample, whether their
+// endpoint answers and whether they are simulated, those simulated first.
+func (h *Handlers) handleSimulatedMetricsStatus(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/metrics/simulated")
+	defer span.End()
+
+	resp := simulatedMetricsResponse{Status: simmetrics.Status{Templates: []string{}, NFs: []simmetrics.NF{}}}
+	if h.simulated != nil {
+		resp = simulatedMetricsResponse{Enabled: true, Status: h.simulated.Status()}
+	}
+	span.SetAttributes(attribute.Bool("simulated.active", resp.Active),
+		attribute.Int("simulated.nfs", resp.Simulated))
+
+	writeJSON(w, r, resp)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/slo"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/slo --------------------------------------------------------------
+
+type sloResponse struct {
+	Enabled bool `json:"enabled"`
+	slo.Status
+}
+
+// handleSLO serves the lab's service level objectives with their error
+// budgets and burn rates, alerting ones first.
+func (h *Handlers) handleSLO(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/slo")
+	defer span.End()
+
+	resp := h.sloStatus()
+	alerting := 0
+	for _, o := range resp.Objectives {
+		if o.Alerting {
+			alerting++
+		}
+	}
+	span.SetAttributes(attribute.Int("slo.objectives", len(resp.Objectives)), attribute.Int("slo.alerting", alerting))
+
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) sloStatus() sloResponse {
+	if h.slo == nil {
+		return sloResponse{Status: slo.Status{Objectives: []slo.Result{}}}
+	}
+	return sloResponse{Enabled: true, Status: h.slo.Status()}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/soak"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/soak -----------------------------------------------------------
+
+type soakResponse struct {
+	Enabled bool `json:"enabled"`
+	soak.Status
+}
+
+// handleSoak reports the soak test in progress, with the trends so far, and
+// the report of the last one.
+func (h *Handlers) handleSoak(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/soak")
+	defer span.End()
+
+	resp := h.soakStatus()
+	span.SetAttributes(attribute.Bool("soak.running", resp.Running))
+
+	writeJSON(w, r, resp)
+}
+
+// --- /api/soak/start -----------------------------------------------------
+
+// handleSoakStart starts a soak test of ?duration= (e.g. 8h) in the
+// background; clients poll GET /api/soak.
+func (h *Handlers) handleSoakStart(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.POST /api/soak/start")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.soak == nil {
+		http.Error(w, "soak tests disabled", http.StatusServiceUnavailable)
+		return
+	}
+	d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+	if err != nil || d <= 0 {
+		http.Error(w, "duration must be a positive duration, e.g. ?duration=8h", http.StatusBadRequest)
+		return
+	}
+	started := h.soak.Start(d)
+	span.SetAttributes(attribute.Bool("soak.started", started), attribute.String("soak.duration", d.String()))
+	if !started {
+		http.Error(w, "a soak test is already running", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(h.soakStatus())
+}
+
+func (h *Handlers) soakStatus() soakResponse {
+	if h.soak == nil {
+		return soakResponse{}
+	}
+	return soakResponse{Enabled: true, Status: h.soak.Status()}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/cluster"
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/errorbudget"
+	"github.com/Parz1val02/OM_module/internal/exposure"
+	"github.com/Parz1val02/OM_module/internal/ims"
+	"github.com/Parz1val02/OM_module/internal/insights"
+	"github.com/Parz1val02/OM_module/internal/logsampling"
+	"github.com/Parz1val02/OM_module/internal/metricbuffer"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/promtail"
+	"github.com/Parz1val02/OM_module/internal/regen"
+	"github.com/Parz1val02/OM_module/internal/roaming"
+	"github.com/Parz1val02/OM_module/internal/runtimestats"
+	"github.com/Parz1val02/OM_module/internal/slo"
+	"github.com/Parz1val02/OM_module/internal/soak"
+	"github.com/Parz1val02/OM_module/internal/subscribers"
+	"github.com/Parz1val02/OM_module/internal/synthetic"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// stateDumpLogLines is how many of the module's last log lines a state dump
+// carries.
+const stateDumpLogLines = 200
+
+// stateDump is a snapshot of everything the module knows at one instant:
+// what the collectors hold, the topology and its health history, the log
+// pipeline and the goroutines.
+type stateDump struct {
+	Time       string                  `json:"time"`
+	Reason     string                  `json:"reason"` // SIGUSR1 | api
+	Versions   debugVersions           `json:"versions"`
+	Status     statusResponse          `json:"status"`
+	Topology   topologyResponse        `json:"topology"`
+	Health     []collector.HealthEvent `json:"health_history"`
+	Collectors stateCollectors         `json:"collectors"`
+	Logging    stateLogging            `json:"logging"`
+	Runtime    *runtimestats.Sample    `json:"runtime,omitempty"`
+	// Goroutines is the goroutine profile, one entry per distinct stack
+	// with its count and pprof labels (subsystem).
+	Goroutines []string `json:"goroutines"`
+}
+
+// stateCollectors is the state of the pollers and pipelines; subsystems
+// that are disabled are left out.
+type stateCollectors struct {
+	Snapshot     stateSnapshot          `json:"snapshot"`
+	Exporters    []collector.Exporter   `json:"exporters"`
+	Capture      *captureStatusResponse `json:"capture,omitempty"`
+	Milestones   *milestone.Status      `json:"milestones,omitempty"`
+	IMSProbes    []ims.ProbeResult      `json:"ims_probes,omitempty"`
+	SEPPProbes   []roaming.Result       `json:"sepp_probes,omitempty"`
+	N6Probes     []n6.Result            `json:"n6_probes,omitempty"`
+	Exposure     *exposure.Status       `json:"exposure,omitempty"`
+	Promtail     *promtail.Status       `json:"promtail,omitempty"`
+	Subscribers  *subscribers.Status    `json:"subscribers,omitempty"`
+	Insights     *insights.Status       `json:"insights,omitempty"`
+	Cluster      *cluster.Overview      `json:"cluster,omitempty"`
+	Synthetic    *synthetic.Result      `json:"synthetic,omitempty"`
+	Soak         *soak.Status           `json:"soak,omitempty"`
+	SLO          *slo.Status            `json:"slo,omitempty"`
+	MetricBuffer *metricbuffer.Status   `json:"metric_buffer,omitempty"`
+	Regen        []regen.JobStatus      `json:"regen"`
+}
+
+type stateSnapshot struct {
+	Version                uint64 `json:"version"`
+	UpdatedAt              string `json:"updated_at,omitempty"`
+	ExternalContainerStats bool   `json:"external_container_stats"`
+}
+
+type stateLogging struct {
+	ErrorBudgets *errorbudget.Status `json:"error_budgets,omitempty"`
+	Sampling     *logsampling.Status `json:"sampling,omitempty"`
+	// ModuleLog holds the last lines the module logged, oldest first.
+	ModuleLog []string `json:"module_log"`
+}
+
+// WriteStateDump writes a state dump to dir/state-<time>.json and returns
+// its path. The file is written under a temporary name and renamed, so a
+// reader never sees a partial dump. reason records what requested it.
+func (h *Handlers) WriteStateDump(ctx context.Context, dir, reason string) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "statedump.write")
+	defer span.End()
+
+	now := time.Now().UTC()
+	b, err := json.MarshalIndent(h.stateDump(ctx, reason, now), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".state-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "state-"+now.Format("20060102T150405Z")+".json")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	span.SetAttributes(attribute.String("statedump.path", path), attribute.Int("statedump.size_bytes", len(b)))
+	h.written.Add(path)
+	return path, nil
+}
+
+// --- /api/debug/state ----------------------------------------------------
+
+// handleStateDump serves the same dump SIGUSR1 writes to a file.
+func (h *Handlers) handleStateDump(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/debug/state")
+	defer span.End()
+
+	dump := h.stateDump(ctx, "api", time.Now().UTC())
+	span.SetAttributes(
+		attribute.Int("statedump.containers", len(dump.Topology.Containers)),
+		attribute.Int("statedump.goroutine_stacks", len(dump.Goroutines)),
+	)
+
+	writeJSON(w, r, dump)
+}
+
+func (h *Handlers) stateDump(ctx context.Context, reason string, now time.Time) stateDump {
+	view := h.snap.View()
+	d := stateDump{
+		Time:     now.Format(time.RFC3339Nano),
+		Reason:   reason,
+		Versions: h.versions(),
+		Status:   h.startupStatus(),
+		Topology: h.buildTopology(ctx, view),
+		Health:   h.snap.HealthHistory(),
+		Collectors: stateCollectors{
+			Snapshot: stateSnapshot{
+				Version:                view.Version,
+				ExternalContainerStats: view.ExternalContainerStats(),
+			},
+			Exporters: view.Exporters(),
+			Regen:     []regen.JobStatus{},
+		},
+		Logging:    stateLogging{ModuleLog: []string{}},
+		Goroutines: goroutineStacks(),
+	}
+	if t := view.Updated; !t.IsZero() {
+		d.Collectors.Snapshot.UpdatedAt = t.UTC().Format(time.RFC3339)
+	}
+
+	c := &d.Collectors
+	if h.capManager != nil {
+		s := h.capManager.Status()
+		c.Capture = &captureStatusResponse{
+			Running: s.Running, Interface: s.Interface, Generation: s.Generation,
+			PacketsTotal: s.PacketsTotal, Packets4G: s.Packets4G, Packets5G: s.Packets5G,
+			RestartCount: s.RestartCount, UptimeSeconds: s.UptimeSeconds,
+		}
+	}
+	if h.milestones != nil {
+		st := h.milestones.Status()
+		c.Milestones = &st
+	}
+	if h.imsProber != nil {
+		c.IMSProbes = h.imsProber.Results()
+	}
+	if h.roaming != nil {
+		c.SEPPProbes = h.roaming.Results()
+	}
+	if h.n6 != nil {
+		c.N6Probes = h.n6.Results()
+	}
+	if h.exposure != nil {
+		st := h.exposure.Status()
+		c.Exposure = &st
+	}
+	if h.promtail != nil {
+		st := h.promtail.Status()
+		c.Promtail = &st
+	}
+	if h.subscribers != nil {
+		st := h.subscribers.Status()
+		c.Subscribers = &st
+	}
+	if h.insights != nil {
+		st := h.insights.Status()
+		c.Insights = &st
+	}
+	if h.soak != nil {
+		st := h.soak.Status()
+		c.Soak = &st
+	}
+	if h.slo != nil {
+		st := h.slo.Status()
+		c.SLO = &st
+	}
+	if h.metricBuffer != nil {
+		st := h.metricBuffer.Status()
+		c.MetricBuffer = &st
+	}
+	if h.cluster != nil {
+		ov := h.cluster.Overview()
+		c.Cluster = &ov
+	}
+	if h.synthetic != nil {
+		_, c.Synthetic = h.synthetic.Status()
+	}
+	if h.regen != nil {
+		c.Regen = h.regen.Status()
+	}
+
+	if h.errorBudgets != nil {
+		st := h.errorBudgets.Status()
+		d.Logging.ErrorBudgets = &st
+	}
+	if h.sampling != nil {
+		st := h.sampling.Status()
+		d.Logging.Sampling = &st
+	}
+	if h.debug.logs != nil {
+		d.Logging.ModuleLog = lastLines(h.debug.logs.Bytes(), stateDumpLogLines)
+	}
+	if h.runtime != nil {
+		s := h.runtime.Last()
+		d.Runtime = &s
+	}
+	return d
+}
+
+// goroutineStacks returns the goroutine profile with identical stacks
+// merged, one entry per stack.
+func goroutineStacks() []string {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return []string{}
+	}
+	out := []string{}
+	for _, s := range strings.Split(buf.String(), "\n\n") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// lastLines returns the last n lines of b.
+func lastLines(b []byte, n int) []string {
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
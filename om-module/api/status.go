@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/featureflags"
+	"github.com/Parz1val02/OM_module/internal/readiness"
+	"github.com/Parz1val02/OM_module/internal/runtimestats"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /status -------------------------------------------------------------
+
+type statusResponse struct {
+	// State is "ready" when every dependency was ready (or skipped) at
+	// startup and "partial" when subsystems started disabled or failed
+	// for good since.
+	State        string             `json:"state"`
+	Disabled     []string           `json:"disabled_subsystems"`
+	Dependencies []readiness.Status `json:"dependencies"`
+	// Subsystems are the supervised subsystems: their state, failures and
+	// restarts.
+	Subsystems []runtimestats.Subsystem `json:"subsystems"`
+	// FeatureFlags is the current state of the flags, which may have
+	// changed since startup.
+	FeatureFlags []featureflags.Flag `json:"feature_flags"`
+}
+
+func (h *Handlers) handleStatus(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /status")
+	defer span.End()
+
+	resp := h.startupStatus()
+	span.SetAttributes(
+		attribute.String("status.state", resp.State),
+		attribute.Int("status.disabled_subsystems", len(resp.Disabled)),
+	)
+	writeJSON(w, r, resp)
+}
+
+func (h *Handlers) startupStatus() statusResponse {
+	resp := statusResponse{State: "ready", Disabled: []string{}, Dependencies: []readiness.Status{}, FeatureFlags: h.flags.Flags()}
+	resp.Subsystems = runtimestats.Subsystems()
+	for _, sub := range resp.Subsystems {
+		if sub.State == runtimestats.StateFailed {
+			resp.State = "partial"
+		}
+	}
+	if h.deps == nil {
+		return resp
+	}
+	resp.Dependencies = h.deps.Status()
+	for _, d := range resp.Dependencies {
+		if !h.deps.Ready(d.Name) {
+			resp.Disabled = append(resp.Disabled, d.Subsystems...)
+		}
+	}
+	if h.deps.Partial() {
+		resp.State = "partial"
+	}
+	return resp
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/subscribers"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /api/subscribers/drift ----------------------------------------------
+
+type subscriberDriftResponse struct {
+	Enabled bool `json:"enabled"`
+	subscribers.Status
+}
+
+// handleSubscriberDrift reports the duplicate and malformed subscribers
+// found by the last check and the latest drift events.
+func (h *Handlers) handleSubscriberDrift(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /api/subscribers/drift")
+	defer span.End()
+
+	resp := subscriberDriftResponse{Status: subscribers.Status{
+		Duplicates: []subscribers.Duplicate{},
+		Malformed:  []subscribers.Malformed{},
+		Recent:     []subscribers.Event{},
+	}}
+	if h.subscribers != nil {
+		resp = subscriberDriftResponse{Enabled: true, Status: h.subscribers.Status()}
+	}
+	span.SetAttributes(
+		attribute.Int("subscribers.count", resp.Subscribers),
+		attribute.Int("subscribers.duplicates", len(resp.Duplicates)),
+		attribute.Int("subscribers.malformed", len(resp.Malformed)),
+	)
+
+	writeJSON(w, r, resp)
+}
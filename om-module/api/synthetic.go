@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Parz1val02/OM_module/internal/synthetic"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- /synthetic ----------------------------------------------------------
+
+type syntheticResponse struct {
+	Enabled bool              `json:"enabled"`
+	Running bool              `json:"running"`
+	Last    *synthetic.Result `json:"last,omitempty"`
+}
+
+func (h *Handlers) handleSynthetic(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.GET /synthetic")
+	defer span.End()
+
+	writeJSON(w, r, h.syntheticStatus())
+}
+
+// handleSyntheticRun starts a test run in the background; it takes longer
+// than the server's write timeout, so clients poll GET /synthetic.
+func (h *Handlers) handleSyntheticRun(w http.ResponseWriter, r *http.Request) {
+	_, span := tracing.Tracer().Start(r.Context(), "http.POST /synthetic/run")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.synthetic == nil {
+		http.Error(w, "synthetic test disabled", http.StatusServiceUnavailable)
+		return
+	}
+	started := h.synthetic.Trigger()
+	span.SetAttributes(attribute.Bool("synthetic.started", started))
+	if !started {
+		http.Error(w, "a synthetic test is already running", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(h.syntheticStatus())
+}
+
+func (h *Handlers) syntheticStatus() syntheticResponse {
+	if h.synthetic == nil {
+		return syntheticResponse{}
+	}
+	running, last := h.synthetic.Status()
+	return syntheticResponse{Enabled: true, Running: running, Last: last}
+}
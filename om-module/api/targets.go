@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/targets"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Page sizes of /api/targets.
+const (
+	defaultTargetsLimit = 50
+	maxTargetsLimit     = 500
+)
+
+// --- /api/targets ----------------------------------------------------------
+
+type targetsResponse struct {
+	Config string `json:"config"`
+	// Total and Counts are over every target; Matched over the filter.
+	Total   int              `json:"total"`
+	Counts  map[string]int   `json:"counts"`
+	Matched int              `json:"matched"`
+	Offset  int              `json:"offset"`
+	Limit   int              `json:"limit"`
+	Targets []targets.Target `json:"targets"`
+}
+
+// handleTargets serves the intended scrape targets merged with the scrape
+// state Prometheus reports, mismatches first: ?job=, ?state= (ok, down,
+// stale, missing, unexpected) and ?q= (part of the job, address or
+// container) filter, ?limit= (default 50, at most 500) and ?offset= page.
+func (h *Handlers) handleTargets(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/targets")
+	defer span.End()
+
+	if h.targets == nil {
+		http.Error(w, "target status disabled (no Prometheus)", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	state := strings.ToLower(q.Get("state"))
+	if state != "" && !slices.Contains(targets.States, state) {
+		http.Error(w, "state must be one of "+strings.Join(targets.States, ", "), http.StatusBadRequest)
+		return
+	}
+	limit, offset := defaultTargetsLimit, 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxTargetsLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxTargetsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if s := q.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	all, err := h.targets.Check(ctx, h.snap.View())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	// Mismatches first, each state in job and address order.
+	slices.SortStableFunc(all, func(a, b targets.Target) int {
+		return slices.Index(targets.States, a.State) - slices.Index(targets.States, b.State)
+	})
+	matched := targets.Filter(all, q.Get("job"), state, q.Get("q"))
+	resp := targetsResponse{
+		Config:  h.targets.ConfigFile(),
+		Total:   len(all),
+		Counts:  targets.Count(all),
+		Matched: len(matched),
+		Offset:  offset,
+		Limit:   limit,
+		Targets: matched[min(offset, len(matched)):min(offset+limit, len(matched))],
+	}
+	span.SetAttributes(attribute.Int("targets.total", resp.Total), attribute.Int("targets.matched", resp.Matched),
+		attribute.Int("targets.missing", resp.Counts[targets.StateMissing]))
+
+	writeJSON(w, r, resp)
+}
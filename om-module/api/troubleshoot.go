@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/tracing"
+	"github.com/Parz1val02/OM_module/internal/troubleshoot"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// prometheusUID is the UID of the provisioned Prometheus datasource.
+const prometheusUID = "PBFA97CFB590B2093"
+
+// --- /api/troubleshoot -----------------------------------------------------
+
+type troubleshootResponse struct {
+	Enabled  bool                   `json:"enabled"`
+	Symptoms []troubleshoot.Symptom `json:"symptoms"`
+}
+
+// handleTroubleshoot lists the symptoms without ?symptom=, and with it runs
+// the tree of the symptom over the live lab:
+// /api/troubleshoot?symptom=ue_cannot_attach&generation=5g&imsi=001010000000001.
+// generation defaults to the running core.
+func (h *Handlers) handleTroubleshoot(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(r.Context(), "http.GET /api/troubleshoot")
+	defer span.End()
+
+	q := r.URL.Query()
+	symptom := q.Get("symptom")
+	if symptom == "" {
+		writeJSON(w, r, troubleshootResponse{Enabled: h.troubleshoot != nil, Symptoms: troubleshoot.Symptoms()})
+		return
+	}
+	if h.troubleshoot == nil {
+		http.Error(w, "troubleshooting disabled", http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("troubleshoot.symptom", symptom))
+
+	d, err := h.troubleshoot.Diagnose(ctx, symptom, q.Get("generation"), q.Get("imsi"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = hostname
+	}
+	exploreLinks(&d, "http://"+net.JoinHostPort(host, "3000"))
+	span.SetAttributes(
+		attribute.String("troubleshoot.generation", d.Generation),
+		attribute.String("troubleshoot.verdict", d.Verdict),
+		attribute.String("troubleshoot.cause", d.Cause),
+	)
+
+	writeJSON(w, r, d)
+}
+
+// exploreLinks points the query evidence of d at Grafana Explore, on the
+// window the checks looked at.
+func exploreLinks(d *troubleshoot.Diagnosis, grafanaURL string) {
+	to := time.Now()
+	window, err := time.ParseDuration(d.Window)
+	if err != nil {
+		window = time.Hour
+	}
+	fromMS := strconv.FormatInt(to.Add(-window).UnixMilli(), 10)
+	toMS := strconv.FormatInt(to.UnixMilli(), 10)
+
+	for i := range d.Steps {
+		for j := range d.Steps[i].Evidence {
+			ev := &d.Steps[i].Evidence[j]
+			uid := dashboards.LokiUID
+			switch ev.Datasource {
+			case troubleshoot.DatasourceLoki:
+			case troubleshoot.DatasourcePrometheus:
+				uid = prometheusUID
+			default:
+				continue
+			}
+			explore, _ := json.Marshal(map[string]any{
+				"datasource": uid,
+				"queries":    []map[string]string{{"refId": "A", "expr": ev.Query}},
+				"range":      map[string]string{"from": fromMS, "to": toMS},
+			})
+			ev.URL = grafanaURL + "/explore?orgId=1&left=" + url.QueryEscape(string(explore))
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/output"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// benchTargetJob is the Prometheus job that scrapes the Open5GS metric
+// endpoints (prometheus/configs/prometheus.yml).
+const benchTargetJob = "docker-services"
+
+// benchSampleEvery is how often the module's gauges are read during a
+// phase: rarely enough not to be scrape load of its own.
+const benchSampleEvery = 5 * time.Second
+
+type benchReport struct {
+	StartedAt   string       `json:"started_at"`
+	Module      string       `json:"module"`
+	HostCPUs    int          `json:"host_cpus"`
+	Concurrency int          `json:"concurrency"`
+	Targets     []string     `json:"targets"`
+	Phases      []benchPhase `json:"phases"` // idle, then load
+	Added       []benchAdded `json:"added_latency"`
+	Completed   bool         `json:"completed"`
+	Warnings    []string     `json:"warnings,omitempty"`
+}
+
+// bencher runs the phases of a benchmark.
+type bencher struct {
+	module      string
+	client      *http.Client
+	interval    time.Duration // between probe rounds
+	concurrency int
+	targets     []string
+	warnings    []string
+}
+
+// benchPhase is what was measured while the module was scraped as usual
+// ("idle") or by Concurrency clients at once ("load").
+type benchPhase struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+	// CPUPercent is the module's CPU time over the phase, in percent of
+	// one core; RSSBytes and Goroutines the highest values seen.
+	CPUPercent float64 `json:"cpu_percent"`
+	RSSBytes   float64 `json:"rss_bytes"`
+	Goroutines float64 `json:"goroutines,omitempty"`
+	// DockerCalls are the Docker API requests per second, per call.
+	DockerCalls      map[string]float64 `json:"docker_calls_per_second"`
+	DockerCallsTotal float64            `json:"docker_calls_per_second_total"`
+	// Scrapes are the scrapes of the module's /metrics by the load
+	// clients, "load" only.
+	Scrapes   *benchLatency   `json:"module_scrapes,omitempty"`
+	Endpoints []benchEndpoint `json:"endpoints"`
+}
+
+type benchLatency struct {
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	PerSecond float64 `json:"per_second"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+type benchEndpoint struct {
+	Target string `json:"target"`
+	benchLatency
+}
+
+// benchAdded is how much slower an Open5GS endpoint answered under load.
+type benchAdded struct {
+	Target    string  `json:"target"`
+	IdleP95Ms float64 `json:"idle_p95_ms"`
+	LoadP95Ms float64 `json:"load_p95_ms"`
+	AddedMs   float64 `json:"added_p95_ms"`
+}
+
+// runBench implements `om-module bench`: it measures the overhead the
+// running module imposes on the lab machine, to justify running it on
+// constrained hosts. The run has two phases of half the duration each:
+//
+//   - idle: the module runs as usual;
+//   - load: -concurrency clients scrape its /metrics back to back.
+//
+// In both, every Open5GS metric endpoint (the targets of the
+// docker-services Prometheus job, or -targets) is fetched every
+// -probe-interval and timed, and the module's own metrics give its CPU
+// time, resident memory, goroutines and Docker API requests
+// (om_docker_api_calls_total). The report lists them per phase and the
+// latency the load added to each endpoint at the 95th percentile. The exit
+// code is 1 if the module cannot be reached.
+func runBench(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	duration := fs.Duration("duration", 2*time.Minute, "length of the run, split evenly between the idle and load phases")
+	concurrency := fs.Int("concurrency", 8, "concurrent scrapes of the module's /metrics during the load phase")
+	interval := fs.Duration("probe-interval", time.Second, "interval between two fetches of every Open5GS metric endpoint")
+	targets := fs.String("targets", "", "comma-separated Open5GS metric URLs (empty = the "+benchTargetJob+" targets of PROMETHEUS_URL)")
+	module := fs.String("module", "http://localhost:"+cfg.Port, "URL of the running module")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	reports := fs.String("reports", output.Dir(cfg.OutputDir, output.Reports), "directory the report is saved to as JSON (empty = not saved)")
+	_ = fs.Parse(args)
+
+	if *duration < 2*benchSampleEvery || *concurrency < 1 || *interval <= 0 {
+		fmt.Fprintf(os.Stderr, "usage: om-module bench [-duration ≥ %s] [-concurrency ≥ 1] [-probe-interval 1s] [-targets url,…] [-json]\n", 2*benchSampleEvery)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	b := &bencher{
+		module:      strings.TrimRight(*module, "/"),
+		client:      &http.Client{Timeout: moduleTimeout},
+		interval:    *interval,
+		concurrency: *concurrency,
+	}
+	r := &benchReport{
+		StartedAt:   time.Now().UTC().Format(time.RFC3339),
+		HostCPUs:    runtime.NumCPU(),
+		Concurrency: *concurrency,
+		Module:      b.module,
+	}
+	if _, err := b.sample(ctx); err != nil {
+		log.Printf("⚠️  Module: %v", err)
+		return 1
+	}
+	if *targets != "" {
+		for _, t := range strings.Split(*targets, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				b.targets = append(b.targets, t)
+			}
+		}
+	} else if cfg.PrometheusURL != "" {
+		found, err := benchTargets(ctx, b.client, cfg.PrometheusURL)
+		if err != nil {
+			b.warnings = append(b.warnings, fmt.Sprintf("Open5GS endpoints not discovered: %v", err))
+		}
+		b.targets = found
+	}
+	if len(b.targets) == 0 {
+		b.warnings = append(b.warnings, "no Open5GS metric endpoints to probe (is the core running? else pass -targets): only the module is measured")
+	}
+
+	log.Printf("▶ Benchmark: %s idle, then %s with %d concurrent scrapes; %d Open5GS endpoints", *duration/2, *duration/2, *concurrency, len(b.targets))
+	r.Completed = true
+	for _, load := range []bool{false, true} {
+		p, err := b.phase(ctx, load, *duration/2)
+		if err != nil {
+			b.warnings = append(b.warnings, err.Error())
+		}
+		if p != nil {
+			r.Phases = append(r.Phases, *p)
+		}
+		if ctx.Err() != nil || err != nil {
+			r.Completed = false
+			break
+		}
+	}
+	r.Targets, r.Warnings = b.targets, b.warnings
+	r.Added = addedLatency(r.Phases)
+
+	written := output.NewManifest()
+	defer written.Log("bench")
+	if *reports != "" {
+		path, err := saveBench(*reports, r)
+		if err != nil {
+			log.Printf("⚠️  Report not saved: %v", err)
+		} else {
+			written.Add(path)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+		return 0
+	}
+	printBench(os.Stdout, r)
+	return 0
+}
+
+// benchSample holds the module metrics a phase is measured with.
+type benchSample struct {
+	at          time.Time
+	cpuSeconds  float64
+	rss         float64
+	goroutines  float64
+	dockerCalls map[string]float64
+}
+
+// sample reads the module's /metrics.
+func (b *bencher) sample(ctx context.Context) (benchSample, error) {
+	s := benchSample{at: time.Now(), dockerCalls: map[string]float64{}}
+	body, _, err := b.fetch(ctx, b.module+"/metrics")
+	if err != nil {
+		return s, fmt.Errorf("not reachable at %s: %w", b.module, err)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return s, fmt.Errorf("parse metrics: %w", err)
+	}
+	value := func(m *dto.Metric) float64 {
+		switch {
+		case m.GetCounter() != nil:
+			return m.GetCounter().GetValue()
+		case m.GetGauge() != nil:
+			return m.GetGauge().GetValue()
+		}
+		return 0
+	}
+	for _, m := range families["process_cpu_seconds_total"].GetMetric() {
+		s.cpuSeconds += value(m)
+	}
+	for _, m := range families["process_resident_memory_bytes"].GetMetric() {
+		s.rss += value(m)
+	}
+	for _, m := range families["om_runtime_goroutines"].GetMetric() {
+		s.goroutines += value(m)
+	}
+	for _, m := range families["om_docker_api_calls_total"].GetMetric() {
+		for _, l := range m.GetLabel() {
+			if l.GetName() == "call" {
+				s.dockerCalls[l.GetValue()] += value(m)
+			}
+		}
+	}
+	return s, nil
+}
+
+// fetch GETs url and returns its body and how long it took to read it.
+func (b *bencher) fetch(ctx context.Context, url string) ([]byte, time.Duration, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	took := time.Since(start)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return body, took, err
+}
+
+// phase runs one phase for d.
+func (b *bencher) phase(ctx context.Context, load bool, d time.Duration) (*benchPhase, error) {
+	p := &benchPhase{Name: "idle", DockerCalls: map[string]float64{}}
+	if load {
+		p.Name = "load"
+	}
+	first, err := b.sample(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s phase: module %v", p.Name, err)
+	}
+	p.RSSBytes, p.Goroutines = first.rss, first.goroutines
+
+	phaseCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	var wg sync.WaitGroup
+	scrapes := &benchRecorder{}
+	if load {
+		for i := 0; i < b.concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for phaseCtx.Err() == nil {
+					_, took, err := b.fetch(phaseCtx, b.module+"/metrics")
+					if phaseCtx.Err() == nil {
+						scrapes.add(took, err)
+					}
+				}
+			}()
+		}
+	}
+
+	endpoints := make([]*benchRecorder, len(b.targets))
+	for i := range endpoints {
+		endpoints[i] = &benchRecorder{}
+	}
+	probe := time.NewTicker(b.interval)
+	defer probe.Stop()
+	gauges := time.NewTicker(benchSampleEvery)
+	defer gauges.Stop()
+	var lastErr error
+loop:
+	for {
+		select {
+		case <-probe.C:
+			for i, t := range b.targets {
+				_, took, err := b.fetch(phaseCtx, t)
+				if phaseCtx.Err() != nil {
+					break
+				}
+				endpoints[i].add(took, err)
+			}
+		case <-gauges.C:
+			s, err := b.sample(phaseCtx)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			p.RSSBytes, p.Goroutines = max(p.RSSBytes, s.rss), max(p.Goroutines, s.goroutines)
+		case <-phaseCtx.Done():
+			break loop
+		}
+	}
+	wg.Wait()
+
+	last, err := b.sample(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s phase: module %v", p.Name, err)
+	}
+	elapsed := last.at.Sub(first.at).Seconds()
+	p.Duration = last.at.Sub(first.at).Round(time.Second).String()
+	p.CPUPercent = round2((last.cpuSeconds - first.cpuSeconds) / elapsed * 100)
+	p.RSSBytes, p.Goroutines = max(p.RSSBytes, last.rss), max(p.Goroutines, last.goroutines)
+	for call, n := range last.dockerCalls {
+		rate := round2((n - first.dockerCalls[call]) / elapsed)
+		p.DockerCalls[call] = rate
+		p.DockerCallsTotal += rate
+	}
+	p.DockerCallsTotal = round2(p.DockerCallsTotal)
+	if load {
+		l := scrapes.summary(elapsed)
+		p.Scrapes = &l
+	}
+	for i, t := range b.targets {
+		p.Endpoints = append(p.Endpoints, benchEndpoint{Target: t, benchLatency: endpoints[i].summary(elapsed)})
+	}
+	if lastErr != nil && ctx.Err() == nil {
+		b.warnings = append(b.warnings, fmt.Sprintf("%s phase: module %v", p.Name, lastErr))
+	}
+	return p, nil
+}
+
+// benchRecorder collects request times.
+type benchRecorder struct {
+	mu     sync.Mutex
+	times  []time.Duration
+	errors int
+}
+
+func (b *benchRecorder) add(d time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.errors++
+		return
+	}
+	b.times = append(b.times, d)
+}
+
+// summary returns the latencies of the successful requests over elapsed
+// seconds.
+func (b *benchRecorder) summary(elapsed float64) benchLatency {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := benchLatency{Requests: len(b.times) + b.errors, Errors: b.errors}
+	if elapsed > 0 {
+		l.PerSecond = round2(float64(l.Requests) / elapsed)
+	}
+	if len(b.times) == 0 {
+		return l
+	}
+	sorted := append([]time.Duration{}, b.times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	pct := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return round2(float64(sorted[max(i, 0)]) / float64(time.Millisecond))
+	}
+	l.P50Ms, l.P95Ms, l.MaxMs = pct(0.5), pct(0.95), pct(1)
+	return l
+}
+
+// addedLatency compares the endpoints of the idle and load phases.
+func addedLatency(phases []benchPhase) []benchAdded {
+	if len(phases) < 2 {
+		return nil
+	}
+	idle := make(map[string]benchLatency)
+	for _, e := range phases[0].Endpoints {
+		idle[e.Target] = e.benchLatency
+	}
+	var out []benchAdded
+	for _, e := range phases[1].Endpoints {
+		i, ok := idle[e.Target]
+		if !ok || i.Requests == i.Errors || e.Requests == e.Errors {
+			continue
+		}
+		out = append(out, benchAdded{Target: e.Target, IdleP95Ms: i.P95Ms, LoadP95Ms: e.P95Ms, AddedMs: round2(e.P95Ms - i.P95Ms)})
+	}
+	return out
+}
+
+// benchTargets returns the scrape URLs of the healthy targets of
+// benchTargetJob in the Prometheus at url.
+func benchTargets(ctx context.Context, client *http.Client, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(url, "/")+"/api/v1/targets?state=active", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus targets: unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			ActiveTargets []struct {
+				Labels    map[string]string `json:"labels"`
+				ScrapeURL string            `json:"scrapeUrl"`
+				Health    string            `json:"health"`
+			} `json:"activeTargets"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, t := range body.Data.ActiveTargets {
+		if t.Labels["job"] == benchTargetJob && t.Health == "up" {
+			out = append(out, t.ScrapeURL)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// saveBench writes r as bench-<time>.json in dir.
+func saveBench(dir string, r *benchReport) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "bench-"+time.Now().UTC().Format("20060102-150405")+".json")
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printBench(w io.Writer, r *benchReport) {
+	fmt.Fprintf(w, "Benchmark of %s on %d CPUs (%d concurrent scrapes under load)\n", r.Module, r.HostCPUs, r.Concurrency)
+	if !r.Completed {
+		fmt.Fprintln(w, "⚠️  Interrupted: partial results")
+	}
+	for _, warn := range r.Warnings {
+		fmt.Fprintf(w, "⚠️  %s\n", warn)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHASE\tDURATION\tMODULE CPU\tRSS\tGOROUTINES\tDOCKER API\tMODULE SCRAPES\t")
+	for _, p := range r.Phases {
+		scrapes := "—"
+		if p.Scrapes != nil {
+			scrapes = fmt.Sprintf("%.1f/s, p95 %g ms", p.Scrapes.PerSecond, p.Scrapes.P95Ms)
+			if p.Scrapes.Errors > 0 {
+				scrapes += fmt.Sprintf(", %d errors", p.Scrapes.Errors)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f %%\t%.1f MiB\t%.0f\t%.2f calls/s\t%s\t\n",
+			p.Name, p.Duration, p.CPUPercent, p.RSSBytes/(1<<20), p.Goroutines, p.DockerCallsTotal, scrapes)
+	}
+	_ = tw.Flush()
+
+	for _, p := range r.Phases {
+		if len(p.DockerCalls) == 0 {
+			continue
+		}
+		calls := make([]string, 0, len(p.DockerCalls))
+		for call, rate := range p.DockerCalls {
+			calls = append(calls, fmt.Sprintf("%s %.2f/s", call, rate))
+		}
+		sort.Strings(calls)
+		fmt.Fprintf(w, "\nDocker API (%s): %s\n", p.Name, strings.Join(calls, ", "))
+	}
+
+	if len(r.Added) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "OPEN5GS ENDPOINT\tIDLE P95\tLOAD P95\tADDED\t")
+		for _, a := range r.Added {
+			fmt.Fprintf(tw, "%s\t%g ms\t%g ms\t%+g ms\t\n", a.Target, a.IdleP95Ms, a.LoadP95Ms, a.AddedMs)
+		}
+		_ = tw.Flush()
+	}
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/output"
+)
+
+// bootstrapNetwork is the Docker network created by the core compose files;
+// services.yaml and ran.yaml attach to it.
+const bootstrapNetwork = "docker_open5gs_default"
+
+// bootstrapLogVolumes are the external volumes services.yaml expects: the
+// Open5GS log directories of each core, shared with promtail and the module.
+var bootstrapLogVolumes = []string{"open5gs_4g_logs", "open5gs_5g_logs"}
+
+// metricsConfig is one Open5GS config file whose NF section must expose a
+// Prometheus endpoint on :9091 (scraped through json-exporter and docker SD).
+type metricsConfig struct {
+	file    string // relative to the project directory
+	section string // top-level YAML key of the NF
+	ipVar   string // address placeholder replaced by the NF's init script
+}
+
+var bootstrapMetricsConfigs = []metricsConfig{
+	{"amf/amf.yaml", "amf", "AMF_IP"},
+	{"smf/smf.yaml", "smf", "SMF_IP"},
+	{"smf/smf2.yaml", "smf", "SMF2_IP"},
+	{"smf/smf_4g.yaml", "smf", "SMF_IP"},
+	{"upf/upf.yaml", "upf", "UPF_IP"},
+	{"upf/upf2.yaml", "upf", "UPF2_IP"},
+	{"pcf/pcf.yaml", "pcf", "PCF_IP"},
+	{"mme/mme.yaml", "mme", "MME_IP"},
+	{"hss/hss.yaml", "hss", "HSS_IP"},
+	{"pcrf/pcrf.yaml", "pcrf", "PCRF_IP"},
+}
+
+// bootstrapReadyTimeout bounds the wait for the module to answer /ping and
+// report containers after the stack is started.
+const bootstrapReadyTimeout = 3 * time.Minute
+
+// runBootstrap implements `om-module bootstrap`: it takes a fresh checkout of
+// the testbed to a running, observed lab in one command.
+//
+//   - prerequisites: docker daemon access, docker compose, DOCKER_GID, the
+//     project's compose files and .env
+//   - the metrics endpoint is enabled in every Open5GS config that lacks it
+//   - the external log volumes are created
+//   - the core of -generation is started when its network does not exist yet
+//   - services.yaml (observability stack + this module) is brought up
+//   - the module is polled until discovery reports the topology
+//
+// Every step is idempotent, so bootstrap can be re-run after fixing an error.
+// Unlike cleanup it runs on the host (`make bootstrap`), not inside the
+// container it is about to start; the exit code is 1 on the first failed step.
+func runBootstrap(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	project := fs.String("project", "..", "path of the docker compose project (the repository root)")
+	generation := fs.String("generation", "5g", `core to start if none is running: "4g", "5g" or "none"`)
+	dryRun := fs.Bool("dry-run", false, "print what would be done without changing anything")
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir, err := filepath.Abs(*project)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	b := &bootstrapper{cfg: cfg, dir: dir, generation: *generation, dryRun: *dryRun, written: output.NewManifest()}
+	defer b.written.Log("bootstrap")
+
+	log.Printf("🧰 O&M bootstrap (project=%s, generation=%s, dry-run=%v)", dir, *generation, *dryRun)
+
+	steps := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"Prerequisites", b.checkPrerequisites},
+		{"Open5GS metrics endpoints", b.enableMetrics},
+		{"Log volumes", b.createLogVolumes},
+		{"Core network", b.startCore},
+		{"Observability stack", b.startServices},
+		{"Discovery", b.waitForDiscovery},
+	}
+	for _, s := range steps {
+		log.Printf("▶ %s", s.name)
+		if err := s.fn(ctx); err != nil {
+			log.Printf("⚠️  %s: %v", s.name, err)
+			log.Printf("⚠️  Bootstrap stopped — fix the problem above and run it again")
+			return 1
+		}
+	}
+
+	log.Printf("✅ Bootstrap finished")
+	log.Printf("   Grafana         → http://localhost:3000")
+	log.Printf("   Lab guide       → http://localhost:%s/educational/", cfg.Port)
+	log.Printf("   Next            → bash scripts/mongo_insert.sh, then make e1 / e3 / e4")
+	return 0
+}
+
+type bootstrapper struct {
+	cfg        *config.Config
+	dir        string
+	generation string
+	dryRun     bool
+	written    *output.Manifest // config files changed in the project
+}
+
+func (b *bootstrapper) checkPrerequisites(ctx context.Context) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker CLI not found in PATH")
+	}
+	out, err := b.output(ctx, "docker", "info", "--format", "{{.ServerVersion}}")
+	if err != nil {
+		return fmt.Errorf("cannot reach the Docker daemon (is your user in the docker group?): %s", out)
+	}
+	log.Printf("   Docker Engine %s", out)
+	out, err = b.output(ctx, "docker", "compose", "version", "--short")
+	if err != nil {
+		return fmt.Errorf("docker compose v2 plugin not available: %s", out)
+	}
+	log.Printf("   Docker Compose %s", out)
+
+	files := []string{".env", "services.yaml"}
+	switch b.generation {
+	case "4g":
+		files = append(files, "4G_core.yaml")
+	case "5g":
+		files = append(files, "5G_core.yaml")
+	case "none":
+	default:
+		return fmt.Errorf("unknown -generation %q (want 4g, 5g or none)", b.generation)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(b.dir, f)); err != nil {
+			if f == ".env" {
+				return b.suggestEnv(ctx)
+			}
+			return fmt.Errorf("%s not found in %s — is -project the repository root?", f, b.dir)
+		}
+	}
+
+	// Prometheus runs as 65534:${DOCKER_GID} to read the Docker socket.
+	if os.Getenv("DOCKER_GID") == "" {
+		gid, err := dockerGroupID()
+		if err != nil {
+			return fmt.Errorf("DOCKER_GID is not set and the docker group was not found: %w", err)
+		}
+		_ = os.Setenv("DOCKER_GID", gid)
+		log.Printf("   DOCKER_GID not exported — using %s for this run (see README, Host configuration)", gid)
+	}
+	return nil
+}
+
+// suggestEnv writes .env.suggested for a checkout without .env (see
+// `om-module env`) and stops: the addresses of the lab are the student's
+// to confirm before anything is started with them.
+func (b *bootstrapper) suggestEnv(ctx context.Context) error {
+	path := filepath.Join(b.dir, ".env.suggested")
+	if b.dryRun {
+		log.Printf("   would write %s", path)
+		return fmt.Errorf(".env not found in %s", b.dir)
+	}
+	res, err := suggestEnv(ctx, b.cfg, b.dir, bootstrapNetwork)
+	if err != nil {
+		return fmt.Errorf(".env not found in %s, and none could be suggested: %w", b.dir, err)
+	}
+	for _, w := range res.Warnings {
+		log.Printf("   ⚠️  %s", w)
+	}
+	if err := writeNew(path, res.Env); err != nil {
+		return fmt.Errorf(".env not found in %s: %w", b.dir, err)
+	}
+	b.written.Add(path)
+	return fmt.Errorf(".env not found in %s — a suggestion (TEST_NETWORK %s) was written to .env.suggested: review it, rename it to .env and run bootstrap again", b.dir, res.Subnet)
+}
+
+var metricsKey = regexp.MustCompile(`(?m)^  metrics:`)
+
+// enableMetrics adds the metrics server to the Open5GS configurations that
+// lack it. The files are tracked in the repository, so they are replaced
+// together through an output.Txn, keeping their mode: an interrupted run
+// leaves every file as it was or fully rewritten.
+func (b *bootstrapper) enableMetrics(context.Context) error {
+	tx := output.NewTxn()
+	var enabled []metricsConfig
+	for _, mc := range bootstrapMetricsConfigs {
+		path := filepath.Join(b.dir, mc.file)
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // optional NF (e.g. smf2/upf2 for E4) not in this checkout
+		}
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if metricsKey.Match(data) {
+			continue
+		}
+
+		header := []byte("\n" + mc.section + ":\n")
+		i := bytes.Index(data, header)
+		if i < 0 {
+			return fmt.Errorf("%s: no top-level %q section", mc.file, mc.section)
+		}
+		i += len(header)
+		block := fmt.Sprintf("  metrics:\n    server:\n      - address: %s\n        port: 9091\n", mc.ipVar)
+		if b.dryRun {
+			log.Printf("   would enable metrics in %s", mc.file)
+			continue
+		}
+		out := append(append(append([]byte{}, data[:i]...), block...), data[i:]...)
+		tx.WriteFile(path, out, info.Mode().Perm())
+		enabled = append(enabled, mc)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, mc := range enabled {
+		b.written.Add(filepath.Join(b.dir, mc.file))
+		log.Printf("✅ Metrics enabled in %s (%s:9091)", mc.file, mc.ipVar)
+	}
+	return nil
+}
+
+func (b *bootstrapper) createLogVolumes(ctx context.Context) error {
+	for _, v := range bootstrapLogVolumes {
+		if _, err := b.output(ctx, "docker", "volume", "inspect", v); err == nil {
+			continue
+		}
+		if err := b.run(ctx, "docker", "volume", "create", v); err != nil {
+			return fmt.Errorf("create volume %s: %w", v, err)
+		}
+		log.Printf("✅ Volume %s created", v)
+	}
+	return nil
+}
+
+func (b *bootstrapper) startCore(ctx context.Context) error {
+	if _, err := b.output(ctx, "docker", "network", "inspect", bootstrapNetwork); err == nil {
+		log.Printf("   %s exists — core already running", bootstrapNetwork)
+		return nil
+	}
+	if b.generation == "none" {
+		return fmt.Errorf("%s does not exist; start a core first (make core-4g-up / core-5g-up) or pass -generation", bootstrapNetwork)
+	}
+	file := strings.ToUpper(b.generation) + "_core.yaml"
+	if err := b.compose(ctx, file, "up", "-d"); err != nil {
+		return err
+	}
+	if b.dryRun {
+		return nil
+	}
+	return b.run(ctx, "bash", filepath.Join("scripts", "wait_core.sh"), b.generation)
+}
+
+func (b *bootstrapper) startServices(ctx context.Context) error {
+	return b.compose(ctx, "services.yaml", "up", "-d")
+}
+
+// waitForDiscovery polls the module until /ping answers and the collector
+// has reported the running containers, then prints them by domain.
+func (b *bootstrapper) waitForDiscovery(ctx context.Context) error {
+	if b.dryRun {
+		log.Printf("   would wait for http://localhost:%s/topology", b.cfg.Port)
+		return nil
+	}
+	base := "http://localhost:" + b.cfg.Port
+	ctx, cancel := context.WithTimeout(ctx, bootstrapReadyTimeout)
+	defer cancel()
+
+	var topo struct {
+		Running  int `json:"running"`
+		Services []struct {
+			Service  string `json:"service"`
+			Domain   string `json:"domain"`
+			Replicas int    `json:"replicas"`
+			Running  int    `json:"running"`
+		} `json:"services"`
+	}
+	for {
+		if err := getJSON(ctx, base+"/topology", &topo); err == nil && topo.Running > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("module did not report a topology on %s within %s: %w", base, bootstrapReadyTimeout, ctx.Err())
+		case <-time.After(3 * time.Second):
+		}
+	}
+
+	byDomain := make(map[string][]string)
+	var domains []string
+	for _, s := range topo.Services {
+		if _, ok := byDomain[s.Domain]; !ok {
+			domains = append(domains, s.Domain)
+		}
+		entry := s.Service
+		if s.Running < s.Replicas {
+			entry += fmt.Sprintf(" (%d/%d)", s.Running, s.Replicas)
+		}
+		byDomain[s.Domain] = append(byDomain[s.Domain], entry)
+	}
+	for _, d := range domains {
+		log.Printf("   %-14s %s", d, strings.Join(byDomain[d], ", "))
+	}
+	log.Printf("✅ Discovery: %d containers running", topo.Running)
+
+	var capture struct {
+		Running    bool   `json:"running"`
+		Interface  string `json:"interface"`
+		Generation string `json:"generation"`
+	}
+	if err := getJSON(ctx, base+"/capture/status", &capture); err == nil {
+		if capture.Running {
+			log.Printf("✅ Capture running on %s (%s)", capture.Interface, capture.Generation)
+		} else {
+			log.Printf("   Capture waiting for a core generation to be detected")
+		}
+	}
+	return nil
+}
+
+// compose runs `docker compose -f file args…` in the project directory.
+func (b *bootstrapper) compose(ctx context.Context, file string, args ...string) error {
+	return b.run(ctx, "docker", append([]string{"compose", "-f", file}, args...)...)
+}
+
+// run executes a state-changing command in the project directory, streaming
+// its output, or only prints it in dry-run mode.
+func (b *bootstrapper) run(ctx context.Context, name string, args ...string) error {
+	if b.dryRun {
+		log.Printf("   would run: %s %s", name, strings.Join(args, " "))
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = b.dir
+	cmd.Env = append(os.Environ(), "COMPOSE_IGNORE_ORPHANS=true")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	return cmd.Run()
+}
+
+// output executes a read-only command and returns its trimmed output.
+func (b *bootstrapper) output(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = b.dir
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// dockerGroupID returns the GID of the docker group from /etc/group.
+func dockerGroupID() (string, error) {
+	data, err := os.ReadFile("/etc/group")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) >= 3 && fields[0] == "docker" {
+			return fields[2], nil
+		}
+	}
+	return "", fmt.Errorf("no docker group in /etc/group")
+}
+
+func getJSON(ctx context.Context, target string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, moduleTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", target, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/output"
+)
+
+// moduleTimeout bounds the call to the running module's own API.
+const moduleTimeout = 5 * time.Second
+
+// lokiCleanupSelector matches every stream shipped by Promtail for the
+// Open5GS core (see promtail/core/config.yml).
+const lokiCleanupSelector = `{job="open5gs"}`
+
+// runCleanup implements `om-module cleanup`: it resets the state the module
+// leaves behind between lab sessions so the next group starts from scratch.
+//
+//   - lab milestones on the running module are reset (POST /milestones/reset)
+//   - with -grafana, milestone annotations are deleted from Grafana
+//   - with -loki, a Loki delete request is filed for the core log streams
+//   - unless -keep-files, the subdirectories the module writes under
+//     OUTPUT_DIR (output.Subdirs: dashboards/, prometheus/, logs/,
+//     sessions/, …) are removed
+//
+// The lease file in OUTPUT_DIR is left alone: it belongs to whichever
+// module holds it, which regenerates its files on the next run. Files a
+// setting points outside OUTPUT_DIR are not the root's and are kept. Every
+// step is best-effort; the exit code is 1 if any step failed.
+func runCleanup(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	grafana := fs.Bool("grafana", false, "delete milestone annotations from Grafana")
+	loki := fs.Bool("loki", false, "request deletion of the core log streams from Loki")
+	keepFiles := fs.Bool("keep-files", false, "keep the files the module wrote under OUTPUT_DIR")
+	dryRun := fs.Bool("dry-run", false, "print what would be done without changing anything")
+	_ = fs.Parse(args)
+
+	// Ctrl-C aborts the step in progress instead of waiting for its timeout.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &cleaner{
+		cfg:    cfg,
+		dryRun: *dryRun,
+		client: &http.Client{},
+	}
+
+	log.Printf("🧹 O&M cleanup (dry-run=%v)", *dryRun)
+
+	failed := false
+	step := func(name string, fn func(context.Context) error) {
+		if ctx.Err() != nil {
+			log.Printf("⚠️  %s: skipped (%v)", name, ctx.Err())
+			failed = true
+			return
+		}
+		if err := fn(ctx); err != nil {
+			log.Printf("⚠️  %s: %v", name, err)
+			failed = true
+		}
+	}
+
+	step("Milestones reset", c.resetMilestones)
+	if *grafana {
+		step("Grafana annotations", c.deleteAnnotations)
+	}
+	if *loki {
+		step("Loki delete request", c.deleteLokiStreams)
+	}
+	if !*keepFiles {
+		step("Output files", c.removeOutput)
+	}
+
+	if failed {
+		log.Printf("⚠️  Cleanup finished with errors")
+		return 1
+	}
+	log.Printf("✅ Cleanup finished")
+	return 0
+}
+
+type cleaner struct {
+	cfg    *config.Config
+	dryRun bool
+	client *http.Client
+}
+
+// resetMilestones asks the running module to start a new lab session. A
+// module that is not running, or runs with milestones disabled, is not an error.
+func (c *cleaner) resetMilestones(ctx context.Context) error {
+	target := "http://localhost:" + c.cfg.Port + "/milestones/reset"
+	if c.dryRun {
+		log.Printf("   would POST %s", target)
+		return nil
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, target, moduleTimeout)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("   module not reachable on :%s — nothing to reset", c.cfg.Port)
+		return nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		log.Printf("✅ Milestones reset")
+	case http.StatusServiceUnavailable:
+		log.Printf("   milestone engine disabled — nothing to reset")
+	default:
+		return fmt.Errorf("POST %s: unexpected status %s", target, resp.Status)
+	}
+	return nil
+}
+
+// deleteAnnotations removes every Grafana annotation tagged "milestone".
+func (c *cleaner) deleteAnnotations(ctx context.Context) error {
+	gc := newGrafanaClient(c.cfg)
+	if gc == nil {
+		return fmt.Errorf("GRAFANA_URL is off")
+	}
+
+	annotations, err := gc.FindAnnotations(ctx, []string{"milestone"}, 5000)
+	if err != nil {
+		return fmt.Errorf("list annotations: %w", err)
+	}
+
+	if c.dryRun {
+		log.Printf("   would delete %d milestone annotation(s) from Grafana", len(annotations))
+		return nil
+	}
+
+	for _, a := range annotations {
+		if err := gc.DeleteAnnotation(ctx, a.ID); err != nil {
+			return fmt.Errorf("delete annotation %d: %w", a.ID, err)
+		}
+	}
+	log.Printf("✅ Deleted %d milestone annotation(s) from Grafana", len(annotations))
+	return nil
+}
+
+// deleteLokiStreams files a delete request for all core log streams. Loki
+// applies it asynchronously from the compactor; see loki/local-config.yml.
+func (c *cleaner) deleteLokiStreams(ctx context.Context) error {
+	if c.cfg.LokiURL == "" {
+		return fmt.Errorf("LOKI_URL is off")
+	}
+
+	q := url.Values{}
+	q.Set("query", lokiCleanupSelector)
+	q.Set("start", "0")
+	q.Set("end", fmt.Sprint(time.Now().Unix()))
+	target := strings.TrimRight(c.cfg.LokiURL, "/") + "/loki/api/v1/delete?" + q.Encode()
+
+	if c.dryRun {
+		log.Printf("   would POST %s", target)
+		return nil
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, target, c.cfg.LokiTimeout)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: unexpected status %s", target, resp.Status)
+	}
+	log.Printf("✅ Loki delete request accepted for %s", lokiCleanupSelector)
+	return nil
+}
+
+// removeOutput removes the subdirectories of OUTPUT_DIR the module writes
+// into. The lease file next to them is not touched.
+func (c *cleaner) removeOutput(context.Context) error {
+	if c.cfg.OutputDir == "" {
+		return nil
+	}
+	removed := 0
+	for _, sub := range output.Subdirs {
+		dir := output.Dir(c.cfg.OutputDir, sub)
+		if _, err := os.Lstat(dir); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if c.dryRun {
+			log.Printf("   would remove %s", dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		removed++
+	}
+	if !c.dryRun {
+		log.Printf("✅ Removed %d output subdirectories of %s", removed, c.cfg.OutputDir)
+	}
+	return nil
+}
+
+// do sends a body-less request bounded by timeout and returns the response
+// with its body already closed; callers only look at the status.
+func (c *cleaner) do(ctx context.Context, method, target string, timeout time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("⚠️  Failed to close response body: %v", err)
+	}
+	return resp, nil
+}
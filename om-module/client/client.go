@@ -0,0 +1,272 @@
+// Package client is a Go client for the O&M module HTTP API: topology,
+// startup status and the health rollup, capture and exporter status, log
+// pipeline status and the educational endpoints. Other course projects (an
+// orchestration module, a grading script) import it instead of decoding the
+// JSON by hand:
+//
+//	om := client.New("http://om-module:8080", 0)
+//	topo, err := om.Topology(ctx)
+//
+// The types mirror the JSON the module serves; fields the module leaves
+// out (a disabled subsystem, a teaching aid turned off) stay zero.
+//
+// Tooling in other languages generates its client from the OpenAPI
+// description the module serves on /api/openapi.json (api/openapi.json in
+// the source tree).
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a request when New is given no timeout.
+const defaultTimeout = 10 * time.Second
+
+// StatusError is returned when the module answers with an unexpected status.
+type StatusError struct {
+	Path string
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("GET %s: unexpected status %d", e.Path, e.Code)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the module, e.g. an endpoint
+// an older module does not have.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusNotFound
+}
+
+// Client talks to one O&M module. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	timeout time.Duration
+	http    *http.Client
+}
+
+// New returns a client for the module at baseURL (e.g.
+// "http://localhost:8080"). timeout bounds each request; 0 means 10s.
+func New(baseURL string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		timeout: timeout,
+		http:    &http.Client{},
+	}
+}
+
+// URL returns the base URL the client was created with.
+func (c *Client) URL() string { return c.baseURL }
+
+// Education selects the teaching aids of the educational endpoints for one
+// request. The zero value keeps the module's EDUCATIONAL_FEATURES.
+type Education struct {
+	// Level is a preset: "intro", "advanced", "all" or "none".
+	Level string
+	// Notes, Hints, Spec and Flows override single toggles of the preset.
+	Notes, Hints, Spec, Flows *bool
+}
+
+func (e Education) query(q url.Values) {
+	if e.Level != "" {
+		q.Set("level", e.Level)
+	}
+	for name, v := range map[string]*bool{"notes": e.Notes, "hints": e.Hints, "spec": e.Spec, "flows": e.Flows} {
+		if v != nil {
+			q.Set(name, strconv.FormatBool(*v))
+		}
+	}
+}
+
+// Ping checks GET /ping.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.get(ctx, "/ping", nil, nil)
+}
+
+// Health returns GET /api/health: every component up, degraded (with the
+// response-time and success-rate SLOs it misses) or down, and the testbed
+// rollup. A module without the health rollup answers 503.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var out Health
+	if err := c.get(ctx, "/api/health", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Topology returns GET /topology: every testbed container and Compose
+// service with its state, health and owner.
+func (c *Client) Topology(ctx context.Context) (*Topology, error) {
+	var out Topology
+	if err := c.get(ctx, "/topology", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Status returns GET /status: whether the module started with all its
+// dependencies or with subsystems disabled.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var out Status
+	if err := c.get(ctx, "/status", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CaptureStatus returns GET /capture/status.
+func (c *Client) CaptureStatus(ctx context.Context) (*CaptureStatus, error) {
+	var out CaptureStatus
+	if err := c.get(ctx, "/capture/status", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Exporters returns GET /api/exporters: the cAdvisor and node_exporter
+// containers the collector detected.
+func (c *Client) Exporters(ctx context.Context) (*Exporters, error) {
+	var out Exporters
+	if err := c.get(ctx, "/api/exporters", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ErrorBudget returns GET /api/logs/error-budget.
+func (c *Client) ErrorBudget(ctx context.Context) (*ErrorBudget, error) {
+	var out ErrorBudget
+	if err := c.get(ctx, "/api/logs/error-budget", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LogSampling returns GET /api/logs/sampling: the log pipeline rate limits
+// and the latest summaries of the lines they dropped.
+func (c *Client) LogSampling(ctx context.Context) (*LogSampling, error) {
+	var out LogSampling
+	if err := c.get(ctx, "/api/logs/sampling", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Logging returns GET /logging/status: the Promtail containers, whether
+// they are ready, and the restarts the module made. Action is set while a
+// start, stop, restart or reload requested through the API is under way.
+func (c *Client) Logging(ctx context.Context) (*Logging, error) {
+	var out Logging
+	if err := c.get(ctx, "/logging/status", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// KPI returns GET /api/kpi/{name}: a live KPI such as
+// "attach_success_rate", "active_ues" or "upf_throughput" over window (0
+// means the module's 5m), for generation ("4g", "5g"; empty means the one
+// running). KPI.Value is nil when Prometheus has no data for it.
+func (c *Client) KPI(ctx context.Context, name string, window time.Duration, generation string) (*KPI, error) {
+	q := url.Values{}
+	if window > 0 {
+		q.Set("window", window.String())
+	}
+	if generation != "" {
+		q.Set("generation", generation)
+	}
+	var out KPI
+	if err := c.get(ctx, "/api/kpi/"+url.PathEscape(name), q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Causes returns GET /causes, for one generation ("4g", "5g") or all when
+// generation is empty.
+func (c *Client) Causes(ctx context.Context, generation string, edu Education) (*Causes, error) {
+	q := url.Values{}
+	if generation != "" {
+		q.Set("generation", generation)
+	}
+	edu.query(q)
+	var out Causes
+	if err := c.get(ctx, "/causes", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Milestones returns GET /milestones.
+func (c *Client) Milestones(ctx context.Context, edu Education) (*Milestones, error) {
+	q := url.Values{}
+	edu.query(q)
+	var out Milestones
+	if err := c.get(ctx, "/milestones", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QoS returns GET /qos.
+func (c *Client) QoS(ctx context.Context, edu Education) (*QoS, error) {
+	q := url.Values{}
+	edu.query(q)
+	var out QoS
+	if err := c.get(ctx, "/qos", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// get sends one GET request and decodes a JSON response into out when out
+// is non-nil.
+func (c *Client) get(ctx context.Context, path string, q url.Values, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	target := c.baseURL + path
+	if len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{Path: path, Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("GET %s: decode response: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Parz1val02/OM_module/api"
+	"github.com/Parz1val02/OM_module/client"
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newModule serves the module's own handlers, built from opts.
+func newModule(t *testing.T, opts api.Options) *httptest.Server {
+	t.Helper()
+	opts.Project, opts.Registry = "open5gs", prometheus.NewRegistry()
+	mux := http.NewServeMux()
+	api.New(opts).Register(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// strictGet decodes the response to path into out and fails on any field
+// the client type does not have, so the types cannot fall behind the
+// handlers.
+func strictGet(t *testing.T, srv *httptest.Server, path string, out any) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s: %s", path, resp.Status, body)
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		t.Fatalf("GET %s: %v\n%s", path, err, body)
+	}
+}
+
+func TestTopology(t *testing.T) {
+	snap := collector.NewSnapshot(
+		&collector.ContainerData{
+			Name: "amf", State: "running", Domain: collector.DomainCore, NF: "amf", Generation: "5g",
+			NFKind: collector.KindAMF, NFKindSource: "label",
+			ComposeProject: "open5gs", Service: "amf", Replica: 1, Component: "amf",
+		},
+		&collector.ContainerData{
+			Name: "gnb", State: "running", Domain: collector.DomainRAN, NF: "gnb", Generation: "5g",
+			NFKind: collector.KindGNB, NFKindSource: "image",
+			ComposeProject: "open5gs", Service: "gnb", Replica: 1, Component: "gnb",
+		},
+	)
+	srv := newModule(t, api.Options{Snapshot: snap})
+
+	var strict client.Topology
+	strictGet(t, srv, "/topology", &strict)
+
+	topo, err := client.New(srv.URL, 0).Topology(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topo.Total != 2 || topo.Running != 2 || topo.Status != "ok" {
+		t.Fatalf("total %d, running %d, status %q; want 2, 2, ok", topo.Total, topo.Running, topo.Status)
+	}
+	gnb, ok := topo.Container("gnb")
+	if !ok {
+		t.Fatal("no gnb container")
+	}
+	if gnb.NFKind != "gnb" || gnb.NFKindSource != "image" {
+		t.Errorf("gnb kind %q from %q, want gnb from image", gnb.NFKind, gnb.NFKindSource)
+	}
+	kinds := make(map[string]string)
+	for _, s := range topo.Services {
+		kinds[s.Service] = s.NFKind
+	}
+	if kinds["amf"] != "amf" || kinds["gnb"] != "gnb" {
+		t.Errorf("service kinds %v, want amf and gnb", kinds)
+	}
+	want := client.Link{Source: "gnb", Target: "amf", Interface: "N2"}
+	if len(topo.Links) != 1 || topo.Links[0] != want {
+		t.Errorf("links %+v, want [%+v]", topo.Links, want)
+	}
+}
+
+func TestCaptureStatusPaused(t *testing.T) {
+	snap := collector.NewSnapshot()
+	capMgr := capture.NewManager(nil, snap, "001", "01", "auto")
+	capMgr.SetGate(func() bool { return false })
+	srv := newModule(t, api.Options{Snapshot: snap, Capture: capMgr})
+
+	var strict client.CaptureStatus
+	strictGet(t, srv, "/capture/status", &strict)
+
+	st, err := client.New(srv.URL, 0).CaptureStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Paused || st.Running {
+		t.Fatalf("paused %v, running %v; want a paused capture that is not running", st.Paused, st.Running)
+	}
+}
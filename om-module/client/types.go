@@ -0,0 +1,405 @@
+package client
+
+// --- /topology -----------------------------------------------------------
+
+// Topology is the testbed as the collector last saw it.
+type Topology struct {
+	Timestamp string `json:"timestamp"`
+	Project   string `json:"project"`
+	// Status is "ok", or "degraded" when a container is stopped.
+	Status     string      `json:"status"`
+	Total      int         `json:"total"`
+	Running    int         `json:"running"`
+	Stopped    int         `json:"stopped"`
+	Containers []Container `json:"containers"`
+	Services   []Service   `json:"services"`
+	// Links are the interfaces between the containers, named after their
+	// 3GPP reference points.
+	Links []Link `json:"links"`
+}
+
+// Container is one testbed container.
+type Container struct {
+	Name           string `json:"name"`
+	State          string `json:"state"`
+	Image          string `json:"image"`
+	Domain         string `json:"domain"` // core | ran | ims | observability | infra
+	NF             string `json:"nf"`
+	NFKind         string `json:"nf_kind"`                  // amf | gnb | ue | …
+	NFKindSource   string `json:"nf_kind_source,omitempty"` // label | component_name | service | image
+	Generation     string `json:"generation"`
+	Project        string `json:"project"`
+	ComposeProject string `json:"compose_project"`
+	Service        string `json:"service"`
+	Replica        int    `json:"replica"`
+	Component      string `json:"component"`
+	Owner          string `json:"owner,omitempty"`
+	Contact        string `json:"contact,omitempty"`
+	Description    string `json:"description,omitempty"`
+	// Health is 1 running, 0 running but unhealthy, -1 stopped.
+	Health float64 `json:"health_status"`
+}
+
+// Service is one Compose service and its replicas.
+type Service struct {
+	ComposeProject string   `json:"compose_project"`
+	Service        string   `json:"service"`
+	Domain         string   `json:"domain"`
+	NF             string   `json:"nf"`
+	NFKind         string   `json:"nf_kind"`
+	Generation     string   `json:"generation"`
+	Owner          string   `json:"owner,omitempty"`
+	Contact        string   `json:"contact,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	Replicas       int      `json:"replicas"`
+	Running        int      `json:"running"`
+	Containers     []string `json:"containers"`
+}
+
+// Link is an interface between two containers, such as N2 from a gNB to
+// an AMF.
+type Link struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Interface string `json:"interface"`
+}
+
+// Container returns the container called name.
+func (t *Topology) Container(name string) (Container, bool) {
+	for _, c := range t.Containers {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Container{}, false
+}
+
+// --- /status -------------------------------------------------------------
+
+// Status is the startup state of the module.
+type Status struct {
+	// State is "ready" when every dependency was ready (or skipped) at
+	// startup and "partial" when subsystems started disabled.
+	State        string       `json:"state"`
+	Disabled     []string     `json:"disabled_subsystems"`
+	Dependencies []Dependency `json:"dependencies"`
+	// FeatureFlags is the current state of the flags, which may have
+	// changed since startup.
+	FeatureFlags []FeatureFlag `json:"feature_flags"`
+}
+
+// Dependency is the startup state of Docker, Loki, Prometheus or Grafana.
+type Dependency struct {
+	Name          string   `json:"name"`
+	Target        string   `json:"target"`
+	State         string   `json:"state"`
+	Subsystems    []string `json:"subsystems"`
+	WaitedSeconds float64  `json:"waited_seconds"`
+	// Late is set when the dependency became ready after the deadline; its
+	// subsystems stay off until the module is restarted.
+	Late  bool   `json:"late,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// --- /api/health ---------------------------------------------------------
+
+// Health is the health rollup of the testbed.
+type Health struct {
+	// Status is "down" when a core NF is down, "degraded" when a component
+	// is degraded or one outside the core is down, "up" otherwise.
+	Status          string            `json:"status"`
+	Up              int               `json:"up"`
+	Degraded        int               `json:"degraded"`
+	Down            int               `json:"down"`
+	ResponseTimeSLO string            `json:"response_time_slo"`
+	SuccessRateSLO  float64           `json:"success_rate_slo"`
+	Window          string            `json:"window"`
+	UpdatedAt       string            `json:"updated_at,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Components      []HealthComponent `json:"components"` // worst first
+}
+
+// HealthComponent is the health of one container. The SBI and scrape
+// fields are nil when there is no data for them.
+type HealthComponent struct {
+	Container       string   `json:"container"`
+	Component       string   `json:"component"`
+	Domain          string   `json:"domain"`
+	NF              string   `json:"nf"`
+	Generation      string   `json:"generation"`
+	State           string   `json:"state"`
+	Status          string   `json:"status"`  // up | degraded | down
+	Reasons         []string `json:"reasons"` // why it is not up
+	ResponseP95Ms   *float64 `json:"sbi_response_p95_ms,omitempty"`
+	SuccessRate     *float64 `json:"sbi_success_rate,omitempty"`
+	ScrapeUp        *bool    `json:"scrape_up,omitempty"`
+	StatsAgeSeconds *float64 `json:"stats_age_seconds,omitempty"`
+}
+
+// FeatureFlag is a runtime feature flag.
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	// Source is what decided Enabled: default, config, rollout or api.
+	Source string `json:"source"`
+	// Setting is the FEATURE_FLAGS value of the flag: on, off or a
+	// percentage; "" when not configured.
+	Setting string `json:"setting,omitempty"`
+	// Available is false when the subsystem of the feature did not start,
+	// in which case the flag changes nothing.
+	Available bool   `json:"available"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// --- /capture/status -----------------------------------------------------
+
+// CaptureStatus is the state of the packet capture.
+type CaptureStatus struct {
+	Running       bool    `json:"running"`
+	Interface     string  `json:"interface"`
+	Generation    string  `json:"generation"`
+	PacketsTotal  uint64  `json:"packets_total"`
+	Packets4G     uint64  `json:"packets_4g"`
+	Packets5G     uint64  `json:"packets_5g"`
+	RestartCount  uint64  `json:"restart_count"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	ActiveProcs   int     `json:"active_procedures"`
+	// Paused is true while the capture feature flag is off.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// --- /api/exporters ------------------------------------------------------
+
+// Exporters are the standard exporters the collector detected.
+type Exporters struct {
+	// ExternalContainerStats is true while cAdvisor provides the container
+	// resource metrics instead of the module.
+	ExternalContainerStats bool       `json:"external_container_stats"`
+	Exporters              []Exporter `json:"exporters"`
+	ScrapeConfig           string     `json:"scrape_config,omitempty"`
+}
+
+// Exporter is a cAdvisor or node_exporter container.
+type Exporter struct {
+	Kind      string   `json:"kind"` // cadvisor | node-exporter
+	Container string   `json:"container"`
+	Image     string   `json:"image"`
+	State     string   `json:"state"`
+	Port      int      `json:"port"`
+	Replaces  []string `json:"replaces"` // module metrics not exported while it runs
+}
+
+// --- /api/kpi/{name} -----------------------------------------------------
+
+// KPI is one live KPI evaluated by Prometheus.
+type KPI struct {
+	Name       string             `json:"name"`
+	Generation string             `json:"generation"`
+	Window     string             `json:"window"`
+	Unit       string             `json:"unit"` // ratio, seconds, count, bytes/s
+	Time       string             `json:"time"`
+	Value      *float64           `json:"value"`
+	Values     map[string]float64 `json:"values,omitempty"` // per NF, for upf_throughput
+	Query      string             `json:"query"`            // the PromQL behind it
+}
+
+// --- /api/logs/error-budget ----------------------------------------------
+
+// ErrorBudget are the log error budgets per NF.
+type ErrorBudget struct {
+	Enabled   bool     `json:"enabled"`
+	Per1000   float64  `json:"budget_per_1000"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Budgets   []Budget `json:"budgets"`
+}
+
+// Budget is the error budget of one NF.
+type Budget struct {
+	Generation string         `json:"generation"`
+	NF         string         `json:"nf"`
+	Windows    []BudgetWindow `json:"windows"`
+	// Remaining is the share of the 1h budget left, from 1 (no errors) to
+	// 0 (exhausted).
+	Remaining float64 `json:"remaining"`
+}
+
+// BudgetWindow are the line counts of one NF over one window.
+type BudgetWindow struct {
+	Window        string  `json:"window"`
+	Lines         float64 `json:"lines"`
+	Warnings      float64 `json:"warnings"`
+	Errors        float64 `json:"errors"`
+	ErrorsPer1000 float64 `json:"errors_per_1000"`
+	// WarnErrorRatio is warnings per error; nil without errors.
+	WarnErrorRatio *float64 `json:"warn_error_ratio,omitempty"`
+	BurnRate       float64  `json:"burn_rate"`
+}
+
+// --- /api/logs/sampling --------------------------------------------------
+
+// LogSampling are the log pipeline rate limits and what they dropped.
+type LogSampling struct {
+	Enabled   bool           `json:"enabled"`
+	Rules     []SamplingRule `json:"rules"`
+	Interval  string         `json:"interval"`
+	UpdatedAt string         `json:"updated_at,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Recent    []Suppression  `json:"recent"` // newest first
+}
+
+// SamplingRule is the limit applied per NF to a group of levels.
+type SamplingRule struct {
+	Name   string   `json:"name"`
+	Levels []string `json:"levels"`
+	Rate   float64  `json:"rate"` // lines per second
+	Burst  float64  `json:"burst"`
+}
+
+// Suppression is one "suppressed N lines" summary.
+type Suppression struct {
+	Time       string  `json:"time"`
+	Generation string  `json:"generation"`
+	NF         string  `json:"nf"`
+	Level      string  `json:"level"`
+	Lines      float64 `json:"lines"`
+	Since      string  `json:"since"`
+	Rule       string  `json:"rule,omitempty"`
+	Message    string  `json:"message"`
+}
+
+// --- /logging/status ----------------------------------------------------
+
+// Logging is the state of the Promtail containers the module manages.
+type Logging struct {
+	Enabled   bool   `json:"enabled"`
+	ConfigDir string `json:"config_dir,omitempty"`
+	// ConfigHash is the hash of the configuration files the containers
+	// were last (re)started with, as far as the module knows.
+	ConfigHash      string         `json:"config_hash,omitempty"`
+	ConfigChangedAt string         `json:"config_changed_at,omitempty"`
+	Interval        string         `json:"interval"`
+	UpdatedAt       string         `json:"updated_at,omitempty"`
+	Restarting      bool           `json:"restarting"`
+	Error           string         `json:"error,omitempty"`
+	Agents          []LoggingAgent `json:"agents"`
+	// Action is the requested action queued or running, if any.
+	Action string `json:"action,omitempty"`
+	// Stopped is set while the containers are stopped on request.
+	Stopped bool `json:"stopped"`
+}
+
+// LoggingAgent is the last check of one Promtail container.
+type LoggingAgent struct {
+	Container string `json:"container"`
+	State     string `json:"state"`
+	// Health is the Docker healthcheck status; empty without one.
+	Health     string `json:"health,omitempty"`
+	Ready      bool   `json:"ready"`
+	ReadyError string `json:"ready_error,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	// DockerRestarts counts the restarts done by the restart policy;
+	// Restarts those done by the module.
+	DockerRestarts int             `json:"docker_restarts"`
+	Restarts       uint64          `json:"restarts"`
+	LastRestart    *LoggingRestart `json:"last_restart,omitempty"`
+	CheckedAt      string          `json:"checked_at"`
+}
+
+// LoggingRestart is a restart of a Promtail container by the module.
+type LoggingRestart struct {
+	Time            string  `json:"time"`
+	Reason          string  `json:"reason"`
+	Result          string  `json:"result"`
+	DurationSeconds float64 `json:"duration_seconds"` // until ready, or until given up
+	Error           string  `json:"error,omitempty"`
+}
+
+// --- /causes -------------------------------------------------------------
+
+// Causes are the NAS and NGAP/S1AP causes seen in the capture.
+type Causes struct {
+	Enabled bool    `json:"enabled"`
+	Causes  []Cause `json:"causes"`
+}
+
+// Cause is one cause value and how often it was seen. Meaning, Hint and
+// Spec are empty when the matching teaching aid is off.
+type Cause struct {
+	Generation string `json:"generation"`
+	Layer      string `json:"layer"`
+	Message    string `json:"message"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Meaning    string `json:"meaning"`
+	Hint       string `json:"hint"`
+	Spec       string `json:"spec"`
+	Count      uint64 `json:"count"`
+	LastSeen   string `json:"last_seen"`
+	LastIMSI   string `json:"last_imsi,omitempty"`
+}
+
+// --- /milestones ---------------------------------------------------------
+
+// Milestones are the lab milestones of the current session.
+type Milestones struct {
+	Enabled        bool        `json:"enabled"`
+	SessionStarted string      `json:"session_started"`
+	Achieved       []Milestone `json:"achieved"`
+	Pending        []Milestone `json:"pending"`
+}
+
+// Milestone is a first-time lab event. AchievedAt is empty while pending.
+type Milestone struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Generation  string `json:"generation"`
+	AchievedAt  string `json:"achieved_at"`
+	IMSI        string `json:"imsi,omitempty"`
+	SrcNF       string `json:"src_nf"`
+	DstNF       string `json:"dst_nf"`
+}
+
+// --- /qos ----------------------------------------------------------------
+
+// QoS is the per-UE QoS flow (5G) and EPS bearer (4G) table.
+type QoS struct {
+	Enabled bool     `json:"enabled"`
+	Spec    []string `json:"spec,omitempty"`
+	Flows   []Flow   `json:"flows"`
+}
+
+// Flow is a 5G QoS flow or a 4G EPS bearer.
+type Flow struct {
+	IMSI       string `json:"imsi"`
+	Generation string `json:"generation"`
+
+	// 5G: PDU session and QoS flow.
+	PDUSessionID int `json:"pdu_session_id,omitempty"`
+	QFI          int `json:"qfi,omitempty"`
+	FiveQI       int `json:"five_qi,omitempty"`
+
+	// 4G: EPS bearer. LinkedEBI is the default bearer of the PDN connection.
+	EBI        int    `json:"ebi,omitempty"`
+	LinkedEBI  int    `json:"linked_ebi,omitempty"`
+	QCI        int    `json:"qci,omitempty"`
+	BearerType string `json:"bearer_type,omitempty"` // "default" or "dedicated"
+	APN        string `json:"apn,omitempty"`
+	UEIP       string `json:"ue_ip,omitempty"`
+
+	ARPPriority int      `json:"arp_priority,omitempty"`
+	Class       QoSClass `json:"class"`
+	SetupAt     string   `json:"setup_at"`
+}
+
+// QoSClass are the standardised characteristics of a 5QI or QCI.
+type QoSClass struct {
+	Value               int     `json:"value"`
+	ResourceType        string  `json:"resource_type"`
+	PriorityLevel       float64 `json:"priority_level"`
+	PacketDelayBudgetMs int     `json:"packet_delay_budget_ms"`
+	PacketErrorRate     string  `json:"packet_error_rate"`
+	Examples            string  `json:"examples"`
+}
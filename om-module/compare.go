@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/artifacts"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/output"
+)
+
+// session is one side of a comparison.
+type session struct {
+	Source    string  `json:"source"` // "live", a bundle id or a file path
+	CreatedAt string  `json:"created_at,omitempty"`
+	KPIs      kpi.Set `json:"kpis"`
+}
+
+type comparison struct {
+	Baseline session     `json:"baseline"`
+	Current  session     `json:"current"`
+	Deltas   []kpi.Delta `json:"deltas"`
+}
+
+// runCompare implements `om-module compare`: it computes the session KPIs
+// (attach success rate and latency, rejects, protocol errors, SBI latency)
+// of two recorded sessions, or of the running module and a recorded
+// session, and prints their deltas.
+//
+// A session is "live" (the running module's /metrics), "latest" (the newest
+// bundle in ARTIFACT_STORE), a bundle id, or the path of a bundle.tar.gz
+// downloaded from another host. The exit code is 1 if a session cannot be
+// loaded.
+func runCompare(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	baseline := fs.String("baseline", "", "baseline session: bundle id, \"latest\", bundle.tar.gz path or \"live\"")
+	current := fs.String("current", "live", "current session: bundle id, \"latest\", bundle.tar.gz path or \"live\"")
+	asJSON := fs.Bool("json", false, "print the comparison as JSON")
+	reports := fs.String("reports", output.Dir(cfg.OutputDir, output.Reports), "directory the comparison is saved to as JSON (empty = not saved)")
+	_ = fs.Parse(args)
+
+	if *baseline == "" {
+		fmt.Fprintln(os.Stderr, "usage: om-module compare -baseline <bundle> [-current <bundle|live>] [-json]")
+		return 2
+	}
+
+	ctx := context.Background()
+	var store artifacts.Store
+	openStore := func() (artifacts.Store, error) {
+		if store != nil {
+			return store, nil
+		}
+		if cfg.ArtifactStore == "" {
+			return nil, fmt.Errorf("ARTIFACT_STORE is disabled")
+		}
+		s, err := artifacts.Open(cfg.ArtifactStore, artifacts.S3Options{
+			Endpoint:  cfg.ArtifactS3Endpoint,
+			Region:    cfg.ArtifactS3Region,
+			AccessKey: cfg.ArtifactS3AccessKey,
+			SecretKey: cfg.ArtifactS3SecretKey,
+		})
+		store = s
+		return s, err
+	}
+
+	var c comparison
+	var err error
+	if c.Baseline, err = loadSession(ctx, cfg, *baseline, openStore); err != nil {
+		log.Printf("⚠️  Baseline %s: %v", *baseline, err)
+		return 1
+	}
+	if c.Current, err = loadSession(ctx, cfg, *current, openStore); err != nil {
+		log.Printf("⚠️  Current %s: %v", *current, err)
+		return 1
+	}
+	c.Deltas = kpi.Compare(c.Baseline.KPIs, c.Current.KPIs)
+
+	written := output.NewManifest()
+	defer written.Log("compare")
+	if *reports != "" {
+		path, err := saveComparison(*reports, c)
+		if err != nil {
+			log.Printf("⚠️  Report not saved: %v", err)
+		} else {
+			written.Add(path)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(c)
+		return 0
+	}
+	printComparison(os.Stdout, c)
+	return 0
+}
+
+// loadSession reads the metrics of spec and derives its KPIs.
+func loadSession(ctx context.Context, cfg *config.Config, spec string, openStore func() (artifacts.Store, error)) (session, error) {
+	s := session{Source: spec}
+	var metrics []byte
+
+	switch {
+	case spec == "live":
+		ctx, cancel := context.WithTimeout(ctx, moduleTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:"+cfg.Port+"/metrics", nil)
+		if err != nil {
+			return s, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return s, fmt.Errorf("module not reachable on :%s: %w", cfg.Port, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return s, fmt.Errorf("GET /metrics: %s", resp.Status)
+		}
+		if metrics, err = io.ReadAll(resp.Body); err != nil {
+			return s, err
+		}
+
+	case strings.HasSuffix(spec, ".tar.gz"):
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return s, err
+		}
+		files, err := artifacts.Unpack(data)
+		if err != nil {
+			return s, err
+		}
+		metrics = files["metrics.prom"]
+
+	default:
+		store, err := openStore()
+		if err != nil {
+			return s, err
+		}
+		var m artifacts.Manifest
+		if spec == "latest" {
+			m, err = artifacts.Latest(ctx, store)
+		} else {
+			m, err = artifacts.Load(ctx, store, spec)
+		}
+		if err != nil {
+			return s, err
+		}
+		s.Source, s.CreatedAt = m.ID, m.CreatedAt
+		files, err := artifacts.ReadFiles(ctx, store, m.ID)
+		if err != nil {
+			return s, err
+		}
+		metrics = files["metrics.prom"]
+	}
+
+	if metrics == nil {
+		return s, fmt.Errorf("bundle has no metrics.prom")
+	}
+	set, err := kpi.FromMetrics(metrics)
+	if err != nil {
+		return s, err
+	}
+	s.KPIs = set
+	return s, nil
+}
+
+// saveComparison writes c as compare-<time>.json in dir.
+func saveComparison(dir string, c comparison) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "compare-"+time.Now().UTC().Format("20060102-150405")+".json")
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printComparison(w io.Writer, c comparison) {
+	label := func(s session) string {
+		if s.CreatedAt != "" {
+			return s.Source + " (" + s.CreatedAt + ")"
+		}
+		return s.Source
+	}
+	fmt.Fprintf(w, "Baseline: %s\n", label(c.Baseline))
+	fmt.Fprintf(w, "Current : %s\n\n", label(c.Current))
+	if len(c.Deltas) == 0 {
+		fmt.Fprintln(w, "No KPIs in either session (was packet capture enabled?)")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KPI\tBASELINE\tCURRENT\tCHANGE\t")
+	for _, d := range c.Deltas {
+		change := "—"
+		if d.Change != nil {
+			change = kpi.Format(d.Definition, d.Change)
+			if *d.Change > 0 {
+				change = "+" + change
+			}
+			if d.Percent != nil && d.Unit != "ratio" {
+				change += fmt.Sprintf(" (%+.1f %%)", *d.Percent)
+			}
+		}
+		mark := map[string]string{"better": "✅", "worse": "⚠️", "same": "", "n/a": ""}[d.Verdict]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Name, kpi.Format(d.Definition, d.Baseline),
+			kpi.Format(d.Definition, d.Current), change, mark)
+	}
+	_ = tw.Flush()
+}
@@ -1,9 +1,22 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/output"
+)
 
 // Config holds all runtime configuration for the O&M module.
 type Config struct {
+	// Profile is the profile the settings were read with (see
+	// profile.go), empty for none.
+	// Default: "" (OM_PROFILE, or --profile)
+	Profile string
+
 	// Port the HTTP server listens on (default: 8080)
 	Port string
 
@@ -14,6 +27,41 @@ type Config struct {
 	// containers that belong to the testbed (default: docker_open5gs)
 	ComposeProject string
 
+	// CollectInterval is how often container stats are refreshed.
+	// Default: "15s"
+	CollectInterval time.Duration
+
+	// CollectAdaptive enables adaptive collection: busy containers are
+	// sampled every CollectMinInterval, idle ones back off up to
+	// CollectMaxInterval. CollectInterval is ignored when enabled.
+	// Default: "false" (min "5s", max "60s")
+	CollectAdaptive    bool
+	CollectMinInterval time.Duration
+	CollectMaxInterval time.Duration
+
+	// HTTPMaxConnsPerHost bounds the connections the module keeps to one
+	// host (Prometheus, Loki, Grafana, an NF) across all its subsystems,
+	// which share one HTTP transport; HTTPMaxIdleConnsPerHost of them are
+	// kept alive between requests, until idle for HTTPIdleConnTimeout.
+	// Default: "8" (idle "4", idle timeout "90s")
+	HTTPMaxConnsPerHost     int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// ExporterDetectionEnabled makes discovery look for cAdvisor and
+	// node_exporter containers in the project. While cAdvisor runs, the
+	// module stops sampling Docker stats and exporting the container_*
+	// resource metrics that cAdvisor already provides.
+	// Default: "true"
+	ExporterDetectionEnabled bool
+
+	// CAdvisorCompatEnabled exports the container stats a second time under
+	// cAdvisor's metric names and labels on /metrics/cadvisor, so community
+	// cAdvisor dashboards work without running cAdvisor. Nothing is served
+	// there while cAdvisor itself runs.
+	// Default: "false"
+	CAdvisorCompatEnabled bool
+
 	// TempoEndpoint is the OTLP/HTTP base URL for Grafana Tempo.
 	// The tracing package POSTs to <TempoEndpoint>/v1/traces.
 	// Default: "tempo:4318"
@@ -30,6 +78,660 @@ type Config struct {
 	// Default: "auto"
 	CaptureInterface string
 
+	// SBIAnalyzerEnabled turns on the SBI HTTP/2 analyzer, which pairs
+	// captured SBI requests with their responses and summarises paths,
+	// methods and status codes per NF pair. Requires CaptureEnabled.
+	// Default: "false"
+	SBIAnalyzerEnabled bool
+
+	// CauseAnalyticsEnabled turns on counting and explanation of NAS reject
+	// causes and NGAP/S1AP Cause IEs seen in the capture.
+	// Requires CaptureEnabled.
+	// Default: "true"
+	CauseAnalyticsEnabled bool
+
+	// MilestonesEnabled turns on the milestone engine, which watches captured
+	// signalling for first-time events in a lab session (first gNB connected,
+	// first UE registered, …). Requires CaptureEnabled.
+	// Default: "true"
+	MilestonesEnabled bool
+
+	// QoSTrackingEnabled turns on the per-UE QoS flow table (5QI/QFI for 5G,
+	// QCI/EBI for 4G) built from captured NGAP and GTPv2 signalling.
+	// Requires CaptureEnabled.
+	// Default: "true"
+	QoSTrackingEnabled bool
+
+	// NASSecurityEnabled turns on tracking of NAS authentication and
+	// Security Mode procedures (RAND/AUTN presence, authentication result,
+	// selected NEA/NIA or EEA/EIA). Requires CaptureEnabled.
+	// Default: "true"
+	NASSecurityEnabled bool
+
+	// HandoverAnalyticsEnabled turns on tracking of NGAP/S1AP handovers and
+	// Xn/X2 path switches per UE, counted by source and target cell.
+	// Requires CaptureEnabled.
+	// Default: "true"
+	HandoverAnalyticsEnabled bool
+
+	// SessionFlowsEnabled turns on the per-UE signalling flows (NGAP/S1AP
+	// and the GTPv2, PFCP, Diameter and SBI messages of the UE) served as
+	// Mermaid/PlantUML/SVG sequence diagrams. Requires CaptureEnabled.
+	// Default: "true"
+	SessionFlowsEnabled bool
+
+	// IMSEnabled turns on IMS/VoLTE awareness: SIP REGISTER/INVITE flow
+	// tracking from the capture (requires CaptureEnabled) and SIP OPTIONS
+	// health checks of the CSCF containers (om.domain "ims") every
+	// IMSProbeInterval.
+	// Default: "true" (probe interval "30s")
+	IMSEnabled       bool
+	IMSProbeInterval time.Duration
+
+	// RoamingEnabled turns on SEPP awareness for roaming labs: every
+	// RoamingProbeInterval the SBI port of each SEPP container (om.nf
+	// "sepp") is checked with a TCP connection and its N32 port,
+	// SEPPN32Port, with a TLS handshake that tells whether N32 runs over
+	// TLS or in cleartext (/roaming).
+	// Default: "true" (probe interval "30s", N32 port "7778")
+	RoamingEnabled       bool
+	RoamingProbeInterval time.Duration
+	SEPPN32Port          int
+
+	// ExposureEnabled turns on NEF awareness for IoT labs: every
+	// ExposureInterval the log lines of each NEF service (om.nf "nef") are
+	// read from Loki and their northbound API invocations (monitoring
+	// event, NIDD, device triggering, traffic influence, …) and event
+	// exposure subscriptions counted (/exposure). Needs LokiURL.
+	// Default: "true" (interval "30s")
+	ExposureEnabled  bool
+	ExposureInterval time.Duration
+
+	// N6Enabled turns on the checks of the path from each UPF (om.nf
+	// "upf*") to the data network: every N6Interval the module reads IP
+	// forwarding, the MASQUERADE rules and the NAT table inside the UPF
+	// and pings N6Target from the UPF's address, from each UE pool (through
+	// the NAT) and every DN container (om.nf "dn"), waiting N6Timeout for
+	// each answer (/n6).
+	// Default: "true" (interval "30s", target "8.8.8.8", timeout "2s")
+	N6Enabled  bool
+	N6Interval time.Duration
+	N6Target   string
+	N6Timeout  time.Duration
+
+	// PromtailManaged turns on the management of the Promtail containers
+	// (om.nf "promtail"): every PromtailInterval each one is inspected and
+	// asked /ready, and when the files under PromtailConfigDir change they
+	// are restarted one at a time and given PromtailReadyTimeout to answer
+	// /ready again (/logging/status, POST /logging/{start,stop,restart,
+	// reload}). An empty PromtailConfigDir ("off") keeps the checks and the
+	// manual actions.
+	// Default: "true" (interval "30s", ready timeout "60s", config dir
+	// "/mnt/promtail")
+	PromtailManaged      bool
+	PromtailInterval     time.Duration
+	PromtailReadyTimeout time.Duration
+	PromtailConfigDir    string
+
+	// LogAuditEnabled turns on the audit of the Open5GS logger
+	// configurations: every LogAuditInterval each running Open5GS
+	// container is checked for a logger.file on the shared log volume,
+	// mounted in the NFs at LogAuditDir, which is what Promtail reads.
+	// LogAuditFix rewrites the logger section of those that log elsewhere
+	// (or to stderr only) at LogAuditLevel and restarts them, once per
+	// container; POST /api/logs/audit/fix does it on demand.
+	// Default: "true" (interval "5m", dir "/open5gs/install/var/log/open5gs", fix "false", level "info")
+	LogAuditEnabled  bool
+	LogAuditInterval time.Duration
+	LogAuditDir      string
+	LogAuditFix      bool
+	LogAuditLevel    string
+
+	// RANConfigEnabled turns on the check of the RAN configuration against
+	// the core's: every RANConfigInterval the PLMN, TAC, S-NSSAIs and core
+	// address rendered in each running gNB and eNB are compared with the
+	// configuration of the running AMFs and MMEs (GET /api/config/consistency).
+	// Default: "true" (interval "1m")
+	RANConfigEnabled  bool
+	RANConfigInterval time.Duration
+
+	// LogExportEnabled turns on the local export of the Open5GS logs
+	// (internal/logexport): every LogExportInterval the lines appended to
+	// LogExportSourceDir/<generation>/<nf>.log, the files Promtail ships,
+	// are parsed and written as JSONL to LogExportDir/<generation>/<nf>.jsonl,
+	// rotated at LogExportMaxMB with LogExportMaxFiles rotated files kept.
+	// /api/logs/files lists and downloads them.
+	// Default: "false" (dir OutputDir + "/logs", source "/var/log/open5gs",
+	// "16" MB, "5" files, interval "10s")
+	LogExportEnabled   bool
+	LogExportDir       string
+	LogExportSourceDir string
+	LogExportMaxMB     int
+	LogExportMaxFiles  int
+	LogExportInterval  time.Duration
+
+	// TroubleshootEnabled turns on the guided troubleshooting of
+	// /api/troubleshoot (internal/troubleshoot): each symptom runs a
+	// decision tree over the containers, the captured flows and causes,
+	// the milestones, the N6 probe, Prometheus and Loki, and returns the
+	// first failing check with its evidence. The logs, flows and causes
+	// of the last TroubleshootWindow are looked at.
+	// Default: "true" (window "15m")
+	TroubleshootEnabled bool
+	TroubleshootWindow  time.Duration
+
+	// SessionEnabled turns on lab sessions (internal/labsession): an
+	// instructor opens one with a name, a duration (SessionDefaultDuration
+	// when not given) and the groups taking part through /api/sessions or
+	// `om-module session`. While it is open every scraped series carries
+	// SessionLabel=<session ID> in the rendered Prometheus configurations;
+	// when it ends its report is written to SessionDir/<id>.md. With
+	// SessionGate the capture and the anomaly detection only run during a
+	// session.
+	// Default: "true" (dir OutputDir + "/sessions", label "lab_session",
+	// duration "2h", gate "false")
+	SessionEnabled         bool
+	SessionDir             string
+	SessionLabel           string
+	SessionDefaultDuration time.Duration
+	SessionGate            bool
+
+	// EventsEnabled turns on POST /api/events (internal/labevents), where
+	// the lab's scripts report what they did (a UE started, an SDR
+	// attached, a scenario step executed). Events are kept in
+	// EventsDir/events.jsonl, counted in om_lab_events_total, pushed to
+	// Loki as {job="lab-events"} (with LokiURL) and annotated on the
+	// dashboards with the lab-event tag (with GrafanaURL).
+	// Default: "true" (dir OutputDir + "/events")
+	EventsEnabled bool
+	EventsDir     string
+
+	// SimulatedMetricsDir holds the sampled expositions of the Open5GS NFs
+	// (metrics_endpoints/{4g,5g}/*.txt). Every SimulatedMetricsInterval the
+	// metrics endpoint of each NF with a sample is probed, and while the
+	// "simulated" feature flag is on those that do not answer are served
+	// from their sample on /metrics/simulated with
+	// data_source="simulated", and the dashboards show a banner. Empty
+	// ("off") disables the fallback.
+	// Default: "/mnt/metrics_endpoints" (interval "30s")
+	SimulatedMetricsDir      string
+	SimulatedMetricsInterval time.Duration
+
+	// DemoScenario enables demo mode: synthetic signalling and Open5GS log
+	// lines, driven by a scenario script, replace the packet capture so the
+	// observability stack can be shown without RAN hardware. "default" plays
+	// the built-in scenario; any other value is the path of a script. Empty
+	// ("off") disables demo mode.
+	DemoScenario string
+
+	// DemoLogDir receives the synthetic Open5GS logs as <generation>/<nf>.log,
+	// where promtail picks them up. Set to "off" to skip log output.
+	// Default: "/var/log/open5gs"
+	DemoLogDir string
+
+	// LogTimezone is the zone of the Open5GS log time stamps, which carry
+	// no zone nor year: an IANA name, "UTC" or "Local" (the host's, mounted
+	// into the containers). Promtail and Alloy read the same LOG_TIMEZONE.
+	// Default: "Local"
+	LogTimezone string
+
+	// MilestoneWebhookURL, if set, receives a JSON POST for every milestone.
+	MilestoneWebhookURL string
+
+	// GrafanaURL is the base URL of the Grafana HTTP API, used to post
+	// annotations. Set to "off" to disable annotations.
+	// Default: "http://grafana:3000"
+	GrafanaURL string
+
+	// LokiURL is the base URL of the Loki HTTP API, used by the cleanup
+	// command. Set to "off" to disable.
+	// Default: "http://loki:3100"
+	LokiURL string
+
+	// PrometheusURL is the base URL of Prometheus, checked at startup so
+	// the status shows whether the module's metrics are being scraped. Set
+	// to "off" to disable.
+	// Default: "http://prometheus:9090"
+	PrometheusURL string
+
+	// DependencyTimeout bounds how long startup waits for Docker, Loki
+	// (/ready), Prometheus (/-/ready) and Grafana (/api/health). Subsystems
+	// whose dependency is not ready by then start disabled and GET /status
+	// reports a partial start. DependencySkip is a comma-separated list of
+	// dependencies ("docker", "loki", "prometheus", "grafana") not to wait
+	// for; their subsystems start regardless, as before.
+	// Default: "60s" (skip none)
+	DependencyTimeout time.Duration
+	DependencySkip    string
+
+	// GrafanaUser and GrafanaPassword authenticate against the Grafana API.
+	// They default to the admin credentials from .env.
+	GrafanaUser     string
+	GrafanaPassword string
+
+	// GrafanaToken is a Grafana service-account token. When set it is used
+	// instead of GrafanaUser/GrafanaPassword.
+	GrafanaToken string
+
+	// OutputDir is the root of the files the module generates: educational/
+	// (offline lab guide), artifacts/ (local session bundles), dumps/
+	// (runtime state dumps), prometheus/ (rendered Prometheus
+	// configurations) and reports/ (`om-module compare` results).
+	// EducationalOutputDir, DumpDir, PrometheusConfigDir and ArtifactStore
+	// default below it.
+	// Default: "/var/lib/om-module"
+	OutputDir string
+
+	// LeaseFile is the instance lease (internal/lease): a module holding it
+	// renews it every LeaseTTL/3, and a second module sharing the volume
+	// refuses to start while it is held, instead of fighting over the
+	// generated files. LeaseTakeover (or `om-module --takeover`) takes the
+	// lease from the other module, which then stops. "off" disables the
+	// lease.
+	// Default: OutputDir + "/om-module.lease", "30s", "false"
+	LeaseFile     string
+	LeaseTTL      time.Duration
+	LeaseTakeover bool
+
+	// EducationalOutputDir receives an index.html copy of the /educational/
+	// page, refreshed every minute and after topology changes, for offline
+	// viewing. Set to "off" to disable.
+	// Default: OutputDir + "/educational"
+	EducationalOutputDir string
+
+	// DumpDir receives a state-<time>.json runtime state dump — collectors,
+	// topology, health history, log pipeline, goroutines — every time the
+	// module gets SIGUSR1 (`docker kill -s USR1 om-module`). Set to "off"
+	// to ignore the signal.
+	// Default: OutputDir + "/dumps"
+	DumpDir string
+
+	// PrometheusConfigSource is the directory of the Prometheus
+	// configuration variants (prometheus/configs/*.yml). At startup, and
+	// within a minute of an edit, each is rendered into PrometheusConfigDir,
+	// which Prometheus reads its configuration from; after an edit
+	// Prometheus is reloaded. PrometheusConfigDir set to "off" renders
+	// nothing.
+	// PrometheusConfig is the variant Prometheus runs with, the same
+	// PROMETHEUS_CONFIG services.yaml starts it with; /api/targets reads the
+	// intended scrape targets from its rendered copy.
+	// Default: "/mnt/prometheus/configs", OutputDir + "/prometheus", "prometheus.yml"
+	PrometheusConfigSource string
+	PrometheusConfigDir    string
+	PrometheusConfig       string
+
+	// PrometheusExternalLabels are added to the external labels of every
+	// rendered variant, e.g. "lab=redes,bench=g3", so a course-wide
+	// Prometheus that federates or receives the benches tells them apart.
+	// A label already in the variant (monitor) takes the value given here.
+	// Default: ""
+	PrometheusExternalLabels string
+
+	// PrometheusRemoteWriteURL and PrometheusRemoteReadURL are
+	// comma-separated remote_write and remote_read endpoints added to every
+	// rendered variant. PrometheusRemoteFile holds full remote_write and
+	// remote_read blocks in Prometheus syntax (authentication, relabelling,
+	// …) for endpoints that need more than a URL. A missing file, or "off",
+	// adds none.
+	// Default: "", "", "/mnt/om-module/prometheus-remote.yaml"
+	PrometheusRemoteWriteURL string
+	PrometheusRemoteReadURL  string
+	PrometheusRemoteFile     string
+
+	// PrometheusRulesEnabled writes starter recording rules (per-NF KPIs)
+	// and alerting rules into PrometheusConfigDir/rules, loaded by every
+	// rendered variant. Only the rules whose metrics Prometheus has series
+	// for are written; they are checked, and Prometheus reloaded, when that
+	// set changes.
+	// Default: "true"
+	PrometheusRulesEnabled bool
+
+	// CardinalityEnabled turns on the cardinality budgets
+	// (internal/cardinality): every CardinalityInterval the head series per
+	// metric family and the values of their labels are read from
+	// Prometheus. A family from CardinalityWarnRatio of
+	// CardinalitySeriesBudget series, or a label from that ratio of
+	// CardinalityLabelBudget values, is reported; one over budget has a
+	// label limited per CardinalityAction: "drop" removes it from the
+	// family's series, "hash" replaces its values by one of
+	// CardinalityHashBuckets hashes, both through the rendered
+	// configuration variants, and "report" only warns.
+	// CardinalityProtectedLabels (comma-separated) are never limited.
+	// Needs PrometheusURL; limits need PrometheusConfigDir.
+	// Default: "true" (interval "1m", "2000" series, "200" values, ratio
+	// "0.8", action "hash", "32" buckets, protected
+	// "job,instance,container,nf,generation,le,quantile")
+	CardinalityEnabled         bool
+	CardinalityInterval        time.Duration
+	CardinalitySeriesBudget    int
+	CardinalityLabelBudget     int
+	CardinalityWarnRatio       float64
+	CardinalityAction          string
+	CardinalityHashBuckets     int
+	CardinalityProtectedLabels string
+
+	// RegenQuietPeriod is how long the topology must stay unchanged before
+	// generated files are rewritten; changes within it are coalesced into
+	// one regeneration. RegenMaxDelay bounds the wait while the topology
+	// keeps changing (e.g. during compose up). Files whose inputs did not
+	// change are not rewritten.
+	// Default: "10s" (max delay "2m")
+	RegenQuietPeriod time.Duration
+	RegenMaxDelay    time.Duration
+
+	// EducationalFeatures selects the teaching aids in API responses and on
+	// the educational page: a preset ("intro", "advanced", "all", "none") or
+	// a comma-separated list of "notes", "hints", "spec" and "flows".
+	// Requests can override it with ?level= or ?notes=/hints=/spec=/flows=.
+	// Default: "all"
+	EducationalFeatures string
+
+	// EducationalProviders declares the institution's own educational
+	// content, merged into the insight cards, the rendered dashboards and
+	// the /nas/security and /ims walkthroughs: a comma-separated list of
+	// name=kind:arg, e.g. "curso=file:/mnt/om-module/educational-content.json".
+	// Default: "" (none)
+	EducationalProviders string
+
+	// RuntimeStatsEnabled turns on sampling of the module's own goroutines
+	// (per subsystem), heap and open file descriptors every
+	// RuntimeStatsInterval, served at /internal/debug and as om_runtime_*
+	// metrics, with a warning when a count keeps growing.
+	// Default: "true" (interval "30s")
+	RuntimeStatsEnabled  bool
+	RuntimeStatsInterval time.Duration
+
+	// SubsystemMaxRestarts is how many times a subsystem of the module (a
+	// poller, the capture, the HTTP server) that panics or stops on its own
+	// is restarted, SubsystemBackoff the wait before the first restart,
+	// doubled up to SubsystemMaxBackoff. A subsystem out of restarts is
+	// reported failed by /status; the HTTP server stops the module.
+	// Default: "5" ("1s", "1m")
+	SubsystemMaxRestarts int
+	SubsystemBackoff     time.Duration
+	SubsystemMaxBackoff  time.Duration
+
+	// A soak test (POST /api/soak/start, or SoakDuration at startup)
+	// samples every SoakInterval the module's heap, goroutines and file
+	// descriptors, how old each poller's data is, the series of the module
+	// and of Prometheus and the Loki label values, and reports each one
+	// that grew by SoakGrowthThreshold percent or more from the start to
+	// the end of the run in $OUTPUT_DIR/reports/soak-<time>.json.
+	// Default: "0" (start on request), "1m", "20"
+	SoakDuration        time.Duration
+	SoakInterval        time.Duration
+	SoakGrowthThreshold float64
+
+	// OwnersFile maps components to the team responsible for them (owner,
+	// contact, description), shown in /topology, on the educational page and
+	// as the owner label of the container metrics. A missing file means no
+	// owners. Set to "off" to disable.
+	// Default: "/mnt/om-module/owners.json"
+	OwnersFile string
+
+	// MetricNamesFile extends the embedded mapping of raw metric names
+	// (fivegs_amffunction_rm_reginitreq, …) to friendly titles shown in the
+	// glossary and /api/metrics/names; its entries replace embedded ones. A
+	// missing file, or "off", means the embedded mapping only.
+	// Default: "/mnt/om-module/metric-names.yaml"
+	MetricNamesFile string
+
+	// DashboardsDir is the directory of the Grafana dashboard files (the one
+	// Grafana provisions from), inventoried at /api/dashboards. Set to "off"
+	// to disable the inventory.
+	// Default: "/var/lib/grafana/dashboards"
+	DashboardsDir string
+
+	// DashboardRenderDir receives the dashboards as Grafana provisions
+	// them: a copy of DashboardsDir, re-rendered when a file changes. When
+	// the deployment has no logging stack (LokiURL unset or Loki not ready
+	// at startup) the panels that query Loki are replaced by placeholders
+	// and Loki annotations and variables are dropped. Set to "off" to let
+	// Grafana read DashboardsDir directly.
+	// Default: OutputDir + "/dashboards"
+	DashboardRenderDir string
+
+	// DashboardLintReport receives the result of the dashboard query lint:
+	// whenever the dashboard files, the topology or the metrics Prometheus
+	// knows change, every PromQL target is dry-run against Prometheus and
+	// every LogQL target against Loki, and the metrics and labels they
+	// select are looked up. Also served at /api/dashboards/lint. Needs
+	// DashboardsDir. Set to "off" to disable the lint.
+	// Default: OutputDir + "/reports/dashboard-lint.json"
+	DashboardLintReport string
+
+	// DashboardProvisioningDir is the directory Grafana reads dashboard
+	// providers from (provisioning/dashboards under GF_PATHS_PROVISIONING).
+	// The module writes its provider there as om-module.yml and asks
+	// Grafana to reload it; the dashboards stay in DashboardProviderPath.
+	// Set to "off" to manage the provider file by hand.
+	// Default: "/etc/grafana/provisioning/dashboards"
+	DashboardProvisioningDir string
+
+	// DashboardProviderName names the provider in Grafana, and
+	// DashboardProviderPath is the directory it loads dashboards from as
+	// Grafana sees it. In Docker the module and Grafana mount the same
+	// paths; a module run on the host has to be given Grafana's path.
+	// Defaults: DASHBOARD_PROVIDER_NAME "default", DASHBOARD_PROVIDER_PATH
+	// DashboardRenderDir, or DashboardsDir when rendering is off
+	DashboardProviderName string
+	DashboardProviderPath string
+
+	// DashboardFolder and DashboardFolderUID are the Grafana folder the
+	// provisioned dashboards go to, and those uploaded by
+	// POST /api/dashboards/{uid}/reload (created if missing). A folder
+	// without a uid gets one from Grafana and the uploads go to General.
+	// Default: "" (the General folder)
+	DashboardFolder    string
+	DashboardFolderUID string
+
+	// DatasourceProvisioningDir is the directory Grafana reads datasources
+	// from (provisioning/datasources under GF_PATHS_PROVISIONING). The
+	// module writes the Loki datasource there as loki.yml, with the derived
+	// fields that link IMSIs, PFCP sessions, procedures and trace IDs of
+	// log lines to Tempo and Loki. LokiDatasourceURL is Loki as Grafana
+	// sees it. Set the directory to "off" to manage loki.yml by hand.
+	// Defaults: "/etc/grafana/provisioning/datasources", "http://loki:3100"
+	DatasourceProvisioningDir string
+	LokiDatasourceURL         string
+
+	// ProjectDir is the lab repository as mounted in the module: compose
+	// files, .env, the NF configurations and the observability configs.
+	// `om-module snapshot -baseline` records a checksum of its files, of
+	// the container images, the generated files and the subscriber
+	// database in BaselineFile; `om-module verify` reports what changed
+	// since.
+	// Default: "/mnt/project", OutputDir + "/baselines/baseline.json"
+	ProjectDir   string
+	BaselineFile string
+
+	// SyntheticTestEnabled turns on the synthetic subscriber test: a
+	// temporary subscriber is inserted in SyntheticMongoContainer, a second
+	// nr-ue attaches with it from SyntheticUEContainer for
+	// SyntheticAttachWindow, and the module checks the NAS result, the
+	// capture and Loki before deleting it. Runs on POST /synthetic/run and,
+	// when SyntheticInterval is set, periodically. SyntheticIMSI defaults to
+	// MCC+MNC followed by nines.
+	// Default: "false" (UE "nr_ue", mongo "mongo", window "20s", interval
+	// unset = on demand only)
+	SyntheticTestEnabled    bool
+	SyntheticUEContainer    string
+	SyntheticUEConfig       string
+	SyntheticMongoContainer string
+	SyntheticIMSI           string
+	SyntheticAttachWindow   time.Duration
+	SyntheticInterval       time.Duration
+
+	// SubscriberWatchEnabled turns on the subscriber database watch: every
+	// SubscriberWatchInterval the subscribers in SubscriberMongoContainer
+	// are read with mongosh and compared with the previous check. Deleting,
+	// inserting or re-keying SubscriberDriftThreshold or more subscribers
+	// between two checks, a duplicate IMSI or a malformed IMSI or key is a
+	// drift event: a log line, a Grafana annotation and the
+	// om_subscribers_* metrics the alert rules watch.
+	// Default: "true" (mongo "mongo", interval "1m", threshold "5")
+	SubscriberWatchEnabled   bool
+	SubscriberMongoContainer string
+	SubscriberWatchInterval  time.Duration
+	SubscriberDriftThreshold int
+
+	// InsightsEnabled turns on the learning cards of /educational/insights:
+	// every InsightsInterval the incident review signals (auth and
+	// registration failures, connected gNBs/eNBs, PFCP peers, CPU, memory)
+	// over the last InsightsWindow are compared with the window before,
+	// and each new anomaly gets a card explaining it and a Grafana
+	// annotation. Needs PrometheusURL.
+	// Default: "true" (interval "1m", window "5m")
+	InsightsEnabled  bool
+	InsightsInterval time.Duration
+	InsightsWindow   time.Duration
+
+	// FeatureFlags switches features on and off per lab without a rebuild:
+	// comma-separated name=on|off|N% ("capture=off,insights=25%"). A
+	// percentage turns the feature on in that share of the labs, decided
+	// by FeatureFlagsLab (the compose project when empty), which should be
+	// unique to the lab for the rollout to spread. POST /api/flags/{name}
+	// overrides a flag until restart. See featureflags.Features.
+	// Default: "" (every flag at its default)
+	FeatureFlags    string
+	FeatureFlagsLab string
+
+	// HealthSLOEnabled turns on the degraded state of the health rollup
+	// (om_health_status, /api/health). A container that is not down is
+	// degraded when, over HealthSLOWindow, its SBI responses are slower
+	// than HealthSLOResponseTime at the 95th percentile or succeed less
+	// often than HealthSLOSuccessRate (0–1), or when its metrics are stale.
+	// The SBI and scrape signals are read from Prometheus every
+	// HealthSLOInterval; without PrometheusURL only the container state
+	// and the resource stats age count. Disabled, the rollup follows the
+	// container state only (up, down, or degraded while restarting).
+	// Default: "true" (interval "30s", response time "250ms", success rate
+	// "0.95", window "5m")
+	HealthSLOEnabled      bool
+	HealthSLOInterval     time.Duration
+	HealthSLOResponseTime time.Duration
+	HealthSLOSuccessRate  float64
+	HealthSLOWindow       time.Duration
+
+	// HealthChecksFile overrides and adds to the active health checks of
+	// the components (internal/health): every running container with a
+	// metrics endpoint gets an HTTP check of it, and the file replaces,
+	// drops or adds http, tcp and exec checks per container, om.component
+	// or om.nf, so custom UEs, SDR drivers or web tools are probed right.
+	// A failing check degrades the component. Checks run every
+	// HealthCheckInterval with HealthCheckTimeout unless they (or the
+	// file's defaults) say otherwise. A missing file leaves the generated
+	// checks; "off" disables the checks.
+	// Default: "/mnt/om-module/health-checks.yaml" (interval "30s", timeout "3s")
+	HealthChecksFile    string
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+
+	// ErrorBudgetEnabled turns on log error budgets: every
+	// ErrorBudgetInterval the Open5GS lines in Loki are counted per NF and
+	// level over 5m and 1h, and each NF is allowed ErrorBudgetPer1000 error
+	// lines per 1000 lines. Needs LokiURL.
+	// Default: "true" (interval "1m", budget "5")
+	ErrorBudgetEnabled  bool
+	ErrorBudgetInterval time.Duration
+	ErrorBudgetPer1000  float64
+
+	// SLOFile holds the lab's service level objectives (internal/slo): KPI
+	// targets such as attach success ≥ 99 %, evaluated from Prometheus every
+	// SLOInterval into om_slo_* metrics, burn rate alerts and the generated
+	// SLO overview dashboard. Needs PrometheusURL; "off" disables them, as
+	// does a missing file.
+	// Default: "/mnt/om-module/slo.yaml" (interval "1m")
+	SLOFile     string
+	SLOInterval time.Duration
+
+	// RedactionFile holds the redaction rules (internal/redact): regular
+	// expressions and field names whose values are replaced in the upstream
+	// log lines the module hands out — incident reviews, /exposure and the
+	// module log of debug bundles. With RedactionDryRun the lines are left
+	// as they are and /api/logs/redaction shows what would be replaced.
+	// "off" disables redaction, as does a missing file.
+	// Default: "/mnt/om-module/redaction.yaml" (dry run "false")
+	RedactionFile   string
+	RedactionDryRun bool
+
+	// MetricBufferEnabled turns on the write-ahead metric buffer
+	// (internal/metricbuffer): while Prometheus is down the module scrapes
+	// its last known targets every MetricBufferInterval into segment files
+	// in MetricBufferDir, at most MetricBufferMaxMB, and backfills them
+	// through remote write when it is back. Samples older than
+	// MetricBufferMaxAge are dropped; the rendered Prometheus configuration
+	// variants accept out-of-order samples that far back. Needs
+	// PrometheusURL.
+	// Default: "false" (dir OutputDir + "/metric-buffer", "256" MB,
+	// max age "6h", interval "15s")
+	MetricBufferEnabled  bool
+	MetricBufferDir      string
+	MetricBufferMaxMB    int
+	MetricBufferMaxAge   time.Duration
+	MetricBufferInterval time.Duration
+
+	// LogSamplingEnabled turns on the log sampling summaries. Promtail (or
+	// Alloy) rate-limits every NF per level with the LOG_LIMIT_* variables
+	// below, read from the same .env; every LogSamplingInterval the lines
+	// it dropped are read from Prometheus and written back to the NF's Loki
+	// stream as one "suppressed N lines" entry per level. Needs LokiURL and
+	// PrometheusURL.
+	// Default: "true" (interval "1m")
+	LogSamplingEnabled  bool
+	LogSamplingInterval time.Duration
+
+	// LogLimit* are the per-NF rate limits of the log pipeline, in lines per
+	// second with a burst allowance, for error and fatal lines, warnings,
+	// and everything else. The module only reports them; Promtail and Alloy
+	// enforce them.
+	// Defaults: LOG_LIMIT_ERROR_RATE "20", LOG_LIMIT_ERROR_BURST "200",
+	// LOG_LIMIT_WARNING_RATE "20", LOG_LIMIT_WARNING_BURST "200",
+	// LOG_LIMIT_INFO_RATE "100", LOG_LIMIT_INFO_BURST "1000"
+	LogLimitErrorRate    float64
+	LogLimitErrorBurst   float64
+	LogLimitWarningRate  float64
+	LogLimitWarningBurst float64
+	LogLimitInfoRate     float64
+	LogLimitInfoBurst    float64
+
+	// ArtifactStore is where session bundles (topology, analyzer state,
+	// metrics, educational page, dashboards) are archived: a local directory,
+	// or "s3://bucket/prefix" for an S3 or MinIO bucket reached through
+	// ArtifactS3Endpoint with the given keys. Set to "off" to disable.
+	// Bundles are written on POST /api/artifacts and, when
+	// ArtifactInterval is set, periodically and once more at shutdown.
+	// Default: OutputDir + "/artifacts" (region "us-east-1")
+	ArtifactStore       string
+	ArtifactS3Endpoint  string
+	ArtifactS3Region    string
+	ArtifactS3AccessKey string
+	ArtifactS3SecretKey string
+	ArtifactInterval    time.Duration
+
+	// ClusterPeers turns this instance into a classroom aggregator that
+	// polls the O&M modules of other benches. Comma-separated list of
+	// "name=http://host:8080" entries (or bare URLs). Empty disables.
+	ClusterPeers string
+
+	// ClusterPollInterval is how often cluster peers are polled.
+	// Default: "15s"
+	ClusterPollInterval time.Duration
+
+	// Outbound HTTP timeouts, per destination. Each bounds a single request;
+	// every call also stops as soon as the module starts shutting down.
+	// Defaults: GRAFANA_TIMEOUT "10s", LOKI_TIMEOUT "10s",
+	// PROMETHEUS_TIMEOUT "10s", WEBHOOK_TIMEOUT "5s",
+	// CLUSTER_PEER_TIMEOUT "5s", SIP_PROBE_TIMEOUT "2s",
+	// SEPP_PROBE_TIMEOUT "2s"
+	GrafanaTimeout     time.Duration
+	LokiTimeout        time.Duration
+	PrometheusTimeout  time.Duration
+	WebhookTimeout     time.Duration
+	ClusterPeerTimeout time.Duration
+	SIPProbeTimeout    time.Duration
+	SEPPProbeTimeout   time.Duration
+
 	// MCC and MNC are used to reconstruct full 5G IMSI values from the
 	// SUCI MSIN extracted from NGAP Registration Request packets.
 	// These should match the values in .env.
@@ -37,23 +739,295 @@ type Config struct {
 	MNC string
 }
 
-// Load reads configuration from environment variables with sensible defaults.
-func Load() *Config {
-	return &Config{
-		Port:             getEnv("OM_PORT", "8080"),
-		DockerSocket:     getEnv("DOCKER_SOCKET", "/var/run/docker.sock"),
-		ComposeProject:   getEnv("COMPOSE_PROJECT", "om_module"),
-		TempoEndpoint:    getEnv("TEMPO_ENDPOINT", "tempo:4318"),
-		CaptureEnabled:   getEnv("CAPTURE_ENABLED", "true") == "true",
-		CaptureInterface: getEnv("CAPTURE_INTERFACE", "auto"),
-		MCC:              getEnv("MCC", "001"),
-		MNC:              getEnv("MNC", "01"),
+// Load reads the configuration: every setting from overrides, the
+// environment, the profile (none when empty) or its built-in default, in
+// that order. It fails on an unknown profile or override.
+func Load(profile string, overrides map[string]string) (*Config, error) {
+	s := &settings{overrides: overrides, read: make(map[string]bool)}
+	if profile != "" {
+		var ok bool
+		if s.profile, ok = profiles[profile]; !ok {
+			return nil, fmt.Errorf("unknown profile %q (one of %s)", profile, strings.Join(Profiles(), ", "))
+		}
+	}
+
+	outputDir := s.getEnv("OUTPUT_DIR", "/var/lib/om-module")
+	dashboardsDir := disableable(s.getEnv("DASHBOARDS_DIR", "/var/lib/grafana/dashboards"))
+	renderDir := disableable(s.getEnv("DASHBOARD_RENDER_DIR", output.Dir(outputDir, output.Dashboards)))
+	providerPath := renderDir
+	if providerPath == "" {
+		providerPath = dashboardsDir
+	}
+	cfg := &Config{
+		Profile: profile,
+
+		Port:             s.getEnv("OM_PORT", "8080"),
+		DockerSocket:     s.getEnv("DOCKER_SOCKET", "/var/run/docker.sock"),
+		ComposeProject:   s.getEnv("COMPOSE_PROJECT", "om_module"),
+		TempoEndpoint:    s.getEnv("TEMPO_ENDPOINT", "tempo:4318"),
+		CaptureEnabled:   s.getEnv("CAPTURE_ENABLED", "true") == "true",
+		CaptureInterface: s.getEnv("CAPTURE_INTERFACE", "auto"),
+
+		CollectInterval:    s.getDuration("COLLECT_INTERVAL", 15*time.Second),
+		CollectAdaptive:    s.getEnv("COLLECT_ADAPTIVE", "false") == "true",
+		CollectMinInterval: s.getDuration("COLLECT_MIN_INTERVAL", 5*time.Second),
+		CollectMaxInterval: s.getDuration("COLLECT_MAX_INTERVAL", 60*time.Second),
+
+		HTTPMaxConnsPerHost:     s.getInt("HTTP_MAX_CONNS_PER_HOST", 8),
+		HTTPMaxIdleConnsPerHost: s.getInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 4),
+		HTTPIdleConnTimeout:     s.getDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+
+		ExporterDetectionEnabled: s.getEnv("EXPORTER_DETECTION_ENABLED", "true") == "true",
+		CAdvisorCompatEnabled:    s.getEnv("CADVISOR_COMPAT_ENABLED", "false") == "true",
+
+		SBIAnalyzerEnabled:    s.getEnv("SBI_ANALYZER_ENABLED", "false") == "true",
+		CauseAnalyticsEnabled: s.getEnv("CAUSE_ANALYTICS_ENABLED", "true") == "true",
+		MilestonesEnabled:     s.getEnv("MILESTONES_ENABLED", "true") == "true",
+		MilestoneWebhookURL:   s.get("MILESTONE_WEBHOOK_URL"),
+		QoSTrackingEnabled:    s.getEnv("QOS_TRACKING_ENABLED", "true") == "true",
+		NASSecurityEnabled:    s.getEnv("NAS_SECURITY_ENABLED", "true") == "true",
+
+		HandoverAnalyticsEnabled: s.getEnv("HANDOVER_ANALYTICS_ENABLED", "true") == "true",
+		SessionFlowsEnabled:      s.getEnv("SESSION_FLOWS_ENABLED", "true") == "true",
+
+		IMSEnabled:       s.getEnv("IMS_ENABLED", "true") == "true",
+		IMSProbeInterval: s.getDuration("IMS_PROBE_INTERVAL", 30*time.Second),
+
+		RoamingEnabled:       s.getEnv("ROAMING_ENABLED", "true") == "true",
+		RoamingProbeInterval: s.getDuration("ROAMING_PROBE_INTERVAL", 30*time.Second),
+		SEPPN32Port:          s.getInt("SEPP_N32_PORT", 7778),
+
+		ExposureEnabled:  s.getEnv("EXPOSURE_ENABLED", "true") == "true",
+		ExposureInterval: s.getDuration("EXPOSURE_INTERVAL", 30*time.Second),
+
+		N6Enabled:  s.getEnv("N6_ENABLED", "true") == "true",
+		N6Interval: s.getDuration("N6_INTERVAL", 30*time.Second),
+		N6Target:   s.getEnv("N6_TARGET", "8.8.8.8"),
+		N6Timeout:  s.getDuration("N6_TIMEOUT", 2*time.Second),
+
+		PromtailManaged:      s.getEnv("PROMTAIL_MANAGED", "true") == "true",
+		PromtailInterval:     s.getDuration("PROMTAIL_INTERVAL", 30*time.Second),
+		PromtailReadyTimeout: s.getDuration("PROMTAIL_READY_TIMEOUT", 60*time.Second),
+		PromtailConfigDir:    disableable(s.getEnv("PROMTAIL_CONFIG_DIR", "/mnt/promtail")),
+
+		LogAuditEnabled:  s.getEnv("LOG_AUDIT_ENABLED", "true") == "true",
+		LogAuditInterval: s.getDuration("LOG_AUDIT_INTERVAL", 5*time.Minute),
+		LogAuditDir:      s.getEnv("LOG_AUDIT_DIR", "/open5gs/install/var/log/open5gs"),
+		LogAuditFix:      s.getEnv("LOG_AUDIT_FIX", "false") == "true",
+		LogAuditLevel:    s.getEnv("LOG_AUDIT_LEVEL", "info"),
+
+		RANConfigEnabled:  s.getEnv("RAN_CONFIG_CHECK_ENABLED", "true") == "true",
+		RANConfigInterval: s.getDuration("RAN_CONFIG_CHECK_INTERVAL", time.Minute),
+
+		LogExportEnabled:   s.getEnv("LOG_EXPORT_ENABLED", "false") == "true",
+		LogExportDir:       s.getEnv("LOG_EXPORT_DIR", output.Dir(outputDir, output.Logs)),
+		LogExportSourceDir: s.getEnv("LOG_EXPORT_SOURCE_DIR", "/var/log/open5gs"),
+		LogExportMaxMB:     s.getInt("LOG_EXPORT_MAX_MB", 16),
+		LogExportMaxFiles:  s.getInt("LOG_EXPORT_MAX_FILES", 5),
+		LogExportInterval:  s.getDuration("LOG_EXPORT_INTERVAL", 10*time.Second),
+
+		TroubleshootEnabled: s.getEnv("TROUBLESHOOT_ENABLED", "true") == "true",
+		TroubleshootWindow:  s.getDuration("TROUBLESHOOT_WINDOW", 15*time.Minute),
+
+		SessionEnabled:         s.getEnv("SESSION_ENABLED", "true") == "true",
+		SessionDir:             s.getEnv("SESSION_DIR", output.Dir(outputDir, output.Sessions)),
+		SessionLabel:           s.getEnv("SESSION_LABEL", "lab_session"),
+		SessionDefaultDuration: s.getDuration("SESSION_DEFAULT_DURATION", 2*time.Hour),
+		SessionGate:            s.getEnv("SESSION_GATE", "false") == "true",
+
+		EventsEnabled: s.getEnv("EVENTS_ENABLED", "true") == "true",
+		EventsDir:     s.getEnv("EVENTS_DIR", output.Dir(outputDir, output.Events)),
+
+		SimulatedMetricsDir:      disableable(s.getEnv("SIMULATED_METRICS_DIR", "/mnt/metrics_endpoints")),
+		SimulatedMetricsInterval: s.getDuration("SIMULATED_METRICS_INTERVAL", 30*time.Second),
+
+		GrafanaURL:      disableable(s.getEnv("GRAFANA_URL", "http://grafana:3000")),
+		LokiURL:         disableable(s.getEnv("LOKI_URL", "http://loki:3100")),
+		PrometheusURL:   disableable(s.getEnv("PROMETHEUS_URL", "http://prometheus:9090")),
+		GrafanaUser:     s.getEnv("GRAFANA_USERNAME", "admin"),
+		GrafanaPassword: s.getEnv("GRAFANA_PASSWORD", "admin"),
+		GrafanaToken:    s.get("GRAFANA_TOKEN"),
+
+		DependencyTimeout: s.getDuration("DEPENDENCY_TIMEOUT", 60*time.Second),
+		DependencySkip:    s.get("DEPENDENCY_SKIP"),
+
+		OutputDir:            outputDir,
+		LeaseFile:            disableable(s.getEnv("LEASE_FILE", filepath.Join(outputDir, output.LeaseFile))),
+		LeaseTTL:             s.getDuration("LEASE_TTL", 30*time.Second),
+		LeaseTakeover:        s.getEnv("LEASE_TAKEOVER", "false") == "true",
+		EducationalOutputDir: disableable(s.getEnv("EDUCATIONAL_OUTPUT_DIR", output.Dir(outputDir, output.Educational))),
+		DumpDir:              disableable(s.getEnv("DUMP_DIR", output.Dir(outputDir, output.Dumps))),
+		EducationalFeatures:  s.getEnv("EDUCATIONAL_FEATURES", "all"),
+		EducationalProviders: s.get("EDUCATIONAL_PROVIDERS"),
+		RegenQuietPeriod:     s.getDuration("REGEN_QUIET_PERIOD", 10*time.Second),
+		RegenMaxDelay:        s.getDuration("REGEN_MAX_DELAY", 2*time.Minute),
+
+		PrometheusConfigSource:   s.getEnv("PROMETHEUS_CONFIG_SOURCE", "/mnt/prometheus/configs"),
+		PrometheusConfigDir:      disableable(s.getEnv("PROMETHEUS_CONFIG_DIR", output.Dir(outputDir, output.Prometheus))),
+		PrometheusConfig:         s.getEnv("PROMETHEUS_CONFIG", "prometheus.yml"),
+		PrometheusExternalLabels: s.get("PROMETHEUS_EXTERNAL_LABELS"),
+		PrometheusRemoteWriteURL: s.get("PROMETHEUS_REMOTE_WRITE_URL"),
+		PrometheusRemoteReadURL:  s.get("PROMETHEUS_REMOTE_READ_URL"),
+		PrometheusRemoteFile:     disableable(s.getEnv("PROMETHEUS_REMOTE_FILE", "/mnt/om-module/prometheus-remote.yaml")),
+		PrometheusRulesEnabled:   s.getEnv("PROMETHEUS_RULES_ENABLED", "true") == "true",
+
+		CardinalityEnabled:         s.getEnv("CARDINALITY_ENABLED", "true") == "true",
+		CardinalityInterval:        s.getDuration("CARDINALITY_INTERVAL", time.Minute),
+		CardinalitySeriesBudget:    s.getInt("CARDINALITY_SERIES_BUDGET", 2000),
+		CardinalityLabelBudget:     s.getInt("CARDINALITY_LABEL_BUDGET", 200),
+		CardinalityWarnRatio:       s.getFloat("CARDINALITY_WARN_RATIO", 0.8),
+		CardinalityAction:          s.getEnv("CARDINALITY_ACTION", "hash"),
+		CardinalityHashBuckets:     s.getInt("CARDINALITY_HASH_BUCKETS", 32),
+		CardinalityProtectedLabels: s.getEnv("CARDINALITY_PROTECTED_LABELS", "job,instance,container,nf,generation,le,quantile"),
+
+		OwnersFile:      disableable(s.getEnv("OWNERS_FILE", "/mnt/om-module/owners.json")),
+		MetricNamesFile: disableable(s.getEnv("METRIC_NAMES_FILE", "/mnt/om-module/metric-names.yaml")),
+
+		DashboardsDir: dashboardsDir,
+
+		DashboardRenderDir:  renderDir,
+		DashboardLintReport: disableable(s.getEnv("DASHBOARD_LINT_REPORT", filepath.Join(output.Dir(outputDir, output.Reports), "dashboard-lint.json"))),
+
+		DashboardProvisioningDir: disableable(s.getEnv("DASHBOARD_PROVISIONING_DIR", "/etc/grafana/provisioning/dashboards")),
+		DashboardProviderName:    s.getEnv("DASHBOARD_PROVIDER_NAME", "default"),
+		DashboardProviderPath:    s.getEnv("DASHBOARD_PROVIDER_PATH", providerPath),
+		DashboardFolder:          s.get("DASHBOARD_FOLDER"),
+		DashboardFolderUID:       s.get("DASHBOARD_FOLDER_UID"),
+
+		DatasourceProvisioningDir: disableable(s.getEnv("DATASOURCE_PROVISIONING_DIR", "/etc/grafana/provisioning/datasources")),
+		LokiDatasourceURL:         s.getEnv("LOKI_DATASOURCE_URL", "http://loki:3100"),
+
+		ProjectDir:   s.getEnv("PROJECT_DIR", "/mnt/project"),
+		BaselineFile: s.getEnv("BASELINE_FILE", filepath.Join(output.Dir(outputDir, output.Baselines), "baseline.json")),
+
+		RuntimeStatsEnabled:  s.getEnv("RUNTIME_STATS_ENABLED", "true") == "true",
+		RuntimeStatsInterval: s.getDuration("RUNTIME_STATS_INTERVAL", 30*time.Second),
+
+		SubsystemMaxRestarts: s.getInt("SUBSYSTEM_MAX_RESTARTS", 5),
+		SubsystemBackoff:     s.getDuration("SUBSYSTEM_BACKOFF", time.Second),
+		SubsystemMaxBackoff:  s.getDuration("SUBSYSTEM_MAX_BACKOFF", time.Minute),
+
+		SoakDuration:        s.getDuration("SOAK_DURATION", 0),
+		SoakInterval:        s.getDuration("SOAK_INTERVAL", time.Minute),
+		SoakGrowthThreshold: s.getFloat("SOAK_GROWTH_THRESHOLD", 20),
+
+		SyntheticTestEnabled:    s.getEnv("SYNTHETIC_TEST_ENABLED", "false") == "true",
+		SyntheticUEContainer:    s.getEnv("SYNTHETIC_UE_CONTAINER", "nr_ue"),
+		SyntheticUEConfig:       s.getEnv("SYNTHETIC_UE_CONFIG", "/UERANSIM/config/ueransim-ue.yaml"),
+		SyntheticMongoContainer: s.getEnv("SYNTHETIC_MONGO_CONTAINER", "mongo"),
+		SyntheticIMSI:           s.get("SYNTHETIC_IMSI"),
+		SyntheticAttachWindow:   s.getDuration("SYNTHETIC_ATTACH_WINDOW", 20*time.Second),
+		SyntheticInterval:       s.getDuration("SYNTHETIC_INTERVAL", 0),
+
+		SubscriberWatchEnabled:   s.getEnv("SUBSCRIBER_WATCH_ENABLED", "true") == "true",
+		SubscriberMongoContainer: s.getEnv("SUBSCRIBER_MONGO_CONTAINER", "mongo"),
+		SubscriberWatchInterval:  s.getDuration("SUBSCRIBER_WATCH_INTERVAL", time.Minute),
+		SubscriberDriftThreshold: s.getInt("SUBSCRIBER_DRIFT_THRESHOLD", 5),
+
+		InsightsEnabled:  s.getEnv("INSIGHTS_ENABLED", "true") == "true",
+		InsightsInterval: s.getDuration("INSIGHTS_INTERVAL", time.Minute),
+		InsightsWindow:   s.getDuration("INSIGHTS_WINDOW", 5*time.Minute),
+
+		FeatureFlags:    s.getEnv("FEATURE_FLAGS", ""),
+		FeatureFlagsLab: s.getEnv("FEATURE_FLAGS_LAB", ""),
+
+		HealthSLOEnabled:      s.getEnv("HEALTH_SLO_ENABLED", "true") == "true",
+		HealthSLOInterval:     s.getDuration("HEALTH_SLO_INTERVAL", 30*time.Second),
+		HealthSLOResponseTime: s.getDuration("HEALTH_SLO_RESPONSE_TIME", 250*time.Millisecond),
+		HealthSLOSuccessRate:  s.getFloat("HEALTH_SLO_SUCCESS_RATE", 0.95),
+		HealthSLOWindow:       s.getDuration("HEALTH_SLO_WINDOW", 5*time.Minute),
+
+		HealthChecksFile:    disableable(s.getEnv("HEALTH_CHECKS_FILE", "/mnt/om-module/health-checks.yaml")),
+		HealthCheckInterval: s.getDuration("HEALTH_CHECK_INTERVAL", 30*time.Second),
+		HealthCheckTimeout:  s.getDuration("HEALTH_CHECK_TIMEOUT", 3*time.Second),
+
+		ErrorBudgetEnabled:  s.getEnv("ERROR_BUDGET_ENABLED", "true") == "true",
+		ErrorBudgetInterval: s.getDuration("ERROR_BUDGET_INTERVAL", time.Minute),
+		ErrorBudgetPer1000:  s.getFloat("ERROR_BUDGET_PER_1000", 5),
+
+		SLOFile:     disableable(s.getEnv("SLO_FILE", "/mnt/om-module/slo.yaml")),
+		SLOInterval: s.getDuration("SLO_INTERVAL", time.Minute),
+
+		RedactionFile:   disableable(s.getEnv("REDACTION_FILE", "/mnt/om-module/redaction.yaml")),
+		RedactionDryRun: s.getEnv("REDACTION_DRY_RUN", "false") == "true",
+
+		MetricBufferEnabled:  s.getEnv("METRIC_BUFFER_ENABLED", "false") == "true",
+		MetricBufferDir:      s.getEnv("METRIC_BUFFER_DIR", output.Dir(outputDir, output.MetricBuffer)),
+		MetricBufferMaxMB:    s.getInt("METRIC_BUFFER_MAX_MB", 256),
+		MetricBufferMaxAge:   s.getDuration("METRIC_BUFFER_MAX_AGE", 6*time.Hour),
+		MetricBufferInterval: s.getDuration("METRIC_BUFFER_INTERVAL", 15*time.Second),
+
+		LogSamplingEnabled:   s.getEnv("LOG_SAMPLING_ENABLED", "true") == "true",
+		LogSamplingInterval:  s.getDuration("LOG_SAMPLING_INTERVAL", time.Minute),
+		LogLimitErrorRate:    s.getFloat("LOG_LIMIT_ERROR_RATE", 20),
+		LogLimitErrorBurst:   s.getFloat("LOG_LIMIT_ERROR_BURST", 200),
+		LogLimitWarningRate:  s.getFloat("LOG_LIMIT_WARNING_RATE", 20),
+		LogLimitWarningBurst: s.getFloat("LOG_LIMIT_WARNING_BURST", 200),
+		LogLimitInfoRate:     s.getFloat("LOG_LIMIT_INFO_RATE", 100),
+		LogLimitInfoBurst:    s.getFloat("LOG_LIMIT_INFO_BURST", 1000),
+
+		ArtifactStore:       disableable(s.getEnv("ARTIFACT_STORE", output.Dir(outputDir, output.Artifacts))),
+		ArtifactS3Endpoint:  s.get("ARTIFACT_S3_ENDPOINT"),
+		ArtifactS3Region:    s.getEnv("ARTIFACT_S3_REGION", "us-east-1"),
+		ArtifactS3AccessKey: s.get("ARTIFACT_S3_ACCESS_KEY"),
+		ArtifactS3SecretKey: s.get("ARTIFACT_S3_SECRET_KEY"),
+		ArtifactInterval:    s.getDuration("ARTIFACT_INTERVAL", 0),
+
+		DemoScenario: disableable(s.get("DEMO_SCENARIO")),
+		DemoLogDir:   disableable(s.getEnv("DEMO_LOG_DIR", "/var/log/open5gs")),
+		LogTimezone:  s.getEnv("LOG_TIMEZONE", "Local"),
+
+		ClusterPeers:        s.get("CLUSTER_PEERS"),
+		ClusterPollInterval: s.getDuration("CLUSTER_POLL_INTERVAL", 15*time.Second),
+
+		GrafanaTimeout:     s.getDuration("GRAFANA_TIMEOUT", 10*time.Second),
+		LokiTimeout:        s.getDuration("LOKI_TIMEOUT", 10*time.Second),
+		PrometheusTimeout:  s.getDuration("PROMETHEUS_TIMEOUT", 10*time.Second),
+		WebhookTimeout:     s.getDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+		ClusterPeerTimeout: s.getDuration("CLUSTER_PEER_TIMEOUT", 5*time.Second),
+		SIPProbeTimeout:    s.getDuration("SIP_PROBE_TIMEOUT", 2*time.Second),
+		SEPPProbeTimeout:   s.getDuration("SEPP_PROBE_TIMEOUT", 2*time.Second),
+
+		MCC: s.getEnv("MCC", "001"),
+		MNC: s.getEnv("MNC", "01"),
+	}
+
+	var unknown []string
+	for key := range overrides {
+		if !s.read[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown setting(s) %s", strings.Join(unknown, ", "))
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of c with credentials — and the webhook and
+// remote storage URLs, which often embed a token — replaced, so it can be
+// shared in debug bundles.
+func (c *Config) Redacted() *Config {
+	r := *c
+	for _, v := range []*string{
+		&r.GrafanaPassword, &r.GrafanaToken,
+		&r.ArtifactS3AccessKey, &r.ArtifactS3SecretKey,
+		&r.MilestoneWebhookURL,
+		&r.PrometheusRemoteWriteURL, &r.PrometheusRemoteReadURL,
+	} {
+		if *v != "" {
+			*v = "<redacted>"
+		}
 	}
+	return &r
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// disableable maps the literal "off" to "" so optional endpoints that have a
+// non-empty default can still be switched off from the environment.
+func disableable(v string) string {
+	if v == "off" {
+		return ""
 	}
-	return fallback
+	return v
 }
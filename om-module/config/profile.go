@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A profile bundles the settings for one use of the module, as the
+// environment variables Load reads. A setting is taken from, first to
+// last:
+//
+//  1. a --set KEY=VALUE flag;
+//  2. the environment;
+//  3. the profile (--profile, or OM_PROFILE);
+//  4. the built-in default.
+//
+// Without a profile the built-in defaults apply, as before profiles.
+var profiles = map[string]map[string]string{
+	// dev is the module run from a checkout next to the testbed: short
+	// intervals, the SBI analyzer on, redaction reported but not applied,
+	// and a failing subsystem restarted once only, so failures show.
+	"dev": {
+		"COLLECT_INTERVAL":       "5s",
+		"SBI_ANALYZER_ENABLED":   "true",
+		"LOG_AUDIT_INTERVAL":     "1m",
+		"INSIGHTS_INTERVAL":      "15s",
+		"REGEN_QUIET_PERIOD":     "2s",
+		"REDACTION_DRY_RUN":      "true",
+		"SUBSYSTEM_MAX_RESTARTS": "1",
+		"LEASE_TAKEOVER":         "true",
+	},
+	// classroom is a lab session: collection that backs off on idle
+	// containers, introductory teaching aids, logs redacted (never in dry
+	// run) and exported for later review, and the lab session gate.
+	"classroom": {
+		"COLLECT_ADAPTIVE":     "true",
+		"EDUCATIONAL_FEATURES": "intro",
+		"REDACTION_DRY_RUN":    "false",
+		"LOG_EXPORT_ENABLED":   "true",
+		"SESSION_GATE":         "true",
+	},
+	// demo shows the stack without RAN hardware: the built-in demo
+	// scenario, fast collection and insights, and the lease taken over
+	// from a module left running.
+	"demo": {
+		"DEMO_SCENARIO":     "default",
+		"COLLECT_INTERVAL":  "5s",
+		"INSIGHTS_INTERVAL": "15s",
+		"INSIGHTS_WINDOW":   "2m",
+		"LEASE_TAKEOVER":    "true",
+	},
+	// ci is the module under test in a pipeline: no capture, no teaching
+	// aids, no subsystem that changes the testbed, short waits and its
+	// files in /tmp.
+	"ci": {
+		"OUTPUT_DIR":               "/tmp/om-module",
+		"LEASE_FILE":               "off",
+		"CAPTURE_ENABLED":          "false",
+		"EDUCATIONAL_FEATURES":     "none",
+		"PROMTAIL_MANAGED":         "false",
+		"LOG_AUDIT_ENABLED":        "false",
+		"SUBSCRIBER_WATCH_ENABLED": "false",
+		"DEPENDENCY_TIMEOUT":       "10s",
+		"SUBSYSTEM_MAX_RESTARTS":   "1",
+		"SUBSYSTEM_BACKOFF":        "100ms",
+	},
+}
+
+// Profiles returns the names of the profiles.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseArgs takes the global flags out of args, wherever they are:
+//
+//	--profile NAME       the profile (default: $OM_PROFILE)
+//	--set KEY=VALUE      a setting, over the environment; repeatable
+//
+// The flags may be written with one dash and with "="; "--" ends them.
+// The other arguments are returned as they were, for the subcommands.
+func ParseArgs(args []string) (profile string, overrides map[string]string, rest []string, err error) {
+	profile = os.Getenv("OM_PROFILE")
+	overrides = make(map[string]string)
+	rest = []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != "profile" && name != "set") {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return "", nil, nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name == "profile" {
+			profile = value
+			continue
+		}
+		key, v, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return "", nil, nil, fmt.Errorf("--set %q: want KEY=VALUE", value)
+		}
+		overrides[key] = v
+	}
+	return profile, overrides, rest, nil
+}
+
+// settings looks the configuration up by precedence.
+type settings struct {
+	profile   map[string]string
+	overrides map[string]string
+	// read are the keys Load read, to reject overrides of unknown ones.
+	read map[string]bool
+}
+
+// get returns the value of key, "" when it is set nowhere.
+func (s *settings) get(key string) string {
+	s.read[key] = true
+	if v, ok := s.overrides[key]; ok {
+		return v
+	}
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return s.profile[key]
+}
+
+func (s *settings) getEnv(key, fallback string) string {
+	if v := s.get(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getDuration parses a Go duration ("15s", "1m"); unset or invalid values
+// fall back to fallback.
+func (s *settings) getDuration(key string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(s.get(key))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// getFloat parses a positive number ("5", "0.5"); unset or invalid values
+// fall back to fallback.
+func (s *settings) getFloat(key string, fallback float64) float64 {
+	f, err := strconv.ParseFloat(s.get(key), 64)
+	if err != nil || f <= 0 {
+		return fallback
+	}
+	return f
+}
+
+// getInt parses a positive integer ("7778"); unset or invalid values fall
+// back to fallback.
+func (s *settings) getInt(key string, fallback int) int {
+	n, err := strconv.Atoi(s.get(key))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
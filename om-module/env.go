@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/envsynth"
+)
+
+// envProbeTimeout bounds the Docker calls of the .env suggestion.
+const envProbeTimeout = 10 * time.Second
+
+// runEnv implements `om-module env`: it suggests a .env for the testbed from
+// its defaults and what the Docker socket shows — the subnet of the testbed
+// network (or a free one), the addresses of the running containers — for a
+// checkout that has none:
+//
+//	om-module env                 # writes ../.env.suggested
+//	om-module env -o - | less     # prints it
+//
+// It needs no configuration; without Docker the defaults are suggested. An
+// existing file is never overwritten. The exit code is 1 when nothing was
+// written, 2 on usage errors.
+func runEnv(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	project := fs.String("project", "..", "path of the docker compose project (the repository root)")
+	outPath := fs.String("o", "", `file to write, "-" for stdout (default: <project>/.env.suggested)`)
+	network := fs.String("network", bootstrapNetwork, "Docker network of the testbed")
+	asJSON := fs.Bool("json", false, "print what was detected as JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: om-module env [-project dir] [-o file|-] [-network name] [-json]")
+		return 2
+	}
+
+	dir, err := filepath.Abs(*project)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	path := *outPath
+	if path == "" {
+		path = filepath.Join(dir, ".env.suggested")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), envProbeTimeout)
+	defer cancel()
+	res, err := suggestEnv(ctx, cfg, dir, *network)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(data))
+	} else if path != "-" {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tVALUE\tDEFAULT\tSOURCE")
+		for _, v := range res.Values {
+			source := v.Source
+			if v.Container != "" {
+				source += " (" + v.Container + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Key, v.Value, v.Default, source)
+		}
+		_ = tw.Flush()
+	}
+	for _, w := range res.Warnings {
+		log.Printf("⚠️  %s", w)
+	}
+
+	if path == "-" {
+		_, _ = os.Stdout.Write(res.Env)
+		return 0
+	}
+	if err := writeNew(path, res.Env); err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	log.Printf("✅ Suggested .env written to %s (TEST_NETWORK %s) — review it, then rename it to .env", path, res.Subnet)
+	return 0
+}
+
+// suggestEnv probes the Docker socket of cfg and synthesizes a .env for the
+// compose project in dir. Docker not answering is not an error: the
+// defaults are suggested.
+func suggestEnv(ctx context.Context, cfg *config.Config, dir, network string) (envsynth.Result, error) {
+	addresses, err := envsynth.ComposeAddresses(dir)
+	if err != nil {
+		return envsynth.Result{}, err
+	}
+	if len(addresses) == 0 {
+		return envsynth.Result{}, fmt.Errorf("no compose file with static addresses in %s — is -project the repository root?", dir)
+	}
+
+	probe := envsynth.Probe{Network: network}
+	if err := probeDocker(ctx, cfg.DockerSocket, &probe); err != nil {
+		log.Printf("⚠️  Docker not reachable on %s (%v) — suggesting the defaults", cfg.DockerSocket, err)
+	}
+	return envsynth.Synthesize(envsynth.Defaults(), addresses, probe)
+}
+
+// probeDocker fills in the subnets of the Docker networks and the addresses
+// of the containers on the testbed network.
+func probeDocker(ctx context.Context, socket string, p *envsynth.Probe) error {
+	docker, err := dockerclient.New(socket)
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	if p.Subnets, err = docker.NetworkSubnets(ctx); err != nil {
+		return err
+	}
+	subnets, ok := p.Subnets[p.Network]
+	if !ok {
+		return nil // the core was never started
+	}
+	if len(subnets) > 0 {
+		p.Subnet = subnets[0]
+	}
+	ips, err := docker.GetNetworkContainerIPs(ctx, p.Network)
+	if err != nil {
+		return err
+	}
+	p.IPs = make(map[string]string, len(ips))
+	for ip, name := range ips {
+		p.IPs[name] = ip
+	}
+	return nil
+}
+
+// writeNew writes data to path unless a file is already there.
+func writeNew(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists — remove it or pass -o", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
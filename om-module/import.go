@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/logimport"
+	"github.com/Parz1val02/OM_module/internal/logtime"
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runImport implements `om-module import`: it pushes the Open5GS logs of a
+// past lab run to Loki with their original time stamps and the labels
+// Promtail gives live lines, plus scenario=<name>:
+//
+//	om-module import -scenario auth-failure-2025-03 /archive/2025-03-12/
+//	om-module import -generation 4g -anchor 2025-03-12T18:00:00+01:00 mme.log
+//
+// The scenario defaults to the name of the first path. The exit code is 1
+// when a file could not be imported completely, 2 on usage errors.
+func runImport(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	scenario := fs.String("scenario", "", "value of the scenario label of the imported lines (default: name of the first path)")
+	generation := fs.String("generation", "", "generation (4g or 5g) of logs not under a 4g or 5g directory")
+	anchor := fs.String("anchor", "", "RFC 3339 time no line is younger than (default: modification time of each file)")
+	lokiURL := fs.String("loki", cfg.LokiURL, "Loki to push to")
+	redaction := fs.String("redaction", cfg.RedactionFile, "redaction rules applied to every line (empty = none)")
+	dryRun := fs.Bool("dry-run", false, "parse the files and print what would be pushed")
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: om-module import [-scenario name] [-generation 4g|5g] [-anchor time] [-dry-run] <file|dir>...")
+		return 2
+	}
+	opts := logimport.Options{
+		LokiURL:    *lokiURL,
+		Timeout:    cfg.LokiTimeout,
+		Scenario:   *scenario,
+		Generation: *generation,
+		DryRun:     *dryRun,
+	}
+	if opts.Scenario == "" {
+		opts.Scenario = scenarioName(fs.Arg(0))
+	}
+	if *anchor != "" {
+		t, err := time.Parse(time.RFC3339, *anchor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import: -anchor: %v\n", err)
+			return 2
+		}
+		opts.Anchor = t
+	}
+	loc, err := logtime.Load(cfg.LogTimezone)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: LOG_TIMEZONE: %v\n", err)
+		return 2
+	}
+	opts.Stamps = logtime.New(loc)
+	if *redaction != "" {
+		file, err := redact.Load(*redaction)
+		if err == nil {
+			opts.Redact, err = redact.New(prometheus.NewRegistry(), file, false)
+		}
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("⚠️  No redaction file at %s — lines imported as logged", *redaction)
+		case err != nil:
+			log.Printf("⚠️  Redaction file ignored: %v", err)
+		}
+	}
+
+	im, err := logimport.New(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+		return 2
+	}
+
+	// Ctrl-C stops after the push in progress.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("📥 Importing %s as scenario %q (dry-run=%v)", strings.Join(fs.Args(), ", "), opts.Scenario, *dryRun)
+	sum, err := im.Import(ctx, fs.Args())
+	if err != nil && len(sum.Files) == 0 {
+		log.Printf("⚠️  Import failed: %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(sum, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "FILE\tGEN\tNF\tLINES\tPUSHED\tREJECTED\tFROM\tTO\tERROR")
+		for _, f := range sum.Files {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+				f.Path, f.Generation, f.NF, f.Lines, f.Pushed, f.Rejected, f.From, f.To, f.Error)
+		}
+		_ = tw.Flush()
+	}
+
+	failed := err != nil || sum.Rejected > 0
+	for _, f := range sum.Files {
+		failed = failed || f.Error != ""
+	}
+	switch {
+	case err != nil:
+		log.Printf("⚠️  Import interrupted: %v", err)
+	case *dryRun:
+		log.Printf("✅ Dry run: %d line(s) from %s to %s would be pushed as %s", sum.Pushed, sum.From, sum.To, sum.Selector)
+	case failed:
+		log.Printf("⚠️  Imported %d of %d line(s); see the errors above", sum.Pushed, sum.Lines)
+	default:
+		log.Printf("✅ Imported %d line(s) from %s to %s — query them with %s", sum.Pushed, sum.From, sum.To, sum.Selector)
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+var nonScenario = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// scenarioName derives a scenario name from a path: its base name without
+// the log extensions, or the directory holding the 4g/5g directory.
+func scenarioName(p string) string {
+	p = filepath.Clean(p)
+	base := filepath.Base(p)
+	if base == "4g" || base == "5g" {
+		base = filepath.Base(filepath.Dir(p))
+	}
+	base = strings.TrimSuffix(base, ".gz")
+	base = strings.TrimSuffix(strings.TrimSuffix(base, ".log"), ".jsonl")
+	if name := strings.Trim(nonScenario.ReplaceAllString(base, "-"), "-"); name != "" && name != "." {
+		return name
+	}
+	return "import-" + time.Now().Format("20060102-1504")
+}
@@ -0,0 +1,207 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle layout in the store: <id>/manifest.json next to <id>/bundle.tar.gz.
+const (
+	manifestName = "manifest.json"
+	archiveName  = "bundle.tar.gz"
+)
+
+// idFormat orders bundle ids chronologically as strings.
+const idFormat = "20060102T150405.000Z"
+
+var validID = regexp.MustCompile(`^\d{8}T\d{6}\.\d{3}Z$`)
+
+// File is one entry of a bundle.
+type File struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// Manifest describes a bundle: when and why it was taken, free-form
+// metadata (project, generation, host) and its files.
+type Manifest struct {
+	ID        string            `json:"id"`
+	CreatedAt string            `json:"created_at"`
+	Reason    string            `json:"reason"` // "manual", "scheduled", "shutdown"
+	Metadata  map[string]string `json:"metadata"`
+	Files     []File            `json:"files"`
+	SizeBytes int64             `json:"size_bytes"` // of bundle.tar.gz
+	SHA256    string            `json:"sha256"`     // of bundle.tar.gz
+}
+
+// ArchiveKey returns the store key of the bundle's tar.gz.
+func (m Manifest) ArchiveKey() string { return m.ID + "/" + archiveName }
+
+// Write packs files (name → content) into a new bundle and stores it. The
+// archive is written before the manifest, so a listed bundle is complete.
+func Write(ctx context.Context, store Store, reason string, metadata map[string]string, files map[string][]byte) (Manifest, error) {
+	now := time.Now().UTC()
+	m := Manifest{
+		ID:        now.Format(idFormat),
+		CreatedAt: now.Format(time.RFC3339),
+		Reason:    reason,
+		Metadata:  metadata,
+	}
+	if m.Metadata == nil {
+		m.Metadata = map[string]string{}
+	}
+
+	archive, entries, err := Pack(files, now)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m.Files = entries
+	m.SizeBytes = int64(len(archive))
+	m.SHA256 = sha256Hex(archive)
+
+	if err := store.Put(ctx, m.ArchiveKey(), archive, "application/gzip"); err != nil {
+		return Manifest{}, fmt.Errorf("store bundle: %w", err)
+	}
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := store.Put(ctx, m.ID+"/"+manifestName, body, "application/json"); err != nil {
+		return Manifest{}, fmt.Errorf("store manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Pack writes files (name → content) into a tar.gz, sorted by name and
+// stamped with modTime, and returns it with one entry per file.
+func Pack(files map[string][]byte, modTime time.Time) ([]byte, []File, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]File, 0, len(files))
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		data := files[name]
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, File{Name: name, SizeBytes: int64(len(data)), SHA256: sha256Hex(data)})
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), entries, nil
+}
+
+// List returns the manifests in store, newest first.
+func List(ctx context.Context, store Store) ([]Manifest, error) {
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	out := []Manifest{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		id, name, ok := strings.Cut(keys[i], "/")
+		if !ok || name != manifestName || !validID.MatchString(id) {
+			continue
+		}
+		m, err := Load(ctx, store, id)
+		if err != nil {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// Load returns the manifest of bundle id.
+func Load(ctx context.Context, store Store, id string) (Manifest, error) {
+	if !validID.MatchString(id) {
+		return Manifest{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	data, err := store.Get(ctx, id+"/"+manifestName)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("bundle %s: %w", id, err)
+	}
+	return m, nil
+}
+
+// Latest returns the newest bundle, or ErrNotFound when the store is empty.
+func Latest(ctx context.Context, store Store) (Manifest, error) {
+	list, err := List(ctx, store)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if len(list) == 0 {
+		return Manifest{}, fmt.Errorf("%w: store is empty", ErrNotFound)
+	}
+	return list[0], nil
+}
+
+// ReadFiles unpacks the files of bundle id.
+func ReadFiles(ctx context.Context, store Store, id string) (map[string][]byte, error) {
+	m, err := Load(ctx, store, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(ctx, m.ArchiveKey())
+	if err != nil {
+		return nil, err
+	}
+	return Unpack(data)
+}
+
+// Unpack returns the files of a bundle.tar.gz.
+func Unpack(data []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = b
+	}
+}
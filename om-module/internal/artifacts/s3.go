@@ -0,0 +1,274 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+)
+
+// S3Store keeps artifacts in an S3 bucket, addressed path-style so MinIO
+// works without DNS for bucket subdomains. Requests are signed with AWS
+// Signature Version 4.
+type S3Store struct {
+	endpoint  *url.URL
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3Store returns a store for bucket; keys are stored below prefix.
+func NewS3Store(bucket, prefix string, opts S3Options) (*S3Store, error) {
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return nil, fmt.Errorf("S3 store needs an access key and a secret key")
+	}
+	return &S3Store{
+		endpoint:  u,
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    region,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		client:    httpclient.New("artifacts", 60*time.Second),
+	}, nil
+}
+
+func (s *S3Store) String() string {
+	return fmt.Sprintf("s3://%s/%s (%s)", s.bucket, s.prefix, s.endpoint.Host)
+}
+
+func (s *S3Store) Location(key string) string {
+	return "s3://" + s.bucket + "/" + s.objectKey(key)
+}
+
+func (s *S3Store) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.objectKey(key), nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectKey(key), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	full := s.objectKey(prefix)
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", full)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %w", s, err)
+		}
+		for _, c := range res.Contents {
+			key := c.Key
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			break
+		}
+		token = res.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// do sends a signed request for key (empty for the bucket itself) and
+// returns the response when its status is 2xx. A 404 maps to ErrNotFound.
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", method, u.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sign adds the AWS Signature Version 4 headers to req.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			names = append(names, lk)
+			values[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, n := range names {
+		canonicalHeaders.WriteString(n + ":" + values[n] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by key with RFC 3986 escaping, as
+// both the request URL and the signature require.
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEscape(k)+"="+uriEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath encodes every segment of p with uriEscape. S3 signs the path
+// encoded that way; url.URL.EscapedPath leaves characters such as '+', '='
+// and ':' as they are, so keys holding them would not match the signature.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		segs[i] = uriEscape(seg)
+	}
+	return strings.Join(segs, "/")
+}
+
+func uriEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,142 @@
+// Package artifacts archives what the module produces about a lab session —
+// topology, analyzer state, metrics, the educational page, the Loki label
+// contract and the dashboards it ran with — as versioned bundles in a
+// pluggable store: a local directory by default, or an S3 bucket (AWS or a
+// MinIO server shared by the lab).
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Store.Get for a missing key.
+var ErrNotFound = errors.New("artifact not found")
+
+// Store holds artifacts under slash-separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the keys that start with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Location returns where key is kept: a file path or an s3:// URL.
+	Location(key string) string
+	// String describes the store for logs ("dir /data", "s3://bucket/prefix").
+	String() string
+}
+
+// S3Options are the credentials and endpoint of an S3 store.
+type S3Options struct {
+	Endpoint  string // e.g. "http://minio:9000"; empty means AWS for Region
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// Open returns the store for spec: "s3://bucket/prefix" selects an S3
+// bucket, anything else is a local directory.
+func Open(spec string, s3opts S3Options) (Store, error) {
+	if !strings.HasPrefix(spec, "s3://") {
+		return NewLocalStore(spec)
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("artifact store %q: missing bucket", spec)
+	}
+	return NewS3Store(u.Host, strings.Trim(u.Path, "/"), s3opts)
+}
+
+// LocalStore keeps artifacts as files below a directory.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates dir if needed and returns a store rooted there.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) String() string { return "dir " + s.dir }
+
+func (s *LocalStore) Location(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("invalid artifact key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put writes data atomically, so a concurrent Get never sees a partial file.
+func (s *LocalStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return data, err
+}
+
+func (s *LocalStore) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".artifact-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
@@ -6,6 +6,10 @@ const (
 	Generation5G = "5g"
 )
 
+// sipBPF matches the IMS SIP ports of the Kamailio CSCFs used with
+// docker_open5gs: P-CSCF 5060, I-CSCF 4060, S-CSCF 6060 (UDP and TCP).
+const sipBPF = "port 5060 or port 4060 or port 6060"
+
 // filters holds a pair of tshark filter sets.
 // Two separate tshark processes run in parallel because combining SCTP and
 // UDP in a single BPF filter is unreliable across kernel/container versions.
@@ -14,7 +18,7 @@ type filters struct {
 	SCTPBPF     string
 	SCTPDisplay string
 
-	// UDP process: captures GTPv2-C and/or PFCP
+	// UDP process: captures GTPv2-C and/or PFCP, plus TCP-based SBI and SIP
 	UDPBPF     string
 	UDPDisplay string
 }
@@ -29,8 +33,9 @@ func filtersFor(generation string) filters {
 			SCTPDisplay: "ngap",
 			// PFCP (N4): UDP 8805 — SMF ↔ UPF
 			// SBI HTTP/2 (all N-interfaces): TCP 7777 — NF ↔ NF via SCP
-			UDPBPF:     "udp port 8805 or tcp port 7777",
-			UDPDisplay: "pfcp or http2",
+			// SIP (IMS, VoNR): UDP/TCP 5060/4060/6060 — UE ↔ P-CSCF ↔ I/S-CSCF
+			UDPBPF:     "udp port 8805 or tcp port 7777 or " + sipBPF,
+			UDPDisplay: "pfcp or http2 or sip",
 		}
 	case Generation4G:
 		return filters{
@@ -40,15 +45,16 @@ func filtersFor(generation string) filters {
 			SCTPDisplay: "s1ap or diameter",
 			// GTPv2-C (S11/S5): UDP 2123
 			// PFCP (Sxa/Sxb): UDP 8805
-			UDPBPF:     "udp port 2123 or udp port 8805",
-			UDPDisplay: "gtpv2 or pfcp",
+			// SIP (IMS, VoLTE): UDP/TCP 5060/4060/6060 — UE ↔ P-CSCF ↔ I/S-CSCF
+			UDPBPF:     "udp port 2123 or udp port 8805 or " + sipBPF,
+			UDPDisplay: "gtpv2 or pfcp or sip",
 		}
 	default:
 		return filters{
 			SCTPBPF:     "sctp port 38412 or sctp port 36412 or sctp port 3868 or sctp port 3873 or sctp port 5868",
 			SCTPDisplay: "ngap or s1ap or diameter",
-			UDPBPF:      "udp port 2123 or udp port 8805 or tcp port 7777",
-			UDPDisplay:  "gtpv2 or pfcp or http2",
+			UDPBPF:      "udp port 2123 or udp port 8805 or tcp port 7777 or " + sipBPF,
+			UDPDisplay:  "gtpv2 or pfcp or http2 or sip",
 		}
 	}
 }
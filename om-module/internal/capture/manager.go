@@ -38,6 +38,8 @@ type Status struct {
 	RestartCount  uint64
 	UptimeSeconds float64
 	ActiveProcs   int
+	// Paused is true while the capture feature flag is off.
+	Paused bool
 }
 
 // Manager owns the tshark subprocess and feeds parsed packets to the correlator.
@@ -53,6 +55,9 @@ type Manager struct {
 	mnc              string
 	captureInterface string // "auto" or explicit interface name
 
+	// enabled gates the capture; nil captures always.
+	enabled func() bool
+
 	// out is the channel the correlator reads from.
 	out chan Packet
 
@@ -86,6 +91,32 @@ func NewManager(
 	}
 }
 
+// SetGate makes the manager capture only while enabled reports true:
+// tshark is stopped within generationPollInterval of it turning false and
+// started again when it turns true. Call it before Run.
+func (m *Manager) SetGate(enabled func() bool) {
+	m.enabled = enabled
+}
+
+func (m *Manager) gateOpen() bool {
+	return m.enabled == nil || m.enabled()
+}
+
+// count adds pkt to the packet counters of its generation. SBI response
+// frames are passed on for the SBI analyzer but not counted, so the SBI
+// counts stay one per request as before the analyzer.
+func (m *Manager) count(pkt Packet) {
+	if pkt.Protocol == "sbi" && pkt.SBIMethod == "" {
+		return
+	}
+	switch pkt.Generation {
+	case Generation4G:
+		m.packets4g.Add(1)
+	case Generation5G:
+		m.packets5g.Add(1)
+	}
+}
+
 // Packets returns the channel that delivers parsed packets to consumers.
 // The correlator should range over this channel.
 func (m *Manager) Packets() <-chan Packet {
@@ -113,6 +144,7 @@ func (m *Manager) Status() Status {
 		Packets5G:     m.packets5g.Load(),
 		RestartCount:  m.restarts.Load(),
 		UptimeSeconds: uptime,
+		Paused:        !m.gateOpen(),
 	}
 }
 
@@ -122,6 +154,13 @@ func (m *Manager) Run(ctx context.Context) {
 	log.Printf("📡 Capture manager started")
 
 	for {
+		// Phase 0: wait for the capture feature flag.
+		m.waitForGate(ctx)
+		if ctx.Err() != nil {
+			log.Printf("📡 Capture manager stopped")
+			return
+		}
+
 		// Phase 1: discover which generation is active.
 		gen := m.waitForGeneration(ctx)
 		if ctx.Err() != nil {
@@ -149,16 +188,18 @@ func (m *Manager) Run(ctx context.Context) {
 
 		log.Printf("📡 Capture ready: iface=%s generation=%s", iface, gen)
 
-		// Phase 3: run tshark, restarting on failure with backoff.
-		m.runWithRestart(ctx, iface, gen)
+		// Phase 3: run tshark, restarting on failure with backoff, until
+		// the feature flag turns the capture off.
+		m.runWhileEnabled(ctx, iface, gen)
 
 		if ctx.Err() != nil {
 			log.Printf("📡 Capture manager stopped")
 			return
 		}
 
-		// If we get here the context is still alive but the generation may have
-		// changed (e.g. operator switched from 5G to 4G core). Reset and re-detect.
+		// If we get here the context is still alive but the feature flag
+		// paused the capture or the generation may have changed (e.g.
+		// operator switched from 5G to 4G core). Reset and re-detect.
 		log.Printf("📡 Capture loop exited — re-detecting generation")
 		m.mu.Lock()
 		m.iface = ""
@@ -167,6 +208,50 @@ func (m *Manager) Run(ctx context.Context) {
 	}
 }
 
+// waitForGate returns when the capture feature flag is on or ctx is
+// cancelled.
+func (m *Manager) waitForGate(ctx context.Context) {
+	if m.gateOpen() {
+		return
+	}
+	log.Printf("📡 Capture paused by feature flag")
+	for !m.gateOpen() {
+		select {
+		case <-time.After(generationPollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+	log.Printf("📡 Capture resumed by feature flag")
+}
+
+// runWhileEnabled runs runWithRestart until ctx is cancelled or the capture
+// feature flag turns off.
+func (m *Manager) runWhileEnabled(ctx context.Context, iface, gen string) {
+	if m.enabled == nil {
+		m.runWithRestart(ctx, iface, gen)
+		return
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(generationPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !m.gateOpen() {
+					cancel()
+					return
+				}
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+	m.runWithRestart(runCtx, iface, gen)
+}
+
 // waitForGeneration polls the collector snapshot until a single active
 // generation is detected among running core containers.
 func (m *Manager) waitForGeneration(ctx context.Context) string {
@@ -220,12 +305,7 @@ func (m *Manager) runWithRestart(ctx context.Context, iface, gen string) {
 					if !ok {
 						sctpPkts = nil
 					} else {
-						switch pkt.Generation {
-						case Generation4G:
-							m.packets4g.Add(1)
-						case Generation5G:
-							m.packets5g.Add(1)
-						}
+						m.count(pkt)
 						select {
 						case m.out <- pkt:
 						case <-ctx.Done():
@@ -236,12 +316,7 @@ func (m *Manager) runWithRestart(ctx context.Context, iface, gen string) {
 					if !ok {
 						udpPkts = nil
 					} else {
-						switch pkt.Generation {
-						case Generation4G:
-							m.packets4g.Add(1)
-						case Generation5G:
-							m.packets5g.Add(1)
-						}
+						m.count(pkt)
 						select {
 						case m.out <- pkt:
 						case <-ctx.Done():
@@ -22,13 +22,17 @@ type Packet struct {
 	Generation string
 
 	// Protocol identifies the protocol of this packet:
-	// "s1ap", "ngap", "gtpv2", "pfcp"
+	// "s1ap", "ngap", "gtpv2", "pfcp", "diameter", "sbi", "sip"
 	Protocol string
 
 	// SrcIP and DstIP from the IP layer.
 	SrcIP string
 	DstIP string
 
+	// SrcPort and DstPort from the TCP layer (SBI only; zero otherwise).
+	SrcPort int
+	DstPort int
+
 	// --- S1AP fields (4G) ---
 	S1APProcedureCode int    // e.g. 12=InitialUEMessage, 11=DownlinkNAS, 13=UplinkNAS
 	ENBUUES1APID      string // ENB-UE-S1AP-ID, present from InitialUEMessage onward
@@ -36,6 +40,8 @@ type Packet struct {
 	NASEMMType        string // hex string e.g. "0x41" for Attach Request
 	NASESMType        string // hex string for ESM messages
 	IMSI              string // only present in Identity Response (NAS 0x56)
+	NASEMMCause       int    // EMM cause in Attach/TAU/Service Reject, Authentication Failure
+	NASESMCause       int    // ESM cause in PDN Connectivity Reject and bearer rejects
 
 	// --- NGAP fields (5G) ---
 	NGAPProcedureCode int    // e.g. 15=InitialUEMessage, 4=DownlinkNAS, 46=UplinkNAS
@@ -43,6 +49,29 @@ type Packet struct {
 	AMFUENGAPId       string // AMF-UE-NGAP-ID, present from DownlinkNASTransport onward
 	NASMMType         string // hex string e.g. "0x41" for Registration Request
 	SUCIMsin          string // MSIN portion of SUCI, only in Registration Request
+	NASSMType         string // 5GSM message type e.g. "0xc3" for PDU Session Establishment Reject
+	NAS5GMMCause      int    // 5GMM cause in Registration/Service Reject, Authentication Failure
+	NAS5GSMCause      int    // 5GSM cause in PDU Session Establishment Reject
+	PDUSessionID      int    // PDU session ID in PDU Session Resource Setup/Release
+	QoSFlowIDs        []int  // QFIs of the QoS flows being set up, in IE order
+	FiveQIs           []int  // 5QI of each QoS flow, parallel to QoSFlowIDs
+
+	// --- NAS security (both generations) ---
+	// Only the presence of the authentication vector is recorded, never its
+	// value: RAND/AUTN are per-attempt but still key material derivatives.
+	NASAuthRAND     bool   // RAND IE present (Authentication Request)
+	NASAuthAUTN     bool   // AUTN IE present (Authentication Request)
+	NASCipheringAlg string // selected NEA/EEA as a number e.g. "2"; "" if absent (Security Mode Command)
+	NASIntegrityAlg string // selected NIA/EIA as a number e.g. "2"; "" if absent (Security Mode Command)
+
+	// --- NGAP/S1AP Cause IE (Error Indication, UE Context Release, setup failures) ---
+	APCauseGroup string // radioNetwork | transport | nas | protocol | misc; "" if absent
+	APCause      int    // value within APCauseGroup
+
+	// --- NGAP/S1AP PDU type and cell identities (handover analytics) ---
+	APMessageType  string // initiating | successful | unsuccessful; "" if absent
+	APCellID       string // first NR Cell Identity / E-UTRAN Cell ID in the PDU, hex e.g. "0x000000010"
+	APTargetNodeID string // target gNB-ID / macro eNB-ID of Handover Required, hex
 
 	// --- GTPv2-C fields (4G only, UDP 2123) ---
 	GTPv2MessageType int    // 32=CreateSessionReq, 33=CreateSessionResp, 34=ModifyBearerReq, 35=ModifyBearerResp
@@ -53,6 +82,8 @@ type Packet struct {
 	GTPv2Cause       string // "16" = Request Accepted
 	GTPv2UEIP        string // UE IP address, assigned in Create Session Response
 	GTPv2EBI         string // EPS Bearer ID
+	GTPv2QCI         int    // QCI from the Bearer QoS IE (Create Session/Bearer Request)
+	GTPv2ARP         int    // ARP priority level from the Bearer QoS IE
 
 	// --- PFCP fields (4G and 5G, UDP 8805) ---
 	PFCPMessageType int    // 50=EstReq, 51=EstResp, 52=ModReq, 53=ModResp, 54=DelReq, 55=DelResp
@@ -78,6 +109,16 @@ type Packet struct {
 	SBIService   string // service name extracted from path e.g. "nausf-auth"
 	SBIUserAgent string // NF name from user-agent header e.g. "AMF"
 	SBIIMSI      string // IMSI extracted from path if present
+	SBIStreamID  int    // HTTP/2 stream ID — pairs a response with its request
+
+	// --- SIP fields (IMS/VoLTE: P-CSCF 5060, I-CSCF 4060, S-CSCF 6060) ---
+	SIPMethod     string // request method: REGISTER, INVITE, ACK, BYE, …; "" on responses
+	SIPStatusCode int    // response status code e.g. 401, 200; 0 on requests
+	SIPCSeqMethod string // method from the CSeq header — ties a response to its request
+	SIPCallID     string // Call-ID — correlation key for registrations and dialogs
+	SIPFromUser   string // user part of the From URI (IMSI- or MSISDN-based IMPU)
+	SIPToUser     string // user part of the To URI
+	SIPExpires    string // Expires header; "0" on a REGISTER means de-registration
 }
 
 // ekPacket is the raw EK JSON structure emitted by tshark -T ek.
@@ -116,6 +157,11 @@ func startTshark(ctx context.Context, iface, bpf, display string) (<-chan Packet
 		"-n", // disable name resolution
 		// Decode TCP port 7777 as HTTP/2 (Open5GS SBI uses h2c without upgrade)
 		"-d", "tcp.port==7777,http2",
+		// Kamailio I-CSCF and S-CSCF listen on non-standard SIP ports
+		"-d", "udp.port==4060,sip",
+		"-d", "udp.port==6060,sip",
+		"-d", "tcp.port==4060,sip",
+		"-d", "tcp.port==6060,sip",
 	}
 
 	cmd := exec.CommandContext(procCtx, "tshark", args...)
@@ -244,8 +290,17 @@ func parseEKLine(line string) (*Packet, error) {
 		}
 	}
 
+	// TCP ports — only needed to pair SBI requests and responses.
+	if tcpRaw, ok := raw.Layers["tcp"]; ok {
+		var tcp map[string]interface{}
+		if err := json.Unmarshal(tcpRaw, &tcp); err == nil {
+			pkt.SrcPort = intField(tcp, "tcp_tcp_srcport")
+			pkt.DstPort = intField(tcp, "tcp_tcp_dstport")
+		}
+	}
+
 	// Determine protocol and generation from which layer is present.
-	// Priority: ngap > s1ap > gtpv2 > pfcp > diameter > http2
+	// Priority: ngap > s1ap > gtpv2 > pfcp > diameter > http2 > sip
 	if ngapRaw, ok := raw.Layers["ngap"]; ok {
 		pkt.Generation = Generation5G
 		pkt.Protocol = "ngap"
@@ -269,6 +324,11 @@ func parseEKLine(line string) (*Packet, error) {
 		pkt.Generation = Generation5G
 		pkt.Protocol = "sbi"
 		parseSBI(http2Raw, pkt)
+	} else if sipRaw, ok := raw.Layers["sip"]; ok {
+		// Generation is left empty: IMS serves both VoLTE and VoNR, and the
+		// pipeline resolves it from the NFs involved.
+		pkt.Protocol = "sip"
+		parseSIP(sipRaw, pkt)
 	}
 
 	return pkt, nil
@@ -305,6 +365,13 @@ func parseNGAPObject(raw json.RawMessage, pkt *Packet) {
 	pkt.NGAPProcedureCode = intField(obj, "ngap_ngap_procedureCode")
 	pkt.RANUENGAPId = strField(obj, "ngap_ngap_RAN_UE_NGAP_ID")
 	pkt.AMFUENGAPId = strField(obj, "ngap_ngap_AMF_UE_NGAP_ID")
+	pkt.PDUSessionID = intField(obj, "ngap_ngap_pDUSessionID")
+	pkt.QoSFlowIDs = intsField(obj, "ngap_ngap_qosFlowIdentifier")
+	pkt.FiveQIs = intsField(obj, "ngap_ngap_fiveQI")
+	pkt.APCauseGroup, pkt.APCause = apCause(obj, "ngap_ngap_")
+	pkt.APMessageType = apMessageType(strField(obj, "ngap_ngap_NGAP_PDU"))
+	pkt.APCellID = hexField(obj, "ngap_ngap_nRCellIdentity")
+	pkt.APTargetNodeID = hexField(obj, "ngap_ngap_gNB_ID")
 
 	// NAS-5GS is nested inside the ngap object under the key "nas-5gs".
 	if nasRaw, ok := obj["nas-5gs"]; ok {
@@ -313,6 +380,12 @@ func parseNGAPObject(raw json.RawMessage, pkt *Packet) {
 		if err := json.Unmarshal(nasBytes, &nas); err == nil {
 			pkt.NASMMType = strField(nas, "nas-5gs_nas-5gs_mm_message_type")
 			pkt.SUCIMsin = strField(nas, "nas-5gs_nas-5gs_mm_suci_msin")
+			pkt.NASSMType = strField(nas, "nas-5gs_nas-5gs_sm_message_type")
+			pkt.NAS5GMMCause = intField(nas, "nas-5gs_nas-5gs_mm_5gmm_cause")
+			pkt.NAS5GSMCause = intField(nas, "nas-5gs_nas-5gs_sm_5gsm_cause")
+			pkt.NASCipheringAlg = strField(nas, "nas-5gs_nas-5gs_mm_nas_sec_algo_enc")
+			pkt.NASIntegrityAlg = strField(nas, "nas-5gs_nas-5gs_mm_nas_sec_algo_ip")
+			pkt.NASAuthRAND, pkt.NASAuthAUTN = authVector(nas)
 		}
 	}
 }
@@ -342,6 +415,10 @@ func parseS1APObject(raw json.RawMessage, pkt *Packet) {
 	pkt.S1APProcedureCode = intField(obj, "s1ap_s1ap_procedureCode")
 	pkt.ENBUUES1APID = strField(obj, "s1ap_s1ap_ENB_UE_S1AP_ID")
 	pkt.MMEUUES1APID = strField(obj, "s1ap_s1ap_MME_UE_S1AP_ID")
+	pkt.APCauseGroup, pkt.APCause = apCause(obj, "s1ap_s1ap_")
+	pkt.APMessageType = apMessageType(strField(obj, "s1ap_s1ap_S1AP_PDU"))
+	pkt.APCellID = hexField(obj, "s1ap_s1ap_cell_ID")
+	pkt.APTargetNodeID = hexField(obj, "s1ap_s1ap_macroENB_ID")
 
 	// NAS-EPS is nested inside the s1ap object under "nas-eps".
 	if nasRaw, ok := obj["nas-eps"]; ok {
@@ -351,10 +428,51 @@ func parseS1APObject(raw json.RawMessage, pkt *Packet) {
 			pkt.NASEMMType = strField(nas, "nas-eps_nas-eps_nas_msg_emm_type")
 			pkt.NASESMType = strField(nas, "nas-eps_nas-eps_nas_msg_esm_type")
 			pkt.IMSI = strField(nas, "e212_e212_imsi")
+			pkt.NASEMMCause = intField(nas, "nas-eps_nas-eps_emm_cause")
+			pkt.NASESMCause = intField(nas, "nas-eps_nas-eps_esm_cause")
+			pkt.NASCipheringAlg = strField(nas, "nas-eps_nas-eps_emm_toc")
+			pkt.NASIntegrityAlg = strField(nas, "nas-eps_nas-eps_emm_toi")
+			pkt.NASAuthRAND, pkt.NASAuthAUTN = authVector(nas)
 		}
 	}
 }
 
+// apCauseGroups are the CHOICE alternatives of the NGAP and S1AP Cause IE.
+var apCauseGroups = []string{"radioNetwork", "transport", "nas", "protocol", "misc"}
+
+// apCause returns the group and value of the Cause IE, if the PDU has one.
+// prefix is the layer field prefix ("ngap_ngap_" or "s1ap_s1ap_").
+func apCause(obj map[string]interface{}, prefix string) (string, int) {
+	for _, group := range apCauseGroups {
+		if _, ok := obj[prefix+group]; ok {
+			return group, intField(obj, prefix+group)
+		}
+	}
+	return "", 0
+}
+
+// apMessageType names the NGAP-PDU / S1AP-PDU CHOICE value.
+func apMessageType(v string) string {
+	switch v {
+	case "0":
+		return "initiating"
+	case "1":
+		return "successful"
+	case "2":
+		return "unsuccessful"
+	}
+	return ""
+}
+
+// authVector reports whether the RAND and AUTN IEs of an Authentication
+// Request are present. Both NAS dissectors decode them with the GSM A DTAP
+// fields.
+func authVector(nas map[string]interface{}) (rand, autn bool) {
+	_, rand = nas["gsm_a_dtap_gsm_a_dtap_rand"]
+	_, autn = nas["gsm_a_dtap_gsm_a_dtap_autn"]
+	return rand, autn
+}
+
 // --- helpers ----------------------------------------------------------------
 
 // strField extracts a string value from a map, returning "" if absent or wrong type.
@@ -392,6 +510,16 @@ func strField(m map[string]interface{}, key string) string {
 	}
 }
 
+// hexField returns a BIT STRING field (cell or node identity) as lower-case
+// hex with a 0x prefix, whether tshark printed it with colons or not.
+func hexField(m map[string]interface{}, key string) string {
+	v := strings.ToLower(strings.ReplaceAll(strField(m, key), ":", ""))
+	if v == "" || strings.HasPrefix(v, "0x") {
+		return v
+	}
+	return "0x" + v
+}
+
 // intField extracts an integer value from a map. JSON numbers unmarshal as
 // float64 by default with interface{}, so we handle that explicitly.
 func intField(m map[string]interface{}, key string) int {
@@ -408,11 +536,35 @@ func intField(m map[string]interface{}, key string) int {
 		var i int
 		fmt.Sscanf(n, "%d", &i)
 		return i
+	case []interface{}:
+		// Repeated field — take the first element, as strField does
+		if len(n) > 0 {
+			return intField(map[string]interface{}{key: n[0]}, key)
+		}
+		return 0
 	default:
 		return 0
 	}
 }
 
+// intsField extracts every integer value of a possibly repeated field, e.g.
+// one QFI per QoS flow in a PDU Session Resource Setup.
+func intsField(m map[string]interface{}, key string) []int {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return []int{intField(m, key)}
+	}
+	out := make([]int, 0, len(arr))
+	for _, e := range arr {
+		out = append(out, intField(map[string]interface{}{key: e}, key))
+	}
+	return out
+}
+
 // --- GTPv2-C parser ---------------------------------------------------------
 
 // parseGTPv2 extracts GTPv2-C fields from the gtpv2 layer.
@@ -428,6 +580,8 @@ func parseGTPv2(raw json.RawMessage, pkt *Packet) {
 	pkt.GTPv2APN = strField(obj, "gtpv2_gtpv2_apn")
 	pkt.GTPv2EBI = strField(obj, "gtpv2_gtpv2_ebi")
 	pkt.GTPv2UEIP = strField(obj, "gtpv2_gtpv2_pdn_addr_and_prefix_ipv4")
+	pkt.GTPv2QCI = intField(obj, "gtpv2_gtpv2_bearer_qos_label_qci")
+	pkt.GTPv2ARP = intField(obj, "gtpv2_gtpv2_bearer_qos_pl")
 
 	// Cause may be a scalar or array — strField handles arrays
 	pkt.GTPv2Cause = strField(obj, "gtpv2_gtpv2_cause")
@@ -550,6 +704,7 @@ func parseSBI(raw json.RawMessage, pkt *Packet) {
 	pkt.SBIPath = strField(obj, "http2_http2_headers_path")
 	pkt.SBIStatus = strField(obj, "http2_http2_headers_status")
 	pkt.SBIUserAgent = strField(obj, "http2_http2_headers_user_agent")
+	pkt.SBIStreamID = intField(obj, "http2_http2_streamid")
 
 	// Skip packets with no method and no status — these are DATA frames
 	// or SETTINGS/PING frames with no signalling value
@@ -558,10 +713,10 @@ func parseSBI(raw json.RawMessage, pkt *Packet) {
 		return
 	}
 
-	// Only process request frames (those with a method).
-	// Response frames have no path so the service is unknown — they add noise.
+	// Response frames carry no path, so the service is unknown here. They are
+	// kept so the SBI analyzer can pair them with their request by stream ID;
+	// the span pipeline ignores them.
 	if pkt.SBIMethod == "" {
-		pkt.Protocol = "" // drop response-only frames
 		return
 	}
 
@@ -588,3 +743,37 @@ func parseSBI(raw json.RawMessage, pkt *Packet) {
 		pkt.SBIIMSI = rest[:end]
 	}
 }
+
+// --- SIP parser -------------------------------------------------------------
+
+// parseSIP extracts IMS signalling fields from the sip layer. Over TCP several
+// SIP messages may share a segment; only the first one is kept.
+func parseSIP(raw json.RawMessage, pkt *Packet) {
+	var arr []json.RawMessage
+	if err := json.Unmarshal(raw, &arr); err == nil {
+		if len(arr) == 0 {
+			pkt.Protocol = ""
+			return
+		}
+		raw = arr[0]
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		pkt.Protocol = ""
+		return
+	}
+
+	pkt.SIPMethod = strField(obj, "sip_sip_Method")
+	pkt.SIPStatusCode = intField(obj, "sip_sip_Status-Code")
+	pkt.SIPCSeqMethod = strField(obj, "sip_sip_CSeq_method")
+	pkt.SIPCallID = strField(obj, "sip_sip_Call-ID")
+	pkt.SIPFromUser = strField(obj, "sip_sip_from_user")
+	pkt.SIPToUser = strField(obj, "sip_sip_to_user")
+	pkt.SIPExpires = strField(obj, "sip_sip_Expires")
+
+	// Keep-alive CRLFs and fragments carry neither a method nor a status.
+	if pkt.SIPMethod == "" && pkt.SIPStatusCode == 0 {
+		pkt.Protocol = ""
+	}
+}
@@ -0,0 +1,565 @@
+// Package cardinality keeps the series Prometheus holds within budgets, so
+// a lab on a student laptop does not run out of memory because one metric
+// grew a label per UE, session or RNTI.
+//
+// Every interval the Manager reads the head series per metric family from
+// Prometheus' TSDB status, and the number of values of the labels of every
+// family near its series budget or carrying a label with more values than
+// the label budget. A label over the label budget, or the label with most
+// values of a family over the series budget, is limited: the rendered
+// Prometheus configuration drops it from the series of that family, or
+// hashes its values into a few buckets, in the metric_relabel_configs of
+// every scrape job (promconfig.LabelLimit). Limits stay until the module
+// restarts, since the family looks within budget once they apply. Families
+// near their budget are reported before anything is dropped.
+package cardinality
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/promconfig"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Actions on a label over budget.
+const (
+	ActionDrop   = "drop"
+	ActionHash   = "hash"
+	ActionReport = "report" // warn only
+)
+
+// Actions lists the valid Options.Action values.
+var Actions = []string{ActionDrop, ActionHash, ActionReport}
+
+// States of a metric family against its series budget.
+const (
+	StateOK   = "ok"
+	StateNear = "near"
+	StateOver = "over"
+)
+
+// topFamilies is how many families the TSDB status is asked for, by
+// series; smaller families are not tracked.
+const topFamilies = 50
+
+// Options configure the manager.
+type Options struct {
+	PrometheusURL string
+	Timeout       time.Duration // per request
+	Interval      time.Duration
+	// SeriesBudget is the head series one metric family may have,
+	// LabelBudget the values one of its labels may take. A family is near
+	// its budget from WarnRatio of either.
+	SeriesBudget int
+	LabelBudget  int
+	WarnRatio    float64
+	// Action is what happens to a label over budget; HashBuckets is how
+	// many values a hashed label keeps.
+	Action      string
+	HashBuckets int
+	// Protected labels are never limited: those identifying the target
+	// and the buckets of histograms and summaries.
+	Protected []string
+}
+
+// Label is the number of values of one label of a family.
+type Label struct {
+	Name    string `json:"label"`
+	Values  int    `json:"values"`
+	State   string `json:"state"`
+	Limited string `json:"limited,omitempty"` // drop | hash, once limited
+}
+
+// Family is one metric family and its head series.
+type Family struct {
+	Name   string  `json:"metric_family"`
+	Series int     `json:"series"`
+	Ratio  float64 `json:"budget_ratio"`
+	State  string  `json:"state"`
+	Labels []Label `json:"labels,omitempty"`
+}
+
+// Limit is a label limited by the manager.
+type Limit struct {
+	promconfig.LabelLimit
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	Since  string `json:"since"`
+}
+
+// Status is the API view of the manager.
+type Status struct {
+	HeadSeries   int      `json:"head_series"`
+	SeriesBudget int      `json:"series_budget"`
+	LabelBudget  int      `json:"label_budget"`
+	WarnRatio    float64  `json:"warn_ratio"`
+	Action       string   `json:"action"`
+	Families     []Family `json:"families"`
+	Limits       []Limit  `json:"limits"`
+	Checked      string   `json:"checked,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Manager tracks the series per metric family and label in Prometheus and
+// limits the labels over budget.
+type Manager struct {
+	opts   Options
+	client *http.Client
+
+	head    prometheus.Gauge
+	series  *prometheus.GaugeVec
+	ratio   *prometheus.GaugeVec
+	values  *prometheus.GaugeVec
+	limited *prometheus.GaugeVec
+
+	mu       sync.RWMutex
+	families []Family
+	total    int
+	limits   map[string]*Limit // by metric family and label
+	warned   map[string]bool   // families and labels reported near budget
+	checked  time.Time
+	lastErr  string
+}
+
+// New registers the om_cardinality_* metrics on reg and returns the
+// manager.
+func New(reg prometheus.Registerer, opts Options) *Manager {
+	m := &Manager{
+		opts:   opts,
+		client: httpclient.New("cardinality", 0),
+		limits: make(map[string]*Limit),
+		warned: make(map[string]bool),
+		head: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cardinality", Name: "head_series",
+			Help: "Series in Prometheus' head block.",
+		}),
+		series: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cardinality", Name: "series",
+			Help: "Head series of the largest metric families in Prometheus.",
+		}, []string{"metric_family"}),
+		ratio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cardinality", Name: "budget_ratio",
+			Help: "Head series of a metric family over its series budget; near the budget from the warn ratio, over it above 1.",
+		}, []string{"metric_family"}),
+		values: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cardinality", Name: "label_values",
+			Help: "Values of the labels of the metric families near or over a budget.",
+		}, []string{"metric_family", "label"}),
+		limited: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cardinality", Name: "label_limited",
+			Help: "1 for a label dropped or hashed from a metric family to keep it within budget.",
+		}, []string{"metric_family", "label", "action"}),
+	}
+	reg.MustRegister(m.head, m.series, m.ratio, m.values, m.limited)
+	return m
+}
+
+// ValidAction reports whether a is one of Actions.
+func ValidAction(a string) bool {
+	return slices.Contains(Actions, a)
+}
+
+// Run checks the budgets every interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		err := m.check(ctx)
+		switch {
+		case err == nil:
+			m.mu.Lock()
+			m.lastErr = ""
+			m.mu.Unlock()
+		case ctx.Err() == nil:
+			m.mu.Lock()
+			first := m.lastErr == ""
+			m.lastErr = err.Error()
+			m.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Cardinality: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check reads the series of the largest families, inspects the labels of
+// those near a budget and limits the labels over it.
+func (m *Manager) check(ctx context.Context) error {
+	total, counts, wide, err := m.tsdbStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The families worth a look at their labels: near the series budget,
+	// or carrying a label that has too many values overall.
+	inspect := make(map[string]bool)
+	for name, n := range counts {
+		if m.state(n, m.opts.SeriesBudget) != StateOK {
+			inspect[name] = true
+		}
+	}
+	for _, label := range wide {
+		names, err := m.familiesWith(ctx, label)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			inspect[name] = true
+		}
+	}
+
+	families := make([]Family, 0, len(counts))
+	for name, n := range counts {
+		families = append(families, Family{Name: name, Series: n, Ratio: m.ratioOf(n), State: m.state(n, m.opts.SeriesBudget)})
+	}
+	for name := range inspect {
+		if _, ok := counts[name]; !ok {
+			n, err := m.scalar(ctx, fmt.Sprintf("count({__name__=%q})", name))
+			if err != nil {
+				return err
+			}
+			families = append(families, Family{Name: name, Series: n, Ratio: m.ratioOf(n), State: m.state(n, m.opts.SeriesBudget)})
+		}
+	}
+	sort.Slice(families, func(i, j int) bool {
+		if families[i].Series != families[j].Series {
+			return families[i].Series > families[j].Series
+		}
+		return families[i].Name < families[j].Name
+	})
+
+	for i := range families {
+		f := &families[i]
+		if !inspect[f.Name] {
+			continue
+		}
+		labels, err := m.labels(ctx, f.Name)
+		if err != nil {
+			return err
+		}
+		f.Labels = labels
+		m.enforce(f)
+	}
+
+	m.mu.Lock()
+	m.families = families
+	m.total = total
+	m.checked = time.Now()
+	m.mu.Unlock()
+	m.updateGauges()
+	return nil
+}
+
+// enforce limits the labels of f over budget and reports those near it.
+// A family over its series budget with no label over the label budget
+// loses its label with most values.
+func (m *Manager) enforce(f *Family) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range f.Labels {
+		l := &f.Labels[i]
+		if lim := m.limits[f.Name+"/"+l.Name]; lim != nil {
+			l.Limited = lim.Action
+		}
+	}
+
+	var over *Label
+	reason := ""
+	for i := range f.Labels {
+		l := &f.Labels[i]
+		switch {
+		case l.State == StateOver && l.Limited == "":
+			over, reason = l, fmt.Sprintf("%d values, label budget %d", l.Values, m.opts.LabelBudget)
+		case l.State == StateNear && !m.warned[f.Name+"/"+l.Name]:
+			m.warned[f.Name+"/"+l.Name] = true
+			log.Printf("⚠️  Cardinality: label %s of %s has %d values (budget %d)", l.Name, f.Name, l.Values, m.opts.LabelBudget)
+		}
+		if over != nil {
+			break
+		}
+	}
+	if over == nil && f.State == StateOver {
+		for i := range f.Labels {
+			l := &f.Labels[i]
+			if l.Limited == "" && l.Values > 1 && (over == nil || l.Values > over.Values) {
+				over = l
+			}
+		}
+		if over != nil {
+			reason = fmt.Sprintf("%d series, series budget %d; %s has the most values (%d)", f.Series, m.opts.SeriesBudget, over.Name, over.Values)
+		}
+	}
+	if f.State == StateNear && !m.warned[f.Name] {
+		m.warned[f.Name] = true
+		log.Printf("⚠️  Cardinality: %s has %d series (budget %d)", f.Name, f.Series, m.opts.SeriesBudget)
+	}
+	if over == nil {
+		return
+	}
+	if m.opts.Action == ActionReport {
+		if !m.warned[f.Name+"/"+over.Name+"/over"] {
+			m.warned[f.Name+"/"+over.Name+"/over"] = true
+			log.Printf("🛑 Cardinality: %s over budget (%s) — reported only", f.Name, reason)
+		}
+		return
+	}
+	lim := &Limit{
+		LabelLimit: promconfig.LabelLimit{Metric: f.Name, Label: over.Name},
+		Action:     m.opts.Action,
+		Reason:     reason,
+		Since:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if m.opts.Action == ActionHash {
+		lim.Buckets = m.opts.HashBuckets
+	}
+	m.limits[f.Name+"/"+over.Name] = lim
+	over.Limited = lim.Action
+	log.Printf("🛑 Cardinality: %s over budget (%s) — %s label %s", f.Name, reason, verb(lim.Action), over.Name)
+}
+
+func verb(action string) string {
+	if action == ActionHash {
+		return "hashing"
+	}
+	return "dropping"
+}
+
+// ratioOf is n over the series budget.
+func (m *Manager) ratioOf(n int) float64 {
+	if m.opts.SeriesBudget <= 0 {
+		return 0
+	}
+	return float64(n) / float64(m.opts.SeriesBudget)
+}
+
+// state places n against budget.
+func (m *Manager) state(n, budget int) string {
+	switch {
+	case budget <= 0:
+		return StateOK
+	case n > budget:
+		return StateOver
+	case float64(n) >= m.opts.WarnRatio*float64(budget):
+		return StateNear
+	}
+	return StateOK
+}
+
+// Limits returns the labels limited so far, for promconfig.Options, by
+// metric family and label.
+func (m *Manager) Limits() []promconfig.LabelLimit {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]promconfig.LabelLimit, 0, len(m.limits))
+	for _, l := range m.limits {
+		out = append(out, l.LabelLimit)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Metric != out[j].Metric {
+			return out[i].Metric < out[j].Metric
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out
+}
+
+// Status returns the families tracked, largest first, and the limits.
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s := Status{
+		HeadSeries:   m.total,
+		SeriesBudget: m.opts.SeriesBudget,
+		LabelBudget:  m.opts.LabelBudget,
+		WarnRatio:    m.opts.WarnRatio,
+		Action:       m.opts.Action,
+		Families:     append([]Family{}, m.families...),
+		Limits:       make([]Limit, 0, len(m.limits)),
+		Error:        m.lastErr,
+	}
+	for _, l := range m.limits {
+		s.Limits = append(s.Limits, *l)
+	}
+	sort.Slice(s.Limits, func(i, j int) bool { return s.Limits[i].Since < s.Limits[j].Since })
+	if !m.checked.IsZero() {
+		s.Checked = m.checked.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the om_cardinality_* gauges were last updated,
+// for exporter.Ages.
+func (m *Manager) Freshness() map[string]time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.checked.IsZero() {
+		return nil
+	}
+	out := make(map[string]time.Time, 4)
+	for _, f := range []string{"head_series", "series", "budget_ratio", "label_values"} {
+		out["om_cardinality_"+f] = m.checked
+	}
+	return out
+}
+
+func (m *Manager) updateGauges() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	m.head.Set(float64(m.total))
+	m.series.Reset()
+	m.ratio.Reset()
+	m.values.Reset()
+	m.limited.Reset()
+	for _, f := range m.families {
+		m.series.WithLabelValues(f.Name).Set(float64(f.Series))
+		m.ratio.WithLabelValues(f.Name).Set(f.Ratio)
+		for _, l := range f.Labels {
+			m.values.WithLabelValues(f.Name, l.Name).Set(float64(l.Values))
+		}
+	}
+	for _, l := range m.limits {
+		m.limited.WithLabelValues(l.Metric, l.Label, l.Action).Set(1)
+	}
+}
+
+// --- Prometheus API ---------------------------------------------------------
+
+// tsdbStatus returns the head series, the series of the largest families
+// and the labels with more values than the label budget.
+func (m *Manager) tsdbStatus(ctx context.Context) (int, map[string]int, []string, error) {
+	var body struct {
+		Data struct {
+			HeadStats struct {
+				NumSeries int `json:"numSeries"`
+			} `json:"headStats"`
+			SeriesCountByMetricName    []nameValue `json:"seriesCountByMetricName"`
+			LabelValueCountByLabelName []nameValue `json:"labelValueCountByLabelName"`
+		} `json:"data"`
+	}
+	q := url.Values{"limit": {strconv.Itoa(topFamilies)}}
+	if err := m.get(ctx, "status/tsdb", q, &body); err != nil {
+		return 0, nil, nil, err
+	}
+	counts := make(map[string]int, len(body.Data.SeriesCountByMetricName))
+	for _, nv := range body.Data.SeriesCountByMetricName {
+		counts[nv.Name] = nv.Value
+	}
+	var wide []string
+	for _, nv := range body.Data.LabelValueCountByLabelName {
+		if nv.Value > m.opts.LabelBudget && !m.protected(nv.Name) {
+			wide = append(wide, nv.Name)
+		}
+	}
+	return body.Data.HeadStats.NumSeries, counts, wide, nil
+}
+
+type nameValue struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// familiesWith returns the metric families with series carrying label.
+func (m *Manager) familiesWith(ctx context.Context, label string) ([]string, error) {
+	var body struct {
+		Data []string `json:"data"`
+	}
+	q := url.Values{"match[]": {fmt.Sprintf("{%s!=\"\"}", label)}}
+	if err := m.get(ctx, "label/__name__/values", q, &body); err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
+
+// labels returns the unprotected labels of family with their number of
+// values, most values first.
+func (m *Manager) labels(ctx context.Context, family string) ([]Label, error) {
+	var body struct {
+		Data []string `json:"data"`
+	}
+	sel := fmt.Sprintf("{__name__=%q}", family)
+	if err := m.get(ctx, "labels", url.Values{"match[]": {sel}}, &body); err != nil {
+		return nil, err
+	}
+	var out []Label
+	for _, name := range body.Data {
+		if m.protected(name) {
+			continue
+		}
+		n, err := m.scalar(ctx, fmt.Sprintf("count(count by (%s) (%s))", name, sel))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Label{Name: name, Values: n, State: m.state(n, m.opts.LabelBudget)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Values != out[j].Values {
+			return out[i].Values > out[j].Values
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// protected reports whether label is never limited.
+func (m *Manager) protected(label string) bool {
+	return strings.HasPrefix(label, "__") || slices.Contains(m.opts.Protected, label)
+}
+
+// scalar runs an instant query returning a single number; no result is 0.
+func (m *Manager) scalar(ctx context.Context, expr string) (int, error) {
+	var body struct {
+		Data struct {
+			Result []struct {
+				Value [2]any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := m.get(ctx, "query", url.Values{"query": {expr}}, &body); err != nil {
+		return 0, err
+	}
+	if len(body.Data.Result) == 0 {
+		return 0, nil
+	}
+	s, _ := body.Data.Result[0].Value[1].(string)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", expr, err)
+	}
+	return int(f), nil
+}
+
+func (m *Manager) get(ctx context.Context, endpoint string, q url.Values, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+	target := strings.TrimRight(m.opts.PrometheusURL, "/") + "/api/v1/" + endpoint + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", req.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
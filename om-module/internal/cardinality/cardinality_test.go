@@ -0,0 +1,117 @@
+package cardinality
+
+import (
+	"testing"
+
+	"github.com/Parz1val02/OM_module/internal/promconfig"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestEnforce(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		family Family
+		want   []promconfig.LabelLimit
+	}{
+		{
+			name:   "label over budget dropped",
+			action: ActionDrop,
+			family: Family{Name: "ues_active", Series: 500, State: StateNear, Labels: []Label{
+				{Name: "cell", Values: 3, State: StateOK},
+				{Name: "rnti", Values: 400, State: StateOver},
+			}},
+			want: []promconfig.LabelLimit{{Metric: "ues_active", Label: "rnti"}},
+		},
+		{
+			name:   "label over budget hashed",
+			action: ActionHash,
+			family: Family{Name: "ues_active", Series: 500, State: StateNear, Labels: []Label{
+				{Name: "rnti", Values: 400, State: StateOver},
+			}},
+			want: []promconfig.LabelLimit{{Metric: "ues_active", Label: "rnti", Buckets: 8}},
+		},
+		{
+			name:   "family over budget loses its widest label",
+			action: ActionHash,
+			family: Family{Name: "sessions", Series: 2000, State: StateOver, Labels: []Label{
+				{Name: "dnn", Values: 2, State: StateOK},
+				{Name: "imsi", Values: 90, State: StateNear},
+				{Name: "qfi", Values: 9, State: StateOK},
+			}},
+			want: []promconfig.LabelLimit{{Metric: "sessions", Label: "imsi", Buckets: 8}},
+		},
+		{
+			name:   "report only",
+			action: ActionReport,
+			family: Family{Name: "ues_active", Series: 2000, State: StateOver, Labels: []Label{
+				{Name: "rnti", Values: 400, State: StateOver},
+			}},
+		},
+		{
+			name:   "within budget",
+			action: ActionDrop,
+			family: Family{Name: "ues_active", Series: 900, State: StateNear, Labels: []Label{
+				{Name: "rnti", Values: 90, State: StateNear},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(prometheus.NewRegistry(), Options{
+				SeriesBudget: 1000, LabelBudget: 100, WarnRatio: 0.8,
+				Action: tt.action, HashBuckets: 8,
+			})
+			m.enforce(&tt.family)
+			got := m.Limits()
+			if len(got) != len(tt.want) {
+				t.Fatalf("limits %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("limit %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A label stays limited once, however often it is found over budget.
+func TestEnforceOnce(t *testing.T) {
+	m := New(prometheus.NewRegistry(), Options{SeriesBudget: 1000, LabelBudget: 100, WarnRatio: 0.8, Action: ActionDrop})
+	for range 3 {
+		f := Family{Name: "ues_active", Series: 2000, State: StateOver, Labels: []Label{
+			{Name: "cell", Values: 3, State: StateOK},
+			{Name: "rnti", Values: 400, State: StateOver},
+		}}
+		m.enforce(&f)
+		if f.Labels[1].Limited != ActionDrop {
+			t.Errorf("rnti not marked limited: %+v", f.Labels[1])
+		}
+	}
+	// The family is still over budget after rnti; the widest label left
+	// goes next.
+	if got := m.Limits(); len(got) != 2 || got[0].Label != "cell" || got[1].Label != "rnti" {
+		t.Errorf("limits %v, want cell and rnti", got)
+	}
+}
+
+func TestState(t *testing.T) {
+	m := &Manager{opts: Options{WarnRatio: 0.8}}
+	tests := []struct {
+		n, budget int
+		want      string
+	}{
+		{0, 100, StateOK},
+		{79, 100, StateOK},
+		{80, 100, StateNear},
+		{100, 100, StateNear},
+		{101, 100, StateOver},
+		{5000, 0, StateOK},
+	}
+	for _, tt := range tests {
+		if got := m.state(tt.n, tt.budget); got != tt.want {
+			t.Errorf("state(%d, %d) = %s, want %s", tt.n, tt.budget, got, tt.want)
+		}
+	}
+}
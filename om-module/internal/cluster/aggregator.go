@@ -0,0 +1,328 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Peer is one lab bench running its own O&M module.
+type Peer struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// ParsePeers parses a comma-separated peer list. Each entry is either
+// "name=http://host:8080" or a bare URL, in which case the host is the name.
+func ParsePeers(s string) []Peer {
+	var peers []Peer
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(entry, "=")
+		if !ok {
+			url = entry
+			name = strings.TrimPrefix(strings.TrimPrefix(entry, "http://"), "https://")
+			if i := strings.IndexAny(name, ":/"); i >= 0 {
+				name = name[:i]
+			}
+		}
+		peers = append(peers, Peer{Name: name, URL: strings.TrimRight(url, "/")})
+	}
+	return peers
+}
+
+// PeerStatus is the last known state of one bench.
+type PeerStatus struct {
+	Peer
+	Up                 bool     `json:"up"`
+	Error              string   `json:"error,omitempty"`
+	LastPoll           string   `json:"last_poll"`
+	Status             string   `json:"status"`
+	Generation         string   `json:"generation"`
+	Containers         int      `json:"containers"`
+	Running            int      `json:"running"`
+	Stopped            int      `json:"stopped"`
+	CaptureRunning     bool     `json:"capture_running"`
+	CapturePackets     uint64   `json:"capture_packets"`
+	MilestonesAchieved int      `json:"milestones_achieved"`
+	MilestonesTotal    int      `json:"milestones_total"`
+	Milestones         []string `json:"milestones"`
+}
+
+// Overview is the combined classroom view.
+type Overview struct {
+	Timestamp string       `json:"timestamp"`
+	PeersUp   int          `json:"peers_up"`
+	Peers     []PeerStatus `json:"peers"`
+}
+
+// Aggregator polls the status endpoints of several O&M module instances and
+// federates them into one classroom overview, both as JSON and as
+// om_cluster_* metrics for the cross-bench dashboard.
+type Aggregator struct {
+	peers    []Peer
+	interval time.Duration
+	timeout  time.Duration
+	client   *http.Client
+
+	up         *prometheus.GaugeVec
+	containers *prometheus.GaugeVec
+	packets    *prometheus.GaugeVec
+	milestones *prometheus.GaugeVec
+	info       *prometheus.GaugeVec
+
+	mu       sync.RWMutex
+	status   map[string]PeerStatus
+	polled   time.Time            // end of the last poll round
+	answered map[string]time.Time // peer → last successful poll
+}
+
+// New creates an Aggregator for peers and registers its metrics on reg.
+// timeout bounds each request to a peer.
+func New(reg prometheus.Registerer, peers []Peer, interval, timeout time.Duration) *Aggregator {
+	a := &Aggregator{
+		peers:    peers,
+		interval: interval,
+		timeout:  timeout,
+		client:   httpclient.New("cluster", 0),
+
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cluster", Name: "peer_up",
+			Help: "1 if the peer O&M module answered the last poll, 0 otherwise.",
+		}, []string{"peer"}),
+		containers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cluster", Name: "peer_containers",
+			Help: "Testbed containers on the peer bench by state (running | stopped).",
+		}, []string{"peer", "state"}),
+		packets: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cluster", Name: "peer_capture_packets",
+			Help: "Packets captured by the peer since its capture pipeline started.",
+		}, []string{"peer"}),
+		milestones: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cluster", Name: "peer_milestones_achieved",
+			Help: "Lab milestones achieved on the peer in its current session.",
+		}, []string{"peer"}),
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "cluster", Name: "peer_info",
+			Help: "Always 1; labels carry the peer's active generation and topology status.",
+		}, []string{"peer", "generation", "status"}),
+
+		status:   make(map[string]PeerStatus, len(peers)),
+		answered: make(map[string]time.Time, len(peers)),
+	}
+	reg.MustRegister(a.up, a.containers, a.packets, a.milestones, a.info)
+	return a
+}
+
+// Run polls every peer immediately and then every interval until ctx is
+// cancelled.
+func (a *Aggregator) Run(ctx context.Context) {
+	log.Printf("🏫 Cluster aggregator started (%d peers, interval=%s)", len(a.peers), a.interval)
+	a.pollAll(ctx)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.pollAll(ctx)
+		case <-ctx.Done():
+			log.Printf("🏫 Cluster aggregator stopped")
+			return
+		}
+	}
+}
+
+// Overview returns the last known status of every peer, in configuration order.
+func (a *Aggregator) Overview() Overview {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	ov := Overview{Timestamp: time.Now().UTC().Format(time.RFC3339), Peers: make([]PeerStatus, 0, len(a.peers))}
+	for _, p := range a.peers {
+		st, ok := a.status[p.Name]
+		if !ok {
+			st = PeerStatus{Peer: p, Error: "not polled yet"}
+		}
+		if st.Up {
+			ov.PeersUp++
+		}
+		ov.Peers = append(ov.Peers, st)
+	}
+	return ov
+}
+
+func (a *Aggregator) pollAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range a.peers {
+		wg.Add(1)
+		go func(p Peer) {
+			defer wg.Done()
+			st := a.poll(ctx, p)
+			a.record(st)
+		}(p)
+	}
+	wg.Wait()
+
+	a.mu.Lock()
+	a.polled = time.Now()
+	a.mu.Unlock()
+}
+
+// Freshness returns when the om_cluster_* metrics were last refreshed, for
+// exporter.Ages: peer_up every round, the peer data only when the peer
+// answers — an unreachable peer keeps its last values, so their age is that
+// of the peer that has been silent the longest.
+func (a *Aggregator) Freshness() map[string]time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.polled.IsZero() {
+		return nil
+	}
+	out := map[string]time.Time{"om_cluster_peer_up": a.polled}
+	var oldest time.Time
+	for _, t := range a.answered {
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	if !oldest.IsZero() {
+		for _, f := range []string{"peer_containers", "peer_capture_packets", "peer_milestones_achieved"} {
+			out["om_cluster_"+f] = oldest
+		}
+	}
+	return out
+}
+
+// Subsets of the peer's API responses that the overview needs.
+type (
+	peerTopology struct {
+		Status     string `json:"status"`
+		Total      int    `json:"total"`
+		Running    int    `json:"running"`
+		Stopped    int    `json:"stopped"`
+		Containers []struct {
+			Domain     string `json:"domain"`
+			Generation string `json:"generation"`
+			State      string `json:"state"`
+		} `json:"containers"`
+	}
+	peerCapture struct {
+		Running      bool   `json:"running"`
+		Generation   string `json:"generation"`
+		PacketsTotal uint64 `json:"packets_total"`
+	}
+	peerMilestones struct {
+		Enabled  bool `json:"enabled"`
+		Achieved []struct {
+			ID string `json:"id"`
+		} `json:"achieved"`
+		Pending []struct {
+			ID string `json:"id"`
+		} `json:"pending"`
+	}
+)
+
+// poll fetches one peer. Only /topology is mandatory; capture and milestones
+// are optional subsystems on the peer.
+func (a *Aggregator) poll(ctx context.Context, p Peer) PeerStatus {
+	st := PeerStatus{Peer: p, LastPoll: time.Now().UTC().Format(time.RFC3339), Milestones: []string{}}
+
+	var topo peerTopology
+	if err := a.getJSON(ctx, p.URL+"/topology", &topo); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Up = true
+	st.Status = topo.Status
+	st.Containers, st.Running, st.Stopped = topo.Total, topo.Running, topo.Stopped
+	for _, c := range topo.Containers {
+		if c.Domain == "core" && c.State == "running" && c.Generation != "" {
+			st.Generation = c.Generation
+			break
+		}
+	}
+
+	var capt peerCapture
+	if err := a.getJSON(ctx, p.URL+"/capture/status", &capt); err == nil {
+		st.CaptureRunning = capt.Running
+		st.CapturePackets = capt.PacketsTotal
+		if capt.Generation != "" {
+			st.Generation = capt.Generation
+		}
+	}
+
+	var ms peerMilestones
+	if err := a.getJSON(ctx, p.URL+"/milestones", &ms); err == nil && ms.Enabled {
+		st.MilestonesAchieved = len(ms.Achieved)
+		st.MilestonesTotal = len(ms.Achieved) + len(ms.Pending)
+		for _, m := range ms.Achieved {
+			st.Milestones = append(st.Milestones, m.ID)
+		}
+		sort.Strings(st.Milestones)
+	}
+	return st
+}
+
+func (a *Aggregator) getJSON(ctx context.Context, url string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("⚠️  Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (a *Aggregator) record(st PeerStatus) {
+	a.mu.Lock()
+	prev, hadPrev := a.status[st.Name]
+	a.status[st.Name] = st
+	if st.Up {
+		a.answered[st.Name] = time.Now()
+	}
+	a.mu.Unlock()
+
+	if hadPrev && prev.Up && !st.Up {
+		log.Printf("⚠️  Cluster: peer %s unreachable: %s", st.Name, st.Error)
+	}
+
+	up := 0.0
+	if st.Up {
+		up = 1
+	}
+	a.up.WithLabelValues(st.Name).Set(up)
+	a.info.DeletePartialMatch(prometheus.Labels{"peer": st.Name})
+	if !st.Up {
+		return
+	}
+	a.containers.WithLabelValues(st.Name, "running").Set(float64(st.Running))
+	a.containers.WithLabelValues(st.Name, "stopped").Set(float64(st.Stopped))
+	a.packets.WithLabelValues(st.Name).Set(float64(st.CapturePackets))
+	a.milestones.WithLabelValues(st.Name).Set(float64(st.MilestonesAchieved))
+	a.info.WithLabelValues(st.Name, st.Generation, st.Status).Set(1)
+}
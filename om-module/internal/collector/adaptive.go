@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"math"
+	"time"
+)
+
+// Thresholds above which a container counts as busy between two samples.
+const (
+	adaptiveCPUDelta   = 5.0       // percentage points
+	adaptiveNetRate    = 10 * 1024 // bytes per second, RX + TX
+	adaptiveMemDeltaPc = 5.0       // percent of the previous working set
+)
+
+// adaptiveSchedule decides, per container, when stats are due. Busy
+// containers are sampled every min; each idle sample doubles the interval up
+// to max, so an attach storm is seen at full resolution while an idle lab
+// costs a fraction of the Docker API calls.
+type adaptiveSchedule struct {
+	min, max time.Duration
+	state    map[string]*adaptiveState // keyed by container name
+}
+
+type adaptiveState struct {
+	interval time.Duration
+	last     time.Time
+	next     time.Time
+}
+
+func newAdaptiveSchedule(min, max time.Duration) *adaptiveSchedule {
+	if max < min {
+		max = min
+	}
+	return &adaptiveSchedule{min: min, max: max, state: make(map[string]*adaptiveState)}
+}
+
+// due reports whether name should be sampled at now. Unknown containers are
+// always due.
+func (a *adaptiveSchedule) due(name string, now time.Time) bool {
+	st, ok := a.state[name]
+	return !ok || !now.Before(st.next)
+}
+
+// interval returns the current sampling interval for name.
+func (a *adaptiveSchedule) interval(name string) time.Duration {
+	if st, ok := a.state[name]; ok {
+		return st.interval
+	}
+	return a.min
+}
+
+// update records a fresh sample of cur (prev is the previous sample, nil on
+// first sight) and schedules the next one.
+func (a *adaptiveSchedule) update(prev, cur *ContainerData, now time.Time) {
+	st, ok := a.state[cur.Name]
+	if !ok {
+		a.state[cur.Name] = &adaptiveState{interval: a.min, last: now, next: now.Add(a.min)}
+		return
+	}
+
+	if prev != nil && busy(prev, cur, now.Sub(st.last)) {
+		st.interval = a.min
+	} else {
+		st.interval *= 2
+		if st.interval > a.max {
+			st.interval = a.max
+		}
+	}
+	st.last = now
+	st.next = now.Add(st.interval)
+}
+
+// prune forgets containers that no longer exist.
+func (a *adaptiveSchedule) prune(data map[string]*ContainerData) {
+	for name := range a.state {
+		if _, ok := data[name]; !ok {
+			delete(a.state, name)
+		}
+	}
+}
+
+// busy reports whether the metrics of a container changed rapidly between
+// two samples taken elapsed apart.
+func busy(prev, cur *ContainerData, elapsed time.Duration) bool {
+	if math.Abs(cur.CPUPercent-prev.CPUPercent) >= adaptiveCPUDelta {
+		return true
+	}
+	if cur.PIDs != prev.PIDs {
+		return true
+	}
+	if prev.MemoryUsageB > 0 {
+		delta := math.Abs(float64(cur.MemoryUsageB) - float64(prev.MemoryUsageB))
+		if delta/float64(prev.MemoryUsageB)*100 >= adaptiveMemDeltaPc {
+			return true
+		}
+	}
+	if elapsed > 0 && cur.NetworkRxBytes+cur.NetworkTxBytes >= prev.NetworkRxBytes+prev.NetworkTxBytes {
+		bytes := float64(cur.NetworkRxBytes + cur.NetworkTxBytes - prev.NetworkRxBytes - prev.NetworkTxBytes)
+		if bytes/elapsed.Seconds() >= adaptiveNetRate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Docker Compose labels stamped on every container started by `docker compose`.
+const (
+	labelComposeProject = "com.docker.compose.project"
+	labelComposeService = "com.docker.compose.service"
+	labelComposeNumber  = "com.docker.compose.container-number"
+)
+
+// ServiceGroup groups the containers of one Compose service. A service that
+// was scaled (`docker compose up --scale ue=3`) yields a single group with
+// one entry per replica.
+type ServiceGroup struct {
+	ComposeProject string
+	Service        string
+	Domain         string
+	NF             string
+	NFKind         NFKind
+	Generation     string
+	Owner          string
+	Contact        string
+	Description    string
+	Replicas       int
+	Running        int
+	Containers     []string // container names, ordered by replica number
+}
+
+// Services returns the snapshot grouped by Compose project and service.
+// Containers without Compose labels are grouped under their container name
+// so that manually started containers still appear once.
+func (s *Snapshot) Services() []ServiceGroup { return s.View().Services() }
+
+// Services returns the view grouped by Compose project and service, as
+// Snapshot.Services.
+func (v *View) Services() []ServiceGroup {
+	type key struct{ project, service string }
+
+	byKey := make(map[key][]*ContainerData)
+	for _, cd := range v.data {
+		k := key{cd.ComposeProject, cd.Service}
+		if k.service == "" {
+			k.service = cd.Name
+		}
+		byKey[k] = append(byKey[k], cd)
+	}
+
+	groups := make([]ServiceGroup, 0, len(byKey))
+	for k, members := range byKey {
+		sort.Slice(members, func(i, j int) bool { return members[i].Replica < members[j].Replica })
+
+		g := ServiceGroup{
+			ComposeProject: k.project,
+			Service:        k.service,
+			Domain:         members[0].Domain,
+			NF:             members[0].NF,
+			NFKind:         members[0].NFKind,
+			Generation:     members[0].Generation,
+			Owner:          members[0].Owner,
+			Contact:        members[0].Contact,
+			Description:    members[0].Description,
+			Replicas:       len(members),
+		}
+		for _, cd := range members {
+			if cd.State == "running" {
+				g.Running++
+			}
+			g.Containers = append(g.Containers, cd.Name)
+		}
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].ComposeProject != groups[j].ComposeProject {
+			return groups[i].ComposeProject < groups[j].ComposeProject
+		}
+		return groups[i].Service < groups[j].Service
+	})
+	return groups
+}
+
+// replicaNumber parses the container-number label. Containers not started by
+// Compose (or with a malformed label) count as replica 1.
+func replicaNumber(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// assignComponents sets ContainerData.Component for every container in data.
+// Unscaled services keep the plain service name ("amf"); scaled services get
+// one component per replica ("nr_ue_1", "nr_ue_2") so they stay distinguishable
+// in metrics and topology without relying on generated container names.
+func assignComponents(data map[string]*ContainerData) {
+	replicas := make(map[string]int)
+	for _, cd := range data {
+		if cd.Service != "" {
+			replicas[cd.ComposeProject+"/"+cd.Service]++
+		}
+	}
+
+	for _, cd := range data {
+		switch {
+		case cd.Service == "":
+			cd.Component = cd.Name
+		case replicas[cd.ComposeProject+"/"+cd.Service] > 1 || cd.Replica > 1:
+			cd.Component = fmt.Sprintf("%s_%d", cd.Service, cd.Replica)
+		default:
+			cd.Component = cd.Service
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/ownership"
 	"github.com/Parz1val02/OM_module/internal/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -16,6 +17,7 @@ import (
 const (
 	DomainCore          = "core"
 	DomainRAN           = "ran"
+	DomainIMS           = "ims"
 	DomainInfra         = "infra"
 	DomainObservability = "observability"
 )
@@ -31,17 +33,47 @@ type ContainerData struct {
 	Image string
 
 	// om.* taxonomy labels (sourced directly from container labels)
-	Domain     string // om.domain  → core | ran | infra | observability
-	NF         string // om.nf      → amf | smf | upf | mme | gnb | enb | ue | …
+	Domain     string // om.domain  → core | ran | ims | infra | observability
+	NF         string // om.nf      → amf | smf | upf | mme | gnb | enb | ue | pcscf | …
 	Generation string // om.generation → 4g | 5g | none
 	Project    string // om.project → open5gs | srsran | srslte | ueransim | grafana | …
 
-	// Resource metrics (zero if container is not running)
+	// NFKind is the type of NF the container runs (see classify), and
+	// NFKindSource where it was found: label | component_name | service | image.
+	NFKind       NFKind
+	NFKindSource string
+
+	// Compose identity (sourced from com.docker.compose.* labels)
+	ComposeProject string // com.docker.compose.project
+	Service        string // com.docker.compose.service
+	Replica        int    // com.docker.compose.container-number (1 when not scaled)
+	Component      string // service name, or service_<n> when the service is scaled
+
+	// MetricsAddress is the container:port its prometheus.scrape and
+	// prometheus.port labels ask Prometheus to scrape, or "".
+	MetricsAddress string
+
+	// Ownership from the owners file (empty when none is configured)
+	Owner       string
+	Contact     string
+	Description string
+
+	// Resource metrics (zero if container is not running). A failed
+	// sample keeps the previous values; StatsAt tells how old they are.
 	CPUPercent     float64
+	CPUSeconds     float64 // CPU time used since the container started
 	MemoryUsageB   uint64
-	NetworkRxBytes uint64
-	NetworkTxBytes uint64
+	MemoryTotalB   uint64           // usage including the page cache
+	NetworkRxBytes uint64           // summed over Interfaces
+	NetworkTxBytes uint64           // summed over Interfaces
+	Interfaces     []InterfaceStats // per interface, sorted by name
 	PIDs           uint64
+	StatsAt        time.Time // last successful sample; zero before the first
+
+	// CollectInterval is how often the resource metrics above are refreshed:
+	// the fixed collector interval, or the container's current interval in
+	// adaptive mode.
+	CollectInterval time.Duration
 }
 
 // HealthValue maps Docker container state to a numeric health value.
@@ -62,37 +94,81 @@ func (cd *ContainerData) HealthValue() float64 {
 
 // Snapshot is a thread-safe read-only view of the latest collected data.
 type Snapshot struct {
-	mu   sync.RWMutex
-	data map[string]*ContainerData // keyed by container Name
+	mu        sync.RWMutex
+	data      map[string]*ContainerData // keyed by container Name
+	exporters []Exporter
+	history   []HealthEvent
+	version   uint64
+	updated   time.Time
 }
 
 func newSnapshot() *Snapshot { return &Snapshot{data: make(map[string]*ContainerData)} }
 
+// NewSnapshot returns a snapshot holding containers as if one collection
+// cycle had found them, for code that serves the API without a collector,
+// such as tests.
+func NewSnapshot(containers ...*ContainerData) *Snapshot {
+	s := newSnapshot()
+	data := make(map[string]*ContainerData, len(containers))
+	for _, cd := range containers {
+		data[cd.Name] = cd
+	}
+	s.set(data, nil, nil)
+	return s
+}
+
 // All returns a copy of the current snapshot map.
 func (s *Snapshot) All() map[string]*ContainerData {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	out := make(map[string]*ContainerData, len(s.data))
-	for k, v := range s.data {
-		cp := *v
-		out[k] = &cp
-	}
-	return out
+	return copyData(s.data)
 }
 
-func (s *Snapshot) set(data map[string]*ContainerData) {
+// Version increases every time the collector stores a new snapshot, so
+// readers can tell whether data derived from it is still current.
+func (s *Snapshot) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+func (s *Snapshot) set(data map[string]*ContainerData, exporters []Exporter, events []HealthEvent) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.data = data
+	s.exporters = exporters
+	s.record(events)
+	s.version++
+	s.updated = time.Now()
+}
+
+// Updated returns when the collector last stored a snapshot (container list
+// and states), or the zero time before the first cycle.
+func (s *Snapshot) Updated() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updated
 }
 
 // Collector discovers containers and collects their resource metrics
-// on a fixed interval. It only considers containers that carry om.* labels.
+// on a fixed interval, or per container within bounds in adaptive mode.
+// It only considers containers that carry om.* labels.
 type Collector struct {
 	docker   *dockerclient.Client
 	project  string
 	interval time.Duration
 	snap     *Snapshot
+	adaptive *adaptiveSchedule // nil in fixed-interval mode
+	owners   *ownership.Map    // nil when no owners file is configured
+	ifaces   *interfaceMap
+	kinds    map[string]classified // by container ID; labels and env are fixed at creation
+
+	// detectExporters makes discovery look for cAdvisor and node_exporter
+	// containers; while cAdvisor runs, Docker stats are not sampled.
+	detectExporters bool
+	externalStats   bool // cAdvisor was running in the previous cycle
+
+	onTopologyChange func() // nil unless OnTopologyChange was called
 }
 
 // New creates a Collector. project is the Docker Compose project name used
@@ -103,15 +179,50 @@ func New(docker *dockerclient.Client, project string, interval time.Duration) *C
 		project:  project,
 		interval: interval,
 		snap:     newSnapshot(),
+		ifaces:   newInterfaceMap(docker),
+		kinds:    make(map[string]classified),
 	}
 }
 
+// EnableAdaptive switches the collector to adaptive mode: containers are
+// listed every min, and each container's stats are refreshed every min while
+// its metrics change rapidly, backing off to max while it is idle.
+// Must be called before Run.
+func (c *Collector) EnableAdaptive(min, max time.Duration) {
+	c.adaptive = newAdaptiveSchedule(min, max)
+	c.interval = min
+}
+
+// SetOwners attributes every component to the owner m maps it to.
+// Must be called before Run.
+func (c *Collector) SetOwners(m *ownership.Map) {
+	c.owners = m
+}
+
+// DetectExporters makes discovery recognise standard exporter containers
+// (see Exporter) and leave the container resource metrics to cAdvisor while
+// it runs. Must be called before Run.
+func (c *Collector) DetectExporters() {
+	c.detectExporters = true
+}
+
+// OnTopologyChange registers fn to be called after every cycle in which a
+// container appeared, disappeared or changed state. fn runs on the
+// collection goroutine and must not block. Must be called before Run.
+func (c *Collector) OnTopologyChange(fn func()) {
+	c.onTopologyChange = fn
+}
+
 // Snapshot returns the live, thread-safe snapshot reference.
 func (c *Collector) Snapshot() *Snapshot { return c.snap }
 
 // Run starts the collection loop. It blocks until ctx is cancelled.
 func (c *Collector) Run(ctx context.Context) {
-	log.Printf("📦 Collector started (project=%q, interval=%s)", c.project, c.interval)
+	if c.adaptive != nil {
+		log.Printf("📦 Collector started (project=%q, adaptive interval=%s–%s)", c.project, c.adaptive.min, c.adaptive.max)
+	} else {
+		log.Printf("📦 Collector started (project=%q, interval=%s)", c.project, c.interval)
+	}
 	c.collect(ctx) // run immediately on startup
 	ticker := time.NewTicker(c.interval)
 	defer ticker.Stop()
@@ -130,7 +241,7 @@ func (c *Collector) Run(ctx context.Context) {
 //
 // Tracing structure:
 //
-//	collector.collect_cycle          (root — one trace per 15s tick)
+//	collector.collect_cycle          (root — one trace per tick)
 //	  ├── collector.list_containers  (single Docker API call)
 //	  └── collector.get_stats        (one child span per running container)
 func (c *Collector) collect(ctx context.Context) {
@@ -152,9 +263,43 @@ func (c *Collector) collect(ctx context.Context) {
 	listSpan.SetAttributes(attribute.Int("containers.discovered", len(containers)))
 	listSpan.End()
 
+	var exporters []Exporter
+	if c.detectExporters {
+		for _, ct := range containers {
+			if e, ok := detectExporter(ct.Image, ct.Labels); ok {
+				e.Container, e.State = ct.Name, ct.State
+				exporters = append(exporters, e)
+			}
+		}
+	}
+	externalStats := false
+	for _, e := range exporters {
+		if e.Kind == ExporterCAdvisor && e.Running() {
+			externalStats = true
+		}
+	}
+	if externalStats != c.externalStats {
+		if externalStats {
+			log.Printf("📦 Collector: cAdvisor is running — container resource metrics left to it")
+		} else {
+			log.Printf("📦 Collector: cAdvisor gone — sampling container resource metrics again")
+		}
+		c.externalStats = externalStats
+	}
+
+	if !externalStats {
+		c.ifaces.refresh(ctx)
+		c.ifaces.prune(containers)
+	}
+
 	newData := make(map[string]*ContainerData, len(containers))
+	listed := make(map[string]bool, len(containers))
+	previous := c.snap.All()
+	now := time.Now()
+	sampled := 0
 
 	for _, ct := range containers {
+		listed[ct.ID] = true
 		cd := &ContainerData{
 			ID:    ct.ID,
 			Name:  ct.Name,
@@ -166,6 +311,12 @@ func (c *Collector) collect(ctx context.Context) {
 			NF:         ct.Labels["om.nf"],
 			Generation: ct.Labels["om.generation"],
 			Project:    ct.Labels["om.project"],
+
+			ComposeProject: ct.Labels[labelComposeProject],
+			Service:        ct.Labels[labelComposeService],
+			Replica:        replicaNumber(ct.Labels[labelComposeNumber]),
+
+			MetricsAddress: metricsAddress(ct.Name, ct.Labels),
 		}
 
 		// Skip containers with no om.* labels — they don't belong to the
@@ -173,9 +324,28 @@ func (c *Collector) collect(ctx context.Context) {
 		if cd.Domain == "" && cd.NF == "" {
 			continue
 		}
+		cd.NFKind, cd.NFKindSource = c.classify(ctx, ct)
+
+		cd.CollectInterval = c.interval
+
+		// cAdvisor exports the resource metrics; only identity and state
+		// are kept.
+		if externalStats {
+			newData[ct.Name] = cd
+			continue
+		}
+
+		// In adaptive mode, containers that are not due keep their last sample.
+		if c.adaptive != nil && ct.State == "running" && !c.adaptive.due(ct.Name, now) {
+			keepSample(cd, previous[ct.Name])
+			cd.CollectInterval = c.adaptive.interval(ct.Name)
+			newData[ct.Name] = cd
+			continue
+		}
 
 		// Only collect resource stats for running containers.
 		if ct.State == "running" {
+			sampled++
 			// One child span per container stats call so slow Docker API
 			// calls are individually visible in the Tempo waterfall.
 			_, statsSpan := tracing.Tracer().Start(ctx, "collector.get_stats")
@@ -186,29 +356,61 @@ func (c *Collector) collect(ctx context.Context) {
 				attribute.String("container.generation", cd.Generation),
 			)
 
-			if stats, err := c.docker.GetStats(ctx, ct.ID); err == nil {
+			stats, err := c.docker.GetStats(ctx, ct.ID)
+			if err == nil {
 				cd.CPUPercent = calcCPUPercent(stats)
+				cd.CPUSeconds = float64(stats.CPUStats.CPUUsage.TotalUsage) / 1e9
 				cd.MemoryUsageB = memUsage(stats)
+				cd.MemoryTotalB = stats.MemoryStats.Usage
 				cd.NetworkRxBytes, cd.NetworkTxBytes = sumNetwork(stats)
+				cd.Interfaces = c.ifaces.interfaces(ctx, ct, stats)
 				cd.PIDs = stats.PidsStats.Current
+				cd.StatsAt = now
 
 				statsSpan.SetAttributes(
 					attribute.Float64("container.cpu_percent", cd.CPUPercent),
 					attribute.Int("container.memory_bytes", int(cd.MemoryUsageB)),
 					attribute.Int("container.pids", int(cd.PIDs)),
 				)
-			} else if ctx.Err() == nil {
-				statsSpan.RecordError(err)
-				statsSpan.SetStatus(codes.Error, err.Error())
-				log.Printf("⚠️  Collector: GetStats(%s) error: %v", ct.Name, err)
+			} else {
+				// Keep the last sample rather than exporting zeros: its
+				// growing age marks it as stale.
+				keepSample(cd, previous[ct.Name])
+				if ctx.Err() == nil {
+					statsSpan.RecordError(err)
+					statsSpan.SetStatus(codes.Error, err.Error())
+					log.Printf("⚠️  Collector: GetStats(%s) error: %v", ct.Name, err)
+				}
 			}
 
 			statsSpan.End()
+
+			if c.adaptive != nil {
+				// A failed sample is retried on the next cycle.
+				if err == nil {
+					c.adaptive.update(previous[ct.Name], cd, now)
+				}
+				cd.CollectInterval = c.adaptive.interval(ct.Name)
+			}
 		}
 
 		newData[ct.Name] = cd
 	}
 
+	for id := range c.kinds {
+		if !listed[id] {
+			delete(c.kinds, id)
+		}
+	}
+	assignComponents(newData)
+	for _, cd := range newData {
+		info := c.owners.Lookup(cd.Component, cd.Service)
+		cd.Owner, cd.Contact, cd.Description = info.Owner, info.Contact, info.Description
+	}
+	if c.adaptive != nil {
+		c.adaptive.prune(newData)
+	}
+
 	// Summarise the cycle on the root span.
 	running := 0
 	for _, cd := range newData {
@@ -219,9 +421,60 @@ func (c *Collector) collect(ctx context.Context) {
 	cycleSpan.SetAttributes(
 		attribute.Int("cycle.containers_total", len(newData)),
 		attribute.Int("cycle.containers_running", running),
+		attribute.Int("cycle.containers_sampled", sampled),
+		attribute.Int("cycle.exporters", len(exporters)),
 	)
 
-	c.snap.set(newData)
+	events := healthChanges(previous, newData, now)
+	c.snap.set(newData, exporters, events)
+	if len(events) > 0 && c.onTopologyChange != nil {
+		c.onTopologyChange()
+	}
+}
+
+// classified is the kind of a container and where it was found.
+type classified struct {
+	kind   NFKind
+	source string
+}
+
+// classify returns the kind of ct, classified once per container.
+func (c *Collector) classify(ctx context.Context, ct dockerclient.ContainerInfo) (NFKind, string) {
+	if k, ok := c.kinds[ct.ID]; ok {
+		return k.kind, k.source
+	}
+	retry := false
+	kind, source := classify(ct, func() map[string]string {
+		env, err := c.docker.Env(ctx, ct.ID)
+		if err != nil {
+			// Classified without it this cycle, tried again on the next.
+			retry = true
+			if ctx.Err() == nil {
+				log.Printf("⚠️  Collector: environment of %s not read: %v", ct.Name, err)
+			}
+			return nil
+		}
+		return env
+	})
+	if !retry {
+		c.kinds[ct.ID] = classified{kind, source}
+	}
+	return kind, source
+}
+
+// keepSample copies the resource metrics of prev, if any, into cd.
+func keepSample(cd, prev *ContainerData) {
+	if prev == nil {
+		return
+	}
+	cd.CPUPercent = prev.CPUPercent
+	cd.CPUSeconds = prev.CPUSeconds
+	cd.MemoryUsageB = prev.MemoryUsageB
+	cd.MemoryTotalB = prev.MemoryTotalB
+	cd.NetworkRxBytes, cd.NetworkTxBytes = prev.NetworkRxBytes, prev.NetworkTxBytes
+	cd.Interfaces = prev.Interfaces
+	cd.PIDs = prev.PIDs
+	cd.StatsAt = prev.StatsAt
 }
 
 // --- helper calculations -------------------------------------------------
@@ -263,3 +516,13 @@ func sumNetwork(s *dockerclient.RawStats) (rx, tx uint64) {
 	}
 	return
 }
+
+// metricsAddress returns the address the prometheus.* labels of a container
+// advertise, as the docker-services job of the Prometheus configuration
+// builds it.
+func metricsAddress(name string, labels map[string]string) string {
+	if labels["prometheus.scrape"] != "true" || labels["prometheus.port"] == "" {
+		return ""
+	}
+	return name + ":" + labels["prometheus.port"]
+}
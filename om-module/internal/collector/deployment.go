@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"slices"
+	"sort"
+)
+
+// Deployment is what the lab is made of, as far as its containers tell:
+// the kinds of NF it runs, the core generations they belong to and the
+// reference points between them. Educational content uses it to leave out
+// or mark what a student cannot see in their own lab.
+type Deployment struct {
+	Kinds       []NFKind `json:"kinds"`
+	Generations []string `json:"generations"` // "4g", "5g"
+	// ReferencePoints are the interfaces Links draws at least once.
+	ReferencePoints []ReferencePoint `json:"reference_points"`
+}
+
+// Deployment returns the deployment of the containers of the view, stopped
+// ones included: a stopped NF is part of the lab, only down.
+func (v *View) Deployment() Deployment {
+	d := Deployment{Kinds: []NFKind{}, Generations: []string{}, ReferencePoints: []ReferencePoint{}}
+	byKind := containersByKind(v.data)
+	for k := range byKind {
+		d.Kinds = append(d.Kinds, k)
+	}
+	sort.Slice(d.Kinds, func(i, j int) bool { return d.Kinds[i] < d.Kinds[j] })
+
+	generations := make(map[string]bool)
+	for _, cd := range v.data {
+		if cd.NFKind == KindUnknown {
+			continue
+		}
+		if g := coreGeneration(cd); g != "" {
+			generations[g] = true
+		}
+	}
+	for _, g := range []string{"4g", "5g"} {
+		if generations[g] {
+			d.Generations = append(d.Generations, g)
+		}
+	}
+
+	for _, rp := range referencePoints {
+		if len(rp.links(byKind)) > 0 {
+			d.ReferencePoints = append(d.ReferencePoints, rp)
+		}
+	}
+	return d
+}
+
+// Has reports whether the lab runs an NF of any of kinds.
+func (d Deployment) Has(kinds ...NFKind) bool {
+	for _, k := range kinds {
+		if slices.Contains(d.Kinds, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasGeneration reports whether the lab runs a core of generation g; ""
+// and "none" are always there.
+func (d Deployment) HasGeneration(g string) bool {
+	if g == "" || g == "none" {
+		return true
+	}
+	return slices.Contains(d.Generations, g)
+}
+
+// HasReferencePoint reports whether the lab has rp between two of its
+// containers.
+func (d Deployment) HasReferencePoint(rp ReferencePoint) bool {
+	return slices.Contains(d.ReferencePoints, rp)
+}
@@ -0,0 +1,100 @@
+package collector
+
+import "sort"
+
+// Standard exporters the collector recognises among the discovered
+// containers. When one of them runs, the metrics it already provides are
+// left to it instead of being collected again under conflicting names.
+const (
+	ExporterCAdvisor     = "cadvisor"
+	ExporterNodeExporter = "node-exporter"
+)
+
+// exporterKinds maps a kind to the image names (without registry or tag)
+// it is recognised by, its default metrics port and the internal metrics it
+// replaces. An om.nf label equal to the kind is recognised too.
+var exporterKinds = []struct {
+	kind     string
+	images   []string
+	port     int
+	replaces []string
+}{
+	{ExporterCAdvisor, []string{"cadvisor"}, 8080, []string{
+		"container_cpu_usage_percent",
+		"container_memory_usage_bytes",
+		"container_network_rx_bytes_total",
+		"container_network_tx_bytes_total",
+		"container_network_counter_resets_total",
+		"container_pids",
+		"container_collect_interval_seconds",
+	}},
+	// The module has no host collector, so node_exporter replaces nothing;
+	// it is detected so its scrape config and dashboard can be offered.
+	{ExporterNodeExporter, []string{"node-exporter", "node_exporter"}, 9100, nil},
+}
+
+// Exporter is a standard exporter container found during discovery.
+type Exporter struct {
+	Kind      string   `json:"kind"` // cadvisor | node-exporter
+	Container string   `json:"container"`
+	Image     string   `json:"image"`
+	State     string   `json:"state"`
+	Port      int      `json:"port"`
+	Replaces  []string `json:"replaces"` // internal metrics not exported while it runs
+}
+
+// Running reports whether the exporter container is running.
+func (e Exporter) Running() bool { return e.State == "running" }
+
+// detectExporter returns the exporter kind of a container, or ok=false.
+func detectExporter(image string, labels map[string]string) (Exporter, bool) {
+	name := imageName(image)
+	for _, k := range exporterKinds {
+		match := labels["om.nf"] == k.kind
+		for _, img := range k.images {
+			match = match || name == img
+		}
+		if match {
+			return Exporter{Kind: k.kind, Image: image, Port: k.port, Replaces: append([]string{}, k.replaces...)}, true
+		}
+	}
+	return Exporter{}, false
+}
+
+// Exporters returns the standard exporters found in the last discovery,
+// sorted by kind and container name.
+func (s *Snapshot) Exporters() []Exporter { return s.View().Exporters() }
+
+// Exporters returns the exporters of the view, as Snapshot.Exporters.
+func (v *View) Exporters() []Exporter {
+	out := append([]Exporter{}, v.exporters...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Container < out[j].Container
+	})
+	return out
+}
+
+// ExternalContainerStats reports whether a running cAdvisor provides the
+// container resource metrics, in which case the collector does not sample
+// Docker stats and the exporter leaves those metrics out.
+func (s *Snapshot) ExternalContainerStats() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return externalContainerStats(s.exporters)
+}
+
+// ExternalContainerStats reports whether cAdvisor provided the container
+// metrics of the view, as Snapshot.ExternalContainerStats.
+func (v *View) ExternalContainerStats() bool { return externalContainerStats(v.exporters) }
+
+func externalContainerStats(exporters []Exporter) bool {
+	for _, e := range exporters {
+		if e.Kind == ExporterCAdvisor && e.Running() {
+			return true
+		}
+	}
+	return false
+}
@@ -2,11 +2,13 @@ package collector
 
 // ActiveGeneration inspects the current snapshot and returns the single
 // generation ("4g" or "5g") that has running core-domain containers.
-func (s *Snapshot) ActiveGeneration() string {
-	all := s.All()
+func (s *Snapshot) ActiveGeneration() string { return s.View().ActiveGeneration() }
 
+// ActiveGeneration returns the single generation with running core-domain
+// containers in the view, as Snapshot.ActiveGeneration.
+func (v *View) ActiveGeneration() string {
 	generations := make(map[string]bool)
-	for _, cd := range all {
+	for _, cd := range v.data {
 		if cd.Domain == DomainCore && cd.State == "running" && cd.Generation != "" {
 			generations[cd.Generation] = true
 		}
@@ -24,8 +26,12 @@ func (s *Snapshot) ActiveGeneration() string {
 // NFByName returns the om.nf label value for a container with the given name.
 // Returns the container name itself if no om.nf label is found, and "" if
 // no container with that name exists in the snapshot.
-func (s *Snapshot) NFByName(containerName string) string {
-	for _, cd := range s.All() {
+func (s *Snapshot) NFByName(containerName string) string { return s.View().NFByName(containerName) }
+
+// NFByName returns the om.nf label of a container of the view, as
+// Snapshot.NFByName.
+func (v *View) NFByName(containerName string) string {
+	for _, cd := range v.data {
 		if cd.Name == containerName {
 			if cd.NF != "" {
 				return cd.NF
@@ -38,12 +44,19 @@ func (s *Snapshot) NFByName(containerName string) string {
 
 // NameToNFMap returns a map of container name → om.nf label for all containers
 // currently in the snapshot. Used by the correlator to resolve IP → NF name
-// by joining with the Docker network IP map.
-func (s *Snapshot) NameToNFMap() map[string]string {
-	all := s.All()
-	result := make(map[string]string, len(all))
-	for _, cd := range all {
+// by joining with the Docker network IP map. Containers without an om.nf label
+// fall back to their Compose component name.
+func (s *Snapshot) NameToNFMap() map[string]string { return s.View().NameToNFMap() }
+
+// NameToNFMap returns the container name → NF map of the view, as
+// Snapshot.NameToNFMap.
+func (v *View) NameToNFMap() map[string]string {
+	result := make(map[string]string, len(v.data))
+	for _, cd := range v.data {
 		nf := cd.NF
+		if nf == "" {
+			nf = cd.Component
+		}
 		if nf == "" {
 			nf = cd.Name
 		}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"sort"
+	"time"
+)
+
+// maxHealthEvents bounds the health history kept in the snapshot.
+const maxHealthEvents = 200
+
+// HealthEvent is a container state change seen between two collection
+// cycles. From is empty for a container that appeared, To for one that is
+// gone.
+type HealthEvent struct {
+	Time      string  `json:"time"`
+	Container string  `json:"container"`
+	NF        string  `json:"nf,omitempty"`
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Health    float64 `json:"health_status"` // HealthValue after the change; -1 when gone
+}
+
+// healthChanges compares two cycles. The first cycle (empty previous)
+// records nothing, so a restart of the module does not flood the history.
+func healthChanges(previous, current map[string]*ContainerData, now time.Time) []HealthEvent {
+	if len(previous) == 0 {
+		return nil
+	}
+	ts := now.UTC().Format(time.RFC3339)
+	var events []HealthEvent
+	for name, cd := range current {
+		prev, ok := previous[name]
+		switch {
+		case !ok:
+			events = append(events, HealthEvent{Time: ts, Container: name, NF: cd.NF, To: cd.State, Health: cd.HealthValue()})
+		case prev.State != cd.State:
+			events = append(events, HealthEvent{Time: ts, Container: name, NF: cd.NF, From: prev.State, To: cd.State, Health: cd.HealthValue()})
+		}
+	}
+	for name, prev := range previous {
+		if _, ok := current[name]; !ok {
+			events = append(events, HealthEvent{Time: ts, Container: name, NF: prev.NF, From: prev.State, Health: -1})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Container < events[j].Container })
+	return events
+}
+
+// HealthHistory returns the recent container state changes, oldest first.
+func (s *Snapshot) HealthHistory() []HealthEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]HealthEvent{}, s.history...)
+}
+
+// record appends events to the history, dropping the oldest beyond
+// maxHealthEvents. Callers hold s.mu.
+func (s *Snapshot) record(events []HealthEvent) {
+	s.history = append(s.history, events...)
+	if over := len(s.history) - maxHealthEvents; over > 0 {
+		s.history = append([]HealthEvent(nil), s.history[over:]...)
+	}
+}
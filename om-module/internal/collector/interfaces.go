@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+)
+
+// LabelReferencePoint is the Docker network label naming the 3GPP reference
+// point the network carries (e.g. "n3", "n6", "s1u", "sbi"). Interfaces on a
+// network without it are exported with an empty reference_point.
+const LabelReferencePoint = "om.reference_point"
+
+// ifaceScript prints "<interface> <mac>" for every interface of a container.
+const ifaceScript = `for i in /sys/class/net/*; do echo "${i##*/} $(cat "$i/address")"; done`
+
+// InterfaceStats are the counters of one network interface of a container.
+type InterfaceStats struct {
+	Name           string // interface inside the container, e.g. eth0
+	Network        string // Docker network it is attached to, if known
+	ReferencePoint string // om.reference_point label of the network
+	RxBytes        uint64
+	TxBytes        uint64
+}
+
+// interfaceMap resolves the interfaces Docker stats report to the networks
+// they are attached to. Docker keys the stats by interface name but
+// describes the attachments by network and MAC address; with a single
+// network there is nothing to resolve, otherwise the MAC addresses of the
+// interfaces are read once per container from /sys/class/net.
+type interfaceMap struct {
+	docker  *dockerclient.Client
+	byID    map[string]resolvedIfaces // by container ID
+	refs    map[string]string         // network → reference point
+	refsErr bool                      // the last network list failed (logged once)
+}
+
+type resolvedIfaces struct {
+	key    string            // the attachments they were resolved for
+	ifaces map[string]string // interface → network
+}
+
+func newInterfaceMap(docker *dockerclient.Client) *interfaceMap {
+	return &interfaceMap{docker: docker, byID: make(map[string]resolvedIfaces)}
+}
+
+// refresh reads the reference point labels of the Docker networks. On error
+// the previous labels are kept.
+func (m *interfaceMap) refresh(ctx context.Context) {
+	labels, err := m.docker.NetworkLabels(ctx)
+	if err != nil {
+		if !m.refsErr && ctx.Err() == nil {
+			log.Printf("⚠️  Collector: network list error: %v", err)
+		}
+		m.refsErr = true
+		return
+	}
+	m.refsErr = false
+	m.refs = make(map[string]string, len(labels))
+	for name, l := range labels {
+		if rp := l[LabelReferencePoint]; rp != "" {
+			m.refs[name] = rp
+		}
+	}
+}
+
+// interfaces breaks the network stats of ct down per interface, sorted by
+// name. The loopback interface is never reported by Docker.
+func (m *interfaceMap) interfaces(ctx context.Context, ct dockerclient.ContainerInfo, s *dockerclient.RawStats) []InterfaceStats {
+	if len(s.Networks) == 0 {
+		return nil
+	}
+	var only string
+	if len(ct.Networks) == 1 {
+		for net := range ct.Networks {
+			only = net
+		}
+	}
+	var networks map[string]string
+	if only == "" {
+		networks = m.resolve(ctx, ct)
+	}
+	out := make([]InterfaceStats, 0, len(s.Networks))
+	for name, n := range s.Networks {
+		net := networks[name]
+		if only != "" {
+			net = only
+		}
+		out = append(out, InterfaceStats{
+			Name:           name,
+			Network:        net,
+			ReferencePoint: m.refs[net],
+			RxBytes:        n.RxBytes,
+			TxBytes:        n.TxBytes,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// resolve returns interface → network for a container attached to several
+// networks. The result is kept until the container is attached to another
+// set of networks.
+func (m *interfaceMap) resolve(ctx context.Context, ct dockerclient.ContainerInfo) map[string]string {
+	byMAC := make(map[string]string, len(ct.Networks))
+	attachments := make([]string, 0, len(ct.Networks))
+	for net, mac := range ct.Networks {
+		byMAC[strings.ToLower(mac)] = net
+		attachments = append(attachments, net+"="+strings.ToLower(mac))
+	}
+	sort.Strings(attachments)
+	key := strings.Join(attachments, ",")
+	if cached, ok := m.byID[ct.ID]; ok && cached.key == key {
+		return cached.ifaces
+	}
+
+	resolved := make(map[string]string)
+	out, code, err := m.docker.Exec(ctx, ct.Name, []string{"sh", "-c", ifaceScript})
+	if err != nil || code != 0 {
+		// Cached anyway: a container without a shell would fail every cycle.
+		if ctx.Err() == nil {
+			log.Printf("⚠️  Collector: cannot map the interfaces of %s to networks (exit %d, %v)", ct.Name, code, err)
+		}
+	} else {
+		for _, line := range strings.Split(out, "\n") {
+			f := strings.Fields(line)
+			if len(f) == 2 && byMAC[strings.ToLower(f[1])] != "" {
+				resolved[f[0]] = byMAC[strings.ToLower(f[1])]
+			}
+		}
+	}
+	m.byID[ct.ID] = resolvedIfaces{key: key, ifaces: resolved}
+	return resolved
+}
+
+// prune forgets the containers that no longer exist.
+func (m *interfaceMap) prune(containers []dockerclient.ContainerInfo) {
+	seen := make(map[string]bool, len(containers))
+	for _, ct := range containers {
+		seen[ct.ID] = true
+	}
+	for id := range m.byID {
+		if !seen[id] {
+			delete(m.byID, id)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package collector
+
+import "sort"
+
+// Link is an interface between two containers of the lab, named after its
+// 3GPP reference point (N2, S1-MME, …).
+type Link struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Interface string `json:"interface"`
+}
+
+// ReferencePoint is an interface the reference architecture defines
+// between two kinds of NF.
+type ReferencePoint struct {
+	A         NFKind `json:"a"`
+	B         NFKind `json:"b"`
+	Interface string `json:"interface"`
+}
+
+// referencePoints are the interfaces the topology draws. The SMF and UPF
+// serve both cores, as PGW-C and PGW-U in 4G, so their interfaces carry
+// both names. Every 5G NF also talks to the NRF; those links are left out,
+// they would join every node to it.
+var referencePoints = []ReferencePoint{
+	// 5G RAN and user plane
+	{KindUE, KindGNB, "Uu"},
+	{KindGNB, KindAMF, "N2"},
+	{KindGNB, KindUPF, "N3"},
+	{KindSMF, KindUPF, "N4/Sxb"},
+	// 5G control plane (SBI)
+	{KindAMF, KindSMF, "N11"},
+	{KindAMF, KindAUSF, "N12"},
+	{KindAMF, KindUDM, "N8"},
+	{KindAMF, KindPCF, "N15"},
+	{KindAMF, KindNSSF, "N22"},
+	{KindAUSF, KindUDM, "N13"},
+	{KindSMF, KindUDM, "N10"},
+	{KindSMF, KindPCF, "N7"},
+	{KindUDM, KindUDR, "N35"},
+	{KindPCF, KindUDR, "N36"},
+	{KindPCF, KindBSF, "Nbsf"},
+	{KindNEF, KindPCF, "N30"},
+	{KindNEF, KindUDR, "N37"},
+	{KindSEPP, KindSEPP, "N32"},
+	// 4G
+	{KindUE, KindENB, "Uu"},
+	{KindENB, KindMME, "S1-MME"},
+	{KindENB, KindSGWU, "S1-U"},
+	{KindMME, KindHSS, "S6a"},
+	{KindMME, KindSGWC, "S11"},
+	{KindSGWC, KindSGWU, "Sxa"},
+	{KindSGWC, KindSMF, "S5-C"},
+	{KindSGWU, KindUPF, "S5-U"},
+	{KindSMF, KindPCRF, "Gx"},
+	// IMS
+	{KindPCSCF, KindICSCF, "Mw"},
+	{KindICSCF, KindSCSCF, "Mw"},
+	{KindICSCF, KindPyHSS, "Cx"},
+	{KindSCSCF, KindPyHSS, "Cx"},
+	{KindPCSCF, KindPCRF, "Rx"},
+	{KindPCSCF, KindPCF, "N5"},
+	// Subscriber database
+	{KindUDR, KindMongo, "MongoDB"},
+	{KindPCF, KindMongo, "MongoDB"},
+	{KindHSS, KindMongo, "MongoDB"},
+	{KindPCRF, KindMongo, "MongoDB"},
+	{KindWebUI, KindMongo, "MongoDB"},
+}
+
+// Links returns the interfaces between the containers of data: every pair
+// of containers whose kinds a reference point joins, unless their om.generation
+// labels name different cores. With several instances of a kind every pair
+// is linked, so these are the interfaces the lab can use rather than the
+// ones each UE or session does. Links are sorted by source, target and
+// interface.
+func Links(data map[string]*ContainerData) []Link {
+	byKind := containersByKind(data)
+	var out []Link
+	for _, rp := range referencePoints {
+		out = append(out, rp.links(byKind)...)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Source != out[j].Source {
+			return out[i].Source < out[j].Source
+		}
+		if out[i].Target != out[j].Target {
+			return out[i].Target < out[j].Target
+		}
+		return out[i].Interface < out[j].Interface
+	})
+	return out
+}
+
+// ReferencePoints returns every interface the topology knows, in the
+// order of the reference architecture.
+func ReferencePoints() []ReferencePoint {
+	return append([]ReferencePoint{}, referencePoints...)
+}
+
+// links returns the links of rp between the containers of byKind.
+func (rp ReferencePoint) links(byKind map[NFKind][]*ContainerData) []Link {
+	var out []Link
+	for _, a := range byKind[rp.A] {
+		for _, b := range byKind[rp.B] {
+			if a == b || (rp.A == rp.B && a.Name > b.Name) || !sameCore(a, b) {
+				continue
+			}
+			out = append(out, Link{Source: a.Name, Target: b.Name, Interface: rp.Interface})
+		}
+	}
+	return out
+}
+
+func containersByKind(data map[string]*ContainerData) map[NFKind][]*ContainerData {
+	byKind := make(map[NFKind][]*ContainerData)
+	for _, cd := range data {
+		if cd.NFKind != KindUnknown {
+			byKind[cd.NFKind] = append(byKind[cd.NFKind], cd)
+		}
+	}
+	return byKind
+}
+
+// sameCore reports whether a and b may belong to the same core: their
+// generations match or one of them serves both.
+func sameCore(a, b *ContainerData) bool {
+	ga, gb := coreGeneration(a), coreGeneration(b)
+	return ga == "" || gb == "" || ga == gb
+}
+
+func coreGeneration(cd *ContainerData) string {
+	if g := cd.Generation; g == "4g" || g == "5g" {
+		return g
+	}
+	return cd.NFKind.Generation()
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"strings"
+
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+)
+
+// NFKind is the type of network function a container runs, whatever the
+// container, its Compose service or its om.nf label are called: the smf2
+// service of the slicing lab, labelled om.nf "smf2", and a core renamed
+// "core-smf-a" are both KindSMF. Code that treats an NF type specially
+// compares kinds rather than names.
+type NFKind string
+
+// Kinds of the lab.
+const (
+	KindUnknown NFKind = ""
+
+	// 5G core
+	KindAMF  NFKind = "amf"
+	KindAUSF NFKind = "ausf"
+	KindBSF  NFKind = "bsf"
+	KindNEF  NFKind = "nef"
+	KindNRF  NFKind = "nrf"
+	KindNSSF NFKind = "nssf"
+	KindPCF  NFKind = "pcf"
+	KindSCP  NFKind = "scp"
+	KindSEPP NFKind = "sepp"
+	KindUDM  NFKind = "udm"
+	KindUDR  NFKind = "udr"
+	// 4G and 5G core
+	KindSMF   NFKind = "smf"
+	KindUPF   NFKind = "upf"
+	KindMongo NFKind = "mongo"
+	KindWebUI NFKind = "webui"
+	// 4G core
+	KindHSS  NFKind = "hss"
+	KindMME  NFKind = "mme"
+	KindPCRF NFKind = "pcrf"
+	KindSGWC NFKind = "sgwc"
+	KindSGWU NFKind = "sgwu"
+	// RAN
+	KindGNB NFKind = "gnb"
+	KindENB NFKind = "enb"
+	KindUE  NFKind = "ue"
+	// IMS
+	KindPCSCF NFKind = "pcscf"
+	KindICSCF NFKind = "icscf"
+	KindSCSCF NFKind = "scscf"
+	KindPyHSS NFKind = "pyhss"
+)
+
+// kindInfo is what the module knows about a kind.
+type kindInfo struct {
+	generation string // "4g" or "5g"; "" when it serves both or neither
+	open5gs    bool   // writes an Open5GS log file to the shared log volume
+	sbi        bool   // serves the 5G service-based interface
+}
+
+var kinds = map[NFKind]kindInfo{
+	KindAMF:   {"5g", true, true},
+	KindAUSF:  {"5g", true, true},
+	KindBSF:   {"5g", true, true},
+	KindNEF:   {"5g", true, true},
+	KindNRF:   {"5g", true, true},
+	KindNSSF:  {"5g", true, true},
+	KindPCF:   {"5g", true, true},
+	KindSCP:   {"5g", true, true},
+	KindSEPP:  {"5g", true, true},
+	KindUDM:   {"5g", true, true},
+	KindUDR:   {"5g", true, true},
+	KindSMF:   {"", true, true},
+	KindUPF:   {"", true, false},
+	KindMongo: {"", false, false},
+	KindWebUI: {"", false, false},
+	KindHSS:   {"4g", true, false},
+	KindMME:   {"4g", true, false},
+	KindPCRF:  {"4g", true, false},
+	KindSGWC:  {"4g", true, false},
+	KindSGWU:  {"4g", true, false},
+	KindGNB:   {"5g", false, false},
+	KindENB:   {"4g", false, false},
+	KindUE:    {"", false, false},
+	KindPCSCF: {"", false, false},
+	KindICSCF: {"", false, false},
+	KindSCSCF: {"", false, false},
+	KindPyHSS: {"", false, false},
+}
+
+// Known reports whether k is a kind of the lab.
+func (k NFKind) Known() bool {
+	_, ok := kinds[k]
+	return ok
+}
+
+// Generation returns the core generation k belongs to: "4g", "5g", or ""
+// for the kinds of both (SMF, UPF) and outside the core.
+func (k NFKind) Generation() string { return kinds[k].generation }
+
+// Open5GS reports whether k writes an Open5GS log file.
+func (k NFKind) Open5GS() bool { return kinds[k].open5gs }
+
+// SBI reports whether k serves the 5G service-based interface. The SMF
+// does in 5G; as the 4G PGW-C it serves none, so its generation tells.
+func (k NFKind) SBI() bool { return kinds[k].sbi }
+
+// ParseNFKind returns the kind an NF name denotes — an om.nf label, a
+// Compose service, a COMPONENT_NAME or the nf label of a metric — or
+// KindUnknown. Instance numbers and qualifiers around the kind are
+// ignored: smf2, upf-1, enb_zmq3, ueransim-gnb2 and ue_zmq_bad_apn name an
+// SMF, a UPF, an eNB, a gNB and a UE.
+func ParseNFKind(name string) NFKind {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if k := NFKind(name); k.Known() {
+		return k
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		if k := NFKind(strings.TrimRight(part, "0123456789")); k.Known() {
+			return k
+		}
+	}
+	return KindUnknown
+}
+
+// Sources of a classification, most reliable first.
+const (
+	KindFromLabel     = "label"          // om.nf
+	KindFromComponent = "component_name" // COMPONENT_NAME, the config file the image loads
+	KindFromService   = "service"        // com.docker.compose.service
+	KindFromImage     = "image"          // image name, for single-purpose images
+)
+
+// classify returns the kind of ct and where it was found. Container names
+// are never used: they change with every compose file. env returns the
+// environment of the container and is only called when the labels do not
+// tell; it may return nil.
+func classify(ct dockerclient.ContainerInfo, env func() map[string]string) (NFKind, string) {
+	if k := ParseNFKind(ct.Labels["om.nf"]); k != KindUnknown {
+		return k, KindFromLabel
+	}
+	// docker_open5gs, docker_srslte and docker_srsran run one image for
+	// every NF and pick the configuration file by COMPONENT_NAME.
+	if e := env(); e != nil {
+		if k := ParseNFKind(e["COMPONENT_NAME"]); k != KindUnknown {
+			return k, KindFromComponent
+		}
+	}
+	if k := ParseNFKind(ct.Labels[labelComposeService]); k != KindUnknown {
+		return k, KindFromService
+	}
+	if k := ParseNFKind(imageName(ct.Image)); k != KindUnknown {
+		return k, KindFromImage
+	}
+	return KindUnknown, ""
+}
+
+// imageName strips the registry, repository path, tag and digest of image.
+func imageName(image string) string {
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.IndexAny(image, ":@"); i >= 0 {
+		image = image[:i]
+	}
+	return image
+}
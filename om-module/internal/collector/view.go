@@ -0,0 +1,45 @@
+package collector
+
+import "time"
+
+// View is a read snapshot: a copy of the collected data taken under a
+// single lock, so everything derived from it — containers, services, the
+// active generation, the exporters — describes the same topology. The
+// Snapshot methods of the same names each read a fresh View; a request
+// that combines several of them reads one View and asks it instead, or a
+// collection cycle finishing in between mixes two topologies in one answer.
+type View struct {
+	Version uint64    // Snapshot.Version the view was taken at
+	Updated time.Time // Snapshot.Updated the view was taken at
+
+	data      map[string]*ContainerData
+	exporters []Exporter
+}
+
+// View returns a read snapshot of the latest collected data.
+func (s *Snapshot) View() *View {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &View{
+		Version:   s.version,
+		Updated:   s.updated,
+		data:      copyData(s.data),
+		exporters: append([]Exporter{}, s.exporters...),
+	}
+}
+
+// All returns a copy of the containers of the view.
+func (v *View) All() map[string]*ContainerData { return copyData(v.data) }
+
+// copyData copies data down to the interfaces of each container, so a
+// reader may keep or modify what it got while the collector stores the
+// next cycle.
+func copyData(data map[string]*ContainerData) map[string]*ContainerData {
+	out := make(map[string]*ContainerData, len(data))
+	for k, cd := range data {
+		cp := *cd
+		cp.Interfaces = append([]InterfaceStats(nil), cd.Interfaces...)
+		out[k] = &cp
+	}
+	return out
+}
@@ -0,0 +1,236 @@
+package dashboards
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+)
+
+// CompareUID and CompareFile identify the generated 4G/5G comparison
+// dashboard.
+const (
+	CompareUID  = "4g-vs-5g"
+	CompareFile = "compare_4g_5g.json"
+)
+
+// compareSide is how one generation measures a procedure: the NF that
+// exports it and the query.
+type compareSide struct {
+	NF    collector.NFKind
+	Title string
+	Expr  string
+	Unit  string
+}
+
+// equivalent is a 4G procedure or counter and its 5G counterpart.
+type equivalent struct {
+	Title string
+	// Note explains the correspondence to the students, in markdown.
+	Note   string
+	G4, G5 compareSide
+}
+
+// equivalents are the rows of the comparison dashboard, from the access
+// network to the policy control.
+var equivalents = []equivalent{
+	{
+		Title: "Nodos de acceso: eNB en el MME ⇄ gNB en el AMF",
+		Note:  "La estación base se conecta al nodo de control de movilidad: el eNB al MME por S1-MME (S1 Setup, TS 36.413) y el gNB al AMF por N2 (NG Setup, TS 38.413). Ambos núcleos cuentan las estaciones base conectadas.",
+		G4:    compareSide{NF: collector.KindMME, Title: "eNBs conectados (MME)", Expr: "sum(mme_enb_count)"},
+		G5:    compareSide{NF: collector.KindAMF, Title: "gNBs conectados (AMF)", Expr: "sum(amf_gnb_count)"},
+	},
+	{
+		Title: "UEs en la RAN: enb_ue ⇄ ran_ue",
+		Note:  "UEs con contexto de señalización entre la estación base y el núcleo: UE-associated S1 en 4G, UE-associated NG en 5G.",
+		G4:    compareSide{NF: collector.KindMME, Title: "UEs por S1 (MME)", Expr: "sum(enb_ue)"},
+		G5:    compareSide{NF: collector.KindAMF, Title: "UEs por N2 (AMF)", Expr: "sum(ran_ue)"},
+	},
+	{
+		Title: "Contextos de movilidad: mme_session ⇄ amf_session",
+		Note:  "El MME guarda un contexto EMM por UE adjuntado (Attach, TS 24.301); el AMF un contexto 5GMM por UE registrado (Registration, TS 24.501).",
+		G4:    compareSide{NF: collector.KindMME, Title: "Sesiones en el MME", Expr: "sum(mme_session)"},
+		G5:    compareSide{NF: collector.KindAMF, Title: "Sesiones en el AMF", Expr: "sum(amf_session)"},
+	},
+	{
+		Title: "Attach (EMM) ⇄ Registration (5GMM): rechazos",
+		Note:  "Attach Request / Attach Reject (TS 24.301 §5.5.1) equivale a Registration Request / Registration Reject (TS 24.501 §5.5.1). El MME de Open5GS no exporta contadores de procedimientos, así que se comparan los rechazos que ve la captura del módulo (om_nas_reject_total); en 5G el AMF además cuenta solicitudes y aceptaciones (fivegs_amffunction_rm_reginitreq / rm_reginitsucc).",
+		G4:    compareSide{NF: collector.KindMME, Title: "Attach Reject (captura)", Expr: `sum by (cause_name) (increase(om_nas_reject_total{generation="4g", message="Attach Reject"}[5m]))`},
+		G5:    compareSide{NF: collector.KindAMF, Title: "Registration Reject (captura)", Expr: `sum by (cause_name) (increase(om_nas_reject_total{generation="5g", message="Registration Reject"}[5m]))`},
+	},
+	{
+		Title: "Autenticación: S6a AIR (HSS) ⇄ 5G-AKA (AMF)",
+		Note:  "En 4G el MME pide los vectores EPS-AKA al HSS por Diameter S6a (Authentication-Information-Request, TS 29.272); en 5G el AMF inicia 5G-AKA y obtiene el vector por la SBI del AUSF y la UDM (TS 33.501 §6.1.3.2).",
+		G4:    compareSide{NF: collector.KindHSS, Title: "AIR recibidos por el HSS (5 min)", Expr: "sum(increase(s6a_rx_air[5m]))"},
+		G5:    compareSide{NF: collector.KindAMF, Title: "Autenticaciones iniciadas por el AMF (5 min)", Expr: "sum(increase(fivegs_amffunction_amf_authreq[5m]))"},
+	},
+	{
+		Title: "Señalización RAN–núcleo: S1AP ⇄ NGAP",
+		Note:  "S1AP (TS 36.413) y NGAP (TS 38.413) van sobre SCTP y transportan el NAS del UE; los dos los identifica la captura del módulo.",
+		G4:    compareSide{NF: collector.KindMME, Title: "Paquetes S1AP por segundo", Expr: `sum(rate(om_capture_packets_total{protocol="s1ap"}[5m]))`, Unit: "pps"},
+		G5:    compareSide{NF: collector.KindAMF, Title: "Paquetes NGAP por segundo", Expr: `sum(rate(om_capture_packets_total{protocol="ngap"}[5m]))`, Unit: "pps"},
+	},
+	{
+		Title: "Causas S1AP ⇄ causas NGAP",
+		Note:  "Los grupos de causas (radioNetwork, transport, nas, protocol, misc) son los mismos en los dos protocolos; muchos valores también, con otro nombre de procedimiento.",
+		G4:    compareSide{NF: collector.KindMME, Title: "Causas S1AP por procedimiento (5 min)", Expr: `sum by (procedure, cause_name) (increase(om_ap_cause_total{protocol="S1AP"}[5m]))`},
+		G5:    compareSide{NF: collector.KindAMF, Title: "Causas NGAP por procedimiento (5 min)", Expr: `sum by (procedure, cause_name) (increase(om_ap_cause_total{protocol="NGAP"}[5m]))`},
+	},
+	{
+		Title: "Bearer EPS por defecto ⇄ sesión PDU",
+		Note:  "En 4G la conectividad de datos se crea con Create Session por S5/S8 (GTPv2-C, TS 29.274) hacia el SMF que hace de PGW-C; en 5G con la creación de la sesión PDU por N11 (TS 23.502 §4.3.2).",
+		G4:    compareSide{NF: collector.KindSMF, Title: "Create Session S5/S8 (5 min)", Expr: "sum(increase(s5c_rx_createsession[5m]))"},
+		G5:    compareSide{NF: collector.KindSMF, Title: "Creación de sesiones PDU (5 min)", Expr: "sum(increase(fivegs_smffunction_sm_pdusessioncreationreq[5m]))"},
+	},
+	{
+		Title: "Política: Gx (PCRF) ⇄ Npcf SM Policy (PCF)",
+		Note:  "El PCEF pide la política del bearer al PCRF con Diameter Gx (CCR/CCA, TS 29.212); el SMF crea una asociación de política SM en el PCF por la SBI (TS 29.512).",
+		G4:    compareSide{NF: collector.KindPCRF, Title: "CCR recibidos por el PCRF (5 min)", Expr: "sum(increase(gx_rx_ccr[5m]))"},
+		G5:    compareSide{NF: collector.KindPCF, Title: "Asociaciones de política SM (5 min)", Expr: "sum(increase(fivegs_pcffunction_pa_policysmassoreq[5m]))"},
+	},
+}
+
+// CompareDashboard returns the Grafana model of the 4G/5G comparison: for
+// each equivalent procedure or counter, a note and the pair of queries,
+// then the panel of each generation side by side. Only the equivalents
+// whose NFs are in d get a row, and the side of a core that is not
+// deployed shows its query instead of an empty panel, so a course that
+// runs the EPC and the 5GC in different weeks can still read it.
+func CompareDashboard(d collector.Deployment) ([]byte, error) {
+	present := func(gen string, nf collector.NFKind) bool {
+		return slices.Contains(d.Generations, gen) && slices.Contains(d.Kinds, nf)
+	}
+
+	var panels []any
+	id, y := 0, 0
+	add := func(p map[string]any, x, w, h int) {
+		id++
+		p["id"] = id
+		p["gridPos"] = map[string]any{"h": h, "w": w, "x": x, "y": y}
+		panels = append(panels, p)
+	}
+	text := func(title, content string) map[string]any {
+		return map[string]any{
+			"type":    "text",
+			"title":   title,
+			"options": map[string]any{"mode": "markdown", "content": content},
+		}
+	}
+
+	add(text("", compareIntro(d)), 0, 24, 4)
+	y += 4
+
+	rows := 0
+	for _, e := range equivalents {
+		has4, has5 := present("4g", e.G4.NF), present("5g", e.G5.NF)
+		if !has4 && !has5 {
+			continue
+		}
+		rows++
+		add(map[string]any{"type": "row", "title": e.Title, "collapsed": false, "panels": []any{}}, 0, 24, 1)
+		y++
+		add(text("", fmt.Sprintf("%s\n\n| | Consulta |\n|---|---|\n| **4G** (%s) | `%s` |\n| **5G** (%s) | `%s` |",
+			e.Note, e.G4.NF, e.G4.Expr, e.G5.NF, e.G5.Expr)), 0, 24, 5)
+		y += 5
+		for i, side := range []struct {
+			name string
+			s    compareSide
+			ok   bool
+		}{{"4G", e.G4, has4}, {"5G", e.G5, has5}} {
+			if side.ok {
+				add(comparePanel(side.name, side.s), 12*i, 12, 8)
+			} else {
+				add(text(side.name+" — "+side.s.Title, fmt.Sprintf("El núcleo %s no está desplegado en este laboratorio (o no tiene %s). Con él en marcha, este panel mostraría:\n\n```promql\n%s\n```",
+					side.name, strings.ToUpper(string(side.s.NF)), side.s.Expr)), 12*i, 12, 8)
+			}
+		}
+		y += 8
+	}
+	if rows == 0 {
+		add(text("", "No hay NFs de un núcleo 4G ni 5G en el laboratorio: el tablero se completa cuando se despliegue alguno."), 0, 24, 3)
+	}
+
+	links := []any{}
+	for _, l := range []struct{ gen, title, uid string }{
+		{"4g", "EPC — 4G Core", "4g-core"},
+		{"5g", "5GC — 5G Core", "5g-core"},
+	} {
+		if slices.Contains(d.Generations, l.gen) {
+			links = append(links, map[string]any{"title": l.title, "type": "link", "url": "/d/" + l.uid, "icon": "dashboard"})
+		}
+	}
+
+	m := map[string]any{
+		"annotations": map[string]any{"list": []any{map[string]any{
+			"builtIn": 1, "datasource": map[string]any{"type": "grafana", "uid": "-- Grafana --"},
+			"enable": true, "hide": true, "iconColor": "rgba(0, 211, 255, 1)",
+			"name": "Annotations & Alerts", "type": "dashboard",
+		}}},
+		"description":          "Generado por el módulo O&M: procedimientos y contadores equivalentes de 4G y 5G, lado a lado, con los núcleos desplegados",
+		"editable":             false,
+		"fiscalYearStartMonth": 0,
+		"graphTooltip":         1,
+		"id":                   nil,
+		"links":                links,
+		"panels":               panels,
+		"refresh":              "30s",
+		"schemaVersion":        40,
+		"tags":                 []string{"educational", "4g", "5g"},
+		"templating":           map[string]any{"list": []any{}},
+		"time":                 map[string]any{"from": "now-1h", "to": "now"},
+		"timepicker":           map[string]any{},
+		"timezone":             "browser",
+		"title":                "🎓 4G ⇄ 5G — procedimientos equivalentes",
+		"uid":                  CompareUID,
+		"version":              1,
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// compareIntro is the text at the top of the comparison, naming the cores
+// found.
+func compareIntro(d collector.Deployment) string {
+	var cores []string
+	for _, g := range d.Generations {
+		cores = append(cores, strings.ToUpper(g))
+	}
+	found := "ninguno"
+	if len(cores) > 0 {
+		found = strings.Join(cores, " y ")
+	}
+	return "Cada fila empareja un procedimiento o contador del EPC (4G) con su equivalente en el 5GC, con la consulta PromQL de cada lado para copiarla en Explore. " +
+		"Núcleos desplegados ahora: **" + found + "**. El tablero se regenera cuando cambia el laboratorio."
+}
+
+// comparePanel is the time series of one side of an equivalent.
+func comparePanel(gen string, s compareSide) map[string]any {
+	defaults := map[string]any{"custom": map[string]any{"fillOpacity": 10}}
+	if s.Unit != "" {
+		defaults["unit"] = s.Unit
+	}
+	return map[string]any{
+		"type":        "timeseries",
+		"title":       gen + " — " + s.Title,
+		"datasource":  prometheusDS,
+		"fieldConfig": map[string]any{"defaults": defaults, "overrides": []any{}},
+		"options": map[string]any{
+			"legend":  map[string]any{"displayMode": "list", "placement": "bottom", "showLegend": true},
+			"tooltip": map[string]any{"mode": "multi", "sort": "desc"},
+		},
+		"targets": []any{map[string]any{
+			"datasource": prometheusDS,
+			"expr":       s.Expr,
+			"refId":      "A",
+		}},
+	}
+}
@@ -0,0 +1,148 @@
+package dashboards
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/output"
+	"go.yaml.in/yaml/v2"
+)
+
+// DatasourceFile is the name of the Loki datasource file written in
+// Grafana's datasource provisioning directory. It replaces the hand-written
+// one of the same name, so the datasource is defined once.
+const DatasourceFile = "loki.yml"
+
+// Datasource UIDs the derived fields link to.
+const (
+	LokiUID  = "P8E80F9AEF21F6940"
+	TempoUID = "tempo"
+)
+
+// LokiDatasource is the Grafana Loki datasource with its derived fields.
+type LokiDatasource struct {
+	// URL is the Loki HTTP API as Grafana sees it.
+	URL      string
+	MaxLines int
+}
+
+// DerivedField turns a value of a log line into a link: to a query of
+// another datasource (Tempo, Loki) when DatasourceUID is set, else to URL.
+type DerivedField struct {
+	Name string `yaml:"name"`
+	// MatcherType is "regex", the first group of MatcherRegex in the line,
+	// or "label", the value of the label MatcherRegex names.
+	MatcherType     string `yaml:"matcherType"`
+	MatcherRegex    string `yaml:"matcherRegex"`
+	URL             string `yaml:"url"`
+	DatasourceUID   string `yaml:"datasourceUid,omitempty"`
+	URLDisplayLabel string `yaml:"urlDisplayLabel"`
+}
+
+// DerivedFields are the links of the Loki datasource. The regexes follow
+// the Open5GS lines promtail labels (promtail/core/config.yml): imsi-… in
+// 5G, IMSI[…] in 4G, and the F-SEID the SMF and UPF log per session, whose
+// CP half is the SEID the capture pipeline puts on its PFCP spans.
+var DerivedFields = []DerivedField{
+	{
+		Name:            "TraceID",
+		MatcherType:     "regex",
+		MatcherRegex:    "traceID=([a-f0-9]{32})",
+		URL:             "${__value.raw}",
+		DatasourceUID:   TempoUID,
+		URLDisplayLabel: "Open in Tempo",
+	},
+	{
+		Name:            "IMSI",
+		MatcherType:     "regex",
+		MatcherRegex:    `(?:imsi-|IMSI\[)(\d{15})`,
+		URL:             `{ span.imsi = "${__value.raw}" }`,
+		DatasourceUID:   TempoUID,
+		URLDisplayLabel: "UE traces in Tempo",
+	},
+	{
+		Name:            "UE logs",
+		MatcherType:     "label",
+		MatcherRegex:    "imsi",
+		URL:             `{job="open5gs", imsi="${__value.raw}"}`,
+		DatasourceUID:   LokiUID,
+		URLDisplayLabel: "Logs of this UE in every NF",
+	},
+	{
+		Name:            "Session",
+		MatcherType:     "regex",
+		MatcherRegex:    `F-SEID\[UP:0x[0-9a-f]+ CP:0x([0-9a-f]+)\]`,
+		URL:             `{ span.seid =~ "0x0*${__value.raw}" }`,
+		DatasourceUID:   TempoUID,
+		URLDisplayLabel: "PFCP session in Tempo",
+	},
+	{
+		Name:            "Procedure",
+		MatcherType:     "label",
+		MatcherRegex:    "procedure",
+		URL:             `{job="open5gs", procedure="${__value.raw}"}`,
+		DatasourceUID:   LokiUID,
+		URLDisplayLabel: "Same procedure in every NF",
+	},
+}
+
+type datasourceFile struct {
+	APIVersion  int                `yaml:"apiVersion"`
+	Datasources []datasourceConfig `yaml:"datasources"`
+}
+
+type datasourceConfig struct {
+	Name     string         `yaml:"name"`
+	Type     string         `yaml:"type"`
+	UID      string         `yaml:"uid"`
+	Access   string         `yaml:"access"`
+	URL      string         `yaml:"url"`
+	Editable bool           `yaml:"editable"`
+	JSONData datasourceJSON `yaml:"jsonData"`
+}
+
+type datasourceJSON struct {
+	MaxLines      int            `yaml:"maxLines"`
+	DerivedFields []DerivedField `yaml:"derivedFields"`
+}
+
+// DatasourceYAML returns the datasource file for d.
+func DatasourceYAML(d LokiDatasource) ([]byte, error) {
+	if d.URL == "" {
+		return nil, fmt.Errorf("loki datasource has no url")
+	}
+	fields := make([]DerivedField, len(DerivedFields))
+	for i, f := range DerivedFields {
+		// Grafana expands $VAR in provisioning files; $$ is a literal $.
+		f.URL = strings.ReplaceAll(f.URL, "$", "$$")
+		fields[i] = f
+	}
+	data, err := yaml.Marshal(datasourceFile{
+		APIVersion: 1,
+		Datasources: []datasourceConfig{{
+			Name:     "Loki",
+			Type:     "loki",
+			UID:      LokiUID,
+			Access:   "proxy",
+			URL:      d.URL,
+			Editable: false,
+			JSONData: datasourceJSON{MaxLines: d.MaxLines, DerivedFields: fields},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("# Generated by om-module (DATASOURCE_PROVISIONING_DIR, LOKI_DATASOURCE_URL); edits are overwritten.\n"), data...), nil
+}
+
+// GenerateDatasource stages in tx the Loki datasource file for d in dir,
+// the directory Grafana reads datasources from.
+func GenerateDatasource(tx *output.Txn, dir string, d LokiDatasource) error {
+	data, err := DatasourceYAML(d)
+	if err != nil {
+		return err
+	}
+	tx.WriteFile(filepath.Join(dir, DatasourceFile), data, 0o644)
+	return nil
+}
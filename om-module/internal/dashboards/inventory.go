@@ -0,0 +1,209 @@
+// Package dashboards inventories the Grafana dashboards shipped with the
+// testbed (grafana/dashboards/*.json), so the API can tell instructors which
+// dashboards exist, what they read and whether Grafana runs the same copy.
+package dashboards
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrNotFound is returned by Load when no dashboard file has the uid.
+var ErrNotFound = errors.New("dashboard not found")
+
+// Dashboard describes one dashboard file.
+type Dashboard struct {
+	UID         string   `json:"uid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	File        string   `json:"file"`
+	Tags        []string `json:"tags"`
+	Panels      int      `json:"panels"`
+	Datasources []string `json:"datasources"` // uids of the datasources the panels query
+	Version     int      `json:"version"`
+	SHA256      string   `json:"sha256"`
+	SizeBytes   int64    `json:"size_bytes"`
+	ModifiedAt  string   `json:"modified_at"`
+}
+
+// Inventory reads the dashboard files of one directory. Files are read on
+// every call, so edits show up without a restart.
+type Inventory struct {
+	dir string
+}
+
+// NewInventory returns an inventory of the *.json files in dir, the
+// directory Grafana provisions dashboards from.
+func NewInventory(dir string) *Inventory { return &Inventory{dir: dir} }
+
+// Dir returns the dashboards directory.
+func (inv *Inventory) Dir() string { return inv.dir }
+
+// List returns every dashboard, sorted by title. Files that are not valid
+// dashboards are skipped.
+func (inv *Inventory) List() ([]Dashboard, error) {
+	paths, err := filepath.Glob(filepath.Join(inv.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Dashboard, 0, len(paths))
+	for _, path := range paths {
+		d, _, err := read(path)
+		if err != nil {
+			continue
+		}
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Title < out[j].Title })
+	return out, nil
+}
+
+// Load returns the dashboard with uid and its raw model.
+func (inv *Inventory) Load(uid string) (Dashboard, json.RawMessage, error) {
+	paths, err := filepath.Glob(filepath.Join(inv.dir, "*.json"))
+	if err != nil {
+		return Dashboard{}, nil, err
+	}
+	for _, path := range paths {
+		d, raw, err := read(path)
+		if err == nil && d.UID == uid {
+			return d, raw, nil
+		}
+	}
+	return Dashboard{}, nil, fmt.Errorf("%w: %s", ErrNotFound, uid)
+}
+
+// model is the part of the Grafana dashboard JSON the inventory reads.
+type model struct {
+	UID         string   `json:"uid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Version     int      `json:"version"`
+	Panels      []panel  `json:"panels"`
+}
+
+type panel struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Datasource *datasource `json:"datasource"`
+	Targets    []struct {
+		Datasource *datasource `json:"datasource"`
+		Expr       string      `json:"expr"`
+	} `json:"targets"`
+	Panels []panel `json:"panels"` // collapsed rows keep their panels here
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// Query is one panel target of a dashboard.
+type Query struct {
+	Panel          string `json:"panel"`
+	DatasourceType string `json:"datasource_type"` // "loki", "prometheus", …
+	Expr           string `json:"expr"`
+}
+
+// Queries returns the targets with an expression of a dashboard model, as
+// returned by Load. A target without its own datasource uses its panel's.
+func Queries(raw json.RawMessage) ([]Query, error) {
+	var m model
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	var out []Query
+	var walk func([]panel)
+	walk = func(panels []panel) {
+		for _, p := range panels {
+			for _, t := range p.Targets {
+				ds := t.Datasource
+				if ds == nil || ds.Type == "" {
+					ds = p.Datasource
+				}
+				if t.Expr == "" || ds == nil {
+					continue
+				}
+				out = append(out, Query{Panel: p.Title, DatasourceType: ds.Type, Expr: t.Expr})
+			}
+			walk(p.Panels)
+		}
+	}
+	walk(m.Panels)
+	return out, nil
+}
+
+func read(path string) (Dashboard, json.RawMessage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Dashboard{}, nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Dashboard{}, nil, err
+	}
+	var m model
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Dashboard{}, nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if m.UID == "" {
+		return Dashboard{}, nil, fmt.Errorf("%s: dashboard has no uid", filepath.Base(path))
+	}
+
+	sum := sha256.Sum256(raw)
+	d := Dashboard{
+		UID:         m.UID,
+		Title:       m.Title,
+		Description: m.Description,
+		File:        filepath.Base(path),
+		Tags:        m.Tags,
+		Version:     m.Version,
+		SHA256:      hex.EncodeToString(sum[:]),
+		SizeBytes:   info.Size(),
+		ModifiedAt:  info.ModTime().UTC().Format(time.RFC3339),
+	}
+	if d.Tags == nil {
+		d.Tags = []string{}
+	}
+	seen := make(map[string]bool)
+	d.Panels, d.Datasources = walkPanels(m.Panels, seen, nil)
+	sort.Strings(d.Datasources)
+	return d, raw, nil
+}
+
+// walkPanels counts the non-row panels and collects their datasource uids.
+// Grafana's built-in "-- Grafana --" and "-- Mixed --" sources are left out.
+func walkPanels(panels []panel, seen map[string]bool, uids []string) (int, []string) {
+	add := func(ds *datasource) {
+		if ds == nil || ds.UID == "" || seen[ds.UID] || ds.UID[0] == '-' || ds.UID[0] == '$' {
+			return
+		}
+		seen[ds.UID] = true
+		uids = append(uids, ds.UID)
+	}
+	n := 0
+	for _, p := range panels {
+		if p.Type != "row" {
+			n++
+		}
+		add(p.Datasource)
+		for _, t := range p.Targets {
+			add(t.Datasource)
+		}
+		var nested int
+		nested, uids = walkPanels(p.Panels, seen, uids)
+		n += nested
+	}
+	if uids == nil {
+		uids = []string{}
+	}
+	return n, uids
+}
@@ -0,0 +1,91 @@
+package dashboards
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Parz1val02/OM_module/internal/output"
+	"go.yaml.in/yaml/v2"
+)
+
+// ProvisioningFile is the name of the provider file written in Grafana's
+// dashboard provisioning directory.
+const ProvisioningFile = "om-module.yml"
+
+// folderUIDRe is what Grafana accepts as a folder uid.
+var folderUIDRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,40}$`)
+
+// Provider is the Grafana file provider that loads the dashboards.
+type Provider struct {
+	Name string
+	// Path is the directory of the dashboard files as Grafana sees it,
+	// which is not where the module writes them when the two do not share
+	// a filesystem layout (the module run on the host, Grafana in Docker).
+	Path      string
+	Folder    string // folder title; "" puts the dashboards in General
+	FolderUID string // "" lets Grafana pick one
+}
+
+type providerFile struct {
+	APIVersion int              `yaml:"apiVersion"`
+	Providers  []providerConfig `yaml:"providers"`
+}
+
+type providerConfig struct {
+	Name                  string            `yaml:"name"`
+	OrgID                 int               `yaml:"orgId"`
+	Folder                string            `yaml:"folder,omitempty"`
+	FolderUID             string            `yaml:"folderUid,omitempty"`
+	Type                  string            `yaml:"type"`
+	DisableDeletion       bool              `yaml:"disableDeletion"`
+	UpdateIntervalSeconds int               `yaml:"updateIntervalSeconds"`
+	Options               map[string]string `yaml:"options"`
+}
+
+// ProvisioningYAML returns the provider file for p.
+func ProvisioningYAML(p Provider) ([]byte, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("dashboard provider has no name")
+	}
+	if p.Path == "" {
+		return nil, fmt.Errorf("dashboard provider %q has no path", p.Name)
+	}
+	if p.FolderUID != "" && !folderUIDRe.MatchString(p.FolderUID) {
+		return nil, fmt.Errorf("folder uid %q: at most 40 letters, digits, - or _", p.FolderUID)
+	}
+	data, err := yaml.Marshal(providerFile{
+		APIVersion: 1,
+		Providers: []providerConfig{{
+			Name:      p.Name,
+			OrgID:     1,
+			Folder:    p.Folder,
+			FolderUID: p.FolderUID,
+			Type:      "file",
+			// Grafana also re-reads the directory on its own, which is
+			// all it does without admin rights for the reload API.
+			UpdateIntervalSeconds: 10,
+			Options:               map[string]string{"path": p.Path},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("# Generated by om-module (DASHBOARD_PROVIDER_*, DASHBOARD_FOLDER*); edits are overwritten.\n"), data...), nil
+}
+
+// GenerateProvisioning stages in tx the provider file for p in dir, the
+// directory Grafana reads dashboard providers from. The dashboards
+// themselves are in p.Path, never in dir.
+func GenerateProvisioning(tx *output.Txn, dir string, p Provider) error {
+	if filepath.Clean(dir) == filepath.Clean(p.Path) {
+		// Grafana would try to load the provider file as a dashboard.
+		return fmt.Errorf("provider file and dashboards both in %s", dir)
+	}
+	data, err := ProvisioningYAML(p)
+	if err != nil {
+		return err
+	}
+	tx.WriteFile(filepath.Join(dir, ProvisioningFile), data, 0o644)
+	return nil
+}
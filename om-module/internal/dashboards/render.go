@@ -0,0 +1,450 @@
+package dashboards
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/output"
+)
+
+// RenderOptions are what the rendered copies of the dashboards depend on.
+type RenderOptions struct {
+	// Loki is false in a deployment without the logging stack. Panels that
+	// query Loki are then replaced by a placeholder of the same size, and
+	// Loki annotations and template variables are dropped, so the rest of
+	// the dashboard loads without datasource errors.
+	Loki bool
+	// Notes returns the institution's markdown for the dashboard with the
+	// given uid (EDUCATIONAL_PROVIDERS), or "". It is shown in a text panel
+	// above the others. Nil adds nothing.
+	Notes func(uid string) string
+	// Simulated are the metrics the simulated metrics fallback can serve
+	// in place of an NF (simmetrics). Dashboards that query any of them
+	// get a banner naming the containers being simulated. Empty adds
+	// nothing.
+	Simulated []string
+	// Extra are dashboards the module generates itself, such as the SLO
+	// overview, by file name. They are rendered and kept next to the
+	// copies of src.
+	Extra map[string][]byte
+}
+
+// notes returns the markdown Notes has for the dashboard model m.
+func (o RenderOptions) notes(m map[string]any) string {
+	if o.Notes == nil {
+		return ""
+	}
+	uid, _ := m["uid"].(string)
+	return o.Notes(uid)
+}
+
+// simulated returns the Prometheus datasource of the first panel of m that
+// queries a metric of Simulated, or nil when none does.
+func (o RenderOptions) simulated(m map[string]any) any {
+	if len(o.Simulated) == 0 {
+		return nil
+	}
+	names := make(map[string]bool, len(o.Simulated))
+	for _, n := range o.Simulated {
+		names[n] = true
+	}
+	var walk func([]any) any
+	walk = func(panels []any) any {
+		for _, v := range panels {
+			p, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			if nested, ok := p["panels"].([]any); ok {
+				if ds := walk(nested); ds != nil {
+					return ds
+				}
+			}
+			targets, _ := p["targets"].([]any)
+			for _, t := range targets {
+				tm, _ := t.(map[string]any)
+				expr, _ := tm["expr"].(string)
+				if !queriesAny(expr, names) {
+					continue
+				}
+				ds := p["datasource"]
+				if tm["datasource"] != nil {
+					ds = tm["datasource"]
+				}
+				if ds != nil && !isLoki(ds) {
+					return ds
+				}
+			}
+		}
+		return nil
+	}
+	panels, _ := m["panels"].([]any)
+	return walk(panels)
+}
+
+// queriesAny reports whether the PromQL expr names one of names.
+func queriesAny(expr string, names map[string]bool) bool {
+	words := strings.FieldsFunc(expr, func(r rune) bool {
+		return r != '_' && r != ':' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9')
+	})
+	for _, w := range words {
+		if names[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder is the text of the panels that stand in for Loki panels.
+const placeholder = "### 📭 Logs no disponibles\n\n" +
+	"Este panel consulta **Loki**, que no está configurado en este despliegue " +
+	"(`LOKI_URL` vacío o Loki no respondía al arrancar el módulo O&M). " +
+	"El resto del dashboard funciona con normalidad.\n\n" +
+	"Para recuperarlo, levanta el stack de logs (Loki y Promtail/Alloy) y reinicia el módulo O&M."
+
+// simulatedBanner is the text of the banner when no NF is simulated.
+const simulatedBanner = "✅ Todas las métricas de las NFs son reales"
+
+// Render returns the dashboard model raw rendered with o, and how many
+// panels were replaced. With Loki, no notes and no simulated metrics
+// queried the model is returned unchanged.
+func Render(raw []byte, o RenderOptions) ([]byte, int, error) {
+	if o.Loki && o.Notes == nil && len(o.Simulated) == 0 {
+		return raw, 0, nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, 0, err
+	}
+	notes := o.notes(m)
+	simDS := o.simulated(m)
+	if o.Loki && notes == "" && simDS == nil {
+		return raw, 0, nil
+	}
+
+	replaced := 0
+	if !o.Loki {
+		if panels, ok := m["panels"].([]any); ok {
+			m["panels"], replaced = withoutLoki(panels)
+		}
+		if ann, ok := m["annotations"].(map[string]any); ok {
+			ann["list"] = dropLoki(ann["list"])
+		}
+		if tpl, ok := m["templating"].(map[string]any); ok {
+			tpl["list"] = dropLoki(tpl["list"])
+		}
+	}
+	if notes != "" {
+		panels, _ := m["panels"].([]any)
+		m["panels"] = withNotes(panels, notes)
+	}
+	if simDS != nil {
+		panels, _ := m["panels"].([]any)
+		m["panels"] = withTop(panels, simulatedPanel(simDS), 3)
+	}
+
+	// PromQL comparisons (<, >) stay readable in the rendered file.
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), replaced, nil
+}
+
+// Generate stages in tx every *.json file in src and o.Extra rendered into
+// dst and the removal of the files of dst that are in neither, and returns
+// the number of panels replaced.
+func Generate(tx *output.Txn, src, dst string, o RenderOptions) (int, error) {
+	files, err := filepath.Glob(filepath.Join(src, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no *.json dashboards in %s", src)
+	}
+
+	replaced := 0
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return replaced, err
+		}
+		out, n, err := Render(raw, o)
+		if err != nil {
+			return replaced, fmt.Errorf("%s: %w", filepath.Base(f), err)
+		}
+		name := filepath.Base(f)
+		tx.WriteFile(filepath.Join(dst, name), out, 0o644)
+		keep[name] = true
+		replaced += n
+	}
+
+	for _, name := range sortedKeys(o.Extra) {
+		if keep[name] {
+			return replaced, fmt.Errorf("generated dashboard %s: %s already has a file of that name", name, src)
+		}
+		out, n, err := Render(o.Extra[name], o)
+		if err != nil {
+			return replaced, fmt.Errorf("%s: %w", name, err)
+		}
+		tx.WriteFile(filepath.Join(dst, name), out, 0o644)
+		keep[name] = true
+		replaced += n
+	}
+
+	old, _ := filepath.Glob(filepath.Join(dst, "*.json"))
+	for _, f := range old {
+		if !keep[filepath.Base(f)] {
+			tx.Remove(f)
+		}
+	}
+	return replaced, nil
+}
+
+// ValidateRendered checks the dashboards staged in tx before Grafana is
+// given them: each must be a dashboard model with a uid of its own, or the
+// provisioner would reject it or let one file shadow another.
+func ValidateRendered(tx *output.Txn) error {
+	owners := make(map[string]string)
+	for _, path := range tx.Files() {
+		data, _ := tx.Data(path)
+		var m struct {
+			UID    string `json:"uid"`
+			Panels []any  `json:"panels"`
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if m.UID == "" {
+			return fmt.Errorf("%s: no uid", filepath.Base(path))
+		}
+		if other, ok := owners[m.UID]; ok {
+			return fmt.Errorf("%s: uid %q already used by %s", filepath.Base(path), m.UID, other)
+		}
+		owners[m.UID] = filepath.Base(path)
+	}
+	return nil
+}
+
+// RenderInputs returns what the rendered copies of the dashboards in src
+// are generated from, for the regen.Job Inputs.
+func RenderInputs(src string, o RenderOptions) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(src, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "loki=%t\n", o.Loki)
+	fmt.Fprintf(h, "simulated=%s\n", strings.Join(o.Simulated, ","))
+	hash := func(name string, raw []byte) {
+		fmt.Fprintf(h, "%s %x\n", name, sha256.Sum256(raw))
+		if o.Notes != nil {
+			var m map[string]any
+			if json.Unmarshal(raw, &m) == nil {
+				fmt.Fprintf(h, "notes %x\n", sha256.Sum256([]byte(o.notes(m))))
+			}
+		}
+	}
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		hash(filepath.Base(f), raw)
+	}
+	for _, name := range sortedKeys(o.Extra) {
+		hash("extra "+name, o.Extra[name])
+	}
+	return h.Sum(nil), nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// withoutLoki replaces the panels that only query Loki by placeholders and
+// drops the Loki targets of mixed panels. Collapsed rows are walked too.
+func withoutLoki(panels []any) ([]any, int) {
+	replaced := 0
+	for i, v := range panels {
+		p, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		if nested, ok := p["panels"].([]any); ok {
+			var n int
+			p["panels"], n = withoutLoki(nested)
+			replaced += n
+		}
+		if p["type"] == "row" {
+			continue
+		}
+
+		targets, _ := p["targets"].([]any)
+		var kept []any
+		for _, t := range targets {
+			tm, _ := t.(map[string]any)
+			ds := p["datasource"]
+			if tm != nil && tm["datasource"] != nil {
+				ds = tm["datasource"]
+			}
+			if !isLoki(ds) {
+				kept = append(kept, t)
+			}
+		}
+		switch {
+		case len(targets) == 0 && !isLoki(p["datasource"]):
+			// Text panels and other panels without queries.
+		case len(kept) == 0:
+			panels[i] = placeholderPanel(p)
+			replaced++
+		case len(kept) < len(targets):
+			p["targets"] = kept
+		}
+	}
+	return panels, replaced
+}
+
+// placeholderPanel is a text panel with the position, id and title of p.
+func placeholderPanel(p map[string]any) map[string]any {
+	out := map[string]any{
+		"type":    "text",
+		"options": map[string]any{"mode": "markdown", "content": placeholder},
+	}
+	for _, k := range []string{"id", "gridPos", "title"} {
+		if v, ok := p[k]; ok {
+			out[k] = v
+		}
+	}
+	if desc, ok := p["description"].(string); ok && desc != "" {
+		out["description"] = desc
+	}
+	return out
+}
+
+// withNotes puts a text panel with notes at the top of panels.
+func withNotes(panels []any, notes string) []any {
+	height := 3 + strings.Count(notes, "\n")
+	if height > 12 {
+		height = 12
+	}
+	return withTop(panels, map[string]any{
+		"type":    "text",
+		"title":   "",
+		"options": map[string]any{"mode": "markdown", "content": notes},
+	}, height)
+}
+
+// simulatedPanel is the banner of a dashboard that queries simulated
+// metrics: red with the containers whose metrics are simulated, green
+// with simulatedBanner when none is. om_metrics_simulated is scraped with
+// the om-module-host job, which sets container, hence exported_container.
+func simulatedPanel(ds any) map[string]any {
+	return map[string]any{
+		"type":        "stat",
+		"title":       "",
+		"description": "Cuando el endpoint de métricas de una NF no responde, el módulo O&M puede servir métricas de muestra en su lugar (flag \"simulated\"), con la etiqueta data_source=\"simulated\". Los paneles de esa NF muestran entonces datos simulados, no los del laboratorio.",
+		"datasource":  ds,
+		"targets": []any{map[string]any{
+			"refId":        "A",
+			"datasource":   ds,
+			"expr":         "om_metrics_simulated == 1",
+			"instant":      true,
+			"legendFormat": "⚠️ Métricas simuladas (data_source=\"simulated\"): {{exported_container}}",
+		}},
+		"options": map[string]any{
+			"textMode":      "name",
+			"colorMode":     "background",
+			"graphMode":     "none",
+			"justifyMode":   "center",
+			"reduceOptions": map[string]any{"calcs": []any{"lastNotNull"}, "fields": "", "values": false},
+		},
+		"fieldConfig": map[string]any{
+			"defaults": map[string]any{
+				"noValue": simulatedBanner,
+				"color":   map[string]any{"mode": "thresholds"},
+				"thresholds": map[string]any{
+					"mode": "absolute",
+					"steps": []any{
+						map[string]any{"color": "green", "value": nil},
+						map[string]any{"color": "red", "value": 1},
+					},
+				},
+			},
+			"overrides": []any{},
+		},
+	}
+}
+
+// withTop puts top, height rows high, at the top of panels and moves every
+// panel, including those of collapsed rows, down to make room.
+func withTop(panels []any, top map[string]any, height int) []any {
+	maxID := 0.0
+	var shift func([]any)
+	shift = func(list []any) {
+		for _, v := range list {
+			p, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			if id, ok := p["id"].(float64); ok && id > maxID {
+				maxID = id
+			}
+			if pos, ok := p["gridPos"].(map[string]any); ok {
+				y, _ := pos["y"].(float64)
+				pos["y"] = y + float64(height)
+			}
+			if nested, ok := p["panels"].([]any); ok {
+				shift(nested)
+			}
+		}
+	}
+	shift(panels)
+	top["id"] = maxID + 1
+	top["gridPos"] = map[string]any{"x": 0, "y": 0, "w": 24, "h": height}
+	return append([]any{top}, panels...)
+}
+
+// dropLoki removes the annotations or template variables that query Loki.
+func dropLoki(list any) any {
+	items, ok := list.([]any)
+	if !ok {
+		return list
+	}
+	out := make([]any, 0, len(items))
+	for _, v := range items {
+		if m, ok := v.(map[string]any); ok && isLoki(m["datasource"]) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// isLoki reports whether a datasource reference points at Loki: an object
+// with type "loki", or a bare name or variable mentioning it.
+func isLoki(ds any) bool {
+	switch v := ds.(type) {
+	case map[string]any:
+		t, _ := v["type"].(string)
+		return t == "loki"
+	case string:
+		return strings.Contains(strings.ToLower(v), "loki")
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package dashboards
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// TopologyUID and TopologyFile identify the generated topology dashboard.
+const (
+	TopologyUID  = "topology"
+	TopologyFile = "topology.json"
+)
+
+var prometheusDS = map[string]any{"type": "prometheus", "uid": "PBFA97CFB590B2093"}
+
+// Queries of the node graph. Grafana's node graph takes the frames with a
+// source field as edges and the others as nodes, and reads the fields by
+// name: id, title, subtitle, mainstat, color and detail__* for nodes; id,
+// source, target and mainstat for edges.
+const (
+	topologyNodesQuery = `label_replace(max by (container, nf, generation, service) (container_health_status), "title", "$1", "container", "(.*)")`
+	topologyEdgesQuery = `max by (id, source, target, interface) (label_join(om_topology_link_info, "id", ":", "source", "target", "interface"))`
+)
+
+// healthMappings are the texts of container_health_status.
+var healthMappings = []any{map[string]any{"type": "value", "options": map[string]any{
+	"1":  map[string]any{"text": "en ejecución", "color": "green", "index": 0},
+	"0":  map[string]any{"text": "degradado", "color": "orange", "index": 1},
+	"-1": map[string]any{"text": "detenido", "color": "red", "index": 2},
+}}}
+
+// TopologyDashboard returns the Grafana model of the live topology: a node
+// graph of the containers the collector discovers, colored by
+// container_health_status, joined by the interfaces of
+// om_topology_link_info and labelled with their reference points, and the
+// list of those interfaces. Both follow the lab as containers come and go.
+func TopologyDashboard() ([]byte, error) {
+	byRef := func(ref string) map[string]any {
+		return map[string]any{"id": "byRefId", "options": ref}
+	}
+	nodeGraph := map[string]any{
+		"datasource":  prometheusDS,
+		"description": "Contenedores descubiertos por el módulo O&M (color: container_health_status) y las interfaces 3GPP entre ellos (om_topology_link_info). Pase el ratón por una arista para ver su punto de referencia; con varias instancias de un NF se dibujan todas las interfaces posibles.",
+		"fieldConfig": map[string]any{"defaults": map[string]any{}, "overrides": []any{
+			map[string]any{
+				"matcher": map[string]any{"id": "byName", "options": "color"},
+				"properties": []any{
+					map[string]any{"id": "color", "value": map[string]any{"mode": "thresholds"}},
+					map[string]any{"id": "thresholds", "value": map[string]any{"mode": "absolute", "steps": []any{
+						map[string]any{"color": "red", "value": nil},
+						map[string]any{"color": "orange", "value": 0},
+						map[string]any{"color": "green", "value": 1},
+					}}},
+				},
+			},
+			map[string]any{
+				"matcher":    map[string]any{"id": "byName", "options": "mainstat"},
+				"properties": []any{map[string]any{"id": "mappings", "value": healthMappings}},
+			},
+		}},
+		"gridPos": map[string]any{"h": 20, "w": 24, "x": 0, "y": 0},
+		"id":      1,
+		"options": map[string]any{
+			"nodes": map[string]any{},
+			"edges": map[string]any{},
+		},
+		"targets": []any{
+			map[string]any{"datasource": prometheusDS, "expr": topologyNodesQuery, "format": "table", "instant": true, "range": false, "refId": "A"},
+			map[string]any{"datasource": prometheusDS, "expr": topologyEdgesQuery, "format": "table", "instant": true, "range": false, "refId": "B"},
+		},
+		"title": "🕸️ Topología en vivo",
+		"transformations": []any{
+			map[string]any{
+				"id":     "calculateField",
+				"filter": byRef("A"),
+				"options": map[string]any{
+					"alias":  "color",
+					"mode":   "binary",
+					"binary": map[string]any{"left": "Value", "operator": "*", "right": "1"},
+				},
+			},
+			map[string]any{
+				"id":     "organize",
+				"filter": byRef("A"),
+				"options": map[string]any{
+					"excludeByName": map[string]any{"Time": true},
+					"renameByName": map[string]any{
+						"container":  "id",
+						"nf":         "subtitle",
+						"generation": "detail__generation",
+						"service":    "detail__service",
+						"Value":      "mainstat",
+					},
+				},
+			},
+			map[string]any{
+				"id":     "organize",
+				"filter": byRef("B"),
+				"options": map[string]any{
+					"excludeByName": map[string]any{"Time": true, "Value": true},
+					"renameByName":  map[string]any{"interface": "mainstat"},
+				},
+			},
+		},
+		"type": "nodeGraph",
+	}
+
+	links := map[string]any{
+		"datasource":  prometheusDS,
+		"description": "Interfaces entre los contenedores del laboratorio según la arquitectura de referencia 3GPP, a partir de los tipos de NF descubiertos",
+		"fieldConfig": map[string]any{"defaults": map[string]any{}, "overrides": []any{}},
+		"gridPos":     map[string]any{"h": 10, "w": 24, "x": 0, "y": 20},
+		"id":          2,
+		"options":     map[string]any{"showHeader": true, "sortBy": []any{map[string]any{"displayName": "origen", "desc": false}}},
+		"targets": []any{map[string]any{
+			"datasource": prometheusDS,
+			"expr":       "max by (source, target, interface) (om_topology_link_info)",
+			"format":     "table", "instant": true, "range": false, "refId": "A",
+		}},
+		"title": "Interfaces",
+		"transformations": []any{map[string]any{
+			"id": "organize",
+			"options": map[string]any{
+				"excludeByName": map[string]any{"Time": true, "Value": true},
+				"indexByName":   map[string]any{"source": 0, "interface": 1, "target": 2},
+				"renameByName":  map[string]any{"source": "origen", "interface": "interfaz", "target": "destino"},
+			},
+		}},
+		"type": "table",
+	}
+
+	m := map[string]any{
+		"annotations": map[string]any{"list": []any{map[string]any{
+			"builtIn": 1, "datasource": map[string]any{"type": "grafana", "uid": "-- Grafana --"},
+			"enable": true, "hide": true, "iconColor": "rgba(0, 211, 255, 1)",
+			"name": "Annotations & Alerts", "type": "dashboard",
+		}}},
+		"description":          "Generado por el módulo O&M: los contenedores del laboratorio y sus interfaces, con su estado en vivo",
+		"editable":             false,
+		"fiscalYearStartMonth": 0,
+		"graphTooltip":         0,
+		"id":                   nil,
+		"links":                []any{},
+		"panels":               []any{nodeGraph, links},
+		"refresh":              "10s",
+		"schemaVersion":        40,
+		"tags":                 []string{"topology", "4g", "5g"},
+		"templating":           map[string]any{"list": []any{}},
+		"time":                 map[string]any{"from": "now-15m", "to": "now"},
+		"timepicker":           map[string]any{},
+		"timezone":             "browser",
+		"title":                "🕸️ Topología del laboratorio",
+		"uid":                  TopologyUID,
+		"version":              1,
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
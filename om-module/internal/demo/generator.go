@@ -0,0 +1,285 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+)
+
+const (
+	// messageGap separates the messages of one procedure, stepGap the steps
+	// of the scenario, so spans and log lines are readable in Grafana.
+	messageGap = 40 * time.Millisecond
+	stepGap    = 3 * time.Second
+
+	// loopPause separates two runs of the scenario.
+	loopPause = 30 * time.Second
+)
+
+// Node addresses of the docker_open5gs testbed (see .env). The demo uses
+// the real addresses so that running core containers resolve the same way.
+var nodeIPs = map[string]string{
+	"amf": "172.22.0.10", "ausf": "172.22.0.11", "udm": "172.22.0.13",
+	"smf": "172.22.0.7", "upf": "172.22.0.8",
+	"mme": "172.22.0.9", "hss": "172.22.0.3", "sgwc": "172.22.0.5",
+	"gnb": "172.22.0.23", "gnb2": "172.22.0.25",
+	"enb": "172.22.0.22", "enb2": "172.22.0.38",
+}
+
+// nodeCells are the cell identities (NR Cell Identity / E-UTRAN Cell ID) of
+// the single cell each gNB/eNB serves, as in the RAN configuration files.
+var nodeCells = map[string]string{
+	"gnb": "0x000000010", "gnb2": "0x000000020",
+	"enb": "0x0019b01", "enb2": "0x0019c01",
+}
+
+// ue is the state of one synthetic UE.
+type ue struct {
+	n        int
+	msin     string
+	attached bool
+	cell     int    // 0 or 1: which gNB/eNB serves the UE
+	ranID    string // RAN-UE-NGAP-ID / ENB-UE-S1AP-ID
+	coreID   string // AMF-UE-NGAP-ID / MME-UE-S1AP-ID
+	teid     string // MME S11 TEID of the default bearer (4G)
+	ueIP     string
+}
+
+// Generator plays a Scenario in a loop and emits the resulting packets.
+type Generator struct {
+	scenario *Scenario
+	mcc, mnc string
+	logDir   string
+	logLoc   *time.Location
+	logs     *logWriter // nil when log output is disabled
+
+	out chan capture.Packet
+
+	ues      map[int]*ue
+	ranUp    bool
+	nextID   int
+	seq      int
+	streamID int
+	sessions int
+}
+
+// New creates a Generator. mcc and mnc build the synthetic IMSIs; logDir,
+// if non-empty, receives Open5GS-style log files (the directory promtail
+// reads, e.g. /var/log/open5gs), time stamped in logLoc.
+func New(scenario *Scenario, mcc, mnc, logDir string, logLoc *time.Location) *Generator {
+	return &Generator{
+		scenario: scenario,
+		mcc:      mcc,
+		mnc:      mnc,
+		logDir:   logDir,
+		logLoc:   logLoc,
+		out:      make(chan capture.Packet, 256),
+		ues:      make(map[int]*ue),
+	}
+}
+
+// Packets returns the channel of synthetic packets, read by the pipeline in
+// place of the capture manager's.
+func (g *Generator) Packets() <-chan capture.Packet { return g.out }
+
+// NFs returns the IP→NF map of the synthetic nodes. The pipeline uses it
+// for addresses that are not running containers.
+func (g *Generator) NFs() map[string]string {
+	out := make(map[string]string, len(nodeIPs))
+	for nf, ip := range nodeIPs {
+		switch nf {
+		case "gnb2":
+			nf = "gnb"
+		case "enb2":
+			nf = "enb"
+		}
+		out[ip] = nf
+	}
+	return out
+}
+
+// Run plays the scenario until ctx is cancelled. UEs still attached at the
+// end of a run are detached before the next one starts.
+func (g *Generator) Run(ctx context.Context) {
+	if g.logDir != "" {
+		g.logs = newLogWriter(g.logDir, g.scenario.Generation, g.logLoc)
+		defer g.logs.close()
+	}
+	log.Printf("🎬 Demo scenario started (%s, %d steps)", g.scenario.Generation, len(g.scenario.Steps))
+
+	for run := 1; ; run++ {
+		for _, step := range g.scenario.Steps {
+			if err := g.play(ctx, step); err != nil {
+				return
+			}
+			if err := sleep(ctx, stepGap); err != nil {
+				return
+			}
+		}
+		for n, u := range g.ues {
+			if u.attached {
+				if err := g.play(ctx, Step{Op: OpDetach, UEs: []int{n}}); err != nil {
+					return
+				}
+			}
+		}
+		log.Printf("🎬 Demo scenario run %d finished — restarting in %s", run, loopPause)
+		if err := sleep(ctx, loopPause); err != nil {
+			return
+		}
+	}
+}
+
+func (g *Generator) play(ctx context.Context, step Step) error {
+	if step.Op == OpWait {
+		return sleep(ctx, step.Wait)
+	}
+	if !g.ranUp {
+		if err := g.ranSetup(ctx); err != nil {
+			return err
+		}
+		g.ranUp = true
+		if step.Op == OpRANSetup {
+			return nil
+		}
+	}
+
+	for _, n := range step.UEs {
+		u := g.ue(n)
+		var err error
+		switch {
+		case step.Op == OpAttach && !u.attached:
+			err = g.attach(ctx, u, step.AuthFail)
+		case step.Op == OpHandover && u.attached:
+			err = g.handover(ctx, u)
+		case step.Op == OpDetach && u.attached:
+			err = g.detach(ctx, u)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Generator) ranSetup(ctx context.Context) error {
+	if g.scenario.Generation == capture.Generation4G {
+		return g.s1Setup(ctx)
+	}
+	return g.ngSetup(ctx)
+}
+
+func (g *Generator) attach(ctx context.Context, u *ue, authFail bool) error {
+	u.ranID, u.coreID = g.id(), g.id()
+	if g.scenario.Generation == capture.Generation4G {
+		return g.attach4G(ctx, u, authFail)
+	}
+	return g.register5G(ctx, u, authFail)
+}
+
+func (g *Generator) handover(ctx context.Context, u *ue) error {
+	if g.scenario.Generation == capture.Generation4G {
+		return g.handover4G(ctx, u)
+	}
+	return g.handover5G(ctx, u)
+}
+
+func (g *Generator) detach(ctx context.Context, u *ue) error {
+	if g.scenario.Generation == capture.Generation4G {
+		return g.detach4G(ctx, u)
+	}
+	return g.deregister5G(ctx, u)
+}
+
+// ue returns UE n, creating it on first use. UE 1 has the MSIN of the first
+// subscriber provisioned by scripts/mongo_insert.sh (1234567895).
+func (g *Generator) ue(n int) *ue {
+	u, ok := g.ues[n]
+	if !ok {
+		u = &ue{n: n, msin: fmt.Sprintf("%010d", 1234567894+n)}
+		g.ues[n] = u
+	}
+	return u
+}
+
+func (g *Generator) imsi(u *ue) string { return g.mcc + g.mnc + u.msin }
+
+func (g *Generator) suci(u *ue) string {
+	return fmt.Sprintf("suci-0-%s-%s-0000-0-0-%s", g.mcc, g.mnc, u.msin)
+}
+
+// cell returns the IP of the gNB/eNB serving u, or of the other one.
+func (g *Generator) cell(u *ue, other bool) string {
+	return nodeIPs[g.ranNode(u, other)]
+}
+
+// cellID returns the cell identity of the gNB/eNB serving u, or of the
+// other one.
+func (g *Generator) cellID(u *ue, other bool) string {
+	return nodeCells[g.ranNode(u, other)]
+}
+
+func (g *Generator) ranNode(u *ue, other bool) string {
+	idx := u.cell
+	if other {
+		idx = 1 - idx
+	}
+	name := "gnb"
+	if g.scenario.Generation == capture.Generation4G {
+		name = "enb"
+	}
+	if idx == 1 {
+		name += "2"
+	}
+	return name
+}
+
+func (g *Generator) id() string {
+	g.nextID++
+	return fmt.Sprint(g.nextID)
+}
+
+func (g *Generator) nextSeq() int {
+	g.seq++
+	return g.seq
+}
+
+// emit stamps pkt, sends it to the pipeline and paces the procedure.
+func (g *Generator) emit(ctx context.Context, pkt capture.Packet) error {
+	pkt.Timestamp = time.Now()
+	// Same generation tagging as the tshark parser; PFCP is left to the
+	// pipeline to resolve.
+	switch pkt.Protocol {
+	case "ngap", "sbi":
+		pkt.Generation = capture.Generation5G
+	case "s1ap", "gtpv2", "diameter":
+		pkt.Generation = capture.Generation4G
+	}
+	select {
+	case g.out <- pkt:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return sleep(ctx, messageGap)
+}
+
+// logf writes one Open5GS log line when log output is enabled.
+func (g *Generator) logf(nf, module, level, source, format string, args ...any) {
+	if g.logs != nil {
+		g.logs.line(nf, module, level, fmt.Sprintf(format, args...), source)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package demo
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// logWriter appends Open5GS-formatted lines to <dir>/<generation>/<nf>.log,
+// the files promtail tails, so demo logs flow into Loki like real ones.
+type logWriter struct {
+	dir        string
+	generation string
+	loc        *time.Location // of the time stamps, as LOG_TIMEZONE for promtail
+	files      map[string]*os.File
+}
+
+func newLogWriter(dir, generation string, loc *time.Location) *logWriter {
+	return &logWriter{dir: dir, generation: generation, loc: loc, files: make(map[string]*os.File)}
+}
+
+// line writes one log line for nf in the Open5GS format:
+//
+//	10/16 14:03:22.123: [amf] INFO: InitialUEMessage (../src/amf/ngap-handler.c:461)
+func (w *logWriter) line(nf, module, level, msg, source string) {
+	f, err := w.file(nf)
+	if err != nil {
+		log.Printf("⚠️  Demo: cannot write %s log: %v", nf, err)
+		return
+	}
+	_, _ = fmt.Fprintf(f, "%s: [%s] %s: %s (%s)\n",
+		time.Now().In(w.loc).Format("01/02 15:04:05.000"), module, level, msg, source)
+}
+
+func (w *logWriter) file(nf string) (*os.File, error) {
+	if f, ok := w.files[nf]; ok {
+		return f, nil
+	}
+	dir := filepath.Join(w.dir, w.generation)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, nf+".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w.files[nf] = f
+	return f, nil
+}
+
+func (w *logWriter) close() {
+	for nf, f := range w.files {
+		_ = f.Close()
+		delete(w.files, nf)
+	}
+}
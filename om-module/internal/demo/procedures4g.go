@@ -0,0 +1,195 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+)
+
+func (g *Generator) s1Setup(ctx context.Context) error {
+	for i, enb := range []string{"enb", "enb2"} {
+		ip := nodeIPs[enb]
+		g.logf("mme", "mme", "INFO", "../src/mme/s1ap-sctp.c:114", "eNB-S1 accepted[%s]:%d in s1_path module", ip, 32776+i)
+		if err := g.emit(ctx, capture.Packet{Protocol: "s1ap", S1APProcedureCode: 17, SrcIP: ip, DstIP: nodeIPs["mme"]}); err != nil {
+			return err
+		}
+		if err := g.emit(ctx, capture.Packet{Protocol: "s1ap", S1APProcedureCode: 17, SrcIP: nodeIPs["mme"], DstIP: ip}); err != nil {
+			return err
+		}
+		g.logf("mme", "mme", "INFO", "../src/mme/mme-context.c:3131", "[Added] Number of eNBs is now %d", i+1)
+	}
+	return nil
+}
+
+func (g *Generator) attach4G(ctx context.Context, u *ue, authFail bool) error {
+	imsi := g.imsi(u)
+	session := fmt.Sprintf("mme.localdomain;%d;%d;app_s6a", 1700000000+u.n, g.nextSeq())
+
+	g.logf("mme", "mme", "INFO", "../src/mme/s1ap-handler.c:493", "InitialUEMessage")
+	g.logf("mme", "emm", "INFO", "../src/mme/emm-sm.c:469", "[] Attach request")
+	steps := []func() error{
+		func() error { return g.s1ap(ctx, u, true, 12, capture.Packet{NASEMMType: "0x41"}) },
+		func() error { return g.s1ap(ctx, u, false, 11, capture.Packet{NASEMMType: "0x55"}) },
+		func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x56", IMSI: imsi}) },
+		func() error { return g.diameter(ctx, 318, imsi, session) },
+		func() error { return g.s1ap(ctx, u, false, 11, capture.Packet{NASEMMType: "0x52"}) },
+	}
+	if err := run(steps); err != nil {
+		return err
+	}
+
+	if authFail {
+		g.logf("mme", "emm", "WARNING", "../src/mme/emm-sm.c:1120", "Authentication failure")
+		g.logf("mme", "emm", "WARNING", "../src/mme/emm-sm.c:1128", "Authentication failure(MAC failure)")
+		return run([]func() error{
+			func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x5c", NASEMMCause: 20}) },
+			func() error { return g.s1ap(ctx, u, false, 11, capture.Packet{NASEMMType: "0x54"}) },
+			func() error { return g.s1ap(ctx, u, false, 18, capture.Packet{APCauseGroup: "nas", APCause: 1}) },
+		})
+	}
+
+	u.teid = fmt.Sprintf("0x%08x", 0x1000+u.n)
+	u.ueIP = fmt.Sprintf("192.168.100.%d", u.n+1)
+	seq := fmt.Sprintf("0x%06x", g.nextSeq())
+	modSeq := fmt.Sprintf("0x%06x", g.nextSeq())
+	steps = []func() error{
+		func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x53"}) },
+		func() error { return g.s1ap(ctx, u, false, 11, capture.Packet{NASEMMType: "0x5d"}) },
+		func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x5e"}) },
+		func() error { return g.diameter(ctx, 316, imsi, session) },
+		func() error {
+			return g.gtpv2(ctx, "mme", "sgwc", capture.Packet{
+				GTPv2MessageType: 32, GTPv2Seq: seq, GTPv2TEID: "0x00000000", GTPv2IMSI: imsi,
+				GTPv2APN: "internet", GTPv2EBI: "5", GTPv2QCI: 9, GTPv2ARP: 8,
+			})
+		},
+		func() error {
+			return g.gtpv2(ctx, "sgwc", "mme", capture.Packet{
+				GTPv2MessageType: 33, GTPv2Seq: seq, GTPv2TEID: u.teid, GTPv2Cause: "16",
+				GTPv2UEIP: u.ueIP, GTPv2EBI: "5",
+			})
+		},
+		func() error { return g.s1ap(ctx, u, false, 9, capture.Packet{NASEMMType: "0x42"}) },
+		func() error { return g.s1ap(ctx, u, true, 9, capture.Packet{}) },
+		func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x43"}) },
+		func() error {
+			return g.gtpv2(ctx, "mme", "sgwc", capture.Packet{GTPv2MessageType: 34, GTPv2Seq: modSeq, GTPv2TEID: u.teid, GTPv2EBI: "5"})
+		},
+		func() error {
+			return g.gtpv2(ctx, "sgwc", "mme", capture.Packet{GTPv2MessageType: 35, GTPv2Seq: modSeq, GTPv2TEID: u.teid, GTPv2Cause: "16"})
+		},
+	}
+	if err := run(steps); err != nil {
+		return err
+	}
+	u.attached = true
+	g.sessions++
+	g.logf("sgwc", "sgwc", "INFO", "../src/sgwc/s11-handler.c:268", "UE IMSI[%s] APN[internet]", imsi)
+	g.logf("sgwc", "sgwc", "INFO", "../src/sgwc/context.c:952", "[Added] Number of SGWC-Sessions is now %d", g.sessions)
+	g.logf("mme", "emm", "INFO", "../src/mme/emm-sm.c:1573", "[%s] Attach complete", imsi)
+	return nil
+}
+
+func (g *Generator) handover4G(ctx context.Context, u *ue) error {
+	source, target := g.cell(u, false), g.cell(u, true)
+	g.logf("mme", "mme", "INFO", "../src/mme/s1ap-handler.c:3170", "HandoverRequired")
+	g.logf("mme", "mme", "INFO", "../src/mme/s1ap-handler.c:3175", "    Source : ENB_UE_S1AP_ID[%s] MME_UE_S1AP_ID[%s] eNB[%s]", u.ranID, u.coreID, source)
+	targetRANID := g.id()
+	toTarget := func(proc int, messageType string) capture.Packet {
+		return capture.Packet{
+			Protocol: "s1ap", S1APProcedureCode: proc, APMessageType: messageType,
+			SrcIP: nodeIPs["mme"], DstIP: target, MMEUUES1APID: u.coreID,
+		}
+	}
+	fromTarget := func(proc int, messageType, cell string) capture.Packet {
+		pkt := toTarget(proc, messageType)
+		pkt.SrcIP, pkt.DstIP, pkt.ENBUUES1APID, pkt.APCellID = target, nodeIPs["mme"], targetRANID, cell
+		return pkt
+	}
+	err := run([]func() error{
+		func() error { return g.s1ap(ctx, u, true, 0, capture.Packet{APCellID: g.cellID(u, true)}) },
+		func() error { return g.emit(ctx, toTarget(1, "initiating")) },
+		func() error { return g.emit(ctx, fromTarget(1, "successful", "")) },
+		func() error { return g.s1ap(ctx, u, false, 0, capture.Packet{APMessageType: "successful"}) },
+		func() error { return g.emit(ctx, fromTarget(2, "initiating", g.cellID(u, true))) },
+	})
+	if err != nil {
+		return err
+	}
+	u.cell, u.ranID = 1-u.cell, targetRANID
+	g.logf("mme", "mme", "INFO", "../src/mme/s1ap-handler.c:3430", "    Target : ENB_UE_S1AP_ID[%s] MME_UE_S1AP_ID[%s] eNB[%s]", u.ranID, u.coreID, target)
+	return nil
+}
+
+func (g *Generator) detach4G(ctx context.Context, u *ue) error {
+	imsi := g.imsi(u)
+	seq := fmt.Sprintf("0x%06x", g.nextSeq())
+	g.logf("mme", "emm", "INFO", "../src/mme/emm-sm.c:896", "[%s] Detach request", imsi)
+	err := run([]func() error{
+		func() error { return g.s1ap(ctx, u, true, 13, capture.Packet{NASEMMType: "0x45"}) },
+		func() error {
+			return g.gtpv2(ctx, "mme", "sgwc", capture.Packet{GTPv2MessageType: 36, GTPv2Seq: seq, GTPv2TEID: u.teid, GTPv2EBI: "5"})
+		},
+		func() error {
+			return g.gtpv2(ctx, "sgwc", "mme", capture.Packet{GTPv2MessageType: 37, GTPv2Seq: seq, GTPv2TEID: u.teid, GTPv2Cause: "16"})
+		},
+		func() error { return g.s1ap(ctx, u, false, 11, capture.Packet{NASEMMType: "0x46"}) },
+		func() error { return g.s1ap(ctx, u, false, 18, capture.Packet{APCauseGroup: "nas", APCause: 2}) },
+	})
+	if err != nil {
+		return err
+	}
+	u.attached = false
+	g.sessions--
+	g.logf("sgwc", "sgwc", "INFO", "../src/sgwc/context.c:958", "[Removed] Number of SGWC-Sessions is now %d", g.sessions)
+	g.logf("smf", "smf", "INFO", "../src/smf/context.c:1789", "Removed Session: UE IMSI:[%s] DNN:[internet:0] IPv4:[%s] IPv6:[]", imsi, u.ueIP)
+	return nil
+}
+
+// s1ap sends one UE-associated S1AP message between u's eNB and the MME.
+// Messages are initiating unless pkt says otherwise; Initial UE Message and
+// Uplink NAS Transport carry the UE's cell.
+func (g *Generator) s1ap(ctx context.Context, u *ue, uplink bool, proc int, pkt capture.Packet) error {
+	pkt.Protocol = "s1ap"
+	pkt.S1APProcedureCode = proc
+	if pkt.APMessageType == "" {
+		pkt.APMessageType = "initiating"
+	}
+	if proc == 12 || proc == 13 {
+		pkt.APCellID = g.cellID(u, false)
+	}
+	pkt.SrcIP, pkt.DstIP = g.cell(u, false), nodeIPs["mme"]
+	if !uplink {
+		pkt.SrcIP, pkt.DstIP = pkt.DstIP, pkt.SrcIP
+	}
+	pkt.ENBUUES1APID = u.ranID
+	if proc != 12 { // InitialUEMessage carries no MME-UE-S1AP-ID yet
+		pkt.MMEUUES1APID = u.coreID
+	}
+	return g.emit(ctx, pkt)
+}
+
+// diameter sends one S6a request from the MME to the HSS and its answer.
+func (g *Generator) diameter(ctx context.Context, cmd int, imsi, session string) error {
+	req := capture.Packet{
+		Protocol: "diameter", SrcIP: nodeIPs["mme"], DstIP: nodeIPs["hss"],
+		DiameterCmdCode: cmd, DiameterIsRequest: true, DiameterIMSI: imsi,
+		DiameterSessionID: session, DiameterOriginHost: "mme.localdomain",
+	}
+	if err := g.emit(ctx, req); err != nil {
+		return err
+	}
+	return g.emit(ctx, capture.Packet{
+		Protocol: "diameter", SrcIP: nodeIPs["hss"], DstIP: nodeIPs["mme"],
+		DiameterCmdCode: cmd, DiameterSessionID: session,
+		DiameterResultCode: "2001", DiameterOriginHost: "hss.localdomain",
+	})
+}
+
+// gtpv2 sends one GTPv2-C message on S11.
+func (g *Generator) gtpv2(ctx context.Context, from, to string, pkt capture.Packet) error {
+	pkt.Protocol = "gtpv2"
+	pkt.SrcIP, pkt.DstIP = nodeIPs[from], nodeIPs[to]
+	return g.emit(ctx, pkt)
+}
@@ -0,0 +1,230 @@
+package demo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+)
+
+// sbiClientPort is the ephemeral port every synthetic SBI client uses.
+const sbiClientPort = 45000
+
+func (g *Generator) ngSetup(ctx context.Context) error {
+	for i, gnb := range []string{"gnb", "gnb2"} {
+		ip := nodeIPs[gnb]
+		g.logf("amf", "amf", "INFO", "../src/amf/ngap-sctp.c:113", "gNB-N2 accepted[%s]:%d in ng-path module", ip, 40902+i)
+		if err := g.emit(ctx, capture.Packet{Protocol: "ngap", NGAPProcedureCode: 21, SrcIP: ip, DstIP: nodeIPs["amf"]}); err != nil {
+			return err
+		}
+		if err := g.emit(ctx, capture.Packet{Protocol: "ngap", NGAPProcedureCode: 21, SrcIP: nodeIPs["amf"], DstIP: ip}); err != nil {
+			return err
+		}
+		g.logf("amf", "amf", "INFO", "../src/amf/context.c:1277", "[Added] Number of gNBs is now %d", i+1)
+	}
+	return nil
+}
+
+func (g *Generator) register5G(ctx context.Context, u *ue, authFail bool) error {
+	imsi, suci := g.imsi(u), g.suci(u)
+
+	g.logf("amf", "amf", "INFO", "../src/amf/ngap-handler.c:461", "InitialUEMessage")
+	g.logf("amf", "amf", "INFO", "../src/amf/ngap-handler.c:622", "    RAN_UE_NGAP_ID[%s] AMF_UE_NGAP_ID[%s] TAC[1] CellID[%s]", u.ranID, u.coreID, g.cellID(u, false))
+	g.logf("amf", "amf", "INFO", "../src/amf/context.c:1912", "[%s] Unknown UE by SUCI", suci)
+	g.logf("amf", "gmm", "INFO", "../src/amf/gmm-sm.c:1623", "Registration request")
+	steps := []func() error{
+		func() error { return g.ngap(ctx, u, true, 15, capture.Packet{NASMMType: "0x41", SUCIMsin: u.msin}) },
+		func() error { return g.sbi(ctx, "amf", "ausf", "POST", "/nausf-auth/v1/ue-authentications", "", 201) },
+		func() error {
+			return g.sbi(ctx, "ausf", "udm", "POST", "/nudm-ueau/v1/"+suci+"/security-information/generate-auth-data", "", 200)
+		},
+		func() error { return g.ngap(ctx, u, false, 4, capture.Packet{NASMMType: "0x56"}) },
+	}
+	if err := run(steps); err != nil {
+		return err
+	}
+
+	if authFail {
+		g.logf("amf", "gmm", "WARNING", "../src/amf/gmm-sm.c:2088", "[%s] Authentication failure [20]", suci)
+		g.logf("amf", "gmm", "WARNING", "../src/amf/gmm-sm.c:2113", "Authentication failure(MAC failure)")
+		g.logf("amf", "amf", "WARNING", "../src/amf/nas-path.c:531", "[%s] Authentication reject", suci)
+		return run([]func() error{
+			func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASMMType: "0x59", NAS5GMMCause: 20}) },
+			func() error { return g.ngap(ctx, u, false, 4, capture.Packet{NASMMType: "0x58"}) },
+			func() error { return g.ngap(ctx, u, false, 40, capture.Packet{APCauseGroup: "nas", APCause: 1}) },
+		})
+	}
+
+	steps = []func() error{
+		func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASMMType: "0x57"}) },
+		func() error {
+			return g.sbi(ctx, "amf", "ausf", "PUT", "/nausf-auth/v1/ue-authentications/"+suci+"/5g-aka-confirmation", "", 200)
+		},
+		func() error { return g.ngap(ctx, u, false, 4, capture.Packet{NASMMType: "0x5d"}) },
+		func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASMMType: "0x5e"}) },
+		func() error {
+			return g.sbi(ctx, "amf", "udm", "PUT", "/nudm-uecm/v1/imsi-"+imsi+"/registrations/amf-3gpp-access", imsi, 201)
+		},
+		func() error { return g.sbi(ctx, "amf", "udm", "GET", "/nudm-sdm/v2/imsi-"+imsi+"/am-data", imsi, 200) },
+		func() error { return g.ngap(ctx, u, false, 14, capture.Packet{NASMMType: "0x42"}) },
+		func() error { return g.ngap(ctx, u, true, 14, capture.Packet{}) },
+		func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASMMType: "0x43"}) },
+	}
+	if err := run(steps); err != nil {
+		return err
+	}
+	u.attached = true
+	g.logf("amf", "gmm", "INFO", "../src/amf/gmm-sm.c:3001", "[imsi-%s] Registration complete", imsi)
+
+	return g.pduSession5G(ctx, u)
+}
+
+func (g *Generator) pduSession5G(ctx context.Context, u *ue) error {
+	imsi := g.imsi(u)
+	u.ueIP = fmt.Sprintf("192.168.100.%d", u.n+1)
+	seq := g.nextSeq()
+	seid := fmt.Sprintf("0x%x", 0x100+u.n)
+
+	steps := []func() error{
+		func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASSMType: "0xc1", PDUSessionID: 1}) },
+		func() error { return g.sbi(ctx, "amf", "smf", "POST", "/nsmf-pdusession/v1/sm-contexts", "", 201) },
+		func() error {
+			return g.pfcp(ctx, "smf", "upf", capture.Packet{PFCPMessageType: 50, PFCPSeqNo: seq, PFCPIMSI: imsi, PFCPDNN: "internet"})
+		},
+		func() error {
+			return g.pfcp(ctx, "upf", "smf", capture.Packet{PFCPMessageType: 51, PFCPSeqNo: seq, PFCPSEID: seid, PFCPUEIP: u.ueIP, PFCPCause: "1"})
+		},
+		func() error {
+			return g.ngap(ctx, u, false, 29, capture.Packet{NASSMType: "0xc2", PDUSessionID: 1, QoSFlowIDs: []int{1}, FiveQIs: []int{9}})
+		},
+		func() error { return g.ngap(ctx, u, true, 29, capture.Packet{PDUSessionID: 1}) },
+	}
+	if err := run(steps); err != nil {
+		return err
+	}
+	g.sessions++
+	g.logf("smf", "smf", "INFO", "../src/smf/context.c:3381", "[Added] Number of SMF-Sessions is now %d", g.sessions)
+	g.logf("smf", "smf", "INFO", "../src/smf/npcf-handler.c:594", "UE SUPI[imsi-%s] DNN[internet] IPv4[%s] IPv6[]", imsi, u.ueIP)
+	g.logf("upf", "upf", "INFO", "../src/upf/context.c:212", "[Added] Number of UPF-Sessions is now %d", g.sessions)
+	g.logf("upf", "upf", "INFO", "../src/upf/context.c:498", "UE F-SEID[UP:%s CP:%s] APN[internet] PDN-Type[1] IPv4[%s] IPv6[]", seid, seid, u.ueIP)
+	return nil
+}
+
+func (g *Generator) handover5G(ctx context.Context, u *ue) error {
+	source, target := g.cell(u, false), g.cell(u, true)
+	g.logf("amf", "amf", "INFO", "../src/amf/ngap-handler.c:3256", "HandoverRequired")
+	g.logf("amf", "amf", "INFO", "../src/amf/ngap-handler.c:3263", "    Source : RAN_UE_NGAP_ID[%s] AMF_UE_NGAP_ID[%s] gNB[%s]", u.ranID, u.coreID, source)
+	targetRANID := g.id()
+	toTarget := func(proc int, messageType string) capture.Packet {
+		return capture.Packet{
+			Protocol: "ngap", NGAPProcedureCode: proc, APMessageType: messageType,
+			SrcIP: nodeIPs["amf"], DstIP: target, RANUENGAPId: targetRANID, AMFUENGAPId: u.coreID,
+		}
+	}
+	fromTarget := func(proc int, messageType, cell string) capture.Packet {
+		pkt := toTarget(proc, messageType)
+		pkt.SrcIP, pkt.DstIP, pkt.APCellID = target, nodeIPs["amf"], cell
+		return pkt
+	}
+	err := run([]func() error{
+		func() error { return g.ngap(ctx, u, true, 12, capture.Packet{APCellID: g.cellID(u, true)}) },
+		func() error { return g.emit(ctx, toTarget(13, "initiating")) },
+		func() error { return g.emit(ctx, fromTarget(13, "successful", "")) },
+		func() error { return g.ngap(ctx, u, false, 12, capture.Packet{APMessageType: "successful"}) },
+		func() error { return g.emit(ctx, fromTarget(11, "initiating", g.cellID(u, true))) },
+	})
+	if err != nil {
+		return err
+	}
+	u.cell, u.ranID = 1-u.cell, targetRANID
+	g.logf("amf", "amf", "INFO", "../src/amf/ngap-handler.c:3880", "    Target : RAN_UE_NGAP_ID[%s] AMF_UE_NGAP_ID[%s] gNB[%s]", u.ranID, u.coreID, target)
+	return nil
+}
+
+func (g *Generator) deregister5G(ctx context.Context, u *ue) error {
+	imsi := g.imsi(u)
+	seq := g.nextSeq()
+	g.logf("amf", "gmm", "INFO", "../src/amf/gmm-sm.c:1753", "[imsi-%s] Deregistration request", imsi)
+	err := run([]func() error{
+		func() error { return g.ngap(ctx, u, true, 46, capture.Packet{NASMMType: "0x45"}) },
+		func() error { return g.pfcp(ctx, "smf", "upf", capture.Packet{PFCPMessageType: 54, PFCPSeqNo: seq}) },
+		func() error {
+			return g.pfcp(ctx, "upf", "smf", capture.Packet{PFCPMessageType: 55, PFCPSeqNo: seq, PFCPCause: "1"})
+		},
+		func() error { return g.ngap(ctx, u, false, 28, capture.Packet{PDUSessionID: 1}) },
+		func() error { return g.ngap(ctx, u, false, 4, capture.Packet{NASMMType: "0x46"}) },
+		func() error { return g.ngap(ctx, u, false, 40, capture.Packet{APCauseGroup: "nas", APCause: 2}) },
+	})
+	if err != nil {
+		return err
+	}
+	u.attached = false
+	g.sessions--
+	g.logf("smf", "smf", "INFO", "../src/smf/context.c:3412", "[Removed] Number of SMF-Sessions is now %d", g.sessions)
+	g.logf("upf", "upf", "INFO", "../src/upf/context.c:257", "[Removed] Number of UPF-Sessions is now %d", g.sessions)
+	return nil
+}
+
+// ngap sends one UE-associated NGAP message between u's gNB and the AMF.
+// Messages are initiating unless pkt says otherwise; Initial UE Message and
+// Uplink NAS Transport carry the UE's cell.
+func (g *Generator) ngap(ctx context.Context, u *ue, uplink bool, proc int, pkt capture.Packet) error {
+	pkt.Protocol = "ngap"
+	pkt.NGAPProcedureCode = proc
+	if pkt.APMessageType == "" {
+		pkt.APMessageType = "initiating"
+	}
+	if proc == 15 || proc == 46 {
+		pkt.APCellID = g.cellID(u, false)
+	}
+	pkt.SrcIP, pkt.DstIP = g.cell(u, false), nodeIPs["amf"]
+	if !uplink {
+		pkt.SrcIP, pkt.DstIP = pkt.DstIP, pkt.SrcIP
+	}
+	pkt.RANUENGAPId = u.ranID
+	if proc != 15 { // InitialUEMessage carries no AMF-UE-NGAP-ID yet
+		pkt.AMFUENGAPId = u.coreID
+	}
+	return g.emit(ctx, pkt)
+}
+
+// sbi sends one HTTP/2 request from client to server and its response.
+func (g *Generator) sbi(ctx context.Context, client, server, method, path, imsi string, status int) error {
+	g.streamID += 2
+	service, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	req := capture.Packet{
+		Protocol: "sbi",
+		SrcIP:    nodeIPs[client], SrcPort: sbiClientPort,
+		DstIP: nodeIPs[server], DstPort: 7777,
+		SBIMethod: method, SBIPath: path, SBIService: service,
+		SBIUserAgent: strings.ToUpper(client), SBIIMSI: imsi, SBIStreamID: g.streamID,
+	}
+	if err := g.emit(ctx, req); err != nil {
+		return err
+	}
+	return g.emit(ctx, capture.Packet{
+		Protocol: "sbi",
+		SrcIP:    nodeIPs[server], SrcPort: 7777,
+		DstIP: nodeIPs[client], DstPort: sbiClientPort,
+		SBIStatus: strconv.Itoa(status), SBIStreamID: g.streamID,
+	})
+}
+
+// pfcp sends one PFCP message between two nodes.
+func (g *Generator) pfcp(ctx context.Context, from, to string, pkt capture.Packet) error {
+	pkt.Protocol = "pfcp"
+	pkt.SrcIP, pkt.DstIP = nodeIPs[from], nodeIPs[to]
+	return g.emit(ctx, pkt)
+}
+
+// run calls each step in order and stops at the first error.
+func run(steps []func() error) error {
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package demo generates synthetic signalling and Open5GS log lines for
+// classroom demonstrations without RAN hardware. A scenario script drives the
+// generator; its packets feed the normal pipeline in place of the capture.
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+)
+
+// DefaultScenario is used when DEMO_SCENARIO is "default". It walks through
+// a typical 5G lab: three UEs register, a fourth fails authentication, one
+// UE is handed over to the second gNB, then everybody detaches.
+const DefaultScenario = `# Built-in classroom demo
+generation 5g
+ran-setup
+attach 1-3
+attach 4 auth-fail
+wait 20s
+handover 1
+wait 20s
+detach 1-3
+wait 30s
+`
+
+// Step operations.
+const (
+	OpRANSetup = "ran-setup" // NG Setup / S1 Setup of both gNBs / eNBs
+	OpAttach   = "attach"    // registration/attach plus PDU session/default bearer
+	OpHandover = "handover"  // move a UE to the other gNB/eNB
+	OpDetach   = "detach"    // deregistration/detach, releasing its sessions
+	OpWait     = "wait"      // pause
+)
+
+// Step is one line of a scenario script.
+type Step struct {
+	Op       string
+	UEs      []int         // 1-based UE numbers
+	AuthFail bool          // attach only: the UE answers with a MAC failure
+	Wait     time.Duration // wait only
+}
+
+// Scenario is a parsed scenario script. The generator plays it in a loop.
+type Scenario struct {
+	Generation string // capture.Generation4G or capture.Generation5G
+	Steps      []Step
+}
+
+// LoadScenario returns the built-in scenario for "default", or parses the
+// script file at path otherwise.
+func LoadScenario(path string) (*Scenario, error) {
+	if path == "default" {
+		return ParseScenario(strings.NewReader(DefaultScenario))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(f)
+}
+
+// ParseScenario reads a scenario script: one step per line, "#" starts a
+// comment. Recognised lines:
+//
+//	generation 4g|5g
+//	ran-setup
+//	attach <ues> [auth-fail]
+//	handover <ues>
+//	detach <ues>
+//	wait <duration>
+//
+// <ues> is a comma-separated list of UE numbers or ranges, e.g. "1-3,5".
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	sc := &Scenario{Generation: capture.Generation5G}
+	s := bufio.NewScanner(r)
+	for lineNo := 1; s.Scan(); lineNo++ {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		step, err := parseStep(sc, fields)
+		if err != nil {
+			return nil, fmt.Errorf("scenario line %d: %w", lineNo, err)
+		}
+		if step != nil {
+			sc.Steps = append(sc.Steps, *step)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(sc.Steps) == 0 {
+		return nil, fmt.Errorf("scenario has no steps")
+	}
+	return sc, nil
+}
+
+func parseStep(sc *Scenario, fields []string) (*Step, error) {
+	op, args := fields[0], fields[1:]
+	switch op {
+	case "generation":
+		if len(args) != 1 || (args[0] != capture.Generation4G && args[0] != capture.Generation5G) {
+			return nil, fmt.Errorf("usage: generation 4g|5g")
+		}
+		sc.Generation = args[0]
+		return nil, nil
+
+	case OpRANSetup:
+		return &Step{Op: op}, nil
+
+	case OpAttach, OpHandover, OpDetach:
+		if len(args) == 0 {
+			return nil, fmt.Errorf("usage: %s <ues>", op)
+		}
+		ues, err := parseUEs(args[0])
+		if err != nil {
+			return nil, err
+		}
+		step := &Step{Op: op, UEs: ues}
+		for _, a := range args[1:] {
+			if op != OpAttach || a != "auth-fail" {
+				return nil, fmt.Errorf("unexpected %q", a)
+			}
+			step.AuthFail = true
+		}
+		return step, nil
+
+	case OpWait:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("usage: wait <duration>")
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Step{Op: op, Wait: d}, nil
+	}
+	return nil, fmt.Errorf("unknown step %q", op)
+}
+
+func parseUEs(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("bad UE number %q", part)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("bad UE range %q", part)
+			}
+		}
+		for n := first; n <= last; n++ {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
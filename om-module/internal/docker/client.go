@@ -7,15 +7,19 @@ import (
 	"io"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Client wraps the Docker SDK client.
 type Client struct {
-	cli *client.Client
+	cli   *client.Client
+	calls *prometheus.CounterVec // nil until Instrument
 }
 
 // New creates a Docker client connected to the given socket path.
@@ -35,18 +39,49 @@ func (c *Client) Close() error {
 	return c.cli.Close()
 }
 
+// Instrument registers om_docker_api_calls_total on reg and counts the
+// Docker API requests the client makes from then on, the load the module
+// puts on the daemon.
+func (c *Client) Instrument(reg prometheus.Registerer) {
+	c.calls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "om", Subsystem: "docker", Name: "api_calls_total",
+		Help: "Docker API requests made by the module, per call.",
+	}, []string{"call"})
+	reg.MustRegister(c.calls)
+}
+
+// count records one request to the API call.
+func (c *Client) count(call string) {
+	if c.calls != nil {
+		c.calls.WithLabelValues(call).Inc()
+	}
+}
+
+// Ping checks that the Docker daemon answers on the socket.
+func (c *Client) Ping(ctx context.Context) error {
+	c.count("ping")
+	_, err := c.cli.Ping(ctx)
+	return err
+}
+
 // ContainerInfo is the subset of Docker container data the O&M module cares about.
 type ContainerInfo struct {
-	ID     string
-	Name   string
-	State  string
-	Image  string
-	Labels map[string]string
+	ID      string
+	Name    string
+	State   string
+	Image   string
+	ImageID string // sha256 of the image the container was created from
+	Labels  map[string]string
+
+	// Networks maps each Docker network the container is attached to to
+	// the MAC address of its interface on that network.
+	Networks map[string]string
 }
 
 // ListContainers returns all containers whose Compose project label matches
 // the given project name. If project is empty, all containers are returned.
 func (c *Client) ListContainers(ctx context.Context, project string) ([]ContainerInfo, error) {
+	c.count("container_list")
 	all, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, err
@@ -69,17 +104,61 @@ func (c *Client) ListContainers(ctx context.Context, project string) ([]Containe
 			}
 		}
 
+		networks := make(map[string]string)
+		if ct.NetworkSettings != nil {
+			for net, ep := range ct.NetworkSettings.Networks {
+				if ep != nil {
+					networks[net] = ep.MacAddress
+				}
+			}
+		}
+
 		result = append(result, ContainerInfo{
-			ID:     ct.ID,
-			Name:   name,
-			State:  ct.State,
-			Image:  ct.Image,
-			Labels: ct.Labels,
+			ID:       ct.ID,
+			Name:     name,
+			State:    ct.State,
+			Image:    ct.Image,
+			ImageID:  ct.ImageID,
+			Labels:   ct.Labels,
+			Networks: networks,
 		})
 	}
 	return result, nil
 }
 
+// NetworkLabels returns the labels of every Docker network, by network name.
+func (c *Client) NetworkLabels(ctx context.Context) (map[string]map[string]string, error) {
+	c.count("network_list")
+	nets, err := c.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]string, len(nets))
+	for _, n := range nets {
+		result[n.Name] = n.Labels
+	}
+	return result, nil
+}
+
+// NetworkSubnets returns the IPv4 subnets (CIDR) of every Docker network,
+// by network name; networks without IPAM configuration have none.
+func (c *Client) NetworkSubnets(ctx context.Context) (map[string][]string, error) {
+	c.count("network_list")
+	nets, err := c.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string, len(nets))
+	for _, n := range nets {
+		for _, cfg := range n.IPAM.Config {
+			if cfg.Subnet != "" && !strings.Contains(cfg.Subnet, ":") {
+				result[n.Name] = append(result[n.Name], cfg.Subnet)
+			}
+		}
+	}
+	return result, nil
+}
+
 // GetBridgeInterface returns the Linux bridge interface name for the given
 // Docker network name (e.g. "docker_open5gs_default").
 //
@@ -88,6 +167,7 @@ func (c *Client) ListContainers(ctx context.Context, project string) ([]Containe
 // returning it, so the caller can rely on the result being usable.
 func (c *Client) GetBridgeInterface(ctx context.Context, networkName string) (string, error) {
 	// Inspect the named network to get its ID.
+	c.count("network_inspect")
 	nr, err := c.cli.NetworkInspect(ctx, networkName, network.InspectOptions{})
 	if err != nil {
 		return "", fmt.Errorf("docker: inspect network %q: %w", networkName, err)
@@ -106,6 +186,7 @@ func (c *Client) GetBridgeInterface(ctx context.Context, networkName string) (st
 // containers attached to the given Docker network. The CIDR suffix is stripped
 // from the IP (e.g. "172.22.0.10/24" becomes "172.22.0.10").
 func (c *Client) GetNetworkContainerIPs(ctx context.Context, networkName string) (map[string]string, error) {
+	c.count("network_inspect")
 	nr, err := c.cli.NetworkInspect(ctx, networkName, network.InspectOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("docker: inspect network %q: %w", networkName, err)
@@ -159,6 +240,7 @@ type RawStats struct {
 
 // GetStats fetches a single non-streaming stats snapshot for the given container ID.
 func (c *Client) GetStats(ctx context.Context, containerID string) (*RawStats, error) {
+	c.count("container_stats")
 	resp, err := c.cli.ContainerStats(ctx, containerID, false)
 	if err != nil {
 		return nil, err
@@ -180,3 +262,105 @@ func (c *Client) GetStats(ctx context.Context, containerID string) (*RawStats, e
 	}
 	return &stats, nil
 }
+
+// Exec runs cmd inside the given container and waits for it to exit. It
+// returns the combined stdout and stderr and the command's exit code; err is
+// only set when the command could not be run at all.
+func (c *Client) Exec(ctx context.Context, containerName string, cmd []string) (string, int, error) {
+	c.count("exec_create")
+	created, err := c.cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", -1, err
+	}
+	c.count("exec_attach")
+	att, err := c.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", -1, err
+	}
+	defer att.Close()
+
+	var out strings.Builder
+	if _, err := stdcopy.StdCopy(&out, &out, att.Reader); err != nil {
+		return out.String(), -1, fmt.Errorf("exec %s: %w", cmd[0], err)
+	}
+	c.count("exec_inspect")
+	inspect, err := c.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return out.String(), -1, err
+	}
+	return out.String(), inspect.ExitCode, nil
+}
+
+// ContainerState is the subset of a container inspection the O&M module
+// reports: the Docker state, the healthcheck status when the image or the
+// compose file defines one, and the restarts done by the restart policy.
+type ContainerState struct {
+	Status       string
+	Health       string // starting | healthy | unhealthy; empty without a healthcheck
+	StartedAt    time.Time
+	RestartCount int
+}
+
+// Inspect returns the state of the given container.
+func (c *Client) Inspect(ctx context.Context, containerName string) (ContainerState, error) {
+	c.count("container_inspect")
+	resp, err := c.cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return ContainerState{}, err
+	}
+	var st ContainerState
+	if resp.ContainerJSONBase == nil || resp.State == nil {
+		return st, nil
+	}
+	st.RestartCount = resp.RestartCount
+	st.Status = string(resp.State.Status)
+	if resp.State.Health != nil {
+		st.Health = string(resp.State.Health.Status)
+	}
+	st.StartedAt, _ = time.Parse(time.RFC3339Nano, resp.State.StartedAt)
+	return st, nil
+}
+
+// Env returns the environment variables the given container was created
+// with.
+func (c *Client) Env(ctx context.Context, containerID string) (map[string]string, error) {
+	c.count("container_inspect")
+	resp, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	if resp.Config != nil {
+		for _, kv := range resp.Config.Env {
+			k, v, _ := strings.Cut(kv, "=")
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+// Restart stops the given container, waiting up to timeout for it to exit
+// before killing it, and starts it again.
+func (c *Client) Restart(ctx context.Context, containerName string, timeout time.Duration) error {
+	secs := int(timeout.Seconds())
+	c.count("container_restart")
+	return c.cli.ContainerRestart(ctx, containerName, container.StopOptions{Timeout: &secs})
+}
+
+// Start starts the given container; starting a running one is a no-op.
+func (c *Client) Start(ctx context.Context, containerName string) error {
+	c.count("container_start")
+	return c.cli.ContainerStart(ctx, containerName, container.StartOptions{})
+}
+
+// Stop stops the given container, waiting up to timeout for it to exit
+// before killing it; stopping a stopped one is a no-op.
+func (c *Client) Stop(ctx context.Context, containerName string, timeout time.Duration) error {
+	secs := int(timeout.Seconds())
+	c.count("container_stop")
+	return c.cli.ContainerStop(ctx, containerName, container.StopOptions{Timeout: &secs})
+}
@@ -0,0 +1,234 @@
+// Package educontent lets an institution add its own teaching material —
+// course notes, links to the local lab manual, specifications in the
+// students' language — next to what the module explains on its own. The
+// material comes from providers declared in EDUCATIONAL_PROVIDERS and is
+// merged into the insight cards (/educational/insights), the rendered
+// dashboards and the walkthroughs of /nas/security and /ims.
+//
+// A provider answers for topics: an insight signal ("auth_failures"), a
+// dashboard uid or a walkthrough ("5g-aka", "sip"). The module ships the
+// "file" kind, a JSON file:
+//
+//	{
+//	  "signal":    {"auth_failures": [{"kind": "note", "title": "Práctica 3", "text": "…"}]},
+//	  "dashboard": {"*": [{"kind": "link", "title": "Manual del laboratorio", "url": "https://…"}]},
+//	  "flow":      {"5g-aka": [{"kind": "spec", "title": "TS 33.501 §6.1.3.2", "url": "https://…"}]}
+//	}
+//
+// "*" answers for every topic of its kind. Other kinds are compiled in:
+// a package calls Register from its init function and is imported by main.
+package educontent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Topic kinds.
+const (
+	TopicSignal    = "signal"    // insight card, by incident signal name
+	TopicDashboard = "dashboard" // rendered dashboard, by uid
+	TopicFlow      = "flow"      // walkthrough: FlowAKA, FlowSIP
+)
+
+// Walkthroughs that take content.
+const (
+	FlowAKA = "5g-aka" // /nas/security
+	FlowSIP = "sip"    // /ims
+)
+
+// Item kinds, trimmed by EDUCATIONAL_FEATURES like the built-in content:
+// notes and links with "notes", hints with "hints", specs with "spec".
+const (
+	KindNote = "note"
+	KindLink = "link"
+	KindHint = "hint"
+	KindSpec = "spec"
+)
+
+// Topic is what content is asked for.
+type Topic struct {
+	Kind string
+	Name string
+}
+
+// Item is one piece of institution content.
+type Item struct {
+	Provider string `json:"provider"`
+	Kind     string `json:"kind"`
+	Title    string `json:"title"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Provider supplies content. Content is called on request paths and must
+// not block; a provider backed by something slow caches it.
+type Provider interface {
+	Content(t Topic) []Item
+}
+
+// Factory builds a provider from the argument of its EDUCATIONAL_PROVIDERS
+// entry ("file:<path>" → "<path>").
+type Factory func(arg string) (Provider, error)
+
+var factories = map[string]Factory{"file": loadFile}
+
+// Register makes a provider kind available to EDUCATIONAL_PROVIDERS. It is
+// meant to be called from init functions and panics on a duplicate kind.
+func Register(kind string, f Factory) {
+	if _, ok := factories[kind]; ok {
+		panic("educontent: provider kind " + kind + " registered twice")
+	}
+	factories[kind] = f
+}
+
+type named struct {
+	name string
+	p    Provider
+}
+
+// Providers are the configured providers, asked in the order declared.
+// A nil *Providers has no content.
+type Providers struct {
+	list []named
+}
+
+// Load builds the providers of spec, a comma-separated list of
+// name=kind:arg entries, e.g. "curso=file:/mnt/om-module/educational-content.json".
+// The name is shown as the provider of its items. An empty spec yields no
+// providers.
+func Load(spec string) (*Providers, error) {
+	ps := &Providers{}
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, def, ok := strings.Cut(entry, "=")
+		kind, arg, _ := strings.Cut(def, ":")
+		if !ok || name == "" || kind == "" {
+			return nil, fmt.Errorf("provider %q: want name=kind:arg", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("provider %q declared twice", name)
+		}
+		seen[name] = true
+
+		f := factories[kind]
+		if f == nil {
+			return nil, fmt.Errorf("provider %q: unknown kind %q (known: %s)", name, kind, strings.Join(Kinds(), ", "))
+		}
+		p, err := f(arg)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %w", name, err)
+		}
+		ps.list = append(ps.list, named{name, p})
+	}
+	return ps, nil
+}
+
+// Kinds lists the registered provider kinds.
+func Kinds() []string {
+	out := make([]string, 0, len(factories))
+	for k := range factories {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Names lists the providers, in order.
+func (ps *Providers) Names() []string {
+	if ps == nil {
+		return nil
+	}
+	out := make([]string, 0, len(ps.list))
+	for _, n := range ps.list {
+		out = append(out, n.name)
+	}
+	return out
+}
+
+// Content returns the items every provider has for t, each stamped with
+// the name of its provider.
+func (ps *Providers) Content(t Topic) []Item {
+	if ps == nil {
+		return nil
+	}
+	var out []Item
+	for _, n := range ps.list {
+		for _, it := range n.p.Content(t) {
+			it.Provider = n.name
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// Markdown renders items as a list for a Grafana text panel, or "" for
+// none.
+func Markdown(title string, items []Item) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	for _, it := range items {
+		if it.URL != "" {
+			fmt.Fprintf(&b, "- **[%s](%s)**", it.Title, it.URL)
+		} else {
+			fmt.Fprintf(&b, "- **%s**", it.Title)
+		}
+		if it.Text != "" {
+			fmt.Fprintf(&b, " — %s", it.Text)
+		}
+		fmt.Fprintf(&b, " _(%s)_\n", it.Provider)
+	}
+	return b.String()
+}
+
+// --- file provider -----------------------------------------------------------
+
+// fileProvider serves the content of a JSON file, read once at startup.
+type fileProvider map[string]map[string][]Item // topic kind → name → items
+
+func loadFile(p string) (Provider, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var f fileProvider
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	for kind, byName := range f {
+		switch kind {
+		case TopicSignal, TopicDashboard, TopicFlow:
+		default:
+			return nil, fmt.Errorf("%s: unknown topic kind %q", p, kind)
+		}
+		for name, items := range byName {
+			for _, it := range items {
+				switch it.Kind {
+				case KindNote, KindLink, KindHint, KindSpec:
+				default:
+					return nil, fmt.Errorf("%s: %s %q: unknown item kind %q", p, kind, name, it.Kind)
+				}
+				if it.Title == "" {
+					return nil, fmt.Errorf("%s: %s %q: item without title", p, kind, name)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// Content returns the items for t.Name, then those for "*".
+func (f fileProvider) Content(t Topic) []Item {
+	byName := f[t.Kind]
+	return append(append([]Item{}, byName[t.Name]...), byName["*"]...)
+}
@@ -0,0 +1,300 @@
+// Package envsynth suggests a .env for a testbed checkout that has none.
+// The compose files take every address, subnet and identity of the lab from
+// .env, and without it nothing starts; a student who cloned the project
+// without it (or copied it from another machine) is stuck before the first
+// lab. Synthesize starts from the .env the testbed ships with and adapts it
+// to the Docker host:
+//
+//   - TEST_NETWORK is the subnet of the testbed network when it exists, the
+//     default one otherwise, or the first free 172.16–31.0.0/24 when the
+//     default overlaps another Docker network;
+//   - every <NF>_IP the compose files use as a static address takes the
+//     address of its container when it runs, and the default address moved
+//     into the chosen subnet otherwise;
+//   - <NF>_ADVERTISE_IP follows <NF>_IP.
+//
+// Ports and identities keep their defaults. The result is a suggestion for
+// the student to review, never written over an existing .env.
+package envsynth
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+//go:embed defaults.env
+var defaults []byte
+
+// Defaults returns the .env the testbed ships with.
+func Defaults() []byte { return append([]byte{}, defaults...) }
+
+// Sources of a value.
+const (
+	SourceDefault  = "default"
+	SourceDetected = "detected" // address of the running container
+	SourceRebased  = "rebased"  // default address moved into the chosen subnet
+	SourceDerived  = "derived"  // follows another variable
+)
+
+// Probe is what was found on the Docker host.
+type Probe struct {
+	// Network is the testbed network and Subnet its IPv4 subnet; Subnet is
+	// empty when the network does not exist yet.
+	Network string
+	Subnet  string
+	// Subnets are the IPv4 subnets of every Docker network, by name.
+	Subnets map[string][]string
+	// IPs maps the containers attached to Network to their address.
+	IPs map[string]string
+}
+
+// Value is one variable of the suggestion that does not keep its default,
+// or that was detected.
+type Value struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  string `json:"source"`
+	// Container is the container the value was read from, when detected.
+	Container string `json:"container,omitempty"`
+}
+
+// Result is a suggested .env.
+type Result struct {
+	Network  string   `json:"network"`
+	Subnet   string   `json:"subnet"`
+	Values   []Value  `json:"values"`
+	Warnings []string `json:"warnings"`
+	// Env is the suggested file.
+	Env []byte `json:"-"`
+}
+
+// staticAddress matches `ipv4_address: ${MME_IP}` in a compose file.
+var staticAddress = regexp.MustCompile(`^\$\{([A-Z0-9_]+)\}$`)
+
+// ComposeAddresses reads the compose files (*.yaml) of dir and returns the
+// container each variable is the static address of, e.g. MME_IP → mme.
+func ComposeAddresses(dir string) (map[string]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var compose struct {
+			Services map[string]struct {
+				ContainerName string `yaml:"container_name"`
+				Networks      any    `yaml:"networks"`
+			} `yaml:"services"`
+		}
+		if yaml.Unmarshal(data, &compose) != nil {
+			continue // not a compose file
+		}
+		for name, svc := range compose.Services {
+			if svc.ContainerName != "" {
+				name = svc.ContainerName
+			}
+			nets, _ := svc.Networks.(map[any]any)
+			for _, n := range nets {
+				attrs, _ := n.(map[any]any)
+				addr, _ := attrs["ipv4_address"].(string)
+				if m := staticAddress.FindStringSubmatch(addr); m != nil {
+					out[m[1]] = name
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// line is one KEY=VALUE line of a .env; rest is what follows the value
+// (spaces and an inline comment).
+type line struct {
+	key, value, rest string
+}
+
+var assignment = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(\S*)(.*)$`)
+
+// Synthesize adapts template, a .env, to the host p describes. addresses
+// maps variables to their containers, as ComposeAddresses returns.
+func Synthesize(template []byte, addresses map[string]string, p Probe) (Result, error) {
+	lines := strings.Split(strings.TrimRight(string(template), "\n"), "\n")
+	parsed := make([]*line, len(lines))
+	env := make(map[string]string)
+	for i, l := range lines {
+		if m := assignment.FindStringSubmatch(l); m != nil {
+			parsed[i] = &line{key: m[1], value: m[2], rest: m[3]}
+			env[m[1]] = m[2]
+		}
+	}
+	def, err := netip.ParsePrefix(env["TEST_NETWORK"])
+	if err != nil {
+		return Result{}, fmt.Errorf("template TEST_NETWORK: %w", err)
+	}
+	def = def.Masked()
+
+	res := Result{Network: p.Network, Values: []Value{}, Warnings: []string{}}
+	subnet, source := def, SourceDefault
+	switch {
+	case p.Subnet != "":
+		subnet, err = netip.ParsePrefix(p.Subnet)
+		if err != nil {
+			return Result{}, fmt.Errorf("network %s: %w", p.Network, err)
+		}
+		subnet, source = subnet.Masked(), SourceDetected
+	default:
+		if other := overlapping(def, p.Subnets, p.Network); other != "" {
+			free, ok := freeSubnet(def.Bits(), p.Subnets)
+			if ok {
+				subnet, source = free, SourceRebased
+				res.Warnings = append(res.Warnings, fmt.Sprintf("the default subnet %s overlaps Docker network %s; %s suggested instead", def, other, free))
+			} else {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("the default subnet %s overlaps Docker network %s and no free 172.16–31.0.0/%d was found; remove that network or edit TEST_NETWORK", def, other, def.Bits()))
+			}
+		}
+	}
+	res.Subnet = subnet.String()
+	if subnet != def {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("TEST_NETWORK is not %s: replace %s, the module on the Docker host, with %s in prometheus/configs", def, gateway(def), gateway(subnet)))
+	}
+
+	set := func(l *line, value, source, container string) {
+		if value != l.value || source == SourceDetected {
+			res.Values = append(res.Values, Value{Key: l.key, Value: value, Default: l.value, Source: source, Container: container})
+		}
+		l.value = value
+		env[l.key] = value
+	}
+	owner := make(map[string]string) // address → variable, to spot clashes
+	for _, l := range parsed {
+		if l == nil {
+			continue
+		}
+		switch {
+		case l.key == "TEST_NETWORK":
+			set(l, subnet.String(), source, "")
+			continue
+		case l.key == "DOCKER_NETWORK" && p.Network != "":
+			set(l, p.Network, SourceDefault, "")
+			continue
+		}
+		addr, err := netip.ParseAddr(l.value)
+		if err != nil || !def.Contains(addr) {
+			continue
+		}
+
+		base := strings.TrimSuffix(l.key, "_ADVERTISE_IP") + "_IP"
+		container := addresses[l.key]
+		switch ip := p.IPs[container]; {
+		case container != "" && ip != "":
+			set(l, ip, SourceDetected, container)
+		case strings.HasSuffix(l.key, "_ADVERTISE_IP") && env[base] != "":
+			set(l, env[base], SourceDerived, "")
+		default:
+			moved, ok := rebase(addr, def, subnet)
+			if !ok {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s=%s does not fit in %s; set it by hand", l.key, l.value, subnet))
+				continue
+			}
+			src := SourceDefault
+			if moved != addr {
+				src = SourceRebased
+			}
+			set(l, moved.String(), src, "")
+		}
+
+		if strings.HasSuffix(l.key, "_ADVERTISE_IP") {
+			continue
+		}
+		if prev, ok := owner[l.value]; ok {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("%s and %s both use %s", prev, l.key, l.value))
+		}
+		owner[l.value] = l.key
+		for name, ip := range p.IPs {
+			if ip == l.value && name != container {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s=%s is already used by container %s", l.key, l.value, name))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "# Suggested by `om-module env` — review it, then rename it to .env.")
+	fmt.Fprintf(&buf, "# TEST_NETWORK %s (%s); %d value(s) differ from the defaults or were detected.\n", subnet, source, len(res.Values))
+	for _, w := range res.Warnings {
+		fmt.Fprintf(&buf, "# WARNING: %s\n", w)
+	}
+	fmt.Fprintln(&buf)
+	for i, l := range lines {
+		if pl := parsed[i]; pl != nil {
+			l = pl.key + "=" + pl.value + pl.rest
+		}
+		fmt.Fprintln(&buf, l)
+	}
+	res.Env = buf.Bytes()
+	return res, nil
+}
+
+// overlapping returns a network, other than own, whose subnet overlaps
+// prefix, or "".
+func overlapping(prefix netip.Prefix, subnets map[string][]string, own string) string {
+	names := make([]string, 0, len(subnets))
+	for name := range subnets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == own {
+			continue
+		}
+		for _, s := range subnets[name] {
+			if p, err := netip.ParsePrefix(s); err == nil && p.Overlaps(prefix) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// freeSubnet returns the first 172.N.0.0/bits, N from 16 to 31, that
+// overlaps no Docker network.
+func freeSubnet(bits int, subnets map[string][]string) (netip.Prefix, bool) {
+	for n := 16; n <= 31; n++ {
+		p := netip.PrefixFrom(netip.AddrFrom4([4]byte{172, byte(n), 0, 0}), bits)
+		if overlapping(p, subnets, "") == "" {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// rebase moves addr, in from, to the same host offset in to.
+func rebase(addr netip.Addr, from, to netip.Prefix) (netip.Addr, bool) {
+	if from == to {
+		return addr, true
+	}
+	a, f, t := addr.As4(), from.Addr().As4(), to.Addr().As4()
+	var out [4]byte
+	for i := range out {
+		out[i] = t[i] | (a[i] &^ f[i])
+	}
+	moved := netip.AddrFrom4(out)
+	return moved, to.Contains(moved) && moved != to.Addr()
+}
+
+// gateway is the first address of prefix, the Docker host on the network.
+func gateway(prefix netip.Prefix) netip.Addr {
+	return prefix.Addr().Next()
+}
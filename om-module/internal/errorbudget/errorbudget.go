@@ -0,0 +1,318 @@
+// Package errorbudget applies SRE-style error budgets to the Open5GS logs:
+// every NF may write a fixed number of error lines per 1000 log lines, and
+// the tracker reports how fast each NF is burning that allowance over a
+// short (5m) and a long (1h) window.
+//
+// Line counts come from Loki, where the log pipeline already labels every
+// Open5GS line with generation, nf and level.
+package errorbudget
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Windows are the rolling windows budgets are computed over. A high burn
+// rate on both means a sustained problem; on 5m only, a burst.
+var Windows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// budgetWindow is the window the remaining budget refers to.
+const budgetWindow = "1h"
+
+// Open5GS log levels, lower-cased by the log pipeline.
+var (
+	errorLevels   = map[string]bool{"error": true, "fatal": true}
+	warningLevels = map[string]bool{"warning": true, "warn": true}
+)
+
+// Options configure the tracker.
+type Options struct {
+	LokiURL  string
+	Timeout  time.Duration // per Loki query
+	Interval time.Duration
+	// Per1000 is the budget: error lines allowed per 1000 log lines.
+	Per1000 float64
+}
+
+// WindowStats are the counts and rates of one NF over one window.
+type WindowStats struct {
+	Window        string  `json:"window"`
+	Lines         float64 `json:"lines"`
+	Warnings      float64 `json:"warnings"`
+	Errors        float64 `json:"errors"`
+	ErrorsPer1000 float64 `json:"errors_per_1000"`
+	// WarnErrorRatio is warnings per error; nil without errors.
+	WarnErrorRatio *float64 `json:"warn_error_ratio,omitempty"`
+	// BurnRate is ErrorsPer1000 over the budget: 1 spends the budget
+	// exactly, above 1 exhausts it before the window ends.
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// Budget is the error budget of one NF.
+type Budget struct {
+	Generation string        `json:"generation"`
+	NF         string        `json:"nf"`
+	Windows    []WindowStats `json:"windows"`
+	// Remaining is the share of the 1h budget left, from 1 (no errors) to
+	// 0 (exhausted).
+	Remaining float64 `json:"remaining"`
+}
+
+// Status is the API view of the tracker.
+type Status struct {
+	Per1000   float64  `json:"budget_per_1000"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Budgets   []Budget `json:"budgets"`
+}
+
+// Tracker polls Loki and exports the budgets as om_log_* metrics.
+type Tracker struct {
+	opts   Options
+	client *http.Client
+
+	lines     *prometheus.GaugeVec
+	per1000   *prometheus.GaugeVec
+	warnRatio *prometheus.GaugeVec
+	burnRate  *prometheus.GaugeVec
+	remaining *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	budgets []Budget
+	updated time.Time
+	lastErr string
+}
+
+// New registers the error budget metrics on reg and returns the tracker.
+func New(reg prometheus.Registerer, opts Options) *Tracker {
+	labels := []string{"generation", "nf", "window"}
+	t := &Tracker{
+		opts:   opts,
+		client: httpclient.New("errorbudget", 0),
+		lines: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log", Name: "lines",
+			Help: "Open5GS log lines in Loki over the window.",
+		}, labels),
+		per1000: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log", Name: "errors_per_1000",
+			Help: "Error and fatal log lines per 1000 lines over the window.",
+		}, labels),
+		warnRatio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log", Name: "warn_error_ratio",
+			Help: "Warning lines per error line over the window; absent without errors.",
+		}, labels),
+		burnRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log", Name: "error_budget_burn_rate",
+			Help: "Errors per 1000 lines divided by the budget: above 1 the NF spends its error budget faster than allowed.",
+		}, labels),
+		remaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log", Name: "error_budget_remaining",
+			Help: "Share of the 1h log error budget left (1 = untouched, 0 = exhausted).",
+		}, []string{"generation", "nf"}),
+	}
+	reg.MustRegister(t.lines, t.per1000, t.warnRatio, t.burnRate, t.remaining)
+	return t
+}
+
+// Run recomputes the budgets every interval until ctx is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := t.update(ctx); err != nil && ctx.Err() == nil {
+			t.mu.Lock()
+			first := t.lastErr == ""
+			t.lastErr = err.Error()
+			t.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Log error budgets: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the latest budgets, worst first.
+func (t *Tracker) Status() Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	s := Status{Per1000: t.opts.Per1000, Error: t.lastErr, Budgets: append([]Budget{}, t.budgets...)}
+	if !t.updated.IsZero() {
+		s.UpdatedAt = t.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the om_log_* metrics were last recomputed, for
+// exporter.Ages. While Loki cannot be queried they keep their last values.
+func (t *Tracker) Freshness() map[string]time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.updated.IsZero() {
+		return nil
+	}
+	out := make(map[string]time.Time, 5)
+	for _, f := range []string{"lines", "errors_per_1000", "warn_error_ratio", "error_budget_burn_rate", "error_budget_remaining"} {
+		out["om_log_"+f] = t.updated
+	}
+	return out
+}
+
+type nfKey struct{ generation, nf string }
+
+type levelCounts struct{ lines, warnings, errors float64 }
+
+func (t *Tracker) update(ctx context.Context) error {
+	counts := make(map[nfKey]map[string]levelCounts) // → window → counts
+	for _, w := range Windows {
+		byLevel, err := t.query(ctx, w.Duration)
+		if err != nil {
+			return err
+		}
+		for key, levels := range byLevel {
+			var c levelCounts
+			for level, n := range levels {
+				c.lines += n
+				switch {
+				case errorLevels[level]:
+					c.errors += n
+				case warningLevels[level]:
+					c.warnings += n
+				}
+			}
+			if counts[key] == nil {
+				counts[key] = make(map[string]levelCounts)
+			}
+			counts[key][w.Name] = c
+		}
+	}
+
+	budgets := make([]Budget, 0, len(counts))
+	for key, byWindow := range counts {
+		b := Budget{Generation: key.generation, NF: key.nf, Windows: []WindowStats{}, Remaining: 1}
+		for _, w := range Windows {
+			c := byWindow[w.Name]
+			ws := WindowStats{Window: w.Name, Lines: c.lines, Warnings: c.warnings, Errors: c.errors}
+			if c.lines > 0 {
+				ws.ErrorsPer1000 = c.errors * 1000 / c.lines
+			}
+			if c.errors > 0 {
+				ratio := c.warnings / c.errors
+				ws.WarnErrorRatio = &ratio
+			}
+			if t.opts.Per1000 > 0 {
+				ws.BurnRate = ws.ErrorsPer1000 / t.opts.Per1000
+			}
+			if w.Name == budgetWindow {
+				b.Remaining = max(0, 1-ws.BurnRate)
+			}
+			b.Windows = append(b.Windows, ws)
+		}
+		budgets = append(budgets, b)
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		if budgets[i].Remaining != budgets[j].Remaining {
+			return budgets[i].Remaining < budgets[j].Remaining
+		}
+		if budgets[i].Generation != budgets[j].Generation {
+			return budgets[i].Generation < budgets[j].Generation
+		}
+		return budgets[i].NF < budgets[j].NF
+	})
+
+	t.export(budgets)
+	t.mu.Lock()
+	t.budgets, t.updated, t.lastErr = budgets, time.Now(), ""
+	t.mu.Unlock()
+	return nil
+}
+
+// export replaces the gauges, so NFs whose logs stopped drop out.
+func (t *Tracker) export(budgets []Budget) {
+	t.lines.Reset()
+	t.per1000.Reset()
+	t.warnRatio.Reset()
+	t.burnRate.Reset()
+	t.remaining.Reset()
+	for _, b := range budgets {
+		for _, ws := range b.Windows {
+			t.lines.WithLabelValues(b.Generation, b.NF, ws.Window).Set(ws.Lines)
+			t.per1000.WithLabelValues(b.Generation, b.NF, ws.Window).Set(ws.ErrorsPer1000)
+			t.burnRate.WithLabelValues(b.Generation, b.NF, ws.Window).Set(ws.BurnRate)
+			if ws.WarnErrorRatio != nil {
+				t.warnRatio.WithLabelValues(b.Generation, b.NF, ws.Window).Set(*ws.WarnErrorRatio)
+			}
+		}
+		t.remaining.WithLabelValues(b.Generation, b.NF).Set(b.Remaining)
+	}
+}
+
+// query counts the Open5GS lines per generation, NF and level over window.
+func (t *Tracker) query(ctx context.Context, window time.Duration) (map[nfKey]map[string]float64, error) {
+	q := url.Values{}
+	q.Set("query", fmt.Sprintf(`sum by (generation, nf, level) (count_over_time({job="open5gs"}[%s]))`, window))
+	target := strings.TrimRight(t.opts.LokiURL, "/") + "/loki/api/v1/query?" + q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("loki query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make(map[nfKey]map[string]float64)
+	for _, r := range body.Data.Result {
+		key := nfKey{r.Metric["generation"], r.Metric["nf"]}
+		if key.nf == "" {
+			continue
+		}
+		s, _ := r.Value[1].(string)
+		n, _ := strconv.ParseFloat(s, 64)
+		if out[key] == nil {
+			out[key] = make(map[string]float64)
+		}
+		out[key][r.Metric["level"]] += n
+	}
+	return out, nil
+}
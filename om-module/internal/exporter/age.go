@@ -0,0 +1,157 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// staleIntervals is how many refresh intervals data may miss before it is
+// flagged as stale.
+const staleIntervals = 3
+
+// Freshness reports, per metric family, when the data behind it was last
+// refreshed. Families without data yet are left out.
+type Freshness func() map[string]time.Time
+
+// Ages implements prometheus.Collector and exports how old the re-exported
+// data of every registered component is:
+//
+//	om_metric_age_seconds{component,metric_family}
+//	om_metric_stale{component,metric_family}
+//
+// A poller that stops refreshing (Docker API errors, Loki down, a peer bench
+// unreachable) keeps exporting its last values; the age is what tells that
+// stale data apart from a legitimate zero in a dashboard.
+type Ages struct {
+	age   *prometheus.Desc
+	stale *prometheus.Desc
+
+	mu      sync.Mutex
+	sources []ageSource
+}
+
+type ageSource struct {
+	component string
+	interval  time.Duration
+	fresh     Freshness
+}
+
+// NewAges registers an empty Ages collector on reg.
+func NewAges(reg prometheus.Registerer) *Ages {
+	a := &Ages{
+		age: prometheus.NewDesc(
+			"om_metric_age_seconds",
+			"Seconds since the data behind a re-exported metric family was last refreshed.",
+			[]string{"component", "metric_family"}, nil,
+		),
+		stale: prometheus.NewDesc(
+			"om_metric_stale",
+			"1 when a metric family has not been refreshed for 3 of its component's refresh intervals.",
+			[]string{"component", "metric_family"}, nil,
+		),
+	}
+	reg.MustRegister(a)
+	return a
+}
+
+// Add exports the freshness of component, which refreshes its data every
+// interval.
+func (a *Ages) Add(component string, interval time.Duration, fresh Freshness) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sources = append(a.sources, ageSource{component: component, interval: interval, fresh: fresh})
+}
+
+// Describe sends both metric descriptors to the channel.
+func (a *Ages) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.age
+	ch <- a.stale
+}
+
+// Collect computes the ages at scrape time.
+func (a *Ages) Collect(ch chan<- prometheus.Metric) {
+	for _, age := range a.Snapshot() {
+		stale := 0.0
+		if age.Stale() {
+			stale = 1
+		}
+		lv := []string{age.Component, age.Family}
+		gauge(ch, a.age, age.Age.Seconds(), lv)
+		gauge(ch, a.stale, stale, lv)
+	}
+}
+
+// Age is how old the data behind one metric family is.
+type Age struct {
+	Component string
+	Family    string
+	Interval  time.Duration // the component's refresh interval
+	Age       time.Duration
+}
+
+// Stale reports whether the family missed 3 of its refresh intervals.
+func (a Age) Stale() bool {
+	return a.Age > staleIntervals*a.Interval
+}
+
+// Snapshot returns the current age of every family with data.
+func (a *Ages) Snapshot() []Age {
+	a.mu.Lock()
+	sources := append([]ageSource(nil), a.sources...)
+	a.mu.Unlock()
+
+	now := time.Now()
+	var out []Age
+	for _, src := range sources {
+		for family, at := range src.fresh() {
+			if at.IsZero() {
+				continue
+			}
+			age := now.Sub(at)
+			if age < 0 {
+				age = 0
+			}
+			out = append(out, Age{Component: src.component, Family: family, Interval: src.interval, Age: age})
+		}
+	}
+	return out
+}
+
+// containerResourceFamilies are the metrics of New refreshed from Docker stats.
+var containerResourceFamilies = []string{
+	"container_cpu_usage_percent",
+	"container_memory_usage_bytes",
+	"container_network_rx_bytes_total",
+	"container_network_tx_bytes_total",
+	"container_pids",
+}
+
+// ContainerFreshness is the Freshness of the metrics New exports from snap.
+// The container list and states are as old as the snapshot; the resource
+// metrics as old as the oldest sample of a running container. While
+// cAdvisor provides the resource metrics only the states are reported.
+func ContainerFreshness(snap *collector.Snapshot) Freshness {
+	return func() map[string]time.Time {
+		out := map[string]time.Time{"container_health_status": snap.Updated()}
+		if snap.ExternalContainerStats() {
+			return out
+		}
+		// Containers without a sample yet are skipped until their first one.
+		var oldest time.Time
+		for _, cd := range snap.All() {
+			if cd.State == "running" && !cd.StatsAt.IsZero() && (oldest.IsZero() || cd.StatsAt.Before(oldest)) {
+				oldest = cd.StatsAt
+			}
+		}
+		if oldest.IsZero() {
+			return out
+		}
+		for _, f := range containerResourceFamilies {
+			out[f] = oldest
+		}
+		return out
+	}
+}
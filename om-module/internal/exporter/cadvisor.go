@@ -0,0 +1,147 @@
+package exporter
+
+import (
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cadvisorExporter exports the container stats of the collector a second
+// time, under the names and labels cAdvisor gives them, so the community
+// dashboards written for cAdvisor work on the lab without running it:
+//
+//	id    — cgroup of the container (/docker/<container id>)
+//	name  — container name
+//	image — Docker image name
+//	container_label_<label> — the compose and om.* labels of the container,
+//	                          as cAdvisor exports Docker labels
+//
+// The network counters are exported per interface, with the interface
+// label. Counters start again from zero when the container restarts, as
+// cAdvisor's do.
+//
+// cAdvisor names some series as omExporter does (container_memory_usage_bytes)
+// with other labels and values, so they are registered on a registry of
+// their own and served apart, on /metrics/cadvisor. While a real cAdvisor
+// runs it serves nothing.
+type cadvisorExporter struct {
+	snap *collector.Snapshot
+
+	cpuSeconds *prometheus.Desc
+	memUsage   *prometheus.Desc
+	workingSet *prometheus.Desc
+	netRx      *prometheus.Desc
+	netTx      *prometheus.Desc
+	threads    *prometheus.Desc
+	lastSeen   *prometheus.Desc
+}
+
+// cadvisorLabelNames is the fixed ordered set of labels attached to every
+// cAdvisor-compatible metric.
+var cadvisorLabelNames = []string{
+	"id",
+	"name",
+	"image",
+	"container_label_com_docker_compose_project",
+	"container_label_com_docker_compose_service",
+	"container_label_om_project",
+	"container_label_om_domain",
+	"container_label_om_nf",
+	"container_label_om_generation",
+}
+
+// NewCAdvisor registers a new cadvisorExporter in the given registry,
+// which must not hold the omExporter.
+func NewCAdvisor(snap *collector.Snapshot, reg prometheus.Registerer) {
+	e := &cadvisorExporter{
+		snap: snap,
+
+		cpuSeconds: prometheus.NewDesc(
+			"container_cpu_usage_seconds_total",
+			"Cumulative cpu time consumed in seconds.",
+			cadvisorLabelNames, nil,
+		),
+		memUsage: prometheus.NewDesc(
+			"container_memory_usage_bytes",
+			"Current memory usage in bytes, including all memory regardless of when it was accessed.",
+			cadvisorLabelNames, nil,
+		),
+		workingSet: prometheus.NewDesc(
+			"container_memory_working_set_bytes",
+			"Current working set in bytes.",
+			cadvisorLabelNames, nil,
+		),
+		netRx: prometheus.NewDesc(
+			"container_network_receive_bytes_total",
+			"Cumulative count of bytes received.",
+			append(append([]string{}, cadvisorLabelNames...), "interface"), nil,
+		),
+		netTx: prometheus.NewDesc(
+			"container_network_transmit_bytes_total",
+			"Cumulative count of bytes transmitted.",
+			append(append([]string{}, cadvisorLabelNames...), "interface"), nil,
+		),
+		threads: prometheus.NewDesc(
+			"container_threads",
+			"Number of threads running inside the container.",
+			cadvisorLabelNames, nil,
+		),
+		lastSeen: prometheus.NewDesc(
+			"container_last_seen",
+			"Last time a container was seen by the exporter.",
+			cadvisorLabelNames, nil,
+		),
+	}
+	reg.MustRegister(e)
+}
+
+// Describe sends all metric descriptors to the channel.
+func (e *cadvisorExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.cpuSeconds
+	ch <- e.memUsage
+	ch <- e.workingSet
+	ch <- e.netRx
+	ch <- e.netTx
+	ch <- e.threads
+	ch <- e.lastSeen
+}
+
+// Collect is called by Prometheus on every scrape. Like cAdvisor, it only
+// exports the running containers, from their last sample.
+func (e *cadvisorExporter) Collect(ch chan<- prometheus.Metric) {
+	if e.snap.ExternalContainerStats() {
+		return
+	}
+	for _, cd := range e.snap.All() {
+		if cd.State != "running" || cd.StatsAt.IsZero() {
+			continue
+		}
+		lv := cadvisorLabelValues(cd)
+
+		counter(ch, e.cpuSeconds, cd.CPUSeconds, lv)
+		gauge(ch, e.memUsage, float64(cd.MemoryTotalB), lv)
+		gauge(ch, e.workingSet, float64(cd.MemoryUsageB), lv)
+		for _, iface := range cd.Interfaces {
+			ilv := append(append([]string{}, lv...), iface.Name)
+			counter(ch, e.netRx, float64(iface.RxBytes), ilv)
+			counter(ch, e.netTx, float64(iface.TxBytes), ilv)
+		}
+		gauge(ch, e.threads, float64(cd.PIDs), lv)
+		gauge(ch, e.lastSeen, float64(cd.StatsAt.Unix()), lv)
+	}
+}
+
+// cadvisorLabelValues builds the label value slice in the same order as
+// cadvisorLabelNames.
+func cadvisorLabelValues(cd *collector.ContainerData) []string {
+	return []string{
+		"/docker/" + cd.ID,
+		cd.Name,
+		cd.Image,
+		cd.ComposeProject,
+		cd.Service,
+		cd.Project,
+		cd.Domain,
+		cd.NF,
+		cd.Generation,
+	}
+}
@@ -1,6 +1,10 @@
 package exporter
 
 import (
+	"log"
+	"sync"
+	"time"
+
 	"github.com/Parz1val02/OM_module/internal/collector"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -10,22 +14,44 @@ import (
 //
 //	container  — container name
 //	project    — om.project  (open5gs | srsran | srslte | ueransim | grafana …)
-//	domain     — om.domain   (core | ran | infra | observability)
-//	nf         — om.nf       (amf | smf | upf | mme | gnb | enb | ue …)
+//	domain     — om.domain   (core | ran | ims | infra | observability)
+//	nf         — om.nf       (amf | smf | upf | mme | gnb | enb | ue | pcscf …)
 //	generation — om.generation (4g | 5g | none)
 //	image      — Docker image name
 //	state      — Docker container state (running | exited | …)
+//	compose_project — com.docker.compose.project
+//	service    — Compose component (service name, or service_<n> when scaled)
+//	owner      — team responsible for the component, from the owners file
+//
+// The network counters are exported per interface, with three more labels:
+//
+//	interface       — interface inside the container (eth0, eth1, …)
+//	network         — Docker network the interface is attached to
+//	reference_point — om.reference_point label of that network (n3, n6, sbi, …)
+//
+// The network counters stay monotonic across container restarts (see
+// counterResets); container_network_counter_resets_total counts the resets.
+//
+// Contact and description of the owner are exported once per container in
+// container_owner_info, to keep them off every series. The interfaces
+// between the containers (collector.Links) are exported as
+// om_topology_link_info, for the node graph of the topology dashboard.
 type omExporter struct {
 	snap    *collector.Snapshot
 	project string
+	resets  *counterResets
 
 	// Descriptors
 	cpuPercent   *prometheus.Desc
 	memUsage     *prometheus.Desc
 	netRx        *prometheus.Desc
 	netTx        *prometheus.Desc
+	netResets    *prometheus.Desc
 	pids         *prometheus.Desc
 	healthStatus *prometheus.Desc
+	interval     *prometheus.Desc
+	ownerInfo    *prometheus.Desc
+	linkInfo     *prometheus.Desc
 }
 
 // labelNames is the fixed ordered set of labels attached to every metric.
@@ -37,13 +63,20 @@ var labelNames = []string{
 	"generation",
 	"image",
 	"state",
+	"compose_project",
+	"service",
+	"owner",
 }
 
+// interfaceLabelNames are the labels of the per-interface network counters.
+var interfaceLabelNames = append(append([]string{}, labelNames...), "interface", "network", "reference_point")
+
 // New registers a new omExporter in the given registry and returns it.
 func New(snap *collector.Snapshot, composeProject string, reg prometheus.Registerer) {
 	e := &omExporter{
 		snap:    snap,
 		project: composeProject,
+		resets:  newCounterResets(),
 
 		cpuPercent: prometheus.NewDesc(
 			"container_cpu_usage_percent",
@@ -57,13 +90,18 @@ func New(snap *collector.Snapshot, composeProject string, reg prometheus.Registe
 		),
 		netRx: prometheus.NewDesc(
 			"container_network_rx_bytes_total",
-			"Total bytes received across all container network interfaces.",
-			labelNames, nil,
+			"Total bytes received on one network interface of the container.",
+			interfaceLabelNames, nil,
 		),
 		netTx: prometheus.NewDesc(
 			"container_network_tx_bytes_total",
-			"Total bytes transmitted across all container network interfaces.",
-			labelNames, nil,
+			"Total bytes transmitted on one network interface of the container.",
+			interfaceLabelNames, nil,
+		),
+		netResets: prometheus.NewDesc(
+			"container_network_counter_resets_total",
+			"Times the network counters of one interface started again from zero (container restarted or recreated); the byte counters carry on from their previous value.",
+			[]string{"container", "service", "interface"}, nil,
 		),
 		pids: prometheus.NewDesc(
 			"container_pids",
@@ -75,6 +113,21 @@ func New(snap *collector.Snapshot, composeProject string, reg prometheus.Registe
 			"Container health: 1 = running, 0 = degraded/unknown, -1 = stopped.",
 			labelNames, nil,
 		),
+		interval: prometheus.NewDesc(
+			"container_collect_interval_seconds",
+			"Current resource-stats refresh interval of the container (varies per container in adaptive mode).",
+			labelNames, nil,
+		),
+		ownerInfo: prometheus.NewDesc(
+			"container_owner_info",
+			"Always 1; carries the owner, contact and description of the container's component.",
+			[]string{"container", "service", "owner", "contact", "description"}, nil,
+		),
+		linkInfo: prometheus.NewDesc(
+			"om_topology_link_info",
+			"Always 1; one series per interface between two containers, named after its 3GPP reference point.",
+			[]string{"source", "target", "interface"}, nil,
+		),
 	}
 	reg.MustRegister(e)
 }
@@ -85,27 +138,49 @@ func (e *omExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.memUsage
 	ch <- e.netRx
 	ch <- e.netTx
+	ch <- e.netResets
 	ch <- e.pids
 	ch <- e.healthStatus
+	ch <- e.interval
+	ch <- e.ownerInfo
+	ch <- e.linkInfo
 }
 
 // Collect is called by Prometheus on every scrape.
 func (e *omExporter) Collect(ch chan<- prometheus.Metric) {
-	for _, cd := range e.snap.All() {
+	external := e.snap.ExternalContainerStats()
+	now := time.Now()
+	defer e.resets.prune(now)
+	all := e.snap.All()
+	for _, l := range collector.Links(all) {
+		gauge(ch, e.linkInfo, 1, []string{l.Source, l.Target, l.Interface})
+	}
+	for _, cd := range all {
 		lv := labelValues(cd)
 
-		ch <- gauge(e.healthStatus, cd.HealthValue(), lv)
+		gauge(ch, e.healthStatus, cd.HealthValue(), lv)
+		if cd.Owner != "" || cd.Contact != "" || cd.Description != "" {
+			gauge(ch, e.ownerInfo, 1, []string{cd.Name, cd.Component, cd.Owner, cd.Contact, cd.Description})
+		}
 
-		// Resource metrics are only meaningful for running containers.
-		if cd.State != "running" {
+		// Resource metrics are only meaningful for running containers, and
+		// are left to cAdvisor when it runs: it exports some under the same
+		// names with different labels and values.
+		if cd.State != "running" || external {
 			continue
 		}
 
-		ch <- gauge(e.cpuPercent, cd.CPUPercent, lv)
-		ch <- gauge(e.memUsage, float64(cd.MemoryUsageB), lv)
-		ch <- counter(e.netRx, float64(cd.NetworkRxBytes), lv)
-		ch <- counter(e.netTx, float64(cd.NetworkTxBytes), lv)
-		ch <- gauge(e.pids, float64(cd.PIDs), lv)
+		gauge(ch, e.cpuPercent, cd.CPUPercent, lv)
+		gauge(ch, e.memUsage, float64(cd.MemoryUsageB), lv)
+		for _, iface := range cd.Interfaces {
+			ilv := append(append([]string{}, lv...), iface.Name, iface.Network, iface.ReferencePoint)
+			rx, tx, resets := e.resets.adjust(resetKey{cd.Name, iface.Name}, cd.ID, iface.RxBytes, iface.TxBytes, now)
+			counter(ch, e.netRx, rx, ilv)
+			counter(ch, e.netTx, tx, ilv)
+			counter(ch, e.netResets, float64(resets), []string{cd.Name, cd.Component, iface.Name})
+		}
+		gauge(ch, e.pids, float64(cd.PIDs), lv)
+		gauge(ch, e.interval, cd.CollectInterval.Seconds(), lv)
 	}
 }
 
@@ -119,13 +194,33 @@ func labelValues(cd *collector.ContainerData) []string {
 		cd.Generation,
 		cd.Image,
 		cd.State,
+		cd.ComposeProject,
+		cd.Component,
+		cd.Owner,
 	}
 }
 
-func gauge(desc *prometheus.Desc, val float64, lv []string) prometheus.Metric {
-	return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, val, lv...)
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, val float64, lv []string) {
+	send(ch, desc, prometheus.GaugeValue, val, lv)
 }
 
-func counter(desc *prometheus.Desc, val float64, lv []string) prometheus.Metric {
-	return prometheus.MustNewConstMetric(desc, prometheus.CounterValue, val, lv...)
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, val float64, lv []string) {
+	send(ch, desc, prometheus.CounterValue, val, lv)
+}
+
+// invalid holds the descriptors a series was dropped for, to log each once.
+var invalid sync.Map
+
+// send sends a series, or drops it when its label values are not valid:
+// they come from Docker labels and the owners file, and a panic in Collect
+// would take the whole process down with the scrape.
+func send(ch chan<- prometheus.Metric, desc *prometheus.Desc, vt prometheus.ValueType, val float64, lv []string) {
+	m, err := prometheus.NewConstMetric(desc, vt, val, lv...)
+	if err != nil {
+		if _, logged := invalid.LoadOrStore(desc.String(), true); !logged {
+			log.Printf("⚠️  Exporter: series dropped: %v", err)
+		}
+		return
+	}
+	ch <- m
 }
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"sync"
+	"time"
+)
+
+// resetRetention is how long the offsets of an interface are kept while its
+// container is not running: long enough for an NF to be restarted or
+// recreated during a lab session.
+const resetRetention = time.Hour
+
+// counterResets keeps the network counters the exporter re-exports from
+// Docker monotonic across container restarts. Docker's counters start
+// again from zero when an NF restarts or is recreated; exported as they are,
+// the series jumps backwards, which rate() takes for a reset of its own but
+// sums, deltas and "current − value an hour ago" panels take for a huge
+// negative rate. Each interface therefore carries an offset per direction,
+// the sum of the values its counters had reached before each reset, and
+// the exported values are raw + offset. The resets are counted so
+// dashboards can mark them.
+type counterResets struct {
+	mu     sync.Mutex
+	series map[resetKey]*resetState
+}
+
+type resetKey struct {
+	container string
+	iface     string
+}
+
+type resetState struct {
+	id                 string // container the last values came from
+	lastRx, lastTx     uint64 // last raw values
+	offsetRx, offsetTx float64
+	resets             int
+	seen               time.Time
+}
+
+func newCounterResets() *counterResets {
+	return &counterResets{series: make(map[resetKey]*resetState)}
+}
+
+// adjust returns the exported values of the raw counters rx and tx, read
+// from the container with id, and how many resets the interface has seen.
+// Either counter going down is a reset of both, and so is a new container
+// id even when neither did: the new container counted from zero.
+func (c *counterResets) adjust(k resetKey, id string, rx, tx uint64, now time.Time) (float64, float64, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.series[k]
+	if s == nil {
+		s = &resetState{id: id}
+		c.series[k] = s
+	} else if rx < s.lastRx || tx < s.lastTx || id != s.id {
+		s.offsetRx += float64(s.lastRx)
+		s.offsetTx += float64(s.lastTx)
+		s.resets++
+		s.id = id
+	}
+	s.lastRx, s.lastTx = rx, tx
+	s.seen = now
+	return float64(rx) + s.offsetRx, float64(tx) + s.offsetTx, s.resets
+}
+
+// prune drops the interfaces not exported for resetRetention.
+func (c *counterResets) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, s := range c.series {
+		if now.Sub(s.seen) > resetRetention {
+			delete(c.series, k)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+// Package exposure makes the NEF of an IoT lab observable. The NEF
+// (Network Exposure Function) is the northbound door of the core: an
+// application function (AF) outside the operator network calls its REST
+// APIs to subscribe to UE events (reachability, location, loss of
+// connectivity), to send and receive small amounts of data without an IP
+// session (NIDD), to wake a device with an SMS (device triggering) or to
+// influence how the core routes and prioritises its traffic.
+//
+// The Watcher reads the log lines of every NEF service (om.nf "nef") from
+// Loki, picks out the API invocations (method, path and HTTP status) and
+// counts them per API, together with the event exposure subscriptions
+// created and deleted through them.
+package exposure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NFNEF is the om.nf label of NEF containers.
+const NFNEF = "nef"
+
+// Spec lists the specifications behind the exposure APIs.
+const Spec = "3GPP TS 23.502 §4.15 (network exposure), TS 29.122 (T8 northbound APIs: monitoring event, NIDD, device triggering, AS session with QoS), " +
+	"TS 29.522 (NEF northbound APIs: traffic influence) and TS 29.591 (Nnef_EventExposure)"
+
+// maxLines bounds the lines read from Loki in one poll; the rest are read
+// by the next one.
+const maxLines = 5000
+
+// maxRecent is how many invocations Status keeps.
+const maxRecent = 50
+
+// Event is what happened to a subscription.
+const (
+	EventCreated  = "created"
+	EventDeleted  = "deleted"
+	EventRejected = "rejected"
+)
+
+// apiDescriptions describe the APIs a lab NEF usually exposes. An API that
+// is not listed is still counted.
+var apiDescriptions = map[string]string{
+	"3gpp-monitoring-event":          "Monitoring Event: the AF subscribes to UE reachability, location, loss of connectivity or roaming status",
+	"3gpp-nidd":                      "NIDD: non-IP data delivery to and from IoT devices without a PDU session for IP traffic",
+	"3gpp-device-triggering":         "Device Triggering: the AF wakes a device with a trigger delivered as SMS",
+	"3gpp-traffic-influence":         "Traffic Influence: the AF steers the traffic of a UE or an application to a local data network (DNAI)",
+	"3gpp-as-session-with-qos":       "AS Session with QoS: the AF asks for a QoS for one application session of a UE",
+	"3gpp-pfd-management":            "PFD Management: the AF provisions the packet flow descriptions that identify its application",
+	"3gpp-cp-parameter-provisioning": "CP Parameter Provisioning: the AF provisions the expected communication pattern of its devices",
+	"3gpp-analyticsexposure":         "Analytics Exposure: the AF subscribes to NWDAF analytics through the NEF",
+	"nnef-eventexposure":             "Nnef_EventExposure: NFs of the core subscribe to the events the NEF exposes",
+	"nnef-pfdmanagement":             "Nnef_PFDManagement: the SMF fetches the PFDs the AFs provisioned",
+}
+
+// Describe returns the description of an exposure API.
+func Describe(api string) string {
+	return apiDescriptions[api]
+}
+
+var (
+	ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// invocation is the method and path of a request, as the Open5GS SBI
+	// server and gin-style access logs ("| POST | /3gpp-…") write them.
+	invocation = regexp.MustCompile(`\b(GET|POST|PUT|PATCH|DELETE)\b[\s|"]+(/(?:3gpp|nnef)-[a-z0-9-]+/v\d+[^\s"|?]*)`)
+	// statusCode is the HTTP status of the response: "| 201 |" in access
+	// logs, status=201 or "status code 201" elsewhere.
+	statusCode = regexp.MustCompile(`(?i)(?:\|\s*([1-5]\d\d)\s*\||\bstatus(?:[ _-]?code)?\s*[=:\[ ]\s*([1-5]\d\d)\b)`)
+)
+
+// Invocation is one API call found in the NEF log.
+type Invocation struct {
+	Time   string `json:"time"`
+	NF     string `json:"nf"`
+	API    string `json:"api"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status string `json:"status"` // HTTP status, "unknown" when not logged
+}
+
+// API is the activity of one exposure API since the module started.
+type API struct {
+	Name                string `json:"name"`
+	Description         string `json:"description,omitempty"`
+	Invocations         int    `json:"invocations"`
+	Errors              int    `json:"errors"` // 4xx and 5xx responses
+	ActiveSubscriptions int    `json:"active_subscriptions"`
+	Created             int    `json:"subscriptions_created"`
+	Deleted             int    `json:"subscriptions_deleted"`
+	LastInvocation      string `json:"last_invocation,omitempty"`
+}
+
+// Status is the API view of the watcher.
+type Status struct {
+	NFs       []string     `json:"nfs"` // nf label of the NEF services read
+	Interval  string       `json:"interval"`
+	UpdatedAt string       `json:"updated_at,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	APIs      []API        `json:"apis"`
+	Recent    []Invocation `json:"recent"` // newest first
+}
+
+// Options configure the watcher.
+type Options struct {
+	LokiURL  string
+	Timeout  time.Duration
+	Interval time.Duration
+	// Redact is applied to the paths of the recent invocations (API keys,
+	// identifiers in query strings). Nil keeps them as logged.
+	Redact *redact.Redactor
+}
+
+// Watcher reads the NEF log lines from Loki every interval.
+type Watcher struct {
+	opts   Options
+	snap   *collector.Snapshot
+	client *http.Client
+
+	invocations *prometheus.CounterVec
+	active      *prometheus.GaugeVec
+	events      *prometheus.CounterVec
+
+	mu      sync.RWMutex
+	since   time.Time // start of the next read
+	nfs     []string
+	apis    map[string]*API
+	recent  []Invocation
+	updated time.Time
+	lastErr string
+}
+
+// New registers the om_exposure_* metrics on reg and returns the watcher.
+// Lines logged before the module started are not read, so subscriptions
+// created earlier are not counted as active.
+func New(reg prometheus.Registerer, snap *collector.Snapshot, opts Options) *Watcher {
+	w := &Watcher{
+		opts:   opts,
+		snap:   snap,
+		client: httpclient.New("exposure", 0),
+		since:  time.Now(),
+		apis:   make(map[string]*API),
+		invocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "exposure", Name: "api_invocations_total",
+			Help: "Northbound API invocations found in the NEF log, by API, HTTP method and response status.",
+		}, []string{"api", "method", "status"}),
+		active: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "exposure", Name: "subscriptions_active",
+			Help: "Subscriptions created through the NEF API and not deleted yet, since the module started.",
+		}, []string{"api"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "exposure", Name: "subscription_events_total",
+			Help: "Subscriptions created, deleted or rejected through the NEF API.",
+		}, []string{"api", "event"}),
+	}
+	reg.MustRegister(w.invocations, w.active, w.events)
+	return w
+}
+
+// Run polls every interval until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.update(ctx); err != nil && ctx.Err() == nil {
+			w.mu.Lock()
+			first := w.lastErr == ""
+			w.lastErr = err.Error()
+			w.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Exposure APIs: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the activity per API and the latest invocations.
+func (w *Watcher) Status() Status {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	s := Status{
+		NFs:      append([]string{}, w.nfs...),
+		Interval: w.opts.Interval.String(),
+		Error:    w.lastErr,
+		APIs:     make([]API, 0, len(w.apis)),
+		Recent:   append([]Invocation{}, w.recent...),
+	}
+	for _, a := range w.apis {
+		s.APIs = append(s.APIs, *a)
+	}
+	sort.Slice(s.APIs, func(i, j int) bool {
+		if s.APIs[i].Invocations != s.APIs[j].Invocations {
+			return s.APIs[i].Invocations > s.APIs[j].Invocations
+		}
+		return s.APIs[i].Name < s.APIs[j].Name
+	})
+	if !w.updated.IsZero() {
+		s.UpdatedAt = w.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the NEF log was last read, for exporter.Ages.
+// Without a NEF there are no such metrics and it returns nil.
+func (w *Watcher) Freshness() map[string]time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if len(w.nfs) == 0 || w.updated.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{
+		"om_exposure_api_invocations_total":     w.updated,
+		"om_exposure_subscriptions_active":      w.updated,
+		"om_exposure_subscription_events_total": w.updated,
+	}
+}
+
+// Parse returns the invocation logged in line, if any. Time and NF are
+// left to the caller.
+func Parse(line string) (Invocation, bool) {
+	line = ansiCodes.ReplaceAllString(line, "")
+	m := invocation.FindStringSubmatch(line)
+	if m == nil {
+		return Invocation{}, false
+	}
+	inv := Invocation{Method: m[1], Path: m[2], Status: "unknown"}
+	inv.API = strings.SplitN(strings.TrimPrefix(inv.Path, "/"), "/", 2)[0]
+	if s := statusCode.FindStringSubmatch(line); s != nil {
+		inv.Status = s[1] + s[2]
+	}
+	return inv, true
+}
+
+// subscriptionEvent returns what inv did to a subscription: a POST to a
+// subscriptions collection creates one, a DELETE of one of its members
+// deletes it. Calls without a logged status are not counted.
+func subscriptionEvent(inv Invocation) string {
+	if len(inv.Status) != 3 || inv.Path == "" {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(inv.Path, "/"), "/")
+	last := len(segments) - 1
+	ok := inv.Status[0] == '2'
+	switch {
+	case inv.Method == http.MethodPost && segments[last] == "subscriptions":
+		if ok {
+			return EventCreated
+		}
+		return EventRejected
+	case inv.Method == http.MethodDelete && last > 0 && segments[last-1] == "subscriptions" && ok:
+		return EventDeleted
+	}
+	return ""
+}
+
+func (w *Watcher) update(ctx context.Context) error {
+	var nfs []string
+	for _, g := range w.snap.Services() {
+		// The nf label of a log stream is the Compose service name.
+		if g.NF == NFNEF {
+			nfs = append(nfs, g.Service)
+		}
+	}
+	sort.Strings(nfs)
+	now := time.Now()
+
+	w.mu.Lock()
+	w.nfs = nfs
+	since := w.since
+	w.mu.Unlock()
+	if len(nfs) == 0 {
+		w.mu.Lock()
+		w.since, w.updated, w.lastErr = now, now, ""
+		w.mu.Unlock()
+		return nil
+	}
+
+	found, last, err := w.read(ctx, nfs, since, now)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, inv := range found {
+		a := w.apis[inv.API]
+		if a == nil {
+			a = &API{Name: inv.API, Description: Describe(inv.API)}
+			w.apis[inv.API] = a
+		}
+		a.Invocations++
+		if inv.Status[0] == '4' || inv.Status[0] == '5' {
+			a.Errors++
+		}
+		a.LastInvocation = inv.Time
+		w.invocations.WithLabelValues(inv.API, inv.Method, inv.Status).Inc()
+
+		switch ev := subscriptionEvent(inv); ev {
+		case EventCreated:
+			a.Created++
+			a.ActiveSubscriptions++
+			w.events.WithLabelValues(inv.API, ev).Inc()
+		case EventDeleted:
+			a.Deleted++
+			// Deleting a subscription created before the module started.
+			if a.ActiveSubscriptions > 0 {
+				a.ActiveSubscriptions--
+			}
+			w.events.WithLabelValues(inv.API, ev).Inc()
+		case EventRejected:
+			w.events.WithLabelValues(inv.API, ev).Inc()
+		}
+		w.active.WithLabelValues(inv.API).Set(float64(a.ActiveSubscriptions))
+	}
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	// Paths are redacted once counted: subscriptionEvent needs them whole.
+	for i := range found {
+		found[i].Path = w.opts.Redact.Line(found[i].Path)
+	}
+	w.recent = append(found, w.recent...)
+	if len(w.recent) > maxRecent {
+		w.recent = w.recent[:maxRecent]
+	}
+	w.since = last
+	w.updated = now
+	w.lastErr = ""
+	return nil
+}
+
+// read returns the invocations the NEF services logged between from and
+// to, oldest first, and where the next read starts: to, or just after the
+// last line read when Loki had more than maxLines.
+func (w *Watcher) read(ctx context.Context, nfs []string, from, to time.Time) ([]Invocation, time.Time, error) {
+	quoted := make([]string, len(nfs))
+	for i, nf := range nfs {
+		quoted[i] = regexp.QuoteMeta(nf)
+	}
+	v := url.Values{}
+	v.Set("query", fmt.Sprintf(`{job="open5gs", nf=~"%s"} |~ "(3gpp|nnef)-"`, strings.Join(quoted, "|")))
+	v.Set("start", strconv.FormatInt(from.UnixNano(), 10))
+	v.Set("end", strconv.FormatInt(to.UnixNano(), 10))
+	v.Set("limit", strconv.Itoa(maxLines))
+	v.Set("direction", "forward")
+	target := strings.TrimRight(w.opts.LokiURL, "/") + "/loki/api/v1/query_range?" + v.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, w.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, from, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, from, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, from, fmt.Errorf("loki query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, from, err
+	}
+
+	type entry struct {
+		ns  int64
+		inv Invocation
+	}
+	var entries []entry
+	lines := 0
+	var lastNs int64
+	for _, stream := range body.Data.Result {
+		for _, e := range stream.Values {
+			lines++
+			ns, _ := strconv.ParseInt(e[0], 10, 64)
+			if ns > lastNs {
+				lastNs = ns
+			}
+			inv, ok := Parse(e[1])
+			if !ok {
+				continue
+			}
+			inv.Time = time.Unix(0, ns).UTC().Format(time.RFC3339)
+			inv.NF = stream.Stream["nf"]
+			entries = append(entries, entry{ns, inv})
+		}
+	}
+	// Creations and deletions are applied in the order they were logged,
+	// across NEF instances.
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ns < entries[j].ns })
+
+	out := make([]Invocation, len(entries))
+	for i, e := range entries {
+		out[i] = e.inv
+	}
+	next := to
+	if lines >= maxLines {
+		next = time.Unix(0, lastNs+1)
+	}
+	return out, next, nil
+}
@@ -0,0 +1,331 @@
+// Package featureflags switches features on and off while the module runs,
+// so that an experimental feature can ship disabled and be turned on in one
+// lab without a rebuild. Each feature is declared in Features with its
+// default. FEATURE_FLAGS sets it per lab:
+//
+//	capture=off,insights=25%
+//
+// "on" and "off" decide outright; a percentage turns the feature on in that
+// share of the labs, picked by a hash of the flag name and the lab name, so
+// that a lab keeps its decision across restarts and raising the percentage
+// only adds labs (a gradual rollout). The API can override a flag until the
+// module restarts.
+//
+// A flag only gates a feature whose subsystem was started: the capture
+// still needs CAPTURE_ENABLED, the insights INSIGHTS_ENABLED and Prometheus,
+// the simulated metrics SIMULATED_METRICS_DIR.
+package featureflags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Names of the flags.
+const (
+	Capture   = "capture"
+	Insights  = "insights"
+	Simulated = "simulated"
+)
+
+// Feature declares a feature behind a flag.
+type Feature struct {
+	Name        string
+	Description string
+	// Default is the state of the flag without configuration. Experimental
+	// features are declared with false.
+	Default bool
+}
+
+// Features are the features behind a flag.
+var Features = []Feature{
+	{Name: Capture, Description: "Live packet capture (tshark) feeding the SBI, cause, milestone, QoS, NAS security, handover and IMS analyzers", Default: true},
+	{Name: Insights, Description: "Anomaly detection behind the learning cards of /educational/insights", Default: true},
+	{Name: Simulated, Description: "Sampled Open5GS metrics (data_source=\"simulated\") in place of the NFs whose metrics endpoint does not answer", Default: false},
+}
+
+// Sources of the state of a flag.
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceRollout = "rollout"
+	SourceAPI     = "api"
+)
+
+// Flag is the API view of a flag.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	// Source is what decided Enabled: default, config, rollout or api.
+	Source string `json:"source"`
+	// Setting is the FEATURE_FLAGS value of the flag: on, off or a
+	// percentage; "" when not configured.
+	Setting string `json:"setting,omitempty"`
+	// Available is false when the subsystem of the feature did not start,
+	// in which case the flag changes nothing.
+	Available bool   `json:"available"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// setting is a parsed FEATURE_FLAGS value.
+type setting struct {
+	raw     string
+	percent int // 0 (off) to 100 (on)
+}
+
+type flag struct {
+	Feature
+	setting   *setting
+	override  *bool
+	available bool
+	enabled   bool
+	source    string
+	updated   time.Time
+}
+
+// Set holds the flags of the lab.
+type Set struct {
+	lab string
+
+	enabled *prometheus.GaugeVec
+
+	mu    sync.RWMutex
+	flags []*flag
+}
+
+// Parse parses FEATURE_FLAGS: comma-separated name=value pairs, value being
+// on, off, true, false or a percentage such as 25%.
+func Parse(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(f, "=")
+		name, value = strings.TrimSpace(strings.ToLower(name)), strings.TrimSpace(strings.ToLower(value))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("feature flag %q: want name=on|off|N%%", f)
+		}
+		if lookup(name) == nil {
+			return nil, fmt.Errorf("unknown feature flag %q (want %s)", name, strings.Join(names(), ", "))
+		}
+		if _, err := parseSetting(value); err != nil {
+			return nil, fmt.Errorf("feature flag %s: %w", name, err)
+		}
+		out[name] = value
+	}
+	return out, nil
+}
+
+func parseSetting(v string) (setting, error) {
+	switch v {
+	case "on", "true":
+		return setting{raw: v, percent: 100}, nil
+	case "off", "false":
+		return setting{raw: v, percent: 0}, nil
+	}
+	p, ok := strings.CutSuffix(v, "%")
+	n, err := strconv.Atoi(p)
+	if !ok || err != nil || n < 0 || n > 100 {
+		return setting{}, fmt.Errorf("%q is not on, off or a percentage between 0%% and 100%%", v)
+	}
+	return setting{raw: v, percent: n}, nil
+}
+
+func lookup(name string) *Feature {
+	for i := range Features {
+		if Features[i].Name == name {
+			return &Features[i]
+		}
+	}
+	return nil
+}
+
+func names() []string {
+	out := make([]string, len(Features))
+	for i, f := range Features {
+		out[i] = f.Name
+	}
+	return out
+}
+
+// New returns the flags of lab configured by FEATURE_FLAGS value s and
+// registers om_feature_flag_enabled on reg. lab names the lab for
+// percentage rollouts.
+func New(reg prometheus.Registerer, s, lab string) (*Set, error) {
+	settings, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	fs := &Set{
+		lab: lab,
+		enabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Name: "feature_flag_enabled",
+			Help: "1 if the feature behind the flag is on in this lab.",
+		}, []string{"flag"}),
+	}
+	now := time.Now()
+	for _, feat := range Features {
+		f := &flag{Feature: feat, updated: now}
+		if v, ok := settings[feat.Name]; ok {
+			st, _ := parseSetting(v)
+			f.setting = &st
+		}
+		fs.resolve(f)
+		fs.flags = append(fs.flags, f)
+	}
+	reg.MustRegister(fs.enabled)
+	return fs, nil
+}
+
+// resolve sets the state of f from its override, setting and default.
+func (fs *Set) resolve(f *flag) {
+	switch {
+	case f.override != nil:
+		f.enabled, f.source = *f.override, SourceAPI
+	case f.setting != nil && (f.setting.percent == 0 || f.setting.percent == 100):
+		f.enabled, f.source = f.setting.percent == 100, SourceConfig
+	case f.setting != nil:
+		f.enabled, f.source = fs.bucket(f.Name) < f.setting.percent, SourceRollout
+	default:
+		f.enabled, f.source = f.Default, SourceDefault
+	}
+	v := 0.0
+	if f.enabled {
+		v = 1
+	}
+	fs.enabled.WithLabelValues(f.Name).Set(v)
+}
+
+// bucket places the lab between 0 and 99 for flag name.
+func (fs *Set) bucket(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "/" + fs.lab))
+	return int(h.Sum32() % 100)
+}
+
+func (fs *Set) get(name string) *flag {
+	for _, f := range fs.flags {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the feature name is on. A nil Set has every
+// feature at its default.
+func (fs *Set) Enabled(name string) bool {
+	if fs == nil {
+		feat := lookup(name)
+		return feat != nil && feat.Default
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	f := fs.get(name)
+	return f != nil && f.enabled
+}
+
+// Gate returns a function reporting whether the feature name is on, for
+// subsystems that check it as they run.
+func (fs *Set) Gate(name string) func() bool {
+	return func() bool { return fs.Enabled(name) }
+}
+
+// SetAvailable records that the subsystem of the feature name started.
+func (fs *Set) SetAvailable(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if f := fs.get(name); f != nil {
+		f.available = true
+	}
+}
+
+// Override turns the feature name on or off until the module restarts or
+// Reset is called. ok is false for an unknown flag.
+func (fs *Set) Override(name string, enabled bool) (Flag, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f := fs.get(name)
+	if f == nil {
+		return Flag{}, false
+	}
+	f.override = &enabled
+	f.updated = time.Now()
+	fs.resolve(f)
+	return f.view(), true
+}
+
+// Reset drops the API override of the feature name, leaving it as
+// configured. ok is false for an unknown flag.
+func (fs *Set) Reset(name string) (Flag, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f := fs.get(name)
+	if f == nil {
+		return Flag{}, false
+	}
+	f.override = nil
+	f.updated = time.Now()
+	fs.resolve(f)
+	return f.view(), true
+}
+
+// Lab returns the name percentage rollouts are decided on.
+func (fs *Set) Lab() string { return fs.lab }
+
+// Flags returns every flag, in the order of Features.
+func (fs *Set) Flags() []Flag {
+	if fs == nil {
+		return []Flag{}
+	}
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	out := make([]Flag, len(fs.flags))
+	for i, f := range fs.flags {
+		out[i] = f.view()
+	}
+	return out
+}
+
+// String lists the flags as name=on|off, for the startup banner.
+func (fs *Set) String() string {
+	var b strings.Builder
+	for i, f := range fs.Flags() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		state := "off"
+		if f.Enabled {
+			state = "on"
+		}
+		b.WriteString(f.Name + "=" + state)
+		if f.Source == SourceRollout {
+			b.WriteString(" (" + f.Setting + " rollout)")
+		}
+	}
+	return b.String()
+}
+
+func (f *flag) view() Flag {
+	v := Flag{
+		Name:        f.Name,
+		Description: f.Description,
+		Enabled:     f.enabled,
+		Source:      f.source,
+		Available:   f.available,
+		UpdatedAt:   f.updated.UTC().Format(time.RFC3339),
+	}
+	if f.setting != nil {
+		v.Setting = f.setting.raw
+	}
+	return v
+}
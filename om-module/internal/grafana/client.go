@@ -0,0 +1,194 @@
+// Package grafana is a small client for the parts of the Grafana HTTP API the
+// O&M module uses: annotations, dashboards, folders and datasource checks.
+// Requests are retried with exponential backoff when that is safe.
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+)
+
+const (
+	// defaultTimeout bounds a single attempt when New is given no timeout.
+	defaultTimeout = 10 * time.Second
+
+	maxAttempts    = 4
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// Auth selects how requests are authenticated. A service-account Token takes
+// precedence over basic auth; both empty sends anonymous requests.
+type Auth struct {
+	Token    string
+	User     string
+	Password string
+}
+
+// StatusError is returned when Grafana answers with an unexpected status.
+type StatusError struct {
+	Method string
+	Path   string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s: unexpected status %d", e.Method, e.Path, e.Code)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from Grafana.
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusNotFound
+}
+
+// IsForbidden reports whether err is a 401 or 403 from Grafana: the
+// credentials do not allow the call, e.g. an admin endpoint.
+func IsForbidden(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && (se.Code == http.StatusUnauthorized || se.Code == http.StatusForbidden)
+}
+
+// Client talks to one Grafana instance. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	auth    Auth
+	timeout time.Duration
+	http    *http.Client
+}
+
+// New returns a client for the Grafana instance at baseURL
+// (e.g. "http://grafana:3000"). timeout bounds each attempt; 0 means 10s.
+func New(baseURL string, auth Auth, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		auth:    auth,
+		timeout: timeout,
+		http:    httpclient.New("grafana", 0),
+	}
+}
+
+// URL returns the base URL the client was created with.
+func (c *Client) URL() string { return c.baseURL }
+
+// Health checks GET /api/health, which needs no authentication.
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/api/health", nil, nil)
+}
+
+// do sends one API request, retrying transient failures, and decodes a JSON
+// response into out when out is non-nil.
+//
+// GET, PUT and DELETE are idempotent and are retried on network errors, 429
+// and 5xx. POST is only retried when Grafana cannot have acted on it: the
+// connection was refused, or the answer was 429 / 503.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := c.attempt(ctx, method, path, body, out)
+		if err == nil || !retry || attempt == maxAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%s %s: %w (last error: %v)", method, path, ctx.Err(), err)
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// attempt performs a single request and reports whether a failure is worth
+// retrying.
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, out any) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, rd)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.auth.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.auth.Token)
+	case c.auth.User != "":
+		req.SetBasicAuth(c.auth.User, c.auth.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil && method == http.MethodPost {
+			// The request may have reached Grafana before the deadline.
+			return false, err
+		}
+		return method != http.MethodPost || isConnRefused(err), err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("⚠️  Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		serr := &StatusError{Method: method, Path: path, Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+			return true, serr
+		case resp.StatusCode >= 500:
+			return method != http.MethodPost, serr
+		}
+		return false, serr
+	}
+
+	if out == nil {
+		return false, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	return false, nil
+}
+
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
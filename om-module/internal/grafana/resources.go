@@ -0,0 +1,187 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// --- Annotations -----------------------------------------------------------
+
+// Annotation is a Grafana annotation. Time is in Unix milliseconds.
+type Annotation struct {
+	ID   int64 `json:"id,omitempty"`
+	Time int64 `json:"time"`
+	// TimeEnd, when set, makes the annotation a region.
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+}
+
+// CreateAnnotation posts an organisation-wide annotation and returns its ID.
+func (c *Client) CreateAnnotation(ctx context.Context, a Annotation) (int64, error) {
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/annotations", a, &resp); err != nil {
+		return 0, err
+	}
+	return resp.ID, nil
+}
+
+// FindAnnotations lists up to limit annotations carrying all of tags.
+func (c *Client) FindAnnotations(ctx context.Context, tags []string, limit int) ([]Annotation, error) {
+	q := url.Values{}
+	for _, t := range tags {
+		q.Add("tags", t)
+	}
+	q.Set("limit", fmt.Sprint(limit))
+
+	var out []Annotation
+	if err := c.do(ctx, http.MethodGet, "/api/annotations?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteAnnotation deletes one annotation. Deleting an annotation that is
+// already gone is not an error.
+func (c *Client) DeleteAnnotation(ctx context.Context, id int64) error {
+	err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/annotations/%d", id), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// --- Folders ---------------------------------------------------------------
+
+// Folder is a Grafana dashboard folder.
+type Folder struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// EnsureFolder returns the folder with uid, creating it with title if it
+// does not exist yet.
+func (c *Client) EnsureFolder(ctx context.Context, uid, title string) (Folder, error) {
+	var f Folder
+	err := c.do(ctx, http.MethodGet, "/api/folders/"+url.PathEscape(uid), nil, &f)
+	if err == nil || !IsNotFound(err) {
+		return f, err
+	}
+	err = c.do(ctx, http.MethodPost, "/api/folders", Folder{UID: uid, Title: title}, &f)
+	return f, err
+}
+
+// --- Dashboards ------------------------------------------------------------
+
+// UploadDashboard creates or replaces a dashboard in folderUID ("" for the
+// General folder). dashboard is the dashboard model as stored in
+// grafana/dashboards/*.json; its "id" is cleared so the upload matches by uid.
+func (c *Client) UploadDashboard(ctx context.Context, dashboard json.RawMessage, folderUID, message string) error {
+	var model map[string]any
+	if err := json.Unmarshal(dashboard, &model); err != nil {
+		return fmt.Errorf("decode dashboard: %w", err)
+	}
+	if _, ok := model["uid"].(string); !ok {
+		return fmt.Errorf("dashboard has no uid")
+	}
+	model["id"] = nil
+
+	body := struct {
+		Dashboard map[string]any `json:"dashboard"`
+		FolderUID string         `json:"folderUid,omitempty"`
+		Message   string         `json:"message,omitempty"`
+		Overwrite bool           `json:"overwrite"`
+	}{model, folderUID, message, true}
+
+	return c.do(ctx, http.MethodPost, "/api/dashboards/db", body, nil)
+}
+
+// DashboardMeta is what Grafana reports about a stored dashboard.
+type DashboardMeta struct {
+	UID         string `json:"uid"`
+	Title       string `json:"title"`
+	Version     int    `json:"version"`
+	Provisioned bool   `json:"provisioned"`
+	Updated     string `json:"updated"`
+	URL         string `json:"url"`
+}
+
+// GetDashboard returns Grafana's metadata for the dashboard with uid.
+func (c *Client) GetDashboard(ctx context.Context, uid string) (DashboardMeta, error) {
+	var resp struct {
+		Dashboard struct {
+			UID     string `json:"uid"`
+			Title   string `json:"title"`
+			Version int    `json:"version"`
+		} `json:"dashboard"`
+		Meta struct {
+			Provisioned bool   `json:"provisioned"`
+			Updated     string `json:"updated"`
+			URL         string `json:"url"`
+		} `json:"meta"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/dashboards/uid/"+url.PathEscape(uid), nil, &resp); err != nil {
+		return DashboardMeta{}, err
+	}
+	return DashboardMeta{
+		UID:         resp.Dashboard.UID,
+		Title:       resp.Dashboard.Title,
+		Version:     resp.Dashboard.Version,
+		Provisioned: resp.Meta.Provisioned,
+		Updated:     resp.Meta.Updated,
+		URL:         resp.Meta.URL,
+	}, nil
+}
+
+// ReloadDashboardProvisioning makes Grafana re-read its provisioned dashboard
+// files now instead of at the next provider poll. Needs an admin user.
+func (c *Client) ReloadDashboardProvisioning(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/admin/provisioning/dashboards/reload", nil, nil)
+}
+
+// ReloadDatasourceProvisioning makes Grafana re-read its provisioned
+// datasource files. Grafana does not poll them, so without an admin user a
+// changed file is only read at its next start.
+func (c *Client) ReloadDatasourceProvisioning(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/admin/provisioning/datasources/reload", nil, nil)
+}
+
+// --- Datasources -----------------------------------------------------------
+
+// Datasource is the subset of a Grafana datasource the module checks.
+type Datasource struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// CheckDatasources verifies that every datasource uid the dashboards refer
+// to is provisioned, and returns the missing ones.
+func (c *Client) CheckDatasources(ctx context.Context, uids ...string) ([]string, error) {
+	var missing []string
+	for _, uid := range uids {
+		var ds Datasource
+		err := c.do(ctx, http.MethodGet, "/api/datasources/uid/"+url.PathEscape(uid), nil, &ds)
+		switch {
+		case IsNotFound(err):
+			missing = append(missing, uid)
+		case err != nil:
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// DashboardDatasources are the datasource uids referenced by the dashboards
+// shipped in grafana/dashboards.
+var DashboardDatasources = []string{
+	"PBFA97CFB590B2093", // Prometheus
+	"P8E80F9AEF21F6940", // Loki
+	"tempo",             // Tempo
+	"infinity",          // Infinity (module REST API)
+}
@@ -0,0 +1,499 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+const networkName = "docker_open5gs_default"
+
+// Check types.
+const (
+	CheckHTTP = "http" // GET, expecting a status code
+	CheckTCP  = "tcp"  // connect
+	CheckExec = "exec" // command in the container, expecting exit code 0
+	CheckNone = "none" // drops checks (all of the component's without a name)
+)
+
+// Sources of a check.
+const (
+	SourceAuto = "auto"
+	SourceFile = "file"
+)
+
+var checkNameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// CheckSpec is one active check of a component.
+type CheckSpec struct {
+	// Component is the container name, om.component or om.nf the check is
+	// for; a container name wins over a component, a component over an NF.
+	Component string `yaml:"component" json:"-"`
+	// Name tells the checks of a component apart; default: the type. An
+	// entry with the name of a generated check replaces it.
+	Name string `yaml:"name,omitempty" json:"name"`
+	Type string `yaml:"type" json:"type"`
+	// Endpoint is host:port, or for http a base URL; default: the address
+	// of the container on the lab network and Port.
+	Endpoint string        `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Port     int           `yaml:"port,omitempty" json:"port,omitempty"`
+	Path     string        `yaml:"path,omitempty" json:"path,omitempty"`
+	Expect   int           `yaml:"expect,omitempty" json:"expect,omitempty"` // http status; default 200
+	Command  []string      `yaml:"command,omitempty" json:"command,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty" json:"-"`
+	Timeout  time.Duration `yaml:"timeout,omitempty" json:"-"`
+}
+
+// ChecksFile is the health checks file.
+type ChecksFile struct {
+	// Defaults apply to the checks, generated or not, that do not set
+	// their own.
+	Defaults struct {
+		Interval time.Duration `yaml:"interval,omitempty"`
+		Timeout  time.Duration `yaml:"timeout,omitempty"`
+	} `yaml:"defaults"`
+	Checks []CheckSpec `yaml:"checks"`
+}
+
+// LoadChecks reads and checks the health checks file at p.
+func LoadChecks(p string) (*ChecksFile, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	f := &ChecksFile{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	for i := range f.Checks {
+		if err := f.Checks[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: check %d: %w", p, i+1, err)
+		}
+	}
+	return f, nil
+}
+
+// validate checks s and fills in its name and expected status.
+func (s *CheckSpec) validate() error {
+	if s.Component == "" {
+		return errors.New("no component")
+	}
+	if s.Name == "" && s.Type != CheckNone {
+		s.Name = s.Type
+	}
+	if s.Name != "" && !checkNameRe.MatchString(s.Name) {
+		return fmt.Errorf("%s: name %q must be lowercase letters, digits, _ and -", s.Component, s.Name)
+	}
+	switch s.Type {
+	case CheckHTTP:
+		if s.Expect == 0 {
+			s.Expect = http.StatusOK
+		}
+		fallthrough
+	case CheckTCP:
+		if s.Endpoint == "" && s.Port == 0 {
+			return fmt.Errorf("%s: %s check needs a port or an endpoint", s.Component, s.Type)
+		}
+	case CheckExec:
+		if len(s.Command) == 0 {
+			return fmt.Errorf("%s: exec check needs a command", s.Component)
+		}
+	case CheckNone:
+	default:
+		return fmt.Errorf("%s: type %q is not http, tcp, exec or none", s.Component, s.Type)
+	}
+	return nil
+}
+
+// autoChecks are the checks generated for cd: its metrics endpoint, when
+// its prometheus.* labels declare one.
+func autoChecks(cd *collector.ContainerData) []CheckSpec {
+	if cd.MetricsAddress == "" {
+		return nil
+	}
+	_, port, _ := strings.Cut(cd.MetricsAddress, ":")
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil
+	}
+	return []CheckSpec{{Component: cd.Name, Name: "metrics", Type: CheckHTTP, Port: p, Path: "/metrics", Expect: http.StatusOK}}
+}
+
+// Check is a check of a container as it runs: its spec, where it comes
+// from and its last result.
+type Check struct {
+	CheckSpec
+	Container  string   `json:"container"`
+	Source     string   `json:"source"` // auto | file
+	IntervalS  float64  `json:"interval_seconds"`
+	TimeoutS   float64  `json:"timeout_seconds"`
+	Target     string   `json:"target,omitempty"` // what was probed last
+	OK         *bool    `json:"ok,omitempty"`     // nil before the first run
+	Error      string   `json:"error,omitempty"`
+	DurationMs *float64 `json:"duration_ms,omitempty"`
+	CheckedAt  string   `json:"checked_at,omitempty"`
+
+	next time.Time
+}
+
+// Prober runs the active health checks of the containers: the generated
+// ones merged with the checks file.
+type Prober struct {
+	docker   *dockerclient.Client
+	snap     *collector.Snapshot
+	file     *ChecksFile
+	interval time.Duration
+	timeout  time.Duration
+	http     *http.Client
+
+	up *prometheus.GaugeVec
+
+	mu     sync.RWMutex
+	checks map[string][]*Check // by container
+	err    string              // last error listing the container addresses
+}
+
+// NewProber registers om_health_check_up on reg. file may be nil, for the
+// generated checks only; interval and timeout apply to the checks that set
+// neither, in the file or its defaults.
+func NewProber(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, file *ChecksFile, interval, timeout time.Duration) *Prober {
+	if file == nil {
+		file = &ChecksFile{}
+	}
+	if file.Defaults.Interval > 0 {
+		interval = file.Defaults.Interval
+	}
+	if file.Defaults.Timeout > 0 {
+		timeout = file.Defaults.Timeout
+	}
+	p := &Prober{
+		docker:   docker,
+		snap:     snap,
+		file:     file,
+		interval: interval,
+		timeout:  timeout,
+		http:     httpclient.New("healthcheck", 0),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "health", Name: "check_up",
+			Help: "1 if the active health check of the container passed on its last run, 0 otherwise.",
+		}, []string{"container", "check", "type"}),
+		checks: make(map[string][]*Check),
+	}
+	reg.MustRegister(p.up)
+	return p
+}
+
+// Summary describes the checks file for the startup log.
+func (f *ChecksFile) Summary() string {
+	if f == nil || len(f.Checks) == 0 {
+		return "generated checks only"
+	}
+	return fmt.Sprintf("%d checks file entries", len(f.Checks))
+}
+
+// specificity ranks how a spec matches cd: 0 not at all, 1 by NF, 2 by
+// component, 3 by container name.
+func specificity(s CheckSpec, cd *collector.ContainerData) int {
+	switch s.Component {
+	case cd.Name:
+		return 3
+	case cd.Component:
+		return 2
+	case cd.NF:
+		return 1
+	}
+	return 0
+}
+
+// specsFor merges the generated checks of cd with the file entries that
+// match it, the more specific entries last so they win.
+func (p *Prober) specsFor(cd *collector.ContainerData) (specs []CheckSpec, sources []string) {
+	byName := make(map[string]int)
+	add := func(s CheckSpec, source string) {
+		if i, ok := byName[s.Name]; ok {
+			specs[i], sources[i] = s, source
+			return
+		}
+		byName[s.Name] = len(specs)
+		specs = append(specs, s)
+		sources = append(sources, source)
+	}
+	for _, s := range autoChecks(cd) {
+		add(s, SourceAuto)
+	}
+	for rank := 1; rank <= 3; rank++ {
+		for _, s := range p.file.Checks {
+			if specificity(s, cd) != rank {
+				continue
+			}
+			if s.Type != CheckNone {
+				add(s, SourceFile)
+				continue
+			}
+			// "none" drops the named check, or every check so far.
+			var keptSpecs []CheckSpec
+			var keptSources []string
+			byName = make(map[string]int)
+			for i, k := range specs {
+				if s.Name == "" || k.Name == s.Name {
+					continue
+				}
+				byName[k.Name] = len(keptSpecs)
+				keptSpecs = append(keptSpecs, k)
+				keptSources = append(keptSources, sources[i])
+			}
+			specs, sources = keptSpecs, keptSources
+		}
+	}
+	for i := range specs {
+		if specs[i].Interval == 0 {
+			specs[i].Interval = p.interval
+		}
+		if specs[i].Timeout == 0 {
+			specs[i].Timeout = p.timeout
+		}
+	}
+	return specs, sources
+}
+
+// tick is how often Run looks for due checks: the shortest interval.
+func (p *Prober) tick() time.Duration {
+	d := p.interval
+	for _, s := range p.file.Checks {
+		if s.Interval > 0 && s.Interval < d {
+			d = s.Interval
+		}
+	}
+	return d
+}
+
+// Run runs the checks as they fall due until ctx is cancelled.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.tick())
+	defer ticker.Stop()
+	for {
+		p.runDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue refreshes the checks of the running containers from the snapshot
+// and runs those that are due.
+func (p *Prober) runDue(ctx context.Context) {
+	ips, ipErr := p.containerIPs(ctx)
+	now := time.Now()
+
+	p.mu.Lock()
+	next := make(map[string][]*Check)
+	var due []*Check
+	for name, cd := range p.snap.All() {
+		if cd.State != "running" {
+			continue
+		}
+		previous := make(map[string]*Check)
+		for _, c := range p.checks[name] {
+			previous[c.Name] = c
+		}
+		specs, sources := p.specsFor(cd)
+		for i, s := range specs {
+			c := &Check{CheckSpec: s, Container: name, Source: sources[i],
+				IntervalS: s.Interval.Seconds(), TimeoutS: s.Timeout.Seconds()}
+			if old, ok := previous[s.Name]; ok && old.CheckSpec.equal(s) {
+				c = old
+				c.Source = sources[i]
+			}
+			if !now.Before(c.next) {
+				c.next = now.Add(s.Interval)
+				due = append(due, c)
+			}
+			next[name] = append(next[name], c)
+		}
+	}
+	for name, checks := range p.checks {
+		for _, c := range checks {
+			if !contains(next[name], c) {
+				p.up.DeleteLabelValues(name, c.Name, c.Type)
+			}
+		}
+	}
+	p.checks = next
+	p.err = ""
+	if ipErr != nil {
+		p.err = ipErr.Error()
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range due {
+		wg.Add(1)
+		go func(c *Check) {
+			defer wg.Done()
+			start := time.Now()
+			target, err := p.run(ctx, c, ips)
+			elapsed := float64(time.Since(start).Microseconds()) / 1000
+			ok := err == nil
+
+			p.mu.Lock()
+			c.Target, c.OK, c.DurationMs = target, &ok, &elapsed
+			c.Error = ""
+			if err != nil {
+				c.Error = err.Error()
+			}
+			c.CheckedAt = start.UTC().Format(time.RFC3339)
+			p.mu.Unlock()
+
+			v := 0.0
+			if ok {
+				v = 1
+			}
+			p.up.WithLabelValues(c.Container, c.Name, c.Type).Set(v)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (s CheckSpec) equal(o CheckSpec) bool {
+	return s.Type == o.Type && s.Endpoint == o.Endpoint && s.Port == o.Port && s.Path == o.Path &&
+		s.Expect == o.Expect && strings.Join(s.Command, "\x00") == strings.Join(o.Command, "\x00") &&
+		s.Interval == o.Interval && s.Timeout == o.Timeout
+}
+
+func contains(checks []*Check, c *Check) bool {
+	for _, k := range checks {
+		if k == c {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs one check and returns what it probed.
+func (p *Prober) run(ctx context.Context, c *Check, ips map[string]string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	if c.Type == CheckExec {
+		target := strings.Join(c.Command, " ")
+		out, code, err := p.docker.Exec(ctx, c.Container, c.Command)
+		if err != nil {
+			return target, err
+		}
+		if code != 0 {
+			return target, fmt.Errorf("exit code %d: %s", code, firstLine(out))
+		}
+		return target, nil
+	}
+
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		ip, ok := ips[c.Container]
+		if !ok {
+			return "", errors.New("not on " + networkName)
+		}
+		endpoint = net.JoinHostPort(ip, strconv.Itoa(c.Port))
+	}
+
+	if c.Type == CheckTCP {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", endpoint)
+		if err != nil {
+			return endpoint, err
+		}
+		conn.Close()
+		return endpoint, nil
+	}
+
+	target := endpoint
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+	target = strings.TrimRight(target, "/") + "/" + strings.TrimLeft(c.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return target, err
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return target, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != c.Expect {
+		return target, fmt.Errorf("status %s, expected %d", resp.Status, c.Expect)
+	}
+	return target, nil
+}
+
+func firstLine(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	return s
+}
+
+// containerIPs maps container names to their address on the lab network.
+func (p *Prober) containerIPs(ctx context.Context) (map[string]string, error) {
+	ipToName, err := p.docker.GetNetworkContainerIPs(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(ipToName))
+	for ip, name := range ipToName {
+		out[name] = ip
+	}
+	return out, nil
+}
+
+// Checks returns the checks of container, with their last results.
+func (p *Prober) Checks(container string) []Check {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]Check, 0, len(p.checks[container]))
+	for _, c := range p.checks[container] {
+		out = append(out, *c)
+	}
+	return out
+}
+
+// All returns the checks of every running container, sorted by container
+// and name.
+func (p *Prober) All() []Check {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []Check
+	for _, checks := range p.checks {
+		for _, c := range checks {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Container != out[j].Container {
+			return out[i].Container < out[j].Container
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Error returns the last error listing the container addresses, or "".
+func (p *Prober) Error() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.err
+}
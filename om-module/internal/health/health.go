@@ -0,0 +1,481 @@
+// Package health rolls the testbed components up into three states: up,
+// degraded and down. Down comes from Docker: the container exited or
+// died. Degraded is a container that is not down but does not meet its
+// service level objectives:
+//
+//   - its SBI responses (5G NFs) are slower than the response-time SLO at
+//     the 95th percentile, or succeed less often than the success-rate SLO,
+//     over the SLO window (om_sbi_* from the capture pipeline);
+//   - its metrics are stale: Prometheus fails to scrape its metrics
+//     endpoint, or its resource stats were not refreshed for 3 collection
+//     intervals;
+//   - Docker reports it restarting, paused or just created;
+//   - one of its active checks fails (Prober): the HTTP check generated
+//     for its metrics endpoint, and the http, tcp and exec checks of the
+//     health checks file, which can also replace or drop the generated
+//     ones for components the defaults do not fit.
+//
+// The state is exported as om_health_status (1 up, 0.5 degraded, 0 down)
+// and rolled up into om_health_overall.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Status is the health of a component or of the whole testbed.
+type Status string
+
+// Health states.
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Value is the metric value of s: 1 up, 0.5 degraded, 0 down.
+func (s Status) Value() float64 {
+	switch s {
+	case StatusUp:
+		return 1
+	case StatusDegraded:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// worse reports whether s is worse than t.
+func (s Status) worse(t Status) bool { return s.Value() < t.Value() }
+
+const (
+	// staleIntervals is how many collection intervals the resource stats
+	// of a running container may miss before its metrics count as stale.
+	staleIntervals = 3
+
+	// minRequests is how many SBI requests an NF must have answered in the
+	// window before its success rate is judged.
+	minRequests = 10
+)
+
+// Options configure the evaluator. Without SLOs the health follows the
+// container state only; without PrometheusURL the SBI and scrape signals
+// are left out.
+type Options struct {
+	SLOs              bool
+	PrometheusURL     string
+	PrometheusTimeout time.Duration
+	Interval          time.Duration
+
+	// ResponseTime is the SBI response-time SLO at the 95th percentile.
+	ResponseTime time.Duration
+	// SuccessRate is the SBI success-rate SLO (0–1): answered with a 2xx
+	// or 3xx status, out of all requests including unanswered ones.
+	SuccessRate float64
+	// Window is what the SLOs are evaluated over.
+	Window time.Duration
+}
+
+// Component is the health of one container.
+type Component struct {
+	Container       string   `json:"container"`
+	Component       string   `json:"component"`
+	Domain          string   `json:"domain"`
+	NF              string   `json:"nf"`
+	Generation      string   `json:"generation"`
+	State           string   `json:"state"`
+	Status          Status   `json:"status"`
+	Reasons         []string `json:"reasons"` // why it is not up
+	ResponseP95Ms   *float64 `json:"sbi_response_p95_ms,omitempty"`
+	SuccessRate     *float64 `json:"sbi_success_rate,omitempty"`
+	ScrapeUp        *bool    `json:"scrape_up,omitempty"`
+	StatsAgeSeconds *float64 `json:"stats_age_seconds,omitempty"`
+	Checks          []Check  `json:"checks,omitempty"`
+}
+
+// Rollup is the health of the testbed.
+type Rollup struct {
+	// Status is down when a core NF is down, degraded when a component is
+	// degraded or a component outside the core is down, up otherwise.
+	Status          Status      `json:"status"`
+	Up              int         `json:"up"`
+	Degraded        int         `json:"degraded"`
+	Down            int         `json:"down"`
+	ResponseTimeSLO string      `json:"response_time_slo"`
+	SuccessRateSLO  float64     `json:"success_rate_slo"`
+	Window          string      `json:"window"`
+	UpdatedAt       string      `json:"updated_at,omitempty"` // last SLO evaluation
+	Error           string      `json:"error,omitempty"`
+	Components      []Component `json:"components"` // worst first
+}
+
+// Evaluator polls the SLO signals and computes the health on demand.
+type Evaluator struct {
+	snap   *collector.Snapshot
+	opts   Options
+	client *http.Client
+	prober *Prober
+
+	status  *prometheus.Desc
+	overall *prometheus.Desc
+	counts  *prometheus.Desc
+
+	mu       sync.RWMutex
+	p95      map[string]float64 // SBI p95 in seconds, by server NF
+	success  map[string]float64 // SBI success rate, by server NF
+	scrapeUp map[string]bool    // by container
+	updated  time.Time
+	lastErr  string
+}
+
+// New registers om_health_status, om_health_overall and om_health_components
+// on reg and returns the evaluator of the containers in snap.
+func New(reg prometheus.Registerer, snap *collector.Snapshot, opts Options) *Evaluator {
+	e := &Evaluator{
+		snap:   snap,
+		opts:   opts,
+		client: httpclient.New("health", 0),
+		status: prometheus.NewDesc(
+			"om_health_status",
+			"Component health against its SLOs: 1 = up, 0.5 = degraded (slow, failing or stale), 0 = down.",
+			[]string{"container", "domain", "nf", "generation", "compose_project", "service", "owner"}, nil,
+		),
+		overall: prometheus.NewDesc(
+			"om_health_overall",
+			"Testbed health rollup: 1 = up, 0.5 = degraded, 0 = down (a core NF is down).",
+			nil, nil,
+		),
+		counts: prometheus.NewDesc(
+			"om_health_components",
+			"Components per health status.",
+			[]string{"status"}, nil,
+		),
+		p95:      map[string]float64{},
+		success:  map[string]float64{},
+		scrapeUp: map[string]bool{},
+	}
+	reg.MustRegister(e)
+	return e
+}
+
+// SetProber makes the failing active checks of p degrade their component.
+func (e *Evaluator) SetProber(p *Prober) {
+	e.prober = p
+}
+
+// Describe sends the metric descriptors to the channel.
+func (e *Evaluator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.status
+	ch <- e.overall
+	ch <- e.counts
+}
+
+// Collect evaluates the health at scrape time, so a stopped container is
+// down without waiting for the next SLO poll.
+func (e *Evaluator) Collect(ch chan<- prometheus.Metric) {
+	all := e.snap.All()
+	r := e.evaluate(all)
+	for _, c := range r.Components {
+		cd := all[c.Container]
+		// The label values come from Docker labels and the owners file;
+		// a series they make invalid is dropped, not a panic in the
+		// scrape.
+		if m, err := prometheus.NewConstMetric(e.status, prometheus.GaugeValue, c.Status.Value(),
+			cd.Name, cd.Domain, cd.NF, cd.Generation, cd.ComposeProject, cd.Component, cd.Owner); err == nil {
+			ch <- m
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.overall, prometheus.GaugeValue, r.Status.Value())
+	for s, n := range map[Status]int{StatusUp: r.Up, StatusDegraded: r.Degraded, StatusDown: r.Down} {
+		ch <- prometheus.MustNewConstMetric(e.counts, prometheus.GaugeValue, float64(n), string(s))
+	}
+}
+
+// Run polls the SLO signals every interval until ctx is cancelled. It
+// returns at once without SLOs or a Prometheus to poll.
+func (e *Evaluator) Run(ctx context.Context) {
+	if !e.opts.SLOs || e.opts.PrometheusURL == "" {
+		return
+	}
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := e.update(ctx); err != nil && ctx.Err() == nil {
+			e.mu.Lock()
+			first := e.lastErr == ""
+			e.lastErr = err.Error()
+			e.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Health SLO evaluation: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the current health of every component and the rollup.
+func (e *Evaluator) Status() Rollup {
+	return e.evaluate(e.snap.All())
+}
+
+// Freshness returns when the SLO signals were last read, for exporter.Ages.
+func (e *Evaluator) Freshness() map[string]time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.updated.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_health_status": e.updated}
+}
+
+func (e *Evaluator) evaluate(all map[string]*collector.ContainerData) Rollup {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	r := Rollup{
+		Status:          StatusUp,
+		ResponseTimeSLO: e.opts.ResponseTime.String(),
+		SuccessRateSLO:  e.opts.SuccessRate,
+		Window:          e.opts.Window.String(),
+		Error:           e.lastErr,
+		Components:      make([]Component, 0, len(all)),
+	}
+	if !e.updated.IsZero() {
+		r.UpdatedAt = e.updated.UTC().Format(time.RFC3339)
+	}
+	if e.opts.SLOs && e.opts.PrometheusURL == "" {
+		r.Error = "PROMETHEUS_URL not set or not ready at startup: SBI SLOs and scrape health not evaluated"
+	}
+
+	external := e.snap.ExternalContainerStats()
+	now := time.Now()
+	for _, cd := range all {
+		c := Component{
+			Container: cd.Name, Component: cd.Component, Domain: cd.Domain,
+			NF: cd.NF, Generation: cd.Generation, State: cd.State,
+			Status: StatusUp, Reasons: []string{},
+		}
+		degrade := func(reason string) {
+			c.Status = StatusDegraded
+			c.Reasons = append(c.Reasons, reason)
+		}
+
+		switch cd.HealthValue() {
+		case -1:
+			c.Status = StatusDown
+			c.Reasons = append(c.Reasons, "container "+cd.State)
+		case 0:
+			degrade("container " + cd.State)
+		}
+		if e.prober != nil && c.Status != StatusDown {
+			c.Checks = e.prober.Checks(cd.Name)
+			for _, chk := range c.Checks {
+				if chk.OK != nil && !*chk.OK {
+					degrade(fmt.Sprintf("%s check %s failed: %s", chk.Type, chk.Name, chk.Error))
+				}
+			}
+		}
+		if e.opts.SLOs && c.Status != StatusDown {
+			if up, ok := e.scrapeUp[cd.Name]; ok {
+				c.ScrapeUp = &up
+				if !up {
+					degrade("metrics endpoint not scraped by Prometheus (up=0)")
+				}
+			}
+			if cd.State == "running" && !external && !cd.StatsAt.IsZero() && cd.CollectInterval > 0 {
+				age := now.Sub(cd.StatsAt)
+				secs := age.Seconds()
+				c.StatsAgeSeconds = &secs
+				if age > staleIntervals*cd.CollectInterval {
+					degrade(fmt.Sprintf("resource stats %s old", age.Round(time.Second)))
+				}
+			}
+			if cd.NFKind.SBI() && cd.Generation != "4g" {
+				if p95, ok := e.p95[cd.NF]; ok {
+					ms := p95 * 1000
+					c.ResponseP95Ms = &ms
+					if e.opts.ResponseTime > 0 && p95 > e.opts.ResponseTime.Seconds() {
+						degrade(fmt.Sprintf("SBI p95 response time %s above the %s SLO",
+							time.Duration(p95*float64(time.Second)).Round(time.Millisecond), e.opts.ResponseTime))
+					}
+				}
+				if rate, ok := e.success[cd.NF]; ok {
+					c.SuccessRate = &rate
+					if rate < e.opts.SuccessRate {
+						degrade(fmt.Sprintf("SBI success rate %.1f%% below the %g%% SLO", rate*100, e.opts.SuccessRate*100))
+					}
+				}
+			}
+		}
+
+		switch c.Status {
+		case StatusUp:
+			r.Up++
+		case StatusDegraded:
+			r.Degraded++
+			if r.Status == StatusUp {
+				r.Status = StatusDegraded
+			}
+		case StatusDown:
+			r.Down++
+			rolled := StatusDegraded
+			if cd.Domain == collector.DomainCore {
+				rolled = StatusDown
+			}
+			if rolled.worse(r.Status) {
+				r.Status = rolled
+			}
+		}
+		r.Components = append(r.Components, c)
+	}
+
+	sort.Slice(r.Components, func(i, j int) bool {
+		a, b := r.Components[i], r.Components[j]
+		if a.Status != b.Status {
+			return a.Status.worse(b.Status)
+		}
+		return a.Container < b.Container
+	})
+	return r
+}
+
+// update reads the SLO signals from Prometheus.
+func (e *Evaluator) update(ctx context.Context) error {
+	w := promDuration(e.opts.Window)
+
+	latency, err := e.query(ctx, fmt.Sprintf(
+		`histogram_quantile(0.95, sum by (dst_nf, le) (rate(om_sbi_response_seconds_bucket[%s])))`, w))
+	if err != nil {
+		return err
+	}
+	failed, err := e.query(ctx, fmt.Sprintf(
+		`sum by (dst_nf) (increase(om_sbi_responses_total{status!~"[23].."}[%s]))`, w))
+	if err != nil {
+		return err
+	}
+	answered, err := e.query(ctx, fmt.Sprintf(`sum by (dst_nf) (increase(om_sbi_responses_total[%s]))`, w))
+	if err != nil {
+		return err
+	}
+	unanswered, err := e.query(ctx, fmt.Sprintf(`sum by (dst_nf) (increase(om_sbi_unanswered_total[%s]))`, w))
+	if err != nil {
+		return err
+	}
+	up, err := e.query(ctx, `up{job="docker-services"}`)
+	if err != nil {
+		return err
+	}
+
+	p95 := map[string]float64{}
+	for _, s := range latency {
+		if nf := s.labels["dst_nf"]; nf != "" && !math.IsNaN(s.value) { // NaN without responses
+			p95[nf] = s.value
+		}
+	}
+	bad := byLabel(failed, "dst_nf")
+	lost := byLabel(unanswered, "dst_nf")
+	success := map[string]float64{}
+	for nf, total := range byLabel(answered, "dst_nf") {
+		all := total + lost[nf]
+		if all < minRequests {
+			continue
+		}
+		success[nf] = (total - bad[nf]) / all
+	}
+	scrapeUp := map[string]bool{}
+	for _, s := range up {
+		if c := s.labels["container"]; c != "" {
+			scrapeUp[c] = s.value == 1
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.p95, e.success, e.scrapeUp = p95, success, scrapeUp
+	e.updated = time.Now()
+	e.lastErr = ""
+	return nil
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+func byLabel(samples []sample, label string) map[string]float64 {
+	out := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		if v := s.labels[label]; v != "" {
+			out[v] += s.value
+		}
+	}
+	return out
+}
+
+// query runs one instant query.
+func (e *Evaluator) query(ctx context.Context, expr string) ([]sample, error) {
+	q := url.Values{}
+	q.Set("query", expr)
+	target := strings.TrimRight(e.opts.PrometheusURL, "/") + "/api/v1/query?" + q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, e.opts.PrometheusTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make([]sample, 0, len(body.Data.Result))
+	for _, res := range body.Data.Result {
+		s, _ := res.Value[1].(string)
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, sample{labels: res.Metric, value: v})
+	}
+	return out, nil
+}
+
+// promDuration formats d as a PromQL duration in whole seconds.
+func promDuration(d time.Duration) string {
+	return strconv.Itoa(int(d.Seconds())) + "s"
+}
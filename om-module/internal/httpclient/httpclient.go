@@ -0,0 +1,164 @@
+// Package httpclient is the HTTP client of every outbound call the module
+// makes: Prometheus, Loki, Grafana, Promtail, the NFs, cluster peers. The
+// clients share one transport, so the connections to the lab's small
+// containers are kept alive and reused, and bounded per host, instead of
+// every subsystem dialling its own. Instrument exports, per client, the
+// requests, how many reused a connection, and the time spent resolving,
+// connecting and waiting for the first byte.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options tune the shared transport.
+type Options struct {
+	// MaxConnsPerHost bounds the connections, idle or in use, to one
+	// host; requests beyond it wait for one to be free.
+	MaxConnsPerHost int
+	// MaxIdleConnsPerHost is how many connections to one host are kept
+	// alive between requests.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes the connections idle for that long.
+	IdleConnTimeout time.Duration
+}
+
+// DefaultOptions are the options of the transport until Configure.
+func DefaultOptions() Options {
+	return Options{MaxConnsPerHost: 8, MaxIdleConnsPerHost: 4, IdleConnTimeout: 90 * time.Second}
+}
+
+var shared atomic.Pointer[http.Transport]
+
+func init() {
+	shared.Store(newTransport(DefaultOptions()))
+}
+
+func newTransport(o Options) *http.Transport {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Configure replaces the shared transport with one tuned by o. Clients
+// already created use it from their next request.
+func Configure(o Options) {
+	if old := shared.Swap(newTransport(o)); old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "om", Subsystem: "http_client", Name: "requests_total",
+		Help: "Outbound HTTP requests of the module, per client, host and status code (\"error\" without a response).",
+	}, []string{"client", "host", "code"})
+	connections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "om", Subsystem: "http_client", Name: "connections_total",
+		Help: "Connections outbound HTTP requests were sent on, per client and whether the connection was reused (true) or dialled (false).",
+	}, []string{"client", "reused"})
+	duration = newHistogram("request_duration_seconds", "Time until the response headers of outbound HTTP requests, per client.")
+	dns      = newHistogram("dns_seconds", "Time resolving the host of outbound HTTP requests, per client.")
+	connect  = newHistogram("connect_seconds", "Time dialling a new connection for outbound HTTP requests, per client.")
+	ttfb     = newHistogram("ttfb_seconds", "Time from sending outbound HTTP requests to the first byte of the response, per client.")
+)
+
+func newHistogram(name, help string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "om", Subsystem: "http_client", Name: name, Help: help,
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"client"})
+}
+
+// Instrument registers the om_http_client_* metrics on reg.
+func Instrument(reg prometheus.Registerer) {
+	reg.MustRegister(requests, connections, duration, dns, connect, ttfb)
+}
+
+// New returns a client named name in the metrics, with timeout (0 for
+// none, leaving the deadline to the request context) over the shared
+// transport.
+func New(name string, timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: &transport{name: name}}
+}
+
+type transport struct {
+	name string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	var (
+		mu                  sync.Mutex
+		dnsStart, connStart time.Time
+		wrote               time.Time
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !dnsStart.IsZero() {
+				dns.WithLabelValues(t.name).Observe(time.Since(dnsStart).Seconds())
+			}
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			connStart = time.Now()
+			mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil && !connStart.IsZero() {
+				connect.WithLabelValues(t.name).Observe(time.Since(connStart).Seconds())
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			connections.WithLabelValues(t.name, strconv.FormatBool(info.Reused)).Inc()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			wrote = time.Now()
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !wrote.IsZero() {
+				ttfb.WithLabelValues(t.name).Observe(time.Since(wrote).Seconds())
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := shared.Load().RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	requests.WithLabelValues(t.name, req.URL.Host, code).Inc()
+	duration.WithLabelValues(t.name).Observe(time.Since(start).Seconds())
+	return resp, err
+}
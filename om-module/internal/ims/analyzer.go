@@ -0,0 +1,371 @@
+// Package ims follows IMS signalling (SIP between the UE and the Kamailio
+// P/I/S-CSCFs) for VoLTE/VoNR labs: registrations, calls, per-message
+// explanations and SIP health checks of the CSCFs.
+package ims
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxEvents is how many recent SIP messages are kept for GET /ims.
+	maxEvents = 100
+
+	// callRetention is how long finished calls stay in the call table.
+	callRetention = 10 * time.Minute
+)
+
+// Registration states.
+const (
+	RegPending      = "pending"
+	RegChallenged   = "challenged"
+	RegRegistered   = "registered"
+	RegDeregistered = "deregistered"
+	RegFailed       = "failed"
+)
+
+// Call states.
+const (
+	CallSetup       = "setup"
+	CallRinging     = "ringing"
+	CallEstablished = "established"
+	CallTerminated  = "terminated"
+	CallCancelled   = "cancelled"
+	CallFailed      = "failed"
+)
+
+// Registration is the IMS registration state of one public identity.
+type Registration struct {
+	User        string `json:"user"`
+	State       string `json:"state"`
+	LastCode    int    `json:"last_code,omitempty"`
+	Explanation string `json:"explanation"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// Call is one INVITE dialog.
+type Call struct {
+	CallID          string  `json:"call_id"`
+	From            string  `json:"from"`
+	To              string  `json:"to"`
+	State           string  `json:"state"`
+	LastCode        int     `json:"last_code,omitempty"`
+	Explanation     string  `json:"explanation"`
+	StartedAt       string  `json:"started_at"`
+	SetupSeconds    float64 `json:"setup_seconds,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// Event is one SIP message with its educational explanation.
+type Event struct {
+	Time        string `json:"time"`
+	SrcNF       string `json:"src_nf"`
+	DstNF       string `json:"dst_nf"`
+	Message     string `json:"message"`
+	User        string `json:"user,omitempty"`
+	CallID      string `json:"call_id"`
+	Explanation string `json:"explanation"`
+}
+
+// Summary is the API view of the analyzer.
+type Summary struct {
+	Registrations []Registration `json:"registrations"`
+	Calls         []Call         `json:"calls"`
+	Recent        []Event        `json:"recent"`
+}
+
+// Analyzer follows REGISTER and INVITE flows across the CSCFs. It implements
+// pipeline.Observer.
+type Analyzer struct {
+	requests   *prometheus.CounterVec
+	responses  *prometheus.CounterVec
+	registered prometheus.Gauge
+	calls      *prometheus.GaugeVec
+	regTime    prometheus.Histogram
+	setupTime  prometheus.Histogram
+
+	mu       sync.Mutex
+	regs     map[string]*regState  // keyed by IMPU user part
+	dialogs  map[string]*callState // keyed by Call-ID
+	deregIDs map[string]bool       // Call-IDs of REGISTERs with Expires: 0
+	events   []Event
+}
+
+type regState struct {
+	Registration
+	started time.Time // first REGISTER of the current attempt
+}
+
+type callState struct {
+	Call
+	started  time.Time
+	answered time.Time
+	ended    time.Time
+}
+
+// NewAnalyzer registers the IMS metrics on reg and returns the analyzer.
+func NewAnalyzer(reg prometheus.Registerer) *Analyzer {
+	a := &Analyzer{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "sip", Name: "requests_total",
+			Help: "SIP requests captured between IMS nodes, by method and hop.",
+		}, []string{"method", "src_nf", "dst_nf"}),
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "sip", Name: "responses_total",
+			Help: "SIP responses captured between IMS nodes, by request method and status code.",
+		}, []string{"method", "code", "class"}),
+		registered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ims", Name: "registered_users",
+			Help: "IMS public identities currently registered.",
+		}),
+		calls: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ims", Name: "calls",
+			Help: "Calls in the call table by state (setup | ringing | established | terminated | cancelled | failed).",
+		}, []string{"state"}),
+		regTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "om", Subsystem: "ims", Name: "registration_seconds",
+			Help:    "Time from the first REGISTER to the 200 OK, including the AKA challenge round trip.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		}),
+		setupTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "om", Subsystem: "ims", Name: "call_setup_seconds",
+			Help:    "Time from INVITE to the first 180 Ringing or 200 OK (post-dial delay).",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+		}),
+		regs:     make(map[string]*regState),
+		dialogs:  make(map[string]*callState),
+		deregIDs: make(map[string]bool),
+	}
+	reg.MustRegister(a.requests, a.responses, a.registered, a.calls, a.regTime, a.setupTime)
+	return a
+}
+
+// Observe implements pipeline.Observer.
+func (a *Analyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	if pkt.Protocol != "sip" || pkt.SIPMethod == "OPTIONS" || pkt.SIPCSeqMethod == "OPTIONS" {
+		return
+	}
+
+	if pkt.SIPMethod != "" {
+		a.requests.WithLabelValues(pkt.SIPMethod, srcNF, dstNF).Inc()
+	} else {
+		a.responses.WithLabelValues(pkt.SIPCSeqMethod, fmt.Sprint(pkt.SIPStatusCode), fmt.Sprintf("%dxx", pkt.SIPStatusCode/100)).Inc()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	at := pkt.Timestamp
+	explanation := explain(pkt.SIPMethod, pkt.SIPStatusCode, pkt.SIPCSeqMethod)
+	a.addEvent(pkt, srcNF, dstNF, explanation)
+
+	switch {
+	case pkt.SIPMethod == "REGISTER" || pkt.SIPCSeqMethod == "REGISTER":
+		a.observeRegister(pkt, at, explanation)
+	case pkt.SIPMethod == "INVITE" || pkt.SIPCSeqMethod == "INVITE",
+		pkt.SIPMethod == "ACK", pkt.SIPMethod == "BYE", pkt.SIPMethod == "CANCEL":
+		a.observeCall(pkt, at, explanation)
+	}
+	a.updateGauges(at)
+}
+
+// observeRegister advances the registration state machine. The same message
+// is seen once per hop (UE → P-CSCF → I-CSCF → S-CSCF), so every transition
+// is idempotent.
+func (a *Analyzer) observeRegister(pkt capture.Packet, at time.Time, explanation string) {
+	user := pkt.SIPToUser
+	if user == "" {
+		return
+	}
+	st, ok := a.regs[user]
+	if !ok {
+		st = &regState{Registration: Registration{User: user}}
+		a.regs[user] = st
+	}
+	st.Explanation = explanation
+	st.UpdatedAt = at.UTC().Format(time.RFC3339)
+
+	if pkt.SIPMethod == "REGISTER" {
+		// UEs reuse the Call-ID for refreshes and the final de-registration.
+		if pkt.SIPExpires == "0" {
+			a.deregIDs[pkt.SIPCallID] = true
+		} else {
+			delete(a.deregIDs, pkt.SIPCallID)
+		}
+		// A refresh of an active registration keeps it registered.
+		if st.State != RegPending && st.State != RegChallenged && st.State != RegRegistered {
+			st.State = RegPending
+			st.started = at
+		}
+		return
+	}
+
+	st.LastCode = pkt.SIPStatusCode
+	switch code := pkt.SIPStatusCode; {
+	case code == 401 || code == 407:
+		st.State = RegChallenged
+	case code >= 200 && code < 300:
+		if a.deregIDs[pkt.SIPCallID] {
+			st.State = RegDeregistered
+			return
+		}
+		if st.State != RegRegistered && !st.started.IsZero() {
+			a.regTime.Observe(at.Sub(st.started).Seconds())
+		}
+		st.State = RegRegistered
+		st.started = time.Time{}
+	case code >= 300:
+		st.State = RegFailed
+		st.started = time.Time{}
+	}
+}
+
+// observeCall advances the state of one INVITE dialog.
+func (a *Analyzer) observeCall(pkt capture.Packet, at time.Time, explanation string) {
+	id := pkt.SIPCallID
+	if id == "" {
+		return
+	}
+	c, ok := a.dialogs[id]
+	if !ok {
+		if pkt.SIPMethod != "INVITE" {
+			return // mid-dialog message of a call that started before capture
+		}
+		c = &callState{
+			Call: Call{
+				CallID:    id,
+				From:      pkt.SIPFromUser,
+				To:        pkt.SIPToUser,
+				State:     CallSetup,
+				StartedAt: at.UTC().Format(time.RFC3339),
+			},
+			started: at,
+		}
+		a.dialogs[id] = c
+	}
+	c.Explanation = explanation
+	if pkt.SIPStatusCode != 0 {
+		c.LastCode = pkt.SIPStatusCode
+	}
+
+	switch {
+	case pkt.SIPMethod == "BYE":
+		if c.State == CallEstablished {
+			c.DurationSeconds = at.Sub(c.answered).Seconds()
+		}
+		c.State = CallTerminated
+		c.ended = at
+	case pkt.SIPMethod == "CANCEL":
+		c.State = CallCancelled
+		c.ended = at
+	case pkt.SIPCSeqMethod != "INVITE" || pkt.SIPStatusCode == 0:
+		// ACK, re-INVITE or responses to other methods: no state change.
+	case pkt.SIPStatusCode == 180 || pkt.SIPStatusCode == 183:
+		if c.State == CallSetup {
+			c.State = CallRinging
+			c.SetupSeconds = at.Sub(c.started).Seconds()
+			a.setupTime.Observe(c.SetupSeconds)
+		}
+	case pkt.SIPStatusCode >= 200 && pkt.SIPStatusCode < 300:
+		if c.State == CallSetup || c.State == CallRinging {
+			if c.State == CallSetup {
+				c.SetupSeconds = at.Sub(c.started).Seconds()
+				a.setupTime.Observe(c.SetupSeconds)
+			}
+			c.State = CallEstablished
+			c.answered = at
+		}
+	case pkt.SIPStatusCode == 401 || pkt.SIPStatusCode == 407 || pkt.SIPStatusCode < 200:
+		// Challenge (the UE re-sends the INVITE) or 100 Trying.
+	case pkt.SIPStatusCode == 487:
+		c.State = CallCancelled
+		c.ended = at
+	default:
+		if c.State == CallSetup || c.State == CallRinging {
+			c.State = CallFailed
+			c.ended = at
+		}
+	}
+}
+
+func (a *Analyzer) addEvent(pkt capture.Packet, srcNF, dstNF, explanation string) {
+	msg := pkt.SIPMethod
+	if msg == "" {
+		msg = fmt.Sprintf("%d (%s)", pkt.SIPStatusCode, pkt.SIPCSeqMethod)
+	}
+	user := pkt.SIPFromUser
+	if pkt.SIPMethod == "REGISTER" || pkt.SIPCSeqMethod == "REGISTER" {
+		user = pkt.SIPToUser
+	}
+	a.events = append(a.events, Event{
+		Time:        pkt.Timestamp.UTC().Format(time.RFC3339Nano),
+		SrcNF:       srcNF,
+		DstNF:       dstNF,
+		Message:     msg,
+		User:        user,
+		CallID:      pkt.SIPCallID,
+		Explanation: explanation,
+	})
+	if len(a.events) > maxEvents {
+		a.events = a.events[len(a.events)-maxEvents:]
+	}
+}
+
+// updateGauges refreshes the gauges and drops calls that ended long ago.
+func (a *Analyzer) updateGauges(now time.Time) {
+	registered := 0
+	for _, r := range a.regs {
+		if r.State == RegRegistered {
+			registered++
+		}
+	}
+	a.registered.Set(float64(registered))
+
+	byState := map[string]int{
+		CallSetup: 0, CallRinging: 0, CallEstablished: 0,
+		CallTerminated: 0, CallCancelled: 0, CallFailed: 0,
+	}
+	for id, c := range a.dialogs {
+		if !c.ended.IsZero() && now.Sub(c.ended) > callRetention {
+			delete(a.dialogs, id)
+			continue
+		}
+		byState[c.State]++
+	}
+	for state, n := range byState {
+		a.calls.WithLabelValues(state).Set(float64(n))
+	}
+}
+
+// Summary returns registrations (by user), calls (newest first) and the most
+// recent SIP messages (newest first).
+func (a *Analyzer) Summary() Summary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := Summary{
+		Registrations: make([]Registration, 0, len(a.regs)),
+		Calls:         make([]Call, 0, len(a.dialogs)),
+		Recent:        make([]Event, 0, len(a.events)),
+	}
+	for _, r := range a.regs {
+		s.Registrations = append(s.Registrations, r.Registration)
+	}
+	sort.Slice(s.Registrations, func(i, j int) bool { return s.Registrations[i].User < s.Registrations[j].User })
+
+	for _, c := range a.dialogs {
+		s.Calls = append(s.Calls, c.Call)
+	}
+	sort.Slice(s.Calls, func(i, j int) bool { return s.Calls[i].StartedAt > s.Calls[j].StartedAt })
+
+	for i := len(a.events) - 1; i >= 0; i-- {
+		s.Recent = append(s.Recent, a.events[i])
+	}
+	return s
+}
@@ -0,0 +1,82 @@
+package ims
+
+import "fmt"
+
+// IMS call-session control functions as used in the om.nf label of IMS
+// containers (om.domain "ims"). PyHSS is labelled om.nf "pyhss".
+const (
+	NFPCSCF = "pcscf"
+	NFICSCF = "icscf"
+	NFSCSCF = "scscf"
+)
+
+// Spec lists the specifications behind the SIP explanations.
+const Spec = "IETF RFC 3261 (SIP), 3GPP TS 24.229 (IMS SIP profile)"
+
+// requestExplanations describes what each SIP request does in a VoLTE/VoNR
+// lab (TS 24.229, RFC 3261).
+var requestExplanations = map[string]string{
+	"REGISTER":  "The UE registers its IMS public identity. P-CSCF → I-CSCF (asks the HSS over Cx which S-CSCF serves the user) → S-CSCF.",
+	"INVITE":    "Call setup: the caller's UE offers an SDP session (codecs, media ports). It travels P-CSCF → S-CSCF of the caller → S-CSCF/P-CSCF of the callee.",
+	"ACK":       "The caller confirms the 200 OK of the INVITE; the call is now established and RTP media flows.",
+	"BYE":       "One party hangs up; the dialog and its dedicated voice bearer (QCI 1 / 5QI 1) are released.",
+	"CANCEL":    "The caller abandons the call before it is answered.",
+	"PRACK":     "Reliable acknowledgement of a provisional response (183/180) — used for QoS preconditions in VoLTE.",
+	"UPDATE":    "Mid-call session update, typically to confirm that QoS preconditions (the dedicated bearer) are met.",
+	"SUBSCRIBE": "The UE subscribes to its registration state (reg event package) after registering.",
+	"NOTIFY":    "The S-CSCF reports registration state to the subscribed UE/P-CSCF.",
+	"MESSAGE":   "SMS over IMS (SMS over IP).",
+	"OPTIONS":   "Keep-alive / capability query; the O&M module uses it to health-check the CSCFs.",
+}
+
+// responseExplanations describes notable SIP responses, keyed by status code.
+var responseExplanations = map[int]string{
+	100: "Trying — the next hop received the request and is processing it.",
+	180: "Ringing — the callee's UE is alerting the user.",
+	183: "Session Progress — early media / QoS preconditions; the PCRF/PCF is setting up the dedicated voice bearer.",
+	200: "OK — the request succeeded.",
+	401: "Unauthorized — normal IMS AKA challenge: the S-CSCF fetched an authentication vector from the HSS (Cx MAR/MAA) and asks the UE to answer it.",
+	403: "Forbidden — the user is not allowed; often the IMPI/IMPU is not provisioned in PyHSS or the AKA response was wrong.",
+	404: "Not Found — the called identity is unknown or not registered (check the callee's MSISDN and registration).",
+	407: "Proxy Authentication Required — challenge from a proxy; answered like a 401.",
+	408: "Request Timeout — the next hop did not answer; a CSCF or the callee's UE is down.",
+	480: "Temporarily Unavailable — the callee is known but not reachable right now (not registered).",
+	486: "Busy Here — the callee is busy.",
+	487: "Request Terminated — the INVITE was cancelled by the caller.",
+	488: "Not Acceptable Here — SDP negotiation failed: no common codec between the UEs.",
+	500: "Server Internal Error — a CSCF failed to process the request; check its logs.",
+	503: "Service Unavailable — a CSCF or PyHSS is overloaded or unreachable (e.g. Diameter Cx down).",
+	504: "Server Time-out — an upstream server (HSS, other CSCF) did not answer in time.",
+}
+
+// explain returns the educational description of one SIP message.
+func explain(method string, code int, cseqMethod string) string {
+	if method != "" {
+		if e, ok := requestExplanations[method]; ok {
+			return e
+		}
+		return fmt.Sprintf("SIP %s request.", method)
+	}
+	if code == 200 {
+		switch cseqMethod {
+		case "REGISTER":
+			return "OK — registration accepted by the S-CSCF; the user can now make and receive calls."
+		case "INVITE":
+			return "OK — the callee answered; the caller must ACK and media starts."
+		case "BYE":
+			return "OK — the call is released."
+		}
+	}
+	if e, ok := responseExplanations[code]; ok {
+		return e
+	}
+	switch {
+	case code < 200:
+		return fmt.Sprintf("%d — provisional response to %s.", code, cseqMethod)
+	case code < 300:
+		return fmt.Sprintf("%d — %s succeeded.", code, cseqMethod)
+	case code < 400:
+		return fmt.Sprintf("%d — %s redirected.", code, cseqMethod)
+	}
+	return fmt.Sprintf("%d — %s failed.", code, cseqMethod)
+}
@@ -0,0 +1,257 @@
+package ims
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const networkName = "docker_open5gs_default"
+
+// sipPorts are the SIP listening ports of the Kamailio CSCFs in
+// docker_open5gs, keyed by om.nf.
+var sipPorts = map[string]int{
+	NFPCSCF: 5060,
+	NFICSCF: 4060,
+	NFSCSCF: 6060,
+}
+
+// ProbeResult is the last SIP health check of one CSCF container.
+type ProbeResult struct {
+	Container  string  `json:"container"`
+	NF         string  `json:"nf"`
+	Target     string  `json:"target"`
+	Up         bool    `json:"up"`
+	StatusCode int     `json:"status_code,omitempty"`
+	RTTSeconds float64 `json:"rtt_seconds,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	CheckedAt  string  `json:"checked_at"`
+}
+
+// Prober health-checks the SIP ports of running CSCF containers with SIP
+// OPTIONS over UDP. Any SIP response — even an error status — proves the
+// SIP stack is listening.
+type Prober struct {
+	docker   *dockerclient.Client
+	snap     *collector.Snapshot
+	interval time.Duration
+	timeout  time.Duration
+
+	up  *prometheus.GaugeVec
+	rtt *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	results map[string]ProbeResult // keyed by container name
+	probed  time.Time              // end of the last complete probe round
+}
+
+// NewProber registers the probe metrics on reg. timeout bounds each OPTIONS
+// round trip.
+func NewProber(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, interval, timeout time.Duration) *Prober {
+	p := &Prober{
+		docker:   docker,
+		snap:     snap,
+		interval: interval,
+		timeout:  timeout,
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ims", Name: "sip_up",
+			Help: "1 if the CSCF answered the last SIP OPTIONS probe, 0 otherwise.",
+		}, []string{"container", "nf"}),
+		rtt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ims", Name: "sip_options_rtt_seconds",
+			Help: "Round-trip time of the last successful SIP OPTIONS probe.",
+		}, []string{"container", "nf"}),
+		results: make(map[string]ProbeResult),
+	}
+	reg.MustRegister(p.up, p.rtt)
+	return p
+}
+
+// Run probes every interval until ctx is cancelled. Labs without IMS
+// containers cost one snapshot scan per interval.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probeAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Results returns the last probe of every CSCF, sorted by container name.
+func (p *Prober) Results() []ProbeResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]ProbeResult, 0, len(p.results))
+	for _, r := range p.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+// Freshness returns when the om_ims_* metrics were last refreshed by a
+// complete probe round, for exporter.Ages. Without CSCF containers there
+// are no such metrics and it returns nil.
+func (p *Prober) Freshness() map[string]time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.results) == 0 || p.probed.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{
+		"om_ims_sip_up":                  p.probed,
+		"om_ims_sip_options_rtt_seconds": p.probed,
+	}
+}
+
+func (p *Prober) probeAll(ctx context.Context) {
+	targets := make(map[string]string) // container name → nf
+	for name, cd := range p.snap.All() {
+		if cd.Domain != collector.DomainIMS || cd.State != "running" {
+			continue
+		}
+		if _, ok := sipPorts[cd.NF]; ok {
+			targets[name] = cd.NF
+		}
+	}
+
+	p.mu.Lock()
+	for name, r := range p.results {
+		if _, ok := targets[name]; !ok {
+			delete(p.results, name)
+			p.up.DeleteLabelValues(name, r.NF)
+			p.rtt.DeleteLabelValues(name, r.NF)
+		}
+	}
+	p.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	ipToName, err := p.docker.GetNetworkContainerIPs(ctx, networkName)
+	if err != nil {
+		log.Printf("⚠️  IMS probe: %v", err)
+		return
+	}
+	nameToIP := make(map[string]string, len(ipToName))
+	for ip, name := range ipToName {
+		nameToIP[name] = ip
+	}
+
+	for name, nf := range targets {
+		r := ProbeResult{Container: name, NF: nf, CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+		ip, ok := nameToIP[name]
+		if !ok {
+			r.Error = "no IP on " + networkName
+		} else {
+			r.Target = net.JoinHostPort(ip, strconv.Itoa(sipPorts[nf]))
+			code, rtt, err := sipOptions(ctx, r.Target, p.timeout)
+			if err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Up, r.StatusCode, r.RTTSeconds = true, code, rtt.Seconds()
+			}
+		}
+		p.record(r)
+	}
+
+	p.mu.Lock()
+	p.probed = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *Prober) record(r ProbeResult) {
+	p.mu.Lock()
+	prev, seen := p.results[r.Container]
+	p.results[r.Container] = r
+	p.mu.Unlock()
+
+	if seen && prev.Up && !r.Up {
+		log.Printf("⚠️  IMS: %s (%s) stopped answering SIP OPTIONS: %s", r.Container, r.NF, r.Error)
+	}
+	if r.Up {
+		p.up.WithLabelValues(r.Container, r.NF).Set(1)
+		p.rtt.WithLabelValues(r.Container, r.NF).Set(r.RTTSeconds)
+	} else {
+		p.up.WithLabelValues(r.Container, r.NF).Set(0)
+	}
+}
+
+// sipOptions sends one SIP OPTIONS request over UDP and returns the status
+// code of the first response.
+func sipOptions(ctx context.Context, target string, timeout time.Duration) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", target)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	local := conn.LocalAddr().String()
+	localHost, _, _ := net.SplitHostPort(local)
+	req := strings.Join([]string{
+		"OPTIONS sip:" + target + " SIP/2.0",
+		"Via: SIP/2.0/UDP " + local + ";branch=z9hG4bK" + randomToken() + ";rport",
+		"Max-Forwards: 70",
+		"From: <sip:om-module@" + localHost + ">;tag=" + randomToken(),
+		"To: <sip:" + target + ">",
+		"Call-ID: " + randomToken() + "@om-module",
+		"CSeq: 1 OPTIONS",
+		"User-Agent: om-module",
+		"Content-Length: 0",
+		"", "",
+	}, "\r\n")
+
+	start := time.Now()
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return 0, 0, err
+	}
+	buf := make([]byte, 2048)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	rtt := time.Since(start)
+
+	// Status line: "SIP/2.0 200 OK"
+	line, _, _ := strings.Cut(string(buf[:n]), "\r\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "SIP/") {
+		return 0, rtt, fmt.Errorf("not a SIP response: %q", line)
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, rtt, fmt.Errorf("bad status line %q", line)
+	}
+	return code, rtt, nil
+}
+
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,367 @@
+// Package incident gathers the log and metric evidence of an incident
+// review: for a time window, the Open5GS error lines in Loki grouped into
+// the most frequent messages per NF, and the Prometheus signals (container
+// CPU and memory, registration and authentication failures, connected
+// gNBs/eNBs, PFCP peers) that moved away from their level in the window
+// just before. The API adds what the module itself saw (health transitions,
+// restarts) and renders the review.
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/logtime"
+	"github.com/Parz1val02/OM_module/internal/redact"
+)
+
+// Options configure the Querier. An empty URL leaves that part of the
+// review out.
+type Options struct {
+	LokiURL           string
+	LokiTimeout       time.Duration
+	PrometheusURL     string
+	PrometheusTimeout time.Duration
+	// Stamps dates error lines by their Open5GS time stamp; nil keeps the
+	// time Loki received them, minutes late for a replayed or batched log.
+	Stamps *logtime.Inferrer
+	// Redact is applied to the error lines before they are grouped; nil
+	// keeps them as logged.
+	Redact *redact.Redactor
+}
+
+// Querier fetches review evidence from Loki and Prometheus.
+type Querier struct {
+	opts   Options
+	client *http.Client
+}
+
+// New returns a Querier.
+func New(opts Options) *Querier {
+	return &Querier{opts: opts, client: httpclient.New("incident", 0)}
+}
+
+// HasLoki reports whether error lines can be queried.
+func (q *Querier) HasLoki() bool { return q != nil && q.opts.LokiURL != "" }
+
+// HasPrometheus reports whether metric anomalies can be queried.
+func (q *Querier) HasPrometheus() bool { return q != nil && q.opts.PrometheusURL != "" }
+
+// --- Error lines -----------------------------------------------------------
+
+// ErrorSelector selects the Open5GS error and fatal lines; levels are
+// lower-cased by the log pipeline.
+const ErrorSelector = `{job="open5gs", level=~"error|fatal"}`
+
+// maxErrorLines bounds the lines read from Loki per review.
+const maxErrorLines = 5000
+
+// ErrorMessage is one kind of error line: lines that differ only in
+// numbers, addresses and identifiers count as the same message.
+type ErrorMessage struct {
+	Message string `json:"message"` // with <n>, <ip>, <hex> placeholders
+	Example string `json:"example"` // first line as logged, without the header
+	Count   int    `json:"count"`
+	First   string `json:"first"`
+	Last    string `json:"last"`
+}
+
+// ComponentErrors are the error lines of one NF.
+type ComponentErrors struct {
+	Generation string         `json:"generation,omitempty"`
+	NF         string         `json:"nf"`
+	Lines      int            `json:"lines"`
+	Fatal      int            `json:"fatal"`
+	Top        []ErrorMessage `json:"top"`
+}
+
+// ErrorLines returns the error lines logged between from and to, the top
+// messages of each NF, NFs with the most lines first. truncated is true
+// when Loki had more lines than a review reads.
+func (q *Querier) ErrorLines(ctx context.Context, from, to time.Time, top int) (_ []ComponentErrors, truncated bool, err error) {
+	v := url.Values{}
+	v.Set("query", ErrorSelector)
+	v.Set("start", strconv.FormatInt(from.UnixNano(), 10))
+	v.Set("end", strconv.FormatInt(to.UnixNano(), 10))
+	v.Set("limit", strconv.Itoa(maxErrorLines))
+	v.Set("direction", "forward")
+	target := strings.TrimRight(q.opts.LokiURL, "/") + "/loki/api/v1/query_range?" + v.Encode()
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := q.get(ctx, target, q.opts.LokiTimeout, "loki", &body); err != nil {
+		return nil, false, err
+	}
+
+	type nfKey struct{ generation, nf string }
+	byNF := make(map[nfKey]*ComponentErrors)
+	messages := make(map[nfKey]map[string]*ErrorMessage)
+	total := 0
+	for _, stream := range body.Data.Result {
+		key := nfKey{stream.Stream["generation"], stream.Stream["nf"]}
+		if key.nf == "" {
+			continue
+		}
+		c := byNF[key]
+		if c == nil {
+			c = &ComponentErrors{Generation: key.generation, NF: key.nf, Top: []ErrorMessage{}}
+			byNF[key] = c
+			messages[key] = make(map[string]*ErrorMessage)
+		}
+		for _, entry := range stream.Values {
+			total++
+			ns, _ := strconv.ParseInt(entry[0], 10, 64)
+			at := q.lineTime(entry[1], time.Unix(0, ns)).UTC().Format(time.RFC3339)
+			c.Lines++
+			if stream.Stream["level"] == "fatal" {
+				c.Fatal++
+			}
+			msg := q.opts.Redact.Line(stripHeader(entry[1]))
+			sig := Signature(msg)
+			m := messages[key][sig]
+			if m == nil {
+				m = &ErrorMessage{Message: sig, Example: msg, First: at}
+				messages[key][sig] = m
+			}
+			m.Count++
+			if at < m.First {
+				m.First = at
+			}
+			if at > m.Last {
+				m.Last = at
+			}
+		}
+	}
+
+	out := make([]ComponentErrors, 0, len(byNF))
+	for key, c := range byNF {
+		for _, m := range messages[key] {
+			c.Top = append(c.Top, *m)
+		}
+		sort.Slice(c.Top, func(i, j int) bool {
+			if c.Top[i].Count != c.Top[j].Count {
+				return c.Top[i].Count > c.Top[j].Count
+			}
+			return c.Top[i].First < c.Top[j].First
+		})
+		if len(c.Top) > top {
+			c.Top = c.Top[:top]
+		}
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Lines != out[j].Lines {
+			return out[i].Lines > out[j].Lines
+		}
+		return out[i].NF < out[j].NF
+	})
+	return out, total >= maxErrorLines, nil
+}
+
+// lineTime returns when line was logged: its header time stamp, dated from
+// received, or received itself when the stamp is missing or unparseable.
+func (q *Querier) lineTime(line string, received time.Time) time.Time {
+	if q.opts.Stamps == nil {
+		return received
+	}
+	m := logStamp.FindStringSubmatch(ansiCodes.ReplaceAllString(line, ""))
+	if m == nil {
+		return received
+	}
+	t, err := q.opts.Stamps.Infer(m[1], received)
+	if err != nil {
+		return received
+	}
+	return t
+}
+
+var (
+	ansiCodes   = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	logStamp    = regexp.MustCompile(`^\s*(\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d+):`)
+	logHeader   = regexp.MustCompile(`^\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d+:\s+\[\w+\]\s+\w+:\s+`)
+	sourceLoc   = regexp.MustCompile(`\s*\([^()]*\.c:\d+\)$`)
+	ipAddresses = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`)
+	hexNumbers  = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+	numbers     = regexp.MustCompile(`\d+`)
+)
+
+// stripHeader removes the colour codes and the "MM/DD hh:mm:ss.mmm: [nf]
+// LEVEL: " header of an Open5GS log line.
+func stripHeader(line string) string {
+	return logHeader.ReplaceAllString(strings.TrimSpace(ansiCodes.ReplaceAllString(line, "")), "")
+}
+
+// Signature reduces an Open5GS log message to what identifies its kind:
+// the source location is dropped and addresses, hex values and numbers
+// (IMSIs, ids, causes, counts) become placeholders.
+func Signature(msg string) string {
+	msg = sourceLoc.ReplaceAllString(msg, "")
+	msg = ipAddresses.ReplaceAllString(msg, "<ip>")
+	msg = hexNumbers.ReplaceAllString(msg, "<hex>")
+	return numbers.ReplaceAllString(msg, "<n>")
+}
+
+// --- Metric anomalies ------------------------------------------------------
+
+// Signal is a Prometheus expression, one series per container, watched
+// for anomalies.
+type Signal struct {
+	Name  string
+	Title string
+	Expr  string
+	Unit  string // percent | bytes | count
+	// Up signals are anomalous when their maximum in the window reaches
+	// Factor times their mean in the window before (and at least Floor);
+	// down signals when their minimum falls to the previous mean divided
+	// by Factor, from a previous mean of at least Floor.
+	Up     bool
+	Factor float64
+	Floor  float64
+}
+
+// Signals are the signals every review checks.
+var Signals = []Signal{
+	{Name: "cpu", Title: "CPU usage", Expr: `container_cpu_usage_percent`, Unit: "percent", Up: true, Factor: 2, Floor: 20},
+	{Name: "memory", Title: "Memory usage", Expr: `container_memory_usage_bytes`, Unit: "bytes", Up: true, Factor: 1.5, Floor: 64 << 20},
+	{Name: "registration_failures", Title: "Registration failures per minute", Expr: `sum by (container) (increase(fivegs_amffunction_rm_reginitfail[1m]))`, Unit: "count", Up: true, Factor: 2, Floor: 1},
+	{Name: "auth_failures", Title: "Authentication failures per minute", Expr: `sum by (container) (increase(fivegs_amffunction_amf_authfail[1m]))`, Unit: "count", Up: true, Factor: 2, Floor: 1},
+	{Name: "gnbs", Title: "Connected gNBs", Expr: `sum by (container) (gnb)`, Unit: "count", Factor: 2, Floor: 1},
+	{Name: "enbs", Title: "Connected eNBs", Expr: `sum by (container) (enb)`, Unit: "count", Factor: 2, Floor: 1},
+	{Name: "pfcp_peers", Title: "Active PFCP peers", Expr: `sum by (container) (pfcp_peers_active)`, Unit: "count", Factor: 2, Floor: 1},
+}
+
+// anomalyStep is the resolution the windows are evaluated at, the
+// Prometheus scrape interval.
+const anomalyStep = 15 * time.Second
+
+// Anomaly is one series that moved away from its previous level.
+type Anomaly struct {
+	Signal    string  `json:"signal"`
+	Title     string  `json:"title"`
+	Container string  `json:"container"`
+	Direction string  `json:"direction"` // up | down
+	Unit      string  `json:"unit"`
+	Before    float64 `json:"before"` // mean over the window before
+	Value     float64 `json:"value"`  // maximum (up) or minimum (down) in the window
+}
+
+// Anomalies compares every Signal in [from, to] with the window of the same
+// length just before it.
+func (q *Querier) Anomalies(ctx context.Context, from, to time.Time) ([]Anomaly, error) {
+	d := to.Sub(from).Round(time.Second)
+	if d < anomalyStep {
+		d = anomalyStep
+	}
+	rng := fmt.Sprintf("[%ds:%ds]", int(d.Seconds()), int(anomalyStep.Seconds()))
+
+	out := []Anomaly{}
+	for _, s := range Signals {
+		during, extreme := "max_over_time", "up"
+		if !s.Up {
+			during, extreme = "min_over_time", "down"
+		}
+		before, err := q.instant(ctx, "avg_over_time(("+s.Expr+")"+rng+")", from)
+		if err != nil {
+			return nil, err
+		}
+		now, err := q.instant(ctx, during+"(("+s.Expr+")"+rng+")", to)
+		if err != nil {
+			return nil, err
+		}
+		for container, v := range now {
+			prev, seen := before[container]
+			var anomalous bool
+			if s.Up {
+				anomalous = v >= s.Floor && v >= s.Factor*prev
+			} else {
+				anomalous = seen && prev >= s.Floor && v <= prev/s.Factor
+			}
+			if anomalous {
+				out = append(out, Anomaly{
+					Signal: s.Name, Title: s.Title, Container: container, Direction: extreme,
+					Unit: s.Unit, Before: round(prev), Value: round(v),
+				})
+			}
+		}
+	}
+	order := make(map[string]int, len(Signals))
+	for i, s := range Signals {
+		order[s.Name] = i
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Signal != out[j].Signal {
+			return order[out[i].Signal] < order[out[j].Signal]
+		}
+		return out[i].Container < out[j].Container
+	})
+	return out, nil
+}
+
+// instant evaluates expr at t and returns the value of each series by its
+// container label.
+func (q *Querier) instant(ctx context.Context, expr string, t time.Time) (map[string]float64, error) {
+	v := url.Values{}
+	v.Set("query", expr)
+	v.Set("time", strconv.FormatInt(t.Unix(), 10))
+	target := strings.TrimRight(q.opts.PrometheusURL, "/") + "/api/v1/query?" + v.Encode()
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := q.get(ctx, target, q.opts.PrometheusTimeout, "prometheus", &body); err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(body.Data.Result))
+	for _, r := range body.Data.Result {
+		s, _ := r.Value[1].(string)
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			continue
+		}
+		out[r.Metric["container"]] = f
+	}
+	return out, nil
+}
+
+func (q *Querier) get(ctx context.Context, target string, timeout time.Duration, what string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s query: unexpected status %s", what, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,363 @@
+// Package insights turns the metric anomalies of the incident review into
+// learning cards while the lab runs. Every interval the signals of
+// incident.Signals are compared with the window before; when one starts to
+// move away from its level (a surge of authentication failures, gNBs
+// disconnecting) a card explains what the pattern usually means, which
+// specification section covers the procedure involved and which queries to
+// run to investigate it, and a Grafana annotation marks the moment on the
+// dashboards.
+package insights
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/incident"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxCards is how many cards Status keeps.
+const maxCards = 50
+
+// Query is a query suggested to investigate a card, ready to paste in
+// Grafana Explore.
+type Query struct {
+	Datasource string `json:"datasource"` // prometheus | loki
+	Title      string `json:"title"`
+	Expr       string `json:"expr"`
+}
+
+// Card is the learning card of one anomaly.
+type Card struct {
+	Time      string  `json:"time"`
+	Signal    string  `json:"signal"`
+	Title     string  `json:"title"`
+	Container string  `json:"container"`
+	Direction string  `json:"direction"` // up | down
+	Unit      string  `json:"unit"`
+	Before    float64 `json:"before"` // mean over the window before
+	Value     float64 `json:"value"`  // latest maximum (up) or minimum (down)
+	// Active is true while the signal is still anomalous; Resolved is when
+	// it stopped being so.
+	Active   bool   `json:"active"`
+	Resolved string `json:"resolved,omitempty"`
+
+	Meaning   string  `json:"meaning,omitempty"`
+	Hint      string  `json:"hint,omitempty"`
+	Spec      string  `json:"spec,omitempty"`
+	Queries   []Query `json:"queries"`
+	Dashboard string  `json:"dashboard,omitempty"` // Grafana dashboard uid
+	// Course is the institution's own material for the signal, added by
+	// the API from the EDUCATIONAL_PROVIDERS.
+	Course []educontent.Item `json:"course,omitempty"`
+	// NotDeployed is set by the API on the cards of containers the lab no
+	// longer has.
+	NotDeployed bool `json:"not_in_deployment,omitempty"`
+}
+
+// Status is the API view of the engine.
+type Status struct {
+	Interval  string `json:"interval"`
+	Window    string `json:"window"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Active    int    `json:"active"`
+	// Paused is true while the insights feature flag is off; the cards
+	// are those of the last check before.
+	Paused bool   `json:"paused,omitempty"`
+	Cards  []Card `json:"cards"` // newest first
+}
+
+// lesson is what a card says about a signal. "$container" in the queries
+// is replaced by the container of the anomaly.
+type lesson struct {
+	meaning   string
+	hint      string
+	spec      string
+	queries   []Query
+	dashboard string
+}
+
+// lessons are keyed by incident.Signal name; the direction is the one the
+// signal is watched in.
+var lessons = map[string]lesson{
+	"cpu": {
+		meaning: "The container uses at least twice the CPU it used a few minutes ago. In a lab NF this is rarely load from real traffic: " +
+			"it usually follows a retry loop (a peer that keeps rejecting a procedure) or a burst of UEs attaching at once.",
+		hint: "Look for the same error repeating in the container's logs, and check whether a UE simulator was just scaled up or restarted.",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "CPU of the container", Expr: `container_cpu_usage_percent{container="$container"}`},
+			{Datasource: "loki", Title: "Error lines of the core per NF", Expr: `sum by (nf) (count_over_time({job="open5gs", level=~"error|fatal"}[1m]))`},
+		},
+		dashboard: "exporters",
+	},
+	"memory": {
+		meaning: "The container's memory grew by half over its recent level. Open5GS allocates its UE and session pools at start, " +
+			"so steady growth afterwards points at contexts that are created and never released.",
+		hint: "Compare the UE and session counts with the number of UEs actually attached: contexts left behind by failed releases keep their memory.",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Memory of the container", Expr: `container_memory_usage_bytes{container="$container"}`},
+			{Datasource: "loki", Title: "Release procedures", Expr: `{job="open5gs", procedure="release"}`},
+		},
+		dashboard: "exporters",
+	},
+	"registration_failures": {
+		meaning: "The AMF rejects initial registrations at least twice as often as before. Every reject carries a 5GMM cause that says why: " +
+			"unknown subscriber, no allowed slice, PLMN not allowed, or a failed authentication.",
+		hint: "Check the cause of the rejects at /causes: most bursts in the lab come from UEs whose IMSI, MCC/MNC or S-NSSAI do not match the subscriber database or the AMF configuration.",
+		spec: "3GPP TS 24.501 §5.5.1.2 (registration procedure for initial registration)",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Registration attempts", Expr: `increase(fivegs_amffunction_rm_reginitreq[5m])`},
+			{Datasource: "prometheus", Title: "Registration failures", Expr: `increase(fivegs_amffunction_rm_reginitfail{container="$container"}[5m])`},
+			{Datasource: "loki", Title: "Registration rejects in the AMF", Expr: `{job="open5gs", nf="amf", procedure="error"} |~ "(?i)registration reject|cannot find"`},
+		},
+		dashboard: "5g-core",
+	},
+	"auth_failures": {
+		meaning: "Authentication failures at the AMF at least doubled. In 5G AKA the UE checks the network's MAC and sequence number before answering: " +
+			"a \"MAC failure\" means UE and core do not share the same K/OPc, a \"synch failure\" that their sequence numbers drifted apart and are being resynchronised.",
+		hint: "Compare the K, OPc and AMF of the failing UEs in the UE simulator with the subscriber database (/api/subscribers/drift flags re-keyed subscribers).",
+		spec: "3GPP TS 33.501 §6.1.3.2 (5G AKA) and TS 24.501 §5.4.1.3 (authentication procedure)",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Authentication failures", Expr: `increase(fivegs_amffunction_amf_authfail{container="$container"}[5m])`},
+			{Datasource: "loki", Title: "Authentication failures in the AMF", Expr: `{job="open5gs", nf="amf"} |= "Authentication failure"`},
+			{Datasource: "loki", Title: "IMSIs involved", Expr: `sum by (imsi) (count_over_time({job="open5gs", nf="amf", procedure="error"} |= "Authentication failure" [5m]))`},
+		},
+		dashboard: "nas-security",
+	},
+	"gnbs": {
+		meaning: "Half or more of the gNBs connected to the AMF went away. The NG Setup over SCTP has to be redone by every gNB that lost its N2 association, " +
+			"and every UE behind it loses service.",
+		hint: "Check whether the gNB containers restarted, and that the AMF still listens on the NGAP address the gNBs are configured with.",
+		spec: "3GPP TS 38.413 §8.7.1 (NG Setup)",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Connected gNBs", Expr: `gnb{container="$container"}`},
+			{Datasource: "loki", Title: "gNB associations in the AMF", Expr: `{job="open5gs", nf="amf"} |~ "(?i)gnb|ng.?setup|sctp"`},
+		},
+		dashboard: "5g-core",
+	},
+	"enbs": {
+		meaning: "Half or more of the eNBs connected to the MME went away. Each eNB has to redo the S1 Setup over SCTP, and its UEs lose service until they attach again.",
+		hint:    "Check whether the eNB containers restarted, and that the MME still listens on the S1-MME address the eNBs are configured with.",
+		spec:    "3GPP TS 36.413 §8.7.3 (S1 Setup)",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Connected eNBs", Expr: `enb{container="$container"}`},
+			{Datasource: "loki", Title: "eNB associations in the MME", Expr: `{job="open5gs", nf="mme"} |~ "(?i)enb|s1.?setup|sctp"`},
+		},
+		dashboard: "4g-core",
+	},
+	"pfcp_peers": {
+		meaning: "The control plane lost half or more of its PFCP associations with the user plane. Without one the SMF (or SGW-C) cannot create or modify sessions on that UPF (or SGW-U), " +
+			"so new PDU sessions fail while existing ones keep their last rules.",
+		hint: "Check whether the UPF restarted and that the PFCP addresses of both configurations still match; the heartbeat timeout ends the association after a few missed heartbeats.",
+		spec: "3GPP TS 29.244 §6.2.6 (PFCP Association Setup) and §6.2.2 (Heartbeat)",
+		queries: []Query{
+			{Datasource: "prometheus", Title: "Active PFCP peers", Expr: `pfcp_peers_active{container="$container"}`},
+			{Datasource: "loki", Title: "PFCP associations", Expr: `{job="open5gs", nf=~"smf|upf|sgwc|sgwu"} |~ "(?i)pfcp|association|heartbeat"`},
+		},
+		dashboard: "5g-core",
+	},
+}
+
+// Engine watches the anomaly signals every interval and keeps the cards.
+type Engine struct {
+	querier  *incident.Querier
+	grafana  *grafana.Client
+	interval time.Duration
+	window   time.Duration
+	enabled  func() bool // nil checks always
+
+	cards *prometheus.CounterVec
+
+	mu      sync.RWMutex
+	recent  []Card         // newest first
+	active  map[string]int // signal/container → index in recent of its card
+	updated time.Time
+	lastErr string
+}
+
+// New registers om_insights_cards_total on reg. Each check compares the
+// last window with the window before it. grafanaClient may be nil, in which
+// case cards are not annotated.
+func New(reg prometheus.Registerer, querier *incident.Querier, grafanaClient *grafana.Client, interval, window time.Duration) *Engine {
+	e := &Engine{
+		querier:  querier,
+		grafana:  grafanaClient,
+		interval: interval,
+		window:   window,
+		cards: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "insights", Name: "cards_total",
+			Help: "Learning cards generated for metric anomalies, by signal.",
+		}, []string{"signal"}),
+		active: make(map[string]int),
+	}
+	for _, s := range incident.Signals {
+		e.cards.WithLabelValues(s.Name)
+	}
+	reg.MustRegister(e.cards)
+	return e
+}
+
+// SetGate makes the engine check only while enabled reports true. Call it
+// before Run.
+func (e *Engine) SetGate(enabled func() bool) {
+	e.enabled = enabled
+}
+
+func (e *Engine) paused() bool {
+	return e.enabled != nil && !e.enabled()
+}
+
+// Run checks every interval until ctx is cancelled, skipping the checks
+// while the engine is paused.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if !e.paused() {
+			if err := e.check(ctx); err != nil && ctx.Err() == nil {
+				e.mu.Lock()
+				first := e.lastErr == ""
+				e.lastErr = err.Error()
+				e.mu.Unlock()
+				if first {
+					log.Printf("⚠️  Insights: %v", err)
+				}
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the latest cards.
+func (e *Engine) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s := Status{
+		Interval: e.interval.String(),
+		Window:   e.window.String(),
+		Error:    e.lastErr,
+		Active:   len(e.active),
+		Paused:   e.paused(),
+		Cards:    make([]Card, len(e.recent)),
+	}
+	for i, c := range e.recent {
+		c.Queries = append([]Query{}, c.Queries...)
+		s.Cards[i] = c
+	}
+	if !e.updated.IsZero() {
+		s.UpdatedAt = e.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the signals were last checked, for exporter.Ages.
+func (e *Engine) Freshness() map[string]time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.updated.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_insights_cards_total": e.updated}
+}
+
+func (e *Engine) check(ctx context.Context) error {
+	now := time.Now()
+	anomalies, err := e.querier.Anomalies(ctx, now.Add(-e.window), now)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	var created []Card
+	seen := make(map[string]bool, len(anomalies))
+	for _, a := range anomalies {
+		key := a.Signal + "/" + a.Container
+		seen[key] = true
+		if i, ok := e.active[key]; ok {
+			e.recent[i].Value = a.Value
+			continue
+		}
+		c := newCard(a, now)
+		created = append(created, c)
+		e.recent = append([]Card{c}, e.recent...)
+	}
+	if len(e.recent) > maxCards {
+		e.recent = e.recent[:maxCards]
+	}
+	e.active = make(map[string]int)
+	for i := range e.recent {
+		c := &e.recent[i]
+		if !c.Active {
+			continue
+		}
+		key := c.Signal + "/" + c.Container
+		if !seen[key] {
+			c.Active = false
+			c.Resolved = now.UTC().Format(time.RFC3339)
+			continue
+		}
+		e.active[key] = i
+	}
+	e.updated, e.lastErr = now, ""
+	e.mu.Unlock()
+
+	for _, c := range created {
+		e.cards.WithLabelValues(c.Signal).Inc()
+		log.Printf("💡 Insight: %s %s on %s (%g → %g)", c.Title, c.Direction, c.Container, c.Before, c.Value)
+		if e.grafana != nil {
+			e.annotate(ctx, now, c)
+		}
+	}
+	return nil
+}
+
+func newCard(a incident.Anomaly, at time.Time) Card {
+	l := lessons[a.Signal]
+	c := Card{
+		Time:      at.UTC().Format(time.RFC3339),
+		Signal:    a.Signal,
+		Title:     a.Title,
+		Container: a.Container,
+		Direction: a.Direction,
+		Unit:      a.Unit,
+		Before:    a.Before,
+		Value:     a.Value,
+		Active:    true,
+		Meaning:   l.meaning,
+		Hint:      l.hint,
+		Spec:      l.spec,
+		Queries:   make([]Query, 0, len(l.queries)),
+		Dashboard: l.dashboard,
+	}
+	for _, q := range l.queries {
+		q.Expr = strings.ReplaceAll(q.Expr, "$container", a.Container)
+		c.Queries = append(c.Queries, q)
+	}
+	return c
+}
+
+func (e *Engine) annotate(ctx context.Context, at time.Time, c Card) {
+	text := fmt.Sprintf("💡 %s %s on %s: %g → %g", c.Title, c.Direction, c.Container, c.Before, c.Value)
+	if c.Spec != "" {
+		text += " — " + c.Spec
+	}
+	a := grafana.Annotation{
+		Time: at.UnixMilli(),
+		Tags: []string{"insight", c.Signal},
+		Text: text + " (see /educational/insights)",
+	}
+	if _, err := e.grafana.CreateAnnotation(ctx, a); err != nil {
+		log.Printf("⚠️  Insights: Grafana annotation failed: %v", err)
+	}
+}
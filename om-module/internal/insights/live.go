@@ -0,0 +1,246 @@
+package insights
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/kpi"
+)
+
+// Evaluator evaluates live KPIs; *kpi.Prometheus is one.
+type Evaluator interface {
+	Eval(ctx context.Context, d kpi.LiveDefinition, generation string, window time.Duration) (kpi.LiveValue, error)
+}
+
+// Reading is a live KPI of the student's lab put in words: a sentence with
+// its current value and, when the value is on the wrong side of what a
+// healthy lab shows, what that usually means. Unlike the cards, readings
+// are computed when asked for, not on the engine's interval.
+type Reading struct {
+	KPI        string   `json:"kpi"`
+	Generation string   `json:"generation"`
+	Window     string   `json:"window"`
+	Unit       string   `json:"unit"`
+	Value      *float64 `json:"value"` // nil when Prometheus has no data
+	Text       string   `json:"text"`
+	// Alert is true when the value crosses the threshold of the KPI.
+	Alert   bool   `json:"alert"`
+	Meaning string `json:"meaning,omitempty"`
+	Hint    string `json:"hint,omitempty"`
+	Spec    string `json:"spec,omitempty"`
+	Query   string `json:"query"`
+	Error   string `json:"error,omitempty"`
+}
+
+// phrasing is how a reading speaks of one KPI. text gets the value, the
+// words of the generation and the window in words; alert, when set, tells
+// whether the value is a concern. meaning, hint and spec are given on an
+// alert only.
+type phrasing struct {
+	text                func(v float64, w words, window string) string
+	alert               func(v float64) bool
+	meaning, hint, spec string
+}
+
+// words are the names a generation gives the same things.
+type words struct {
+	core, ran, request, session string
+}
+
+var generationWords = map[string]words{
+	"4g": {core: "MME", ran: "eNB", request: "attach request", session: "session"},
+	"5g": {core: "AMF", ran: "gNB", request: "registration request", session: "PDU session"},
+}
+
+// phrasings are keyed by kpi.LiveDefinition name, in the order readings
+// are returned.
+var phrasings = []struct {
+	kpi string
+	phrasing
+}{
+	{"attach_attempts", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("Your %s has processed %s %s in the last %s.", w.core, count(v), plural(v, w.request), window)
+		},
+	}},
+	{"registration_success_rate", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("%s of the initial registrations your AMF received in the last %s were accepted, a failure ratio of %s.", percent(v), window, percent(1-v))
+		},
+		alert:   func(v float64) bool { return v < 0.95 },
+		meaning: "A failure ratio above 5% usually indicates UEs whose subscription does not match the core: an IMSI missing from the subscriber database, a PLMN or S-NSSAI the AMF does not serve, or an authentication that fails.",
+		hint:    "The 5GMM cause of each reject is at /causes; compare the IMSI, MCC/MNC and S-NSSAI of the rejected UEs with the subscriber database and the AMF configuration.",
+		spec:    "3GPP TS 24.501 §5.5.1.2 (registration procedure for initial registration)",
+	}},
+	{"attach_success_rate", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("%s of the %ss the capture saw in the last %s were accepted.", percent(v), w.request, window)
+		},
+		alert:   func(v float64) bool { return v < 0.95 },
+		meaning: "Below 95% some UEs are rejected or get no answer at all. Rejects carry a cause that says why; a request without any answer usually means the core cannot reach one of its own NFs (the subscriber database, the authentication server) in time.",
+		hint:    "Check the causes at /causes and the attempts that timed out in the capture; NFs that restarted recently are the usual suspects.",
+	}},
+	{"attach_latency_p95", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("95%% of the UEs that attached in the last %s were accepted within %s.", window, seconds(v))
+		},
+		alert:   func(v float64) bool { return v > 1 },
+		meaning: "In the lab a UE is usually accepted within tens of milliseconds. Above a second the procedure is waiting on something: retransmissions towards a slow or unreachable NF, or a UE that needs several authentication rounds.",
+		hint:    "Look at the SBI response times per NF pair at /capture/sbi (the Diameter answers of the HSS in 4G) to find the NF that answers late.",
+	}},
+	{"auth_failures", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("Your AMF saw %s authentication %s in the last %s.", count(v), plural(v, "failure"), window)
+		},
+		alert:   func(v float64) bool { return v > 0 },
+		meaning: "An authentication failure means UE and core disagree on the keys: a \"MAC failure\" that they do not share the same K/OPc, a \"synch failure\" that their sequence numbers drifted apart.",
+		hint:    "Compare the K, OPc and AMF of the failing UEs with the subscriber database; /api/subscribers/drift flags re-keyed subscribers.",
+		spec:    "3GPP TS 33.501 §6.1.3.2 (5G AKA)",
+	}},
+	{"active_ues", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("Up to %s %s connected to your %s in the last %s.", count(v), plural2(v, "UE was", "UEs were"), w.core, window)
+		},
+	}},
+	{"ran_nodes", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("%s %s stayed connected to the core for the whole last %s.", count(v), plural(v, w.ran), window)
+		},
+		alert:   func(v float64) bool { return v < 1 },
+		meaning: "Without a radio node connected to the core no UE can attach: every UE reaches the core through the N2 (S1-MME) association of its base station.",
+		hint:    "Check that the RAN containers are running and that the core listens on the NGAP (S1AP) address they are configured with.",
+	}},
+	{"sessions", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("Up to %s %s open in the last %s.", count(v), plural2(v, w.session+" was", w.session+"s were"), window)
+		},
+	}},
+	{"nf_availability", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("The core NF containers were running %s of the last %s.", percent(v), window)
+		},
+		alert:   func(v float64) bool { return v < 0.99 },
+		meaning: "An NF container that stops or restarts drops the contexts it held: the UEs and sessions it served have to attach or be set up again.",
+		hint:    "/topology shows which containers are not running; their logs say why they stopped.",
+	}},
+	{"upf_throughput", phrasing{
+		text: func(v float64, w words, window string) string {
+			return fmt.Sprintf("The user plane carried %s on average over the last %s.", rate(v), window)
+		},
+	}},
+}
+
+// Live evaluates the live KPIs defined for generation over window and
+// phrases them. A KPI Prometheus fails to evaluate is returned with its
+// Error.
+func Live(ctx context.Context, ev Evaluator, generation string, window time.Duration) []Reading {
+	w, ok := generationWords[generation]
+	if !ok {
+		return []Reading{}
+	}
+	type job struct {
+		def kpi.LiveDefinition
+		ph  phrasing
+	}
+	var jobs []job
+	for _, p := range phrasings {
+		d, ok := kpi.LookupLive(p.kpi)
+		if !ok {
+			continue
+		}
+		if _, ok := d.Query(generation, window); ok {
+			jobs = append(jobs, job{d, p.phrasing})
+		}
+	}
+
+	out := make([]Reading, len(jobs))
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = reading(ctx, ev, j.def, j.ph, w, generation, window)
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func reading(ctx context.Context, ev Evaluator, d kpi.LiveDefinition, ph phrasing, w words, generation string, window time.Duration) Reading {
+	v, err := ev.Eval(ctx, d, generation, window)
+	r := Reading{
+		KPI:        d.Name,
+		Generation: generation,
+		Window:     window.String(),
+		Unit:       d.Unit,
+		Value:      v.Value,
+		Query:      v.Query,
+	}
+	switch {
+	case err != nil:
+		r.Error = err.Error()
+		return r
+	case v.Value == nil:
+		r.Text = fmt.Sprintf("Prometheus has no data for %s over the last %s yet.", d.Name, inWords(window))
+		return r
+	}
+	r.Text = ph.text(*v.Value, w, inWords(window))
+	if ph.alert != nil && ph.alert(*v.Value) {
+		r.Alert = true
+		r.Meaning, r.Hint, r.Spec = ph.meaning, ph.hint, ph.spec
+	}
+	return r
+}
+
+// inWords says a window as in "the last hour", "the last 5 minutes".
+func inWords(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "hour"
+	case d == time.Minute:
+		return "minute"
+	case d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + " hours"
+	case d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + " minutes"
+	}
+	return d.String()
+}
+
+func count(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+
+func percent(v float64) string { return strconv.FormatFloat(100*v, 'f', 1, 64) + "%" }
+
+func seconds(v float64) string {
+	if v < 1 {
+		return strconv.FormatFloat(1000*v, 'f', 0, 64) + " ms"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64) + " s"
+}
+
+func rate(v float64) string {
+	for _, u := range []struct {
+		div  float64
+		name string
+	}{{1e9, "GB/s"}, {1e6, "MB/s"}, {1e3, "kB/s"}} {
+		if v >= u.div {
+			return strconv.FormatFloat(v/u.div, 'f', 1, 64) + " " + u.name
+		}
+	}
+	return strconv.FormatFloat(v, 'f', 0, 64) + " B/s"
+}
+
+// plural returns noun, with an "s" unless v is 1.
+func plural(v float64, noun string) string {
+	return plural2(v, noun, noun+"s")
+}
+
+func plural2(v float64, one, many string) string {
+	if count(v) == "1" {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,406 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/exporter"
+	"github.com/Parz1val02/OM_module/internal/logsampling"
+	"github.com/Parz1val02/OM_module/internal/promconfig"
+	"github.com/Parz1val02/OM_module/internal/targets"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.yaml.in/yaml/v2"
+)
+
+// queryTimeout bounds a single Prometheus or Loki request.
+const queryTimeout = 10 * time.Second
+
+// Flags of the test binary: go test -tags integration ./internal/integration -args -keep.
+var (
+	dockerSocket = flag.String("docker", envOr("DOCKER_SOCKET", "/var/run/docker.sock"), "Docker daemon socket")
+	configSource = flag.String("configs", "../../../prometheus/configs", "directory of the Prometheus configuration variants")
+	keep         = flag.Bool("keep", false, "leave the lab running after the test")
+	wait         = flag.Duration("wait", 2*time.Minute, "how long each wait and check may take")
+)
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// checker runs the module's packages against a lab, as main wires them.
+type checker struct {
+	lab      *Lab
+	snap     *collector.Snapshot
+	reg      *prometheus.Registry
+	reporter *logsampling.Reporter
+	client   *http.Client
+}
+
+// TestLab starts the lab and checks, in order:
+//
+//   - the rendered configuration: the lab's external labels and the
+//     monitoring stack jobs are in it, and Prometheus runs it;
+//   - discovery: the collector finds every mock, running, of its kind,
+//     with the metrics address its labels advertise;
+//   - the exporter: container_health_status of every mock, and the
+//     interfaces between them in om_topology_link_info;
+//   - the scrape targets: every target the configuration intends for the
+//     lab's containers is scraped, none unexpectedly;
+//   - the Open5GS metrics of the mock AMF, read back from Prometheus;
+//   - the log sampling summaries, written to the AMF's stream in Loki and
+//     read back.
+//
+// Each check waits up to -wait for its condition. A check that fails
+// stops the ones after it, which depend on it.
+func TestLab(t *testing.T) {
+	l := startLab(t, Options{
+		DockerSocket: *dockerSocket,
+		ConfigSource: *configSource,
+		Keep:         *keep,
+		Timeout:      *wait,
+	})
+	docker, err := dockerclient.New(l.opts.DockerSocket)
+	if err != nil {
+		t.Fatalf("Docker client: %v", err)
+	}
+	defer docker.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	col := collector.New(docker, l.Project, 2*time.Second)
+	c := &checker{lab: l, snap: col.Snapshot(), reg: prometheus.NewRegistry(), client: &http.Client{}}
+	exporter.New(c.snap, l.Project, c.reg)
+	c.reporter = logsampling.New(c.reg, logsampling.Options{
+		PrometheusURL:     l.PrometheusURL,
+		PrometheusTimeout: queryTimeout,
+		LokiURL:           l.LokiURL,
+		LokiTimeout:       queryTimeout,
+		Interval:          5 * time.Second,
+		Rules:             logsampling.Rules(10, 20, 50, 100, 100, 200),
+	})
+	go col.Run(ctx)
+	go c.reporter.Run(ctx)
+
+	checks := []struct {
+		name string
+		fn   func(context.Context) (string, error)
+	}{
+		{"RenderedConfiguration", c.config},
+		{"Discovery", c.discovery},
+		{"ExportedMetrics", c.exported},
+		{"ScrapeTargets", c.targets},
+		{"Open5GSMetricsInPrometheus", c.scraped},
+		{"LokiEntries", c.loki},
+	}
+	for _, ch := range checks {
+		ok := t.Run(ch.name, func(t *testing.T) {
+			detail, err := ch.fn(ctx)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			t.Log(detail)
+		})
+		if !ok {
+			break
+		}
+	}
+}
+
+func (c *checker) config(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(c.lab.prometheusConfig())
+	if err != nil {
+		return "", err
+	}
+	var cfg struct {
+		Global struct {
+			ExternalLabels map[string]string `yaml:"external_labels"`
+		} `yaml:"global"`
+		ScrapeConfigs []struct {
+			JobName string `yaml:"job_name"`
+		} `yaml:"scrape_configs"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", err
+	}
+	for name, value := range c.lab.ExternalLabels {
+		if got := cfg.Global.ExternalLabels[name]; got != value {
+			return "", fmt.Errorf("external label %s is %q, want %q", name, got, value)
+		}
+	}
+	jobs := make(map[string]bool, len(cfg.ScrapeConfigs))
+	for _, j := range cfg.ScrapeConfigs {
+		jobs[j.JobName] = true
+	}
+	want := []string{"docker-services"}
+	for _, t := range promconfig.MonitoringStack {
+		want = append(want, t.NF)
+	}
+	for _, job := range want {
+		if !jobs[job] {
+			return "", fmt.Errorf("no %s job", job)
+		}
+	}
+
+	// Prometheus started with it, so it parsed; it must also be the file
+	// it runs.
+	var loaded struct {
+		Data struct {
+			YAML string `json:"yaml"`
+		} `json:"data"`
+	}
+	if err := c.get(ctx, c.lab.PrometheusURL+"/api/v1/status/config", &loaded); err != nil {
+		return "", err
+	}
+	for name, value := range c.lab.ExternalLabels {
+		if !strings.Contains(loaded.Data.YAML, name+": "+value) {
+			return "", fmt.Errorf("prometheus does not run the rendered configuration: no external label %s=%s", name, value)
+		}
+	}
+	return fmt.Sprintf("%d jobs, external labels %v, loaded by Prometheus", len(cfg.ScrapeConfigs), c.lab.ExternalLabels), nil
+}
+
+func (c *checker) discovery(ctx context.Context) (string, error) {
+	var found []string
+	err := poll(ctx, c.lab.opts.Timeout, func(context.Context) error {
+		all := c.snap.View().All()
+		found = found[:0]
+		for _, m := range Mocks {
+			name := c.lab.Container(m.Service)
+			cd := all[name]
+			switch {
+			case cd == nil:
+				return fmt.Errorf("%w: %s not discovered", errPending, name)
+			case cd.State != "running":
+				return fmt.Errorf("%w: %s is %s", errPending, name, cd.State)
+			case cd.NFKind != m.Kind:
+				return fmt.Errorf("%s classified as %q (from %s), want %q", name, cd.NFKind, cd.NFKindSource, m.Kind)
+			}
+			want := ""
+			if m.Metrics != "" {
+				want = name + ":" + mockPort
+			}
+			if cd.MetricsAddress != want {
+				return fmt.Errorf("%s advertises metrics at %q, want %q", name, cd.MetricsAddress, want)
+			}
+			found = append(found, fmt.Sprintf("%s (%s)", m.Service, cd.NF))
+		}
+		return nil
+	})
+	return strings.Join(found, ", "), err
+}
+
+func (c *checker) exported(context.Context) (string, error) {
+	mfs, err := c.reg.Gather()
+	if err != nil {
+		return "", err
+	}
+	for _, m := range Mocks {
+		name := c.lab.Container(m.Service)
+		v, ok := value(mfs, "container_health_status", map[string]string{"container": name, "compose_project": c.lab.Project, "nf": m.NF})
+		if !ok {
+			return "", fmt.Errorf("no container_health_status for %s", name)
+		}
+		if v != 1 {
+			return "", fmt.Errorf("container_health_status of %s is %g, want 1", name, v)
+		}
+	}
+	links := []collector.Link{
+		{Source: c.lab.Container("amf"), Target: c.lab.Container("smf"), Interface: "N11"},
+		{Source: c.lab.Container("gnb"), Target: c.lab.Container("amf"), Interface: "N2"},
+		{Source: c.lab.Container("gnb"), Target: c.lab.Container("upf"), Interface: "N3"},
+		{Source: c.lab.Container("smf"), Target: c.lab.Container("upf"), Interface: "N4/Sxb"},
+	}
+	for _, ln := range links {
+		if _, ok := value(mfs, "om_topology_link_info", map[string]string{"source": ln.Source, "target": ln.Target, "interface": ln.Interface}); !ok {
+			return "", fmt.Errorf("no om_topology_link_info for %s %s → %s", ln.Interface, ln.Source, ln.Target)
+		}
+	}
+	return fmt.Sprintf("container_health_status of %d containers, %d interfaces", len(Mocks), len(links)), nil
+}
+
+func (c *checker) targets(ctx context.Context) (string, error) {
+	checker := targets.New(targets.Options{PrometheusURL: c.lab.PrometheusURL, ConfigFile: c.lab.prometheusConfig(), Timeout: queryTimeout})
+	want := map[string]string{ // container → job
+		c.lab.Container("prometheus"): "prometheus",
+		c.lab.Container("loki"):       "loki",
+	}
+	for _, m := range Mocks {
+		if m.Metrics != "" {
+			want[c.lab.Container(m.Service)] = "docker-services"
+		}
+	}
+	var counts map[string]int
+	err := poll(ctx, c.lab.opts.Timeout, func(ctx context.Context) error {
+		all, err := checker.Check(ctx, c.snap.View())
+		if err != nil {
+			return fmt.Errorf("%w: %v", errPending, err)
+		}
+		var ours []targets.Target
+		for _, t := range all {
+			if strings.HasPrefix(t.Container, c.lab.Project+"-") {
+				ours = append(ours, t)
+			}
+		}
+		counts = targets.Count(ours)
+		var problems []string
+		seen := make(map[string]bool)
+		for _, t := range ours {
+			seen[t.Container+"/"+t.Job] = true
+			if t.State != targets.StateOK {
+				problems = append(problems, fmt.Sprintf("%s/%s %s (%s)", t.Job, t.Container, t.State, t.Reason))
+			}
+		}
+		for name, job := range want {
+			if !seen[name+"/"+job] {
+				problems = append(problems, fmt.Sprintf("%s/%s not intended", job, name))
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return fmt.Errorf("%w: %s", errPending, strings.Join(problems, "; "))
+		}
+		return nil
+	})
+	return fmt.Sprintf("%d targets ok", counts[targets.StateOK]), err
+}
+
+func (c *checker) scraped(ctx context.Context) (string, error) {
+	amf := c.lab.Container("amf")
+	expr := fmt.Sprintf(`fivegs_amffunction_rm_reginitreq{container=%q}`, amf)
+	var got float64
+	err := poll(ctx, c.lab.opts.Timeout, func(ctx context.Context) error {
+		var body struct {
+			Data struct {
+				Result []struct {
+					Value [2]any `json:"value"`
+				} `json:"result"`
+			} `json:"data"`
+		}
+		if err := c.get(ctx, c.lab.PrometheusURL+"/api/v1/query?"+url.Values{"query": {expr}}.Encode(), &body); err != nil {
+			return err
+		}
+		if len(body.Data.Result) == 0 {
+			return fmt.Errorf("%w: no %s", errPending, expr)
+		}
+		s, _ := body.Data.Result[0].Value[1].(string)
+		got, _ = strconv.ParseFloat(s, 64)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if got != 3 {
+		return "", fmt.Errorf("%s = %g, the mock serves 3", expr, got)
+	}
+	return fmt.Sprintf("%s = %g", expr, got), nil
+}
+
+func (c *checker) loki(ctx context.Context) (string, error) {
+	err := poll(ctx, c.lab.opts.Timeout, func(context.Context) error {
+		st := c.reporter.Status()
+		for _, s := range st.Recent {
+			if s.NF == "amf" && s.Level == "error" {
+				return nil
+			}
+		}
+		if st.Error != "" {
+			return fmt.Errorf("%w: %s", errPending, st.Error)
+		}
+		return fmt.Errorf("%w: no summary of the AMF's suppressed ERROR lines", errPending)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	const query = `{job="open5gs", nf="amf", level="error"} |= "om-module: suppressed"`
+	var line string
+	err = poll(ctx, c.lab.opts.Timeout, func(ctx context.Context) error {
+		end := time.Now()
+		q := url.Values{
+			"query": {query},
+			"start": {strconv.FormatInt(end.Add(-15*time.Minute).UnixNano(), 10)},
+			"end":   {strconv.FormatInt(end.UnixNano(), 10)},
+			"limit": {"1"},
+		}
+		var body struct {
+			Data struct {
+				Result []struct {
+					Values [][2]string `json:"values"`
+				} `json:"result"`
+			} `json:"data"`
+		}
+		if err := c.get(ctx, c.lab.LokiURL+"/loki/api/v1/query_range?"+q.Encode(), &body); err != nil {
+			return err
+		}
+		for _, r := range body.Data.Result {
+			if len(r.Values) > 0 {
+				line = r.Values[0][1]
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: no entry for %s", errPending, query)
+	})
+	return line, err
+}
+
+// get decodes the JSON answer to a GET of target into v. Errors wrap
+// errPending: the lab may still be starting.
+func (c *checker) get(ctx context.Context, target string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPending, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: unexpected status %s", errPending, strings.SplitN(target, "?", 2)[0], resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// value returns the value of the first gauge of family name whose labels
+// include want.
+func value(mfs []*dto.MetricFamily, name string, want map[string]string) (float64, bool) {
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			for k, v := range want {
+				if labels[k] != v {
+					continue metrics
+				}
+			}
+			return m.GetGauge().GetValue(), true
+		}
+	}
+	return 0, false
+}
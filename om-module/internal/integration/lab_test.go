@@ -0,0 +1,372 @@
+//go:build integration
+
+// Package integration checks the module against a throwaway lab in
+// Docker, so that a refactoring of discovery, the exporter, the
+// configuration rendering or the Loki writers can be tested end to end
+// without a core network. The lab is a Compose project of its own
+// (om-it-<random>) on its own network:
+//
+//   - mock Open5GS NFs: busybox httpd serving a fixed Prometheus exposition
+//     on :9091 with the om.* and prometheus.* labels of the real NFs, and a
+//     mock log pipeline serving the om_logging_lines_* counters, which grow
+//     every second;
+//   - Prometheus, running the configuration the module renders from
+//     prometheus/configs/prometheus.yml with Docker service discovery on the
+//     host's socket, as in services.yaml;
+//   - Loki, with its default configuration.
+//
+// The tests then run the module's own packages against it. They are only
+// built with the integration build tag (`make integration`, or
+// go test -tags integration ./internal/integration), and are skipped when
+// the Docker socket is not there.
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/Parz1val02/OM_module/internal/promconfig"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+// Images of the lab: the versions services.yaml runs.
+const (
+	mockImage       = "busybox:1.36"
+	prometheusImage = "prom/prometheus:v3.10.0"
+	lokiImage       = "grafana/loki:3.0.0"
+)
+
+// mockPort is the metrics port of the Open5GS NFs, the one the
+// docker-services job keeps.
+const mockPort = "9091"
+
+// Mock is a container standing in for an NF of the lab.
+type Mock struct {
+	Service    string // Compose service; the container is <project>-<service>
+	NF         string // om.nf label
+	Domain     string // om.domain label
+	Generation string // om.generation label
+	// Kind is the kind discovery must find.
+	Kind collector.NFKind
+	// Metrics is the exposition the mock serves; $NOW is replaced by the
+	// Unix time of each scrape. A mock without metrics is not scraped.
+	Metrics string
+}
+
+// Mocks are the NFs of the lab: enough of a 5G core for the topology to
+// have N2, N3, N4 and N11, and the log pipeline.
+var Mocks = []Mock{
+	{Service: "amf", NF: "amf", Domain: collector.DomainCore, Generation: "5g", Kind: collector.KindAMF,
+		Metrics: "fivegs_amffunction_rm_reginitreq 3\nfivegs_amffunction_rm_reginitsucc 2\ngnb 1\nran_ue 1\n"},
+	{Service: "smf", NF: "smf", Domain: collector.DomainCore, Generation: "5g", Kind: collector.KindSMF,
+		Metrics: "pfcp_peers_active 1\nfivegs_smffunction_sm_sessionnbr 1\n"},
+	{Service: "upf", NF: "upf", Domain: collector.DomainCore, Generation: "5g", Kind: collector.KindUPF,
+		Metrics: "pfcp_peers_active 1\nfivegs_upffunction_upf_sessionnbr 1\n"},
+	{Service: "gnb", NF: "gnb", Domain: collector.DomainRAN, Generation: "5g", Kind: collector.KindGNB},
+	{Service: "promtail", NF: "promtail", Domain: collector.DomainObservability, Generation: "none",
+		Metrics: `om_logging_lines_level_total{generation="5g",nf="amf",level="error"} $NOW` + "\n" +
+			`om_logging_lines_forwarded_total{generation="5g",nf="amf",level="error"} 0` + "\n"},
+}
+
+// Options configures the lab.
+type Options struct {
+	DockerSocket string
+	// ConfigSource is the directory of the Prometheus configuration
+	// variants the module renders (prometheus/configs).
+	ConfigSource string
+	// Keep leaves the containers, the network and the rendered
+	// configuration in place, to look at after a failed run.
+	Keep bool
+	// Timeout bounds every wait: for an image, a container to be ready, a
+	// check to pass.
+	Timeout time.Duration
+}
+
+// Lab is a running lab.
+type Lab struct {
+	Project       string
+	PrometheusURL string
+	LokiURL       string
+	// ConfigDir holds the rendered Prometheus configuration.
+	ConfigDir string
+	// ExternalLabels are the labels rendered into the configuration.
+	ExternalLabels map[string]string
+
+	opts       Options
+	t          *testing.T
+	cli        *client.Client
+	network    string
+	containers []string // IDs, in start order
+}
+
+// Container returns the name of the container of Compose service service.
+func (l *Lab) Container(service string) string {
+	return l.Project + "-" + service
+}
+
+// startLab renders the Prometheus configuration and starts the lab, or
+// fails t. The lab is removed when t finishes, unless opts.Keep is set.
+func startLab(t *testing.T, opts Options) *Lab {
+	t.Helper()
+	if _, err := os.Stat(opts.DockerSocket); err != nil {
+		t.Skipf("no Docker daemon: %v", err)
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost("unix://"+opts.DockerSocket), client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Fatalf("Docker client: %v", err)
+	}
+	id := make([]byte, 3)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+	l := &Lab{Project: "om-it-" + hex.EncodeToString(id), opts: opts, t: t, cli: cli}
+	l.ExternalLabels = map[string]string{"bench": l.Project}
+	t.Cleanup(l.close)
+	if err := l.start(t.Context()); err != nil {
+		t.Fatalf("lab not started: %v", err)
+	}
+	t.Logf("project %s · Prometheus %s · Loki %s", l.Project, l.PrometheusURL, l.LokiURL)
+	return l
+}
+
+// start brings up the lab; close removes whatever it got to.
+func (l *Lab) start(ctx context.Context) (err error) {
+	if err := l.render(); err != nil {
+		return fmt.Errorf("rendering the Prometheus configuration: %w", err)
+	}
+	for _, ref := range []string{mockImage, prometheusImage, lokiImage} {
+		if err := l.pull(ctx, ref); err != nil {
+			return fmt.Errorf("image %s: %w", ref, err)
+		}
+	}
+	name := l.Project + "_default"
+	if _, err := l.cli.NetworkCreate(ctx, name, network.CreateOptions{
+		Labels: map[string]string{"com.docker.compose.project": l.Project, "com.docker.compose.network": "default"},
+	}); err != nil {
+		return err
+	}
+	l.network = name
+
+	for _, m := range Mocks {
+		if err := l.startMock(ctx, m); err != nil {
+			return fmt.Errorf("mock %s: %w", m.Service, err)
+		}
+	}
+	if l.PrometheusURL, err = l.run(ctx, "prometheus", prometheusImage, "9090", &container.Config{
+		User: "0", // reads the Docker socket, as 65534:${DOCKER_GID} does in services.yaml
+		Cmd:  []string{"--config.file=/etc/prometheus/prometheus.yml", "--web.enable-lifecycle"},
+	}, []string{
+		l.ConfigDir + ":/etc/prometheus:ro",
+		l.opts.DockerSocket + ":/var/run/docker.sock",
+	}); err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	if l.LokiURL, err = l.run(ctx, "loki", lokiImage, "3100", &container.Config{}, nil); err != nil {
+		return fmt.Errorf("loki: %w", err)
+	}
+	for _, ready := range []string{l.PrometheusURL + "/-/ready", l.LokiURL + "/ready"} {
+		if err := l.waitReady(ctx, ready); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close removes the lab, unless it is kept.
+func (l *Lab) close() {
+	defer l.cli.Close()
+	if l.opts.Keep {
+		l.t.Logf("lab kept: docker ps --filter label=com.docker.compose.project=%s; configuration in %s", l.Project, l.ConfigDir)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.opts.Timeout)
+	defer cancel()
+	for i := len(l.containers) - 1; i >= 0; i-- {
+		if err := l.cli.ContainerRemove(ctx, l.containers[i], container.RemoveOptions{Force: true, RemoveVolumes: true}); err != nil {
+			l.t.Errorf("removing container %s: %v", l.containers[i], err)
+		}
+	}
+	if l.network != "" {
+		if err := l.cli.NetworkRemove(ctx, l.network); err != nil {
+			l.t.Errorf("removing network %s: %v", l.network, err)
+		}
+	}
+	if l.ConfigDir != "" {
+		_ = os.RemoveAll(l.ConfigDir)
+	}
+}
+
+// render writes the variants of ConfigSource rendered as the module
+// renders them for Prometheus, with the lab's external labels and the
+// monitoring stack jobs, into a new ConfigDir.
+func (l *Lab) render() error {
+	dir, err := os.MkdirTemp("", l.Project+"-prometheus-")
+	if err != nil {
+		return err
+	}
+	l.ConfigDir = dir
+	// Prometheus runs as root here, but the directory must still be
+	// readable through the bind mount.
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return err
+	}
+	tx := output.NewTxn()
+	opts := promconfig.Options{ExternalLabels: l.ExternalLabels, Stack: promconfig.MonitoringStack}
+	if err := promconfig.Generate(tx, l.opts.ConfigSource, dir, opts); err != nil {
+		return err
+	}
+	if err := promconfig.Validate(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (l *Lab) pull(ctx context.Context, ref string) error {
+	if _, err := l.cli.ImageInspect(ctx, ref); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return err
+	}
+	l.t.Logf("pulling %s", ref)
+	rc, err := l.cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+// mockScript is the CGI program of a mock: the exposition, with $NOW
+// expanded by the here-document.
+const mockScript = "#!/bin/sh\nprintf 'Content-Type: text/plain; version=0.0.4\\r\\n\\r\\n'\nNOW=$(date +%%s)\ncat <<EOF\n%sEOF\n"
+
+func (l *Lab) startMock(ctx context.Context, m Mock) error {
+	labels := map[string]string{
+		"om.nf":         m.NF,
+		"om.domain":     m.Domain,
+		"om.generation": m.Generation,
+		"om.project":    "open5gs",
+	}
+	if m.Metrics != "" {
+		labels["prometheus.scrape"] = "true"
+		labels["prometheus.port"] = mockPort
+		labels["prometheus.path"] = "/cgi-bin/metrics"
+	}
+	cfg := &container.Config{
+		Labels: labels,
+		Env:    []string{"METRICS_CGI=" + fmt.Sprintf(mockScript, m.Metrics)},
+		Cmd: []string{"sh", "-c", `mkdir -p /www/cgi-bin && printf '%s' "$METRICS_CGI" > /www/cgi-bin/metrics && ` +
+			`chmod +x /www/cgi-bin/metrics && exec httpd -f -p ` + mockPort + ` -h /www`},
+	}
+	_, err := l.run(ctx, m.Service, mockImage, mockPort, cfg, nil)
+	return err
+}
+
+// run starts service as a container of the lab's project and network,
+// exposing port, and returns the URL the port is published on at
+// 127.0.0.1. cfg.Labels gets the Compose labels of the service.
+func (l *Lab) run(ctx context.Context, service, ref, port string, cfg *container.Config, binds []string) (string, error) {
+	p := nat.Port(port + "/tcp")
+	cfg.Image = ref
+	cfg.ExposedPorts = nat.PortSet{p: struct{}{}}
+	if cfg.Labels == nil {
+		cfg.Labels = make(map[string]string)
+	}
+	if cfg.Labels["om.nf"] == "" {
+		cfg.Labels["om.nf"] = service
+		cfg.Labels["om.domain"] = collector.DomainObservability
+	}
+	cfg.Labels["com.docker.compose.project"] = l.Project
+	cfg.Labels["com.docker.compose.service"] = service
+	host := &container.HostConfig{
+		NetworkMode:  container.NetworkMode(l.network),
+		Binds:        binds,
+		PortBindings: nat.PortMap{p: {{HostIP: "127.0.0.1"}}},
+	}
+	created, err := l.cli.ContainerCreate(ctx, cfg, host, nil, nil, l.Container(service))
+	if err != nil {
+		return "", err
+	}
+	l.containers = append(l.containers, created.ID)
+	if err := l.cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	info, err := l.cli.ContainerInspect(ctx, created.ID)
+	if err != nil {
+		return "", err
+	}
+	if info.NetworkSettings == nil || len(info.NetworkSettings.Ports[p]) == 0 {
+		return "", fmt.Errorf("port %s not published", p)
+	}
+	return "http://127.0.0.1:" + info.NetworkSettings.Ports[p][0].HostPort, nil
+}
+
+// waitReady polls url until it answers 200.
+func (l *Lab) waitReady(ctx context.Context, url string) error {
+	err := poll(ctx, l.opts.Timeout, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errPending, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%w: %s", errPending, resp.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s not ready: %w", strings.TrimPrefix(url, "http://"), err)
+	}
+	return nil
+}
+
+// errPending wraps the errors of a condition poll waits for that may
+// still come true: a container not discovered yet, a target not scraped
+// yet.
+var errPending = errors.New("not yet")
+
+// poll calls fn every second until it returns nil, an error that does not
+// wrap errPending, or timeout passes, and returns its last error.
+func poll(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		err := fn(ctx)
+		if err == nil || !errors.Is(err, errPending) {
+			return err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("after %s: %w", timeout, err)
+		}
+	}
+}
+
+// prometheusConfig returns the rendered variant Prometheus runs.
+func (l *Lab) prometheusConfig() string {
+	return filepath.Join(l.ConfigDir, "prometheus.yml")
+}
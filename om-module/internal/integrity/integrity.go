@@ -0,0 +1,329 @@
+// Package integrity records a checksum manifest of a lab environment and
+// compares two of them. A TA takes a baseline before class with
+// `om-module snapshot -baseline`; `om-module verify` then lists what a
+// student changed since: container images swapped or rebuilt, NF and
+// observability configs edited, generated files touched by hand, and
+// subscribers added, removed or re-keyed.
+//
+// Only checksums are recorded, so a baseline can be shared without leaking
+// the subscriber keys or the contents of .env.
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/subscribers"
+)
+
+// Entry kinds, in the order they are reported.
+const (
+	KindImage      = "image"
+	KindConfig     = "config"
+	KindArtifact   = "artifact"
+	KindSubscriber = "subscriber"
+)
+
+var kinds = []string{KindImage, KindConfig, KindArtifact, KindSubscriber}
+
+// Change kinds.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// configExts are the project files recorded as configuration. Logs, captures
+// and figures change on every run and are left out with their directories.
+var configExts = map[string]bool{
+	".yaml": true, ".yml": true, ".conf": true, ".json": true, ".env": true,
+	".sh": true, ".py": true, ".cfg": true, ".toml": true, ".alloy": true,
+}
+
+var skipDirs = map[string]bool{
+	".git": true, "logs": true, "snapshots": true, "procedures_captures": true,
+	"figuras": true, "node_modules": true,
+}
+
+// Entry is one checksummed item.
+type Entry struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`             // container, relative path or IMSI
+	Checksum string `json:"checksum"`         // sha256, or the image ID
+	Detail   string `json:"detail,omitempty"` // image reference of a container
+}
+
+// Manifest is the state of an environment at one point in time.
+type Manifest struct {
+	CreatedAt string            `json:"created_at"`
+	Entries   []Entry           `json:"entries"`
+	Errors    map[string]string `json:"errors,omitempty"` // by kind: the source could not be read
+}
+
+// Sources says where Collect reads each kind of entry. An empty field skips
+// its kind.
+type Sources struct {
+	Docker         *dockerclient.Client
+	ProjectDir     string   // the lab repository: compose files, NF configs
+	ArtifactDirs   []string // files the module generates (Prometheus configs, dashboards)
+	MongoContainer string   // the Open5GS subscriber database
+}
+
+// Change is an entry that differs between the baseline and the current
+// environment.
+type Change struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Change   string `json:"change"` // added, removed or modified
+	Baseline string `json:"baseline,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// Report is the result of Verify.
+type Report struct {
+	BaselineAt string   `json:"baseline_at"`
+	CheckedAt  string   `json:"checked_at"`
+	Checked    int      `json:"checked"` // entries compared
+	Changes    []Change `json:"changes"`
+	// Skipped are the kinds not compared because their source could not be
+	// read for the baseline or now, with the reason.
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// Clean reports whether nothing changed.
+func (r Report) Clean() bool {
+	return len(r.Changes) == 0
+}
+
+// Collect reads every source and returns the manifest. A source that cannot
+// be read is recorded in Errors rather than failing the whole manifest, so a
+// lab without its core running can still be verified on its files.
+func Collect(ctx context.Context, src Sources) Manifest {
+	m := Manifest{CreatedAt: time.Now().UTC().Format(time.RFC3339), Entries: []Entry{}}
+	fail := func(kind string, err error) {
+		if m.Errors == nil {
+			m.Errors = make(map[string]string)
+		}
+		m.Errors[kind] = err.Error()
+	}
+	add := func(kind string, entries []Entry, err error) {
+		if err != nil {
+			fail(kind, err)
+			return
+		}
+		m.Entries = append(m.Entries, entries...)
+	}
+
+	if src.Docker != nil {
+		entries, err := images(ctx, src.Docker)
+		add(KindImage, entries, err)
+	}
+	if src.ProjectDir != "" {
+		entries, err := files(KindConfig, src.ProjectDir, func(rel string) bool {
+			return configExts[strings.ToLower(filepath.Ext(rel))]
+		})
+		add(KindConfig, entries, err)
+	}
+	var artifacts []Entry
+	for _, dir := range src.ArtifactDirs {
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			continue // not generated yet
+		}
+		entries, err := files(KindArtifact, dir, func(string) bool { return true })
+		if err != nil {
+			fail(KindArtifact, err)
+			break
+		}
+		for _, e := range entries {
+			e.Name = filepath.Join(filepath.Base(dir), e.Name)
+			artifacts = append(artifacts, e)
+		}
+	}
+	m.Entries = append(m.Entries, artifacts...)
+	if src.Docker != nil && src.MongoContainer != "" {
+		sums, err := subscribers.Digest(ctx, src.Docker, src.MongoContainer)
+		entries := make([]Entry, 0, len(sums))
+		for imsi, sum := range sums {
+			entries = append(entries, Entry{Kind: KindSubscriber, Name: imsi, Checksum: sum})
+		}
+		add(KindSubscriber, entries, err)
+	}
+
+	sort.Slice(m.Entries, func(i, j int) bool {
+		a, b := m.Entries[i], m.Entries[j]
+		if a.Kind != b.Kind {
+			return kindOrder(a.Kind) < kindOrder(b.Kind)
+		}
+		return a.Name < b.Name
+	})
+	return m
+}
+
+// Verify compares current against baseline.
+func Verify(baseline, current Manifest) Report {
+	r := Report{BaselineAt: baseline.CreatedAt, CheckedAt: current.CreatedAt, Changes: []Change{}}
+	for _, kind := range kinds {
+		if reason, ok := baseline.Errors[kind]; ok {
+			r.skip(kind, "baseline: "+reason)
+		} else if reason, ok := current.Errors[kind]; ok {
+			r.skip(kind, reason)
+		}
+	}
+
+	before := index(baseline.Entries)
+	after := index(current.Entries)
+	for key, b := range before {
+		if _, skipped := r.Skipped[b.Kind]; skipped {
+			continue
+		}
+		r.Checked++
+		a, ok := after[key]
+		switch {
+		case !ok:
+			r.Changes = append(r.Changes, Change{Kind: b.Kind, Name: b.Name, Change: Removed, Baseline: describe(b)})
+		case a.Checksum != b.Checksum:
+			r.Changes = append(r.Changes, Change{Kind: b.Kind, Name: b.Name, Change: Modified, Baseline: describe(b), Current: describe(a)})
+		}
+	}
+	for key, a := range after {
+		if _, skipped := r.Skipped[a.Kind]; skipped {
+			continue
+		}
+		if _, ok := before[key]; !ok {
+			r.Changes = append(r.Changes, Change{Kind: a.Kind, Name: a.Name, Change: Added, Current: describe(a)})
+		}
+	}
+
+	sort.Slice(r.Changes, func(i, j int) bool {
+		a, b := r.Changes[i], r.Changes[j]
+		if a.Kind != b.Kind {
+			return kindOrder(a.Kind) < kindOrder(b.Kind)
+		}
+		return a.Name < b.Name
+	})
+	return r
+}
+
+// Load reads a manifest written by `om-module snapshot -baseline`.
+func Load(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (r *Report) skip(kind, reason string) {
+	if r.Skipped == nil {
+		r.Skipped = make(map[string]string)
+	}
+	r.Skipped[kind] = reason
+}
+
+// images records the image each lab container runs. The ID changes when the
+// image is rebuilt or the tag points somewhere else; the reference is kept to
+// tell which.
+func images(ctx context.Context, docker *dockerclient.Client) ([]Entry, error) {
+	containers, err := docker.ListContainers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, c := range containers {
+		if c.Labels["om.nf"] == "" {
+			continue
+		}
+		entries = append(entries, Entry{Kind: KindImage, Name: c.Name, Checksum: c.ImageID, Detail: c.Image})
+	}
+	return entries, nil
+}
+
+// files checksums the regular files under root that keep accepts, named by
+// their path relative to root.
+func files(kind, root string, keep func(rel string) bool) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") && d.Name() != ".env" {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !keep(rel) {
+			return err
+		}
+		sum, err := fileSum(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{Kind: kind, Name: filepath.ToSlash(rel), Checksum: sum})
+		return nil
+	})
+	return entries, err
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func index(entries []Entry) map[string]Entry {
+	m := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		m[e.Kind+"/"+e.Name] = e
+	}
+	return m
+}
+
+// describe is the short form of an entry shown in a change: the image
+// reference and ID, or the first 12 digits of the checksum.
+func describe(e Entry) string {
+	sum := strings.TrimPrefix(e.Checksum, "sha256:")
+	if len(sum) > 12 {
+		sum = sum[:12]
+	}
+	if e.Detail != "" {
+		return e.Detail + " (" + sum + ")"
+	}
+	return sum
+}
+
+func kindOrder(kind string) int {
+	for i, k := range kinds {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kinds)
+}
@@ -0,0 +1,253 @@
+// Package kpi derives lab-session KPIs from the module's Prometheus
+// metrics (a live /metrics scrape or the metrics.prom of a session bundle)
+// and compares two sessions, e.g. before and after a configuration change.
+// Live KPIs are instead evaluated by Prometheus over a recent window, with
+// curated PromQL, for scripts that want a number rather than a query.
+package kpi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// Definition describes one KPI.
+type Definition struct {
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Unit           string `json:"unit"` // "ratio", "seconds", "count"
+	HigherIsBetter bool   `json:"higher_is_better"`
+}
+
+// Definitions lists the KPIs in report order.
+var Definitions = []Definition{
+	{"attach_attempts", "Attach / registration requests", "count", true},
+	{"attach_success_rate", "Accepted / (accepted + rejected + timed out)", "ratio", true},
+	{"attach_rejects", "Attach / registration rejects", "count", false},
+	{"attach_timeouts", "Attempts with no answer within 60 s", "count", false},
+	{"attach_latency_avg", "Mean request → accept time", "seconds", false},
+	{"attach_latency_p95", "95th percentile request → accept time", "seconds", false},
+	{"nas_rejects", "NAS messages carrying a cause", "count", false},
+	{"ap_causes", "NGAP/S1AP messages carrying a Cause IE", "count", false},
+	{"protocol_errors", "GTPv2/PFCP/Diameter/SBI error causes", "count", false},
+	{"sbi_errors", "SBI responses with status ≥ 400", "count", false},
+	{"sbi_latency_avg", "Mean SBI response time", "seconds", false},
+	{"sbi_unanswered", "SBI requests without a response", "count", false},
+	{"packets", "Captured signalling packets", "count", true},
+	{"containers_unhealthy", "Containers not running/healthy", "count", false},
+}
+
+// Set holds the KPI values of one session. KPIs whose metrics were not
+// exported (subsystem disabled, nothing observed) are absent.
+type Set map[string]float64
+
+// FromMetrics computes the KPIs from Prometheus text exposition.
+func FromMetrics(text []byte) (Set, error) {
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(text))
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics: %w", err)
+	}
+
+	s := Set{}
+	sum := func(name string, match func(*dto.Metric) bool) (float64, bool) {
+		mf, ok := families[name]
+		if !ok {
+			return 0, false
+		}
+		total := 0.0
+		for _, m := range mf.GetMetric() {
+			if match != nil && !match(m) {
+				continue
+			}
+			switch {
+			case m.Counter != nil:
+				total += m.GetCounter().GetValue()
+			case m.Gauge != nil:
+				total += m.GetGauge().GetValue()
+			}
+		}
+		return total, true
+	}
+	result := func(r string) func(*dto.Metric) bool {
+		return func(m *dto.Metric) bool { return label(m, "result") == r }
+	}
+
+	if v, ok := sum("om_attach_attempts_total", nil); ok {
+		s["attach_attempts"] = v
+		acc, _ := sum("om_attach_results_total", result("accepted"))
+		rej, _ := sum("om_attach_results_total", result("rejected"))
+		tmo, _ := sum("om_attach_results_total", result("timeout"))
+		s["attach_rejects"] = rej
+		s["attach_timeouts"] = tmo
+		if done := acc + rej + tmo; done > 0 {
+			s["attach_success_rate"] = acc / done
+		}
+	}
+	if h, ok := mergeHistograms(families["om_attach_seconds"]); ok && h.GetSampleCount() > 0 {
+		s["attach_latency_avg"] = h.GetSampleSum() / float64(h.GetSampleCount())
+		s["attach_latency_p95"] = quantile(h, 0.95)
+	}
+	if v, ok := sum("om_nas_reject_total", nil); ok {
+		s["nas_rejects"] = v
+	}
+	if v, ok := sum("om_ap_cause_total", nil); ok {
+		s["ap_causes"] = v
+	}
+	if v, ok := sum("om_capture_errors_total", nil); ok {
+		s["protocol_errors"] = v
+	}
+	if v, ok := sum("om_capture_packets_total", nil); ok {
+		s["packets"] = v
+	}
+	if h, ok := mergeHistograms(families["om_sbi_response_seconds"]); ok && h.GetSampleCount() > 0 {
+		s["sbi_latency_avg"] = h.GetSampleSum() / float64(h.GetSampleCount())
+	}
+	if _, ok := families["om_sbi_responses_total"]; ok {
+		s["sbi_errors"], _ = sum("om_sbi_responses_total", func(m *dto.Metric) bool {
+			code, err := strconv.Atoi(label(m, "status"))
+			return err == nil && code >= 400
+		})
+	}
+	if v, ok := sum("om_sbi_unanswered_total", nil); ok {
+		s["sbi_unanswered"] = v
+	}
+	if mf, ok := families["container_health_status"]; ok {
+		// 1 = running; 0 (degraded/unknown) and -1 (stopped) count as unhealthy.
+		unhealthy := 0.0
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() < 1 {
+				unhealthy++
+			}
+		}
+		s["containers_unhealthy"] = unhealthy
+	}
+	return s, nil
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// mergeHistograms adds up every series of a histogram family.
+func mergeHistograms(mf *dto.MetricFamily) (*dto.Histogram, bool) {
+	if mf == nil || mf.GetType() != dto.MetricType_HISTOGRAM {
+		return nil, false
+	}
+	var count uint64
+	var total float64
+	buckets := map[float64]uint64{}
+	for _, m := range mf.GetMetric() {
+		h := m.GetHistogram()
+		count += h.GetSampleCount()
+		total += h.GetSampleSum()
+		for _, b := range h.GetBucket() {
+			buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+		}
+	}
+	bounds := make([]float64, 0, len(buckets))
+	for ub := range buckets {
+		bounds = append(bounds, ub)
+	}
+	sort.Float64s(bounds)
+	out := &dto.Histogram{SampleCount: &count, SampleSum: &total}
+	for _, ub := range bounds {
+		ub, c := ub, buckets[ub]
+		out.Bucket = append(out.Bucket, &dto.Bucket{UpperBound: &ub, CumulativeCount: &c})
+	}
+	return out, true
+}
+
+// quantile estimates q from the buckets by linear interpolation, as
+// PromQL's histogram_quantile does.
+func quantile(h *dto.Histogram, q float64) float64 {
+	rank := q * float64(h.GetSampleCount())
+	prevBound, prevCount := 0.0, 0.0
+	for _, b := range h.GetBucket() {
+		count := float64(b.GetCumulativeCount())
+		if count >= rank {
+			if math.IsInf(b.GetUpperBound(), 1) {
+				return prevBound
+			}
+			if count == prevCount {
+				return b.GetUpperBound()
+			}
+			return prevBound + (b.GetUpperBound()-prevBound)*(rank-prevCount)/(count-prevCount)
+		}
+		prevBound, prevCount = b.GetUpperBound(), count
+	}
+	return prevBound
+}
+
+// Delta is one KPI of a comparison.
+type Delta struct {
+	Definition
+	Baseline *float64 `json:"baseline"`
+	Current  *float64 `json:"current"`
+	Change   *float64 `json:"change,omitempty"`         // current − baseline
+	Percent  *float64 `json:"change_percent,omitempty"` // relative to baseline
+	Verdict  string   `json:"verdict"`                  // "better", "worse", "same", "n/a"
+}
+
+// Compare returns the delta of every KPI present in either set.
+func Compare(baseline, current Set) []Delta {
+	out := make([]Delta, 0, len(Definitions))
+	for _, def := range Definitions {
+		b, bok := baseline[def.Name]
+		c, cok := current[def.Name]
+		if !bok && !cok {
+			continue
+		}
+		d := Delta{Definition: def, Verdict: "n/a"}
+		if bok {
+			d.Baseline = &b
+		}
+		if cok {
+			d.Current = &c
+		}
+		if bok && cok {
+			change := c - b
+			d.Change = &change
+			if b != 0 {
+				pct := change / math.Abs(b) * 100
+				d.Percent = &pct
+			}
+			switch {
+			case math.Abs(change) < 1e-9:
+				d.Verdict = "same"
+			case (change > 0) == def.HigherIsBetter:
+				d.Verdict = "better"
+			default:
+				d.Verdict = "worse"
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// Format renders v in the KPI's unit for the text report.
+func Format(def Definition, v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	switch def.Unit {
+	case "ratio":
+		return strconv.FormatFloat(*v*100, 'f', 1, 64) + " %"
+	case "seconds":
+		return time.Duration(*v * float64(time.Second)).Round(time.Microsecond).String()
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
@@ -0,0 +1,259 @@
+package kpi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+)
+
+// Live window bounds: below the scrape interval rate() has nothing to work
+// with, beyond a day a coursework script is better off with a bundle.
+const (
+	MinWindow = 30 * time.Second
+	MaxWindow = 24 * time.Hour
+)
+
+// LiveDefinition is a KPI Prometheus computes over a window of the running
+// lab, for /api/kpi/{name}. Queries are keyed by generation; "$window" is
+// replaced by the window. With By the value is also broken down by that
+// label.
+type LiveDefinition struct {
+	Definition
+	Queries map[string]string `json:"-"`
+	By      string            `json:"by,omitempty"`
+}
+
+// Generations lists the generations the KPI has a query for.
+func (d LiveDefinition) Generations() []string {
+	out := make([]string, 0, len(d.Queries))
+	for g := range d.Queries {
+		out = append(out, g)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Query returns the PromQL of the KPI for generation over window.
+func (d LiveDefinition) Query(generation string, window time.Duration) (string, bool) {
+	q, ok := d.Queries[generation]
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(q, "$window", strconv.Itoa(int(window.Seconds()))+"s"), true
+}
+
+// LiveDefinitions lists the live KPIs. The attach KPIs come from the
+// module's own capture metrics; the others from the Open5GS and container
+// metrics Prometheus scrapes.
+var LiveDefinitions = []LiveDefinition{
+	{
+		Definition: Definition{"attach_success_rate", "Accepted / (accepted + rejected + timed out) attaches in the window", "ratio", true},
+		Queries: map[string]string{
+			"4g": `sum(increase(om_attach_results_total{generation="4g", result="accepted"}[$window])) / sum(increase(om_attach_results_total{generation="4g"}[$window]))`,
+			"5g": `sum(increase(om_attach_results_total{generation="5g", result="accepted"}[$window])) / sum(increase(om_attach_results_total{generation="5g"}[$window]))`,
+		},
+	},
+	{
+		Definition: Definition{"attach_attempts", "Attach / registration requests in the window", "count", true},
+		Queries: map[string]string{
+			"4g": `sum(increase(om_attach_attempts_total{generation="4g"}[$window]))`,
+			"5g": `sum(increase(om_attach_attempts_total{generation="5g"}[$window]))`,
+		},
+	},
+	{
+		Definition: Definition{"attach_latency_p95", "95th percentile request → accept time in the window", "seconds", false},
+		Queries: map[string]string{
+			"4g": `histogram_quantile(0.95, sum by (le) (rate(om_attach_seconds_bucket{generation="4g"}[$window])))`,
+			"5g": `histogram_quantile(0.95, sum by (le) (rate(om_attach_seconds_bucket{generation="5g"}[$window])))`,
+		},
+	},
+	{
+		Definition: Definition{"registration_success_rate", "Initial registrations accepted by the AMF / requested, in the window", "ratio", true},
+		Queries: map[string]string{
+			"5g": `sum(increase(fivegs_amffunction_rm_reginitsucc[$window])) / sum(increase(fivegs_amffunction_rm_reginitreq[$window]))`,
+		},
+	},
+	{
+		Definition: Definition{"auth_failures", "UE authentication failures at the AMF in the window", "count", false},
+		Queries: map[string]string{
+			"5g": `sum(increase(fivegs_amffunction_amf_authfail[$window]))`,
+		},
+	},
+	{
+		Definition: Definition{"active_ues", "UEs connected to the AMF (5G) or MME (4G), highest in the window", "count", true},
+		Queries: map[string]string{
+			"4g": `max_over_time(sum(ues_active)[$window:])`,
+			"5g": `max_over_time(sum(ran_ue)[$window:])`,
+		},
+	},
+	{
+		Definition: Definition{"ran_nodes", "gNBs (5G) or eNBs (4G) connected to the core, lowest in the window", "count", true},
+		Queries: map[string]string{
+			"4g": `min_over_time(sum(enb)[$window:])`,
+			"5g": `min_over_time(sum(gnb)[$window:])`,
+		},
+	},
+	{
+		Definition: Definition{"sessions", "PDU sessions at the SMF (5G) or MME sessions (4G), highest in the window", "count", true},
+		Queries: map[string]string{
+			"4g": `max_over_time(sum(mme_session)[$window:])`,
+			"5g": `max_over_time(sum(fivegs_smffunction_sm_sessionnbr)[$window:])`,
+		},
+	},
+	{
+		Definition: Definition{"nf_availability", "Share of the window the core NF containers were running, mean over the NFs", "ratio", true},
+		Queries: map[string]string{
+			"4g": `avg(avg_over_time(clamp_min(container_health_status{domain="core", generation="4g"}, 0)[$window:]))`,
+			"5g": `avg(avg_over_time(clamp_min(container_health_status{domain="core", generation="5g"}, 0)[$window:]))`,
+		},
+	},
+	{
+		Definition: Definition{"upf_throughput", "User plane bytes per second received + sent by the UPFs (SGW-Us in 4G), mean over the window", "bytes/s", true},
+		Queries: map[string]string{
+			"4g": `sum by (nf) (rate(container_network_rx_bytes_total{generation="4g", nf=~"sgwu|upf.*"}[$window]) + rate(container_network_tx_bytes_total{generation="4g", nf=~"sgwu|upf.*"}[$window]))`,
+			"5g": `sum by (nf) (rate(container_network_rx_bytes_total{generation="5g", nf=~"upf.*"}[$window]) + rate(container_network_tx_bytes_total{generation="5g", nf=~"upf.*"}[$window]))`,
+		},
+		By: "nf",
+	},
+}
+
+// LookupLive returns the live KPI called name.
+func LookupLive(name string) (LiveDefinition, bool) {
+	for _, d := range LiveDefinitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return LiveDefinition{}, false
+}
+
+// LiveValue is a live KPI as returned by /api/kpi/{name}: flat, so a script
+// reads value without knowing PromQL or the metric names.
+type LiveValue struct {
+	Name       string `json:"name"`
+	Generation string `json:"generation"`
+	Window     string `json:"window"`
+	Unit       string `json:"unit"`
+	Time       string `json:"time"`
+	// Value is nil when Prometheus has no data for the KPI (nothing
+	// observed, or a ratio of two zero counts).
+	Value  *float64           `json:"value"`
+	Values map[string]float64 `json:"values,omitempty"` // by the By label
+	Query  string             `json:"query"`
+}
+
+// Prometheus evaluates live KPIs.
+type Prometheus struct {
+	url     string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewPrometheus returns a client of the Prometheus at url.
+func NewPrometheus(url string, timeout time.Duration) *Prometheus {
+	return &Prometheus{url: url, timeout: timeout, client: httpclient.New("kpi", 0)}
+}
+
+// Eval evaluates d for generation over the window ending now.
+func (p *Prometheus) Eval(ctx context.Context, d LiveDefinition, generation string, window time.Duration) (LiveValue, error) {
+	expr, ok := d.Query(generation, window)
+	if !ok {
+		return LiveValue{}, fmt.Errorf("%s has no %s query (generations: %s)", d.Name, generation, strings.Join(d.Generations(), ", "))
+	}
+	now := time.Now()
+	v := LiveValue{
+		Name:       d.Name,
+		Generation: generation,
+		Window:     window.String(),
+		Unit:       d.Unit,
+		Time:       now.UTC().Format(time.RFC3339),
+		Query:      expr,
+	}
+
+	samples, err := p.query(ctx, expr, now)
+	if err != nil {
+		return v, err
+	}
+	total, seen := 0.0, false
+	for _, s := range samples {
+		if math.IsNaN(s.value) || math.IsInf(s.value, 0) {
+			continue
+		}
+		total += s.value
+		seen = true
+		if d.By != "" {
+			if v.Values == nil {
+				v.Values = make(map[string]float64)
+			}
+			v.Values[s.labels[d.By]] = round(s.value)
+		}
+	}
+	if seen {
+		total = round(total)
+		v.Value = &total
+	}
+	return v, nil
+}
+
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// query runs one instant query at t.
+func (p *Prometheus) query(ctx context.Context, expr string, t time.Time) ([]sample, error) {
+	q := url.Values{}
+	q.Set("query", expr)
+	q.Set("time", strconv.FormatInt(t.Unix(), 10))
+	target := strings.TrimRight(p.url, "/") + "/api/v1/query?" + q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make([]sample, 0, len(body.Data.Result))
+	for _, res := range body.Data.Result {
+		s, _ := res.Value[1].(string)
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, sample{labels: res.Metric, value: v})
+	}
+	return out, nil
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
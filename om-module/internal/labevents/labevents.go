@@ -0,0 +1,470 @@
+// Package labevents takes in the events the lab's own scripts report — a
+// UE simulator started, an SDR attached, a scenario step executed — so
+// what the students did can be seen next to what the network did. Every
+// event is kept (in memory and in Dir/events.jsonl), counted in
+// om_lab_events_total with its ID as exemplar, pushed to Loki as a line of
+// {job="lab-events"} and annotated in Grafana with the lab-event tag,
+// which the core and classroom dashboards show.
+package labevents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// File is the events file in Options.Dir; it is rotated to File.1 when it
+// outgrows maxFileBytes.
+const File = "events.jsonl"
+
+const (
+	// maxEvents is how many events Status keeps.
+	maxEvents = 500
+	// maxKinds bounds the type/source pairs, the series of the metrics.
+	maxKinds     = 200
+	maxFileBytes = 8 << 20
+	// queueSize is how many events wait to be forwarded.
+	queueSize = 256
+	// maxAge and maxSkew bound the time of an event: Loki refuses older
+	// lines, and a clock far ahead is a script's mistake.
+	maxAge  = 24 * time.Hour
+	maxSkew = time.Minute
+)
+
+// Errors of Record.
+var (
+	ErrInvalid  = errors.New("invalid event")
+	ErrTooMany  = errors.New("too many event types and sources")
+	ErrDisabled = errors.New("lab events disabled")
+)
+
+// name is a type, source or label name: it becomes a label value.
+var name = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+// Event is one event reported by a lab script.
+type Event struct {
+	ID string `json:"id"`
+	// Time is when it happened (RFC 3339); the time it was received when
+	// the script gives none.
+	Time string `json:"time"`
+	// Type is what happened (ue_started, sdr_attached, scenario_step) and
+	// Source who reports it (the script or host): both lowercase names.
+	Type    string `json:"type"`
+	Source  string `json:"source"`
+	Message string `json:"message,omitempty"`
+	// IMSI and Generation tie the event to a UE and a core, when known.
+	IMSI       string `json:"imsi,omitempty"`
+	Generation string `json:"generation,omitempty"`
+	// Attributes are free-form details (scenario, step, gain), kept and
+	// forwarded but not used as labels.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Session is the lab session open when the event was received.
+	Session  string `json:"session,omitempty"`
+	Received string `json:"received"`
+}
+
+// Options configure the recorder.
+type Options struct {
+	// Dir holds the events file; empty keeps the events in memory only.
+	Dir string
+	// LokiURL, when set, receives every event as a log line.
+	LokiURL     string
+	LokiTimeout time.Duration
+	// Grafana, when set, gets an annotation per event.
+	Grafana *grafana.Client
+	// Session, when set, returns the ID of the open lab session, "" when
+	// none is.
+	Session func() string
+}
+
+// Status is the API view of the recorder.
+type Status struct {
+	File     string `json:"file,omitempty"`
+	Received int    `json:"received"` // since start
+	// Forwarded and Failed count the pushes to Loki and Grafana.
+	Forwarded int     `json:"forwarded"`
+	Failed    int     `json:"failed"`
+	LastError string  `json:"last_error,omitempty"`
+	Events    []Event `json:"events"` // newest first
+}
+
+// Recorder keeps and forwards the events.
+type Recorder struct {
+	opts   Options
+	client *http.Client
+	queue  chan Event
+
+	events  *prometheus.CounterVec
+	last    *prometheus.GaugeVec
+	dropped prometheus.Counter
+	forward *prometheus.CounterVec
+
+	mu        sync.Mutex
+	recent    []Event // newest first
+	kinds     map[string]bool
+	received  int
+	forwarded int
+	failed    int
+	lastErr   string
+}
+
+// New registers the om_lab_event* metrics on reg and loads the events
+// kept in opts.Dir.
+func New(reg prometheus.Registerer, opts Options) (*Recorder, error) {
+	r := &Recorder{
+		opts:   opts,
+		client: httpclient.New("labevents", opts.LokiTimeout),
+		queue:  make(chan Event, queueSize),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "lab", Name: "events_total",
+			Help: "Events reported by lab scripts (POST /api/events), by type and source; the exemplar is the event ID.",
+		}, []string{"type", "source"}),
+		last: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "lab", Name: "event_last_timestamp_seconds",
+			Help: "Time of the last event reported by lab scripts, by type and source.",
+		}, []string{"type", "source"}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "lab", Name: "events_dropped_total",
+			Help: "Lab events kept but not forwarded to Loki and Grafana because the queue was full.",
+		}),
+		forward: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "lab", Name: "event_forward_failures_total",
+			Help: "Lab events that could not be forwarded, by target (loki, grafana).",
+		}, []string{"target"}),
+		kinds: make(map[string]bool),
+	}
+	for _, t := range []string{"loki", "grafana"} {
+		r.forward.WithLabelValues(t)
+	}
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	reg.MustRegister(r.events, r.last, r.dropped, r.forward)
+	return r, nil
+}
+
+// load reads back the newest events of the events file.
+func (r *Recorder) load() error {
+	if r.opts.Dir == "" {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(r.opts.Dir, File))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for sc.Scan() {
+		var e Event
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		r.recent = append([]Event{e}, r.recent...)
+		if len(r.recent) > maxEvents {
+			r.recent = r.recent[:maxEvents]
+		}
+		r.kinds[e.Type+"/"+e.Source] = true
+	}
+	return sc.Err()
+}
+
+// Record checks e, keeps it and queues it for Loki and Grafana. It
+// returns the event as kept, with its ID and times.
+func (r *Recorder) Record(e Event) (Event, error) {
+	if r == nil {
+		return Event{}, ErrDisabled
+	}
+	now := time.Now()
+	if err := check(&e, now); err != nil {
+		return Event{}, err
+	}
+	kind := e.Type + "/" + e.Source
+
+	r.mu.Lock()
+	if !r.kinds[kind] && len(r.kinds) >= maxKinds {
+		r.mu.Unlock()
+		return Event{}, fmt.Errorf("%w: %d type/source pairs already", ErrTooMany, maxKinds)
+	}
+	r.kinds[kind] = true
+	e.ID = newID()
+	if r.opts.Session != nil {
+		e.Session = r.opts.Session()
+	}
+	e.Received = now.UTC().Format(time.RFC3339Nano)
+	if err := r.append(e); err != nil {
+		r.mu.Unlock()
+		return Event{}, err
+	}
+	r.recent = append([]Event{e}, r.recent...)
+	if len(r.recent) > maxEvents {
+		r.recent = r.recent[:maxEvents]
+	}
+	r.received++
+	r.mu.Unlock()
+
+	at, _ := time.Parse(time.RFC3339Nano, e.Time)
+	c := r.events.WithLabelValues(e.Type, e.Source)
+	if ea, ok := c.(prometheus.ExemplarAdder); ok {
+		ea.AddWithExemplar(1, prometheus.Labels{"event_id": e.ID})
+	} else {
+		c.Inc()
+	}
+	r.last.WithLabelValues(e.Type, e.Source).Set(float64(at.UnixMilli()) / 1000)
+
+	select {
+	case r.queue <- e:
+	default:
+		r.dropped.Inc()
+	}
+	return e, nil
+}
+
+// Validate returns the error Record would return for the fields of e, to
+// check a batch before recording any of it.
+func Validate(e Event) error {
+	return check(&e, time.Now())
+}
+
+// check validates e and fills in its time.
+func check(e *Event, now time.Time) error {
+	e.Type, e.Source = strings.ToLower(strings.TrimSpace(e.Type)), strings.ToLower(strings.TrimSpace(e.Source))
+	switch {
+	case !name.MatchString(e.Type):
+		return fmt.Errorf("%w: type must be a lowercase name (letters, digits, _ . -), e.g. ue_started", ErrInvalid)
+	case !name.MatchString(e.Source):
+		return fmt.Errorf("%w: source must be a lowercase name (letters, digits, _ . -), e.g. ue-script", ErrInvalid)
+	case e.Generation != "" && e.Generation != "4g" && e.Generation != "5g":
+		return fmt.Errorf("%w: generation must be 4g or 5g", ErrInvalid)
+	case len(e.Message) > 4096:
+		return fmt.Errorf("%w: message longer than 4096 bytes", ErrInvalid)
+	case len(e.Attributes) > 32:
+		return fmt.Errorf("%w: more than 32 attributes", ErrInvalid)
+	}
+	if e.Time == "" {
+		e.Time = now.UTC().Format(time.RFC3339Nano)
+		return nil
+	}
+	at, err := time.Parse(time.RFC3339Nano, e.Time)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: time: %v", ErrInvalid, err)
+	case at.Before(now.Add(-maxAge)) || at.After(now.Add(maxSkew)):
+		return fmt.Errorf("%w: time must be within the last 24 hours", ErrInvalid)
+	}
+	e.Time = at.UTC().Format(time.RFC3339Nano)
+	return nil
+}
+
+// append writes e to the events file, rotating it when it is full. Called
+// with r.mu held.
+func (r *Recorder) append(e Event) error {
+	if r.opts.Dir == "" {
+		return nil
+	}
+	path := filepath.Join(r.opts.Dir, File)
+	if info, err := os.Stat(path); err == nil && info.Size() >= maxFileBytes {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Run forwards the queued events to Loki and Grafana until ctx is
+// cancelled.
+func (r *Recorder) Run(ctx context.Context) {
+	for {
+		select {
+		case e := <-r.queue:
+			r.forwardEvent(ctx, e)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Recorder) forwardEvent(ctx context.Context, e Event) {
+	var errs []error
+	if r.opts.LokiURL != "" {
+		if err := r.push(ctx, e); err != nil {
+			r.forward.WithLabelValues("loki").Inc()
+			errs = append(errs, err)
+		}
+	}
+	if r.opts.Grafana != nil {
+		if _, err := r.opts.Grafana.CreateAnnotation(ctx, annotation(e)); err != nil {
+			r.forward.WithLabelValues("grafana").Inc()
+			errs = append(errs, fmt.Errorf("grafana annotation: %w", err))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := errors.Join(errs...); err != nil {
+		if r.lastErr == "" {
+			log.Printf("⚠️  Lab events: %v", err)
+		}
+		r.failed++
+		r.lastErr = err.Error()
+		return
+	}
+	r.forwarded++
+}
+
+// annotation marks e on the dashboards.
+func annotation(e Event) grafana.Annotation {
+	at, _ := time.Parse(time.RFC3339Nano, e.Time)
+	text := fmt.Sprintf("🧪 %s (%s)", e.Type, e.Source)
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	if e.IMSI != "" {
+		text += " — IMSI " + e.IMSI
+	}
+	tags := []string{"lab-event", e.Type}
+	if e.Generation != "" {
+		tags = append(tags, e.Generation)
+	}
+	return grafana.Annotation{Time: at.UnixMilli(), Tags: tags, Text: text}
+}
+
+// push sends e to Loki as a logfmt line of the stream {job="lab-events",
+// type, source}.
+func (r *Recorder) push(ctx context.Context, e Event) error {
+	at, _ := time.Parse(time.RFC3339Nano, e.Time)
+	labels := map[string]string{"job": "lab-events", "type": e.Type, "source": e.Source}
+	if e.Generation != "" {
+		labels["generation"] = e.Generation
+	}
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	body := struct {
+		Streams []stream `json:"streams"`
+	}{Streams: []stream{{Stream: labels, Values: [][2]string{{strconv.FormatInt(at.UnixNano(), 10), logLine(e)}}}}}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.LokiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(r.opts.LokiURL, "/")+"/loki/api/v1/push", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("loki push: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// logLine renders e as logfmt, for | logfmt in LogQL.
+func logLine(e Event) string {
+	pairs := [][2]string{{"id", e.ID}, {"msg", e.Message}, {"imsi", e.IMSI}, {"session", e.Session}}
+	keys := make([]string, 0, len(e.Attributes))
+	for k := range e.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs = append(pairs, [2]string{k, e.Attributes[k]})
+	}
+	var b strings.Builder
+	for _, p := range pairs {
+		if p[1] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p[0] + "=")
+		if strings.ContainsAny(p[1], " \"=") {
+			b.WriteString(strconv.Quote(p[1]))
+		} else {
+			b.WriteString(p[1])
+		}
+	}
+	return b.String()
+}
+
+// Status returns the newest events first, optionally only those of type
+// and source, up to limit (all when 0).
+func (r *Recorder) Status(typ, source string, limit int) Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Status{
+		Received:  r.received,
+		Forwarded: r.forwarded,
+		Failed:    r.failed,
+		LastError: r.lastErr,
+		Events:    []Event{},
+	}
+	if r.opts.Dir != "" {
+		s.File = filepath.Join(r.opts.Dir, File)
+	}
+	for _, e := range r.recent {
+		if (typ != "" && e.Type != typ) || (source != "" && e.Source != source) {
+			continue
+		}
+		if limit > 0 && len(s.Events) == limit {
+			break
+		}
+		s.Events = append(s.Events, e)
+	}
+	return s
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,535 @@
+// Package labsession structures the lab's data around the class schedule.
+// An instructor opens a session with a name, a duration and the groups
+// taking part; while it is open every series Prometheus scrapes carries its
+// ID in a label (through the rendered configurations, see promconfig), the
+// high-overhead collection runs, and Grafana gets a start annotation. When
+// it ends, closed by hand or because its time is up, a report of the
+// session is written next to the session state, and the collection gated
+// on sessions stops until the next one.
+package labsession
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stateFile keeps the sessions in Options.Dir across restarts.
+const stateFile = "sessions.json"
+
+// maxSessions bounds the past sessions kept.
+const maxSessions = 50
+
+// MaxDuration is the longest session that can be opened.
+const MaxDuration = 12 * time.Hour
+
+// Ways a session ends.
+const (
+	EndClosed  = "closed"  // closed through the API or CLI
+	EndExpired = "expired" // its duration was up
+)
+
+// Errors of Open and Close.
+var (
+	ErrActive    = errors.New("a lab session is already open")
+	ErrNoSession = errors.New("no lab session is open")
+)
+
+// Options configure the manager.
+type Options struct {
+	// Dir holds the session state and the reports, <id>.md.
+	Dir string
+	// Label is the series label carrying the session ID.
+	Label string
+	// DefaultDuration is the duration of a session opened without one.
+	DefaultDuration time.Duration
+	// Gate stops the high-overhead collection outside sessions.
+	Gate bool
+	// Interval is how often the end of the session is checked.
+	Interval time.Duration
+	// Grafana, when set, gets an annotation when a session opens and a
+	// region over it when it ends.
+	Grafana *grafana.Client
+}
+
+// Reporter renders the report of an ended session as markdown.
+type Reporter func(ctx context.Context, s Session) ([]byte, error)
+
+// Session is one lab session.
+type Session struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Groups    []string `json:"groups"`
+	StartedAt string   `json:"started_at"`
+	EndsAt    string   `json:"ends_at"`
+	EndedAt   string   `json:"ended_at,omitempty"`
+	// End is how the session ended: closed or expired.
+	End string `json:"end,omitempty"`
+	// Report is the file name of the report in the session directory,
+	// once written; ReportError why it could not be.
+	Report      string `json:"report,omitempty"`
+	ReportError string `json:"report_error,omitempty"`
+}
+
+// Status is the API view of the sessions.
+type Status struct {
+	Dir   string `json:"dir"`
+	Label string `json:"label"`
+	// Gate is true when the high-overhead collection only runs during a
+	// session, Collecting whether it runs now.
+	Gate       bool     `json:"gate"`
+	Collecting bool     `json:"collecting"`
+	Active     *Session `json:"active"`
+	// RemainingSeconds is the time left of the active session.
+	RemainingSeconds float64 `json:"remaining_seconds,omitempty"`
+	// Sessions are the past sessions, newest first.
+	Sessions []Session `json:"sessions"`
+}
+
+// Manager opens, ends and reports lab sessions.
+type Manager struct {
+	opts     Options
+	reporter Reporter
+	wake     chan struct{}
+
+	active    prometheus.Gauge
+	info      *prometheus.GaugeVec
+	remaining prometheus.Gauge
+	ended     *prometheus.CounterVec
+
+	mu       sync.RWMutex
+	current  *Session
+	past     []Session // newest first
+	onChange []func()
+	onStart  []func(Session)
+	onEnd    []func(Session)
+	checked  time.Time
+}
+
+// New registers the om_lab_session_* metrics on reg and returns the
+// manager, with the sessions saved in opts.Dir. A session still open when
+// the module stopped is resumed, or ended on the first check when its time
+// is up.
+func New(reg prometheus.Registerer, opts Options) (*Manager, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		opts: opts,
+		wake: make(chan struct{}, 1),
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "lab_session", Name: "active",
+			Help: "1 while a lab session is open.",
+		}),
+		info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "lab_session", Name: "info",
+			Help: "1 for the open lab session, with its ID and name.",
+		}, []string{"session", "name"}),
+		remaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "lab_session", Name: "remaining_seconds",
+			Help: "Time left of the open lab session (0 outside sessions).",
+		}),
+		ended: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "lab_session", Name: "ended_total",
+			Help: "Lab sessions ended, by how they ended (closed or expired).",
+		}, []string{"end"}),
+	}
+
+	data, err := os.ReadFile(filepath.Join(opts.Dir, stateFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var state struct {
+			Active   *Session  `json:"active"`
+			Sessions []Session `json:"sessions"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %w", stateFile, err)
+		}
+		m.current, m.past = state.Active, state.Sessions
+	}
+	if m.current != nil {
+		m.info.WithLabelValues(m.current.ID, m.current.Name).Set(1)
+		m.active.Set(1)
+	}
+	reg.MustRegister(m.active, m.info, m.remaining, m.ended)
+	return m, nil
+}
+
+// SetReporter sets what renders the report of an ended session.
+func (m *Manager) SetReporter(r Reporter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reporter = r
+}
+
+// OnChange registers fn to run when a session opens or ends, for the files
+// that carry the session label.
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// OnStart registers fn to run when a session opens.
+func (m *Manager) OnStart(fn func(Session)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onStart = append(m.onStart, fn)
+}
+
+// OnEnd registers fn to run when a session ends.
+func (m *Manager) OnEnd(fn func(Session)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEnd = append(m.onEnd, fn)
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Open opens a session named name for d (the default duration when 0)
+// with groups.
+func (m *Manager) Open(ctx context.Context, name string, d time.Duration, groups []string) (Session, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Session{}, fmt.Errorf("a session needs a name")
+	}
+	if d == 0 {
+		d = m.opts.DefaultDuration
+	}
+	if d <= 0 || d > MaxDuration {
+		return Session{}, fmt.Errorf("duration must be between 1s and %s", MaxDuration)
+	}
+
+	m.mu.Lock()
+	if m.current != nil {
+		m.mu.Unlock()
+		return Session{}, ErrActive
+	}
+	now := time.Now()
+	id := now.UTC().Format("20060102-1504")
+	if slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-"); slug != "" {
+		id += "-" + slug
+	}
+	for n, base := 2, id; m.known(id); n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	s := Session{
+		ID:        id,
+		Name:      name,
+		Groups:    cleanGroups(groups),
+		StartedAt: now.UTC().Format(time.RFC3339),
+		EndsAt:    now.Add(d).UTC().Format(time.RFC3339),
+	}
+	m.current = &s
+	hooks, starts := m.onChange, m.onStart
+	m.mu.Unlock()
+
+	m.info.WithLabelValues(s.ID, s.Name).Set(1)
+	m.active.Set(1)
+	m.remaining.Set(d.Seconds())
+	if err := m.save(); err != nil {
+		log.Printf("⚠️  Lab session: %v", err)
+	}
+	log.Printf("🎓 Lab session %s opened for %s (groups: %s)", s.ID, d, strings.Join(s.Groups, ", "))
+	m.annotate(ctx, grafana.Annotation{
+		Time: now.UnixMilli(),
+		Tags: []string{"lab_session", s.ID},
+		Text: "🎓 Lab session opened: " + s.Name,
+	})
+	for _, fn := range starts {
+		fn(s)
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+	return s, nil
+}
+
+// known reports whether a past session has id. m.mu is held.
+func (m *Manager) known(id string) bool {
+	for _, s := range m.past {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Close ends the open session now. Its report is written in the
+// background.
+func (m *Manager) Close(ctx context.Context) (Session, error) {
+	s, ok := m.end(ctx, EndClosed, time.Now())
+	if !ok {
+		return Session{}, ErrNoSession
+	}
+	return s, nil
+}
+
+// end ends the open session with reason, when there is one.
+func (m *Manager) end(ctx context.Context, reason string, at time.Time) (Session, bool) {
+	m.mu.Lock()
+	if m.current == nil {
+		m.mu.Unlock()
+		return Session{}, false
+	}
+	s := *m.current
+	s.EndedAt, s.End = at.UTC().Format(time.RFC3339), reason
+	m.current = nil
+	m.past = append([]Session{s}, m.past...)
+	if len(m.past) > maxSessions {
+		m.past = m.past[:maxSessions]
+	}
+	hooks, ends := m.onChange, m.onEnd
+	m.mu.Unlock()
+
+	m.info.Reset()
+	m.active.Set(0)
+	m.remaining.Set(0)
+	m.ended.WithLabelValues(reason).Inc()
+	if err := m.save(); err != nil {
+		log.Printf("⚠️  Lab session: %v", err)
+	}
+	log.Printf("🎓 Lab session %s %s", s.ID, reason)
+	if started, err := time.Parse(time.RFC3339, s.StartedAt); err == nil {
+		m.annotate(ctx, grafana.Annotation{
+			Time:    started.UnixMilli(),
+			TimeEnd: at.UnixMilli(),
+			Tags:    []string{"lab_session", s.ID},
+			Text:    fmt.Sprintf("🎓 Lab session %s (%s)", s.Name, reason),
+		})
+	}
+	for _, fn := range ends {
+		fn(s)
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return s, true
+}
+
+// Run ends the session when its time is up and writes the reports of the
+// ended sessions, every interval and as soon as one is closed.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx, time.Now())
+		select {
+		case <-ticker.C:
+		case <-m.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) check(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	m.checked = now
+	var due time.Time
+	if m.current != nil {
+		due, _ = time.Parse(time.RFC3339, m.current.EndsAt)
+	}
+	m.mu.Unlock()
+
+	if !due.IsZero() {
+		if now.Before(due) {
+			m.remaining.Set(due.Sub(now).Seconds())
+		} else {
+			m.end(ctx, EndExpired, due)
+		}
+	}
+	m.report(ctx)
+}
+
+// report writes the report of every ended session that has none yet.
+func (m *Manager) report(ctx context.Context) {
+	m.mu.RLock()
+	reporter := m.reporter
+	var todo []Session
+	for _, s := range m.past {
+		if s.Report == "" && s.ReportError == "" {
+			todo = append(todo, s)
+		}
+	}
+	m.mu.RUnlock()
+	if reporter == nil || len(todo) == 0 {
+		return
+	}
+
+	for _, s := range todo {
+		name, errText := s.ID+".md", ""
+		data, err := reporter(ctx, s)
+		if err == nil {
+			err = writeFile(filepath.Join(m.opts.Dir, name), data)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return // retried at the next start
+			}
+			name, errText = "", err.Error()
+			log.Printf("⚠️  Lab session %s: report: %v", s.ID, err)
+		} else {
+			log.Printf("📝 Lab session %s: report written to %s", s.ID, filepath.Join(m.opts.Dir, name))
+		}
+		m.mu.Lock()
+		for i := range m.past {
+			if m.past[i].ID == s.ID {
+				m.past[i].Report, m.past[i].ReportError = name, errText
+			}
+		}
+		m.mu.Unlock()
+	}
+	if err := m.save(); err != nil {
+		log.Printf("⚠️  Lab session: %v", err)
+	}
+}
+
+func (m *Manager) annotate(ctx context.Context, a grafana.Annotation) {
+	if m.opts.Grafana == nil {
+		return
+	}
+	if _, err := m.opts.Grafana.CreateAnnotation(ctx, a); err != nil {
+		log.Printf("⚠️  Lab session: Grafana annotation failed: %v", err)
+	}
+}
+
+// Current returns the open session.
+func (m *Manager) Current() (Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.current == nil {
+		return Session{}, false
+	}
+	return *m.current, true
+}
+
+// Labels returns the series label of the open session, or nil outside
+// sessions.
+func (m *Manager) Labels() map[string]string {
+	s, ok := m.Current()
+	if !ok {
+		return nil
+	}
+	return map[string]string{m.opts.Label: s.ID}
+}
+
+// Gate returns a gate that is open when next is and, with Options.Gate,
+// only during a session.
+func (m *Manager) Gate(next func() bool) func() bool {
+	return func() bool {
+		if !next() {
+			return false
+		}
+		if !m.opts.Gate {
+			return true
+		}
+		_, ok := m.Current()
+		return ok
+	}
+}
+
+// Report opens the report of session id. ok is false when the session is
+// unknown or its report is not written yet.
+func (m *Manager) Report(id string) (*os.File, bool) {
+	m.mu.RLock()
+	var name string
+	for _, s := range m.past {
+		if s.ID == id {
+			name = s.Report
+		}
+	}
+	m.mu.RUnlock()
+	if name == "" {
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(m.opts.Dir, name))
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// Status returns the open session and the past ones.
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	st := Status{
+		Dir:        m.opts.Dir,
+		Label:      m.opts.Label,
+		Gate:       m.opts.Gate,
+		Collecting: !m.opts.Gate || m.current != nil,
+		Sessions:   append([]Session{}, m.past...),
+	}
+	if m.current != nil {
+		s := *m.current
+		st.Active = &s
+		if due, err := time.Parse(time.RFC3339, s.EndsAt); err == nil && time.Until(due) > 0 {
+			st.RemainingSeconds = time.Until(due).Round(time.Second).Seconds()
+		}
+	}
+	return st
+}
+
+// Freshness reports when om_lab_session_remaining_seconds was last
+// updated.
+func (m *Manager) Freshness() map[string]time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_lab_session_remaining_seconds": m.checked}
+}
+
+func (m *Manager) save() error {
+	m.mu.RLock()
+	state := struct {
+		Active   *Session  `json:"active"`
+		Sessions []Session `json:"sessions"`
+	}{m.current, m.past}
+	data, err := json.MarshalIndent(state, "", "  ")
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.opts.Dir, stateFile), data)
+}
+
+func writeFile(p string, data []byte) error {
+	if err := os.WriteFile(p+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// cleanGroups trims groups and drops the empty and repeated ones.
+func cleanGroups(groups []string) []string {
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		if g = strings.TrimSpace(g); g != "" && !seen[g] {
+			seen[g] = true
+			out = append(out, g)
+		}
+	}
+	return out
+}
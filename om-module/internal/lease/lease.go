@@ -0,0 +1,233 @@
+// Package lease keeps two modules from generating files into the same
+// shared volumes. A module that starts holds a lease: a small JSON file in
+// OUTPUT_DIR naming it, which it renews every third of the lease's time to
+// live. A second module pointed at the same volume finds the lease held and
+// refuses to start, naming the holder, until the lease expires or it is
+// started with takeover; that includes a second module on the same host
+// or in the same container, as long as the holder's process is alive. A
+// holder that finds the lease taken over stops.
+//
+// The lease is a file rather than a lock so that it works on any shared
+// volume and can be taken over; two modules starting in the same instant
+// may both write it, in which case the next renewal tells the loser.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Holder is the content of the lease file.
+type Holder struct {
+	ID       string    `json:"id"`
+	Host     string    `json:"host"` // the container ID under Docker
+	PID      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+	Renewed  time.Time `json:"renewed"`
+	Expires  time.Time `json:"expires"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d, since %s)", h.Host, h.PID, h.Acquired.Format(time.RFC3339))
+}
+
+// HeldError is returned by Acquire when another module holds the lease.
+type HeldError struct {
+	Path   string
+	Holder Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("another orchestrator instance is active: %s holds %s until %s (renewed %s ago); stop it, or start this one with --takeover (LEASE_TAKEOVER=true)",
+		e.Holder, e.Path, e.Holder.Expires.Format(time.RFC3339), time.Since(e.Holder.Renewed).Round(time.Second))
+}
+
+// ErrLost is returned by Run when another module took the lease over.
+var ErrLost = errors.New("lease taken over by another orchestrator instance")
+
+// Options configures the lease.
+type Options struct {
+	// Path is the lease file, on the volume the modules share.
+	Path string
+	// TTL is how long the lease is held without renewal.
+	TTL time.Duration
+	// Takeover acquires the lease even if another module holds it.
+	Takeover bool
+}
+
+// Status is the API view of the lease.
+type Status struct {
+	Path         string  `json:"path"`
+	Holder       Holder  `json:"holder"`
+	TakenOver    *Holder `json:"taken_over,omitempty"` // the holder this module replaced
+	RenewalError string  `json:"renewal_error,omitempty"`
+}
+
+// Lease is a held lease.
+type Lease struct {
+	opts Options
+
+	mu        sync.Mutex
+	self      Holder
+	takenOver *Holder
+	lastErr   error
+}
+
+// Acquire takes the lease at opts.Path. It fails with a *HeldError if
+// another module holds an unexpired lease and opts.Takeover is not set. A
+// lease left on the same host by a process that is gone, such as the
+// previous run of a restarted container, is taken back without waiting for
+// it to expire.
+func Acquire(opts Options) (*Lease, error) {
+	host, _ := os.Hostname()
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	l := &Lease{opts: opts, self: Holder{
+		ID:       hex.EncodeToString(id),
+		Host:     host,
+		PID:      os.Getpid(),
+		Acquired: now,
+		Renewed:  now,
+		Expires:  now.Add(opts.TTL),
+	}}
+
+	prev, err := read(opts.Path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		// An unreadable lease is overwritten: it holds nothing.
+	case now.After(prev.Expires):
+	case prev.Host == host && !alive(prev.PID):
+	case opts.Takeover:
+		l.takenOver = &prev
+	default:
+		return nil, &HeldError{Path: opts.Path, Holder: prev}
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := write(opts.Path, l.self); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// alive reports whether process pid of this host is running. A holder with
+// this process's own PID is a previous run: a restarted container starts
+// its module with the same PID again.
+func alive(pid int) bool {
+	if pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// TakenOver returns the holder this module took the lease from, if any.
+func (l *Lease) TakenOver() *Holder {
+	return l.takenOver
+}
+
+// Run renews the lease until ctx is done. It returns ErrLost as soon as it
+// finds another module's ID in the lease file.
+func (l *Lease) Run(ctx context.Context) error {
+	ticker := time.NewTicker(l.opts.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.renew(); err != nil {
+				if errors.Is(err, ErrLost) {
+					return err
+				}
+				l.mu.Lock()
+				l.lastErr = err
+				l.mu.Unlock()
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (l *Lease) renew() error {
+	cur, err := read(l.opts.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil && cur.ID != l.self.ID {
+		return fmt.Errorf("%w: %s", ErrLost, cur)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	self := l.self
+	self.Renewed, self.Expires = now, now.Add(l.opts.TTL)
+	if err := write(l.opts.Path, self); err != nil {
+		return err
+	}
+	l.self, l.lastErr = self, nil
+	return nil
+}
+
+// Release removes the lease file if it is still this module's, so the next
+// module starts without waiting for it to expire.
+func (l *Lease) Release() {
+	if cur, err := read(l.opts.Path); err == nil && cur.ID == l.self.ID {
+		_ = os.Remove(l.opts.Path)
+	}
+}
+
+// Status returns the lease as held.
+func (l *Lease) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := Status{Path: l.opts.Path, Holder: l.self, TakenOver: l.takenOver}
+	if l.lastErr != nil {
+		s.RenewalError = l.lastErr.Error()
+	}
+	return s
+}
+
+func read(p string) (Holder, error) {
+	var h Holder
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h); err != nil {
+		return h, fmt.Errorf("%s: %w", p, err)
+	}
+	return h, nil
+}
+
+// write replaces the lease file through a rename, so a reader never sees
+// half of it.
+func write(p string, h Holder) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp-" + h.ID
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package lease
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary act as a second module: TestHelperAcquire
+// acquires the lease at its path and exits 0, or prints the error and exits 3.
+const helperEnv = "LEASE_TEST_HELPER_PATH"
+
+func TestHelperAcquire(t *testing.T) {
+	path := os.Getenv(helperEnv)
+	if path == "" {
+		t.Skip("helper process only")
+	}
+	_, err := Acquire(Options{Path: path, TTL: time.Minute, Takeover: os.Getenv("LEASE_TEST_HELPER_TAKEOVER") != ""})
+	if err != nil {
+		os.Stdout.WriteString(err.Error())
+		os.Exit(3)
+	}
+	os.Exit(0)
+}
+
+// acquireFromProcess runs Acquire in a second process on this host.
+func acquireFromProcess(t *testing.T, path string, takeover bool) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperAcquire$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+path)
+	if takeover {
+		cmd.Env = append(cmd.Env, "LEASE_TEST_HELPER_TAKEOVER=1")
+	}
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func TestAcquireSameHost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "om-module.lease")
+	first, err := Acquire(Options{Path: path, TTL: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := acquireFromProcess(t, path, false)
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) || exit.ExitCode() != 3 {
+		t.Fatalf("second module on the same host: %v (%s), want it refused", err, out)
+	}
+	if !strings.Contains(out, "another orchestrator instance is active") {
+		t.Fatalf("second module on the same host: %q, want the held error", out)
+	}
+	if err := first.renew(); err != nil {
+		t.Fatalf("first module lost its lease to a refused one: %v", err)
+	}
+
+	if out, err := acquireFromProcess(t, path, true); err != nil {
+		t.Fatalf("second module with takeover: %v (%s)", err, out)
+	}
+	if err := first.renew(); !errors.Is(err, ErrLost) {
+		t.Fatalf("first module after a takeover: renew = %v, want ErrLost", err)
+	}
+}
+
+func TestAcquireTakesBackDeadHolder(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	tests := []struct {
+		name   string
+		holder Holder
+	}{
+		{"exited process", Holder{ID: "gone", Host: host, PID: cmd.Process.Pid, Expires: now.Add(time.Minute)}},
+		{"own PID, restarted container", Holder{ID: "before", Host: host, PID: os.Getpid(), Expires: now.Add(time.Minute)}},
+		{"expired, other host", Holder{ID: "old", Host: "elsewhere", PID: 1, Expires: now.Add(-time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "om-module.lease")
+			if err := write(path, tt.holder); err != nil {
+				t.Fatal(err)
+			}
+			l, err := Acquire(Options{Path: path, TTL: time.Minute})
+			if err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+			if l.TakenOver() != nil {
+				t.Errorf("taken over from %s, want taken back", l.TakenOver())
+			}
+		})
+	}
+}
+
+func TestAcquireOtherHostHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "om-module.lease")
+	other := Holder{ID: "other", Host: "elsewhere", PID: 1, Expires: time.Now().Add(time.Minute)}
+	if err := write(path, other); err != nil {
+		t.Fatal(err)
+	}
+	var held *HeldError
+	if _, err := Acquire(Options{Path: path, TTL: time.Minute}); !errors.As(err, &held) {
+		t.Fatalf("Acquire = %v, want *HeldError", err)
+	}
+	l, err := Acquire(Options{Path: path, TTL: time.Minute, Takeover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk := l.TakenOver(); tk == nil || tk.ID != "other" {
+		t.Fatalf("taken over from %v, want the other host's holder", tk)
+	}
+}
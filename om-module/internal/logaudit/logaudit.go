@@ -0,0 +1,611 @@
+// Package logaudit checks that the Open5GS NFs of the lab log where the
+// log pipeline reads. Promtail only sees the *.log files of the shared log
+// volume, mounted in every NF at /open5gs/install/var/log/open5gs; an NF
+// whose configuration has no logger.file, or points it elsewhere, logs to
+// stderr or to a file nobody reads, and its lines never reach Loki.
+//
+// The Auditor looks into every running Open5GS container each interval:
+// the daemon PID 1 runs and its command line (-c, -l, -e), the logger
+// section of the configuration it was started with, whether the log
+// directory is a volume and whether the log file exists. A component whose
+// logs will not be collected can be fixed: the logger section of its
+// configuration is rewritten to log to the volume at a chosen level, in the
+// file under /mnt the container's init script copies it from when there is
+// one, and the container is restarted.
+package logaudit
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+// ProjectOpen5GS is the om.project label of Open5GS containers.
+const ProjectOpen5GS = "open5gs"
+
+// DefaultLevel is the level of an Open5GS daemon without logger.level.
+const DefaultLevel = "info"
+
+// Levels are the Open5GS log levels, least verbose first.
+var Levels = []string{"fatal", "error", "warn", "info", "debug", "trace"}
+
+// stopTimeout is how long a restart lets the NF shut down before Docker
+// kills it.
+const stopTimeout = 10 * time.Second
+
+// marker separates the parts of the output of procScript.
+const marker = "--om--"
+
+// procScript prints the command line and working directory of PID 1, the
+// number of mounts on the log directory ($1) and the files in it.
+const procScript = `tr '\0' '\n' </proc/1/cmdline; echo ` + marker + `
+readlink /proc/1/cwd; echo ` + marker + `
+grep -c " $1 " /proc/1/mounts; echo ` + marker + `
+ls -1 "$1" 2>/dev/null`
+
+// daemonRe matches the binary of an Open5GS NF daemon, e.g. open5gs-amfd.
+var daemonRe = regexp.MustCompile(`^open5gs-([a-z0-9]+)d$`)
+
+// Component is the last audit of one Open5GS container.
+type Component struct {
+	Container string `json:"container"`
+	NF        string `json:"nf,omitempty"`
+	Daemon    string `json:"daemon"`
+	// Config is the configuration the daemon runs, Source the file under
+	// /mnt it is copied from at start ("" when none was found).
+	Config  string `json:"config"`
+	Source  string `json:"source,omitempty"`
+	LogFile string `json:"log_file,omitempty"` // "" logs to stderr only
+	Level   string `json:"level"`
+	// CommandLine is set when -l, -e, -d or -t on the daemon's command
+	// line override the configuration, which a fix cannot change.
+	CommandLine bool `json:"command_line,omitempty"`
+	// Collected is true when Promtail reads the daemon's log file;
+	// Problems tell why not.
+	Collected bool     `json:"collected"`
+	Problems  []string `json:"problems,omitempty"`
+	// Error is set when the container could not be audited.
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checked_at"`
+	LastFix   *Fix   `json:"last_fix,omitempty"`
+}
+
+// Fix is the outcome of rewriting the logger section of a component.
+type Fix struct {
+	Time      string `json:"time"`
+	File      string `json:"file"`
+	LogFile   string `json:"log_file"`
+	Level     string `json:"level"`
+	Automatic bool   `json:"automatic,omitempty"`
+	Restarted bool   `json:"restarted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Status is the API view of the auditor.
+type Status struct {
+	LogDir     string      `json:"log_dir"`
+	Interval   string      `json:"interval"`
+	AutoFix    bool        `json:"auto_fix"`
+	Level      string      `json:"level"`
+	UpdatedAt  string      `json:"updated_at,omitempty"`
+	Collected  int         `json:"collected"`
+	Components []Component `json:"components"`
+}
+
+// Options configure the auditor.
+type Options struct {
+	// LogDir is where the NFs must log: the shared log volume Promtail
+	// reads, as mounted in the NF containers.
+	LogDir   string
+	Interval time.Duration
+	// AutoFix fixes every component whose logs are not collected, once
+	// per container, at Level.
+	AutoFix bool
+	// Level is the level fixes write when none is asked for.
+	Level string
+}
+
+// ErrUnknown is returned by Fix for a container the last audit did not
+// find.
+var ErrUnknown = errors.New("not an audited Open5GS container")
+
+// ErrNotFixable is wrapped by the error Fix returns when the component
+// cannot be fixed by rewriting its configuration.
+var ErrNotFixable = errors.New("cannot be fixed")
+
+// Auditor audits the logger configuration of the Open5GS containers.
+type Auditor struct {
+	docker *dockerclient.Client
+	snap   *collector.Snapshot
+	opts   Options
+
+	collected *prometheus.GaugeVec
+	fixes     *prometheus.CounterVec
+
+	mu         sync.RWMutex
+	components map[string]Component // keyed by container name
+	autoFixed  map[string]bool      // container IDs fixed automatically
+	checked    time.Time
+}
+
+// New registers om_logging_audit_collected and om_logging_audit_fixes_total
+// on reg.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, opts Options) *Auditor {
+	a := &Auditor{
+		docker: docker,
+		snap:   snap,
+		opts:   opts,
+		collected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "logging_audit", Name: "collected",
+			Help: "1 if the Open5GS NF logs to a file the log pipeline reads, 0 otherwise.",
+		}, []string{"container"}),
+		fixes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "logging_audit", Name: "fixes_total",
+			Help: "Logger configurations rewritten by the module, by result (ok|failed).",
+		}, []string{"container", "result"}),
+		components: make(map[string]Component),
+		autoFixed:  make(map[string]bool),
+	}
+	reg.MustRegister(a.collected, a.fixes)
+	return a
+}
+
+// ValidLevel reports whether level is an Open5GS log level.
+func ValidLevel(level string) bool {
+	return slices.Contains(Levels, level)
+}
+
+// Run audits the containers every interval until ctx is cancelled.
+func (a *Auditor) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+	for {
+		a.check(ctx)
+		if a.opts.AutoFix {
+			a.autoFix(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the last audit of every Open5GS container, those whose
+// logs are not collected first.
+func (a *Auditor) Status() Status {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	st := Status{
+		LogDir:     a.opts.LogDir,
+		Interval:   a.opts.Interval.String(),
+		AutoFix:    a.opts.AutoFix,
+		Level:      a.opts.Level,
+		Components: make([]Component, 0, len(a.components)),
+	}
+	if !a.checked.IsZero() {
+		st.UpdatedAt = a.checked.UTC().Format(time.RFC3339)
+	}
+	for _, c := range a.components {
+		c.Problems = slices.Clone(c.Problems)
+		if c.LastFix != nil {
+			f := *c.LastFix
+			c.LastFix = &f
+		}
+		if c.Collected {
+			st.Collected++
+		}
+		st.Components = append(st.Components, c)
+	}
+	sort.Slice(st.Components, func(i, j int) bool {
+		ci, cj := st.Components[i], st.Components[j]
+		if ci.Collected != cj.Collected {
+			return !ci.Collected
+		}
+		return ci.Container < cj.Container
+	})
+	return st
+}
+
+// Freshness returns when om_logging_audit_collected was last refreshed,
+// for exporter.Ages.
+func (a *Auditor) Freshness() map[string]time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if len(a.components) == 0 || a.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_logging_audit_collected": a.checked}
+}
+
+// targets returns the running Open5GS containers of the snapshot.
+func (a *Auditor) targets() map[string]*collector.ContainerData {
+	out := make(map[string]*collector.ContainerData)
+	for name, cd := range a.snap.All() {
+		if cd.Project == ProjectOpen5GS && cd.State == "running" {
+			out[name] = cd
+		}
+	}
+	return out
+}
+
+func (a *Auditor) check(ctx context.Context) {
+	targets := a.targets()
+	audited := make(map[string]Component, len(targets))
+	for name, cd := range targets {
+		c, ok := a.audit(ctx, cd)
+		if ctx.Err() != nil {
+			return
+		}
+		if ok {
+			audited[name] = c
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, prev := range a.components {
+		if _, ok := audited[name]; !ok {
+			a.collected.DeleteLabelValues(name)
+			continue
+		}
+		c := audited[name]
+		c.LastFix = prev.LastFix
+		audited[name] = c
+	}
+	for name, c := range audited {
+		if !c.Collected && (a.components[name].Collected || a.components[name].Container == "") && c.Error == "" {
+			log.Printf("⚠️  Logs of %s will not reach Loki: %s", name, strings.Join(c.Problems, "; "))
+		}
+		v := 0.0
+		if c.Collected {
+			v = 1
+		}
+		a.collected.WithLabelValues(name).Set(v)
+	}
+	a.components = audited
+	a.checked = time.Now()
+}
+
+// audit looks into one container. ok is false when PID 1 is not an
+// Open5GS daemon (the WebUI, a database), which is left out.
+func (a *Auditor) audit(ctx context.Context, cd *collector.ContainerData) (Component, bool) {
+	c := Component{Container: cd.Name, NF: cd.NF, Level: DefaultLevel, CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+	out, _, err := a.docker.Exec(ctx, cd.Name, []string{"sh", "-c", procScript, "sh", a.opts.LogDir})
+	parts := strings.Split(out, marker+"\n")
+	if err != nil || len(parts) != 4 {
+		if err == nil {
+			err = fmt.Errorf("unexpected output %q", out)
+		}
+		c.Error = fmt.Sprintf("inspect PID 1: %v", err)
+		return c, true
+	}
+	args := lines(parts[0])
+	if len(args) == 0 || !daemonRe.MatchString(path.Base(args[0])) {
+		return c, false
+	}
+	c.Daemon = path.Base(args[0])
+	cwd := strings.TrimSpace(parts[1])
+	mounted := strings.TrimSpace(parts[2]) != "0"
+	files := lines(parts[3])
+
+	cl := parseArgs(args, cwd)
+	c.Config = cl.config
+	cfgData, code, err := a.docker.Exec(ctx, cd.Name, []string{"cat", c.Config})
+	if err != nil || code != 0 {
+		c.Error = fmt.Sprintf("read %s: exit %d, %v", c.Config, code, err)
+		return c, true
+	}
+	logger, err := parseLogger([]byte(cfgData))
+	if err != nil {
+		c.Error = fmt.Sprintf("%s: %v", c.Config, err)
+		return c, true
+	}
+	c.LogFile, c.Level = logger.file, logger.level
+	if cl.logFile != "" {
+		c.LogFile, c.CommandLine = cl.logFile, true
+	}
+	if cl.level != "" {
+		c.Level, c.CommandLine = cl.level, true
+	}
+	if c.Level == "" {
+		c.Level = DefaultLevel
+	}
+	if src, _, err := a.docker.Exec(ctx, cd.Name, []string{"sh", "-c", `ls -1 /mnt/*/"$1" 2>/dev/null`, "sh", path.Base(c.Config)}); err == nil {
+		if found := lines(src); len(found) == 1 {
+			c.Source = found[0]
+		}
+	}
+
+	c.Problems = a.problems(c.LogFile, mounted, files)
+	c.Collected = len(c.Problems) == 0
+	return c, true
+}
+
+// problems tells why a daemon logging to logFile is not collected. mounted
+// reports whether the log directory is a volume, files lists its files.
+func (a *Auditor) problems(logFile string, mounted bool, files []string) []string {
+	var out []string
+	switch {
+	case logFile == "":
+		return []string{"no logger.file: logs go to stderr only, which the log pipeline does not read"}
+	case path.Dir(logFile) != path.Clean(a.opts.LogDir):
+		out = append(out, fmt.Sprintf("logs to %s, outside %s", logFile, a.opts.LogDir))
+	case !strings.HasSuffix(logFile, ".log"):
+		out = append(out, fmt.Sprintf("%s is not a *.log file, which Promtail skips", path.Base(logFile)))
+	case !slices.Contains(files, path.Base(logFile)):
+		out = append(out, fmt.Sprintf("%s does not exist", logFile))
+	}
+	if !mounted {
+		out = append(out, fmt.Sprintf("%s is not a volume: Promtail cannot see it", a.opts.LogDir))
+	}
+	return out
+}
+
+// commandLine is what the daemon's arguments say about its logging.
+type commandLine struct {
+	config  string
+	logFile string
+	level   string
+}
+
+// parseArgs reads -c, -l, -e, -d and -t from the arguments of an Open5GS
+// daemon started in cwd. Without -c the daemon reads <prefix>/etc/open5gs/
+// <nf>.yaml, <prefix> being the parent of the directory of the binary.
+func parseArgs(args []string, cwd string) commandLine {
+	abs := func(p string) string {
+		if path.IsAbs(p) {
+			return path.Clean(p)
+		}
+		return path.Join(cwd, p)
+	}
+	var cl commandLine
+	for i := 1; i < len(args); i++ {
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		switch args[i] {
+		case "-c":
+			cl.config = abs(next)
+			i++
+		case "-l":
+			cl.logFile = abs(next)
+			i++
+		case "-e":
+			cl.level = next
+			i++
+		case "-d":
+			cl.level = "debug"
+		case "-t":
+			cl.level = "trace"
+		}
+	}
+	if cl.config == "" {
+		bin := abs(args[0])
+		nf := daemonRe.FindStringSubmatch(path.Base(bin))[1]
+		cl.config = path.Join(path.Dir(path.Dir(bin)), "etc", "open5gs", nf+".yaml")
+	}
+	return cl
+}
+
+// ConfigPath returns the configuration an Open5GS daemon started with args
+// in cwd reads, as for PID 1 of an NF container; ok is false when args[0]
+// is not an Open5GS daemon.
+func ConfigPath(args []string, cwd string) (config string, ok bool) {
+	if len(args) == 0 || !daemonRe.MatchString(path.Base(args[0])) {
+		return "", false
+	}
+	return parseArgs(args, cwd).config, true
+}
+
+type loggerSection struct {
+	file  string
+	level string
+}
+
+// parseLogger reads the logger section of an Open5GS configuration. The
+// file is logger.file.path, or logger.file itself in configurations older
+// than Open5GS 2.7.
+func parseLogger(data []byte) (loggerSection, error) {
+	var cfg struct {
+		Logger struct {
+			File  interface{} `yaml:"file"`
+			Level string      `yaml:"level"`
+		} `yaml:"logger"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return loggerSection{}, err
+	}
+	s := loggerSection{level: cfg.Logger.Level}
+	switch f := cfg.Logger.File.(type) {
+	case string:
+		s.file = f
+	case map[interface{}]interface{}:
+		s.file, _ = f["path"].(string)
+	}
+	return s, nil
+}
+
+// PatchLogger sets logger.file.path and logger.level of an Open5GS
+// configuration, keeping the rest of the file, comments included, and the
+// other keys of the logger section. A configuration without a logger
+// section gets one at the top.
+func PatchLogger(src, logFile, level string) string {
+	lines := strings.Split(src, "\n")
+	start := -1
+	for i, l := range lines {
+		if strings.HasPrefix(l, "logger:") {
+			start = i
+			break
+		}
+	}
+	section := func(indent string) []string {
+		return []string{"logger:", indent + "file:", indent + indent + "path: " + logFile, indent + "level: " + level}
+	}
+	if start < 0 {
+		return strings.Join(append(section("  "), append([]string{""}, lines...)...), "\n")
+	}
+	if rest := strings.TrimSpace(strings.TrimPrefix(lines[start], "logger:")); rest != "" && !strings.HasPrefix(rest, "#") {
+		// A flow mapping on one line: replace it whole.
+		return strings.Join(slices.Concat(lines[:start], section("  "), lines[start+1:]), "\n")
+	}
+
+	// The section runs until the next line at the top level; blank and
+	// comment lines at its end belong to what follows.
+	end := start + 1
+	for end < len(lines) && (strings.TrimSpace(lines[end]) == "" || indentOf(lines[end]) > 0 || strings.HasPrefix(lines[end], "#")) {
+		end++
+	}
+	for end > start+1 && (strings.TrimSpace(lines[end-1]) == "" || strings.HasPrefix(strings.TrimSpace(lines[end-1]), "#")) {
+		end--
+	}
+	child := -1
+	for _, l := range lines[start+1 : end] {
+		if t := strings.TrimSpace(l); t != "" && !strings.HasPrefix(t, "#") {
+			child = indentOf(l)
+			break
+		}
+	}
+	if child <= 0 {
+		child = 2
+	}
+	kept := make([]string, 0, end-start)
+	dropping := false
+	for _, l := range lines[start+1 : end] {
+		t := strings.TrimSpace(l)
+		if t != "" && !strings.HasPrefix(t, "#") && indentOf(l) <= child {
+			dropping = strings.HasPrefix(t, "file:") || strings.HasPrefix(t, "level:")
+		}
+		if !dropping {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(slices.Concat(lines[:start], section(strings.Repeat(" ", child)), kept, lines[end:]), "\n")
+}
+
+func indentOf(l string) int {
+	return len(l) - len(strings.TrimLeft(l, " \t"))
+}
+
+func lines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Fix rewrites the logger section of the configuration of container to log
+// to the log directory at level (the configured level when ""), and
+// restarts the container. The file is named after the container, which
+// is the nf label Promtail gives its lines.
+func (a *Auditor) Fix(ctx context.Context, container, level string) (Component, error) {
+	return a.fix(ctx, container, level, false)
+}
+
+func (a *Auditor) fix(ctx context.Context, container, level string, automatic bool) (Component, error) {
+	if level == "" {
+		level = a.opts.Level
+	}
+	if !ValidLevel(level) {
+		return Component{}, fmt.Errorf("level must be one of %s", strings.Join(Levels, ", "))
+	}
+	a.mu.RLock()
+	c, ok := a.components[container]
+	a.mu.RUnlock()
+	switch {
+	case !ok:
+		return Component{}, fmt.Errorf("%s: %w", container, ErrUnknown)
+	case c.Error != "":
+		return c, fmt.Errorf("%s: %w: the last audit failed: %s", container, ErrNotFixable, c.Error)
+	case c.CommandLine:
+		return c, fmt.Errorf("%s: %w: logging is set on the command line of %s", container, ErrNotFixable, c.Daemon)
+	}
+
+	file := c.Source
+	if file == "" {
+		file = c.Config
+	}
+	f := Fix{Time: time.Now().UTC().Format(time.RFC3339), File: file, LogFile: path.Join(a.opts.LogDir, container+".log"), Level: level, Automatic: automatic}
+	err := a.rewrite(ctx, container, f)
+	if err == nil {
+		f.Restarted = true
+		if err = a.docker.Restart(ctx, container, stopTimeout); err != nil {
+			f.Restarted = false
+			err = fmt.Errorf("restart %s: %w", container, err)
+		}
+	}
+	result := "ok"
+	if err != nil {
+		f.Error, result = err.Error(), "failed"
+	}
+	a.fixes.WithLabelValues(container, result).Inc()
+	log.Printf("🪵 Logger of %s set to %s at %s in %s (restarted %v)", container, f.LogFile, level, file, f.Restarted)
+
+	a.mu.Lock()
+	if cur, ok := a.components[container]; ok {
+		cur.LastFix = &f
+		a.components[container] = cur
+		c = cur
+	}
+	a.mu.Unlock()
+	return c, err
+}
+
+// rewrite patches the file of f inside container.
+func (a *Auditor) rewrite(ctx context.Context, container string, f Fix) error {
+	src, code, err := a.docker.Exec(ctx, container, []string{"cat", f.File})
+	if err != nil || code != 0 {
+		return fmt.Errorf("read %s: exit %d, %v", f.File, code, err)
+	}
+	patched := PatchLogger(src, f.LogFile, f.Level)
+	if got, err := parseLogger([]byte(patched)); err != nil || got.file != f.LogFile || got.level != f.Level {
+		return fmt.Errorf("%s: %w: the logger section could not be rewritten", f.File, ErrNotFixable)
+	}
+	out, code, err := a.docker.Exec(ctx, container, []string{"sh", "-c", `printf %s "$1" | base64 -d > "$2"`, "sh",
+		base64.StdEncoding.EncodeToString([]byte(patched)), f.File})
+	if err != nil || code != 0 {
+		return fmt.Errorf("write %s: exit %d, %v %s", f.File, code, err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// autoFix fixes, once per container, every component whose logs are not
+// collected.
+func (a *Auditor) autoFix(ctx context.Context) {
+	targets := a.targets()
+	for _, c := range a.Status().Components {
+		cd := targets[c.Container]
+		if c.Collected || c.Error != "" || c.CommandLine || cd == nil {
+			continue
+		}
+		a.mu.Lock()
+		done := a.autoFixed[cd.ID]
+		a.autoFixed[cd.ID] = true
+		a.mu.Unlock()
+		if done {
+			continue
+		}
+		if _, err := a.fix(ctx, c.Container, "", true); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️  Log audit: cannot fix %s: %v", c.Container, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Package logbuffer keeps the most recent lines the module logged, so they
+// can be included in debug bundles without access to `docker logs`.
+package logbuffer
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Buffer is an io.Writer that retains the last lines written to it. Install
+// it next to the normal output with log.SetOutput(io.MultiWriter(...)).
+type Buffer struct {
+	mu      sync.Mutex
+	lines   [][]byte
+	next    int // ring position of the oldest line once full
+	full    bool
+	partial []byte // unterminated tail of the last write
+}
+
+// New returns a buffer that keeps up to size lines.
+func New(size int) *Buffer {
+	return &Buffer{lines: make([][]byte, size)}
+}
+
+// Write stores every complete line of p; a trailing partial line is kept
+// until its newline arrives. It never fails.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.add(data[:i+1])
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (b *Buffer) add(line []byte) {
+	if len(b.lines) == 0 {
+		return
+	}
+	b.lines[b.next] = append([]byte(nil), line...)
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Bytes returns the retained lines, oldest first.
+func (b *Buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out bytes.Buffer
+	if b.full {
+		for _, l := range b.lines[b.next:] {
+			out.Write(l)
+		}
+	}
+	for _, l := range b.lines[:b.next] {
+		out.Write(l)
+	}
+	out.Write(b.partial)
+	return out.Bytes()
+}
@@ -0,0 +1,466 @@
+// Package logexport keeps a local copy of the Open5GS logs as files some
+// assignments ask for. Promtail ships the *.log files of the shared log
+// volume to Loki; the Exporter follows the same files every interval and
+// appends each new line, parsed into its time stamp, level, module, message
+// and source location, as one JSON object to <dir>/<generation>/<nf>.jsonl.
+// A file that reaches MaxBytes is rotated to <nf>.jsonl.1, the older ones
+// shift up and at most MaxFiles rotated files are kept per component.
+//
+// How far each log file has been read is kept in <dir>/positions.json, so a
+// restarted module picks up where it stopped; a log file that shrank was
+// truncated or recreated and is read again from its start.
+package logexport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/logtime"
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// positionsFile keeps the read offsets of the source logs in Dir.
+const positionsFile = "positions.json"
+
+// Extension is the suffix of the exported files, before any rotation
+// number.
+const Extension = ".jsonl"
+
+// maxRead bounds what is read from one log file per interval, so a large
+// backlog is exported over several intervals instead of at once.
+const maxRead = 8 << 20
+
+// Options configure the exporter.
+type Options struct {
+	// SourceDir holds the Open5GS logs as <generation>/<nf>.log.
+	SourceDir string
+	Dir       string
+	// MaxBytes is the size at which an exported file is rotated, MaxFiles
+	// how many rotated files are kept per component.
+	MaxBytes int64
+	MaxFiles int
+	Interval time.Duration
+	// Stamps dates the "MM/DD hh:mm:ss.mmm" stamps of the lines; without
+	// it a line is stamped when it was read.
+	Stamps *logtime.Inferrer
+	// Redact is applied to every message before it is written.
+	Redact *redact.Redactor
+}
+
+// Entry is one exported log line.
+type Entry struct {
+	Time       string `json:"time"`
+	Generation string `json:"generation"`
+	NF         string `json:"nf"`
+	Level      string `json:"level,omitempty"`
+	Module     string `json:"module,omitempty"`
+	Message    string `json:"message"`
+	Source     string `json:"source,omitempty"`
+}
+
+// File is an exported file, by its path relative to Dir.
+type File struct {
+	Name       string `json:"name"`
+	Generation string `json:"generation"`
+	NF         string `json:"nf"`
+	Size       int64  `json:"size"`
+	Modified   string `json:"modified"`
+}
+
+// Component is the export of one Open5GS log file.
+type Component struct {
+	Generation string `json:"generation"`
+	NF         string `json:"nf"`
+	Source     string `json:"source"`
+	Offset     int64  `json:"offset"`
+	Lines      uint64 `json:"lines"`
+	Rotations  uint64 `json:"rotations"`
+	LastLine   string `json:"last_line,omitempty"`
+}
+
+// Status is the API view of the exporter.
+type Status struct {
+	SourceDir  string      `json:"source_dir"`
+	Dir        string      `json:"dir"`
+	MaxBytes   int64       `json:"max_bytes"`
+	MaxFiles   int         `json:"max_files"`
+	Components []Component `json:"components"`
+	Checked    string      `json:"checked,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// component is the state of one followed log file.
+type component struct {
+	Component
+	last time.Time // stamp of the last parsed line, for continuation lines
+}
+
+// Exporter follows the Open5GS logs and writes them out as JSONL.
+type Exporter struct {
+	opts Options
+
+	lines     *prometheus.CounterVec
+	rotations *prometheus.CounterVec
+	bytes     *prometheus.GaugeVec
+
+	mu         sync.RWMutex
+	components map[string]*component // by <generation>/<nf>
+	checked    time.Time
+	lastErr    string
+}
+
+// New registers the om_log_export_* metrics on reg and returns the
+// exporter, with the read offsets a previous run left in opts.Dir.
+func New(reg prometheus.Registerer, opts Options) (*Exporter, error) {
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	e := &Exporter{
+		opts:       opts,
+		components: make(map[string]*component),
+		lines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "log_export", Name: "lines_total",
+			Help: "Open5GS log lines written to the local JSONL export, by component.",
+		}, []string{"generation", "nf"}),
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "log_export", Name: "rotations_total",
+			Help: "Rotations of the exported log files, by component.",
+		}, []string{"generation", "nf"}),
+		bytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "log_export", Name: "bytes",
+			Help: "Disk space used by the exported log files of a component, rotated ones included.",
+		}, []string{"generation", "nf"}),
+	}
+	reg.MustRegister(e.lines, e.rotations, e.bytes)
+	if data, err := os.ReadFile(filepath.Join(opts.Dir, positionsFile)); err == nil {
+		var offsets map[string]int64
+		if json.Unmarshal(data, &offsets) == nil {
+			for key, off := range offsets {
+				gen, nf, ok := strings.Cut(key, "/")
+				if !ok {
+					continue
+				}
+				e.components[key] = &component{Component: Component{
+					Generation: gen, NF: nf,
+					Source: filepath.Join(opts.SourceDir, gen, nf+".log"),
+					Offset: off,
+				}}
+			}
+		}
+	}
+	return e, nil
+}
+
+// Run exports the new log lines every interval until ctx is cancelled.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		err := e.tick()
+		e.mu.Lock()
+		first := err != nil && e.lastErr == ""
+		e.lastErr = ""
+		if err != nil {
+			e.lastErr = err.Error()
+		}
+		e.mu.Unlock()
+		if first {
+			log.Printf("⚠️  Log export: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tick exports what was appended to every log file since the last one and
+// saves the offsets reached.
+func (e *Exporter) tick() error {
+	sources, err := filepath.Glob(filepath.Join(e.opts.SourceDir, "*", "*.log"))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, src := range sources {
+		gen := filepath.Base(filepath.Dir(src))
+		nf := strings.TrimSuffix(filepath.Base(src), ".log")
+		if err := e.follow(gen, nf, src); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", gen, nf, err))
+		}
+	}
+	if err := e.savePositions(); err != nil {
+		errs = append(errs, err)
+	}
+	e.mu.Lock()
+	e.checked = time.Now()
+	e.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// follow exports the complete lines appended to src since its offset.
+func (e *Exporter) follow(gen, nf, src string) error {
+	key := gen + "/" + nf
+	e.mu.Lock()
+	c := e.components[key]
+	if c == nil {
+		c = &component{Component: Component{Generation: gen, NF: nf, Source: src}}
+		e.components[key] = c
+	}
+	offset := c.Offset
+	e.mu.Unlock()
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < offset {
+		offset = 0
+		e.mu.Lock()
+		c.Offset = 0
+		e.mu.Unlock()
+	}
+	if info.Size() == offset {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(io.NewSectionReader(f, offset, info.Size()-offset), maxRead))
+	if err != nil {
+		return err
+	}
+	// A partial last line is left for the next interval, unless it alone
+	// fills the read.
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		data = data[:i+1]
+	} else if len(data) < maxRead {
+		return nil
+	}
+
+	out, err := newSink(e.opts.Dir, gen, nf, e.opts.MaxBytes, e.opts.MaxFiles)
+	if err != nil {
+		return err
+	}
+	defer out.close()
+
+	var lines uint64
+	last := c.last
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64<<10), maxRead)
+	for sc.Scan() {
+		line := strings.TrimRight(ansiCodes.ReplaceAllString(sc.Text(), ""), "\r ")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry := e.parse(gen, nf, line, info.ModTime(), &last)
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := out.write(append(b, '\n')); err != nil {
+			return err
+		}
+		lines++
+	}
+
+	e.lines.WithLabelValues(gen, nf).Add(float64(lines))
+	e.rotations.WithLabelValues(gen, nf).Add(float64(out.rotations))
+	e.bytes.WithLabelValues(gen, nf).Set(float64(out.total()))
+	e.mu.Lock()
+	c.Offset = offset + int64(len(data))
+	c.Lines += lines
+	c.Rotations += uint64(out.rotations)
+	c.last = last
+	if !last.IsZero() {
+		c.LastLine = last.UTC().Format(time.RFC3339Nano)
+	}
+	e.mu.Unlock()
+	return nil
+}
+
+var (
+	ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// logLine is the "MM/DD hh:mm:ss.mmm: [module] LEVEL: message (source)"
+	// of an Open5GS log line; the source location is optional.
+	logLine = regexp.MustCompile(`^(\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d+):\s+\[(\w+)\]\s+(\w+):\s+(.*?)(?:\s+\(([^()]+:\d+)\))?$`)
+)
+
+// parse turns line into an Entry. A line without the Open5GS header, such
+// as a hex dump under a message, keeps the time of the line before it.
+func (e *Exporter) parse(gen, nf, line string, modified time.Time, last *time.Time) Entry {
+	entry := Entry{Generation: gen, NF: nf}
+	m := logLine.FindStringSubmatch(line)
+	if m == nil {
+		entry.Message = e.opts.Redact.Line(line)
+		if last.IsZero() {
+			*last = modified
+		}
+		entry.Time = last.UTC().Format(time.RFC3339Nano)
+		return entry
+	}
+	t := modified
+	if e.opts.Stamps != nil {
+		if st, err := e.opts.Stamps.Infer(m[1], modified); err == nil {
+			t = st
+		}
+	}
+	*last = t
+	entry.Time = t.UTC().Format(time.RFC3339Nano)
+	entry.Module = m[2]
+	entry.Level = strings.ToLower(m[3])
+	entry.Message = e.opts.Redact.Line(m[4])
+	entry.Source = m[5]
+	return entry
+}
+
+// savePositions writes the read offsets to Dir, through a temporary file.
+func (e *Exporter) savePositions() error {
+	e.mu.RLock()
+	offsets := make(map[string]int64, len(e.components))
+	for key, c := range e.components {
+		offsets[key] = c.Offset
+	}
+	e.mu.RUnlock()
+	data, err := json.MarshalIndent(offsets, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := filepath.Join(e.opts.Dir, positionsFile)
+	if err := os.WriteFile(p+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(p+".tmp", p)
+}
+
+// Status returns the state of the export, components sorted by generation
+// and NF.
+func (e *Exporter) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s := Status{
+		SourceDir:  e.opts.SourceDir,
+		Dir:        e.opts.Dir,
+		MaxBytes:   e.opts.MaxBytes,
+		MaxFiles:   e.opts.MaxFiles,
+		Components: make([]Component, 0, len(e.components)),
+		Error:      e.lastErr,
+	}
+	for _, c := range e.components {
+		s.Components = append(s.Components, c.Component)
+	}
+	sort.Slice(s.Components, func(i, j int) bool {
+		if s.Components[i].Generation != s.Components[j].Generation {
+			return s.Components[i].Generation < s.Components[j].Generation
+		}
+		return s.Components[i].NF < s.Components[j].NF
+	})
+	if !e.checked.IsZero() {
+		s.Checked = e.checked.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when om_log_export_bytes was last updated, for
+// exporter.Ages.
+func (e *Exporter) Freshness() map[string]time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_log_export_bytes": e.checked}
+}
+
+// Files lists the exported files, current and rotated, by generation, NF
+// and age (the current file first).
+func (e *Exporter) Files() ([]File, error) {
+	paths, err := filepath.Glob(filepath.Join(e.opts.Dir, "*", "*"+Extension+"*"))
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, p := range paths {
+		gen := filepath.Base(filepath.Dir(p))
+		nf, _, ok := splitName(filepath.Base(p))
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, File{
+			Name:       gen + "/" + filepath.Base(p),
+			Generation: gen,
+			NF:         nf,
+			Size:       info.Size(),
+			Modified:   info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Generation != b.Generation {
+			return a.Generation < b.Generation
+		}
+		if a.NF != b.NF {
+			return a.NF < b.NF
+		}
+		_, na, _ := splitName(filepath.Base(a.Name))
+		_, nb, _ := splitName(filepath.Base(b.Name))
+		return na < nb
+	})
+	return files, nil
+}
+
+// ErrNotFound is returned by Open for a name Files does not list.
+var ErrNotFound = errors.New("no such exported log file")
+
+// Open opens the exported file name, as Files lists it.
+func (e *Exporter) Open(name string) (*os.File, error) {
+	files, err := e.Files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Name == name {
+			return os.Open(filepath.Join(e.opts.Dir, filepath.FromSlash(name)))
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// splitName returns the NF and rotation number (0 for the current file) of
+// an exported file name: amf.jsonl, amf.jsonl.1, ...
+func splitName(base string) (string, int, bool) {
+	nf, rest, ok := strings.Cut(base, Extension)
+	if !ok || nf == "" {
+		return "", 0, false
+	}
+	if rest == "" {
+		return nf, 0, true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(rest, "."))
+	if err != nil || !strings.HasPrefix(rest, ".") || n < 1 {
+		return "", 0, false
+	}
+	return nf, n, true
+}
@@ -0,0 +1,98 @@
+package logexport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sink appends to the exported file of one component and rotates it when
+// it reaches maxBytes.
+type sink struct {
+	path      string
+	maxBytes  int64
+	maxFiles  int
+	f         *os.File
+	size      int64
+	rotations int
+}
+
+// newSink opens <dir>/<gen>/<nf>.jsonl for appending.
+func newSink(dir, gen, nf string, maxBytes int64, maxFiles int) (*sink, error) {
+	if err := os.MkdirAll(filepath.Join(dir, gen), 0o755); err != nil {
+		return nil, err
+	}
+	s := &sink{path: filepath.Join(dir, gen, nf+Extension), maxBytes: maxBytes, maxFiles: maxFiles}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// write appends one line, rotating first when it would take the file past
+// maxBytes. A line is never split across files.
+func (s *sink) write(line []byte) error {
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate renames the current file to .1, shifting the rotated ones up and
+// dropping those beyond maxFiles, and starts an empty one.
+func (s *sink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.maxFiles < 1 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		_ = os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxFiles))
+		for n := s.maxFiles - 1; n >= 1; n-- {
+			err := os.Rename(fmt.Sprintf("%s.%d", s.path, n), fmt.Sprintf("%s.%d", s.path, n+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	}
+	s.rotations++
+	return s.open()
+}
+
+// total returns the size of the current file and the rotated ones.
+func (s *sink) total() int64 {
+	total := s.size
+	for n := 1; n <= s.maxFiles; n++ {
+		if info, err := os.Stat(fmt.Sprintf("%s.%d", s.path, n)); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+func (s *sink) close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,219 @@
+package logimport
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/logexport"
+)
+
+// Formats of the files of a corpus.
+const (
+	FormatOpen5GS = "open5gs" // Open5GS log, as the log volume holds it
+	FormatExport  = "jsonl"   // lines exported by internal/logexport
+	// FormatSRSRAN is a srsRAN (or srsLTE) log. The Promtail pipeline has
+	// no stages for it: its lines reach Loki without labels.
+	FormatSRSRAN  = "srsran"
+	FormatUnknown = "unknown"
+)
+
+// srsranHeader is the "[2024-03-12T]18:00:00.123456 [RRC  ] [I] message"
+// of a srsRAN or srsLTE line.
+var srsranHeader = regexp.MustCompile(`^(?:\d{4}-\d{2}-\d{2}T)?\d{2}:\d{2}:\d{2}\.\d+\s+\[[\w-]+\s*\]\s+\[[A-Z]\]`)
+
+// sniffLines is how many lines of a file are read to tell its format.
+const sniffLines = 50
+
+// maxUnparsed bounds the sample of header-less lines kept per format.
+const maxUnparsed = 5
+
+// CorpusFile is the coverage of one file of a corpus.
+type CorpusFile struct {
+	Path       string `json:"path"`
+	Format     string `json:"format"`
+	Generation string `json:"generation,omitempty"`
+	Coverage
+	Error string `json:"error,omitempty"`
+}
+
+// FormatCoverage is the coverage of the files of one format and
+// generation, e.g. open5gs-5g.
+type FormatCoverage struct {
+	Format string `json:"format"`
+	Files  int    `json:"files"`
+	Coverage
+	// Unparsed are the first distinct lines whose header did not match.
+	Unparsed []string `json:"unparsed"`
+}
+
+// Corpus is the parse coverage of a directory of sample logs.
+type Corpus struct {
+	Files   []CorpusFile     `json:"files"`
+	Formats []FormatCoverage `json:"formats"`
+}
+
+// RunCorpus runs the stages over every file under paths — Open5GS logs
+// (rotated and compressed ones too), JSONL exports, srsRAN logs — and
+// reports how many lines each field was extracted from, per file and per
+// format. The format of a file is told from its first lines; its
+// generation from its 4g or 5g directory, or generation. A file that
+// cannot be read is reported in its CorpusFile.
+func RunCorpus(paths []string, generation string) (Corpus, error) {
+	c := Corpus{Files: []CorpusFile{}, Formats: []FormatCoverage{}}
+	var files []string
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir() && path != p && strings.HasPrefix(d.Name(), "."):
+				return filepath.SkipDir
+			case d.Type().IsRegular() && !strings.HasPrefix(d.Name(), "."):
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return c, err
+		}
+	}
+	if len(files) == 0 {
+		return c, fmt.Errorf("no files under %s", strings.Join(paths, ", "))
+	}
+	sort.Strings(files)
+
+	formats := make(map[string]*FormatCoverage)
+	for _, path := range files {
+		f, unparsed := corpusFile(path, generation)
+		c.Files = append(c.Files, f)
+		key := f.Format
+		if f.Generation != "" {
+			key += "-" + f.Generation
+		}
+		fc := formats[key]
+		if fc == nil {
+			fc = &FormatCoverage{Format: key, Unparsed: []string{}}
+			formats[key] = fc
+		}
+		fc.Files++
+		fc.Merge(f.Coverage)
+		for _, l := range unparsed {
+			if len(fc.Unparsed) < maxUnparsed && !slices.Contains(fc.Unparsed, l) {
+				fc.Unparsed = append(fc.Unparsed, l)
+			}
+		}
+	}
+	for _, fc := range formats {
+		c.Formats = append(c.Formats, *fc)
+	}
+	sort.Slice(c.Formats, func(i, j int) bool { return c.Formats[i].Format < c.Formats[j].Format })
+	return c, nil
+}
+
+// corpusFile parses the file at path, returning its coverage and the
+// first distinct lines whose header did not match.
+func corpusFile(path, generation string) (CorpusFile, []string) {
+	f := CorpusFile{Path: path, Format: FormatUnknown}
+	if dir := filepath.Base(filepath.Dir(path)); dir == "4g" || dir == "5g" {
+		f.Generation = dir
+	} else {
+		f.Generation = generation
+	}
+	lines, err := readLines(path)
+	if err != nil {
+		f.Error = err.Error()
+	}
+	f.Format = sniff(lines)
+	if f.Format != FormatOpen5GS && f.Format != FormatExport {
+		f.Generation = ""
+	}
+	if f.Format == FormatOpen5GS && f.Generation == "" {
+		f.Error = "generation unknown; give the generation"
+		return f, nil
+	}
+
+	var unparsed []string
+	for _, text := range lines {
+		gen, line := f.Generation, text
+		if f.Format == FormatExport {
+			var ex logexport.Entry
+			if err := json.Unmarshal([]byte(text), &ex); err != nil {
+				if f.Error == "" {
+					f.Error = err.Error()
+				}
+				continue
+			}
+			if ex.Generation != "" {
+				gen = ex.Generation
+			}
+			at, _ := time.Parse(time.RFC3339Nano, ex.Time)
+			line = openLine(ex, at, time.UTC)
+		}
+		l := Parse(gen, line)
+		f.Add(l)
+		if plain := ansiCodes.ReplaceAllString(line, ""); l.Stamp == "" && len(unparsed) < maxUnparsed && !slices.Contains(unparsed, plain) {
+			unparsed = append(unparsed, plain)
+		}
+	}
+	return f, unparsed
+}
+
+// readLines returns the non-blank lines of the file at path, gunzipped
+// when it ends in .gz; on an error, the lines read until then.
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for sc.Scan() {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(ansiCodes.ReplaceAllString(text, "")) != "" {
+			lines = append(lines, text)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// sniff tells the format of a file from its first lines.
+func sniff(lines []string) string {
+	for i, text := range lines {
+		if i == sniffLines {
+			break
+		}
+		plain := ansiCodes.ReplaceAllString(text, "")
+		var ex logexport.Entry
+		switch {
+		case strings.HasPrefix(plain, "{") && json.Unmarshal([]byte(plain), &ex) == nil && ex.Message != "":
+			return FormatExport
+		case header.MatchString(plain):
+			return FormatOpen5GS
+		case srsranHeader.MatchString(plain):
+			return FormatSRSRAN
+		}
+	}
+	return FormatUnknown
+}
@@ -0,0 +1,371 @@
+// Package logimport backfills Loki with the Open5GS logs of past lab runs.
+// Promtail only ships what is appended to the live log files, stamped as it
+// reads them when a stamp does not parse; a run whose logs were kept as
+// files (the log volume of an earlier class, the JSONL export of
+// internal/logexport) never reaches Loki. The Importer reads such files and
+// pushes every line with its original time stamp and the labels Promtail
+// would have given it, plus scenario=<name>, so past sessions can be
+// queried next to the live one and kept as a library of example failures.
+//
+// Accepted files, alone or found under a directory:
+//
+//	<generation>/<nf>.log[.N][.gz]    Open5GS logs as the log volume holds them
+//	<generation>/<nf>.jsonl[.N][.gz]  lines exported by internal/logexport
+//
+// Stamps without a year are completed from the modification time of the
+// file, or from Options.Anchor when the files were copied.
+package logimport
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/logexport"
+	"github.com/Parz1val02/OM_module/internal/logtime"
+	"github.com/Parz1val02/OM_module/internal/redact"
+)
+
+// maxLine bounds one log line; a hex dump of a large message stays below.
+const maxLine = 1 << 20
+
+// Options configure the importer.
+type Options struct {
+	LokiURL string
+	Timeout time.Duration
+	// Scenario is the value of the scenario label of every imported line.
+	Scenario string
+	// Generation is the generation of the logs found outside a 4g or 5g
+	// directory.
+	Generation string
+	// Anchor, when set, completes the stamps instead of the modification
+	// times of the files: no line is younger than it.
+	Anchor time.Time
+	// Stamps reads the stamps in LOG_TIMEZONE.
+	Stamps *logtime.Inferrer
+	// Redact is applied to every line before it is parsed.
+	Redact *redact.Redactor
+	// DryRun parses the files without pushing anything.
+	DryRun bool
+}
+
+// File is the import of one file.
+type File struct {
+	Path       string `json:"path"`
+	Generation string `json:"generation,omitempty"`
+	NF         string `json:"nf,omitempty"`
+	Lines      int    `json:"lines"`
+	// Pushed lines Loki accepted; Rejected ones were in a push it refused.
+	Pushed   int    `json:"pushed"`
+	Rejected int    `json:"rejected"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Summary is the outcome of an import.
+type Summary struct {
+	Scenario string `json:"scenario"`
+	// Selector selects the imported lines in LogQL.
+	Selector string `json:"selector"`
+	DryRun   bool   `json:"dry_run"`
+	Files    []File `json:"files"`
+	Lines    int    `json:"lines"`
+	Pushed   int    `json:"pushed"`
+	Rejected int    `json:"rejected"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// Importer pushes log files to Loki.
+type Importer struct {
+	opts   Options
+	client *http.Client
+}
+
+// New returns an importer.
+func New(opts Options) (*Importer, error) {
+	if opts.Scenario == "" {
+		return nil, fmt.Errorf("an import needs a scenario name")
+	}
+	if opts.Generation != "" && opts.Generation != "4g" && opts.Generation != "5g" {
+		return nil, fmt.Errorf("generation must be 4g or 5g, not %q", opts.Generation)
+	}
+	if !opts.DryRun && opts.LokiURL == "" {
+		return nil, fmt.Errorf("LOKI_URL is off")
+	}
+	if opts.Stamps == nil {
+		opts.Stamps = logtime.New(time.Local)
+	}
+	return &Importer{opts: opts, client: httpclient.New("logimport", opts.Timeout)}, nil
+}
+
+// source is one file to import.
+type source struct {
+	path     string
+	gen, nf  string
+	rotation int
+	jsonl    bool
+}
+
+// fileName is <nf>.log or <nf>.jsonl, rotated (.N) and compressed (.gz).
+var fileName = regexp.MustCompile(`^(.+)\.(log|jsonl)(?:\.(\d+))?(\.gz)?$`)
+
+// sources expands paths into the files to import, each component oldest
+// file first: Loki accepts a stream's lines out of order only within a
+// window behind its newest line.
+func (im *Importer) sources(paths []string) ([]source, error) {
+	var out []source
+	add := func(p string) error {
+		m := fileName.FindStringSubmatch(filepath.Base(p))
+		if m == nil {
+			return fmt.Errorf("%s: not a .log or .jsonl file", p)
+		}
+		s := source{path: p, nf: m[1], jsonl: m[2] == "jsonl"}
+		s.rotation, _ = strconv.Atoi(m[3])
+		switch dir := filepath.Base(filepath.Dir(p)); {
+		case dir == "4g" || dir == "5g":
+			s.gen = dir
+		case im.opts.Generation != "":
+			s.gen = im.opts.Generation
+		case !s.jsonl:
+			return fmt.Errorf("%s: not under a 4g or 5g directory; give the generation", p)
+		}
+		out = append(out, s)
+		return nil
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := add(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !fileName.MatchString(d.Name()) {
+				return err
+			}
+			return add(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.gen != b.gen {
+			return a.gen < b.gen
+		}
+		if a.nf != b.nf {
+			return a.nf < b.nf
+		}
+		return a.rotation > b.rotation
+	})
+	return out, nil
+}
+
+// Import pushes the files under paths to Loki. A file that cannot be read
+// or that Loki refuses is reported in its File and the import goes on;
+// the error is for paths that do not exist or hold no importable file.
+func (im *Importer) Import(ctx context.Context, paths []string) (Summary, error) {
+	sum := Summary{
+		Scenario: im.opts.Scenario,
+		Selector: fmt.Sprintf(`{job="open5gs", scenario=%q}`, im.opts.Scenario),
+		DryRun:   im.opts.DryRun,
+		Files:    []File{},
+	}
+	srcs, err := im.sources(paths)
+	if err != nil {
+		return sum, err
+	}
+	if len(srcs) == 0 {
+		return sum, fmt.Errorf("no .log or .jsonl files under %s", strings.Join(paths, ", "))
+	}
+
+	var from, to time.Time
+	for _, src := range srcs {
+		if ctx.Err() != nil {
+			return sum, ctx.Err()
+		}
+		f, first, last := im.importFile(ctx, src)
+		sum.Files = append(sum.Files, f)
+		sum.Lines += f.Lines
+		sum.Pushed += f.Pushed
+		sum.Rejected += f.Rejected
+		if !first.IsZero() && (from.IsZero() || first.Before(from)) {
+			from = first
+		}
+		if last.After(to) {
+			to = last
+		}
+	}
+	if !from.IsZero() {
+		sum.From = from.UTC().Format(time.RFC3339)
+		sum.To = to.UTC().Format(time.RFC3339)
+	}
+	return sum, nil
+}
+
+// importFile reads src and pushes its lines in batches.
+func (im *Importer) importFile(ctx context.Context, src source) (File, time.Time, time.Time) {
+	f := File{Path: src.path, Generation: src.gen, NF: src.nf}
+	var from, to time.Time
+	fail := func(err error) {
+		if f.Error == "" {
+			f.Error = err.Error()
+		}
+	}
+
+	file, err := os.Open(src.path)
+	if err != nil {
+		fail(err)
+		return f, from, to
+	}
+	defer file.Close()
+	anchor := im.opts.Anchor
+	if anchor.IsZero() {
+		if info, err := file.Stat(); err == nil {
+			anchor = info.ModTime()
+		} else {
+			anchor = time.Now()
+		}
+	}
+	var r io.Reader = file
+	if strings.HasSuffix(src.path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			fail(err)
+			return f, from, to
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	b := newBatch()
+	flush := func() {
+		if b.lines == 0 {
+			return
+		}
+		if im.opts.DryRun {
+			f.Pushed += b.lines
+		} else if err := im.push(ctx, b); err != nil {
+			f.Rejected += b.lines
+			fail(err)
+		} else {
+			f.Pushed += b.lines
+		}
+		b = newBatch()
+	}
+	emit := func(e entry) {
+		f.Lines++
+		if from.IsZero() || e.at.Before(from) {
+			from = e.at
+		}
+		if e.at.After(to) {
+			to = e.at
+		}
+		b.add(e)
+		if b.bytes >= batchBytes {
+			flush()
+		}
+	}
+
+	// Lines before the first stamped one (a hex dump cut at the start of
+	// a rotated file) wait for its time; every later header-less line
+	// takes the time of the line before it, as in the JSONL export.
+	var last time.Time
+	var pending []entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for sc.Scan() && ctx.Err() == nil {
+		text := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(ansiCodes.ReplaceAllString(text, "")) == "" {
+			continue
+		}
+		gen, nf, line, at := src.gen, src.nf, im.opts.Redact.Line(text), time.Time{}
+		if src.jsonl {
+			var ex logexport.Entry
+			if err := json.Unmarshal([]byte(text), &ex); err != nil {
+				fail(fmt.Errorf("line %d: %w", f.Lines+len(pending)+1, err))
+				continue
+			}
+			if ex.Generation != "" {
+				gen = ex.Generation
+			}
+			if ex.NF != "" {
+				nf = ex.NF
+			}
+			at, _ = time.Parse(time.RFC3339Nano, ex.Time)
+			line = im.opts.Redact.Line(openLine(ex, at, im.opts.Stamps.Location()))
+		}
+		if gen == "" {
+			fail(fmt.Errorf("generation unknown; give the generation"))
+			return f, from, to
+		}
+
+		p := parse(gen, line)
+		if at.IsZero() && p.stamp != "" {
+			at, _ = im.opts.Stamps.Infer(p.stamp, anchor)
+		}
+		e := entry{at: at, line: line, labels: p.labels(gen, nf, im.opts.Scenario)}
+		if at.IsZero() {
+			if last.IsZero() {
+				pending = append(pending, e)
+				continue
+			}
+			e.at = last
+		}
+		last = e.at
+		for _, pe := range pending {
+			pe.at = e.at
+			emit(pe)
+		}
+		pending = nil
+		emit(e)
+	}
+	if err := sc.Err(); err != nil {
+		fail(err)
+	}
+	for _, pe := range pending {
+		pe.at = anchor
+		emit(pe)
+	}
+	flush()
+	if !from.IsZero() {
+		f.From = from.UTC().Format(time.RFC3339)
+		f.To = to.UTC().Format(time.RFC3339)
+	}
+	return f, from, to
+}
+
+// openLine writes an exported entry back as the Open5GS line it was
+// parsed from, stamped in loc, so it goes through the same stages as a
+// .log line.
+func openLine(e logexport.Entry, at time.Time, loc *time.Location) string {
+	if e.Module == "" || e.Level == "" || at.IsZero() {
+		return e.Message
+	}
+	line := fmt.Sprintf("%s: [%s] %s: %s",
+		at.In(loc).Format("01/02 15:04:05.000"), e.Module, strings.ToUpper(e.Level), e.Message)
+	if e.Source != "" {
+		line += " (" + e.Source + ")"
+	}
+	return line
+}
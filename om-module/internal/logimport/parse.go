@@ -0,0 +1,100 @@
+package logimport
+
+import "fmt"
+
+// Line is what the Promtail stages extract from one log line.
+type Line struct {
+	Line string `json:"line"`
+	// Stamp is the Open5GS stamp, empty when the header did not match.
+	Stamp     string `json:"stamp,omitempty"`
+	Level     string `json:"level,omitempty"`
+	IMSI      string `json:"imsi,omitempty"`
+	Procedure string `json:"procedure,omitempty"`
+}
+
+// Parse runs the stages of generation gen (4g or 5g) over line, as
+// Promtail would ship it. With another generation only the header is
+// parsed.
+func Parse(gen, line string) Line {
+	p := parse(gen, line)
+	return Line{Line: line, Stamp: p.stamp, Level: p.level, IMSI: p.imsi, Procedure: p.procedure}
+}
+
+// Expect is the expected outcome of parsing one line. A nil field is not
+// checked; "" expects the stages to extract nothing.
+type Expect struct {
+	Stamp     *string `json:"stamp,omitempty"`
+	Level     *string `json:"level,omitempty"`
+	IMSI      *string `json:"imsi,omitempty"`
+	Procedure *string `json:"procedure,omitempty"`
+}
+
+// Mismatch is one field of a line that is not what was expected.
+type Mismatch struct {
+	Field string `json:"field"`
+	Want  string `json:"want"`
+	Got   string `json:"got"`
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: want %q, got %q", m.Field, m.Want, m.Got)
+}
+
+// Check returns the fields of l that differ from e.
+func (l Line) Check(e Expect) []Mismatch {
+	var out []Mismatch
+	for _, f := range []struct {
+		name string
+		want *string
+		got  string
+	}{
+		{"stamp", e.Stamp, l.Stamp},
+		{"level", e.Level, l.Level},
+		{"imsi", e.IMSI, l.IMSI},
+		{"procedure", e.Procedure, l.Procedure},
+	} {
+		if f.want != nil && *f.want != f.got {
+			out = append(out, Mismatch{Field: f.name, Want: *f.want, Got: f.got})
+		}
+	}
+	return out
+}
+
+// Coverage counts how many lines the stages extracted each field from.
+type Coverage struct {
+	Lines     int `json:"lines"`
+	Stamped   int `json:"stamped"`
+	Level     int `json:"level"`
+	IMSI      int `json:"imsi"`
+	Procedure int `json:"procedure"`
+}
+
+// Add counts l.
+func (c *Coverage) Add(l Line) {
+	c.Lines++
+	for _, f := range []struct {
+		n *int
+		v string
+	}{{&c.Stamped, l.Stamp}, {&c.Level, l.Level}, {&c.IMSI, l.IMSI}, {&c.Procedure, l.Procedure}} {
+		if f.v != "" {
+			*f.n++
+		}
+	}
+}
+
+// Merge adds the counts of o.
+func (c *Coverage) Merge(o Coverage) {
+	c.Lines += o.Lines
+	c.Stamped += o.Stamped
+	c.Level += o.Level
+	c.IMSI += o.IMSI
+	c.Procedure += o.Procedure
+}
+
+// Percent is n as a percentage of the lines, 0 without lines.
+func (c Coverage) Percent(n int) float64 {
+	if c.Lines == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(c.Lines)
+}
@@ -0,0 +1,109 @@
+package logimport
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The stages below mirror the pipeline_stages of promtail/core/config.yml
+// (and alloy/config.alloy), so an imported line carries the labels it would
+// have carried had Promtail shipped it live; keep them in sync.
+
+var (
+	ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// header is the "MM/DD hh:mm:ss.mmm: [module] LEVEL: message" of an
+	// Open5GS line, matched on the line without colour codes.
+	header = regexp.MustCompile(`(\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d+):\s+\[\w+\]\s+(\w+):\s+(.*)`)
+)
+
+// procedure is one procedure stage: when expr matches, the line gets
+// procedure=value. onLine stages match the whole line, the others the
+// message.
+type procedure struct {
+	value  string
+	expr   *regexp.Regexp
+	onLine bool
+}
+
+// stages are the IMSI and procedure stages of one generation.
+type stages struct {
+	imsi       *regexp.Regexp
+	procedures []procedure
+}
+
+var pipelines = map[string]stages{
+	"5g": {
+		imsi: regexp.MustCompile(`(?:imsi-|IMSI\[)(\d{15})`),
+		procedures: []procedure{
+			{value: "attach", expr: regexp.MustCompile(`(?i)InitialUEMessage|Unknown UE by SUCI|Registration request|Registration complete|Configuration update command|No GUTI allocated|gNB-N2 accepted|\[Added\] Number of (?:AMF|gNB)-UEs is now \d+|\[Added\] Number of gNBs is now \d+`)},
+			{value: "session", expr: regexp.MustCompile(`(?i)UE SUPI\[imsi-|UE F-SEID\[|Removed Session:|\[Added\] Number of (?:SMF|UPF|AMF)-Sessions is now \d+|\[Added\] Number of (?:SMF|UPF)-UEs is now \d+`)},
+			{value: "release", expr: regexp.MustCompile(`(?i)Deregistration request|UE Context Release|Release SM [Cc]ontext|\[Removed\] Number of (?:AMF|gNB)-UEs is now \d+|\[Removed\] Number of (?:SMF|UPF|AMF)-(?:UEs?|Sessions) is now \d+|\[Removed\] Number of gNBs is now \d+`)},
+			{value: "error", expr: regexp.MustCompile(`(?i)Authentication failure|Cannot find SUCI|Cannot find.*NSSAI|Registration reject|(?:Not Supported OR Not Subscribed|Ue requested DNN.*Not Supported)`)},
+			{value: "roaming", expr: regexp.MustCompile(`(?i)\bN32[-_]?[cf]?\b|SEPP (?:established|terminated|de-?registered)|security[ _-]?capability|PRINS|n32c-handshake|n32f-forward`)},
+			{value: "exposure", expr: regexp.MustCompile(`\b(?:GET|POST|PUT|PATCH|DELETE)\b[\s|"]+/(?:3gpp|nnef)-[a-z0-9-]+/v\d+`), onLine: true},
+		},
+	},
+	"4g": {
+		imsi: regexp.MustCompile(`IMSI\[(\d{15})\]`),
+		procedures: []procedure{
+			{value: "attach", expr: regexp.MustCompile(`(?i)InitialUEMessage|Unknown UE by (?:GUTI|S_TMSI)|Attach request|Identity response|Attach complete|Service request|eNB-S1 accepted|\[Added\] Number of (?:eNBs|eNB-UEs|MME-UEs) is now \d+`)},
+			{value: "session", expr: regexp.MustCompile(`(?i)UE IMSI\[\d+\] APN\[|UE F-SEID\[|Removed Session:|\[Added\] Number of (?:SGWC|SGWU|SMF|UPF)-(?:UEs?|[Ss]essions) is now \d+|\[Added\] Number of MME-Sessions is now \d+`)},
+			{value: "release", expr: regexp.MustCompile(`(?i)Detach request|UE Context Release|Mobile Reachable timer|\[Removed\] Number of (?:SGWC|SGWU|SMF|MME|UPF)-(?:UEs?|Sessions|[Ss]essions) is now \d+|\[Removed\] Number of (?:eNBs|eNB-UEs|MME-UEs) is now \d+`)},
+			{value: "error", expr: regexp.MustCompile(`(?i)Authentication failure|Authentication Information failed|Attach reject|Invalid APN\[|Failure in transaction|connection refused`)},
+		},
+	},
+}
+
+// parsed is what the stages extract from one line.
+type parsed struct {
+	stamp     string // Open5GS stamp, empty without a header
+	level     string
+	imsi      string
+	procedure string
+}
+
+// parse runs the stages of generation gen over line.
+func parse(gen, line string) parsed {
+	plain := ansiCodes.ReplaceAllString(line, "")
+	var p parsed
+	message := plain
+	if m := header.FindStringSubmatch(plain); m != nil {
+		p.stamp, p.level, message = m[1], strings.ToLower(m[2]), m[3]
+	}
+	st, ok := pipelines[gen]
+	if !ok {
+		return p
+	}
+	if m := st.imsi.FindStringSubmatch(message); m != nil {
+		p.imsi = m[1]
+	}
+	// Like successive labels stages, the last procedure that matches wins.
+	for _, pr := range st.procedures {
+		src := message
+		if pr.onLine {
+			src = plain
+		}
+		if pr.expr.MatchString(src) {
+			p.procedure = pr.value
+		}
+	}
+	return p
+}
+
+// labels returns the stream labels of a line of <gen>/<nf>.log.
+func (p parsed) labels(gen, nf, scenario string) map[string]string {
+	l := map[string]string{
+		"job":        "open5gs",
+		"domain":     "core",
+		"generation": gen,
+		"nf":         nf,
+		"filename":   "/var/log/open5gs/" + gen + "/" + nf + ".log",
+		"scenario":   scenario,
+	}
+	for name, v := range map[string]string{"level": p.level, "imsi": p.imsi, "procedure": p.procedure} {
+		if v != "" {
+			l[name] = v
+		}
+	}
+	return l
+}
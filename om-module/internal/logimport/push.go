@@ -0,0 +1,121 @@
+package logimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// batchBytes bounds the lines of one push, well under the ingestion burst
+// of a default Loki (6 MB).
+const batchBytes = 1 << 20
+
+// maxAttempts is how often a push Loki throttles (429) or fails (5xx) is
+// tried before the batch is given up.
+const maxAttempts = 5
+
+// entry is one line to push.
+type entry struct {
+	at     time.Time
+	line   string
+	labels map[string]string
+}
+
+// batch gathers entries by stream until it is pushed.
+type batch struct {
+	streams map[string]*stream
+	bytes   int
+	lines   int
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newBatch() *batch {
+	return &batch{streams: make(map[string]*stream)}
+}
+
+func (b *batch) add(e entry) {
+	key := streamKey(e.labels)
+	s := b.streams[key]
+	if s == nil {
+		s = &stream{Stream: e.labels}
+		b.streams[key] = s
+	}
+	s.Values = append(s.Values, [2]string{strconv.FormatInt(e.at.UnixNano(), 10), e.line})
+	b.bytes += len(e.line)
+	b.lines++
+}
+
+// streamKey renders labels as a selector, the identity of their stream.
+func streamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for n := range labels {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf("%s=%q", n, labels[n])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// push sends b to Loki, retrying while Loki throttles or is unavailable.
+// Loki rejects lines older than reject_old_samples_max_age or behind the
+// ordering window of their stream with a 400, which is not retried.
+func (im *Importer) push(ctx context.Context, b *batch) error {
+	var body struct {
+		Streams []*stream `json:"streams"`
+	}
+	for _, s := range b.streams {
+		body.Streams = append(body.Streams, s)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	target := strings.TrimRight(im.opts.LokiURL, "/") + "/loki/api/v1/push"
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		retry, err := im.send(ctx, target, data)
+		if err == nil || !retry || attempt == maxAttempts {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func (im *Importer) send(ctx context.Context, target string, data []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := im.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("loki push: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,379 @@
+// Package logsampling reports the Open5GS log lines the log pipeline drops
+// under its rate limits. Promtail (or Alloy) lets every NF send a fixed
+// number of lines per second of each level, with a burst allowance, so an
+// NF in a crash loop cannot flood Loki. Each line is counted before the
+// limit (om_logging_lines_level_total) and after it
+// (om_logging_lines_forwarded_total).
+//
+// The Reporter reads both counters from Prometheus, exports the difference
+// as om_log_suppressed_lines_total and writes one summary entry per NF and
+// level into the NF's own Loki stream ("suppressed 12,430 ERROR lines"), so
+// the gap in the logs explains itself.
+package logsampling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxRecent is how many summaries Status keeps.
+const maxRecent = 50
+
+// Rule is the limit the log pipeline applies to a group of levels, per NF.
+// Name is also the LOG_LIMIT_<NAME>_RATE/_BURST variables that set it.
+type Rule struct {
+	Name   string   `json:"name"`
+	Levels []string `json:"levels"`
+	Rate   float64  `json:"rate"` // lines per second
+	Burst  float64  `json:"burst"`
+}
+
+// Rules returns the rules of promtail/core/config.yml and alloy/config.alloy
+// with the given limits. Lines without a level (the header did not parse)
+// share the info limit.
+func Rules(errorRate, errorBurst, warningRate, warningBurst, infoRate, infoBurst float64) []Rule {
+	return []Rule{
+		{Name: "error", Levels: []string{"error", "fatal"}, Rate: errorRate, Burst: errorBurst},
+		{Name: "warning", Levels: []string{"warning", "warn"}, Rate: warningRate, Burst: warningBurst},
+		{Name: "info", Levels: []string{"info", "debug", "trace", ""}, Rate: infoRate, Burst: infoBurst},
+	}
+}
+
+// Options configure the reporter.
+type Options struct {
+	PrometheusURL     string
+	PrometheusTimeout time.Duration
+	LokiURL           string
+	LokiTimeout       time.Duration
+	Interval          time.Duration
+	Rules             []Rule
+}
+
+// Suppression is what one summary entry reported.
+type Suppression struct {
+	Time       string  `json:"time"`
+	Generation string  `json:"generation"`
+	NF         string  `json:"nf"`
+	Level      string  `json:"level"`
+	Lines      float64 `json:"lines"`
+	Since      string  `json:"since"`
+	Rule       string  `json:"rule,omitempty"`
+	Message    string  `json:"message"`
+}
+
+// Status is the API view of the reporter.
+type Status struct {
+	Rules     []Rule        `json:"rules"`
+	Interval  string        `json:"interval"`
+	UpdatedAt string        `json:"updated_at,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Recent    []Suppression `json:"recent"` // newest first
+}
+
+type streamKey struct{ generation, nf, level string }
+
+// Reporter polls the pipeline counters and writes the summaries.
+type Reporter struct {
+	opts   Options
+	client *http.Client
+
+	suppressed *prometheus.CounterVec
+
+	mu      sync.RWMutex
+	dropped map[streamKey]float64 // cumulative drops at the last poll
+	primed  bool
+	polled  time.Time
+	recent  []Suppression
+	updated time.Time
+	lastErr string
+}
+
+// New registers om_log_suppressed_lines_total on reg and returns the
+// reporter.
+func New(reg prometheus.Registerer, opts Options) *Reporter {
+	r := &Reporter{
+		opts:    opts,
+		client:  httpclient.New("logsampling", 0),
+		dropped: make(map[streamKey]float64),
+		suppressed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "log", Name: "suppressed_lines_total",
+			Help: "Open5GS log lines dropped by the log pipeline rate limits since the module started.",
+		}, []string{"generation", "nf", "level"}),
+	}
+	reg.MustRegister(r.suppressed)
+	return r
+}
+
+// Run polls every interval until ctx is cancelled. The first poll only
+// records the counters, so lines dropped before the module started are
+// not reported.
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.update(ctx); err != nil && ctx.Err() == nil {
+			r.mu.Lock()
+			first := r.lastErr == ""
+			r.lastErr = err.Error()
+			r.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Log sampling summaries: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the rules and the latest summaries.
+func (r *Reporter) Status() Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s := Status{
+		Rules:    append([]Rule{}, r.opts.Rules...),
+		Interval: r.opts.Interval.String(),
+		Error:    r.lastErr,
+		Recent:   append([]Suppression{}, r.recent...),
+	}
+	if !r.updated.IsZero() {
+		s.UpdatedAt = r.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the pipeline counters were last read, for
+// exporter.Ages.
+func (r *Reporter) Freshness() map[string]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.updated.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_log_suppressed_lines_total": r.updated}
+}
+
+func (r *Reporter) update(ctx context.Context) error {
+	dropped, err := r.query(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	r.mu.Lock()
+	primed, since := r.primed, now.Sub(r.polled).Round(time.Second)
+	var out []Suppression
+	for key, total := range dropped {
+		prev, seen := r.dropped[key]
+		delta := total - prev
+		if !seen {
+			delta = total
+		}
+		if total < prev {
+			// The pipeline restarted and its counters with it.
+			delta = total
+		}
+		if !primed || delta < 1 {
+			continue
+		}
+		s := Suppression{
+			Time:       now.UTC().Format(time.RFC3339),
+			Generation: key.generation,
+			NF:         key.nf,
+			Level:      key.level,
+			Lines:      delta,
+			Since:      since.String(),
+		}
+		if rule, ok := r.rule(key.level); ok {
+			s.Rule = rule.Name
+		}
+		s.Message = r.message(s)
+		out = append(out, s)
+	}
+	r.dropped, r.primed, r.polled = dropped, true, now
+	r.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Lines != out[j].Lines {
+			return out[i].Lines > out[j].Lines
+		}
+		if out[i].Generation != out[j].Generation {
+			return out[i].Generation < out[j].Generation
+		}
+		if out[i].NF != out[j].NF {
+			return out[i].NF < out[j].NF
+		}
+		return out[i].Level < out[j].Level
+	})
+	for _, s := range out {
+		r.suppressed.WithLabelValues(s.Generation, s.NF, s.Level).Add(s.Lines)
+	}
+
+	var pushErr error
+	if len(out) > 0 {
+		pushErr = r.push(ctx, now, out)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recent = append(append([]Suppression{}, out...), r.recent...)
+	if len(r.recent) > maxRecent {
+		r.recent = r.recent[:maxRecent]
+	}
+	r.updated = now
+	if pushErr != nil {
+		return pushErr
+	}
+	r.lastErr = ""
+	return nil
+}
+
+func (r *Reporter) rule(level string) (Rule, bool) {
+	for _, rule := range r.opts.Rules {
+		for _, l := range rule.Levels {
+			if l == level {
+				return rule, true
+			}
+		}
+	}
+	return Rule{}, false
+}
+
+// message is the summary line written to Loki.
+func (r *Reporter) message(s Suppression) string {
+	what := strings.ToUpper(s.Level) + " lines"
+	if s.Level == "" {
+		what = "unparsed lines"
+	}
+	msg := fmt.Sprintf("om-module: suppressed %s %s from %s in the last %s", thousands(s.Lines), what, s.NF, s.Since)
+	if rule, ok := r.rule(s.Level); ok {
+		msg += fmt.Sprintf(" (log sampling limit %g lines/s, burst %g)", rule.Rate, rule.Burst)
+	}
+	return msg
+}
+
+// query returns the lines dropped so far per generation, NF and level: the
+// lines counted before the limits minus those forwarded after them. A level
+// of which nothing was forwarded yet has no forwarded series.
+func (r *Reporter) query(ctx context.Context) (map[streamKey]float64, error) {
+	const by = "sum by (generation, nf, level)"
+	expr := fmt.Sprintf(`(%[1]s (om_logging_lines_level_total) - %[1]s (om_logging_lines_forwarded_total)) or %[1]s (om_logging_lines_level_total)`, by)
+	q := url.Values{}
+	q.Set("query", expr)
+	target := strings.TrimRight(r.opts.PrometheusURL, "/") + "/api/v1/query?" + q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.PrometheusTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make(map[streamKey]float64)
+	for _, res := range body.Data.Result {
+		key := streamKey{res.Metric["generation"], res.Metric["nf"], res.Metric["level"]}
+		if key.nf == "" {
+			continue
+		}
+		s, _ := res.Value[1].(string)
+		n, _ := strconv.ParseFloat(s, 64)
+		out[key] = math.Max(0, math.Round(n))
+	}
+	return out, nil
+}
+
+// push writes the summaries to the streams of the NFs they are about, with
+// the labels the log pipeline gives those streams.
+func (r *Reporter) push(ctx context.Context, at time.Time, list []Suppression) error {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var body struct {
+		Streams []stream `json:"streams"`
+	}
+	ts := strconv.FormatInt(at.UnixNano(), 10)
+	for _, s := range list {
+		labels := map[string]string{
+			"job":        "open5gs",
+			"domain":     "core",
+			"generation": s.Generation,
+			"nf":         s.NF,
+			"filename":   fmt.Sprintf("/var/log/open5gs/%s/%s.log", s.Generation, s.NF),
+		}
+		if s.Level != "" {
+			labels["level"] = s.Level
+		}
+		body.Streams = append(body.Streams, stream{Stream: labels, Values: [][2]string{{ts, s.Message}}})
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.LokiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(r.opts.LokiURL, "/")+"/loki/api/v1/push", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("loki push: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// thousands formats n with comma separators: 12430 → "12,430".
+func thousands(n float64) string {
+	s := strconv.FormatFloat(n, 'f', 0, 64)
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+// Package logschema builds the Loki label contract of the testbed: the stream
+// labels promtail (or Alloy) attaches to Open5GS log lines and the values they
+// can take for the containers currently running. Dashboards and ad-hoc LogQL
+// can be checked against it, so a query that filters on a label or NF that
+// will never be shipped is caught before a student stares at an empty panel.
+//
+// The label set mirrors promtail/core/config.yml and alloy/config.alloy; keep
+// the three in sync.
+package logschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+)
+
+// Selector matches every Open5GS stream.
+const Selector = `{job="open5gs"}`
+
+// Parser is the LogQL pattern stage that extracts the Fields from a line.
+const Parser = `| pattern "<timestamp>: [<module>] <_>: <message> (<source>)"`
+
+// Label is one stream label.
+type Label struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Values      []string `json:"values,omitempty"`  // closed value set; empty for free-form labels
+	Pattern     string   `json:"pattern,omitempty"` // format of free-form values
+	Optional    bool     `json:"optional"`          // absent on lines that do not match its stage
+}
+
+// Field is a value carried in the log line rather than as a label; it is
+// available to queries through Parser.
+type Field struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Component is one Open5GS NF that ships logs, with the label values its
+// lines carry.
+type Component struct {
+	Service    string   `json:"service"`
+	NF         string   `json:"nf"` // value of the nf label (the log file name)
+	Generation string   `json:"generation"`
+	Running    bool     `json:"running"`
+	Labels     []string `json:"labels"`
+}
+
+// Schema is the contract served as loki-labels.json.
+type Schema struct {
+	Generated  string      `json:"generated"`
+	Selector   string      `json:"selector"`
+	Parser     string      `json:"parser"`
+	Labels     []Label     `json:"labels"`
+	Fields     []Field     `json:"fields"`
+	Components []Component `json:"components"`
+}
+
+// Labels every Open5GS line carries.
+var streamLabels = []string{"job", "domain", "generation", "nf", "filename"}
+
+// Labels extracted from the line, present only when their stage matches.
+var optionalLabels = []string{"level", "imsi", "procedure", "scenario"}
+
+var fields = []Field{
+	{"timestamp", "Open5GS time stamp (MM/DD hh:mm:ss.mmm in LOG_TIMEZONE, no year), also the entry time"},
+	{"module", "Open5GS module that logged the line (amf, ngap, gmm, pfcp, …)"},
+	{"message", "Free text after the level"},
+	{"source", "C source file and line (../src/amf/ngap-handler.c:461)"},
+}
+
+// Build returns the schema for the given Compose services. Every Open5GS
+// service of the core domain contributes its nf and generation values;
+// services that are not running are listed but do not widen the value sets.
+func Build(services []collector.ServiceGroup) Schema {
+	s := Schema{
+		Generated:  time.Now().UTC().Format(time.RFC3339),
+		Selector:   Selector,
+		Parser:     Parser,
+		Fields:     fields,
+		Components: []Component{},
+	}
+
+	nfs := make(map[string]bool)
+	generations := make(map[string]bool)
+	for _, g := range services {
+		// mongo and webui are core containers too but ship no log file.
+		if g.Domain != collector.DomainCore || !g.NFKind.Open5GS() {
+			continue
+		}
+		// The log file, and therefore the nf label, is named after the
+		// Compose service: smf2 logs to smf2.log although its om.nf is smf.
+		c := Component{
+			Service:    g.Service,
+			NF:         g.Service,
+			Generation: g.Generation,
+			Running:    g.Running > 0,
+			Labels:     append(append([]string{}, streamLabels...), optionalLabels...),
+		}
+		s.Components = append(s.Components, c)
+		if c.Running {
+			nfs[c.NF] = true
+			generations[c.Generation] = true
+		}
+	}
+
+	s.Labels = []Label{
+		{Name: "job", Description: "Always open5gs for core NF logs", Values: []string{"open5gs"}},
+		{Name: "domain", Description: "Testbed domain of the NF", Values: []string{collector.DomainCore}},
+		{Name: "generation", Description: "Core generation, from the log directory", Values: keys(generations)},
+		{Name: "nf", Description: "NF instance, from the log file name (amf.log → amf)", Values: keys(nfs)},
+		{Name: "filename", Description: "Path of the log file inside the promtail container", Pattern: "/var/log/open5gs/<generation>/<nf>.log"},
+		{Name: "level", Description: "Open5GS log level, lower-cased", Values: []string{"debug", "error", "fatal", "info", "trace", "warning"}, Optional: true},
+		{Name: "imsi", Description: "Subscriber the line refers to (imsi-… or IMSI[…])", Pattern: `\d{15}`, Optional: true},
+		{Name: "procedure", Description: "Procedure family matched by the message", Values: []string{"attach", "error", "exposure", "release", "roaming", "session"}, Optional: true},
+		{Name: "scenario", Description: "Past lab run the line was imported from (om-module import); absent on live lines", Pattern: `[A-Za-z0-9_.-]+`, Optional: true},
+	}
+	return s
+}
+
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Problem is one reference in a query the schema cannot satisfy.
+type Problem struct {
+	Label   string `json:"label"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	selectorRe = regexp.MustCompile(`\{([^{}]*)\}`)
+	matcherRe  = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*(=~|!~|!=|=)\s*"((?:[^"\\]|\\.)*)"`)
+	literalRe  = regexp.MustCompile(`^[A-Za-z0-9_.-]+(\|[A-Za-z0-9_.-]+)*$`)
+)
+
+// Check returns the problems of the stream selectors in a LogQL query: labels
+// the pipeline never emits, and equality or alternation matchers on a closed
+// label whose values will not exist in the current topology. Negative
+// matchers, non-literal regexes and Grafana variables ($var) are not checked.
+func (s Schema) Check(expr string) []Problem {
+	byName := make(map[string]Label, len(s.Labels))
+	for _, l := range s.Labels {
+		byName[l.Name] = l
+	}
+
+	problems := []Problem{}
+	for _, sel := range selectorRe.FindAllStringSubmatch(expr, -1) {
+		for _, m := range matcherRe.FindAllStringSubmatch(sel[1], -1) {
+			name, op, value := m[1], m[2], m[3]
+			l, ok := byName[name]
+			if !ok {
+				problems = append(problems, Problem{Label: name,
+					Message: fmt.Sprintf("label %q is not emitted by the log pipeline", name)})
+				continue
+			}
+			if l.Pattern != "" || strings.Contains(value, "$") {
+				continue
+			}
+			switch {
+			case op == "=" && !contains(l.Values, value):
+				problems = append(problems, Problem{Label: name, Value: value,
+					Message: fmt.Sprintf("no stream with %s=%q in the current topology", name, value)})
+			case op == "=~" && literalRe.MatchString(value):
+				// An alternation still returns data while one branch exists
+				// (nf=~"smf|smf2" without the E4 slice core).
+				matched := false
+				for _, v := range strings.Split(value, "|") {
+					matched = matched || contains(l.Values, v)
+				}
+				if !matched {
+					problems = append(problems, Problem{Label: name, Value: value,
+						Message: fmt.Sprintf("no stream with %s=~%q in the current topology", name, value)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
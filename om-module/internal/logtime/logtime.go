@@ -0,0 +1,99 @@
+// Package logtime turns the partial timestamps of the lab's log lines into
+// full times. Open5GS stamps its lines with the local month, day and time
+// but no year ("10/16 14:03:22.123"), and the RAN simulators with the time
+// of day only ("14:03:22.123"); taken at face value, or replaced by the time
+// a line is read, they break historical replay and any correlation across
+// midnight or New Year.
+//
+// A stamp is completed from an anchor, a time the line is known not to be
+// younger than: the modification time of the file it was read from, or
+// the time Loki received it. The stamp is placed at the latest date that is
+// not after the anchor, so a 23:59 line read at 00:01 belongs to the day
+// before and a 12/31 line read on January 1st to the year before.
+//
+// Promtail and Alloy parse the Open5GS stamp with the same layout and
+// LOG_TIMEZONE (promtail/core/config.yml, alloy/config.alloy); keep the
+// three in sync.
+package logtime
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	// Named LOG_TIMEZONE zones must resolve in images without tzdata.
+	_ "time/tzdata"
+)
+
+// Layouts of the partial stamps. Fractional seconds of any length are
+// accepted after the seconds when parsing.
+const (
+	Open5GSLayout   = "01/02 15:04:05"
+	TimeOfDayLayout = "15:04:05"
+)
+
+// Skew is how far after its anchor a stamp may fall and still be taken as
+// is: the clocks of the containers and of whatever read the line drift a
+// little apart.
+const Skew = 5 * time.Minute
+
+var (
+	open5gsStamp   = regexp.MustCompile(`^\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?$`)
+	timeOfDayStamp = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(?:\.\d+)?$`)
+)
+
+// Inferrer completes stamps written in one time zone.
+type Inferrer struct {
+	loc *time.Location
+}
+
+// New returns an Inferrer for stamps written in loc.
+func New(loc *time.Location) *Inferrer {
+	return &Inferrer{loc: loc}
+}
+
+// Load returns the location LOG_TIMEZONE names: an IANA zone such as
+// "Europe/Madrid", "UTC", or "Local" for the zone of the host (the lab
+// containers mount its /etc/localtime).
+func Load(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Location returns the zone stamps are read in.
+func (i *Inferrer) Location() *time.Location { return i.loc }
+
+// Infer returns the time of stamp, an Open5GS or time-of-day stamp, at the
+// latest date that is not after anchor (plus Skew).
+func (i *Inferrer) Infer(stamp string, anchor time.Time) (time.Time, error) {
+	limit := anchor.Add(Skew).In(i.loc)
+	switch {
+	case open5gsStamp.MatchString(stamp):
+		t, err := time.ParseInLocation(Open5GSLayout, stamp, i.loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// February 29th only exists in leap years, at most 8 years back.
+		for y := limit.Year(); y > limit.Year()-8; y-- {
+			c := time.Date(y, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), i.loc)
+			if c.Month() == t.Month() && !c.After(limit) {
+				return c, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("no date before %s matches %q", limit.Format(time.RFC3339), stamp)
+	case timeOfDayStamp.MatchString(stamp):
+		t, err := time.ParseInLocation(TimeOfDayLayout, stamp, i.loc)
+		if err != nil {
+			return time.Time{}, err
+		}
+		y, m, d := limit.Date()
+		c := time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), i.loc)
+		if c.After(limit) {
+			c = time.Date(y, m, d-1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), i.loc)
+		}
+		return c, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown timestamp format %q", stamp)
+}
@@ -0,0 +1,104 @@
+package logtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInfer(t *testing.T) {
+	madrid, err := Load("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		loc    *time.Location
+		stamp  string
+		anchor time.Time
+		want   time.Time
+	}{
+		{
+			name:   "open5gs same day",
+			loc:    time.UTC,
+			stamp:  "10/16 14:03:22.123",
+			anchor: time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC),
+			want:   time.Date(2026, 10, 16, 14, 3, 22, 123000000, time.UTC),
+		},
+		{
+			name:   "open5gs new year",
+			loc:    time.UTC,
+			stamp:  "12/31 23:59:58",
+			anchor: time.Date(2027, 1, 1, 0, 1, 0, 0, time.UTC),
+			want:   time.Date(2026, 12, 31, 23, 59, 58, 0, time.UTC),
+		},
+		{
+			name:   "open5gs within skew",
+			loc:    time.UTC,
+			stamp:  "10/16 15:03:00",
+			anchor: time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC),
+			want:   time.Date(2026, 10, 16, 15, 3, 0, 0, time.UTC),
+		},
+		{
+			name:   "open5gs beyond skew is last year",
+			loc:    time.UTC,
+			stamp:  "10/16 15:10:00",
+			anchor: time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC),
+			want:   time.Date(2025, 10, 16, 15, 10, 0, 0, time.UTC),
+		},
+		{
+			name:   "open5gs leap day",
+			loc:    time.UTC,
+			stamp:  "02/29 08:00:00",
+			anchor: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			want:   time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "open5gs local zone",
+			loc:    madrid,
+			stamp:  "10/16 14:03:22",
+			anchor: time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC),
+			want:   time.Date(2026, 10, 16, 12, 3, 22, 0, time.UTC),
+		},
+		{
+			name:   "time of day",
+			loc:    time.UTC,
+			stamp:  "14:03:22.5",
+			anchor: time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC),
+			want:   time.Date(2026, 10, 16, 14, 3, 22, 500000000, time.UTC),
+		},
+		{
+			name:   "time of day across midnight",
+			loc:    time.UTC,
+			stamp:  "23:59:00",
+			anchor: time.Date(2026, 10, 17, 0, 1, 0, 0, time.UTC),
+			want:   time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC),
+		},
+		{
+			name:   "time of day across new year",
+			loc:    time.UTC,
+			stamp:  "23:59:00",
+			anchor: time.Date(2027, 1, 1, 0, 1, 0, 0, time.UTC),
+			want:   time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.loc).Infer(tt.stamp, tt.anchor)
+			if err != nil {
+				t.Fatalf("Infer(%q): %v", tt.stamp, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Infer(%q) = %s, want %s", tt.stamp, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferRejects(t *testing.T) {
+	anchor := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+	for _, stamp := range []string{"", "2026-10-16T14:03:22Z", "10/16 14:03", "13/01 00:00:00", "25:00:00"} {
+		if got, err := New(time.UTC).Infer(stamp, anchor); err == nil {
+			t.Errorf("Infer(%q) = %s, want an error", stamp, got)
+		}
+	}
+}
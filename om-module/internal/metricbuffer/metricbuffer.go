@@ -0,0 +1,552 @@
+// Package metricbuffer keeps the lab's metrics through a Prometheus outage.
+// While Prometheus is up the buffer only remembers its scrape targets; once
+// Prometheus stops answering it scrapes those targets itself every interval
+// and appends the samples, time-stamped, to segment files on disk. When
+// Prometheus is back the segments are replayed, oldest first, through its
+// remote write receiver, so the gap of the outage fills in.
+//
+// Prometheus accepts the replayed samples only with remote write enabled
+// (--web.enable-remote-write-receiver) and an out-of-order window
+// (storage.tsdb.out_of_order_time_window) at least as long as MaxAge, since
+// it scrapes newer samples of the same series before the replay ends. The
+// rendered configuration variants get that window from the module.
+package metricbuffer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// targetsFile keeps the last known targets in Dir, so a module restarted
+// during an outage still knows what to scrape.
+const targetsFile = "targets.json"
+
+// Options configure the buffer.
+type Options struct {
+	PrometheusURL string
+	Dir           string
+	// MaxBytes bounds the segments on disk; the oldest are dropped beyond.
+	MaxBytes int64
+	// MaxAge is the oldest sample worth replaying; older records are
+	// dropped instead of being rejected by Prometheus.
+	MaxAge   time.Duration
+	Interval time.Duration // health check, and scrape during an outage
+	Timeout  time.Duration // per request
+}
+
+// Target is a scrape target as Prometheus last reported it.
+type Target struct {
+	URL    string            `json:"url"`
+	Labels map[string]string `json:"labels"` // job, instance and relabelled target labels
+}
+
+// Status is the API view of the buffer.
+type Status struct {
+	PrometheusUp bool   `json:"prometheus_up"`
+	OutageSince  string `json:"outage_since,omitempty"`
+	Targets      int    `json:"targets"`
+	Segments     int    `json:"segments"`
+	// PendingSamples and PendingBytes are buffered and not replayed yet.
+	PendingSamples int    `json:"pending_samples"`
+	PendingBytes   int64  `json:"pending_bytes"`
+	MaxBytes       int64  `json:"max_bytes"`
+	MaxAge         string `json:"max_age"`
+	Buffered       uint64 `json:"buffered"`
+	Backfilled     uint64 `json:"backfilled"`
+	// Rejected were refused by Prometheus, Dropped removed to stay within
+	// MaxBytes or MaxAge.
+	Rejected     uint64 `json:"rejected"`
+	Dropped      uint64 `json:"dropped"`
+	LastBackfill string `json:"last_backfill,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Buffer watches Prometheus and buffers the samples of its targets during
+// an outage.
+type Buffer struct {
+	opts   Options
+	client *http.Client
+
+	up       prometheus.Gauge
+	pending  prometheus.Gauge
+	bytes    prometheus.Gauge
+	targetsG prometheus.Gauge
+	samples  *prometheus.CounterVec
+
+	mu           sync.RWMutex
+	targets      []Target
+	segments     []*segment // oldest first; the last one is appended to
+	current      *segment   // segment of the ongoing outage, or nil
+	promUp       bool
+	outageSince  time.Time
+	counts       map[string]uint64
+	lastBackfill time.Time
+	lastErr      string
+	checked      time.Time
+}
+
+// New registers the om_metric_buffer_* metrics on reg and returns the
+// buffer, with the segments and targets a previous run left in opts.Dir.
+func New(reg prometheus.Registerer, opts Options) (*Buffer, error) {
+	segments, err := openSegments(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	b := &Buffer{
+		opts:     opts,
+		client:   httpclient.New("metricbuffer", 0),
+		segments: segments,
+		promUp:   true,
+		counts:   make(map[string]uint64),
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "metric_buffer", Name: "prometheus_up",
+			Help: "1 while Prometheus answers its readiness check, 0 while the module buffers its targets.",
+		}),
+		pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "metric_buffer", Name: "pending_samples",
+			Help: "Samples buffered on disk and not yet backfilled into Prometheus.",
+		}),
+		bytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "metric_buffer", Name: "bytes",
+			Help: "Disk space used by the buffer segments.",
+		}),
+		targetsG: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "metric_buffer", Name: "targets",
+			Help: "Scrape targets the buffer scrapes during a Prometheus outage.",
+		}),
+		samples: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "metric_buffer", Name: "samples_total",
+			Help: "Samples by outcome: buffered during an outage, backfilled afterwards, rejected by Prometheus or dropped to stay within the size and age limits.",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(b.up, b.pending, b.bytes, b.targetsG, b.samples)
+	if data, err := os.ReadFile(filepath.Join(opts.Dir, targetsFile)); err == nil {
+		_ = json.Unmarshal(data, &b.targets)
+	}
+	b.updateGauges()
+	return b, nil
+}
+
+// Run checks Prometheus every interval until ctx is cancelled.
+func (b *Buffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+	for {
+		err := b.tick(ctx)
+		switch {
+		case err == nil:
+			b.mu.Lock()
+			b.lastErr = ""
+			b.mu.Unlock()
+		case ctx.Err() == nil:
+			b.mu.Lock()
+			first := b.lastErr == ""
+			b.lastErr = err.Error()
+			b.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Metric buffer: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Buffer) tick(ctx context.Context) error {
+	now := time.Now()
+	up := b.ready(ctx)
+
+	b.mu.Lock()
+	b.checked = now
+	wasUp := b.promUp
+	b.promUp = up
+	switch {
+	case wasUp && !up:
+		b.outageSince = now
+		log.Printf("🛑 Prometheus unreachable — buffering %d targets in %s", len(b.targets), b.opts.Dir)
+	case !wasUp && up:
+		log.Printf("✅ Prometheus back after %s — backfilling %d samples", now.Sub(b.outageSince).Round(time.Second), b.pendingSamples())
+		b.outageSince = time.Time{}
+		b.current = nil
+	}
+	b.mu.Unlock()
+	b.updateGauges()
+
+	if !up {
+		return b.scrapeAll(ctx, now)
+	}
+	if err := b.refreshTargets(ctx); err != nil {
+		return err
+	}
+	return b.backfill(ctx)
+}
+
+// ready reports whether Prometheus answers its readiness endpoint.
+func (b *Buffer) ready(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(b.opts.PrometheusURL, "/")+"/-/ready", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// refreshTargets remembers the targets Prometheus scrapes successfully.
+func (b *Buffer) refreshTargets(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(b.opts.PrometheusURL, "/")+"/api/v1/targets?state=active", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("prometheus targets: unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			ActiveTargets []struct {
+				ScrapeURL string            `json:"scrapeUrl"`
+				Labels    map[string]string `json:"labels"`
+				Health    string            `json:"health"`
+			} `json:"activeTargets"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	targets := make([]Target, 0, len(body.Data.ActiveTargets))
+	for _, t := range body.Data.ActiveTargets {
+		if t.Health == "up" && t.ScrapeURL != "" {
+			targets = append(targets, Target{URL: t.ScrapeURL, Labels: t.Labels})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].URL < targets[j].URL })
+
+	b.mu.Lock()
+	b.targets = targets
+	b.mu.Unlock()
+	b.updateGauges()
+	if data, err := json.Marshal(targets); err == nil {
+		_ = os.WriteFile(filepath.Join(b.opts.Dir, targetsFile), data, 0o644)
+	}
+	return nil
+}
+
+// scrapeAll scrapes every known target once and appends the samples as one
+// record. Unreachable targets are skipped, as Prometheus would.
+func (b *Buffer) scrapeAll(ctx context.Context, now time.Time) error {
+	b.mu.RLock()
+	targets := b.targets
+	b.mu.RUnlock()
+
+	var all []series
+	var firstErr error
+	for _, t := range targets {
+		ss, err := b.scrape(ctx, t, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("scrape %s: %w", t.URL, err)
+			}
+			continue
+		}
+		all = append(all, ss...)
+	}
+	if len(all) == 0 {
+		return firstErr
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current == nil || b.current.bytes >= b.segmentBytes() {
+		b.current = newSegment(b.opts.Dir, now)
+		b.segments = append(b.segments, b.current)
+	}
+	if err := b.current.append(record{samples: len(all), time: now, payload: encodeWriteRequest(all)}); err != nil {
+		return err
+	}
+	b.counts["buffered"] += uint64(len(all))
+	b.samples.WithLabelValues("buffered").Add(float64(len(all)))
+	b.enforceLimit()
+	b.updateGaugesLocked()
+	return firstErr
+}
+
+// scrape returns the samples of one target, with its target labels and
+// stamped at now unless the exposition carries its own time stamps.
+func (b *Buffer) scrape(ctx context.Context, t Target, now time.Time) ([]series, error) {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []series
+	add := func(name string, m *dto.Metric, extra map[string]string, v float64) {
+		labels := make(map[string]string, len(t.Labels)+len(m.GetLabel())+len(extra)+1)
+		for k, v := range t.Labels {
+			labels[k] = v
+		}
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		for k, v := range extra {
+			labels[k] = v
+		}
+		labels[model.MetricNameLabel] = name
+		ms := now.UnixMilli()
+		if m.TimestampMs != nil {
+			ms = m.GetTimestampMs()
+		}
+		out = append(out, series{labels: labels, value: v, ms: ms})
+	}
+	for name, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, nil, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m, nil, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m, nil, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m, map[string]string{model.QuantileLabel: formatFloat(q.GetQuantile())}, q.GetValue())
+				}
+				add(name+"_sum", m, nil, s.GetSampleSum())
+				add(name+"_count", m, nil, float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				inf := false
+				for _, bk := range h.GetBucket() {
+					inf = inf || math.IsInf(bk.GetUpperBound(), +1)
+					add(name+"_bucket", m, map[string]string{model.BucketLabel: formatFloat(bk.GetUpperBound())}, float64(bk.GetCumulativeCount()))
+				}
+				if !inf {
+					add(name+"_bucket", m, map[string]string{model.BucketLabel: "+Inf"}, float64(h.GetSampleCount()))
+				}
+				add(name+"_sum", m, nil, h.GetSampleSum())
+				add(name+"_count", m, nil, float64(h.GetSampleCount()))
+			}
+		}
+	}
+	return out, nil
+}
+
+// backfill replays the segments of past outages, oldest first, and deletes
+// each once it is sent. A failed request stops the replay until the next
+// tick; records Prometheus rejects are dropped.
+func (b *Buffer) backfill(ctx context.Context) error {
+	for {
+		b.mu.RLock()
+		var s *segment
+		if len(b.segments) > 0 {
+			s = b.segments[0]
+		}
+		b.mu.RUnlock()
+		if s == nil {
+			return nil
+		}
+
+		var sent, rejected, expired int
+		var rejectErr error
+		cutoff := time.Now().Add(-b.opts.MaxAge)
+		err := readSegment(s.path, func(r record) error {
+			if r.time.Before(cutoff) {
+				expired += r.samples
+				return nil
+			}
+			err := b.write(ctx, r.payload)
+			var rej errRejected
+			switch {
+			case errors.As(err, &rej):
+				rejected += r.samples
+				rejectErr = err
+				return nil
+			case err != nil:
+				return err
+			}
+			sent += r.samples
+			return nil
+		})
+
+		b.mu.Lock()
+		b.counts["backfilled"] += uint64(sent)
+		b.counts["rejected"] += uint64(rejected)
+		b.counts["dropped"] += uint64(expired)
+		b.samples.WithLabelValues("backfilled").Add(float64(sent))
+		b.samples.WithLabelValues("rejected").Add(float64(rejected))
+		b.samples.WithLabelValues("dropped").Add(float64(expired))
+		if sent > 0 {
+			b.lastBackfill = time.Now()
+		}
+		if err == nil {
+			// Records sent before a failure are sent again with the rest
+			// of the segment; Prometheus ignores duplicate samples.
+			os.Remove(s.path)
+			b.segments = b.segments[1:]
+		}
+		b.updateGaugesLocked()
+		b.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+		if rejectErr != nil {
+			log.Printf("⚠️  Metric buffer: %d samples of %s rejected: %v", rejected, filepath.Base(s.path), rejectErr)
+		}
+	}
+}
+
+// segmentBytes is the size at which a new segment is opened, so that the
+// size limit drops a small part of the buffer at a time.
+func (b *Buffer) segmentBytes() int64 {
+	return max(b.opts.MaxBytes/16, 1<<20)
+}
+
+// enforceLimit removes the oldest segments while the buffer is larger than
+// MaxBytes. The segment being written is kept. Caller holds b.mu.
+func (b *Buffer) enforceLimit() {
+	for len(b.segments) > 1 && b.totalBytes() > b.opts.MaxBytes {
+		s := b.segments[0]
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("⚠️  Metric buffer: %v", err)
+			return
+		}
+		b.segments = b.segments[1:]
+		b.counts["dropped"] += uint64(s.samples)
+		b.samples.WithLabelValues("dropped").Add(float64(s.samples))
+		log.Printf("⚠️  Metric buffer above %d bytes — dropped %d samples of %s", b.opts.MaxBytes, s.samples, filepath.Base(s.path))
+	}
+}
+
+// totalBytes is the size of the segments. Caller holds b.mu.
+func (b *Buffer) totalBytes() int64 {
+	var n int64
+	for _, s := range b.segments {
+		n += s.bytes
+	}
+	return n
+}
+
+// pendingSamples counts the samples of the segments. Caller holds b.mu.
+func (b *Buffer) pendingSamples() int {
+	n := 0
+	for _, s := range b.segments {
+		n += s.samples
+	}
+	return n
+}
+
+func (b *Buffer) updateGauges() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	b.updateGaugesLocked()
+}
+
+func (b *Buffer) updateGaugesLocked() {
+	up := 0.0
+	if b.promUp {
+		up = 1
+	}
+	b.up.Set(up)
+	b.pending.Set(float64(b.pendingSamples()))
+	b.bytes.Set(float64(b.totalBytes()))
+	b.targetsG.Set(float64(len(b.targets)))
+}
+
+// Status returns the state of the buffer.
+func (b *Buffer) Status() Status {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s := Status{
+		PrometheusUp:   b.promUp,
+		Targets:        len(b.targets),
+		Segments:       len(b.segments),
+		PendingSamples: b.pendingSamples(),
+		PendingBytes:   b.totalBytes(),
+		MaxBytes:       b.opts.MaxBytes,
+		MaxAge:         b.opts.MaxAge.String(),
+		Buffered:       b.counts["buffered"],
+		Backfilled:     b.counts["backfilled"],
+		Rejected:       b.counts["rejected"],
+		Dropped:        b.counts["dropped"],
+		Error:          b.lastErr,
+	}
+	if !b.outageSince.IsZero() {
+		s.OutageSince = b.outageSince.UTC().Format(time.RFC3339)
+	}
+	if !b.lastBackfill.IsZero() {
+		s.LastBackfill = b.lastBackfill.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the om_metric_buffer_* gauges were last updated,
+// for exporter.Ages.
+func (b *Buffer) Freshness() map[string]time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.checked.IsZero() {
+		return nil
+	}
+	out := make(map[string]time.Time, 4)
+	for _, f := range []string{"prometheus_up", "pending_samples", "bytes", "targets"} {
+		out["om_metric_buffer_"+f] = b.checked
+	}
+	return out
+}
+
+// formatFloat writes a bucket bound or quantile as Prometheus does.
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return model.SampleValue(f).String()
+}
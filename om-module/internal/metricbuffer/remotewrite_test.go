@@ -0,0 +1,155 @@
+package metricbuffer
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// snappyBlock switches literal tag at 60 and 256 bytes and splits the
+// input every 65536; the reference decoder must read every size back.
+func TestSnappyBlockRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 59, 60, 61, 255, 256, 257, 65535, 65536, 65537, 3*65536 + 7} {
+		src := make([]byte, n)
+		for i := range src {
+			src[i] = byte(i * 7)
+		}
+		block := snappyBlock(src)
+		if got, err := snappy.DecodedLen(block); err != nil || got != n {
+			t.Errorf("%d bytes: decoded length %d, %v", n, got, err)
+			continue
+		}
+		got, err := snappy.Decode(nil, block)
+		if err != nil {
+			t.Errorf("%d bytes: decode: %v", n, err)
+			continue
+		}
+		if !bytes.Equal(got, src) {
+			t.Errorf("%d bytes: round trip changed the data", n)
+		}
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []series
+	}{
+		{name: "empty"},
+		{
+			name: "labels sorted",
+			in: []series{{
+				labels: map[string]string{"job": "open5gs", "__name__": "amf_session", "instance": "amf:9090", "generation": "5g"},
+				value:  3, ms: 1700000000000,
+			}},
+		},
+		{
+			name: "several series",
+			in: []series{
+				{labels: map[string]string{"__name__": "up", "job": "smf"}, value: 1, ms: 1},
+				{labels: map[string]string{"__name__": "ratio", "b": "", "a": "x"}, value: math.Inf(-1), ms: 1700000000123},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeWriteRequest(t, encodeWriteRequest(tt.in))
+			if len(got) != len(tt.in) {
+				t.Fatalf("decoded %d series, want %d", len(got), len(tt.in))
+			}
+			for i, s := range got {
+				want := tt.in[i]
+				if len(s.names) != len(want.labels) {
+					t.Errorf("series %d: %d labels, want %d", i, len(s.names), len(want.labels))
+				}
+				for j, n := range s.names {
+					if j > 0 && s.names[j-1] >= n {
+						t.Errorf("series %d: labels not sorted: %q before %q", i, s.names[j-1], n)
+					}
+					if s.values[j] != want.labels[n] {
+						t.Errorf("series %d: label %s = %q, want %q", i, n, s.values[j], want.labels[n])
+					}
+				}
+				if s.value != want.value || s.ms != want.ms {
+					t.Errorf("series %d: sample (%v, %d), want (%v, %d)", i, s.value, s.ms, want.value, want.ms)
+				}
+			}
+		})
+	}
+}
+
+type decodedSeries struct {
+	names, values []string
+	value         float64
+	ms            int64
+}
+
+// decodeWriteRequest parses a prompb.WriteRequest field by field.
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+	var out []decodedSeries
+	for _, ts := range fields(t, b, 1) {
+		var s decodedSeries
+		for _, l := range fields(t, ts, 1) {
+			s.names = append(s.names, string(fields(t, l, 1)[0]))
+			s.values = append(s.values, string(fields(t, l, 2)[0]))
+		}
+		smp := fields(t, ts, 2)
+		if len(smp) != 1 {
+			t.Fatalf("%d samples in a series, want 1", len(smp))
+		}
+		for b := smp[0]; len(b) > 0; {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("sample tag: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			switch {
+			case num == 1 && typ == protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(b)
+				if n < 0 {
+					t.Fatalf("sample value: %v", protowire.ParseError(n))
+				}
+				s.value, b = math.Float64frombits(v), b[n:]
+			case num == 2 && typ == protowire.VarintType:
+				v, n := protowire.ConsumeVarint(b)
+				if n < 0 {
+					t.Fatalf("sample timestamp: %v", protowire.ParseError(n))
+				}
+				s.ms, b = int64(v), b[n:]
+			default:
+				t.Fatalf("unexpected sample field %d (type %d)", num, typ)
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// fields returns the values of field num of message b. Every field of the
+// messages checked is length-delimited.
+func fields(t *testing.T, b []byte, num protowire.Number) [][]byte {
+	t.Helper()
+	var out [][]byte
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			t.Fatalf("tag: %v", protowire.ParseError(l))
+		}
+		if typ != protowire.BytesType {
+			t.Fatalf("field %d has wire type %d, want bytes", n, typ)
+		}
+		v, m := protowire.ConsumeBytes(b[l:])
+		if m < 0 {
+			t.Fatalf("field %d: %v", n, protowire.ParseError(m))
+		}
+		if n == num {
+			out = append(out, v)
+		}
+		b = b[l+m:]
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package metricbuffer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A segment is a file of records, each the samples of one scrape round as
+// a remote write request:
+//
+//	uvarint samples | uvarint time (Unix ms) | uvarint length | WriteRequest
+//
+// Segments are named after the time they were opened, so that lexical
+// order is age order, and only ever appended to.
+const segmentExt = ".seg"
+
+type segment struct {
+	path    string
+	bytes   int64
+	samples int
+}
+
+type record struct {
+	samples int
+	time    time.Time
+	payload []byte
+}
+
+// openSegments lists the segments left in dir, oldest first. A record cut
+// short by a crash ends its segment; what came before is kept.
+func openSegments(dir string) ([]*segment, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []*segment
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		s := &segment{path: filepath.Join(dir, e.Name())}
+		err := readSegment(s.path, func(r record) error {
+			s.samples += r.samples
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if info, err := e.Info(); err == nil {
+			s.bytes = info.Size()
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out, nil
+}
+
+// newSegment names a segment opened at t in dir.
+func newSegment(dir string, t time.Time) *segment {
+	return &segment{path: filepath.Join(dir, fmt.Sprintf("%020d%s", t.UnixNano(), segmentExt))}
+}
+
+// append writes r at the end of s and syncs it, so a crash loses at most
+// the round being written.
+func (s *segment) append(r record) error {
+	var hdr []byte
+	hdr = binary.AppendUvarint(hdr, uint64(r.samples))
+	hdr = binary.AppendUvarint(hdr, uint64(r.time.UnixMilli()))
+	hdr = binary.AppendUvarint(hdr, uint64(len(r.payload)))
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(hdr, r.payload...)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.bytes += int64(len(hdr) + len(r.payload))
+	s.samples += r.samples
+	return nil
+}
+
+// readSegment calls fn with every record of the segment at p, in order,
+// until fn returns an error.
+func readSegment(p string, fn func(record) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	for {
+		samples, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return nil // truncated header
+		}
+		ms, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil
+		}
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return nil // truncated payload
+		}
+		if err := fn(record{samples: int(samples), time: time.UnixMilli(int64(ms)), payload: payload}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package metriccatalog describes every metric the module exports — name,
+// type, help, category, the components it has series for and its labels —
+// from the registry itself, so the glossary on the educational page and
+// generated documentation never drift from what /metrics serves.
+package metriccatalog
+
+import (
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// componentLabels are the labels that name the NF or container a series
+// belongs to, in order of preference.
+var componentLabels = []string{"nf", "service", "container", "component"}
+
+// categoryOverrides group metrics whose name prefix is not their subsystem.
+// The first matching prefix wins; other om_* metrics are categorised by the
+// first word after "om_".
+var categoryOverrides = []struct{ prefix, category string }{
+	{"container_", "containers"},
+	{"om_nas_reject", "causes"},
+	{"om_ap_cause", "causes"},
+	{"om_sip_", "ims"},
+}
+
+// Metric is one catalog entry.
+type Metric struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"` // counter, gauge, histogram, summary, untyped
+	Help       string            `json:"help"`
+	Category   string            `json:"category"`
+	Components []string          `json:"components"` // NFs/containers with series, sorted
+	Labels     []string          `json:"labels"`     // label names across all series, sorted
+	Sample     map[string]string `json:"sample_labels,omitempty"`
+	Series     int               `json:"series"`
+}
+
+// Category is the number of metrics in one category.
+type Category struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Build turns gathered metric families into catalog entries, sorted by
+// category and name.
+func Build(mfs []*dto.MetricFamily) []Metric {
+	out := make([]Metric, 0, len(mfs))
+	for _, mf := range mfs {
+		m := Metric{
+			Name:       mf.GetName(),
+			Type:       strings.ToLower(mf.GetType().String()),
+			Help:       mf.GetHelp(),
+			Category:   categoryOf(mf.GetName()),
+			Components: []string{},
+			Labels:     []string{},
+			Series:     len(mf.GetMetric()),
+		}
+		labels := make(map[string]bool)
+		components := make(map[string]bool)
+		for i, series := range mf.GetMetric() {
+			byName := make(map[string]string, len(series.GetLabel()))
+			for _, lp := range series.GetLabel() {
+				labels[lp.GetName()] = true
+				byName[lp.GetName()] = lp.GetValue()
+			}
+			if i == 0 && len(byName) > 0 {
+				m.Sample = byName
+			}
+			for _, l := range componentLabels {
+				if v := byName[l]; v != "" {
+					components[v] = true
+					break
+				}
+			}
+		}
+		m.Labels = sortedKeys(labels)
+		m.Components = sortedKeys(components)
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Category != out[j].Category {
+			return out[i].Category < out[j].Category
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Filter returns the entries in category (all when empty) whose name, help,
+// category, labels or components contain query, case-insensitively.
+func Filter(metrics []Metric, query, category string) []Metric {
+	query = strings.ToLower(strings.TrimSpace(query))
+	out := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if category != "" && m.Category != category {
+			continue
+		}
+		if query != "" && !matches(m, query) {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// Categories counts the entries per category, sorted by name.
+func Categories(metrics []Metric) []Category {
+	counts := make(map[string]int)
+	for _, m := range metrics {
+		counts[m.Category]++
+	}
+	out := make([]Category, 0, len(counts))
+	for _, name := range sortedKeys(counts) {
+		out = append(out, Category{Name: name, Count: counts[name]})
+	}
+	return out
+}
+
+func matches(m Metric, query string) bool {
+	fields := append([]string{m.Name, m.Help, m.Category}, m.Labels...)
+	fields = append(fields, m.Components...)
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func categoryOf(name string) string {
+	for _, o := range categoryOverrides {
+		if strings.HasPrefix(name, o.prefix) {
+			return o.category
+		}
+	}
+	if rest, ok := strings.CutPrefix(name, "om_"); ok {
+		if word, _, found := strings.Cut(rest, "_"); found {
+			return word
+		}
+		return rest
+	}
+	return "other"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
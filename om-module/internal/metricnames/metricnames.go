@@ -0,0 +1,118 @@
+// Package metricnames maps raw metric names such as
+// fivegs_amffunction_rm_reginitreq to friendly titles and descriptions, so
+// students read "Registros iniciales solicitados" instead of a 3GPP counter
+// name. A curated mapping is embedded (names.yaml); a file in the same
+// format extends it, its entries replacing embedded ones of the same name:
+//
+//	fivegs_amffunction_rm_reginitreq:
+//	  nf: amf
+//	  title: Registros iniciales solicitados
+//	  description: Registration Request de tipo «initial registration» …
+package metricnames
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+//go:embed names.yaml
+var embedded []byte
+
+// Name is the friendly name of one metric.
+type Name struct {
+	Metric      string `yaml:"-" json:"metric"`
+	NF          string `yaml:"nf,omitempty" json:"nf,omitempty"` // NF that exports it; empty when several do
+	Title       string `yaml:"title" json:"title"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// Map resolves metric names to their Name. A nil Map knows no metric.
+type Map struct {
+	names map[string]Name
+}
+
+// Default returns the embedded mapping.
+func Default() *Map {
+	m := &Map{names: make(map[string]Name)}
+	if err := m.merge(embedded); err != nil {
+		panic("metricnames: embedded names.yaml: " + err.Error())
+	}
+	return m
+}
+
+// Load returns the embedded mapping extended with the file at p.
+func Load(p string) (*Map, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	m := Default()
+	if err := m.merge(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return m, nil
+}
+
+func (m *Map) merge(data []byte) error {
+	var entries map[string]Name
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return err
+	}
+	for metric, n := range entries {
+		if strings.TrimSpace(n.Title) == "" {
+			return fmt.Errorf("metric %q: empty title", metric)
+		}
+		n.Metric = metric
+		m.names[metric] = n
+	}
+	return nil
+}
+
+// Lookup returns the friendly name of metric.
+func (m *Map) Lookup(metric string) (Name, bool) {
+	if m == nil {
+		return Name{}, false
+	}
+	n, ok := m.names[metric]
+	return n, ok
+}
+
+// Title returns the friendly title of metric, or metric itself when it has
+// none.
+func (m *Map) Title(metric string) string {
+	if n, ok := m.Lookup(metric); ok {
+		return n.Title
+	}
+	return metric
+}
+
+// All returns every entry, sorted by NF and metric name.
+func (m *Map) All() []Name {
+	if m == nil {
+		return []Name{}
+	}
+	out := make([]Name, 0, len(m.names))
+	for _, n := range m.names {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].NF != out[j].NF {
+			return out[i].NF < out[j].NF
+		}
+		return out[i].Metric < out[j].Metric
+	})
+	return out
+}
+
+// Len returns the number of entries.
+func (m *Map) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.names)
+}
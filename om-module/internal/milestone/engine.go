@@ -0,0 +1,227 @@
+package milestone
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Milestone IDs. Each one is achieved at most once per lab session.
+const (
+	FirstENBConnected = "first_enb_connected"
+	FirstGNBConnected = "first_gnb_connected"
+	FirstUEAttached   = "first_ue_attached"
+	FirstUERegistered = "first_ue_registered"
+	FirstDefaultPDN   = "first_default_bearer"
+	FirstPDUSession   = "first_pdu_session"
+	FirstHandover     = "first_handover"
+)
+
+// definition describes a milestone for students.
+type definition struct {
+	ID          string
+	Generation  string
+	Title       string
+	Description string
+}
+
+// definitions is the ordered catalogue of milestones. The order is the order
+// in which a student normally reaches them in E1–E4.
+var definitions = []definition{
+	{FirstENBConnected, "4g", "First eNB connected",
+		"The MME answered an S1 Setup — the eNB is now part of the network."},
+	{FirstUEAttached, "4g", "First UE attached",
+		"The MME sent an Attach Accept — a UE completed the EPS attach procedure."},
+	{FirstDefaultPDN, "4g", "First default bearer",
+		"The SGW-C/PGW accepted a Create Session Request — the UE has an IP address."},
+	{FirstGNBConnected, "5g", "First gNB connected",
+		"The AMF answered an NG Setup — the gNB is now part of the network."},
+	{FirstUERegistered, "5g", "First UE registered",
+		"The AMF sent a Registration Accept — a UE completed 5G registration."},
+	{FirstPDUSession, "5g", "First PDU session",
+		"The gNB answered a PDU Session Resource Setup — the UE has a PDU session."},
+	{FirstHandover, "", "First handover",
+		"The RAN asked the core to prepare a handover (S1 Handover Preparation / NGAP Handover Required)."},
+}
+
+// Milestone is one achieved milestone.
+type Milestone struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Generation  string `json:"generation"`
+	AchievedAt  string `json:"achieved_at"`
+	IMSI        string `json:"imsi,omitempty"`
+	SrcNF       string `json:"src_nf"`
+	DstNF       string `json:"dst_nf"`
+}
+
+// Status is the API view of the milestone engine.
+type Status struct {
+	SessionStarted string      `json:"session_started"`
+	Achieved       []Milestone `json:"achieved"`
+	Pending        []Milestone `json:"pending"`
+}
+
+// Engine watches captured signalling for first-time events in a lab session
+// and announces each one once: log line, API, Grafana annotation and an
+// optional webhook.
+type Engine struct {
+	notifier *notifier
+	achieved *prometheus.GaugeVec
+
+	mu           sync.Mutex
+	sessionStart time.Time
+	done         map[string]Milestone
+}
+
+// New creates an Engine and registers its metrics on reg. grafanaClient may
+// be nil and webhookURL empty to disable the respective notification;
+// webhookTimeout bounds each webhook call. Notifications still in flight are
+// abandoned when ctx is cancelled.
+func New(ctx context.Context, reg prometheus.Registerer, grafanaClient *grafana.Client, webhookURL string, webhookTimeout time.Duration) *Engine {
+	e := &Engine{
+		notifier: newNotifier(ctx, grafanaClient, webhookURL, webhookTimeout),
+		achieved: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om",
+			Subsystem: "milestone",
+			Name:      "achieved_timestamp_seconds",
+			Help:      "Unix time at which a lab milestone was first achieved in the current session (absent until achieved).",
+		}, []string{"milestone", "generation"}),
+		sessionStart: time.Now(),
+		done:         make(map[string]Milestone),
+	}
+	reg.MustRegister(e.achieved)
+	return e
+}
+
+// Observe implements pipeline.Observer.
+func (e *Engine) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	id := detect(pkt, dstNF)
+	if id == "" {
+		return
+	}
+
+	e.mu.Lock()
+	if _, ok := e.done[id]; ok {
+		e.mu.Unlock()
+		return
+	}
+	def := lookup(id)
+	m := Milestone{
+		ID:          def.ID,
+		Title:       def.Title,
+		Description: def.Description,
+		Generation:  def.Generation,
+		AchievedAt:  pkt.Timestamp.UTC().Format(time.RFC3339Nano),
+		IMSI:        packetIMSI(pkt),
+		SrcNF:       srcNF,
+		DstNF:       dstNF,
+	}
+	if m.Generation == "" {
+		m.Generation = pkt.Generation
+	}
+	e.done[id] = m
+	e.mu.Unlock()
+
+	e.achieved.WithLabelValues(m.ID, m.Generation).Set(float64(pkt.Timestamp.Unix()))
+	log.Printf("🏆 Milestone reached: %s (%s → %s)", m.Title, srcNF, dstNF)
+	e.notifier.announce(m, pkt.Timestamp)
+}
+
+// Status returns achieved milestones (in achievement order) and the ones
+// still pending in the current session.
+func (e *Engine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := Status{
+		SessionStarted: e.sessionStart.UTC().Format(time.RFC3339),
+		Achieved:       []Milestone{},
+		Pending:        []Milestone{},
+	}
+	for _, def := range definitions {
+		if m, ok := e.done[def.ID]; ok {
+			st.Achieved = append(st.Achieved, m)
+			continue
+		}
+		st.Pending = append(st.Pending, Milestone{
+			ID: def.ID, Title: def.Title, Description: def.Description, Generation: def.Generation,
+		})
+	}
+	sort.SliceStable(st.Achieved, func(i, j int) bool { return st.Achieved[i].AchievedAt < st.Achieved[j].AchievedAt })
+	return st
+}
+
+// Reset starts a new lab session: all milestones become pending again.
+func (e *Engine) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sessionStart = time.Now()
+	e.done = make(map[string]Milestone)
+	e.achieved.Reset()
+	log.Printf("🏆 Milestones reset — new lab session")
+}
+
+// detect maps a packet to the milestone it proves, or "". Setup procedures
+// only count once the core answers (or, for PDU sessions, once the gNB
+// answers the core), so a rejected request never unlocks a milestone.
+func detect(pkt capture.Packet, dstNF string) string {
+	dst := collector.ParseNFKind(dstNF)
+	switch pkt.Protocol {
+	case "s1ap":
+		switch {
+		case pkt.S1APProcedureCode == 17 && dst != collector.KindMME:
+			return FirstENBConnected
+		case strings.EqualFold(pkt.NASEMMType, "0x42"):
+			return FirstUEAttached
+		case pkt.S1APProcedureCode == 0 && pkt.APMessageType == "initiating":
+			return FirstHandover
+		}
+	case "ngap":
+		switch {
+		case pkt.NGAPProcedureCode == 21 && dst != collector.KindAMF:
+			return FirstGNBConnected
+		case strings.EqualFold(pkt.NASMMType, "0x42"):
+			return FirstUERegistered
+		case pkt.NGAPProcedureCode == 29 && dst == collector.KindAMF:
+			return FirstPDUSession
+		case pkt.NGAPProcedureCode == 12 && pkt.APMessageType == "initiating":
+			return FirstHandover
+		}
+	case "gtpv2":
+		if pkt.GTPv2MessageType == 33 && pkt.GTPv2Cause == "16" {
+			return FirstDefaultPDN
+		}
+	}
+	return ""
+}
+
+func lookup(id string) definition {
+	for _, d := range definitions {
+		if d.ID == id {
+			return d
+		}
+	}
+	return definition{ID: id, Title: id}
+}
+
+func packetIMSI(pkt capture.Packet) string {
+	switch {
+	case pkt.IMSI != "":
+		return pkt.IMSI
+	case pkt.GTPv2IMSI != "":
+		return pkt.GTPv2IMSI
+	case pkt.PFCPIMSI != "":
+		return pkt.PFCPIMSI
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package milestone
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+)
+
+// notifier pushes achieved milestones to Grafana (as annotations) and to an
+// optional webhook. Both are best-effort: failures are logged. The Grafana
+// client retries transient errors; the webhook is called once.
+//
+// Notifications run in the background, detached from the packet that
+// triggered them, so the notifier keeps the module's root context and stops
+// in-flight calls when it is cancelled at shutdown.
+type notifier struct {
+	ctx            context.Context
+	grafana        *grafana.Client
+	webhookURL     string
+	webhookTimeout time.Duration
+	client         *http.Client
+}
+
+func newNotifier(ctx context.Context, grafanaClient *grafana.Client, webhookURL string, webhookTimeout time.Duration) *notifier {
+	return &notifier{
+		ctx:            ctx,
+		grafana:        grafanaClient,
+		webhookURL:     webhookURL,
+		webhookTimeout: webhookTimeout,
+		client:         httpclient.New("milestone", 0),
+	}
+}
+
+// announce sends m to every configured destination in the background.
+func (n *notifier) announce(m Milestone, at time.Time) {
+	if n.grafana != nil {
+		go n.postAnnotation(m, at)
+	}
+	if n.webhookURL != "" {
+		go n.postWebhook(m)
+	}
+}
+
+func (n *notifier) postAnnotation(m Milestone, at time.Time) {
+	a := grafana.Annotation{
+		Time: at.UnixMilli(),
+		Tags: []string{"milestone", m.ID, m.Generation},
+		Text: fmt.Sprintf("🏆 %s — %s", m.Title, m.Description),
+	}
+	if _, err := n.grafana.CreateAnnotation(n.ctx, a); err != nil {
+		log.Printf("⚠️  Milestone: Grafana annotation failed: %v", err)
+	}
+}
+
+func (n *notifier) postWebhook(m Milestone) {
+	if err := n.post(n.webhookURL, m); err != nil {
+		log.Printf("⚠️  Milestone: webhook failed: %v", err)
+	}
+}
+
+func (n *notifier) post(url string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("⚠️  Failed to close response body: %v", err)
+		}
+	}()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
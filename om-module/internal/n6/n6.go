@@ -0,0 +1,515 @@
+// Package n6 checks the path from the UPF to the data network, so "the UE
+// attached but has no internet" — the most common lab problem — can be told
+// apart from a core problem. In docker_open5gs the UPF (the PGW-U in 4G)
+// terminates N6/SGi itself: UE packets leave its tun interfaces (ogstun,
+// ogstun2, …) and reach the internet through the Docker bridge after an
+// iptables MASQUERADE rule for the UE pool. A lab may add a data network
+// container of its own (om.nf "dn"), e.g. an application or iperf server.
+//
+// Every interval the Prober runs inside each UPF container: it reads IP
+// forwarding, the NAT rules and the connection tracking table, and pings
+// the internet target from the UPF's own address and from the gateway
+// address of each UE pool, which goes through the NAT like a UE packet
+// does, and every DN container.
+package n6
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const networkName = "docker_open5gs_default"
+
+// NFDN is the om.nf label of data network containers.
+const NFDN = "dn"
+
+// Spec lists the specifications behind the N6 checks.
+const Spec = "3GPP TS 23.501 §5.6 (data network, N6), TS 29.061 §11 (SGi, IP access to the PDN), RFC 3022 (NAT)"
+
+// SourceUPF is the source of the pings sent from the UPF's own address.
+const SourceUPF = "upf"
+
+// Kinds of finding.
+const (
+	FindingForwarding = "ip_forward_off"
+	FindingNoNAT      = "no_nat_rule"
+	FindingNoInternet = "upf_no_internet"
+	FindingNATPath    = "nat_path_broken"
+	FindingNATFull    = "nat_table_full"
+	FindingDN         = "dn_unreachable"
+)
+
+// natFullRatio is the fill of the connection tracking table above which
+// new UE flows risk being dropped.
+const natFullRatio = 0.9
+
+// hints tell the lab what usually causes each finding.
+var hints = map[string]string{
+	FindingForwarding: "The UPF drops the UE packets instead of routing them: set net.ipv4.ip_forward=1 (sysctls of the upf service) and recreate the container.",
+	FindingNoNAT: "UE packets leave the UPF with their private UE pool address and the answers never come back. upf_init.sh adds the MASQUERADE rule through tun_if.py; " +
+		"check its output in the UPF log, or that UE_IPV4_INTERNET matches the subnet of the SMF/UPF configuration.",
+	FindingNoInternet: "The UPF itself cannot reach the internet, so no UE can: the problem is outside the 5G core — the host's internet access, its firewall, " +
+		"or Docker's own NAT for the network (iptables=false in the Docker daemon, a VPN on the host).",
+	FindingNATPath: "The UPF reaches the internet from its own address but not from the UE pool: the MASQUERADE rule does not match (wrong subnet, or the outgoing " +
+		"interface excluded), or another rule drops forwarded traffic (iptables -S FORWARD in the UPF).",
+	FindingNATFull: "The connection tracking table, which holds the NAT translations, is nearly full: new UE flows are dropped. Raise nf_conntrack_max on the host " +
+		"or look for a UE flooding connections.",
+	FindingDN: "The data network container does not answer from the UPF: check that it runs on the same Docker network and that it routes the UE pool back through the UPF.",
+}
+
+// Finding is one problem found on the N6 path of a UPF.
+type Finding struct {
+	Kind    string `json:"kind"`
+	Problem string `json:"problem"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Pool is one UE pool of the UPF: its tun interface and gateway address.
+type Pool struct {
+	Interface string `json:"interface"`
+	Subnet    string `json:"subnet"`
+	Gateway   string `json:"gateway"`
+	NAT       bool   `json:"nat"` // a MASQUERADE rule covers the subnet
+}
+
+// Ping is the last ping from one source of a UPF to one target.
+type Ping struct {
+	Source     string  `json:"source"` // "upf" or the tun interface of a UE pool
+	Target     string  `json:"target"`
+	Kind       string  `json:"kind"` // internet | dn
+	Reachable  bool    `json:"reachable"`
+	RTTSeconds float64 `json:"rtt_seconds,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Result is the last check of one UPF container.
+type Result struct {
+	Container  string    `json:"container"`
+	IPForward  bool      `json:"ip_forward"`
+	Pools      []Pool    `json:"pools"`
+	NATRules   []string  `json:"nat_rules"`
+	NATEntries int       `json:"nat_entries"`
+	NATMax     int       `json:"nat_max,omitempty"`
+	Pings      []Ping    `json:"pings"`
+	Findings   []Finding `json:"findings"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  string    `json:"checked_at"`
+}
+
+// Prober checks the N6 path of every running UPF container.
+type Prober struct {
+	docker   *dockerclient.Client
+	snap     *collector.Snapshot
+	interval time.Duration
+	timeout  time.Duration
+	target   string
+
+	reachable  *prometheus.GaugeVec
+	rtt        *prometheus.GaugeVec
+	ipForward  *prometheus.GaugeVec
+	natRules   *prometheus.GaugeVec
+	natEntries *prometheus.GaugeVec
+	natMax     *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	results map[string]Result // keyed by container name
+	probed  time.Time         // end of the last complete probe round
+}
+
+// NewProber registers the N6 metrics on reg. target is the internet address
+// pinged from every UPF; timeout bounds each ping.
+func NewProber(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, interval, timeout time.Duration, target string) *Prober {
+	p := &Prober{
+		docker:   docker,
+		snap:     snap,
+		interval: interval,
+		timeout:  timeout,
+		target:   target,
+		reachable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "reachable",
+			Help: "1 if the target (kind internet or dn) answered the last ping from the UPF, sent from its own address (source upf) or from a UE pool (source = tun interface).",
+		}, []string{"upf", "source", "target", "kind"}),
+		rtt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "rtt_seconds",
+			Help: "Round-trip time of the last answered ping from the UPF to the target.",
+		}, []string{"upf", "source", "target", "kind"}),
+		ipForward: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "ip_forward",
+			Help: "1 if IP forwarding is on in the UPF container, 0 if UE packets are dropped.",
+		}, []string{"upf"}),
+		natRules: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "nat_rules",
+			Help: "MASQUERADE rules in the POSTROUTING chain of the UPF container.",
+		}, []string{"upf"}),
+		natEntries: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "nat_entries",
+			Help: "Entries of the connection tracking table (NAT translations) of the UPF container.",
+		}, []string{"upf"}),
+		natMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "n6", Name: "nat_entries_max",
+			Help: "Size of the connection tracking table of the UPF container (nf_conntrack_max).",
+		}, []string{"upf"}),
+		results: make(map[string]Result),
+	}
+	reg.MustRegister(p.reachable, p.rtt, p.ipForward, p.natRules, p.natEntries, p.natMax)
+	return p
+}
+
+// Run probes every interval until ctx is cancelled.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probeAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Results returns the last check of every UPF, sorted by container name.
+func (p *Prober) Results() []Result {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]Result, 0, len(p.results))
+	for _, r := range p.results {
+		r.Findings = append([]Finding{}, r.Findings...)
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+// Freshness returns when the om_n6_* metrics were last refreshed by a
+// complete probe round, for exporter.Ages. Without UPF containers there
+// are no such metrics and it returns nil.
+func (p *Prober) Freshness() map[string]time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.results) == 0 || p.probed.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{
+		"om_n6_reachable":       p.probed,
+		"om_n6_rtt_seconds":     p.probed,
+		"om_n6_ip_forward":      p.probed,
+		"om_n6_nat_rules":       p.probed,
+		"om_n6_nat_entries":     p.probed,
+		"om_n6_nat_entries_max": p.probed,
+	}
+}
+
+func (p *Prober) probeAll(ctx context.Context) {
+	upfs := make(map[string]bool)
+	var dns []string
+	for name, cd := range p.snap.All() {
+		if cd.State != "running" {
+			continue
+		}
+		switch {
+		// The SGW-U of the 4G core is not on N6/SGi.
+		case cd.NFKind == collector.KindUPF:
+			upfs[name] = true
+		case cd.NF == NFDN:
+			dns = append(dns, name)
+		}
+	}
+	sort.Strings(dns)
+
+	p.mu.Lock()
+	for name := range p.results {
+		if !upfs[name] {
+			delete(p.results, name)
+			p.forget(name)
+		}
+	}
+	p.mu.Unlock()
+	if len(upfs) == 0 {
+		return
+	}
+
+	var dnIPs []string
+	if len(dns) > 0 {
+		ipToName, err := p.docker.GetNetworkContainerIPs(ctx, networkName)
+		if err != nil {
+			log.Printf("⚠️  N6 probe: %v", err)
+		}
+		for ip, name := range ipToName {
+			for _, dn := range dns {
+				if name == dn {
+					dnIPs = append(dnIPs, ip)
+				}
+			}
+		}
+		sort.Strings(dnIPs)
+	}
+
+	for name := range upfs {
+		p.record(p.probe(ctx, name, dnIPs))
+	}
+
+	p.mu.Lock()
+	p.probed = time.Now()
+	p.mu.Unlock()
+}
+
+// stateScript prints the N6 state of a UPF container, one key=value line
+// per item: IP forwarding, the connection tracking table, the IPv4
+// address of every tun/tap interface and the MASQUERADE rules.
+const stateScript = `echo "ip_forward=$(cat /proc/sys/net/ipv4/ip_forward 2>/dev/null)"
+echo "conntrack_count=$(cat /proc/sys/net/netfilter/nf_conntrack_count 2>/dev/null)"
+echo "conntrack_max=$(cat /proc/sys/net/netfilter/nf_conntrack_max 2>/dev/null)"
+for d in /sys/class/net/*; do
+  [ -e "$d/tun_flags" ] || continue
+  ip -o -4 addr show dev "${d##*/}" | awk '{print "tun=" $2 " " $4}'
+done
+iptables-save -t nat 2>/dev/null | grep -- '-A POSTROUTING' | grep MASQUERADE | sed 's/^/nat=/'`
+
+// probe checks one UPF container.
+func (p *Prober) probe(ctx context.Context, name string, dnIPs []string) Result {
+	r := Result{
+		Container: name,
+		Pools:     []Pool{},
+		NATRules:  []string{},
+		Pings:     []Ping{},
+		Findings:  []Finding{},
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	out, code, err := p.docker.Exec(ctx, name, []string{"sh", "-c", stateScript})
+	if err != nil || code != 0 {
+		if err == nil {
+			err = fmt.Errorf("exit %d: %s", code, strings.TrimSpace(out))
+		}
+		r.Error = "read N6 state: " + err.Error()
+		return r
+	}
+	parseState(&r, out)
+
+	r.Pings = append(r.Pings, p.ping(ctx, name, SourceUPF, "", p.target, "internet"))
+	for _, pool := range r.Pools {
+		r.Pings = append(r.Pings, p.ping(ctx, name, pool.Interface, pool.Gateway, p.target, "internet"))
+	}
+	for _, ip := range dnIPs {
+		r.Pings = append(r.Pings, p.ping(ctx, name, SourceUPF, "", ip, "dn"))
+	}
+	r.Findings = diagnose(r)
+	return r
+}
+
+// parseState fills r from the output of stateScript.
+func parseState(r *Result, out string) {
+	var rules []string
+	for _, line := range strings.Split(out, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "ip_forward":
+			r.IPForward = val == "1"
+		case "conntrack_count":
+			r.NATEntries, _ = strconv.Atoi(val)
+		case "conntrack_max":
+			r.NATMax, _ = strconv.Atoi(val)
+		case "tun":
+			ifname, cidr, _ := strings.Cut(val, " ")
+			ip, subnet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			r.Pools = append(r.Pools, Pool{Interface: ifname, Subnet: subnet.String(), Gateway: ip.String()})
+		case "nat":
+			rules = append(rules, val)
+		}
+	}
+	r.NATRules = append(r.NATRules, rules...)
+	for i := range r.Pools {
+		r.Pools[i].NAT = natCovers(rules, r.Pools[i])
+	}
+}
+
+// natCovers reports whether a MASQUERADE rule applies to packets from the
+// pool leaving through another interface: no source, or a source subnet
+// containing the pool, and not restricted to the pool's own interface.
+func natCovers(rules []string, pool Pool) bool {
+	_, poolNet, err := net.ParseCIDR(pool.Subnet)
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		f := strings.Fields(rule)
+		covers := true
+		for i := 0; i < len(f)-1; i++ {
+			switch {
+			case f[i] == "-s" && (i == 0 || f[i-1] != "!"):
+				// iptables-save writes every source as a CIDR.
+				_, src, err := net.ParseCIDR(f[i+1])
+				if err != nil {
+					covers = false
+					continue
+				}
+				ones, _ := src.Mask.Size()
+				poolOnes, _ := poolNet.Mask.Size()
+				if !src.Contains(poolNet.IP) || ones > poolOnes {
+					covers = false
+				}
+			case f[i] == "-o" && (i == 0 || f[i-1] != "!"):
+				if f[i+1] == pool.Interface {
+					covers = false
+				}
+			}
+		}
+		if covers {
+			return true
+		}
+	}
+	return false
+}
+
+// errNoPing is the ping error of a container without the ping binary; it
+// says nothing about the path.
+const errNoPing = "ping not available in the container"
+
+var rttRE = regexp.MustCompile(`time[=<]([0-9.]+) ?ms`)
+
+// ping sends one echo request from the UPF container, from the address
+// src when set.
+func (p *Prober) ping(ctx context.Context, container, source, src, target, kind string) Ping {
+	res := Ping{Source: source, Target: target, Kind: kind}
+	wait := int(p.timeout.Seconds())
+	if wait < 1 {
+		wait = 1
+	}
+	cmd := []string{"ping", "-n", "-c", "1", "-W", strconv.Itoa(wait)}
+	if src != "" {
+		cmd = append(cmd, "-I", src)
+	}
+	cmd = append(cmd, target)
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout+5*time.Second)
+	defer cancel()
+	out, code, err := p.docker.Exec(ctx, container, cmd)
+	switch {
+	case err != nil:
+		res.Error = err.Error()
+	case code == 127 || code == 126:
+		res.Error = errNoPing
+	case code != 0:
+		res.Error = "no answer within " + p.timeout.String()
+	default:
+		res.Reachable = true
+		if m := rttRE.FindStringSubmatch(out); m != nil {
+			if ms, err := strconv.ParseFloat(m[1], 64); err == nil {
+				res.RTTSeconds = ms / 1000
+			}
+		}
+	}
+	return res
+}
+
+// diagnose turns a check into findings, most fundamental first.
+func diagnose(r Result) []Finding {
+	var out []Finding
+	add := func(kind, problem string) {
+		out = append(out, Finding{Kind: kind, Problem: problem, Hint: hints[kind]})
+	}
+	if !r.IPForward {
+		add(FindingForwarding, "IP forwarding is off in "+r.Container+".")
+	}
+	for _, pool := range r.Pools {
+		if !pool.NAT {
+			add(FindingNoNAT, fmt.Sprintf("No MASQUERADE rule covers the UE pool %s of %s.", pool.Subnet, pool.Interface))
+		}
+	}
+
+	upfOK := false
+	for _, pg := range r.Pings {
+		if pg.Kind == "internet" && pg.Source == SourceUPF {
+			upfOK = pg.Reachable
+			if !pg.Reachable && pg.Error != errNoPing {
+				add(FindingNoInternet, fmt.Sprintf("%s does not reach %s from its own address.", r.Container, pg.Target))
+			}
+		}
+	}
+	for _, pg := range r.Pings {
+		switch {
+		case pg.Kind == "internet" && pg.Source != SourceUPF && upfOK && !pg.Reachable:
+			add(FindingNATPath, fmt.Sprintf("%s reaches %s from its own address but not from the UE pool of %s.", r.Container, pg.Target, pg.Source))
+		case pg.Kind == "dn" && !pg.Reachable && pg.Error != errNoPing:
+			add(FindingDN, fmt.Sprintf("The data network container at %s does not answer %s.", pg.Target, r.Container))
+		}
+	}
+	if r.NATMax > 0 && float64(r.NATEntries) >= natFullRatio*float64(r.NATMax) {
+		add(FindingNATFull, fmt.Sprintf("The NAT table of %s holds %d of %d entries.", r.Container, r.NATEntries, r.NATMax))
+	}
+	if out == nil {
+		out = []Finding{}
+	}
+	return out
+}
+
+func (p *Prober) forget(name string) {
+	p.reachable.DeletePartialMatch(prometheus.Labels{"upf": name})
+	p.rtt.DeletePartialMatch(prometheus.Labels{"upf": name})
+	p.ipForward.DeleteLabelValues(name)
+	p.natRules.DeleteLabelValues(name)
+	p.natEntries.DeleteLabelValues(name)
+	p.natMax.DeleteLabelValues(name)
+}
+
+func (p *Prober) record(r Result) {
+	p.mu.Lock()
+	prev, seen := p.results[r.Container]
+	p.results[r.Container] = r
+	p.mu.Unlock()
+
+	if r.Error != "" {
+		if !seen || prev.Error != r.Error {
+			log.Printf("⚠️  N6 probe: %s: %s", r.Container, r.Error)
+		}
+		return
+	}
+	if seen && len(prev.Findings) == 0 && len(r.Findings) > 0 {
+		log.Printf("⚠️  N6: %s", r.Findings[0].Problem)
+	}
+
+	// Targets come and go with the DN containers: replace this UPF's series.
+	p.reachable.DeletePartialMatch(prometheus.Labels{"upf": r.Container})
+	p.rtt.DeletePartialMatch(prometheus.Labels{"upf": r.Container})
+	for _, pg := range r.Pings {
+		if pg.Reachable {
+			p.reachable.WithLabelValues(r.Container, pg.Source, pg.Target, pg.Kind).Set(1)
+			p.rtt.WithLabelValues(r.Container, pg.Source, pg.Target, pg.Kind).Set(pg.RTTSeconds)
+		} else {
+			p.reachable.WithLabelValues(r.Container, pg.Source, pg.Target, pg.Kind).Set(0)
+		}
+	}
+	p.ipForward.WithLabelValues(r.Container).Set(boolFloat(r.IPForward))
+	p.natRules.WithLabelValues(r.Container).Set(float64(len(r.NATRules)))
+	p.natEntries.WithLabelValues(r.Container).Set(float64(r.NATEntries))
+	if r.NATMax > 0 {
+		p.natMax.WithLabelValues(r.Container).Set(float64(r.NATMax))
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+// Package output defines where the module writes the files it generates and
+// keeps the list of what a run wrote. Everything lives under one root
+// (OUTPUT_DIR):
+//
+//	<root>/
+//	  artifacts/    session bundles, when ARTIFACT_STORE is a local directory
+//	  baselines/    environment baseline of `om-module snapshot -baseline`
+//	  dashboards/   Grafana dashboards as provisioned, without Loki panels when
+//	                there is no logging stack
+//	  dumps/        runtime state dumps written on SIGUSR1
+//	  educational/  offline copy of the /educational/ page (index.html)
+//	  events/       lab events reported to POST /api/events (events.jsonl)
+//	  logs/         Open5GS logs exported as rotated JSONL files
+//	  metric-buffer/  samples scraped while Prometheus is down (internal/metricbuffer)
+//	  prometheus/   Prometheus configurations with the lab's labels and remotes
+//	  reports/      `om-module compare` and `verify` results, dashboard query
+//	                lint, soak test reports
+//	  sessions/     lab session reports (internal/labsession)
+//	  om-module.lease  the module holding the output volume (internal/lease)
+//
+// Each writer can still be pointed elsewhere with its own setting; the root
+// only supplies the defaults. Generators stage their files in a Txn, so a
+// failed run leaves the previous set in place. `om-module cleanup` removes
+// the subdirectories (Subdirs) but not the lease.
+package output
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Subdirectories of the output root.
+const (
+	Artifacts    = "artifacts"
+	Baselines    = "baselines"
+	Dashboards   = "dashboards"
+	Dumps        = "dumps"
+	Educational  = "educational"
+	Events       = "events"
+	Logs         = "logs"
+	MetricBuffer = "metric-buffer"
+	Prometheus   = "prometheus"
+	Reports      = "reports"
+	Sessions     = "sessions"
+)
+
+// Subdirs are the subdirectories of the output root, in layout order.
+var Subdirs = []string{
+	Artifacts, Baselines, Dashboards, Dumps, Educational, Events,
+	Logs, MetricBuffer, Prometheus, Reports, Sessions,
+}
+
+// LeaseFile is the instance lease in the output root.
+const LeaseFile = "om-module.lease"
+
+// Dir returns the subdirectory sub of root.
+func Dir(root, sub string) string {
+	return filepath.Join(root, sub)
+}
+
+// Manifest records the files written during a run. A nil Manifest records
+// nothing, so writers need not check whether one is set.
+type Manifest struct {
+	mu    sync.Mutex
+	files map[string]struct{}
+}
+
+// NewManifest returns an empty manifest.
+func NewManifest() *Manifest {
+	return &Manifest{files: make(map[string]struct{})}
+}
+
+// Add records that path was written. Rewriting a file records it once.
+func (m *Manifest) Add(path string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[path] = struct{}{}
+}
+
+// Files returns the recorded paths, sorted.
+func (m *Manifest) Files() []string {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, 0, len(m.files))
+	for p := range m.files {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Log prints the manifest at the end of mode ("server", "compare", …).
+func (m *Manifest) Log(mode string) {
+	files := m.Files()
+	if len(files) == 0 {
+		log.Printf("📄 %s wrote no files", mode)
+		return
+	}
+	log.Printf("📄 %s wrote %d file(s):", mode, len(files))
+	for _, p := range files {
+		log.Printf("   %s", p)
+	}
+}
@@ -0,0 +1,225 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Txn stages the files one generator writes so they replace the previous
+// ones together or not at all. Nothing touches the disk until Commit; a
+// failed Commit, or a Rollback after it, puts the previous files back.
+// Readers never see a half-written file: every file is written next to its
+// target, synced, and renamed over it, and the directories are synced after
+// the renames, so a power loss leaves either the old file or the new one.
+type Txn struct {
+	files   map[string]stagedFile // by target path; data nil removes it
+	prev    map[string]stagedFile // previous contents of committed paths
+	applied []string              // paths replaced so far, in order
+}
+
+type stagedFile struct {
+	data   []byte
+	perm   os.FileMode
+	exists bool // prev only: the path existed before the commit
+}
+
+// NewTxn returns an empty transaction.
+func NewTxn() *Txn {
+	return &Txn{files: make(map[string]stagedFile)}
+}
+
+// WriteFile stages data to be written to path.
+func (t *Txn) WriteFile(path string, data []byte, perm os.FileMode) {
+	if data == nil {
+		data = []byte{}
+	}
+	t.files[path] = stagedFile{data: data, perm: perm}
+}
+
+// Remove stages the removal of path. A path that does not exist at commit
+// time is ignored.
+func (t *Txn) Remove(path string) {
+	t.files[path] = stagedFile{}
+}
+
+// Files returns the paths staged for writing, sorted.
+func (t *Txn) Files() []string {
+	var out []string
+	for p, f := range t.files {
+		if f.data != nil {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Data returns what is staged for path, for validation before Commit.
+func (t *Txn) Data(path string) ([]byte, bool) {
+	f, ok := t.files[path]
+	return f.data, ok && f.data != nil
+}
+
+// Commit writes every staged file to a temporary file in its directory,
+// then renames them over their targets, applies the removals and syncs the
+// directories. If a temporary file cannot be written nothing is replaced;
+// if a rename or a directory sync fails the files already replaced are
+// restored.
+func (t *Txn) Commit() error {
+	paths := make([]string, 0, len(t.files))
+	for p := range t.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	temps := make(map[string]string, len(paths))
+	defer func() {
+		for _, tmp := range temps {
+			_ = os.Remove(tmp)
+		}
+	}()
+	for _, p := range paths {
+		f := t.files[p]
+		if f.data == nil {
+			continue
+		}
+		tmp, err := writeTemp(p, f.data, f.perm)
+		if err != nil {
+			return fmt.Errorf("stage %s: %w", p, err)
+		}
+		temps[p] = tmp
+	}
+
+	t.prev = make(map[string]stagedFile, len(paths))
+	t.applied = nil
+	for _, p := range paths {
+		prev, err := readPrevious(p)
+		if err != nil {
+			return t.fail(fmt.Errorf("back up %s: %w", p, err))
+		}
+		t.prev[p] = prev
+		if tmp, ok := temps[p]; ok {
+			err = os.Rename(tmp, p)
+			delete(temps, p)
+		} else if prev.exists {
+			err = os.Remove(p)
+		}
+		if err != nil {
+			return t.fail(fmt.Errorf("replace %s: %w", p, err))
+		}
+		t.applied = append(t.applied, p)
+	}
+	if err := syncDirs(paths); err != nil {
+		return t.fail(err)
+	}
+	return nil
+}
+
+// Rollback puts back the files a Commit replaced or removed, and removes
+// the ones it created.
+func (t *Txn) Rollback() error {
+	var errs []error
+	for i := len(t.applied) - 1; i >= 0; i-- {
+		p := t.applied[i]
+		prev := t.prev[p]
+		if !prev.exists {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		tmp, err := writeTemp(p, prev.data, prev.perm)
+		if err == nil {
+			err = os.Rename(tmp, p)
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+			errs = append(errs, fmt.Errorf("restore %s: %w", p, err))
+		}
+	}
+	if err := syncDirs(t.applied); err != nil {
+		errs = append(errs, err)
+	}
+	t.applied = nil
+	return errors.Join(errs...)
+}
+
+// fail rolls back a partial commit and returns err, with the rollback
+// error if there was one.
+func (t *Txn) fail(err error) error {
+	if rbErr := t.Rollback(); rbErr != nil {
+		return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+	}
+	return err
+}
+
+func writeTemp(path string, data []byte, perm os.FileMode) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDirs syncs the directories of paths, each once, so the renames and
+// removals in them survive a power loss.
+func syncDirs(paths []string) error {
+	done := make(map[string]bool)
+	for _, p := range paths {
+		dir := filepath.Dir(p)
+		if done[dir] {
+			continue
+		}
+		done[dir] = true
+		d, err := os.Open(dir)
+		if err != nil {
+			return fmt.Errorf("sync %s: %w", dir, err)
+		}
+		err = d.Sync()
+		d.Close()
+		if err != nil {
+			return fmt.Errorf("sync %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func readPrevious(path string) (stagedFile, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return stagedFile{}, nil
+	}
+	if err != nil {
+		return stagedFile{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stagedFile{}, err
+	}
+	return stagedFile{data: data, perm: info.Mode().Perm(), exists: true}, nil
+}
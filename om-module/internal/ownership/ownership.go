@@ -0,0 +1,109 @@
+// Package ownership maps testbed components to the team responsible for
+// them, so failures on a shared bench can be attributed to the right
+// student group or to the instructor.
+//
+// The mapping file is JSON:
+//
+//	{
+//	  "default":    {"owner": "instructor", "contact": "lab@example.edu"},
+//	  "components": {
+//	    "amf":     {"owner": "grupo-1", "contact": "grupo1@example.edu", "description": "5G AMF"},
+//	    "nr_ue_*": {"owner": "grupo-2", "description": "Scaled UERANSIM UEs"}
+//	  }
+//	}
+//
+// Keys are matched against the component ("nr_ue_2"), then the Compose
+// service ("nr_ue"), then as path.Match patterns in sorted order. Fields a
+// matching entry leaves empty are taken from "default".
+package ownership
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Info is the ownership of one component.
+type Info struct {
+	Owner       string `json:"owner,omitempty"`
+	Contact     string `json:"contact,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Map resolves components to their Info. A nil Map resolves every
+// component to the zero Info.
+type Map struct {
+	def      Info
+	exact    map[string]Info
+	patterns []string // keys containing glob characters, sorted
+}
+
+type file struct {
+	Default    Info            `json:"default"`
+	Components map[string]Info `json:"components"`
+}
+
+// Load reads the mapping file at p.
+func Load(p string) (*Map, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+
+	m := &Map{def: f.Default, exact: make(map[string]Info, len(f.Components))}
+	for key, info := range f.Components {
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, fmt.Errorf("%s: component %q: %w", p, key, err)
+		}
+		m.exact[key] = info
+		if strings.ContainsAny(key, `*?[\`) {
+			m.patterns = append(m.patterns, key)
+		}
+	}
+	sort.Strings(m.patterns)
+	return m, nil
+}
+
+// Lookup returns the ownership of component, a replica of Compose service.
+func (m *Map) Lookup(component, service string) Info {
+	if m == nil {
+		return Info{}
+	}
+	info, ok := m.exact[component]
+	if !ok && service != "" {
+		info, ok = m.exact[service]
+	}
+	if !ok {
+		for _, p := range m.patterns {
+			if matched, _ := path.Match(p, component); matched {
+				info = m.exact[p]
+				break
+			}
+		}
+	}
+	if info.Owner == "" {
+		info.Owner = m.def.Owner
+	}
+	if info.Contact == "" {
+		info.Contact = m.def.Contact
+	}
+	if info.Description == "" {
+		info.Description = m.def.Description
+	}
+	return info
+}
+
+// Len returns the number of component entries.
+func (m *Map) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.exact)
+}
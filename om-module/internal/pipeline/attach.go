@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NAS message types shared by EMM (4G) and 5GMM (5G): Attach/Registration
+// Request, Accept and Reject.
+const (
+	nasAttachRequest = "0x41"
+	nasAttachAccept  = "0x42"
+	nasAttachReject  = "0x44"
+)
+
+// attachTimeout is how long an attempt may stay unanswered before it is
+// counted as timed out.
+const attachTimeout = 60 * time.Second
+
+// AttachAnalyzer measures attach (4G) and registration (5G) outcomes: a NAS
+// Request from the RAN opens an attempt, the Accept or Reject towards the
+// same RAN UE id closes it. These are the KPIs compared between lab sessions.
+type AttachAnalyzer struct {
+	attempts *prometheus.CounterVec
+	results  *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	pending map[string]time.Time // generation/RAN IP/RAN UE id → request time
+}
+
+// NewAttachAnalyzer registers the attach metrics on reg and returns the analyzer.
+func NewAttachAnalyzer(reg prometheus.Registerer) *AttachAnalyzer {
+	a := &AttachAnalyzer{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "attach",
+			Name:      "attempts_total",
+			Help:      "NAS Attach (4G) / Registration (5G) Requests seen from the RAN.",
+		}, []string{"generation"}),
+
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "attach",
+			Name:      "results_total",
+			Help:      "Outcome of attach/registration attempts (accepted, rejected, timeout).",
+		}, []string{"generation", "result"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "om",
+			Subsystem: "attach",
+			Name:      "seconds",
+			Help:      "Time from Attach/Registration Request to Accept as seen on the wire.",
+			Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"generation"}),
+
+		pending: make(map[string]time.Time),
+	}
+	reg.MustRegister(a.attempts, a.results, a.latency)
+	return a
+}
+
+// Observe implements Observer.
+func (a *AttachAnalyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	var nasType, ueID string
+	switch pkt.Protocol {
+	case "s1ap":
+		nasType, ueID = pkt.NASEMMType, pkt.ENBUUES1APID
+	case "ngap":
+		nasType, ueID = pkt.NASMMType, pkt.RANUENGAPId
+	default:
+		return
+	}
+	if ueID == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(pkt.Timestamp)
+
+	switch strings.ToLower(nasType) {
+	case nasAttachRequest:
+		a.attempts.WithLabelValues(pkt.Generation).Inc()
+		a.pending[pkt.Generation+"/"+pkt.SrcIP+"/"+ueID] = pkt.Timestamp
+	case nasAttachAccept, nasAttachReject:
+		key := pkt.Generation + "/" + pkt.DstIP + "/" + ueID
+		start, ok := a.pending[key]
+		if !ok {
+			return
+		}
+		delete(a.pending, key)
+		if strings.ToLower(nasType) == nasAttachReject {
+			a.results.WithLabelValues(pkt.Generation, "rejected").Inc()
+			return
+		}
+		a.results.WithLabelValues(pkt.Generation, "accepted").Inc()
+		a.latency.WithLabelValues(pkt.Generation).Observe(pkt.Timestamp.Sub(start).Seconds())
+	}
+}
+
+// expire counts attempts older than attachTimeout as timed out. Callers
+// hold a.mu.
+func (a *AttachAnalyzer) expire(now time.Time) {
+	for key, start := range a.pending {
+		if now.Sub(start) > attachTimeout {
+			gen, _, _ := strings.Cut(key, "/")
+			a.results.WithLabelValues(gen, "timeout").Inc()
+			delete(a.pending, key)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package pipeline
+
+import "fmt"
+
+// causeInfo explains one cause code to a student: what the 3GPP name means
+// and which testbed misconfiguration most often produces it.
+type causeInfo struct {
+	Name    string
+	Meaning string
+	Hint    string
+}
+
+// Cause layers, used as the "layer" label and as keys of causeCatalogue.
+const (
+	layer5GMM = "5GMM"
+	layer5GSM = "5GSM"
+	layerEMM  = "EMM"
+	layerESM  = "ESM"
+	layerNGAP = "NGAP"
+	layerS1AP = "S1AP"
+)
+
+// layerSpecs is the specification clause that defines the causes of each layer.
+var layerSpecs = map[string]string{
+	layer5GMM: "3GPP TS 24.501 §9.11.3.2",
+	layer5GSM: "3GPP TS 24.501 §9.11.4.2",
+	layerEMM:  "3GPP TS 24.301 §9.9.3.9",
+	layerESM:  "3GPP TS 24.301 §9.9.4.4",
+	layerNGAP: "3GPP TS 38.413 §9.3.1.2",
+	layerS1AP: "3GPP TS 36.413 §9.2.1.3",
+}
+
+// causeCatalogue maps layer → code → explanation. NAS causes follow
+// TS 24.501 §9.11.3.2 / §9.11.4.2 (5G) and TS 24.301 §9.9.3.9 / §9.9.4.4 (4G).
+// NGAP/S1AP causes are keyed by "<group>/<value>" in apCauseCatalogue.
+var causeCatalogue = map[string]map[int]causeInfo{
+	layer5GMM: {
+		3:   {"Illegal UE", "The network could not authenticate the UE.", "K/OPc of the UE does not match the subscriber in the WebUI/UDR."},
+		5:   {"PEI not accepted", "The equipment identity was rejected.", "IMEI/IMEISV blocked or malformed in the UE config."},
+		6:   {"Illegal ME", "The mobile equipment is not allowed.", "IMEI check failed."},
+		7:   {"5GS services not allowed", "The subscriber may not use 5GS services.", "Subscriber missing or not enabled for 5G in the WebUI."},
+		9:   {"UE identity cannot be derived by the network", "The AMF could not resolve the SUCI/5G-GUTI.", "SUCI protection scheme or home network key mismatch; stale GUTI after a core restart."},
+		10:  {"Implicitly de-registered", "The network already considered the UE de-registered.", "Core restarted while the UE was registered — re-register the UE."},
+		11:  {"PLMN not allowed", "The UE's PLMN is not served by this network.", "MCC/MNC of the UE/gNB differs from the AMF plmn_support."},
+		12:  {"Tracking area not allowed", "The UE's tracking area is not allowed.", "TAC broadcast by the gNB is missing from the AMF tai list."},
+		13:  {"Roaming not allowed in this tracking area", "Roaming is not permitted here.", "UE home PLMN differs from the serving PLMN."},
+		15:  {"No suitable cells in tracking area", "The UE should look for another TA.", "TAC configuration mismatch between gNB and AMF."},
+		20:  {"MAC failure", "The UE could not verify the network's authentication token.", "K/OPc in the UE config differs from the subscriber in the WebUI/UDR."},
+		21:  {"Synch failure", "SQN out of range; the UE asks for re-synchronisation.", "Usually harmless after re-provisioning — the next attempt succeeds."},
+		22:  {"Congestion", "The network is congested.", "AMF overload or too many UEs started at once."},
+		26:  {"Non-5G authentication unacceptable", "The authentication vector was not a 5G one.", "AMF/AUSF security settings mismatch."},
+		27:  {"N1 mode not allowed", "The UE may not use N1 (5G NAS).", "Subscriber restrictions in the WebUI."},
+		62:  {"No network slices available", "None of the requested S-NSSAIs are allowed.", "SST/SD of the UE not in the AMF/NSSF slice list or not subscribed in the WebUI."},
+		65:  {"Maximum number of PDU sessions reached", "The UE already has the maximum PDU sessions.", "UE config requests more sessions than allowed."},
+		90:  {"Payload was not forwarded", "The AMF could not forward the 5GSM message.", "SMF unreachable (check NRF registration of the SMF)."},
+		91:  {"DNN not supported or not subscribed in the slice", "The requested DNN is not available in this slice.", "DNN missing from SMF config or WebUI subscription for that S-NSSAI."},
+		92:  {"Insufficient user-plane resources for the PDU session", "No UPF could serve the session.", "UPF down or not associated with the SMF over PFCP."},
+		111: {"Protocol error, unspecified", "Generic protocol error.", "Check AMF logs for the decoding error."},
+	},
+	layer5GSM: {
+		26: {"Insufficient resources", "The SMF/UPF lacks resources.", "UE IP pool exhausted or UPF not associated."},
+		27: {"Missing or unknown DNN", "The requested DNN is unknown.", "DNN in the UE config differs from the SMF session/dnn config."},
+		28: {"Unknown PDU session type", "The PDU session type is not supported.", "UE requests IPv6/IPv4v6 but the SMF subnet is IPv4 only."},
+		29: {"User authentication or authorization failed", "Secondary authentication failed.", "DN-AAA settings."},
+		31: {"Request rejected, unspecified", "The SMF rejected the request.", "Check SMF logs; often a PCF/UDM lookup failure."},
+		33: {"Requested service option not subscribed", "The session is not allowed by the subscription.", "DNN/slice not subscribed for this IMSI in the WebUI."},
+		50: {"PDU session type IPv4 only allowed", "Only IPv4 PDU sessions are allowed.", "Informational — UE asked for IPv4v6."},
+		54: {"PDU session does not exist", "The referenced PDU session is unknown.", "Stale session after an SMF restart."},
+		68: {"Not supported SSC mode", "The requested SSC mode is not supported.", "UE requests SSC mode 2/3."},
+		69: {"Insufficient resources for specific slice", "No resources in the requested slice.", "No SMF/UPF serves that S-NSSAI (E4: check smf2/upf2)."},
+		70: {"Missing or unknown DNN in a slice", "The DNN is not configured for that slice.", "DNN/S-NSSAI pairing mismatch between UE, SMF and WebUI."},
+	},
+	layerEMM: {
+		2:   {"IMSI unknown in HSS", "The subscriber does not exist.", "IMSI not provisioned — run scripts/mongo_insert.sh or add it in the WebUI."},
+		3:   {"Illegal UE", "The network could not authenticate the UE.", "K/OPc of the UE does not match the subscriber in the HSS."},
+		6:   {"Illegal ME", "The mobile equipment is not allowed.", "IMEI check failed."},
+		7:   {"EPS services not allowed", "The subscriber may not use EPS services.", "Subscriber restrictions in the WebUI."},
+		8:   {"EPS and non-EPS services not allowed", "The subscriber may not use any service.", "Subscriber disabled in the WebUI."},
+		11:  {"PLMN not allowed", "The UE's PLMN is not served by this network.", "MCC/MNC of the UE/eNB differs from the MME gummei/tai."},
+		12:  {"Tracking area not allowed", "The UE's tracking area is not allowed.", "TAC broadcast by the eNB is missing from the MME tai list."},
+		13:  {"Roaming not allowed in this tracking area", "Roaming is not permitted here.", "UE home PLMN differs from the serving PLMN."},
+		14:  {"EPS services not allowed in this PLMN", "EPS is barred in this PLMN for the UE.", "Subscriber/PLMN restrictions."},
+		15:  {"No suitable cells in tracking area", "The UE should look for another TA.", "TAC configuration mismatch between eNB and MME."},
+		19:  {"ESM failure", "The attach failed in the session management part.", "See the ESM cause — usually an unknown APN."},
+		20:  {"MAC failure", "The UE could not verify the network's authentication token.", "K/OPc in the UE config differs from the subscriber in the HSS."},
+		21:  {"Synch failure", "SQN out of range; the UE asks for re-synchronisation.", "Usually harmless after re-provisioning — the next attempt succeeds."},
+		22:  {"Congestion", "The network is congested.", "MME overload or too many UEs started at once."},
+		26:  {"Non-EPS authentication unacceptable", "The authentication vector was not an EPS one.", "HSS/MME security settings mismatch."},
+		111: {"Protocol error, unspecified", "Generic protocol error.", "Check MME logs for the decoding error."},
+	},
+	layerESM: {
+		26: {"Insufficient resources", "The gateway lacks resources.", "UE IP pool exhausted or SGW-U/UPF not associated."},
+		27: {"Missing or unknown APN", "The requested APN is unknown.", "APN in the UE config differs from the SMF/HSS subscription (e.g. internet)."},
+		28: {"Unknown PDN type", "The PDN type is not supported.", "UE requests IPv6/IPv4v6 but the subnet is IPv4 only."},
+		29: {"User authentication failed", "PDN authentication failed.", "PAP/CHAP settings on the APN."},
+		30: {"Request rejected by Serving GW or PDN GW", "The gateway rejected the session.", "SGW-C/SMF error — check the GTPv2 cause in the capture."},
+		31: {"Request rejected, unspecified", "The network rejected the request.", "Check SMF logs; often a PCRF (Gx) failure."},
+		32: {"Service option not supported", "The requested service is not supported.", "Feature not enabled in the core."},
+		33: {"Requested service option not subscribed", "The APN is not allowed by the subscription.", "APN not subscribed for this IMSI in the WebUI."},
+	},
+}
+
+// apCauseCatalogue explains the most common NGAP/S1AP Cause values, keyed by
+// layer and "<group>/<value>". Groups follow TS 38.413 §9.3.1.2 and
+// TS 36.413 §9.2.1.3.
+var apCauseCatalogue = map[string]map[string]causeInfo{
+	layerNGAP: {
+		"radioNetwork/0":  {"unspecified", "Radio network error without a specific cause.", ""},
+		"radioNetwork/2":  {"successful-handover", "Context released after a successful handover.", "Normal."},
+		"radioNetwork/3":  {"release-due-to-ngran-generated-reason", "The gNB released the UE.", "Normal when the UE goes idle."},
+		"radioNetwork/4":  {"release-due-to-5gc-generated-reason", "The core released the UE.", "Normal after de-registration."},
+		"radioNetwork/5":  {"handover-cancelled", "The handover was cancelled.", ""},
+		"radioNetwork/11": {"cell-not-available", "The target cell is not available.", "Target gNB down or misconfigured neighbour."},
+		"radioNetwork/12": {"unknown-targetID", "The handover target is unknown to the AMF.", "Target gNB not connected to the AMF."},
+		"radioNetwork/14": {"unknown-local-UE-NGAP-ID", "The UE context is unknown.", "gNB or AMF restarted — contexts are out of sync."},
+		"radioNetwork/15": {"inconsistent-remote-UE-NGAP-ID", "The UE NGAP ID pair does not match.", "gNB or AMF restarted — contexts are out of sync."},
+		"radioNetwork/20": {"user-inactivity", "Released for inactivity.", "Normal."},
+		"radioNetwork/21": {"radio-connection-with-ue-lost", "The gNB lost the UE.", "UE process stopped or radio link (ZMQ) broken."},
+		"nas/0":           {"normal-release", "Release requested by NAS.", "Normal."},
+		"nas/1":           {"authentication-failure", "Released after a failed authentication.", "K/OPc mismatch — see the 5GMM cause."},
+		"nas/2":           {"deregister", "Released after de-registration.", "Normal."},
+		"nas/3":           {"unspecified", "NAS-triggered release without a specific cause.", ""},
+		"transport/0":     {"transport-resource-unavailable", "Transport resources unavailable.", "SCTP/N3 connectivity problem."},
+		"protocol/0":      {"transfer-syntax-error", "The message could not be decoded.", "Version mismatch between gNB and AMF."},
+		"protocol/1":      {"abstract-syntax-error-reject", "The message was rejected as malformed.", "Version mismatch between gNB and AMF."},
+		"protocol/6":      {"unspecified", "Protocol error without a specific cause.", ""},
+		"misc/0":          {"control-processing-overload", "The node is overloaded.", ""},
+		"misc/1":          {"not-enough-user-plane-processing-resources", "No user-plane resources.", "UPF down or not associated."},
+		"misc/2":          {"hardware-failure", "Hardware failure.", ""},
+		"misc/3":          {"om-intervention", "Released by O&M.", ""},
+		"misc/4":          {"unknown-PLMN-or-SNPN", "The PLMN is not served.", "NG Setup: gNB MCC/MNC or TAC does not match the AMF plmn_support/tai."},
+		"misc/5":          {"unspecified", "Miscellaneous failure.", ""},
+	},
+	layerS1AP: {
+		"radioNetwork/0":  {"unspecified", "Radio network error without a specific cause.", ""},
+		"radioNetwork/2":  {"successful-handover", "Context released after a successful handover.", "Normal."},
+		"radioNetwork/3":  {"release-due-to-eutran-generated-reason", "The eNB released the UE.", "Normal when the UE goes idle."},
+		"radioNetwork/4":  {"handover-cancelled", "The handover was cancelled.", ""},
+		"radioNetwork/14": {"unknown-mme-ue-s1ap-id", "The MME UE context is unknown.", "eNB or MME restarted — contexts are out of sync."},
+		"radioNetwork/15": {"unknown-enb-ue-s1ap-id", "The eNB UE context is unknown.", "eNB or MME restarted — contexts are out of sync."},
+		"radioNetwork/16": {"unknown-pair-ue-s1ap-id", "The UE S1AP ID pair does not match.", "eNB or MME restarted — contexts are out of sync."},
+		"radioNetwork/20": {"user-inactivity", "Released for inactivity.", "Normal."},
+		"radioNetwork/21": {"radio-connection-with-ue-lost", "The eNB lost the UE.", "UE process stopped or radio link (ZMQ) broken."},
+		"nas/0":           {"normal-release", "Release requested by NAS.", "Normal."},
+		"nas/1":           {"authentication-failure", "Released after a failed authentication.", "K/OPc mismatch — see the EMM cause."},
+		"nas/2":           {"detach", "Released after detach.", "Normal."},
+		"nas/3":           {"unspecified", "NAS-triggered release without a specific cause.", ""},
+		"transport/0":     {"transport-resource-unavailable", "Transport resources unavailable.", "SCTP/S1-U connectivity problem."},
+		"protocol/0":      {"transfer-syntax-error", "The message could not be decoded.", "Version mismatch between eNB and MME."},
+		"misc/0":          {"control-processing-overload", "The node is overloaded.", ""},
+		"misc/1":          {"not-enough-user-plane-processing-resources", "No user-plane resources.", "SGW-U down or not associated."},
+		"misc/2":          {"hardware-failure", "Hardware failure.", ""},
+		"misc/3":          {"om-intervention", "Released by O&M.", ""},
+		"misc/4":          {"unspecified", "Miscellaneous failure.", ""},
+		"misc/5":          {"unknown-PLMN", "The PLMN is not served.", "S1 Setup: eNB MCC/MNC or TAC does not match the MME gummei/tai."},
+	},
+}
+
+// lookupCause returns the explanation for a NAS cause, or a generic entry.
+func lookupCause(layer string, code int) causeInfo {
+	if c, ok := causeCatalogue[layer][code]; ok {
+		return c
+	}
+	return causeInfo{Name: fmt.Sprintf("cause #%d", code)}
+}
+
+// lookupAPCause returns the explanation for an NGAP/S1AP cause, or a generic entry.
+func lookupAPCause(layer, group string, value int) causeInfo {
+	if c, ok := apCauseCatalogue[layer][fmt.Sprintf("%s/%d", group, value)]; ok {
+		return c
+	}
+	return causeInfo{Name: fmt.Sprintf("%s #%d", group, value)}
+}
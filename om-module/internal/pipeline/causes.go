@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NAS messages that carry a cause worth counting, by layer and message type.
+var (
+	nas5GMMCauseMessages = map[string]string{
+		"0x44": "RegistrationReject",
+		"0x47": "DeregistrationRequest",
+		"0x4d": "ServiceReject",
+		"0x59": "AuthenticationFailure",
+		"0x5f": "SecurityModeReject",
+	}
+	nas5GSMCauseMessages = map[string]string{
+		"0xc3": "PDUSessionEstablishmentReject",
+		"0xcb": "PDUSessionModificationReject",
+		"0xd3": "PDUSessionReleaseCommand",
+	}
+	nasEMMCauseMessages = map[string]string{
+		"0x44": "AttachReject",
+		"0x45": "DetachRequest",
+		"0x4b": "TrackingAreaUpdateReject",
+		"0x4e": "ServiceReject",
+		"0x5c": "AuthenticationFailure",
+		"0x5f": "SecurityModeReject",
+	}
+	nasESMCauseMessages = map[string]string{
+		"0xc3": "ActivateDefaultEPSBearerContextReject",
+		"0xc7": "ActivateDedicatedEPSBearerContextReject",
+		"0xd1": "PDNConnectivityReject",
+	}
+)
+
+// CauseAnalyzer counts NAS reject causes and NGAP/S1AP Cause IEs seen in the
+// capture and explains each one, so students can go from "the UE does not
+// attach" to "the APN in the UE config is unknown" without reading logs.
+type CauseAnalyzer struct {
+	nasRejects *prometheus.CounterVec
+	apCauses   *prometheus.CounterVec
+
+	mu      sync.Mutex
+	summary map[causeKey]*causeStats
+}
+
+type causeKey struct {
+	generation string
+	layer      string
+	message    string
+	code       string
+}
+
+type causeStats struct {
+	info     causeInfo
+	count    uint64
+	lastSeen time.Time
+	lastIMSI string
+}
+
+// CauseSummary is one (layer, message, cause) row with its explanation.
+type CauseSummary struct {
+	Generation string `json:"generation"`
+	Layer      string `json:"layer"`
+	Message    string `json:"message"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Meaning    string `json:"meaning"`
+	Hint       string `json:"hint"`
+	Spec       string `json:"spec"`
+	Count      uint64 `json:"count"`
+	LastSeen   string `json:"last_seen"`
+	LastIMSI   string `json:"last_imsi,omitempty"`
+}
+
+// NewCauseAnalyzer registers the cause counters on reg and returns the analyzer.
+func NewCauseAnalyzer(reg prometheus.Registerer) *CauseAnalyzer {
+	a := &CauseAnalyzer{
+		nasRejects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Name:      "nas_reject_total",
+			Help:      "NAS messages carrying a 5GMM/5GSM/EMM/ESM cause (rejects, authentication failures, network-initiated releases).",
+		}, []string{"generation", "layer", "message", "cause", "cause_name"}),
+
+		apCauses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Name:      "ap_cause_total",
+			Help:      "NGAP/S1AP messages carrying a Cause IE, by procedure and cause.",
+		}, []string{"protocol", "procedure", "group", "cause", "cause_name"}),
+
+		summary: make(map[causeKey]*causeStats),
+	}
+	reg.MustRegister(a.nasRejects, a.apCauses)
+	return a
+}
+
+// Observe implements Observer.
+func (a *CauseAnalyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	imsi := packetIMSI(pkt)
+
+	switch pkt.Protocol {
+	case "ngap":
+		if msg, ok := nas5GMMCauseMessages[strings.ToLower(pkt.NASMMType)]; ok && pkt.NAS5GMMCause != 0 {
+			a.recordNAS(pkt, layer5GMM, msg, pkt.NAS5GMMCause, imsi)
+		}
+		if msg, ok := nas5GSMCauseMessages[strings.ToLower(pkt.NASSMType)]; ok && pkt.NAS5GSMCause != 0 {
+			a.recordNAS(pkt, layer5GSM, msg, pkt.NAS5GSMCause, imsi)
+		}
+		if pkt.APCauseGroup != "" {
+			a.recordAP(pkt, layerNGAP, ngapProcedureName(pkt.NGAPProcedureCode), imsi)
+		}
+
+	case "s1ap":
+		if msg, ok := nasEMMCauseMessages[strings.ToLower(pkt.NASEMMType)]; ok && pkt.NASEMMCause != 0 {
+			a.recordNAS(pkt, layerEMM, msg, pkt.NASEMMCause, imsi)
+		}
+		if msg, ok := nasESMCauseMessages[strings.ToLower(pkt.NASESMType)]; ok && pkt.NASESMCause != 0 {
+			a.recordNAS(pkt, layerESM, msg, pkt.NASESMCause, imsi)
+		}
+		if pkt.APCauseGroup != "" {
+			a.recordAP(pkt, layerS1AP, s1apProcedureName(pkt.S1APProcedureCode), imsi)
+		}
+	}
+}
+
+func (a *CauseAnalyzer) recordNAS(pkt capture.Packet, layer, message string, code int, imsi string) {
+	info := lookupCause(layer, code)
+	cause := strconv.Itoa(code)
+	a.nasRejects.WithLabelValues(pkt.Generation, layer, message, cause, info.Name).Inc()
+	a.record(causeKey{pkt.Generation, layer, message, cause}, info, pkt.Timestamp, imsi)
+}
+
+func (a *CauseAnalyzer) recordAP(pkt capture.Packet, layer, procedure, imsi string) {
+	info := lookupAPCause(layer, pkt.APCauseGroup, pkt.APCause)
+	cause := strconv.Itoa(pkt.APCause)
+	a.apCauses.WithLabelValues(layer, procedure, pkt.APCauseGroup, cause, info.Name).Inc()
+	a.record(causeKey{pkt.Generation, layer, procedure, pkt.APCauseGroup + "/" + cause}, info, pkt.Timestamp, imsi)
+}
+
+func (a *CauseAnalyzer) record(key causeKey, info causeInfo, at time.Time, imsi string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st, ok := a.summary[key]
+	if !ok {
+		st = &causeStats{info: info}
+		a.summary[key] = st
+	}
+	st.count++
+	st.lastSeen = at
+	if imsi != "" {
+		st.lastIMSI = imsi
+	}
+}
+
+// Summary returns every cause seen so far, most frequent first. generation
+// ("4g" / "5g") filters the result; "" returns both.
+func (a *CauseAnalyzer) Summary(generation string) []CauseSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]CauseSummary, 0, len(a.summary))
+	for key, st := range a.summary {
+		if generation != "" && key.generation != generation {
+			continue
+		}
+		out = append(out, CauseSummary{
+			Generation: key.generation,
+			Layer:      key.layer,
+			Message:    key.message,
+			Code:       key.code,
+			Name:       st.info.Name,
+			Meaning:    st.info.Meaning,
+			Hint:       st.info.Hint,
+			Spec:       layerSpecs[key.layer],
+			Count:      st.count,
+			LastSeen:   st.lastSeen.UTC().Format(time.RFC3339),
+			LastIMSI:   st.lastIMSI,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].LastSeen > out[j].LastSeen
+	})
+	return out
+}
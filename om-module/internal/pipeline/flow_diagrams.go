@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Sequence diagrams of a Flow. Every message is labelled with its offset
+// from the first message of the flow; failed answers (a cause other than
+// success) are drawn with a cross.
+
+// flowTitle names the flow in the diagram headers.
+func (f Flow) flowTitle() string {
+	ue := f.IMSI
+	if ue == "" {
+		ue = "RAN UE " + f.RANUEID
+	}
+	return fmt.Sprintf("%s %s — %s", strings.ToUpper(f.Generation), ue, f.StartedAt)
+}
+
+// lifelines returns the participants of f, in diagram order.
+func (f Flow) lifelines() []string {
+	if f.Participants != nil {
+		return f.Participants
+	}
+	return participants(f.Steps)
+}
+
+// stepLabel is the text of the arrow of s.
+func stepLabel(s FlowStep) string {
+	return fmt.Sprintf("+%.3f ms %s", s.OffsetMs, s.Message)
+}
+
+// Mermaid renders f as a Mermaid sequenceDiagram.
+func (f Flow) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("sequenceDiagram\n")
+	fmt.Fprintf(&b, "    %%%% %s\n", f.flowTitle())
+	alias := make(map[string]string)
+	for i, p := range f.lifelines() {
+		alias[p] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(&b, "    participant %s as %s\n", alias[p], mermaidText(p))
+	}
+	for _, s := range f.Steps {
+		arrow := "->>"
+		if s.Error {
+			arrow = "-x"
+		}
+		fmt.Fprintf(&b, "    %s%s%s: %s\n", alias[s.From], arrow, alias[s.To], mermaidText(stepLabel(s)))
+	}
+	return b.String()
+}
+
+// mermaidText keeps s from ending the statement or starting an entity.
+func mermaidText(s string) string {
+	return strings.NewReplacer(";", ",", "#", "#35;", "\n", " ").Replace(s)
+}
+
+// PlantUML renders f as a PlantUML sequence diagram.
+func (f Flow) PlantUML() string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	fmt.Fprintf(&b, "title %s\n", f.flowTitle())
+	alias := make(map[string]string)
+	for i, p := range f.lifelines() {
+		alias[p] = fmt.Sprintf("P%d", i)
+		fmt.Fprintf(&b, "participant %q as %s\n", p, alias[p])
+	}
+	for _, s := range f.Steps {
+		arrow := "->"
+		if s.Error {
+			arrow = "->x"
+		}
+		fmt.Fprintf(&b, "%s %s %s : %s\n", alias[s.From], arrow, alias[s.To], strings.ReplaceAll(stepLabel(s), "\n", " "))
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// Layout of the SVG rendering, in pixels.
+const (
+	svgMargin    = 20
+	svgLaneWidth = 220
+	svgHeadTop   = 40
+	svgHeadH     = 30
+	svgRowH      = 30
+)
+
+// SVG renders f as a standalone SVG sequence diagram, so it can be shown
+// without a Mermaid or PlantUML renderer.
+func (f Flow) SVG() string {
+	lanes := f.lifelines()
+	x := make(map[string]int, len(lanes))
+	for i, p := range lanes {
+		x[p] = svgMargin + svgLaneWidth/2 + i*svgLaneWidth
+	}
+	width := max(2*svgMargin+len(lanes)*svgLaneWidth, 400)
+	top := svgHeadTop + svgHeadH
+	bottom := top + (len(f.Steps)+1)*svgRowH
+	height := bottom + svgMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n", width, height, width, height)
+	b.WriteString(`<defs><marker id="arrow" markerWidth="10" markerHeight="8" refX="9" refY="4" orient="auto"><path d="M0,0 L10,4 L0,8 z" fill="#333"/></marker></defs>` + "\n")
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", svgMargin, svgMargin+4, html.EscapeString(f.flowTitle()))
+
+	for _, p := range lanes {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999" stroke-dasharray="4 4"/>`+"\n", x[p], top, x[p], bottom)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#eef3fb" stroke="#336"/>`+"\n", x[p]-svgLaneWidth/2+20, svgHeadTop, svgLaneWidth-40, svgHeadH)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-weight="bold">%s</text>`+"\n", x[p], svgHeadTop+svgHeadH/2+4, html.EscapeString(p))
+	}
+
+	for i, s := range f.Steps {
+		y := top + (i+1)*svgRowH
+		color := "#333"
+		if s.Error {
+			color = "#c00"
+		}
+		x1, x2 := x[s.From], x[s.To]
+		label := html.EscapeString(stepLabel(s))
+		if x1 == x2 {
+			// A message between two addresses of the same NF.
+			fmt.Fprintf(&b, `<polyline points="%d,%d %d,%d %d,%d %d,%d" fill="none" stroke="%s" marker-end="url(#arrow)"/>`+"\n",
+				x1, y-8, x1+30, y-8, x1+30, y+4, x1+2, y+4, color)
+			fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s">%s</text>`+"\n", x1+36, y, color, label)
+			continue
+		}
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" marker-end="url(#arrow)"/>`+"\n", x1, y, x2, y, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" fill="%s">%s</text>`+"\n", (x1+x2)/2, y-5, color, label)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
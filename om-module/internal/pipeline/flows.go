@@ -0,0 +1,420 @@
+package pipeline
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/collector"
+)
+
+// Bounds of the flow recorder: finished flows kept for the API, messages
+// kept per flow and request/response pairs waiting for their answer.
+const (
+	maxFlows        = 50
+	maxFlowSteps    = 200
+	maxFlowRequests = 4096
+)
+
+// ueParticipant is the lifeline of the UE. The UE is never on the capture
+// interface; NAS messages are drawn between it and the AMF/MME.
+const ueParticipant = "ue"
+
+// FlowRecorder reconstructs the signalling of each UE from the capture:
+// the NGAP/S1AP messages of one RAN UE id and the GTPv2, PFCP, Diameter and
+// SBI messages the core exchanged on its behalf, in order and with their
+// time offsets, to be drawn as sequence diagrams. A core message joins a
+// flow by the request it answers, by its IMSI or — when neither tells —
+// when only one UE of the generation is signalling.
+type FlowRecorder struct {
+	mcc string
+	mnc string
+
+	mu       sync.Mutex
+	seq      int
+	active   map[string]*Flow  // generation/RAN IP/RAN UE id
+	recent   []Flow            // finished, oldest first
+	requests map[string]string // request correlation key → active flow key
+}
+
+// Flow is the signalling of one UE between its first NGAP/S1AP message and
+// its UE context release, or attachTimeout without messages.
+type Flow struct {
+	ID         string `json:"id"`
+	Generation string `json:"generation"`
+	IMSI       string `json:"imsi,omitempty"`
+	RAN        string `json:"ran"`
+	RANUEID    string `json:"ran_ue_id"`
+	StartedAt  string `json:"started_at"`
+	// DurationMs is the time from the first to the last message.
+	DurationMs   float64    `json:"duration_ms"`
+	Active       bool       `json:"active"`
+	Messages     int        `json:"messages"`
+	Participants []string   `json:"participants,omitempty"`
+	Steps        []FlowStep `json:"steps,omitempty"`
+
+	key     string
+	started time.Time
+	last    time.Time
+}
+
+// FlowStep is one message of a flow.
+type FlowStep struct {
+	Time string `json:"time"`
+	// OffsetMs is the time since the first message of the flow.
+	OffsetMs float64 `json:"offset_ms"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Protocol string  `json:"protocol"`
+	Message  string  `json:"message"`
+	Error    bool    `json:"error,omitempty"`
+}
+
+// NewFlowRecorder returns a recorder that builds the IMSI of 5G flows from
+// the MSIN of the SUCI and the PLMN mcc/mnc.
+func NewFlowRecorder(mcc, mnc string) *FlowRecorder {
+	return &FlowRecorder{
+		mcc:      mcc,
+		mnc:      mnc,
+		active:   make(map[string]*Flow),
+		requests: make(map[string]string),
+	}
+}
+
+// Observe implements Observer.
+func (r *FlowRecorder) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	if isHeartbeat(pkt) {
+		return
+	}
+	message := spanName(pkt)
+	if message == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(pkt.Timestamp)
+
+	step := FlowStep{
+		From:     srcNF,
+		To:       dstNF,
+		Protocol: pkt.Protocol,
+		Message:  message,
+		Error:    isErrorCause(pkt),
+	}
+	var f *Flow
+	switch pkt.Protocol {
+	case "ngap", "s1ap":
+		f = r.ranFlow(pkt, srcNF, &step)
+	case "gtpv2", "pfcp", "diameter", "sbi":
+		f = r.coreFlow(pkt, srcNF, dstNF)
+	}
+	if f == nil {
+		return
+	}
+
+	if len(f.Steps) < maxFlowSteps {
+		step.Time = pkt.Timestamp.UTC().Format(time.RFC3339Nano)
+		step.OffsetMs = milliseconds(pkt.Timestamp.Sub(f.started))
+		f.Steps = append(f.Steps, step)
+	}
+	f.Messages++
+	f.last = pkt.Timestamp
+
+	if released(pkt, srcNF) {
+		r.finish(f)
+	}
+}
+
+// ranFlow returns the flow of the UE of an NGAP/S1AP message, starting one
+// on its first message, and draws NAS messages from or to the UE. Non-UE
+// associated messages (NG Setup, S1 Setup, …) belong to no flow. Callers
+// hold r.mu.
+func (r *FlowRecorder) ranFlow(pkt capture.Packet, srcNF string, step *FlowStep) *Flow {
+	ueID, nas := pkt.RANUENGAPId, nasMM5GName(pkt.NASMMType)
+	if pkt.Protocol == "s1ap" {
+		ueID, nas = pkt.ENBUUES1APID, nasEMMName(pkt.NASEMMType)
+	}
+	if ueID == "" {
+		return nil
+	}
+
+	downlink := fromMobilityManagement(srcNF)
+	ran := pkt.SrcIP
+	if downlink {
+		ran = pkt.DstIP
+	}
+	if nas != "" {
+		if downlink {
+			step.To = ueParticipant
+		} else {
+			step.From = ueParticipant
+		}
+	}
+
+	key := pkt.Generation + "/" + ran + "/" + ueID
+	f := r.active[key]
+	if f != nil && initialUEMessage(pkt) {
+		// The RAN reuses UE ids; a new Initial UE Message is a new flow.
+		r.finish(f)
+		f = nil
+	}
+	if f == nil {
+		r.seq++
+		f = &Flow{
+			ID:         pkt.Generation + "-" + strconv.Itoa(r.seq),
+			Generation: pkt.Generation,
+			RAN:        ran,
+			RANUEID:    ueID,
+			StartedAt:  pkt.Timestamp.UTC().Format(time.RFC3339),
+			Active:     true,
+			Steps:      []FlowStep{},
+			key:        key,
+			started:    pkt.Timestamp,
+		}
+		r.active[key] = f
+	}
+	switch {
+	case pkt.SUCIMsin != "":
+		f.IMSI = r.mcc + r.mnc + pkt.SUCIMsin
+	case pkt.IMSI != "":
+		f.IMSI = pkt.IMSI
+	}
+	return f
+}
+
+// coreFlow returns the flow a GTPv2, PFCP, Diameter or SBI message belongs
+// to: the flow of the request it answers, the flow of its IMSI or the only
+// flow of its generation that may be its UE, in that order. Callers hold
+// r.mu.
+func (r *FlowRecorder) coreFlow(pkt capture.Packet, srcNF, dstNF string) *Flow {
+	request, answer := correlationKeys(pkt)
+	if answer != "" {
+		if f := r.active[r.requests[answer]]; f != nil {
+			if answer != request {
+				delete(r.requests, answer)
+			}
+			return f
+		}
+	}
+
+	imsi := packetIMSI(pkt)
+	generation := pkt.Generation
+	if generation == "" {
+		generation = collector.ParseNFKind(srcNF).Generation()
+	}
+	if generation == "" {
+		generation = collector.ParseNFKind(dstNF).Generation()
+	}
+
+	var f *Flow
+	if imsi != "" {
+		for _, c := range r.active {
+			if c.IMSI == imsi && (f == nil || c.started.After(f.started)) {
+				f = c
+			}
+		}
+	}
+	if f == nil {
+		for _, c := range r.active {
+			if generation != "" && c.Generation != generation {
+				continue
+			}
+			if imsi != "" && c.IMSI != "" {
+				continue
+			}
+			if f != nil {
+				return nil // several UEs signalling: ambiguous
+			}
+			f = c
+		}
+	}
+	if f == nil {
+		return nil
+	}
+	if f.IMSI == "" {
+		f.IMSI = imsi
+	}
+	if request != "" {
+		if len(r.requests) >= maxFlowRequests {
+			clear(r.requests)
+		}
+		r.requests[request] = f.key
+	}
+	return f
+}
+
+// correlationKeys returns the key a message leaves for its answer and the
+// key it looks up when it is itself an answer. GTPv2 and PFCP pair by
+// sequence number, SBI by HTTP/2 stream, both between the same two
+// endpoints; Diameter messages of one Session-Id all belong together.
+func correlationKeys(pkt capture.Packet) (request, answer string) {
+	var id string
+	switch pkt.Protocol {
+	case "gtpv2":
+		id = pkt.GTPv2Seq
+	case "pfcp":
+		id = strconv.Itoa(pkt.PFCPSeqNo)
+	case "sbi":
+		if pkt.SBIStreamID == 0 {
+			return "", ""
+		}
+		src := pkt.SrcIP + ":" + strconv.Itoa(pkt.SrcPort)
+		dst := pkt.DstIP + ":" + strconv.Itoa(pkt.DstPort)
+		id = strconv.Itoa(pkt.SBIStreamID)
+		return "sbi/" + src + "/" + dst + "/" + id, "sbi/" + dst + "/" + src + "/" + id
+	case "diameter":
+		if pkt.DiameterSessionID == "" {
+			return "", ""
+		}
+		key := "diameter/" + pkt.DiameterSessionID
+		return key, key
+	}
+	if id == "" {
+		return "", ""
+	}
+	prefix := pkt.Protocol + "/"
+	return prefix + pkt.SrcIP + "/" + pkt.DstIP + "/" + id, prefix + pkt.DstIP + "/" + pkt.SrcIP + "/" + id
+}
+
+// initialUEMessage reports whether pkt opens the signalling of a UE.
+func initialUEMessage(pkt capture.Packet) bool {
+	switch pkt.Protocol {
+	case "ngap":
+		return ngapProcedureName(pkt.NGAPProcedureCode) == "InitialUEMessage"
+	case "s1ap":
+		return s1apProcedureName(pkt.S1APProcedureCode) == "InitialUEMessage"
+	}
+	return false
+}
+
+// released reports whether pkt is the RAN's UE Context Release Complete,
+// the last message of a flow.
+func released(pkt capture.Packet, srcNF string) bool {
+	if pkt.APMessageType == "initiating" || fromMobilityManagement(srcNF) {
+		return false
+	}
+	switch pkt.Protocol {
+	case "ngap":
+		return ngapProcedureName(pkt.NGAPProcedureCode) == "UEContextRelease" && pkt.NASMMType == ""
+	case "s1ap":
+		return s1apProcedureName(pkt.S1APProcedureCode) == "UEContextRelease" && pkt.NASEMMType == ""
+	}
+	return false
+}
+
+// fromMobilityManagement reports whether nf is an AMF or an MME, i.e. an
+// NGAP/S1AP message from nf goes towards the RAN.
+func fromMobilityManagement(nf string) bool {
+	switch collector.ParseNFKind(nf) {
+	case collector.KindAMF, collector.KindMME:
+		return true
+	}
+	return false
+}
+
+// finish moves f from the active set to the recent list. Callers hold r.mu.
+func (r *FlowRecorder) finish(f *Flow) {
+	delete(r.active, f.key)
+	f.Active = false
+	r.recent = append(r.recent, *f)
+	if len(r.recent) > maxFlows {
+		r.recent = r.recent[len(r.recent)-maxFlows:]
+	}
+}
+
+// expire finishes the flows without messages for attachTimeout. Callers
+// hold r.mu.
+func (r *FlowRecorder) expire(now time.Time) {
+	for _, f := range r.active {
+		if now.Sub(f.last) > attachTimeout {
+			r.finish(f)
+		}
+	}
+}
+
+// Flows returns the finished flows followed by those in progress, newest
+// first and without their steps, optionally filtered by generation ("4g"
+// or "5g") and IMSI.
+func (r *FlowRecorder) Flows(generation, imsi string) []Flow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Flow, 0, len(r.recent)+len(r.active))
+	add := func(f Flow) {
+		if (generation != "" && f.Generation != generation) || (imsi != "" && f.IMSI != imsi) {
+			return
+		}
+		f.DurationMs = milliseconds(f.last.Sub(f.started))
+		f.Steps = nil
+		out = append(out, f)
+	}
+	for _, f := range r.active {
+		add(*f)
+	}
+	for _, f := range r.recent {
+		add(f)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].started.After(out[j].started) })
+	return out
+}
+
+// Flow returns the flow with id, with its steps and participants.
+func (r *FlowRecorder) Flow(id string) (Flow, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found *Flow
+	for _, f := range r.active {
+		if f.ID == id {
+			found = f
+		}
+	}
+	for i := range r.recent {
+		if r.recent[i].ID == id {
+			found = &r.recent[i]
+		}
+	}
+	if found == nil {
+		return Flow{}, false
+	}
+	f := *found
+	f.DurationMs = milliseconds(f.last.Sub(f.started))
+	f.Steps = append([]FlowStep(nil), f.Steps...)
+	f.Participants = participants(f.Steps)
+	return f, true
+}
+
+// participants returns the lifelines of steps: the UE, the RAN node and the
+// AMF/MME first, then the other NFs in the order they first appear.
+func participants(steps []FlowStep) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range steps {
+		for _, p := range []string{s.From, s.To} {
+			if !seen[p] {
+				seen[p] = true
+				out = append(out, p)
+			}
+		}
+	}
+	rank := func(p string) int {
+		switch collector.ParseNFKind(p) {
+		case collector.KindUE:
+			return 0
+		case collector.KindGNB, collector.KindENB:
+			return 1
+		case collector.KindAMF, collector.KindMME:
+			return 2
+		}
+		return 3
+	}
+	sort.SliceStable(out, func(i, j int) bool { return rank(out[i]) < rank(out[j]) })
+	return out
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
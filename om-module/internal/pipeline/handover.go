@@ -0,0 +1,417 @@
+package pipeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NGAP (TS 38.413) and S1AP (TS 36.413) procedure codes of the handover
+// procedures.
+const (
+	ngapHandoverCancel             = 10
+	ngapHandoverNotification       = 11
+	ngapHandoverPreparation        = 12
+	ngapHandoverResourceAllocation = 13
+	ngapPathSwitchRequest          = 25
+
+	s1apHandoverPreparation        = 0
+	s1apHandoverResourceAllocation = 1
+	s1apHandoverNotification       = 2
+	s1apPathSwitchRequest          = 3
+	s1apHandoverCancel             = 4
+)
+
+// Handover types: through the core (N2/S1) or directly between RAN nodes
+// (Xn/X2), in which case the core only sees the Path Switch Request.
+const (
+	handoverN2 = "n2"
+	handoverXn = "xn"
+	handoverS1 = "s1"
+	handoverX2 = "x2"
+)
+
+// Handover results.
+const (
+	handoverPending            = "pending"
+	handoverSuccess            = "success"
+	handoverPreparationFailure = "preparation_failure"
+	handoverPathSwitchFailure  = "path_switch_failure"
+	handoverCancelled          = "cancelled"
+	handoverTimeout            = "timeout"
+)
+
+// unknownCell labels a side of a handover whose cell was never seen.
+const unknownCell = "unknown"
+
+// maxHandovers bounds the finished handovers kept for the API.
+const maxHandovers = 100
+
+// ueCellTTL is how long the last cell of a UE is remembered without news.
+const ueCellTTL = time.Hour
+
+// HandoverAnalyzer follows N2/S1 handovers and Xn/X2 path switches per UE
+// and counts attempts by source and target cell. The source cell is the
+// User Location Information the UE last reported through its RAN node; the
+// target cell is the one the UE reports from the target node (Handover
+// Notify, Path Switch Request) or, if the handover fails before that, the
+// target cell or node of the Handover Required.
+type HandoverAnalyzer struct {
+	attempts *prometheus.CounterVec
+
+	mu     sync.Mutex
+	cells  map[string]ueCell    // generation/core UE id → last cell
+	active map[string]*Handover // generation/core UE id
+	recent []Handover           // finished, oldest first
+	matrix map[matrixKey]*HandoverPair
+}
+
+type ueCell struct {
+	cell string
+	seen time.Time
+}
+
+type matrixKey struct{ generation, src, dst string }
+
+// Handover is one handover attempt of a UE.
+type Handover struct {
+	Generation string         `json:"generation"`
+	Type       string         `json:"type"`  // n2 | xn (5G), s1 | x2 (4G)
+	UEID       string         `json:"ue_id"` // AMF-UE-NGAP-ID / MME-UE-S1AP-ID
+	SrcCell    string         `json:"src_cell"`
+	DstCell    string         `json:"dst_cell"`
+	SrcRAN     string         `json:"src_ran,omitempty"`
+	DstRAN     string         `json:"dst_ran,omitempty"`
+	StartedAt  string         `json:"started_at"`
+	DurationMs float64        `json:"duration_ms,omitempty"`
+	Result     string         `json:"result"`
+	Cause      string         `json:"cause,omitempty"`
+	Steps      []HandoverStep `json:"steps"`
+
+	started time.Time
+}
+
+// HandoverStep is one NGAP/S1AP message of a handover.
+type HandoverStep struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// HandoverPair is one cell of the handover matrix.
+type HandoverPair struct {
+	Generation  string  `json:"generation"`
+	SrcCell     string  `json:"src_cell"`
+	DstCell     string  `json:"dst_cell"`
+	Attempts    int     `json:"attempts"`
+	Successes   int     `json:"successes"`
+	Failures    int     `json:"failures"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// NewHandoverAnalyzer registers the handover counter on reg and returns the
+// analyzer.
+func NewHandoverAnalyzer(reg prometheus.Registerer) *HandoverAnalyzer {
+	a := &HandoverAnalyzer{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "handover",
+			Name:      "attempts_total",
+			Help:      "Finished handover attempts by source and target cell and result (success, preparation_failure, path_switch_failure, cancelled, timeout).",
+		}, []string{"generation", "type", "src_cell", "dst_cell", "result"}),
+
+		cells:  make(map[string]ueCell),
+		active: make(map[string]*Handover),
+		matrix: make(map[matrixKey]*HandoverPair),
+	}
+	reg.MustRegister(a.attempts)
+	return a
+}
+
+// Observe implements Observer.
+func (a *HandoverAnalyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	if pkt.APMessageType == "" {
+		return
+	}
+	var code int
+	var ueID, layer string
+	switch pkt.Protocol {
+	case "ngap":
+		code, ueID, layer = pkt.NGAPProcedureCode, pkt.AMFUENGAPId, layerNGAP
+	case "s1ap":
+		code, ueID, layer = s1apAsNGAP(pkt.S1APProcedureCode), pkt.MMEUUES1APID, layerS1AP
+	default:
+		return
+	}
+	if ueID == "" {
+		return
+	}
+	key := pkt.Generation + "/" + ueID
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(pkt.Timestamp)
+
+	h := a.active[key]
+	switch code {
+	case ngapHandoverPreparation:
+		switch pkt.APMessageType {
+		case "initiating": // Handover Required, source RAN → core
+			if h != nil {
+				a.finish(key, h, handoverTimeout, pkt.Timestamp)
+			}
+			h = a.start(key, ueID, pkt, handoverN2, handoverS1)
+			h.SrcRAN = pkt.SrcIP
+			h.DstCell = a.targetLabel(pkt)
+			a.step(h, pkt, "HandoverRequired")
+		case "successful": // Handover Command
+			if h != nil {
+				a.step(h, pkt, "HandoverCommand")
+			}
+		case "unsuccessful": // Handover Preparation Failure
+			if h != nil {
+				a.step(h, pkt, "HandoverPreparationFailure")
+				h.Cause = apCauseName(layer, pkt)
+				a.finish(key, h, handoverPreparationFailure, pkt.Timestamp)
+			}
+		}
+
+	case ngapHandoverResourceAllocation:
+		if h == nil {
+			return
+		}
+		switch pkt.APMessageType {
+		case "initiating": // Handover Request, core → target RAN
+			h.DstRAN = pkt.DstIP
+			a.step(h, pkt, "HandoverRequest")
+		case "successful":
+			a.step(h, pkt, "HandoverRequestAcknowledge")
+		case "unsuccessful": // reported to the source as a preparation failure
+			a.step(h, pkt, "HandoverFailure")
+			h.Cause = apCauseName(layer, pkt)
+		}
+
+	case ngapHandoverNotification: // Handover Notify, target RAN → core
+		if h == nil {
+			return
+		}
+		h.DstRAN = pkt.SrcIP
+		if pkt.APCellID != "" {
+			h.DstCell = pkt.APCellID
+		}
+		a.step(h, pkt, "HandoverNotify")
+		a.finish(key, h, handoverSuccess, pkt.Timestamp)
+
+	case ngapHandoverCancel:
+		if h != nil && pkt.APMessageType == "initiating" {
+			a.step(h, pkt, "HandoverCancel")
+			h.Cause = apCauseName(layer, pkt)
+			a.finish(key, h, handoverCancelled, pkt.Timestamp)
+		}
+
+	case ngapPathSwitchRequest:
+		switch pkt.APMessageType {
+		case "initiating": // the UE already moved over Xn/X2
+			if h != nil {
+				a.finish(key, h, handoverTimeout, pkt.Timestamp)
+			}
+			h = a.start(key, ueID, pkt, handoverXn, handoverX2)
+			h.DstRAN = pkt.SrcIP
+			if pkt.APCellID != "" {
+				h.DstCell = pkt.APCellID
+			}
+			a.step(h, pkt, "PathSwitchRequest")
+		case "successful":
+			if h != nil {
+				a.step(h, pkt, "PathSwitchRequestAcknowledge")
+				a.finish(key, h, handoverSuccess, pkt.Timestamp)
+			}
+		case "unsuccessful":
+			if h != nil {
+				a.step(h, pkt, "PathSwitchRequestFailure")
+				h.Cause = apCauseName(layer, pkt)
+				a.finish(key, h, handoverPathSwitchFailure, pkt.Timestamp)
+			}
+		}
+
+	default:
+		// Any other UE-associated PDU with a User Location Information
+		// carries the cell the UE is camped on.
+		if pkt.APCellID != "" {
+			a.cells[key] = ueCell{cell: pkt.APCellID, seen: pkt.Timestamp}
+		}
+	}
+}
+
+// s1apAsNGAP maps S1AP handover procedure codes onto their NGAP
+// equivalents so both generations share one state machine. Other codes map
+// to -1.
+func s1apAsNGAP(code int) int {
+	switch code {
+	case s1apHandoverPreparation:
+		return ngapHandoverPreparation
+	case s1apHandoverResourceAllocation:
+		return ngapHandoverResourceAllocation
+	case s1apHandoverNotification:
+		return ngapHandoverNotification
+	case s1apPathSwitchRequest:
+		return ngapPathSwitchRequest
+	case s1apHandoverCancel:
+		return ngapHandoverCancel
+	}
+	return -1
+}
+
+// start opens a handover for key from the UE's last known cell. Callers
+// hold a.mu.
+func (a *HandoverAnalyzer) start(key, ueID string, pkt capture.Packet, type5G, type4G string) *Handover {
+	h := &Handover{
+		Generation: pkt.Generation,
+		Type:       type5G,
+		UEID:       ueID,
+		SrcCell:    unknownCell,
+		DstCell:    unknownCell,
+		StartedAt:  pkt.Timestamp.UTC().Format(time.RFC3339),
+		Result:     handoverPending,
+		Steps:      []HandoverStep{},
+		started:    pkt.Timestamp,
+	}
+	if pkt.Generation == "4g" {
+		h.Type = type4G
+	}
+	if c, ok := a.cells[key]; ok {
+		h.SrcCell = c.cell
+	}
+	a.active[key] = h
+	return h
+}
+
+// targetLabel is the target of a Handover Required before the UE reports
+// from it: the target cell of the transparent container, else the target
+// node as "gnb:<id>" / "enb:<id>".
+func (a *HandoverAnalyzer) targetLabel(pkt capture.Packet) string {
+	switch {
+	case pkt.APCellID != "":
+		return pkt.APCellID
+	case pkt.APTargetNodeID == "":
+		return unknownCell
+	case pkt.Generation == "4g":
+		return "enb:" + pkt.APTargetNodeID
+	default:
+		return "gnb:" + pkt.APTargetNodeID
+	}
+}
+
+func (a *HandoverAnalyzer) step(h *Handover, pkt capture.Packet, message string) {
+	h.Steps = append(h.Steps, HandoverStep{
+		Time:    pkt.Timestamp.UTC().Format(time.RFC3339Nano),
+		Message: message,
+	})
+}
+
+// finish records the result of h, counts it and moves it to the recent
+// list. A successful handover moves the UE to the target cell. Callers hold
+// a.mu.
+func (a *HandoverAnalyzer) finish(key string, h *Handover, result string, at time.Time) {
+	delete(a.active, key)
+	h.Result = result
+	if result != handoverTimeout {
+		h.DurationMs = float64(at.Sub(h.started).Microseconds()) / 1000
+	}
+	if result == handoverSuccess && h.DstCell != unknownCell {
+		a.cells[key] = ueCell{cell: h.DstCell, seen: at}
+	}
+	a.attempts.WithLabelValues(h.Generation, h.Type, h.SrcCell, h.DstCell, result).Inc()
+
+	mk := matrixKey{h.Generation, h.SrcCell, h.DstCell}
+	p := a.matrix[mk]
+	if p == nil {
+		p = &HandoverPair{Generation: h.Generation, SrcCell: h.SrcCell, DstCell: h.DstCell}
+		a.matrix[mk] = p
+	}
+	p.Attempts++
+	if result == handoverSuccess {
+		p.Successes++
+	} else {
+		p.Failures++
+	}
+	p.SuccessRate = float64(p.Successes) / float64(p.Attempts)
+
+	a.recent = append(a.recent, *h)
+	if len(a.recent) > maxHandovers {
+		a.recent = a.recent[len(a.recent)-maxHandovers:]
+	}
+}
+
+// expire times out handovers older than attachTimeout and forgets cells not
+// refreshed within ueCellTTL. Callers hold a.mu.
+func (a *HandoverAnalyzer) expire(now time.Time) {
+	for key, h := range a.active {
+		if now.Sub(h.started) > attachTimeout {
+			a.finish(key, h, handoverTimeout, now)
+		}
+	}
+	for key, c := range a.cells {
+		if now.Sub(c.seen) > ueCellTTL {
+			delete(a.cells, key)
+		}
+	}
+}
+
+// Handovers returns the finished handovers followed by those in progress,
+// newest first, optionally filtered by generation ("4g" or "5g").
+func (a *HandoverAnalyzer) Handovers(generation string) []Handover {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]Handover, 0, len(a.recent)+len(a.active))
+	add := func(h Handover) {
+		if generation != "" && h.Generation != generation {
+			return
+		}
+		h.Steps = append([]HandoverStep(nil), h.Steps...)
+		out = append(out, h)
+	}
+	for _, h := range a.active {
+		add(*h)
+	}
+	for _, h := range a.recent {
+		add(h)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].started.After(out[j].started) })
+	return out
+}
+
+// Matrix returns the finished attempts per source and target cell since
+// the module started, sorted by generation and cells.
+func (a *HandoverAnalyzer) Matrix(generation string) []HandoverPair {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]HandoverPair, 0, len(a.matrix))
+	for _, p := range a.matrix {
+		if generation == "" || p.Generation == generation {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Generation != out[j].Generation {
+			return out[i].Generation < out[j].Generation
+		}
+		if out[i].SrcCell != out[j].SrcCell {
+			return out[i].SrcCell < out[j].SrcCell
+		}
+		return out[i].DstCell < out[j].DstCell
+	})
+	return out
+}
+
+// apCauseName is the 3GPP name of the Cause IE of pkt, or "".
+func apCauseName(layer string, pkt capture.Packet) string {
+	if pkt.APCauseGroup == "" {
+		return ""
+	}
+	return lookupAPCause(layer, pkt.APCauseGroup, pkt.APCause).Name
+}
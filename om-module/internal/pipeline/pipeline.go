@@ -18,23 +18,47 @@ import (
 
 const networkName = "docker_open5gs_default"
 
+// Observer receives every captured packet together with the NF names resolved
+// for its source and destination IPs. Observers run synchronously on the
+// pipeline goroutine and must not block.
+type Observer interface {
+	Observe(pkt capture.Packet, srcNF, dstNF string)
+}
+
 // Pipeline reads packets from the capture manager and emits one span per packet.
 type Pipeline struct {
-	mcc     string
-	mnc     string
-	docker  *dockerclient.Client
-	snap    *collector.Snapshot
-	metrics *Metrics
+	mcc       string
+	mnc       string
+	docker    *dockerclient.Client
+	snap      *collector.Snapshot
+	metrics   *Metrics
+	observers []Observer
+	staticNFs map[string]string // IP → NF for nodes that are not containers
 }
 
 // New creates a Pipeline. metrics may be nil if Prometheus is not enabled.
-func New(mcc, mnc string, docker *dockerclient.Client, snap *collector.Snapshot, metrics *Metrics) *Pipeline {
+// observers (SBI analyzer, milestone engine, …) see every packet, including
+// heartbeats and SBI responses that never become spans.
+func New(mcc, mnc string, docker *dockerclient.Client, snap *collector.Snapshot, metrics *Metrics, observers ...Observer) *Pipeline {
 	return &Pipeline{
-		mcc:     mcc,
-		mnc:     mnc,
-		docker:  docker,
-		snap:    snap,
-		metrics: metrics,
+		mcc:       mcc,
+		mnc:       mnc,
+		docker:    docker,
+		snap:      snap,
+		metrics:   metrics,
+		observers: observers,
+	}
+}
+
+// AddStaticNFs registers IP→NF entries for nodes that are not containers on
+// the lab network, such as the synthetic nodes of demo mode. Containers win
+// when both claim an IP. Must be called before Run.
+func (p *Pipeline) AddStaticNFs(ipToNF map[string]string) {
+	if p.staticNFs == nil {
+		p.staticNFs = make(map[string]string, len(ipToNF))
+	}
+	for ip, nf := range ipToNF {
+		p.staticNFs[ip] = nf
 	}
 }
 
@@ -54,6 +78,16 @@ func (p *Pipeline) Run(ctx context.Context, pkts <-chan capture.Packet) {
 			if !ok {
 				return
 			}
+			if len(p.observers) > 0 {
+				srcNF, dstNF := resolveNF(pkt.SrcIP, ipToNF), resolveNF(pkt.DstIP, ipToNF)
+				for _, o := range p.observers {
+					o.Observe(pkt, srcNF, dstNF)
+				}
+			}
+			// SBI response frames only feed the observers — no span.
+			if pkt.Protocol == "sbi" && pkt.SBIMethod == "" {
+				continue
+			}
 			// Ignore heartbeats and keepalives — they add noise with no value
 			if isHeartbeat(pkt) {
 				continue
@@ -84,14 +118,8 @@ func (p *Pipeline) emitSpan(ctx context.Context, pkt capture.Packet, ipToNF map[
 	}
 
 	// Resolve NF names from IPs
-	srcNF := ipToNF[pkt.SrcIP]
-	if srcNF == "" {
-		srcNF = pkt.SrcIP
-	}
-	dstNF := ipToNF[pkt.DstIP]
-	if dstNF == "" {
-		dstNF = pkt.DstIP
-	}
+	srcNF := resolveNF(pkt.SrcIP, ipToNF)
+	dstNF := resolveNF(pkt.DstIP, ipToNF)
 
 	// Resolve generation from packet or from IP if not set (PFCP has no generation)
 	generation := pkt.Generation
@@ -184,8 +212,17 @@ func (p *Pipeline) emitSpan(ctx context.Context, pkt capture.Packet, ipToNF map[
 
 // --- Helpers ----------------------------------------------------------------
 
+// resolveNF returns the NF name for ip, or the IP itself when unknown.
+func resolveNF(ip string, ipToNF map[string]string) string {
+	if nf := ipToNF[ip]; nf != "" {
+		return nf
+	}
+	return ip
+}
+
 // isHeartbeat returns true for PFCP heartbeats, GTPv2 echo, Diameter
-// Device-Watchdog, and SBI NRF heartbeat PATCH messages which add noise.
+// Device-Watchdog, SBI NRF heartbeat PATCH and SIP OPTIONS keep-alives, which
+// add noise.
 func isHeartbeat(pkt capture.Packet) bool {
 	switch pkt.Protocol {
 	case "pfcp":
@@ -199,6 +236,8 @@ func isHeartbeat(pkt capture.Packet) bool {
 		return pkt.SBIMethod == "PATCH" &&
 			strings.Contains(pkt.SBIPath, "/nnrf-nfm/") &&
 			strings.Contains(pkt.SBIPath, "/nf-instances/")
+	case "sip":
+		return pkt.SIPMethod == "OPTIONS" || pkt.SIPCSeqMethod == "OPTIONS"
 	}
 	return false
 }
@@ -218,6 +257,8 @@ func spanName(pkt capture.Packet) string {
 		return diameterSpanName(pkt)
 	case "sbi":
 		return sbiSpanName(pkt)
+	case "sip":
+		return sipSpanName(pkt)
 	}
 	return ""
 }
@@ -299,10 +340,28 @@ func packetIMSI(pkt capture.Packet) string {
 		return pkt.DiameterIMSI
 	case "sbi":
 		return pkt.SBIIMSI
+	case "sip":
+		// IMS users provisioned from the SIM have an IMSI-based IMPU.
+		if isIMSI(pkt.SIPFromUser) {
+			return pkt.SIPFromUser
+		}
 	}
 	return ""
 }
 
+// isIMSI reports whether s looks like a 15-digit IMSI.
+func isIMSI(s string) bool {
+	if len(s) != 15 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // protocolAttrs extracts protocol-specific span attributes.
 func protocolAttrs(pkt capture.Packet) (procedure, nasMsg, teid, seid, apn, cause string) {
 	switch pkt.Protocol {
@@ -328,6 +387,11 @@ func protocolAttrs(pkt capture.Packet) (procedure, nasMsg, teid, seid, apn, caus
 	case "sbi":
 		procedure = pkt.SBIService
 		cause = pkt.SBIStatus
+	case "sip":
+		procedure = pkt.SIPCSeqMethod
+		if pkt.SIPStatusCode != 0 {
+			cause = fmt.Sprint(pkt.SIPStatusCode)
+		}
 	}
 	return
 }
@@ -342,12 +406,15 @@ func messageDirection(pkt capture.Packet, ipToNF map[string]string) string {
 		}
 		return "response"
 	}
-	srcNF := ipToNF[pkt.SrcIP]
-	coreNFs := map[string]bool{
-		"amf": true, "mme": true, "smf": true, "smf2": true,
-		"pgw": true, "sgw": true, "upf": true, "upf2": true,
+	if pkt.Protocol == "sip" {
+		if pkt.SIPMethod != "" {
+			return "request"
+		}
+		return "response"
 	}
-	if coreNFs[srcNF] {
+	switch collector.ParseNFKind(ipToNF[pkt.SrcIP]) {
+	case collector.KindAMF, collector.KindMME, collector.KindSMF,
+		collector.KindUPF, collector.KindSGWC, collector.KindSGWU:
 		return "response"
 	}
 	return "request"
@@ -408,16 +475,33 @@ func isErrorCause(pkt capture.Packet) bool {
 			fmt.Sscanf(pkt.SBIStatus, "%d", &code)
 			return code >= 400
 		}
+	case "sip":
+		// 401/407 are the normal IMS AKA challenge, not failures.
+		return pkt.SIPStatusCode >= 400 && pkt.SIPStatusCode != 401 && pkt.SIPStatusCode != 407
 	}
 	return false
 }
 
+// sipSpanName builds the span name for a SIP packet.
+// Format: "SIP:INVITE" for requests, "SIP:180 INVITE" for responses.
+func sipSpanName(pkt capture.Packet) string {
+	if pkt.SIPMethod != "" {
+		return "SIP:" + pkt.SIPMethod
+	}
+	return fmt.Sprintf("SIP:%d %s", pkt.SIPStatusCode, pkt.SIPCSeqMethod)
+}
+
 // buildIPToNFMap joins Docker network IPs with collector snapshot NF labels.
 func (p *Pipeline) buildIPToNFMap(ctx context.Context) map[string]string {
+	result := make(map[string]string, len(p.staticNFs))
+	for ip, nf := range p.staticNFs {
+		result[ip] = nf
+	}
+
 	ipToName, err := p.docker.GetNetworkContainerIPs(ctx, networkName)
 	if err != nil {
 		log.Printf("⚠️  IP→NF resolution failed: %v", err)
-		return map[string]string{}
+		return result
 	}
 	nameToNF := p.snap.NameToNFMap()
 
@@ -447,7 +531,6 @@ func (p *Pipeline) buildIPToNFMap(ctx context.Context) map[string]string {
 		"nr_ue_bad_sst":  "ue",
 	}
 
-	result := make(map[string]string, len(ipToName))
 	for ip, name := range ipToName {
 		if nf, ok := nameToNF[name]; ok {
 			result[ip] = nf
@@ -466,6 +549,10 @@ func ngapProcedureName(code int) string {
 	names := map[int]string{
 		0:  "AMFConfigurationUpdate",
 		4:  "DownlinkNASTransport",
+		10: "HandoverCancel",
+		11: "HandoverNotification",
+		12: "HandoverPreparation",
+		13: "HandoverResourceAllocation",
 		14: "InitialContextSetup",
 		15: "InitialUEMessage",
 		20: "NGReset",
@@ -483,9 +570,6 @@ func ngapProcedureName(code int) string {
 		48: "RerouteNASRequest",
 		52: "LocationReportingControl",
 		60: "PDUSessionResourceNotify",
-		63: "HandoverCancel",
-		64: "HandoverRequired",
-		65: "HandoverCommand",
 	}
 	if n, ok := names[code]; ok {
 		return n
@@ -497,6 +581,9 @@ func s1apProcedureName(code int) string {
 	names := map[int]string{
 		0:  "HandoverPreparation",
 		1:  "HandoverResourceAllocation",
+		2:  "HandoverNotification",
+		3:  "PathSwitchRequest",
+		4:  "HandoverCancel",
 		9:  "InitialContextSetup",
 		11: "DownlinkNASTransport",
 		12: "InitialUEMessage",
@@ -535,38 +622,23 @@ func nasMM5GName(msgType string) string {
 	return ""
 }
 
-// resolveGeneration infers 4g or 5g from the NF names involved in a packet.
-// Used for PFCP packets which have no generation set by the parser.
+// resolveGeneration infers 4g or 5g from the kinds of the NFs involved in a
+// packet. Used for PFCP packets which have no generation set by the parser.
 // 4G PFCP: sgwc/sgwu (Sxa) and smf/upf acting as pgwc/pgwu (Sxb)
 // 5G PFCP: smf/upf (N4)
-// Note: smf and upf appear in both — we disambiguate by checking if sgwc/sgwu
-// are also present in the ipToNF map (meaning 4G core is running).
+// Note: smf and upf appear in both — we disambiguate by checking if a
+// 4G-only NF is also present in the ipToNF map (meaning 4G core is running).
 func resolveGeneration(srcIP, dstIP string, ipToNF map[string]string) string {
-	srcNF := ipToNF[srcIP]
-	dstNF := ipToNF[dstIP]
-
-	nf4g := map[string]bool{
-		"mme": true, "sgwc": true, "sgwu": true,
-		"pgwc": true, "pgwu": true, "hss": true, "pcrf": true,
-	}
-	nf5g := map[string]bool{
-		"amf": true, "ausf": true, "udm": true, "udr": true,
-		"pcf": true, "nrf": true, "nssf": true, "scp": true, "bsf": true,
-	}
-
-	for _, nf := range []string{srcNF, dstNF} {
-		if nf4g[nf] {
-			return "4g"
-		}
-		if nf5g[nf] {
-			return "5g"
+	for _, nf := range []string{ipToNF[srcIP], ipToNF[dstIP]} {
+		if g := collector.ParseNFKind(nf).Generation(); g != "" {
+			return g
 		}
 	}
 
 	// smf and upf exist in both generations — check if any 4G-specific NF
 	// is present in the overall IP map to determine which core is running
 	for _, nf := range ipToNF {
-		if nf == "sgwc" || nf == "sgwu" || nf == "mme" {
+		if collector.ParseNFKind(nf).Generation() == "4g" {
 			return "4g"
 		}
 	}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// sbiPendingTTL is how long a request waits for its response before it is
+	// counted as unanswered and evicted.
+	sbiPendingTTL = 30 * time.Second
+
+	// sbiPendingMax bounds the number of in-flight requests tracked at once.
+	sbiPendingMax = 4096
+)
+
+// SBIAnalyzer pairs captured SBI HTTP/2 requests with their responses and
+// summarises the service-based architecture per NF pair: which API paths are
+// called, with which methods, and which status codes come back.
+//
+// It is optional — the pipeline only feeds it when SBI_ANALYZER_ENABLED=true.
+type SBIAnalyzer struct {
+	responses *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	timeouts  *prometheus.CounterVec
+
+	mu         sync.Mutex
+	pending    map[sbiStreamKey]sbiRequest
+	summary    map[sbiPairKey]map[sbiOpKey]*sbiOpStats
+	lastExpiry time.Time
+}
+
+// sbiStreamKey identifies one HTTP/2 stream as seen from the client side.
+type sbiStreamKey struct {
+	clientIP   string
+	clientPort int
+	serverIP   string
+	serverPort int
+	streamID   int
+}
+
+type sbiRequest struct {
+	service string
+	method  string
+	path    string
+	srcNF   string
+	dstNF   string
+	seen    time.Time
+}
+
+type sbiPairKey struct{ srcNF, dstNF string }
+
+type sbiOpKey struct{ service, method, path string }
+
+type sbiOpStats struct {
+	requests    uint64
+	unanswered  uint64
+	statuses    map[string]uint64
+	totalMillis float64
+	answered    uint64
+	lastSeen    time.Time
+}
+
+// SBIOperation is one (service, method, path template) summary row.
+type SBIOperation struct {
+	Service        string            `json:"service"`
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Requests       uint64            `json:"requests"`
+	Unanswered     uint64            `json:"unanswered"`
+	ErrorResponses uint64            `json:"error_responses"`
+	StatusCodes    map[string]uint64 `json:"status_codes"`
+	AvgLatencyMs   float64           `json:"avg_latency_ms"`
+	LastSeen       string            `json:"last_seen"`
+}
+
+// SBIPairSummary groups the operations observed from one NF to another.
+type SBIPairSummary struct {
+	SrcNF      string         `json:"src_nf"`
+	DstNF      string         `json:"dst_nf"`
+	Requests   uint64         `json:"requests"`
+	Operations []SBIOperation `json:"operations"`
+}
+
+// NewSBIAnalyzer registers the analyzer metrics on reg and returns it.
+func NewSBIAnalyzer(reg prometheus.Registerer) *SBIAnalyzer {
+	a := &SBIAnalyzer{
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "sbi",
+			Name:      "responses_total",
+			Help:      "SBI HTTP/2 request/response exchanges by service, method, path template, status code and NF pair.",
+		}, []string{"service", "method", "path", "status", "src_nf", "dst_nf"}),
+
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "om",
+			Subsystem: "sbi",
+			Name:      "response_seconds",
+			Help:      "Time between an SBI request and its response as seen on the wire.",
+			Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+		}, []string{"service", "method", "src_nf", "dst_nf"}),
+
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "sbi",
+			Name:      "unanswered_total",
+			Help:      "SBI requests for which no response was captured within 30 seconds.",
+		}, []string{"service", "method", "src_nf", "dst_nf"}),
+
+		pending: make(map[sbiStreamKey]sbiRequest),
+		summary: make(map[sbiPairKey]map[sbiOpKey]*sbiOpStats),
+	}
+	reg.MustRegister(a.responses, a.latency, a.timeouts)
+	return a
+}
+
+// Observe feeds one captured SBI packet into the analyzer. srcNF and dstNF are
+// the NF names already resolved by the pipeline for the packet's IPs.
+func (a *SBIAnalyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	if pkt.Protocol != "sbi" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.expire(pkt.Timestamp)
+
+	if pkt.SBIMethod != "" {
+		a.observeRequest(pkt, srcNF, dstNF)
+		return
+	}
+	if pkt.SBIStatus != "" {
+		a.observeResponse(pkt)
+	}
+}
+
+// observeRequest records an in-flight request. Caller holds a.mu.
+func (a *SBIAnalyzer) observeRequest(pkt capture.Packet, srcNF, dstNF string) {
+	req := sbiRequest{
+		service: pkt.SBIService,
+		method:  pkt.SBIMethod,
+		path:    sbiPathTemplate(pkt.SBIPath),
+		srcNF:   srcNF,
+		dstNF:   dstNF,
+		seen:    pkt.Timestamp,
+	}
+
+	op := a.op(req)
+	op.requests++
+	op.lastSeen = pkt.Timestamp
+
+	if len(a.pending) >= sbiPendingMax {
+		// Drop the whole table rather than growing without bound; the
+		// affected requests are simply never paired.
+		a.pending = make(map[sbiStreamKey]sbiRequest)
+	}
+	a.pending[sbiStreamKey{pkt.SrcIP, pkt.SrcPort, pkt.DstIP, pkt.DstPort, pkt.SBIStreamID}] = req
+}
+
+// observeResponse pairs a response with its pending request. Caller holds a.mu.
+func (a *SBIAnalyzer) observeResponse(pkt capture.Packet) {
+	// Responses travel server → client, so the key is reversed.
+	key := sbiStreamKey{pkt.DstIP, pkt.DstPort, pkt.SrcIP, pkt.SrcPort, pkt.SBIStreamID}
+	req, ok := a.pending[key]
+	if !ok {
+		return
+	}
+	delete(a.pending, key)
+
+	a.responses.WithLabelValues(req.service, req.method, req.path, pkt.SBIStatus, req.srcNF, req.dstNF).Inc()
+
+	elapsed := pkt.Timestamp.Sub(req.seen)
+	if elapsed >= 0 {
+		a.latency.WithLabelValues(req.service, req.method, req.srcNF, req.dstNF).Observe(elapsed.Seconds())
+	}
+
+	op := a.op(req)
+	op.statuses[pkt.SBIStatus]++
+	op.answered++
+	if elapsed >= 0 {
+		op.totalMillis += float64(elapsed) / float64(time.Millisecond)
+	}
+}
+
+// expire evicts requests that have waited longer than sbiPendingTTL. The scan
+// runs at most once per second of capture time. Caller holds a.mu.
+func (a *SBIAnalyzer) expire(now time.Time) {
+	if now.Sub(a.lastExpiry) < time.Second {
+		return
+	}
+	a.lastExpiry = now
+
+	for key, req := range a.pending {
+		if now.Sub(req.seen) < sbiPendingTTL {
+			continue
+		}
+		delete(a.pending, key)
+		a.timeouts.WithLabelValues(req.service, req.method, req.srcNF, req.dstNF).Inc()
+		a.op(req).unanswered++
+	}
+}
+
+// op returns (creating if needed) the summary entry for req. Caller holds a.mu.
+func (a *SBIAnalyzer) op(req sbiRequest) *sbiOpStats {
+	pair := sbiPairKey{req.srcNF, req.dstNF}
+	ops, ok := a.summary[pair]
+	if !ok {
+		ops = make(map[sbiOpKey]*sbiOpStats)
+		a.summary[pair] = ops
+	}
+	key := sbiOpKey{req.service, req.method, req.path}
+	st, ok := ops[key]
+	if !ok {
+		st = &sbiOpStats{statuses: make(map[string]uint64)}
+		ops[key] = st
+	}
+	return st
+}
+
+// Summary returns the per-NF-pair SBI summary, busiest pairs first.
+func (a *SBIAnalyzer) Summary() []SBIPairSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]SBIPairSummary, 0, len(a.summary))
+	for pair, ops := range a.summary {
+		ps := SBIPairSummary{SrcNF: pair.srcNF, DstNF: pair.dstNF}
+		for key, st := range ops {
+			op := SBIOperation{
+				Service:     key.service,
+				Method:      key.method,
+				Path:        key.path,
+				Requests:    st.requests,
+				Unanswered:  st.unanswered,
+				StatusCodes: make(map[string]uint64, len(st.statuses)),
+				LastSeen:    st.lastSeen.UTC().Format(time.RFC3339),
+			}
+			for code, n := range st.statuses {
+				op.StatusCodes[code] = n
+				if strings.HasPrefix(code, "4") || strings.HasPrefix(code, "5") {
+					op.ErrorResponses += n
+				}
+			}
+			if st.answered > 0 {
+				op.AvgLatencyMs = st.totalMillis / float64(st.answered)
+			}
+			ps.Requests += st.requests
+			ps.Operations = append(ps.Operations, op)
+		}
+		sort.Slice(ps.Operations, func(i, j int) bool {
+			return ps.Operations[i].Requests > ps.Operations[j].Requests
+		})
+		out = append(out, ps)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Requests > out[j].Requests })
+	return out
+}
+
+var (
+	// These match path segments that are identifiers rather than
+	// resource names: SUPIs/SUCIs, UUIDs and long hex or numeric IDs.
+	sbiUEIDSegment = regexp.MustCompile(`^(imsi|suci|imei|imeisv|msisdn|nai)-.+$`)
+	sbiUUIDSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	sbiNumSegment  = regexp.MustCompile(`^[0-9a-fA-F]*[0-9][0-9a-fA-F]*$`)
+)
+
+// sbiPathTemplate collapses identifiers in an SBI path so that metric label
+// cardinality stays bounded, e.g.
+//
+//	/nudm-sdm/v2/imsi-001011234567895/am-data → /nudm-sdm/v2/{ueId}/am-data
+//	/nsmf-pdusession/v1/sm-contexts/1/modify    → /nsmf-pdusession/v1/sm-contexts/{id}/modify
+func sbiPathTemplate(path string) string {
+	if q := strings.IndexByte(path, '?'); q >= 0 {
+		path = path[:q]
+	}
+	parts := strings.Split(path, "/")
+	for i, seg := range parts {
+		switch {
+		case seg == "":
+		case sbiUEIDSegment.MatchString(seg):
+			parts[i] = "{ueId}"
+		case sbiUUIDSegment.MatchString(seg):
+			parts[i] = "{id}"
+		case i > 2 && sbiNumSegment.MatchString(seg):
+			// Segments 1 and 2 are the service name and API version (v1, v2).
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,25 @@
+package pipeline
+
+import "testing"
+
+func TestSBIPathTemplate(t *testing.T) {
+	tests := []struct{ path, want string }{
+		{"/nudm-sdm/v2/imsi-001011234567895/am-data", "/nudm-sdm/v2/{ueId}/am-data"},
+		{"/nsmf-pdusession/v1/sm-contexts/1/modify", "/nsmf-pdusession/v1/sm-contexts/{id}/modify"},
+		{"/nausf-auth/v1/ue-authentications", "/nausf-auth/v1/ue-authentications"},
+		{"/nausf-auth/v1/ue-authentications/suci-0-001-01-0000-0-0-0000000001", "/nausf-auth/v1/ue-authentications/{ueId}"},
+		{"/nausf-auth/v1/ue-authentications/3/5g-aka-confirmation", "/nausf-auth/v1/ue-authentications/{id}/5g-aka-confirmation"},
+		{"/nudm-uecm/v1/imsi-001011234567895/registrations/amf-3gpp-access", "/nudm-uecm/v1/{ueId}/registrations/amf-3gpp-access"},
+		{"/npcf-smpolicycontrol/v1/sm-policies/0a1b2c", "/npcf-smpolicycontrol/v1/sm-policies/{id}"},
+		{"/nnrf-nfm/v1/nf-instances/6e2e3b1a-1c2d-11ef-8a5a-0242ac160009", "/nnrf-nfm/v1/nf-instances/{id}"},
+		{"/nnrf-disc/v1/nf-instances?target-nf-type=AUSF&requester-nf-type=AMF", "/nnrf-disc/v1/nf-instances"},
+		{"/nudm-sdm/v2/imsi-001011234567895/smf-select-data?plmn-id=00101", "/nudm-sdm/v2/{ueId}/smf-select-data"},
+		{"/nsmf-pdusession/v1/sm-contexts/add/modify", "/nsmf-pdusession/v1/sm-contexts/add/modify"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sbiPathTemplate(tt.path); got != tt.want {
+			t.Errorf("sbiPathTemplate(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package pipeline
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NAS authentication and security mode messages. 5GMM and EMM number the
+// authentication messages differently; Security Mode shares its codes.
+var (
+	nas5GMMSecurityMessages = map[string]string{
+		"0x56": msgAuthRequest,
+		"0x57": msgAuthResponse,
+		"0x58": msgAuthReject,
+		"0x59": msgAuthFailure,
+		"0x5a": msgAuthResult,
+		"0x5d": msgSecurityModeCommand,
+		"0x5e": msgSecurityModeComplete,
+		"0x5f": msgSecurityModeReject,
+	}
+	nasEMMSecurityMessages = map[string]string{
+		"0x52": msgAuthRequest,
+		"0x53": msgAuthResponse,
+		"0x54": msgAuthReject,
+		"0x5c": msgAuthFailure,
+		"0x5d": msgSecurityModeCommand,
+		"0x5e": msgSecurityModeComplete,
+		"0x5f": msgSecurityModeReject,
+	}
+)
+
+const (
+	msgAuthRequest          = "AuthenticationRequest"
+	msgAuthResponse         = "AuthenticationResponse"
+	msgAuthReject           = "AuthenticationReject"
+	msgAuthFailure          = "AuthenticationFailure"
+	msgAuthResult           = "AuthenticationResult"
+	msgSecurityModeCommand  = "SecurityModeCommand"
+	msgSecurityModeComplete = "SecurityModeComplete"
+	msgSecurityModeReject   = "SecurityModeReject"
+)
+
+// securityExplanations describe each step for students. AUSF/UDM are the
+// 5G names; the 4G equivalent of both is the HSS.
+var securityExplanations = map[string]string{
+	msgAuthRequest:          "The network challenges the UE with RAND and AUTN from an authentication vector computed by the UDM/HSS from the subscriber key K.",
+	msgAuthResponse:         "The USIM verified AUTN (the network is genuine) and answers RES*/RES, which the AMF/AUSF (5G) or MME (4G) compares with the expected value.",
+	msgAuthReject:           "The network did not accept RES*/RES: the UE's K/OPc differs from the subscriber provisioned in the core.",
+	msgAuthFailure:          "The UE could not verify AUTN: MAC failure means different keys, synch failure means the SQN must be re-synchronised.",
+	msgAuthResult:           "The network reports the outcome of EAP-AKA' authentication (not used by 5G-AKA).",
+	msgSecurityModeCommand:  "The network selects the ciphering and integrity algorithms and activates NAS security with keys derived from the authentication (K_AMF/K_ASME).",
+	msgSecurityModeComplete: "The UE accepted the algorithms; from here on NAS is integrity-protected and, unless the null algorithm was chosen, ciphered.",
+	msgSecurityModeReject:   "The UE refused the selected algorithms or could not verify the command — check the security capabilities it announced.",
+}
+
+// Authentication results.
+const (
+	authPending      = "pending"
+	authResponded    = "responded"
+	authRejected     = "rejected"
+	authMACFailure   = "mac_failure"
+	authSynchFailure = "synch_failure"
+	authFailure      = "failure"
+)
+
+// Security Mode results. securityModeNotSeen covers the Complete that was
+// never decoded, which is also what happens when NAS is ciphered with a
+// non-null algorithm.
+const (
+	securityModePending  = "pending"
+	securityModeComplete = "complete"
+	securityModeRejected = "rejected"
+	securityModeNotSeen  = "not_seen"
+)
+
+// maxSecurityProcedures bounds the finished procedures kept for the API.
+const maxSecurityProcedures = 50
+
+// SecurityAnalyzer follows NAS authentication (5G-AKA / EPS-AKA) and
+// Security Mode procedures per UE: whether the challenge carried RAND and
+// AUTN, how the UE answered and which ciphering and integrity algorithms
+// the AMF/MME selected. No key material is kept — only IE presence and
+// algorithm identifiers.
+type SecurityAnalyzer struct {
+	authRequests *prometheus.CounterVec
+	authResults  *prometheus.CounterVec
+	securityMode *prometheus.CounterVec
+
+	mu     sync.Mutex
+	active map[string]*SecurityProcedure // generation/RAN IP/RAN UE id
+	recent []SecurityProcedure           // finished, oldest first
+}
+
+// SecurityProcedure is the authentication and security mode of one UE.
+type SecurityProcedure struct {
+	Generation   string         `json:"generation"`
+	RAN          string         `json:"ran"`
+	RANUEID      string         `json:"ran_ue_id"`
+	StartedAt    string         `json:"started_at"`
+	RAND         bool           `json:"rand"`
+	AUTN         bool           `json:"autn"`
+	AuthResult   string         `json:"auth_result,omitempty"`
+	Ciphering    string         `json:"ciphering,omitempty"`
+	Integrity    string         `json:"integrity,omitempty"`
+	SecurityMode string         `json:"security_mode,omitempty"`
+	Steps        []SecurityStep `json:"steps"`
+
+	started time.Time
+}
+
+// SecurityStep is one NAS message of a procedure.
+type SecurityStep struct {
+	Time        string `json:"time"`
+	Message     string `json:"message"`
+	Direction   string `json:"direction"` // "downlink" (network → UE) or "uplink"
+	Explanation string `json:"explanation"`
+}
+
+// NewSecurityAnalyzer registers the NAS security counters on reg and
+// returns the analyzer.
+func NewSecurityAnalyzer(reg prometheus.Registerer) *SecurityAnalyzer {
+	a := &SecurityAnalyzer{
+		authRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "nas",
+			Name:      "auth_requests_total",
+			Help:      "NAS Authentication Requests, by whether RAND and AUTN were both present (complete) or not (incomplete).",
+		}, []string{"generation", "vector"}),
+
+		authResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "nas",
+			Name:      "auth_results_total",
+			Help:      "Answer to NAS authentication challenges (responded, rejected, mac_failure, synch_failure, failure).",
+		}, []string{"generation", "result"}),
+
+		securityMode: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om",
+			Subsystem: "nas",
+			Name:      "security_mode_total",
+			Help:      "NAS Security Mode procedures by selected algorithms and outcome (complete, rejected, not_seen).",
+		}, []string{"generation", "ciphering", "integrity", "result"}),
+
+		active: make(map[string]*SecurityProcedure),
+	}
+	reg.MustRegister(a.authRequests, a.authResults, a.securityMode)
+	return a
+}
+
+// Observe implements Observer.
+func (a *SecurityAnalyzer) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	var message, ueID string
+	var cause int
+	switch pkt.Protocol {
+	case "ngap":
+		message = nas5GMMSecurityMessages[strings.ToLower(pkt.NASMMType)]
+		ueID, cause = pkt.RANUENGAPId, pkt.NAS5GMMCause
+	case "s1ap":
+		message = nasEMMSecurityMessages[strings.ToLower(pkt.NASEMMType)]
+		ueID, cause = pkt.ENBUUES1APID, pkt.NASEMMCause
+	}
+	if message == "" || ueID == "" {
+		return
+	}
+
+	// Requests, Rejects, Commands and Results come from the core; the RAN
+	// is the destination. Everything else is the UE's answer.
+	direction, ran := "downlink", pkt.DstIP
+	switch message {
+	case msgAuthResponse, msgAuthFailure, msgSecurityModeComplete, msgSecurityModeReject:
+		direction, ran = "uplink", pkt.SrcIP
+	}
+	key := pkt.Generation + "/" + ran + "/" + ueID
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(pkt.Timestamp)
+
+	p := a.active[key]
+	if message == msgAuthRequest && p != nil {
+		// A new challenge (e.g. after a synch failure) starts a new procedure.
+		a.finish(key, p)
+		p = nil
+	}
+	if p == nil {
+		p = &SecurityProcedure{
+			Generation: pkt.Generation,
+			RAN:        ran,
+			RANUEID:    ueID,
+			StartedAt:  pkt.Timestamp.UTC().Format(time.RFC3339),
+			Steps:      []SecurityStep{},
+			started:    pkt.Timestamp,
+		}
+		a.active[key] = p
+	}
+	p.Steps = append(p.Steps, SecurityStep{
+		Time:        pkt.Timestamp.UTC().Format(time.RFC3339Nano),
+		Message:     message,
+		Direction:   direction,
+		Explanation: securityExplanations[message],
+	})
+
+	switch message {
+	case msgAuthRequest:
+		p.RAND, p.AUTN = pkt.NASAuthRAND, pkt.NASAuthAUTN
+		p.AuthResult = authPending
+		vector := "complete"
+		if !p.RAND || !p.AUTN {
+			vector = "incomplete"
+		}
+		a.authRequests.WithLabelValues(pkt.Generation, vector).Inc()
+
+	case msgAuthResponse:
+		a.authResult(p, authResponded)
+	case msgAuthReject:
+		a.authResult(p, authRejected)
+		a.finish(key, p)
+	case msgAuthFailure:
+		switch cause {
+		case 20:
+			a.authResult(p, authMACFailure)
+		case 21:
+			a.authResult(p, authSynchFailure)
+		default:
+			a.authResult(p, authFailure)
+		}
+
+	case msgSecurityModeCommand:
+		p.Ciphering = algorithmName(pkt.Generation, "ciphering", pkt.NASCipheringAlg)
+		p.Integrity = algorithmName(pkt.Generation, "integrity", pkt.NASIntegrityAlg)
+		p.SecurityMode = securityModePending
+	case msgSecurityModeComplete:
+		a.securityModeResult(p, securityModeComplete)
+		a.finish(key, p)
+	case msgSecurityModeReject:
+		a.securityModeResult(p, securityModeRejected)
+		a.finish(key, p)
+	}
+}
+
+// authResult records the UE's answer to the pending challenge. Callers
+// hold a.mu.
+func (a *SecurityAnalyzer) authResult(p *SecurityProcedure, result string) {
+	if p.AuthResult != authPending {
+		return
+	}
+	p.AuthResult = result
+	a.authResults.WithLabelValues(p.Generation, result).Inc()
+}
+
+// securityModeResult records the outcome of the pending Security Mode
+// Command. Callers hold a.mu.
+func (a *SecurityAnalyzer) securityModeResult(p *SecurityProcedure, result string) {
+	if p.SecurityMode != securityModePending {
+		return
+	}
+	p.SecurityMode = result
+	a.securityMode.WithLabelValues(p.Generation, labelOr(p.Ciphering), labelOr(p.Integrity), result).Inc()
+}
+
+// finish moves p from the active set to the recent list. Callers hold a.mu.
+func (a *SecurityAnalyzer) finish(key string, p *SecurityProcedure) {
+	delete(a.active, key)
+	a.recent = append(a.recent, *p)
+	if len(a.recent) > maxSecurityProcedures {
+		a.recent = a.recent[len(a.recent)-maxSecurityProcedures:]
+	}
+}
+
+// expire finishes procedures older than attachTimeout; a Security Mode
+// Command still pending then is counted as not seen. Callers hold a.mu.
+func (a *SecurityAnalyzer) expire(now time.Time) {
+	for key, p := range a.active {
+		if now.Sub(p.started) <= attachTimeout {
+			continue
+		}
+		a.securityModeResult(p, securityModeNotSeen)
+		a.finish(key, p)
+	}
+}
+
+// Procedures returns the finished procedures followed by those in progress,
+// newest first, optionally filtered by generation ("4g" or "5g").
+func (a *SecurityAnalyzer) Procedures(generation string) []SecurityProcedure {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]SecurityProcedure, 0, len(a.recent)+len(a.active))
+	add := func(p SecurityProcedure) {
+		if generation != "" && p.Generation != generation {
+			return
+		}
+		p.Steps = append([]SecurityStep(nil), p.Steps...)
+		out = append(out, p)
+	}
+	for _, p := range a.active {
+		add(*p)
+	}
+	for _, p := range a.recent {
+		add(p)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].started.After(out[j].started) })
+	return out
+}
+
+// algorithmName turns the algorithm identifier of a Security Mode Command
+// into its name: NEA/NIA for 5G, EEA/EIA for 4G. Identifier 0 is the null
+// algorithm (no ciphering / no integrity protection).
+func algorithmName(generation, kind, id string) string {
+	if id == "" {
+		return ""
+	}
+	n, err := strconv.ParseInt(id, 0, 0)
+	if err != nil {
+		return ""
+	}
+	prefix := map[string]string{
+		"5g/ciphering": "NEA", "5g/integrity": "NIA",
+		"4g/ciphering": "EEA", "4g/integrity": "EIA",
+	}[generation+"/"+kind]
+	if prefix == "" {
+		return ""
+	}
+	return prefix + strconv.FormatInt(n, 10)
+}
+
+func labelOr(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
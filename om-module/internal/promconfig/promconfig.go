@@ -0,0 +1,476 @@
+// Package promconfig renders the Prometheus configuration variants
+// (prometheus/configs/*.yml) with the external labels and remote storage
+// endpoints of a particular lab, and with scrape jobs for the monitoring
+// stack itself. The variants in the repository only carry
+// the monitor label; a bench that federates into a course-wide Prometheus,
+// or remote-writes to one, needs labels telling the benches apart and the
+// remote_write/remote_read blocks pointing at it. Prometheus reads the
+// rendered copies, so the variants themselves stay untouched.
+package promconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+)
+
+// Options are what a rendered variant adds to its base file.
+type Options struct {
+	// ExternalLabels are added to global.external_labels; a label the
+	// base file already has takes the value given here.
+	ExternalLabels map[string]string
+	// RemoteWrite and RemoteRead are appended to the remote_write and
+	// remote_read blocks of the base file.
+	RemoteWrite []yaml.MapSlice
+	RemoteRead  []yaml.MapSlice
+	// Stack are the monitoring stack components scraped for
+	// self-monitoring; a variant that already has a job of the same name
+	// keeps its own.
+	Stack []StackTarget
+	// OutOfOrderWindow, when set, is how far back Prometheus accepts
+	// samples older than the newest of their series, so that the metric
+	// buffer can backfill an outage. A window the base file sets is kept.
+	OutOfOrderWindow time.Duration
+	// RuleFiles are appended to the rule_files of the base file, relative
+	// to the rendered variant.
+	RuleFiles []string
+	// LabelLimits rewrite one label of a metric family in every scrape
+	// job, to keep the family within its cardinality budget.
+	LabelLimits []LabelLimit
+	// SeriesLabels are set on every series of every scrape job, such as
+	// the ID of the open lab session. Unlike ExternalLabels they are
+	// stored with the samples, so local queries see them.
+	SeriesLabels map[string]string
+}
+
+// LabelLimit drops Label from the series of Metric, or with Buckets set
+// replaces its values by one of Buckets hashes ("h0" to "h<Buckets-1>").
+type LabelLimit struct {
+	Metric  string `json:"metric_family"`
+	Label   string `json:"label"`
+	Buckets int    `json:"buckets,omitempty"`
+}
+
+// hashLabel holds the hash of a LabelLimit while the metric relabelling
+// runs; it is dropped at the end.
+const hashLabel = "__tmp_om_hash"
+
+// StackTarget is a monitoring stack component, found through Docker by
+// its om.nf label and scraped on the metrics port of its container.
+type StackTarget struct {
+	NF   string // om.nf label, also the job name
+	Port int
+}
+
+// MonitoringStack are the components of the monitoring stack that expose
+// their own metrics on /metrics.
+var MonitoringStack = []StackTarget{
+	{NF: "prometheus", Port: 9090},
+	{NF: "loki", Port: 3100},
+	{NF: "grafana", Port: 3000},
+}
+
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ParseLabels parses "name=value,name=value" into labels. Names follow the
+// Prometheus label syntax and may not start with "__".
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("%q: want name=value", pair)
+		}
+		if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("%q: invalid label name", name)
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// RemoteURLs returns one remote_write or remote_read entry per URL in the
+// comma-separated list s.
+func RemoteURLs(s string) []yaml.MapSlice {
+	var out []yaml.MapSlice
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			out = append(out, yaml.MapSlice{{Key: "url", Value: u}})
+		}
+	}
+	return out
+}
+
+// LoadRemote reads remote_write and remote_read blocks, in Prometheus
+// syntax, from the file at p:
+//
+//	remote_write:
+//	  - url: https://prometheus.example.org/api/v1/write
+//	    basic_auth: {username: lab1, password: secret}
+//	remote_read:
+//	  - url: https://prometheus.example.org/api/v1/read
+func LoadRemote(p string) (write, read []yaml.MapSlice, err error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	var f struct {
+		RemoteWrite []yaml.MapSlice `yaml:"remote_write"`
+		RemoteRead  []yaml.MapSlice `yaml:"remote_read"`
+	}
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", p, err)
+	}
+	for _, blocks := range [][]yaml.MapSlice{f.RemoteWrite, f.RemoteRead} {
+		for i, b := range blocks {
+			if u, _ := get(b, "url").(string); u == "" {
+				return nil, nil, fmt.Errorf("%s: remote endpoint %d has no url", p, i+1)
+			}
+		}
+	}
+	return f.RemoteWrite, f.RemoteRead, nil
+}
+
+// Render returns base, a Prometheus configuration, with o applied. The
+// order of the base file is kept; its comments are not.
+func Render(base []byte, o Options) ([]byte, error) {
+	var cfg yaml.MapSlice
+	if err := yaml.Unmarshal(base, &cfg); err != nil {
+		return nil, err
+	}
+
+	global, _ := get(cfg, "global").(yaml.MapSlice)
+	labels, _ := get(global, "external_labels").(yaml.MapSlice)
+	names := make([]string, 0, len(o.ExternalLabels))
+	for name := range o.ExternalLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		labels = set(labels, name, o.ExternalLabels[name])
+	}
+	if len(labels) > 0 {
+		global = set(global, "external_labels", labels)
+	}
+	if len(global) > 0 {
+		cfg = set(cfg, "global", global)
+	}
+
+	if len(o.Stack) > 0 {
+		jobs, _ := get(cfg, "scrape_configs").([]interface{})
+		for _, t := range o.Stack {
+			if !hasJob(jobs, t.NF) {
+				jobs = append(jobs, stackJob(t))
+			}
+		}
+		cfg = set(cfg, "scrape_configs", jobs)
+	}
+
+	if len(o.LabelLimits) > 0 {
+		jobs, _ := get(cfg, "scrape_configs").([]interface{})
+		rules := limitRules(o.LabelLimits)
+		for i, j := range jobs {
+			job, ok := j.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+			existing, _ := get(job, "metric_relabel_configs").([]interface{})
+			for _, r := range rules {
+				existing = append(existing, r)
+			}
+			jobs[i] = set(job, "metric_relabel_configs", existing)
+		}
+		cfg = set(cfg, "scrape_configs", jobs)
+	}
+
+	if len(o.SeriesLabels) > 0 {
+		names := sortedKeys(o.SeriesLabels)
+		jobs, _ := get(cfg, "scrape_configs").([]interface{})
+		for i, j := range jobs {
+			job, ok := j.(yaml.MapSlice)
+			if !ok {
+				continue
+			}
+			existing, _ := get(job, "relabel_configs").([]interface{})
+			for _, name := range names {
+				existing = append(existing, yaml.MapSlice{
+					{Key: "target_label", Value: name},
+					{Key: "replacement", Value: o.SeriesLabels[name]},
+				})
+			}
+			jobs[i] = set(job, "relabel_configs", existing)
+		}
+		cfg = set(cfg, "scrape_configs", jobs)
+	}
+
+	if o.OutOfOrderWindow > 0 {
+		storage, _ := get(cfg, "storage").(yaml.MapSlice)
+		tsdb, _ := get(storage, "tsdb").(yaml.MapSlice)
+		if get(tsdb, "out_of_order_time_window") == nil {
+			tsdb = set(tsdb, "out_of_order_time_window", model.Duration(o.OutOfOrderWindow).String())
+			cfg = set(cfg, "storage", set(storage, "tsdb", tsdb))
+		}
+	}
+
+	if len(o.RuleFiles) > 0 {
+		files, _ := get(cfg, "rule_files").([]interface{})
+		for _, f := range o.RuleFiles {
+			if !slices.Contains(files, interface{}(f)) {
+				files = append(files, f)
+			}
+		}
+		cfg = set(cfg, "rule_files", files)
+	}
+
+	for _, remote := range []struct {
+		key    string
+		blocks []yaml.MapSlice
+	}{{"remote_write", o.RemoteWrite}, {"remote_read", o.RemoteRead}} {
+		if len(remote.blocks) == 0 {
+			continue
+		}
+		existing, _ := get(cfg, remote.key).([]interface{})
+		for _, b := range remote.blocks {
+			existing = append(existing, b)
+		}
+		cfg = set(cfg, remote.key, existing)
+	}
+
+	return yaml.Marshal(cfg)
+}
+
+// Generate stages in tx every *.yml file in src rendered into dst.
+func Generate(tx *output.Txn, src, dst string, o Options) error {
+	variants, err := filepath.Glob(filepath.Join(src, "*.yml"))
+	if err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return fmt.Errorf("no *.yml variants in %s", src)
+	}
+	for _, v := range variants {
+		base, err := os.ReadFile(v)
+		if err != nil {
+			return err
+		}
+		out, err := Render(base, o)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v, err)
+		}
+		name := filepath.Base(v)
+		header := fmt.Sprintf("# Rendered by om-module from prometheus/configs/%s with\n"+
+			"# PROMETHEUS_EXTERNAL_LABELS, the remote endpoints and the monitoring stack\n"+
+			"# jobs; edit that file instead.\n", name)
+		if len(o.LabelLimits) > 0 {
+			header += fmt.Sprintf("# Labels limited by the cardinality budgets: %d (GET /api/metrics/cardinality).\n", len(o.LabelLimits))
+		}
+		for _, name := range sortedKeys(o.SeriesLabels) {
+			header += fmt.Sprintf("# Every series labelled %s=%q.\n", name, o.SeriesLabels[name])
+		}
+		tx.WriteFile(filepath.Join(dst, name), append([]byte(header), out...), 0o644)
+	}
+	return nil
+}
+
+// Inputs returns what the variants in src are rendered from with o, for
+// the regen.Job Inputs.
+func Inputs(src string, o Options) ([]byte, error) {
+	variants, err := filepath.Glob(filepath.Join(src, "*.yml"))
+	if err != nil {
+		return nil, err
+	}
+	opts, err := yaml.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	out := opts
+	for _, v := range variants {
+		base, err := os.ReadFile(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(append(out, filepath.Base(v)...), '\n'), base...)
+	}
+	return out, nil
+}
+
+// Validate checks the variants staged in tx before Prometheus reloads
+// them: each must parse, scrape something, and name every job once.
+func Validate(tx *output.Txn) error {
+	for _, path := range tx.Files() {
+		data, _ := tx.Data(path)
+		var cfg struct {
+			ScrapeConfigs []struct {
+				JobName string `yaml:"job_name"`
+			} `yaml:"scrape_configs"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		if len(cfg.ScrapeConfigs) == 0 {
+			return fmt.Errorf("%s: no scrape_configs", filepath.Base(path))
+		}
+		jobs := make(map[string]bool, len(cfg.ScrapeConfigs))
+		for _, j := range cfg.ScrapeConfigs {
+			if j.JobName == "" {
+				return fmt.Errorf("%s: scrape config without job_name", filepath.Base(path))
+			}
+			if jobs[j.JobName] {
+				return fmt.Errorf("%s: job %q defined twice", filepath.Base(path), j.JobName)
+			}
+			jobs[j.JobName] = true
+		}
+	}
+	return nil
+}
+
+var reloadClient = httpclient.New("promconfig", 0)
+
+// Reload asks the Prometheus at baseURL to re-read its configuration
+// (POST /-/reload, served with --web.enable-lifecycle). A Prometheus that
+// is not running is not an error: it reads the files when it starts. A
+// configuration it rejects is.
+func Reload(ctx context.Context, baseURL string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/-/reload", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := reloadClient.Do(req)
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "dial" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("prometheus reload: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// stackJob scrapes t on the address Docker reports for its container and
+// metrics port, so the job follows the container when its IP changes.
+func stackJob(t StackTarget) yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "job_name", Value: t.NF},
+		{Key: "docker_sd_configs", Value: []yaml.MapSlice{{
+			{Key: "host", Value: "unix:///var/run/docker.sock"},
+			{Key: "refresh_interval", Value: "15s"},
+		}}},
+		{Key: "relabel_configs", Value: []yaml.MapSlice{
+			{
+				{Key: "source_labels", Value: []string{"__meta_docker_container_label_om_nf", "__meta_docker_port_private"}},
+				{Key: "regex", Value: fmt.Sprintf("%s;%d", t.NF, t.Port)},
+				{Key: "action", Value: "keep"},
+			},
+			{
+				{Key: "source_labels", Value: []string{"__meta_docker_container_name"}},
+				{Key: "target_label", Value: "container"},
+				{Key: "regex", Value: "/(.*)"},
+			},
+		}},
+	}
+}
+
+// limitRules returns the metric_relabel_configs applying limits. A dropped
+// label is replaced by the empty value, which removes it, only on the
+// series of its family; a hashed one goes through hashLabel first.
+func limitRules(limits []LabelLimit) []yaml.MapSlice {
+	var rules []yaml.MapSlice
+	hashed := false
+	for _, l := range limits {
+		metric := regexp.QuoteMeta(l.Metric)
+		if l.Buckets <= 0 {
+			rules = append(rules, yaml.MapSlice{
+				{Key: "source_labels", Value: []string{"__name__"}},
+				{Key: "regex", Value: metric},
+				{Key: "target_label", Value: l.Label},
+				{Key: "replacement", Value: ""},
+			})
+			continue
+		}
+		hashed = true
+		rules = append(rules,
+			yaml.MapSlice{
+				{Key: "source_labels", Value: []string{l.Label}},
+				{Key: "target_label", Value: hashLabel},
+				{Key: "modulus", Value: l.Buckets},
+				{Key: "action", Value: "hashmod"},
+			},
+			yaml.MapSlice{
+				{Key: "source_labels", Value: []string{"__name__", l.Label, hashLabel}},
+				{Key: "regex", Value: metric + ";.+;(.*)"},
+				{Key: "target_label", Value: l.Label},
+				{Key: "replacement", Value: "h${1}"},
+			})
+	}
+	if hashed {
+		rules = append(rules, yaml.MapSlice{
+			{Key: "regex", Value: hashLabel},
+			{Key: "action", Value: "labeldrop"},
+		})
+	}
+	return rules
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hasJob(jobs []interface{}, name string) bool {
+	for _, j := range jobs {
+		if m, ok := j.(yaml.MapSlice); ok && get(m, "job_name") == name {
+			return true
+		}
+	}
+	return false
+}
+
+func get(m yaml.MapSlice, key string) interface{} {
+	for _, it := range m {
+		if it.Key == key {
+			return it.Value
+		}
+	}
+	return nil
+}
+
+// set replaces the value of key in m, or appends it.
+func set(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, it := range m {
+		if it.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
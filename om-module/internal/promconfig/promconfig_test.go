@@ -0,0 +1,141 @@
+package promconfig
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v2"
+)
+
+func TestLimitRules(t *testing.T) {
+	limits := []LabelLimit{
+		{Metric: "fivegs_amffunction_rm_reginitreq", Label: "imsi"},
+		{Metric: "ues_active", Label: "rnti", Buckets: 4},
+	}
+	tests := []struct {
+		name   string
+		series map[string]string
+		check  func(t *testing.T, got map[string]string)
+	}{
+		{
+			name:   "dropped label",
+			series: map[string]string{"__name__": "fivegs_amffunction_rm_reginitreq", "imsi": "001011234567895", "job": "amf"},
+			check: func(t *testing.T, got map[string]string) {
+				if _, ok := got["imsi"]; ok || got["job"] != "amf" {
+					t.Errorf("got %v, want imsi dropped and job kept", got)
+				}
+			},
+		},
+		{
+			name:   "hashed label",
+			series: map[string]string{"__name__": "ues_active", "rnti": "17921", "job": "gnb"},
+			check: func(t *testing.T, got map[string]string) {
+				if want := fmt.Sprintf("h%d", hashmod("17921", 4)); got["rnti"] != want {
+					t.Errorf("rnti = %q, want %q", got["rnti"], want)
+				}
+			},
+		},
+		{
+			name:   "hashed label missing",
+			series: map[string]string{"__name__": "ues_active", "job": "gnb"},
+			check: func(t *testing.T, got map[string]string) {
+				if _, ok := got["rnti"]; ok {
+					t.Errorf("got %v, want no rnti", got)
+				}
+			},
+		},
+		{
+			name:   "other family",
+			series: map[string]string{"__name__": "ues_active_total", "rnti": "17921", "imsi": "001011234567895"},
+			check: func(t *testing.T, got map[string]string) {
+				if got["rnti"] != "17921" || got["imsi"] != "001011234567895" {
+					t.Errorf("got %v, want the labels untouched", got)
+				}
+			},
+		},
+	}
+	rules := limitRules(limits)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := relabel(t, maps.Clone(tt.series), rules)
+			if _, ok := got[hashLabel]; ok {
+				t.Errorf("%s left on the series", hashLabel)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+// The buckets of a hashed label stay within h0 to h<Buckets-1>.
+func TestLimitRulesBuckets(t *testing.T) {
+	rules := limitRules([]LabelLimit{{Metric: "ues_active", Label: "rnti", Buckets: 3}})
+	seen := make(map[string]bool)
+	for i := range 100 {
+		got := relabel(t, map[string]string{"__name__": "ues_active", "rnti": strconv.Itoa(i)}, rules)
+		seen[got["rnti"]] = true
+	}
+	if len(seen) != 3 || !seen["h0"] || !seen["h1"] || !seen["h2"] {
+		t.Errorf("buckets %v, want h0, h1 and h2", seen)
+	}
+}
+
+// relabel applies the replace, hashmod and labeldrop rules the way
+// Prometheus' metric relabelling does.
+func relabel(t *testing.T, lbls map[string]string, rules []yaml.MapSlice) map[string]string {
+	t.Helper()
+	for _, rule := range rules {
+		r := make(map[string]interface{})
+		for _, item := range rule {
+			r[item.Key.(string)] = item.Value
+		}
+		action, _ := r["action"].(string)
+		expr, _ := r["regex"].(string)
+		if expr == "" {
+			expr = "(.*)"
+		}
+		re := regexp.MustCompile("^(?:" + expr + ")$")
+		var vals []string
+		if src, ok := r["source_labels"].([]string); ok {
+			for _, s := range src {
+				vals = append(vals, lbls[s])
+			}
+		}
+		val := strings.Join(vals, ";")
+		target, _ := r["target_label"].(string)
+		switch action {
+		case "", "replace":
+			m := re.FindStringSubmatchIndex(val)
+			if m == nil {
+				continue
+			}
+			repl, _ := r["replacement"].(string)
+			if v := string(re.ExpandString(nil, repl, val, m)); v == "" {
+				delete(lbls, target)
+			} else {
+				lbls[target] = v
+			}
+		case "hashmod":
+			lbls[target] = strconv.FormatUint(hashmod(val, uint64(r["modulus"].(int))), 10)
+		case "labeldrop":
+			for k := range lbls {
+				if re.MatchString(k) {
+					delete(lbls, k)
+				}
+			}
+		default:
+			t.Fatalf("unexpected relabel action %q", action)
+		}
+	}
+	return lbls
+}
+
+func hashmod(val string, modulus uint64) uint64 {
+	sum := md5.Sum([]byte(val))
+	return binary.BigEndian.Uint64(sum[8:]) % modulus
+}
@@ -0,0 +1,384 @@
+// Package promrules writes the recording and alerting rules the rendered
+// Prometheus configurations load (rule_files: rules/*.yml). The starter set
+// records per-NF KPIs of the Open5GS cores — registration and PDU session
+// success ratios, connected gNBs/eNBs, sessions, N3 traffic — and alerts on
+// components that are down or failing. It is kept in sync with the metrics
+// Prometheus has seen: a rule is only written while every metric it reads
+// has series, so a 4G lab gets no 5G rules and the set follows the lab as
+// NFs are started and stopped. Validate checks the staged rules before
+// Prometheus reloads them, and Prometheus rejecting them rolls them back.
+package promrules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+)
+
+// Dir is the directory of the rules next to the rendered configurations,
+// and Glob what the configurations load from it.
+const (
+	Dir  = "rules"
+	Glob = Dir + "/*.yml"
+)
+
+// File is the file the starter rules are written to.
+const File = "om-module.yml"
+
+// Group is a Prometheus rule group.
+type Group struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule is a recording rule (Record) or an alerting rule (Alert).
+type Rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// metrics are the scraped metrics Expr reads.
+	metrics []string
+}
+
+// Name is the record or alert name of r.
+func (r Rule) Name() string {
+	if r.Record != "" {
+		return r.Record
+	}
+	return r.Alert
+}
+
+func record(name, expr string, metrics ...string) Rule {
+	return Rule{Record: name, Expr: expr, metrics: metrics}
+}
+
+func alert(name, expr, forDuration, severity, summary string, metrics ...string) Rule {
+	return Rule{
+		Alert:       name,
+		Expr:        expr,
+		For:         forDuration,
+		Labels:      map[string]string{"severity": severity},
+		Annotations: map[string]string{"summary": summary},
+		metrics:     metrics,
+	}
+}
+
+// ratio records the 5-minute success ratio of a request/success counter
+// pair of an NF, per container.
+func ratio(name, success, requests string) Rule {
+	return record(name,
+		fmt.Sprintf("sum by (container) (rate(%s[5m])) / sum by (container) (rate(%s[5m]))", success, requests),
+		success, requests)
+}
+
+// total records the sum of a gauge per container.
+func total(name, gauge string) Rule {
+	return record(name, fmt.Sprintf("sum by (container) (%s)", gauge), gauge)
+}
+
+// Starter is the full starter set, before it is filtered by the metrics
+// Prometheus has seen.
+var Starter = []Group{
+	{Name: "om-5g-kpis", Rules: []Rule{
+		ratio("container:amf_registration_success_ratio:rate5m", "fivegs_amffunction_rm_reginitsucc", "fivegs_amffunction_rm_reginitreq"),
+		ratio("container:amf_mobility_registration_success_ratio:rate5m", "fivegs_amffunction_rm_regmobsucc", "fivegs_amffunction_rm_regmobreq"),
+		ratio("container:amf_paging_success_ratio:rate5m", "fivegs_amffunction_mm_paging5gsucc", "fivegs_amffunction_mm_paging5greq"),
+		ratio("container:amf_auth_reject_ratio:rate5m", "fivegs_amffunction_amf_authreject", "fivegs_amffunction_amf_authreq"),
+		total("container:amf_registered_subscribers:sum", "fivegs_amffunction_rm_registeredsubnbr"),
+		total("container:amf_gnbs:sum", "gnb"),
+		total("container:amf_ran_ues:sum", "ran_ue"),
+		ratio("container:smf_pdu_session_success_ratio:rate5m", "fivegs_smffunction_sm_pdusessioncreationsucc", "fivegs_smffunction_sm_pdusessioncreationreq"),
+		total("container:smf_pdu_sessions:sum", "fivegs_smffunction_sm_sessionnbr"),
+		ratio("container:pcf_am_policy_success_ratio:rate5m", "fivegs_pcffunction_pa_policyamassosucc", "fivegs_pcffunction_pa_policyamassoreq"),
+		ratio("container:pcf_sm_policy_success_ratio:rate5m", "fivegs_pcffunction_pa_policysmassosucc", "fivegs_pcffunction_pa_policysmassoreq"),
+		total("container:upf_sessions:sum", "fivegs_upffunction_upf_sessionnbr"),
+		total("container:upf_qos_flows:sum", "fivegs_upffunction_upf_qosflows"),
+		record("container:upf_n3_in_packets:rate5m", "sum by (container) (rate(fivegs_ep_n3_gtp_indatapktn3upf[5m]))", "fivegs_ep_n3_gtp_indatapktn3upf"),
+		record("container:upf_n3_out_packets:rate5m", "sum by (container) (rate(fivegs_ep_n3_gtp_outdatapktn3upf[5m]))", "fivegs_ep_n3_gtp_outdatapktn3upf"),
+	}},
+	{Name: "om-4g-kpis", Rules: []Rule{
+		total("container:mme_enbs:sum", "enb"),
+		total("container:mme_ues:sum", "enb_ue"),
+		total("container:mme_sessions:sum", "mme_session"),
+		record("container:pcrf_gx_ccr_error_ratio:rate5m",
+			"sum by (container) (rate(gx_rx_ccr_error[5m])) / sum by (container) (rate(gx_rx_ccr[5m]))",
+			"gx_rx_ccr_error", "gx_rx_ccr"),
+		total("container:smf_gtp2_sessions:sum", "gtp2_sessions_active"),
+		total("container:smf_pfcp_sessions:sum", "pfcp_sessions_active"),
+	}},
+	{Name: "om-alerts", Rules: []Rule{
+		alert("OMScrapeTargetDown", `up == 0`, "1m", "warning",
+			"Prometheus cannot scrape {{ $labels.job }} {{ $labels.instance }}.", "up"),
+		alert("OMComponentDown", `om_health_status == 0`, "2m", "critical",
+			"{{ $labels.container }} is down (GET /api/health on the O&M module tells why).", "om_health_status"),
+		alert("OMTestbedDegraded", `om_health_overall < 1`, "5m", "warning",
+			"The testbed has been degraded for 5 minutes.", "om_health_overall"),
+		alert("NFRestarted", `changes(process_start_time_seconds[10m]) > 0`, "", "info",
+			"{{ $labels.container }} restarted in the last 10 minutes.", "process_start_time_seconds"),
+		alert("AMFNoGNB", `sum by (container) (gnb) == 0`, "2m", "warning",
+			"No gNB is connected to {{ $labels.container }} (NG Setup not done).", "gnb"),
+		alert("AMFRegistrationFailures",
+			`container:amf_registration_success_ratio:rate5m < 0.9 and sum by (container) (rate(fivegs_amffunction_rm_reginitreq[5m])) > 0`, "5m", "warning",
+			"Less than 90% of the initial registrations at {{ $labels.container }} succeed.",
+			"fivegs_amffunction_rm_reginitsucc", "fivegs_amffunction_rm_reginitreq"),
+		alert("SMFPDUSessionFailures",
+			`container:smf_pdu_session_success_ratio:rate5m < 0.9 and sum by (container) (rate(fivegs_smffunction_sm_pdusessioncreationreq[5m])) > 0`, "5m", "warning",
+			"Less than 90% of the PDU session establishments at {{ $labels.container }} succeed.",
+			"fivegs_smffunction_sm_pdusessioncreationsucc", "fivegs_smffunction_sm_pdusessioncreationreq"),
+		alert("MMENoENB", `sum by (container) (enb) == 0`, "2m", "warning",
+			"No eNB is connected to {{ $labels.container }} (S1 Setup not done).", "enb"),
+		alert("PCRFGxErrors", `container:pcrf_gx_ccr_error_ratio:rate5m > 0.1`, "5m", "warning",
+			"More than 10% of the Gx Credit-Control Requests at {{ $labels.container }} fail.",
+			"gx_rx_ccr_error", "gx_rx_ccr"),
+	}},
+}
+
+// Select returns the groups of starter with the rules whose metrics all
+// have series in seen, and the names of those left out. A nil seen (the
+// metrics could not be read) keeps every rule. Groups left without rules
+// are dropped.
+func Select(starter []Group, seen map[string]bool) (kept []Group, omitted []string) {
+	for _, g := range starter {
+		out := Group{Name: g.Name}
+		for _, r := range g.Rules {
+			if missing(r, seen) {
+				omitted = append(omitted, r.Name())
+				continue
+			}
+			out.Rules = append(out.Rules, r)
+		}
+		if len(out.Rules) > 0 {
+			kept = append(kept, out)
+		}
+	}
+	return kept, omitted
+}
+
+func missing(r Rule, seen map[string]bool) bool {
+	if seen == nil {
+		return false
+	}
+	for _, m := range r.metrics {
+		if !seen[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// Generator writes the starter rules selected by the metrics of the
+// Prometheus at URL.
+type Generator struct {
+	url     string
+	timeout time.Duration
+	dir     string
+	client  *http.Client
+}
+
+// New returns a generator writing into dir, the directory of the rendered
+// configurations. An empty prometheusURL writes every starter rule.
+func New(prometheusURL string, timeout time.Duration, dir string) *Generator {
+	return &Generator{
+		url:     strings.TrimRight(prometheusURL, "/"),
+		timeout: timeout,
+		dir:     dir,
+		client:  httpclient.New("promrules", 0),
+	}
+}
+
+// Inputs returns the starter metrics that have series, for the regen.Job
+// Inputs: the rules are only rewritten when that set changes.
+func (g *Generator) Inputs() ([]byte, error) {
+	seen, err := g.seen(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var present []string
+	for _, group := range Starter {
+		for _, r := range group.Rules {
+			for _, m := range r.metrics {
+				if seen == nil || seen[m] {
+					present = append(present, m)
+				}
+			}
+		}
+	}
+	sort.Strings(present)
+	return []byte(strings.Join(slices.Compact(present), "\n")), nil
+}
+
+// Generate stages the rules file in tx. When Prometheus cannot be asked
+// for its metrics every starter rule is written; the next run after it
+// answers trims them.
+func (g *Generator) Generate(ctx context.Context, tx *output.Txn) error {
+	seen, err := g.seen(ctx)
+	if err != nil {
+		seen = nil
+	}
+	groups, omitted := Select(Starter, seen)
+	body, err := yaml.Marshal(struct {
+		Groups []Group `yaml:"groups"`
+	}{groups})
+	if err != nil {
+		return err
+	}
+
+	header := "# Starter recording and alerting rules generated by om-module, loaded by\n" +
+		"# every rendered Prometheus configuration (rule_files: " + Glob + ").\n" +
+		"# Rewritten when the metrics Prometheus scrapes change; edit\n" +
+		"# internal/promrules instead.\n"
+	if len(omitted) > 0 {
+		header += "#\n# Left out while a metric they read has no series:\n"
+		for _, name := range omitted {
+			header += "#   " + name + "\n"
+		}
+	}
+	tx.WriteFile(filepath.Join(g.dir, Dir, File), append([]byte(header), body...), 0o644)
+	return nil
+}
+
+// seen returns the metric names Prometheus has series for, or nil without
+// a Prometheus URL.
+func (g *Generator) seen(ctx context.Context) (map[string]bool, error) {
+	if g.url == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.url+"/api/v1/label/__name__/values", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus metric names: unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(body.Data))
+	for _, name := range body.Data {
+		out[name] = true
+	}
+	return out, nil
+}
+
+var (
+	metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Validate checks the rule files staged in tx the way Prometheus loads
+// them: group names are unique, every rule is either a recording rule
+// with a valid metric name or an alerting rule, expressions are present
+// and balanced, and durations and label names parse.
+func Validate(tx *output.Txn) error {
+	for _, path := range tx.Files() {
+		data, _ := tx.Data(path)
+		name := filepath.Base(path)
+		var f struct {
+			Groups []Group `yaml:"groups"`
+		}
+		if err := yaml.UnmarshalStrict(data, &f); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		groups := make(map[string]bool, len(f.Groups))
+		for _, g := range f.Groups {
+			if g.Name == "" {
+				return fmt.Errorf("%s: group without name", name)
+			}
+			if groups[g.Name] {
+				return fmt.Errorf("%s: group %q defined twice", name, g.Name)
+			}
+			groups[g.Name] = true
+			for i, r := range g.Rules {
+				if err := validateRule(r); err != nil {
+					return fmt.Errorf("%s: group %q rule %d: %w", name, g.Name, i+1, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateRule(r Rule) error {
+	switch {
+	case (r.Record == "") == (r.Alert == ""):
+		return fmt.Errorf("want exactly one of record and alert")
+	case r.Record != "" && !metricName.MatchString(r.Record):
+		return fmt.Errorf("record %q: invalid metric name", r.Record)
+	case r.Record != "" && (r.For != "" || len(r.Annotations) > 0):
+		return fmt.Errorf("record %q: for and annotations only apply to alerts", r.Record)
+	case strings.TrimSpace(r.Expr) == "":
+		return fmt.Errorf("%s: empty expr", r.Name())
+	}
+	if err := balanced(r.Expr); err != nil {
+		return fmt.Errorf("%s: %w", r.Name(), err)
+	}
+	if r.For != "" {
+		if _, err := model.ParseDuration(r.For); err != nil {
+			return fmt.Errorf("%s: for: %w", r.Name(), err)
+		}
+	}
+	for l := range r.Labels {
+		if !labelName.MatchString(l) || strings.HasPrefix(l, "__") {
+			return fmt.Errorf("%s: invalid label name %q", r.Name(), l)
+		}
+	}
+	return nil
+}
+
+// balanced reports unbalanced brackets outside the strings of expr, the
+// mistake a hand-edited expression most often has.
+func balanced(expr string) error {
+	var stack []rune
+	var quote rune
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	for i, c := range expr {
+		switch {
+		case quote != 0:
+			if c == quote && (i == 0 || expr[i-1] != '\\') {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			stack = append(stack, c)
+		case pairs[c] != 0:
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[c] {
+				return fmt.Errorf("unbalanced %q at %d", c, i)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("unterminated string")
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("unclosed %q", stack[len(stack)-1])
+	}
+	return nil
+}
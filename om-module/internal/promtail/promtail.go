@@ -0,0 +1,682 @@
+// Package promtail manages the Promtail containers that ship the lab's logs
+// to Loki (om.nf "promtail": promtail-core, and any other Promtail a lab
+// adds, e.g. next to the RAN). Promtail reads its configuration only at
+// start, so when the configuration files change the Manager restarts the
+// containers through the Docker API and waits until each one answers
+// /ready again. Between restarts it checks every container each interval.
+// The pipeline can also be stopped, started, restarted and reloaded on
+// request, as many times as needed.
+package promtail
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const networkName = "docker_open5gs_default"
+
+// NFPromtail is the om.nf label of Promtail containers.
+const NFPromtail = "promtail"
+
+// HTTPPort is the server.http_listen_port of the Promtail configurations.
+const HTTPPort = 9080
+
+// stopTimeout is how long a restart lets Promtail flush its batches and
+// positions before Docker kills it.
+const stopTimeout = 10 * time.Second
+
+// Reasons of a restart.
+const (
+	ReasonConfig = "config"
+	ReasonManual = "manual"
+)
+
+// Actions that can be requested of the manager.
+const (
+	ActionStart   = "start"
+	ActionStop    = "stop"
+	ActionRestart = "restart"
+	ActionReload  = "reload" // re-read the configuration, restart if it changed
+)
+
+// Results of a restart.
+const (
+	ResultReady    = "ready"
+	ResultNotReady = "not_ready" // restarted, but /ready did not answer in time
+	ResultFailed   = "failed"    // Docker could not restart the container
+)
+
+// Restart is the outcome of one restart of one container.
+type Restart struct {
+	Time            string  `json:"time"`
+	Reason          string  `json:"reason"`
+	Result          string  `json:"result"`
+	DurationSeconds float64 `json:"duration_seconds"` // until ready, or until given up
+	Error           string  `json:"error,omitempty"`
+}
+
+// Agent is the last check of one Promtail container.
+type Agent struct {
+	Container string `json:"container"`
+	State     string `json:"state"`
+	// Health is the Docker healthcheck status; empty without one.
+	Health     string `json:"health,omitempty"`
+	Ready      bool   `json:"ready"`
+	ReadyError string `json:"ready_error,omitempty"`
+	StartedAt  string `json:"started_at,omitempty"`
+	// DockerRestarts counts the restarts done by the restart policy;
+	// Restarts those done by the module.
+	DockerRestarts int      `json:"docker_restarts"`
+	Restarts       uint64   `json:"restarts"`
+	LastRestart    *Restart `json:"last_restart,omitempty"`
+	CheckedAt      string   `json:"checked_at"`
+}
+
+// Status is the API view of the manager.
+type Status struct {
+	ConfigDir string `json:"config_dir,omitempty"`
+	// ConfigHash is the hash of the configuration files the containers
+	// were last (re)started with, as far as the module knows.
+	ConfigHash      string  `json:"config_hash,omitempty"`
+	ConfigChangedAt string  `json:"config_changed_at,omitempty"`
+	Interval        string  `json:"interval"`
+	UpdatedAt       string  `json:"updated_at,omitempty"`
+	Restarting      bool    `json:"restarting"`
+	Error           string  `json:"error,omitempty"`
+	Agents          []Agent `json:"agents"`
+	// Action is the requested action queued or running, if any.
+	Action string `json:"action,omitempty"`
+	// Stopped is set while the containers are stopped on request:
+	// configuration changes are then applied by the next start.
+	Stopped bool `json:"stopped"`
+}
+
+// Options configure the manager.
+type Options struct {
+	// ConfigDir holds the Promtail configurations, mounted read-only in
+	// the module. Empty disables the restart on configuration changes.
+	ConfigDir string
+	Interval  time.Duration
+	// ReadyTimeout is how long a restarted Promtail has to answer /ready.
+	ReadyTimeout time.Duration
+}
+
+// Manager checks the Promtail containers and restarts them after their
+// configuration changes, or starts, stops, restarts and reloads them when
+// asked to.
+type Manager struct {
+	docker *dockerclient.Client
+	snap   *collector.Snapshot
+	opts   Options
+	http   *http.Client
+
+	up       *prometheus.GaugeVec
+	restarts *prometheus.CounterVec
+	duration *prometheus.GaugeVec
+
+	trigger chan string
+
+	mu         sync.RWMutex
+	agents     map[string]Agent // keyed by container name
+	configHash string
+	configAt   time.Time
+	restarting bool
+	pending    string // requested action, until it is done
+	stopped    bool
+	checked    time.Time
+	checkErr   string // last error listing the container addresses
+	configErr  string // last error reading the configuration
+}
+
+// New registers the om_promtail_* metrics on reg.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, opts Options) *Manager {
+	m := &Manager{
+		docker: docker,
+		snap:   snap,
+		opts:   opts,
+		http:   httpclient.New("promtail", 2*time.Second),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "promtail", Name: "up",
+			Help: "1 if the Promtail container is running and answers /ready, 0 otherwise.",
+		}, []string{"container"}),
+		restarts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "promtail", Name: "restarts_total",
+			Help: "Restarts of Promtail containers by the module, by reason (config|manual) and result (ready|not_ready|failed).",
+		}, []string{"container", "reason", "result"}),
+		duration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "promtail", Name: "restart_duration_seconds",
+			Help: "Time the last restart of the Promtail container took until it answered /ready.",
+		}, []string{"container"}),
+		trigger: make(chan string, 1),
+		agents:  make(map[string]Agent),
+	}
+	reg.MustRegister(m.up, m.restarts, m.duration)
+	return m
+}
+
+// Run checks the containers and the configuration every interval, restarts
+// the containers when the configuration changed, and carries out the
+// requested actions, until ctx is cancelled. The configuration found at start is
+// the one the containers already run with.
+func (m *Manager) Run(ctx context.Context) {
+	if m.opts.ConfigDir != "" {
+		hash, err := HashConfig(m.opts.ConfigDir)
+		m.setError(&m.configErr, err)
+		if err == nil {
+			m.mu.Lock()
+			m.configHash = hash
+			m.mu.Unlock()
+		}
+	}
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+	for {
+		m.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if hash, changed := m.configChanged(); changed {
+				m.restartAll(ctx, ReasonConfig, hash)
+			}
+		case action := <-m.trigger:
+			m.do(ctx, action)
+		}
+	}
+}
+
+// Trigger asks for a restart of every Promtail container. It returns false
+// when another action is in progress or pending.
+func (m *Manager) Trigger() bool {
+	return m.request(ActionRestart)
+}
+
+// Start asks for the stopped Promtail containers to be started with the
+// current configuration. It returns false when another action is in
+// progress or pending.
+func (m *Manager) Start() bool {
+	return m.request(ActionStart)
+}
+
+// Stop asks for every Promtail container to be stopped; they stay stopped,
+// whatever the configuration does, until Start or Trigger. It returns
+// false when another action is in progress or pending.
+func (m *Manager) Stop() bool {
+	return m.request(ActionStop)
+}
+
+// Reload asks for the configuration to be read now instead of at the next
+// interval, restarting the containers if it changed. It returns false when
+// another action is in progress or pending.
+func (m *Manager) Reload() bool {
+	return m.request(ActionReload)
+}
+
+func (m *Manager) request(action string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending != "" || m.restarting {
+		return false
+	}
+	select {
+	case m.trigger <- action:
+		m.pending = action
+		return true
+	default:
+		return false
+	}
+}
+
+// do carries out one requested action.
+func (m *Manager) do(ctx context.Context, action string) {
+	defer func() {
+		m.mu.Lock()
+		m.pending = ""
+		m.mu.Unlock()
+	}()
+	switch action {
+	case ActionStart:
+		m.startAll(ctx)
+	case ActionStop:
+		m.stopAll(ctx)
+	case ActionRestart:
+		m.restartAll(ctx, ReasonManual, "")
+	case ActionReload:
+		m.reload(ctx)
+	}
+}
+
+// Status returns the last check of every Promtail container, sorted by
+// container name.
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	st := Status{
+		ConfigDir:  m.opts.ConfigDir,
+		ConfigHash: m.configHash,
+		Interval:   m.opts.Interval.String(),
+		Restarting: m.restarting,
+		Action:     m.pending,
+		Stopped:    m.stopped,
+		Error:      m.configErr,
+		Agents:     make([]Agent, 0, len(m.agents)),
+	}
+	if st.Error == "" {
+		st.Error = m.checkErr
+	}
+	if !m.configAt.IsZero() {
+		st.ConfigChangedAt = m.configAt.UTC().Format(time.RFC3339)
+	}
+	if !m.checked.IsZero() {
+		st.UpdatedAt = m.checked.UTC().Format(time.RFC3339)
+	}
+	for _, a := range m.agents {
+		if a.LastRestart != nil {
+			r := *a.LastRestart
+			a.LastRestart = &r
+		}
+		st.Agents = append(st.Agents, a)
+	}
+	sort.Slice(st.Agents, func(i, j int) bool { return st.Agents[i].Container < st.Agents[j].Container })
+	return st
+}
+
+// Freshness returns when om_promtail_up was last refreshed, for
+// exporter.Ages. Without Promtail containers it returns nil.
+func (m *Manager) Freshness() map[string]time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.agents) == 0 || m.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_promtail_up": m.checked}
+}
+
+// HashConfig hashes the names and contents of the files under dir. Hidden
+// files (editor swap and backup files) are left out.
+func HashConfig(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(h, "%s\x00%d\x00", rel, len(data))
+		h.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash promtail config: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// configChanged reports whether the configuration differs from the one the
+// containers were last started with, and its hash.
+func (m *Manager) configChanged() (string, bool) {
+	if m.opts.ConfigDir == "" {
+		return "", false
+	}
+	hash, err := HashConfig(m.opts.ConfigDir)
+	m.setError(&m.configErr, err)
+	if err != nil {
+		return "", false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.configHash == "" {
+		return hash, false
+	}
+	return hash, hash != m.configHash && !m.restarting && !m.stopped
+}
+
+// targetNames returns the names of the Promtail containers, sorted.
+func (m *Manager) targetNames() []string {
+	targets := m.targets()
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// targets returns the Promtail containers of the snapshot.
+func (m *Manager) targets() map[string]*collector.ContainerData {
+	out := make(map[string]*collector.ContainerData)
+	for name, cd := range m.snap.All() {
+		if cd.NF == NFPromtail {
+			out[name] = cd
+		}
+	}
+	return out
+}
+
+// check inspects every Promtail container and asks running ones /ready.
+func (m *Manager) check(ctx context.Context) {
+	targets := m.targets()
+
+	m.mu.Lock()
+	for name := range m.agents {
+		if targets[name] == nil {
+			delete(m.agents, name)
+			m.up.DeleteLabelValues(name)
+			m.duration.DeleteLabelValues(name)
+		}
+	}
+	m.mu.Unlock()
+
+	var ips map[string]string
+	if len(targets) > 0 {
+		var err error
+		ips, err = m.containerIPs(ctx)
+		m.setError(&m.checkErr, err)
+	}
+	for name := range targets {
+		m.record(m.inspect(ctx, name, ips))
+	}
+
+	m.mu.Lock()
+	m.checked = time.Now()
+	m.mu.Unlock()
+}
+
+// inspect returns the current state of one container, keeping the restart
+// history of the previous check.
+func (m *Manager) inspect(ctx context.Context, name string, ips map[string]string) Agent {
+	m.mu.RLock()
+	a := m.agents[name]
+	m.mu.RUnlock()
+	a.Container = name
+	a.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+	a.Ready, a.ReadyError = false, ""
+
+	st, err := m.docker.Inspect(ctx, name)
+	if err != nil {
+		a.State = "unknown"
+		a.ReadyError = err.Error()
+		return a
+	}
+	a.State = st.Status
+	a.Health = st.Health
+	a.DockerRestarts = st.RestartCount
+	a.StartedAt = ""
+	if !st.StartedAt.IsZero() {
+		a.StartedAt = st.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if st.Status != "running" {
+		a.ReadyError = "container " + st.Status
+		return a
+	}
+	ip, ok := ips[name]
+	if !ok {
+		a.ReadyError = "no IP on " + networkName
+		return a
+	}
+	if err := m.ready(ctx, ip); err != nil {
+		a.ReadyError = err.Error()
+		return a
+	}
+	a.Ready = true
+	return a
+}
+
+// ready asks Promtail whether its targets are set up and it is shipping.
+func (m *Manager) ready(ctx context.Context, ip string) error {
+	url := "http://" + net.JoinHostPort(ip, strconv.Itoa(HTTPPort)) + "/ready"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/ready: %s", resp.Status)
+	}
+	return nil
+}
+
+func (m *Manager) containerIPs(ctx context.Context) (map[string]string, error) {
+	ipToName, err := m.docker.GetNetworkContainerIPs(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(ipToName))
+	for ip, name := range ipToName {
+		out[name] = ip
+	}
+	return out, nil
+}
+
+// restartAll restarts every Promtail container one after the other, so
+// that logs keep flowing through the others, and waits for each to answer
+// /ready. hash is the configuration being applied, empty for a manual
+// restart.
+func (m *Manager) restartAll(ctx context.Context, reason, hash string) {
+	m.mu.Lock()
+	m.restarting = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.restarting = false
+		m.mu.Unlock()
+	}()
+
+	// Stopped containers are started too: the restart is what tells
+	// whether they come back with the configuration.
+	m.mu.Lock()
+	m.stopped = false
+	m.mu.Unlock()
+	for _, name := range m.targetNames() {
+		r := m.restart(ctx, name, reason)
+		if r.Result != ResultReady {
+			log.Printf("⚠️  Promtail: restart of %s (%s) ended %s: %s", name, reason, r.Result, r.Error)
+		} else {
+			log.Printf("🔄 Promtail: %s restarted (%s), ready after %.1fs", name, reason, r.DurationSeconds)
+		}
+	}
+
+	// A configuration Promtail does not come back with is not retried
+	// every interval: the failed restart stays in the status of the
+	// container until the files change again.
+	if hash != "" {
+		m.mu.Lock()
+		m.configHash = hash
+		m.configAt = time.Now()
+		m.mu.Unlock()
+	}
+}
+
+// startAll starts the Promtail containers one after the other and waits
+// for each to answer /ready. They read the configuration as it is now, so
+// it becomes the one they run with.
+func (m *Manager) startAll(ctx context.Context) {
+	hash := ""
+	if m.opts.ConfigDir != "" {
+		var err error
+		hash, err = HashConfig(m.opts.ConfigDir)
+		m.setError(&m.configErr, err)
+	}
+
+	for _, name := range m.targetNames() {
+		start := time.Now()
+		err := m.docker.Start(ctx, name)
+		if err == nil {
+			err = m.waitReady(ctx, name)
+		}
+		if err != nil {
+			log.Printf("⚠️  Promtail: start of %s failed: %v", name, err)
+		} else {
+			log.Printf("▶️  Promtail: %s started, ready after %.1fs", name, time.Since(start).Seconds())
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = false
+	if hash != "" && hash != m.configHash {
+		m.configHash = hash
+		m.configAt = time.Now()
+	}
+}
+
+// stopAll stops every Promtail container, letting each flush its batches
+// and positions for up to stopTimeout.
+func (m *Manager) stopAll(ctx context.Context) {
+	m.mu.Lock()
+	m.stopped = true
+	m.mu.Unlock()
+
+	for _, name := range m.targetNames() {
+		if err := m.docker.Stop(ctx, name, stopTimeout); err != nil {
+			log.Printf("⚠️  Promtail: stop of %s failed: %v", name, err)
+			continue
+		}
+		log.Printf("⏹️  Promtail: %s stopped", name)
+	}
+}
+
+// reload reads the configuration and restarts the containers if it
+// changed; while they are stopped the next start applies it.
+func (m *Manager) reload(ctx context.Context) {
+	if m.opts.ConfigDir == "" {
+		return
+	}
+	hash, changed := m.configChanged()
+	if changed {
+		m.restartAll(ctx, ReasonConfig, hash)
+		return
+	}
+	if hash == "" {
+		return
+	}
+	m.mu.RLock()
+	pending := m.stopped && hash != m.configHash
+	m.mu.RUnlock()
+	if pending {
+		log.Printf("🔁 Promtail: configuration changed, applied at the next start")
+	} else {
+		log.Printf("🔁 Promtail: configuration unchanged")
+	}
+}
+
+// restart restarts one container and waits up to ReadyTimeout for it to
+// answer /ready.
+func (m *Manager) restart(ctx context.Context, name, reason string) Restart {
+	start := time.Now()
+	r := Restart{Time: start.UTC().Format(time.RFC3339), Reason: reason}
+
+	err := m.docker.Restart(ctx, name, stopTimeout)
+	if err != nil {
+		r.Result, r.Error = ResultFailed, err.Error()
+	} else {
+		r.Result, r.Error = ResultNotReady, "not ready after "+m.opts.ReadyTimeout.String()
+		if err := m.waitReady(ctx, name); err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				r.Error = err.Error()
+			}
+		} else {
+			r.Result, r.Error = ResultReady, ""
+		}
+	}
+	r.DurationSeconds = time.Since(start).Seconds()
+
+	m.restarts.WithLabelValues(name, reason, r.Result).Inc()
+	if r.Result == ResultReady {
+		m.duration.WithLabelValues(name).Set(r.DurationSeconds)
+	}
+	m.mu.Lock()
+	a := m.agents[name]
+	a.Container = name
+	a.Restarts++
+	a.LastRestart = &r
+	m.agents[name] = a
+	m.mu.Unlock()
+	return r
+}
+
+// waitReady polls the container every second until it runs and answers
+// /ready, or ReadyTimeout passes.
+func (m *Manager) waitReady(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.ReadyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if ips, err := m.containerIPs(ctx); err == nil {
+			a := m.inspect(ctx, name, ips)
+			m.record(a)
+			if a.Ready {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) record(a Agent) {
+	m.mu.Lock()
+	m.agents[a.Container] = a
+	m.mu.Unlock()
+	if a.Ready {
+		m.up.WithLabelValues(a.Container).Set(1)
+	} else {
+		m.up.WithLabelValues(a.Container).Set(0)
+	}
+}
+
+// setError keeps err in *field for the status, logging it the first time;
+// a nil err clears it.
+func (m *Manager) setError(field *string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		*field = ""
+		return
+	}
+	if *field != err.Error() {
+		log.Printf("⚠️  Promtail: %v", err)
+	}
+	*field = err.Error()
+}
@@ -0,0 +1,86 @@
+package qos
+
+import "fmt"
+
+// Resource types of a standardized QoS class.
+const (
+	ResourceGBR              = "GBR"
+	ResourceNonGBR           = "Non-GBR"
+	ResourceDelayCriticalGBR = "Delay-critical GBR"
+)
+
+// Specifications of the 5QI and QCI tables below.
+const (
+	FiveQISpec = "3GPP TS 23.501 Table 5.7.4-1"
+	QCISpec    = "3GPP TS 23.203 Table 6.1.7-A"
+)
+
+// Class is one row of the standardized QoS characteristics table.
+type Class struct {
+	Value               int     `json:"value"`
+	ResourceType        string  `json:"resource_type"`
+	PriorityLevel       float64 `json:"priority_level"`
+	PacketDelayBudgetMs int     `json:"packet_delay_budget_ms"`
+	PacketErrorRate     string  `json:"packet_error_rate"`
+	Examples            string  `json:"examples"`
+}
+
+// fiveQIClasses is the subset of TS 23.501 Table 5.7.4-1 that students can
+// meet in the testbed or in the course material.
+var fiveQIClasses = map[int]Class{
+	1:  {1, ResourceGBR, 20, 100, "10⁻²", "Conversational voice"},
+	2:  {2, ResourceGBR, 40, 150, "10⁻³", "Conversational video (live streaming)"},
+	3:  {3, ResourceGBR, 30, 50, "10⁻³", "Real-time gaming, V2X messages"},
+	4:  {4, ResourceGBR, 50, 300, "10⁻⁶", "Non-conversational video (buffered streaming)"},
+	65: {65, ResourceGBR, 7, 75, "10⁻²", "Mission-critical push-to-talk voice"},
+	66: {66, ResourceGBR, 20, 100, "10⁻²", "Non-mission-critical push-to-talk voice"},
+	67: {67, ResourceGBR, 15, 100, "10⁻³", "Mission-critical video user plane"},
+	5:  {5, ResourceNonGBR, 10, 100, "10⁻⁶", "IMS signalling"},
+	6:  {6, ResourceNonGBR, 60, 300, "10⁻⁶", "Video (buffered streaming), TCP-based applications"},
+	7:  {7, ResourceNonGBR, 70, 100, "10⁻³", "Voice, live video streaming, interactive gaming"},
+	8:  {8, ResourceNonGBR, 80, 300, "10⁻⁶", "TCP-based applications (www, e-mail, chat, ftp) — premium subscribers"},
+	9:  {9, ResourceNonGBR, 90, 300, "10⁻⁶", "TCP-based applications (www, e-mail, chat, ftp) — default internet"},
+	69: {69, ResourceNonGBR, 5, 60, "10⁻⁶", "Mission-critical delay-sensitive signalling"},
+	70: {70, ResourceNonGBR, 55, 200, "10⁻⁶", "Mission-critical data"},
+	79: {79, ResourceNonGBR, 65, 50, "10⁻²", "V2X messages"},
+	80: {80, ResourceNonGBR, 68, 10, "10⁻⁶", "Low-latency eMBB, augmented reality"},
+	82: {82, ResourceDelayCriticalGBR, 19, 10, "10⁻⁴", "Discrete automation (small packets)"},
+	83: {83, ResourceDelayCriticalGBR, 22, 10, "10⁻⁴", "Discrete automation (large packets)"},
+	84: {84, ResourceDelayCriticalGBR, 24, 30, "10⁻⁵", "Intelligent transport systems"},
+	85: {85, ResourceDelayCriticalGBR, 21, 5, "10⁻⁵", "Electricity distribution (high voltage)"},
+}
+
+// qciClasses is TS 23.203 Table 6.1.7-A (EPS).
+var qciClasses = map[int]Class{
+	1:  {1, ResourceGBR, 2, 100, "10⁻²", "Conversational voice"},
+	2:  {2, ResourceGBR, 4, 150, "10⁻³", "Conversational video (live streaming)"},
+	3:  {3, ResourceGBR, 3, 50, "10⁻³", "Real-time gaming, V2X messages"},
+	4:  {4, ResourceGBR, 5, 300, "10⁻⁶", "Non-conversational video (buffered streaming)"},
+	65: {65, ResourceGBR, 0.7, 75, "10⁻²", "Mission-critical push-to-talk voice"},
+	66: {66, ResourceGBR, 2, 100, "10⁻²", "Non-mission-critical push-to-talk voice"},
+	5:  {5, ResourceNonGBR, 1, 100, "10⁻⁶", "IMS signalling"},
+	6:  {6, ResourceNonGBR, 6, 300, "10⁻⁶", "Video (buffered streaming), TCP-based applications"},
+	7:  {7, ResourceNonGBR, 7, 100, "10⁻³", "Voice, live video streaming, interactive gaming"},
+	8:  {8, ResourceNonGBR, 8, 300, "10⁻⁶", "TCP-based applications (www, e-mail, chat, ftp) — premium subscribers"},
+	9:  {9, ResourceNonGBR, 9, 300, "10⁻⁶", "TCP-based applications (www, e-mail, chat, ftp) — default internet"},
+	69: {69, ResourceNonGBR, 0.5, 60, "10⁻⁶", "Mission-critical delay-sensitive signalling"},
+	70: {70, ResourceNonGBR, 5.5, 200, "10⁻⁶", "Mission-critical data"},
+}
+
+// FiveQI returns the standardized characteristics of a 5QI. Values outside
+// the table are operator-specific.
+func FiveQI(v int) Class {
+	if c, ok := fiveQIClasses[v]; ok {
+		return c
+	}
+	return Class{Value: v, ResourceType: "operator-specific", Examples: fmt.Sprintf("Non-standard 5QI %d", v)}
+}
+
+// QCI returns the standardized characteristics of an EPS QCI. Values outside
+// the table are operator-specific.
+func QCI(v int) Class {
+	if c, ok := qciClasses[v]; ok {
+		return c
+	}
+	return Class{Value: v, ResourceType: "operator-specific", Examples: fmt.Sprintf("Non-standard QCI %d", v)}
+}
@@ -0,0 +1,363 @@
+package qos
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// pendingTTL is how long a GTPv2 request waits for its response.
+	pendingTTL = 30 * time.Second
+
+	// mapMax bounds every correlation table; a full table is dropped rather
+	// than grown without bound.
+	mapMax = 4096
+)
+
+// Flow is one QoS flow (5G) or EPS bearer (4G) of a UE.
+type Flow struct {
+	IMSI       string `json:"imsi"`
+	Generation string `json:"generation"`
+
+	// 5G: PDU session and QoS flow.
+	PDUSessionID int `json:"pdu_session_id,omitempty"`
+	QFI          int `json:"qfi,omitempty"`
+	FiveQI       int `json:"five_qi,omitempty"`
+
+	// 4G: EPS bearer. LinkedEBI is the default bearer of the PDN connection.
+	EBI        int    `json:"ebi,omitempty"`
+	LinkedEBI  int    `json:"linked_ebi,omitempty"`
+	QCI        int    `json:"qci,omitempty"`
+	BearerType string `json:"bearer_type,omitempty"` // "default" or "dedicated"
+	APN        string `json:"apn,omitempty"`
+	UEIP       string `json:"ue_ip,omitempty"`
+
+	ARPPriority int    `json:"arp_priority,omitempty"`
+	Class       Class  `json:"class"`
+	SetupAt     string `json:"setup_at"`
+}
+
+type flowKey struct {
+	imsi       string
+	generation string
+	session    int // PDU session ID or linked EBI
+	flow       int // QFI or EBI
+}
+
+// gtpRequest is a Create Session/Create Bearer/Delete Session request waiting
+// for its response.
+type gtpRequest struct {
+	imsi string
+	apn  string
+	ebi  int
+	qci  int
+	arp  int
+	seen time.Time
+}
+
+// Tracker maintains the per-UE QoS flow table from captured signalling:
+//
+//   - 5G: NGAP PDU Session Resource Setup / Initial Context Setup requests
+//     (PDU session ID, QFI, 5QI) and PDU Session Resource Release commands;
+//     the UE is identified through the SUCI of its Registration Request.
+//   - 4G: GTPv2 Create Session (default bearer), Create Bearer (dedicated
+//     bearer) and Delete Session exchanges on S11, as seen by the MME.
+type Tracker struct {
+	mcc string
+	mnc string
+
+	flowsGauge *prometheus.GaugeVec
+
+	mu sync.Mutex
+	// flows is the current table.
+	flows map[flowKey]*Flow
+	// 5G UE identification: "<gnb ip>/<RAN-UE-NGAP-ID>" and AMF-UE-NGAP-ID → IMSI.
+	ueByRAN map[string]string
+	ueByAMF map[string]string
+	// 4G: MME S11 TEID → IMSI, and in-flight requests by "<sgw ip>/<seq>".
+	ueByTEID   map[string]string
+	pending    map[string]gtpRequest
+	lastExpiry time.Time
+}
+
+// New creates a Tracker and registers its metrics on reg. mcc and mnc rebuild
+// the IMSI from the SUCI MSIN of 5G Registration Requests.
+func New(reg prometheus.Registerer, mcc, mnc string) *Tracker {
+	t := &Tracker{
+		mcc: mcc,
+		mnc: mnc,
+		flowsGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om",
+			Subsystem: "qos",
+			Name:      "flows",
+			Help:      "Active QoS flows (5G) or EPS bearers (4G) by generation, 5QI/QCI and resource type.",
+		}, []string{"generation", "qos_class", "resource_type"}),
+		flows:    make(map[flowKey]*Flow),
+		ueByRAN:  make(map[string]string),
+		ueByAMF:  make(map[string]string),
+		ueByTEID: make(map[string]string),
+		pending:  make(map[string]gtpRequest),
+	}
+	reg.MustRegister(t.flowsGauge)
+	return t
+}
+
+// Observe implements pipeline.Observer.
+func (t *Tracker) Observe(pkt capture.Packet, srcNF, dstNF string) {
+	switch pkt.Protocol {
+	case "ngap":
+		t.mu.Lock()
+		changed := t.observeNGAP(pkt, srcNF)
+		t.mu.Unlock()
+		if changed {
+			t.updateGauge()
+		}
+	case "gtpv2":
+		t.mu.Lock()
+		changed := t.observeGTPv2(pkt, srcNF, dstNF)
+		t.mu.Unlock()
+		if changed {
+			t.updateGauge()
+		}
+	}
+}
+
+// observeNGAP reports whether the flow table changed. Caller holds t.mu.
+func (t *Tracker) observeNGAP(pkt capture.Packet, srcNF string) bool {
+	fromAMF := collector.ParseNFKind(srcNF) == collector.KindAMF
+	gnbIP := pkt.SrcIP
+	if fromAMF {
+		gnbIP = pkt.DstIP
+	}
+	ranKey := gnbIP + "/" + pkt.RANUENGAPId
+
+	if pkt.SUCIMsin != "" && pkt.RANUENGAPId != "" {
+		putBounded(t.ueByRAN, ranKey, t.mcc+t.mnc+pkt.SUCIMsin)
+	}
+	if pkt.RANUENGAPId != "" && pkt.AMFUENGAPId != "" {
+		if imsi := t.ueByRAN[ranKey]; imsi != "" {
+			putBounded(t.ueByAMF, pkt.AMFUENGAPId, imsi)
+		}
+	}
+
+	imsi := t.ueByAMF[pkt.AMFUENGAPId]
+	if imsi == "" {
+		imsi = t.ueByRAN[ranKey]
+	}
+	if imsi == "" || !fromAMF || pkt.PDUSessionID == 0 {
+		return false
+	}
+
+	switch pkt.NGAPProcedureCode {
+	case 14, 29: // InitialContextSetup, PDUSessionResourceSetup
+		if len(pkt.QoSFlowIDs) == 0 {
+			return false
+		}
+		for i, qfi := range pkt.QoSFlowIDs {
+			f := &Flow{
+				IMSI:         imsi,
+				Generation:   capture.Generation5G,
+				PDUSessionID: pkt.PDUSessionID,
+				QFI:          qfi,
+				SetupAt:      pkt.Timestamp.UTC().Format(time.RFC3339),
+			}
+			if i < len(pkt.FiveQIs) {
+				f.FiveQI = pkt.FiveQIs[i]
+				f.Class = FiveQI(f.FiveQI)
+			}
+			t.flows[flowKey{imsi, capture.Generation5G, pkt.PDUSessionID, qfi}] = f
+		}
+		return true
+
+	case 28: // PDUSessionResourceRelease
+		return t.remove(imsi, capture.Generation5G, pkt.PDUSessionID)
+	}
+	return false
+}
+
+// observeGTPv2 reports whether the flow table changed. Only the S11 leg
+// (MME ↔ SGW-C) is used so that each bearer is counted once. Caller holds t.mu.
+func (t *Tracker) observeGTPv2(pkt capture.Packet, srcNF, dstNF string) bool {
+	t.expire(pkt.Timestamp)
+
+	var reqKey string
+	switch {
+	case collector.ParseNFKind(srcNF) == collector.KindMME:
+		reqKey = pkt.DstIP + "/" + pkt.GTPv2Seq
+	case collector.ParseNFKind(dstNF) == collector.KindMME:
+		reqKey = pkt.SrcIP + "/" + pkt.GTPv2Seq
+	default:
+		return false
+	}
+	ebi, _ := strconv.Atoi(pkt.GTPv2EBI)
+
+	switch pkt.GTPv2MessageType {
+	case 32: // Create Session Request (MME → SGW-C)
+		if pkt.GTPv2IMSI != "" {
+			t.addPending(reqKey, gtpRequest{
+				imsi: pkt.GTPv2IMSI, apn: pkt.GTPv2APN, ebi: ebi,
+				qci: pkt.GTPv2QCI, arp: pkt.GTPv2ARP, seen: pkt.Timestamp,
+			})
+		}
+
+	case 33: // Create Session Response — the header TEID is the MME's S11 TEID
+		req, ok := t.takePending(reqKey)
+		if !ok || pkt.GTPv2Cause != "16" {
+			return false
+		}
+		putBounded(t.ueByTEID, pkt.GTPv2TEID, req.imsi)
+		t.flows[flowKey{req.imsi, capture.Generation4G, req.ebi, req.ebi}] = &Flow{
+			IMSI:        req.imsi,
+			Generation:  capture.Generation4G,
+			EBI:         req.ebi,
+			LinkedEBI:   req.ebi,
+			QCI:         req.qci,
+			BearerType:  "default",
+			APN:         req.apn,
+			UEIP:        pkt.GTPv2UEIP,
+			ARPPriority: req.arp,
+			Class:       QCI(req.qci),
+			SetupAt:     pkt.Timestamp.UTC().Format(time.RFC3339),
+		}
+		return true
+
+	case 95: // Create Bearer Request (SGW-C → MME) — EBI carries the linked bearer
+		if imsi := t.ueByTEID[pkt.GTPv2TEID]; imsi != "" {
+			t.addPending(reqKey, gtpRequest{
+				imsi: imsi, ebi: ebi, qci: pkt.GTPv2QCI, arp: pkt.GTPv2ARP, seen: pkt.Timestamp,
+			})
+		}
+
+	case 96: // Create Bearer Response — EBI is the newly allocated bearer
+		req, ok := t.takePending(reqKey)
+		if !ok || pkt.GTPv2Cause != "16" || ebi == 0 {
+			return false
+		}
+		t.flows[flowKey{req.imsi, capture.Generation4G, req.ebi, ebi}] = &Flow{
+			IMSI:        req.imsi,
+			Generation:  capture.Generation4G,
+			EBI:         ebi,
+			LinkedEBI:   req.ebi,
+			QCI:         req.qci,
+			BearerType:  "dedicated",
+			ARPPriority: req.arp,
+			Class:       QCI(req.qci),
+			SetupAt:     pkt.Timestamp.UTC().Format(time.RFC3339),
+		}
+		return true
+
+	case 36: // Delete Session Request — EBI is the default bearer of the PDN
+		t.addPending(reqKey, gtpRequest{ebi: ebi, seen: pkt.Timestamp})
+
+	case 37: // Delete Session Response
+		req, ok := t.takePending(reqKey)
+		imsi := t.ueByTEID[pkt.GTPv2TEID]
+		if !ok || imsi == "" || pkt.GTPv2Cause != "16" {
+			return false
+		}
+		return t.remove(imsi, capture.Generation4G, req.ebi)
+	}
+	return false
+}
+
+// remove deletes every flow of one PDU session / PDN connection. Caller holds t.mu.
+func (t *Tracker) remove(imsi, generation string, session int) bool {
+	removed := false
+	for k := range t.flows {
+		if k.imsi == imsi && k.generation == generation && k.session == session {
+			delete(t.flows, k)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// addPending records an in-flight GTPv2 request. Caller holds t.mu.
+func (t *Tracker) addPending(key string, req gtpRequest) {
+	if len(t.pending) >= mapMax {
+		t.pending = make(map[string]gtpRequest)
+	}
+	t.pending[key] = req
+}
+
+// takePending returns and forgets the request answered by a response. Caller holds t.mu.
+func (t *Tracker) takePending(key string) (gtpRequest, bool) {
+	req, ok := t.pending[key]
+	if ok {
+		delete(t.pending, key)
+	}
+	return req, ok
+}
+
+// expire evicts unanswered requests, at most once per second of capture
+// time. Caller holds t.mu.
+func (t *Tracker) expire(now time.Time) {
+	if now.Sub(t.lastExpiry) < time.Second {
+		return
+	}
+	t.lastExpiry = now
+	for key, req := range t.pending {
+		if now.Sub(req.seen) >= pendingTTL {
+			delete(t.pending, key)
+		}
+	}
+}
+
+// updateGauge recomputes om_qos_flows from the flow table.
+func (t *Tracker) updateGauge() {
+	type gaugeKey struct{ generation, class, resourceType string }
+	counts := make(map[gaugeKey]int)
+
+	t.mu.Lock()
+	for _, f := range t.flows {
+		class := f.FiveQI
+		if f.Generation == capture.Generation4G {
+			class = f.QCI
+		}
+		counts[gaugeKey{f.Generation, strconv.Itoa(class), f.Class.ResourceType}]++
+	}
+	t.mu.Unlock()
+
+	t.flowsGauge.Reset()
+	for k, n := range counts {
+		t.flowsGauge.WithLabelValues(k.generation, k.class, k.resourceType).Set(float64(n))
+	}
+}
+
+// Flows returns the current QoS flow table ordered by IMSI, session and flow.
+func (t *Tracker) Flows() []Flow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Flow, 0, len(t.flows))
+	for _, f := range t.flows {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.IMSI != b.IMSI {
+			return a.IMSI < b.IMSI
+		}
+		if a.PDUSessionID+a.LinkedEBI != b.PDUSessionID+b.LinkedEBI {
+			return a.PDUSessionID+a.LinkedEBI < b.PDUSessionID+b.LinkedEBI
+		}
+		return a.QFI+a.EBI < b.QFI+b.EBI
+	})
+	return out
+}
+
+// putBounded sets m[k] = v, dropping the whole map first if it is full.
+func putBounded(m map[string]string, k, v string) {
+	if len(m) >= mapMax {
+		for key := range m {
+			delete(m, key)
+		}
+	}
+	m[k] = v
+}
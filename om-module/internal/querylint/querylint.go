@@ -0,0 +1,479 @@
+// Package querylint checks the queries of the dashboard files against the
+// live Prometheus and Loki, so a panel that can never show data is caught
+// when the dashboards change rather than in front of a class. Every PromQL
+// target is sent to the Prometheus query API as a dry run and the metrics
+// and labels it selects are looked up among those Prometheus has seen;
+// every LogQL target is dry-run against Loki and checked against the label
+// contract of the log pipeline (logschema). The result is a Report, written
+// as JSON and exported as om_dashboard_query_problems.
+package querylint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Problem kinds.
+const (
+	// KindSyntax: the query API rejected the expression.
+	KindSyntax = "syntax"
+	// KindQuery: the dry run failed for another reason (timeout, 5xx).
+	KindQuery = "query"
+	// KindMetric: no series of the metric exists in Prometheus.
+	KindMetric = "metric"
+	// KindLabel: a selector matches on a label no series (Prometheus) or
+	// stream (Loki) has.
+	KindLabel = "label"
+	// KindValue: a Loki selector matches a value the topology cannot emit.
+	KindValue = "value"
+)
+
+// Severities. Only errors make a report invalid: a missing metric or label
+// is expected while the component that exports it is stopped.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Options configure the linter. An empty URL skips that datasource.
+type Options struct {
+	PrometheusURL     string
+	PrometheusTimeout time.Duration
+	LokiURL           string
+	LokiTimeout       time.Duration
+}
+
+// Problem is one finding about one panel target.
+type Problem struct {
+	Dashboard  string `json:"dashboard"` // uid
+	File       string `json:"file"`
+	Panel      string `json:"panel"`
+	Datasource string `json:"datasource"` // "prometheus" or "loki"
+	Expr       string `json:"expr"`
+	Kind       string `json:"kind"`
+	Severity   string `json:"severity"`
+	Name       string `json:"name,omitempty"` // the metric or label
+	Message    string `json:"message"`
+}
+
+// Report is the result of one pass over the dashboard files.
+type Report struct {
+	GeneratedAt string `json:"generated_at"`
+	Dir         string `json:"dir"`
+	Dashboards  int    `json:"dashboards"`
+	// Checked counts the targets of each datasource; targets of a
+	// datasource that was skipped are not counted.
+	Checked  map[string]int `json:"checked"`
+	Skipped  []string       `json:"skipped"` // datasources not checked, with the reason
+	Valid    bool           `json:"valid"`   // no problem of severity error
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	Problems []Problem      `json:"problems"`
+}
+
+// Linter checks the dashboards of one inventory.
+type Linter struct {
+	opts   Options
+	inv    *dashboards.Inventory
+	client *http.Client
+
+	problems *prometheus.GaugeVec
+
+	mu   sync.RWMutex
+	last *Report
+}
+
+// New registers om_dashboard_query_problems on reg and returns a linter of
+// the dashboards in inv.
+func New(reg prometheus.Registerer, inv *dashboards.Inventory, opts Options) *Linter {
+	l := &Linter{
+		opts:   opts,
+		inv:    inv,
+		client: httpclient.New("querylint", 0),
+		problems: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "dashboard", Name: "query_problems",
+			Help: "Dashboard panel queries with problems found by the last query lint, per dashboard and kind.",
+		}, []string{"dashboard", "kind", "severity"}),
+	}
+	reg.MustRegister(l.problems)
+	return l
+}
+
+// Last returns the report of the last pass, if there was one.
+func (l *Linter) Last() (Report, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.last == nil {
+		return Report{}, false
+	}
+	return *l.last, true
+}
+
+// Inputs returns what a pass depends on: the dashboard files, the label
+// contract and the metric and label names Prometheus knows. It is the
+// regen.Job Inputs of the lint, so a pass is skipped while none of them
+// changed.
+func (l *Linter) Inputs(ctx context.Context, schema logschema.Schema) ([]byte, error) {
+	h := sha256.New()
+	list, err := l.inv.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range list {
+		fmt.Fprintln(h, d.File, d.SHA256)
+	}
+	if err := json.NewEncoder(h).Encode(schema); err != nil {
+		return nil, err
+	}
+	if l.opts.PrometheusURL != "" {
+		metrics, labels, err := l.names(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(h, strings.Join(keys(metrics), ","))
+		fmt.Fprintln(h, strings.Join(keys(labels), ","))
+	}
+	return h.Sum(nil), nil
+}
+
+// Run lints every dashboard and keeps the report for Last. An error means
+// the dashboards could not be read or Prometheus could not list its names;
+// a datasource that fails a dry run shows up in the report instead.
+func (l *Linter) Run(ctx context.Context, schema logschema.Schema) (Report, error) {
+	list, err := l.inv.List()
+	if err != nil {
+		return Report{}, err
+	}
+	rep := Report{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Dir:         l.inv.Dir(),
+		Dashboards:  len(list),
+		Checked:     map[string]int{},
+		Skipped:     []string{},
+		Problems:    []Problem{},
+	}
+
+	var metrics, labels map[string]bool
+	promOn, lokiOn := l.opts.PrometheusURL != "", l.opts.LokiURL != ""
+	if promOn {
+		if metrics, labels, err = l.names(ctx); err != nil {
+			return Report{}, err
+		}
+	} else {
+		rep.Skipped = append(rep.Skipped, "prometheus: PROMETHEUS_URL not set or not ready at startup")
+	}
+	if !lokiOn {
+		rep.Skipped = append(rep.Skipped, "loki: LOKI_URL not set or not ready at startup")
+	}
+
+	for _, d := range list {
+		_, raw, err := l.inv.Load(d.UID)
+		if err != nil {
+			continue
+		}
+		queries, err := dashboards.Queries(raw)
+		if err != nil {
+			continue
+		}
+		for _, q := range queries {
+			base := Problem{Dashboard: d.UID, File: d.File, Panel: q.Panel, Datasource: q.DatasourceType, Expr: q.Expr}
+			var found []Problem
+			switch {
+			case q.DatasourceType == "prometheus" && promOn:
+				found = l.checkPromQL(ctx, base, metrics, labels)
+			case q.DatasourceType == "loki" && lokiOn:
+				found = l.checkLogQL(ctx, base, schema)
+			default:
+				continue
+			}
+			rep.Checked[q.DatasourceType]++
+			rep.Problems = append(rep.Problems, found...)
+		}
+	}
+	if ctx.Err() != nil {
+		return Report{}, ctx.Err()
+	}
+
+	l.problems.Reset()
+	for _, p := range rep.Problems {
+		if p.Severity == SeverityError {
+			rep.Errors++
+		} else {
+			rep.Warnings++
+		}
+		l.problems.WithLabelValues(p.Dashboard, p.Kind, p.Severity).Inc()
+	}
+	rep.Valid = rep.Errors == 0
+
+	l.mu.Lock()
+	l.last = &rep
+	l.mu.Unlock()
+	return rep, nil
+}
+
+// Write stages the report as indented JSON to path in tx.
+func Write(tx *output.Txn, path string, rep Report) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	tx.WriteFile(path, append(b, '\n'), 0o644)
+	return nil
+}
+
+// --- PromQL --------------------------------------------------------------
+
+var (
+	stringRe   = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|'(?:[^'\\\\]|\\\\.)*'|`[^`]*`")
+	selectorRe = regexp.MustCompile(`\{[^{}]*\}`)
+	matcherRe  = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*(=~|!~|!=|=)\s*"`)
+	rangeRe    = regexp.MustCompile(`\[[^\]]*\]`)
+	groupingRe = regexp.MustCompile(`(?i)\b(by|without|on|ignoring|group_left|group_right)\s*\([^)]*\)`)
+	identRe    = regexp.MustCompile(`[A-Za-z_:][A-Za-z0-9_:]*`)
+)
+
+// keywords are the PromQL words that look like metric names.
+var keywords = map[string]bool{
+	"and": true, "or": true, "unless": true, "bool": true, "offset": true,
+	"by": true, "without": true, "on": true, "ignoring": true,
+	"group_left": true, "group_right": true, "inf": true, "nan": true, "atan2": true,
+}
+
+func (l *Linter) checkPromQL(ctx context.Context, base Problem, metrics, labels map[string]bool) []Problem {
+	var out []Problem
+	add := func(kind, severity, name, msg string) {
+		p := base
+		p.Kind, p.Severity, p.Name, p.Message = kind, severity, name, msg
+		out = append(out, p)
+	}
+
+	q := url.Values{}
+	q.Set("query", substitute(base.Expr))
+	q.Set("time", strconv.FormatInt(time.Now().Unix(), 10))
+	if p, failed := l.dryRun(ctx, base, l.opts.PrometheusURL, "/api/v1/query", q, l.opts.PrometheusTimeout); failed {
+		return []Problem{p}
+	}
+
+	for _, name := range metricNames(base.Expr) {
+		if !metrics[name] {
+			add(KindMetric, SeverityWarning, name, fmt.Sprintf("metric %q has no series in Prometheus", name))
+		}
+	}
+	for _, name := range selectorLabels(base.Expr) {
+		if !labels[name] {
+			add(KindLabel, SeverityWarning, name, fmt.Sprintf("no series in Prometheus has label %q", name))
+		}
+	}
+	return out
+}
+
+// metricNames returns the metric names a PromQL expression selects: the
+// identifiers left once strings, label matchers, ranges and grouping
+// clauses are removed that are neither keywords nor function calls.
+func metricNames(expr string) []string {
+	s := stringRe.ReplaceAllString(expr, `""`)
+	s = selectorRe.ReplaceAllString(s, "{}")
+	s = rangeRe.ReplaceAllString(s, "[]")
+	s = groupingRe.ReplaceAllString(s, "")
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, loc := range identRe.FindAllStringIndex(s, -1) {
+		name := s[loc[0]:loc[1]]
+		if loc[0] > 0 {
+			// Durations (5m), numbers (1e3) and the tail of $variables.
+			if c := s[loc[0]-1]; c >= '0' && c <= '9' || c == '.' || c == '$' {
+				continue
+			}
+		}
+		if strings.HasPrefix(strings.TrimLeft(s[loc[1]:], " \t\n"), "(") {
+			continue
+		}
+		if keywords[strings.ToLower(name)] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+// selectorLabels returns the label names matched in the selectors of an
+// expression. Labels only named in by/without clauses are left out: they
+// may come from label_replace or count_values.
+func selectorLabels(expr string) []string {
+	seen := map[string]bool{"__name__": true}
+	var out []string
+	for _, sel := range selectorRe.FindAllString(expr, -1) {
+		for _, m := range matcherRe.FindAllStringSubmatch(sel, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				out = append(out, m[1])
+			}
+		}
+	}
+	return out
+}
+
+// names returns the metric names and label names Prometheus has seen.
+func (l *Linter) names(ctx context.Context) (metrics, labels map[string]bool, err error) {
+	if metrics, err = l.values(ctx, "/api/v1/label/__name__/values"); err != nil {
+		return nil, nil, err
+	}
+	if labels, err = l.values(ctx, "/api/v1/labels"); err != nil {
+		return nil, nil, err
+	}
+	return metrics, labels, nil
+}
+
+func (l *Linter) values(ctx context.Context, path string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.opts.PrometheusTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(l.opts.PrometheusURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus %s: unexpected status %s", path, resp.Status)
+	}
+	var body struct {
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make(map[string]bool, len(body.Data))
+	for _, v := range body.Data {
+		out[v] = true
+	}
+	return out, nil
+}
+
+// --- LogQL ---------------------------------------------------------------
+
+func (l *Linter) checkLogQL(ctx context.Context, base Problem, schema logschema.Schema) []Problem {
+	var out []Problem
+	add := func(kind, severity, name, msg string) {
+		p := base
+		p.Kind, p.Severity, p.Name, p.Message = kind, severity, name, msg
+		out = append(out, p)
+	}
+
+	// query_range accepts both log and metric queries.
+	now := time.Now()
+	q := url.Values{}
+	q.Set("query", substitute(base.Expr))
+	q.Set("start", strconv.FormatInt(now.Add(-5*time.Minute).UnixNano(), 10))
+	q.Set("end", strconv.FormatInt(now.UnixNano(), 10))
+	q.Set("limit", "1")
+	if p, failed := l.dryRun(ctx, base, l.opts.LokiURL, "/loki/api/v1/query_range", q, l.opts.LokiTimeout); failed {
+		return []Problem{p}
+	}
+
+	for _, p := range schema.Check(base.Expr) {
+		kind := KindValue
+		if p.Value == "" {
+			kind = KindLabel
+		}
+		add(kind, SeverityWarning, p.Label, p.Message)
+	}
+	return out
+}
+
+// --- helpers -------------------------------------------------------------
+
+var (
+	builtinVarRe = regexp.MustCompile(`\$\{?__[A-Za-z_]+\}?`)
+	offsetVarRe  = regexp.MustCompile(`offset\s+(\$\{[A-Za-z0-9_]+\}|\$[A-Za-z0-9_]+)`)
+	varRe        = regexp.MustCompile(`\$\{[A-Za-z0-9_]+(:[A-Za-z]+)?\}|\$[A-Za-z0-9_]+`)
+)
+
+// substitute replaces the Grafana variables of a panel query the way a
+// dashboard with every option selected would: interval variables and
+// template variables after offset ($baseline) by a duration, other template
+// variables ($service) by a match-anything regex.
+func substitute(expr string) string {
+	expr = builtinVarRe.ReplaceAllString(expr, "5m")
+	expr = offsetVarRe.ReplaceAllString(expr, "offset 5m")
+	return varRe.ReplaceAllString(expr, ".*")
+}
+
+// dryRun sends one query and returns the problem when it fails: a syntax
+// error when the API rejects the expression (400), a query error otherwise.
+func (l *Linter) dryRun(ctx context.Context, base Problem, target, path string, q url.Values, timeout time.Duration) (Problem, bool) {
+	status, msg, err := l.get(ctx, target, path, q, timeout)
+	p := base
+	p.Severity = SeverityError
+	switch {
+	case err != nil:
+		p.Kind, p.Message = KindQuery, "dry run failed: "+err.Error()
+	case status == http.StatusBadRequest:
+		p.Kind, p.Message = KindSyntax, msg
+	case status != http.StatusOK:
+		p.Kind, p.Message = KindQuery, fmt.Sprintf("dry run: unexpected status %d: %s", status, msg)
+	default:
+		return Problem{}, false
+	}
+	return p, true
+}
+
+// get sends one query and returns the status and, for a failure, the error
+// message of the body.
+func (l *Linter) get(ctx context.Context, base, path string, q url.Values, timeout time.Duration) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, "", nil
+	}
+
+	// Prometheus answers {"status":"error","error":"…"}, Loki plain text.
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	var body struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(b))
+	if json.Unmarshal(b, &body) == nil && body.Error != "" {
+		msg = body.Error
+	}
+	return resp.StatusCode, msg, nil
+}
+
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,134 @@
+package querylint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricNames(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`fivegs_amffunction_rm_reginitreq`, []string{"fivegs_amffunction_rm_reginitreq"}},
+		{`sum by (nf) (rate(om_signalling_messages_total{protocol="ngap"}[5m]))`, []string{"om_signalling_messages_total"}},
+		{`a / ignoring(instance) group_left b offset 1h`, []string{"a", "b"}},
+		{`histogram_quantile(0.95, sum(rate(x_bucket[$__rate_interval])) by (le))`, []string{"x_bucket"}},
+		{`up{job=~"amf|smf"} == bool 1 and on(job) up`, []string{"up"}},
+		{`label_replace(vector(1), "dst", "$1", "src", "(.*)")`, nil},
+		{`rate(x[5m]) * 1e3 > Inf`, []string{"x"}},
+		{`y{label="not_a_metric"} unless $variable`, []string{"y"}},
+		{`namespace:metric:rate5m`, []string{"namespace:metric:rate5m"}},
+	}
+	for _, tt := range tests {
+		if got := metricNames(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("metricNames(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorLabels(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`up`, nil},
+		{`up{job="amf", instance!="x"}`, []string{"job", "instance"}},
+		{`sum by (nf) (x{__name__=~"a.*", nf=~"$nf"}) / y{nf="amf"}`, []string{"nf"}},
+		{`count_values("version", build_info)`, nil},
+	}
+	for _, tt := range tests {
+		if got := selectorLabels(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectorLabels(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`rate(x[$__rate_interval])`, `rate(x[5m])`},
+		{`rate(x[${__interval}])`, `rate(x[5m])`},
+		{`x offset $baseline`, `x offset 5m`},
+		{`x offset ${baseline}`, `x offset 5m`},
+		{`x{nf=~"$nf", job="${job:regex}"}`, `x{nf=~".*", job=".*"}`},
+	}
+	for _, tt := range tests {
+		if got := substitute(tt.in); got != tt.want {
+			t.Errorf("substitute(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Run reports a rejected query as an error and an unknown metric or label
+// as a warning, against a Prometheus that knows metric up and label job.
+func TestRun(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/label/__name__/values":
+			json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": []string{"up"}})
+		case "/api/v1/labels":
+			json.NewEncoder(w).Encode(map[string]any{"status": "success", "data": []string{"__name__", "job"}})
+		case "/api/v1/query":
+			if strings.Contains(r.URL.Query().Get("query"), "((") {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": "parse error: unexpected"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"status": "success"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer prom.Close()
+
+	dir := t.TempDir()
+	board := map[string]any{
+		"uid": "core", "title": "Core",
+		"panels": []map[string]any{
+			{"title": "ok", "datasource": map[string]string{"type": "prometheus"}, "targets": []map[string]string{{"expr": `up{job="amf"}`}}},
+			{"title": "broken", "datasource": map[string]string{"type": "prometheus"}, "targets": []map[string]string{{"expr": `sum((up)`}}},
+			{"title": "missing", "datasource": map[string]string{"type": "prometheus"}, "targets": []map[string]string{{"expr": `gone{nf="smf"}`}}},
+			{"title": "logs", "datasource": map[string]string{"type": "loki"}, "targets": []map[string]string{{"expr": `{job="x"}`}}},
+		},
+	}
+	b, _ := json.Marshal(board)
+	if err := os.WriteFile(filepath.Join(dir, "core.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(prometheus.NewRegistry(), dashboards.NewInventory(dir), Options{PrometheusURL: prom.URL, PrometheusTimeout: 5 * time.Second})
+	rep, err := l.Run(t.Context(), logschema.Schema{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type finding struct{ panel, kind, severity, name string }
+	var got []finding
+	for _, p := range rep.Problems {
+		got = append(got, finding{p.Panel, p.Kind, p.Severity, p.Name})
+	}
+	want := []finding{
+		{"broken", KindSyntax, SeverityError, ""},
+		{"missing", KindMetric, SeverityWarning, "gone"},
+		{"missing", KindLabel, SeverityWarning, "nf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems %v, want %v", got, want)
+	}
+	if rep.Valid || rep.Errors != 1 || rep.Warnings != 2 || rep.Checked["prometheus"] != 3 || rep.Checked["loki"] != 0 {
+		t.Errorf("report valid %t, %d errors, %d warnings, checked %v", rep.Valid, rep.Errors, rep.Warnings, rep.Checked)
+	}
+	if len(rep.Skipped) != 1 || !strings.HasPrefix(rep.Skipped[0], "loki:") {
+		t.Errorf("skipped %v, want loki", rep.Skipped)
+	}
+}
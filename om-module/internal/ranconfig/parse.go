@@ -0,0 +1,395 @@
+package ranconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"go.yaml.in/yaml/v2"
+)
+
+// Configurations of the RAN nodes, where their init scripts render them
+// from the files under /mnt. A UERANSIM gNB reads the file named after
+// its COMPONENT_NAME.
+const (
+	srsranConfig   = "/etc/srsran/gnb.yml"
+	ueransimDir    = "/UERANSIM/config"
+	srslteConfig   = "/etc/srsran/enb.conf"
+	srslteRRConfig = "/etc/srsran/rr.conf" // when enb.conf has no rr_config
+)
+
+// readRAN reads the configuration of a RAN container. A node of another
+// project is reported with its Error.
+func (c *Checker) readRAN(ctx context.Context, cd *collector.ContainerData) Node {
+	n := Node{Container: cd.Name, Kind: string(cd.NFKind), Project: cd.Project, Generation: cd.NFKind.Generation(), Addresses: []string{}, TAIs: []TAI{}}
+	var err error
+	switch {
+	case cd.Project == ProjectSRSRAN && cd.NFKind == collector.KindGNB:
+		n.Config = srsranConfig
+		var data string
+		if data, err = c.cat(ctx, cd.Name, n.Config); err == nil {
+			err = parseSRSRAN([]byte(data), &n)
+		}
+	case cd.Project == ProjectUERANSIM && cd.NFKind == collector.KindGNB:
+		var env map[string]string
+		if env, err = c.docker.Env(ctx, cd.ID); err == nil && env["COMPONENT_NAME"] == "" {
+			err = errors.New("no COMPONENT_NAME in the environment")
+		}
+		if err == nil {
+			n.Config = path.Join(ueransimDir, env["COMPONENT_NAME"]+".yaml")
+			var data string
+			if data, err = c.cat(ctx, cd.Name, n.Config); err == nil {
+				err = parseUERANSIM([]byte(data), &n)
+			}
+		}
+	case cd.Project == ProjectSRSLTE && cd.NFKind == collector.KindENB:
+		n.Config = srslteConfig
+		var enb, rr string
+		if enb, err = c.cat(ctx, cd.Name, n.Config); err == nil {
+			rrFile := iniValue(enb, "enb_files", "rr_config")
+			if rrFile == "" {
+				rrFile = srslteRRConfig
+			}
+			if rr, err = c.cat(ctx, cd.Name, rrFile); err == nil {
+				err = parseSRSLTE(enb, rr, &n)
+			}
+		}
+	default:
+		err = fmt.Errorf("no known configuration for a %s of project %q", cd.NFKind, cd.Project)
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	return n
+}
+
+// cat returns the content of file inside container.
+func (c *Checker) cat(ctx context.Context, container, file string) (string, error) {
+	data, code, err := c.docker.Exec(ctx, container, []string{"cat", file})
+	if err != nil || code != 0 {
+		return "", fmt.Errorf("read %s: exit %d, %v", file, code, err)
+	}
+	return data, nil
+}
+
+// The values below are decoded as strings: YAML would read an MNC of 01
+// or an SD of 000010 as numbers, losing the leading zeros or reading them
+// as octal.
+
+type plmnID struct {
+	MCC string `yaml:"mcc"`
+	MNC string `yaml:"mnc"`
+}
+
+type sNSSAI struct {
+	SST string `yaml:"sst"`
+	SD  string `yaml:"sd"`
+}
+
+type open5gsServer struct {
+	Server []struct {
+		Address string `yaml:"address"`
+	} `yaml:"server"`
+}
+
+// open5gsSection is the amf or mme section of an Open5GS configuration.
+type open5gsSection struct {
+	NGAP open5gsServer `yaml:"ngap"`
+	S1AP open5gsServer `yaml:"s1ap"`
+	TAI  []struct {
+		PLMNID plmnID      `yaml:"plmn_id"`
+		TAC    interface{} `yaml:"tac"`
+	} `yaml:"tai"`
+	PLMNSupport []struct {
+		PLMNID plmnID   `yaml:"plmn_id"`
+		SNSSAI []sNSSAI `yaml:"s_nssai"`
+	} `yaml:"plmn_support"`
+}
+
+// parseOpen5GS reads the NGAP (S1AP) addresses, the tracking areas and the
+// S-NSSAIs of the configuration of an Open5GS AMF or MME into n.
+func parseOpen5GS(data []byte, n *Node) error {
+	var cfg struct {
+		AMF *open5gsSection `yaml:"amf"`
+		MME *open5gsSection `yaml:"mme"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	s := cfg.AMF
+	if n.Kind == string(collector.KindMME) {
+		s = cfg.MME
+	}
+	if s == nil {
+		return fmt.Errorf("no %s section", n.Kind)
+	}
+	for _, srv := range append(s.NGAP.Server, s.S1AP.Server...) {
+		if srv.Address != "" {
+			n.Addresses = append(n.Addresses, srv.Address)
+		}
+	}
+	for _, t := range s.TAI {
+		plmn, err := formatPLMN(t.PLMNID.MCC, t.PLMNID.MNC)
+		if err != nil {
+			return fmt.Errorf("tai: %w", err)
+		}
+		tacs, err := parseTACs(t.TAC)
+		if err != nil {
+			return fmt.Errorf("tai: %w", err)
+		}
+		for _, tac := range tacs {
+			n.TAIs = appendTAI(n.TAIs, TAI{PLMN: plmn, TAC: tac})
+		}
+	}
+	for _, p := range s.PLMNSupport {
+		plmn, err := formatPLMN(p.PLMNID.MCC, p.PLMNID.MNC)
+		if err != nil {
+			return fmt.Errorf("plmn_support: %w", err)
+		}
+		if n.Slices, err = appendSlices(n.Slices, plmn, p.SNSSAI); err != nil {
+			return fmt.Errorf("plmn_support: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseSRSRAN reads the AMF address, the supported tracking areas with
+// their slices and the tracking area of the cell of a srsRAN gNB
+// configuration into n.
+func parseSRSRAN(data []byte, n *Node) error {
+	var cfg struct {
+		CUCP struct {
+			AMF struct {
+				Addr string `yaml:"addr"`
+				TAs  []struct {
+					TAC   string `yaml:"tac"`
+					PLMNs []struct {
+						PLMN   string   `yaml:"plmn"`
+						Slices []sNSSAI `yaml:"tai_slice_support_list"`
+					} `yaml:"plmn_list"`
+				} `yaml:"supported_tracking_areas"`
+			} `yaml:"amf"`
+		} `yaml:"cu_cp"`
+		Cell struct {
+			PLMN string `yaml:"plmn"`
+			TAC  string `yaml:"tac"`
+		} `yaml:"cell_cfg"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	if cfg.CUCP.AMF.Addr != "" {
+		n.Addresses = append(n.Addresses, cfg.CUCP.AMF.Addr)
+	}
+	for _, ta := range cfg.CUCP.AMF.TAs {
+		tac, err := parseNumber(ta.TAC)
+		if err != nil {
+			return fmt.Errorf("supported_tracking_areas: tac: %w", err)
+		}
+		for _, p := range ta.PLMNs {
+			plmn, err := splitPLMN(p.PLMN)
+			if err != nil {
+				return fmt.Errorf("supported_tracking_areas: %w", err)
+			}
+			n.TAIs = appendTAI(n.TAIs, TAI{PLMN: plmn, TAC: tac})
+			if n.Slices, err = appendSlices(n.Slices, plmn, p.Slices); err != nil {
+				return fmt.Errorf("supported_tracking_areas: %w", err)
+			}
+		}
+	}
+	if cfg.Cell.PLMN != "" && cfg.Cell.TAC != "" {
+		plmn, err := splitPLMN(cfg.Cell.PLMN)
+		if err != nil {
+			return fmt.Errorf("cell_cfg: %w", err)
+		}
+		tac, err := parseNumber(cfg.Cell.TAC)
+		if err != nil {
+			return fmt.Errorf("cell_cfg: tac: %w", err)
+		}
+		n.TAIs = appendTAI(n.TAIs, TAI{PLMN: plmn, TAC: tac})
+	}
+	return nil
+}
+
+// parseUERANSIM reads the PLMN, TAC, AMF addresses and slices of a
+// UERANSIM gNB configuration into n.
+func parseUERANSIM(data []byte, n *Node) error {
+	var cfg struct {
+		MCC  string `yaml:"mcc"`
+		MNC  string `yaml:"mnc"`
+		TAC  string `yaml:"tac"`
+		AMFs []struct {
+			Address string `yaml:"address"`
+		} `yaml:"amfConfigs"`
+		Slices []sNSSAI `yaml:"slices"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	plmn, err := formatPLMN(cfg.MCC, cfg.MNC)
+	if err != nil {
+		return err
+	}
+	tac, err := parseNumber(cfg.TAC)
+	if err != nil {
+		return fmt.Errorf("tac: %w", err)
+	}
+	n.TAIs = appendTAI(n.TAIs, TAI{PLMN: plmn, TAC: tac})
+	for _, a := range cfg.AMFs {
+		if a.Address != "" {
+			n.Addresses = append(n.Addresses, a.Address)
+		}
+	}
+	n.Slices, err = appendSlices(n.Slices, plmn, cfg.Slices)
+	return err
+}
+
+// rrTAC matches the tac of a cell of a srsLTE RR configuration.
+var rrTAC = regexp.MustCompile(`(?m)^\s*tac\s*=\s*(0x[0-9a-fA-F]+|\d+)\s*;`)
+
+// parseSRSLTE reads the PLMN and MME address of a srsLTE eNB configuration
+// and the TACs of the cells of its RR configuration into n.
+func parseSRSLTE(enb, rr string, n *Node) error {
+	plmn, err := formatPLMN(iniValue(enb, "enb", "mcc"), iniValue(enb, "enb", "mnc"))
+	if err != nil {
+		return err
+	}
+	if a := iniValue(enb, "enb", "mme_addr"); a != "" {
+		n.Addresses = append(n.Addresses, a)
+	}
+	matches := rrTAC.FindAllStringSubmatch(rr, -1)
+	if len(matches) == 0 {
+		return errors.New("no tac in the RR configuration")
+	}
+	for _, m := range matches {
+		tac, err := parseNumber(m[1])
+		if err != nil {
+			return fmt.Errorf("tac: %w", err)
+		}
+		n.TAIs = appendTAI(n.TAIs, TAI{PLMN: plmn, TAC: tac})
+	}
+	return nil
+}
+
+// iniValue returns key in section of an INI file, "" when absent.
+func iniValue(data, section, key string) string {
+	current := ""
+	for _, l := range lines(data) {
+		switch {
+		case strings.HasPrefix(l, "#") || strings.HasPrefix(l, ";"):
+		case strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]"):
+			current = strings.TrimSpace(l[1 : len(l)-1])
+		case current == section:
+			if k, v, ok := strings.Cut(l, "="); ok && strings.TrimSpace(k) == key {
+				return strings.TrimSpace(v)
+			}
+		}
+	}
+	return ""
+}
+
+// formatPLMN returns the PLMN of an MCC and MNC as MCC-MNC.
+func formatPLMN(mcc, mnc string) (string, error) {
+	mcc, mnc = strings.TrimSpace(mcc), strings.TrimSpace(mnc)
+	if len(mcc) != 3 || !digits(mcc) || len(mnc) < 2 || len(mnc) > 3 || !digits(mnc) {
+		return "", fmt.Errorf("invalid PLMN: MCC %q, MNC %q", mcc, mnc)
+	}
+	return mcc + "-" + mnc, nil
+}
+
+// splitPLMN returns a PLMN written as MCC and MNC together, e.g. 00101,
+// as MCC-MNC.
+func splitPLMN(plmn string) (string, error) {
+	plmn = strings.TrimSpace(plmn)
+	if len(plmn) < 5 {
+		return "", fmt.Errorf("invalid PLMN %q", plmn)
+	}
+	return formatPLMN(plmn[:3], plmn[3:])
+}
+
+func digits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+// parseNumber parses a decimal or 0x-prefixed hex number, as TACs are
+// written.
+func parseNumber(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	base := 10
+	if h, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		s, base = h, 16
+	}
+	v, err := strconv.ParseUint(s, base, 32)
+	return uint32(v), err
+}
+
+// parseTACs reads the tac of a tai of an Open5GS configuration: a TAC or
+// a list of them.
+func parseTACs(v interface{}) ([]uint32, error) {
+	var raw []interface{}
+	if l, ok := v.([]interface{}); ok {
+		raw = l
+	} else {
+		raw = []interface{}{v}
+	}
+	out := make([]uint32, 0, len(raw))
+	for _, r := range raw {
+		if r == nil {
+			return nil, errors.New("no tac")
+		}
+		tac, err := parseNumber(fmt.Sprint(r))
+		if err != nil {
+			return nil, fmt.Errorf("tac: %w", err)
+		}
+		out = append(out, tac)
+	}
+	return out, nil
+}
+
+// appendSlices appends the S-NSSAIs of plmn to out, their SDs as six
+// lowercase hex digits. An SD of ffffff is no SD.
+func appendSlices(out []Slice, plmn string, list []sNSSAI) ([]Slice, error) {
+	for _, s := range list {
+		sst, err := strconv.Atoi(strings.TrimSpace(s.SST))
+		if err != nil {
+			return out, fmt.Errorf("invalid SST %q", s.SST)
+		}
+		sd := ""
+		if raw := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s.SD)), "0x"); raw != "" {
+			v, err := strconv.ParseUint(raw, 16, 24)
+			if err != nil {
+				return out, fmt.Errorf("invalid SD %q", s.SD)
+			}
+			if v != 0xffffff {
+				sd = fmt.Sprintf("%06x", v)
+			}
+		}
+		if sl := (Slice{PLMN: plmn, SST: sst, SD: sd}); !slices.Contains(out, sl) {
+			out = append(out, sl)
+		}
+	}
+	return out, nil
+}
+
+func appendTAI(out []TAI, t TAI) []TAI {
+	if slices.Contains(out, t) {
+		return out
+	}
+	return append(out, t)
+}
+
+func lines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
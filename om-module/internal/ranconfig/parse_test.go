@@ -0,0 +1,243 @@
+package ranconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+const amfConfig = `
+amf:
+  ngap:
+    server:
+      - address: 172.22.0.10
+  tai:
+    - plmn_id:
+        mcc: 001
+        mnc: 01
+      tac: [1, 0x3]
+  plmn_support:
+    - plmn_id:
+        mcc: 001
+        mnc: 01
+      s_nssai:
+        - sst: 1
+        - sst: 1
+          sd: 000010
+        - sst: 2
+          sd: 0xffffff
+`
+
+const srsranConfig5G = `
+cu_cp:
+  amf:
+    addr: 172.22.0.10
+    supported_tracking_areas:
+      - tac: 1
+        plmn_list:
+          - plmn: "00101"
+            tai_slice_support_list:
+              - sst: 1
+                sd: 0x000010
+cell_cfg:
+  plmn: "00101"
+  tac: 2
+`
+
+const ueransimConfig = `
+mcc: '001'
+mnc: '01'
+tac: 1
+amfConfigs:
+  - address: amf
+    port: 38412
+slices:
+  - sst: 1
+    sd: 0x000001
+`
+
+const srslteENB = `
+[enb_files]
+rr_config = /etc/srsran/rr.conf
+
+[enb]
+# mcc = 999
+mcc = 001
+mnc = 01
+mme_addr = 172.22.0.9
+`
+
+const srslteRR = `
+cell_list =
+(
+  {
+    cell_id = 0x01;
+    tac = 0x0001;
+  },
+  {
+    cell_id = 0x02;
+    tac = 7;
+  }
+);
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  string
+		parse func(n *Node) error
+		want  Node
+	}{
+		{
+			name:  "open5gs amf",
+			kind:  "amf",
+			parse: func(n *Node) error { return parseOpen5GS([]byte(amfConfig), n) },
+			want: Node{
+				Addresses: []string{"172.22.0.10"},
+				TAIs:      []TAI{{"001-01", 1}, {"001-01", 3}},
+				Slices:    []Slice{{"001-01", 1, ""}, {"001-01", 1, "000010"}, {"001-01", 2, ""}},
+			},
+		},
+		{
+			name:  "srsran gnb",
+			kind:  "gnb",
+			parse: func(n *Node) error { return parseSRSRAN([]byte(srsranConfig5G), n) },
+			want: Node{
+				Addresses: []string{"172.22.0.10"},
+				TAIs:      []TAI{{"001-01", 1}, {"001-01", 2}},
+				Slices:    []Slice{{"001-01", 1, "000010"}},
+			},
+		},
+		{
+			name:  "ueransim gnb",
+			kind:  "gnb",
+			parse: func(n *Node) error { return parseUERANSIM([]byte(ueransimConfig), n) },
+			want: Node{
+				Addresses: []string{"amf"},
+				TAIs:      []TAI{{"001-01", 1}},
+				Slices:    []Slice{{"001-01", 1, "000001"}},
+			},
+		},
+		{
+			name:  "srslte enb",
+			kind:  "enb",
+			parse: func(n *Node) error { return parseSRSLTE(srslteENB, srslteRR, n) },
+			want: Node{
+				Addresses: []string{"172.22.0.9"},
+				TAIs:      []TAI{{"001-01", 1}, {"001-01", 7}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := Node{Kind: tt.kind}
+			if err := tt.parse(&n); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(n.Addresses, tt.want.Addresses) {
+				t.Errorf("addresses %v, want %v", n.Addresses, tt.want.Addresses)
+			}
+			if !reflect.DeepEqual(n.TAIs, tt.want.TAIs) {
+				t.Errorf("TAIs %v, want %v", n.TAIs, tt.want.TAIs)
+			}
+			if !reflect.DeepEqual(n.Slices, tt.want.Slices) {
+				t.Errorf("slices %v, want %v", n.Slices, tt.want.Slices)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(n *Node) error
+	}{
+		{"no mme section", func(n *Node) error { n.Kind = "mme"; return parseOpen5GS([]byte(amfConfig), n) }},
+		{"mnc of one digit", func(n *Node) error { return parseUERANSIM([]byte("mcc: '001'\nmnc: '1'\ntac: 1\n"), n) }},
+		{"tac not a number", func(n *Node) error { return parseUERANSIM([]byte("mcc: '001'\nmnc: '01'\ntac: x\n"), n) }},
+		{"short plmn", func(n *Node) error { return parseSRSRAN([]byte("cell_cfg:\n  plmn: \"0010\"\n  tac: 1\n"), n) }},
+		{"sd too long", func(n *Node) error {
+			return parseUERANSIM([]byte("mcc: '001'\nmnc: '01'\ntac: 1\nslices:\n  - sst: 1\n    sd: 0x1000000\n"), n)
+		}},
+		{"no rr tac", func(n *Node) error { return parseSRSLTE(srslteENB, "cell_list = ();", n) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.parse(&Node{Kind: "gnb"}); err == nil {
+				t.Error("parsed without an error")
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32
+		err  bool
+	}{
+		{"1", 1, false},
+		{" 7 ", 7, false},
+		{"0x0001", 1, false},
+		{"0X1F", 31, false},
+		{"16777215", 16777215, false},
+		{"", 0, true},
+		{"-1", 0, true},
+		{"0x", 0, true},
+		{"4294967296", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseNumber(tt.in)
+		if (err != nil) != tt.err || (!tt.err && got != tt.want) {
+			t.Errorf("parseNumber(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	amf := Node{Container: "amf", Generation: "5g", Addresses: []string{"172.22.0.10"},
+		TAIs: []TAI{{"001-01", 1}}, Slices: []Slice{{"001-01", 1, ""}, {"001-01", 1, "000001"}}}
+	tests := []struct {
+		name   string
+		gnb    Node
+		checks []string
+	}{
+		{
+			name: "consistent",
+			gnb:  Node{Generation: "5g", Addresses: []string{"172.22.0.10"}, TAIs: []TAI{{"001-01", 1}}, Slices: []Slice{{"001-01", 1, "000001"}}},
+		},
+		{
+			name: "by container name",
+			gnb:  Node{Generation: "5g", Addresses: []string{"amf"}, TAIs: []TAI{{"001-01", 1}}},
+		},
+		{
+			name:   "wrong address",
+			gnb:    Node{Generation: "5g", Addresses: []string{"172.22.0.11"}, TAIs: []TAI{{"001-01", 1}}},
+			checks: []string{CheckCoreAddress},
+		},
+		{
+			name:   "wrong plmn",
+			gnb:    Node{Generation: "5g", Addresses: []string{"amf"}, TAIs: []TAI{{"001-02", 1}}, Slices: []Slice{{"001-02", 1, "000009"}}},
+			checks: []string{CheckPLMN},
+		},
+		{
+			name:   "wrong tac and slice",
+			gnb:    Node{Generation: "5g", Addresses: []string{"amf"}, TAIs: []TAI{{"001-01", 2}}, Slices: []Slice{{"001-01", 1, "000002"}}},
+			checks: []string{CheckTAC, CheckSlice},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := compare(tt.gnb, []Node{amf})
+			var got []string
+			for _, m := range r.Mismatches {
+				got = append(got, m.Check)
+				if m.Hint == "" {
+					t.Errorf("%s mismatch without a hint", m.Check)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.checks) || r.Consistent != (len(tt.checks) == 0) {
+				t.Errorf("mismatches %v (consistent %t), want %v", got, r.Consistent, tt.checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+// Package ranconfig checks that the RAN of the lab is configured for the
+// core it connects to. A gNB whose PLMN, TAC or S-NSSAI the AMF does not
+// serve is refused at NG Setup, and one pointed at another address never
+// reaches the AMF at all: the radio side looks up, the core never lists
+// the gNB, and the student is left with "the gNB won't connect". The same
+// holds for an eNB and the MME at S1 Setup.
+//
+// The Checker reads, every interval, the configuration the running RAN
+// containers were rendered with (srsRAN and UERANSIM gNBs, srsLTE eNBs) and
+// the one the running AMFs and MMEs started with, and reports every value
+// of a RAN node the core of its generation does not have.
+package ranconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/logaudit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const networkName = "docker_open5gs_default"
+
+// om.project labels of the RAN containers the checker reads.
+const (
+	ProjectSRSRAN   = "srsran"
+	ProjectUERANSIM = "ueransim"
+	ProjectSRSLTE   = "srslte"
+)
+
+// Checks a RAN node is compared on.
+const (
+	CheckCoreAddress = "core_address"
+	CheckPLMN        = "plmn"
+	CheckTAC         = "tac"
+	CheckSlice       = "slice"
+)
+
+// Checks lists the checks, in the order mismatches are reported.
+var Checks = []string{CheckCoreAddress, CheckPLMN, CheckTAC, CheckSlice}
+
+// hints tell where each value is set in the lab. The init scripts of the
+// RAN and core containers substitute the same variables of .env, so a
+// mismatch usually comes from a configuration edited by hand or a
+// container not recreated after .env changed.
+var hints = map[string]string{
+	CheckCoreAddress: "The RAN init scripts set the core address from AMF_IP (MME_IP in 4G) in .env; recreate the RAN container after changing it.",
+	CheckPLMN:        "MCC and MNC in .env set the PLMN of both sides; check the plmn_id of the tai section of the core configuration and recreate both containers.",
+	CheckTAC:         "TAC in .env sets the tracking area of both sides; add the TAC to the tai section of the core configuration or change the one of the RAN node.",
+	CheckSlice:       "The S-NSSAIs of the gNB must be among the s_nssai of the plmn_support section of the AMF configuration; an SD is compared as hex.",
+}
+
+// marker separates the parts of the output of procScript.
+const marker = "--om--"
+
+// procScript prints the command line and working directory of PID 1.
+const procScript = `tr '\0' '\n' </proc/1/cmdline; echo ` + marker + `
+readlink /proc/1/cwd`
+
+// TAI is a tracking area: a PLMN and a TAC.
+type TAI struct {
+	PLMN string `json:"plmn"` // MCC-MNC, e.g. 001-01
+	TAC  uint32 `json:"tac"`
+}
+
+func (t TAI) String() string { return fmt.Sprintf("%s/%d", t.PLMN, t.TAC) }
+
+// Slice is an S-NSSAI served in a PLMN. SD is six lowercase hex digits, ""
+// when the slice has none.
+type Slice struct {
+	PLMN string `json:"plmn"`
+	SST  int    `json:"sst"`
+	SD   string `json:"sd,omitempty"`
+}
+
+func (s Slice) String() string {
+	if s.SD == "" {
+		return fmt.Sprintf("SST %d", s.SST)
+	}
+	return fmt.Sprintf("SST %d SD %s", s.SST, s.SD)
+}
+
+// Node is the configuration of one RAN node or core NF, as read from its
+// container.
+type Node struct {
+	Container  string `json:"container"`
+	Kind       string `json:"kind"` // gnb | enb | amf | mme
+	Project    string `json:"project"`
+	Generation string `json:"generation"`
+	Config     string `json:"config,omitempty"`
+	// Addresses are, for an AMF or MME, where it serves NGAP (S1AP): the
+	// addresses of its configuration and its IP on the lab network. For a
+	// RAN node they are the core addresses it connects to.
+	Addresses []string `json:"addresses"`
+	TAIs      []TAI    `json:"tais"`
+	Slices    []Slice  `json:"slices,omitempty"`
+	// Error is set when the configuration could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// Mismatch is a value of a RAN node the core does not have.
+type Mismatch struct {
+	Check   string   `json:"check"`
+	Got     string   `json:"got"`
+	Want    []string `json:"want"`
+	Message string   `json:"message"`
+	Hint    string   `json:"hint"`
+}
+
+// RAN is the last check of one RAN node.
+type RAN struct {
+	Node
+	// Core is the AMF (MME) the node connects to, "" when no running one
+	// serves the address.
+	Core       string     `json:"core,omitempty"`
+	Consistent bool       `json:"consistent"`
+	Mismatches []Mismatch `json:"mismatches"`
+	CheckedAt  string     `json:"checked_at"`
+}
+
+// Status is the API view of the checker.
+type Status struct {
+	Interval     string `json:"interval"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+	Consistent   int    `json:"consistent"`
+	Inconsistent int    `json:"inconsistent"`
+	RAN          []RAN  `json:"ran"`
+	Core         []Node `json:"core"`
+}
+
+// Checker compares the configuration of the RAN nodes with the core's.
+type Checker struct {
+	docker   *dockerclient.Client
+	snap     *collector.Snapshot
+	interval time.Duration
+
+	consistent *prometheus.GaugeVec
+	mismatch   *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	ran     map[string]RAN // keyed by container name
+	core    []Node
+	checked time.Time
+}
+
+// New registers om_ran_config_consistent and om_ran_config_mismatch on reg.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, interval time.Duration) *Checker {
+	c := &Checker{
+		docker:   docker,
+		snap:     snap,
+		interval: interval,
+		consistent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ran_config", Name: "consistent",
+			Help: "1 if the PLMN, TAC, S-NSSAI and core address of the RAN node match the configuration of its core, 0 otherwise.",
+		}, []string{"container", "generation"}),
+		mismatch: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "ran_config", Name: "mismatch",
+			Help: "1 if the RAN node has a value of the check (core_address|plmn|tac|slice) its core does not, 0 otherwise.",
+		}, []string{"container", "generation", "check"}),
+		ran: make(map[string]RAN),
+	}
+	reg.MustRegister(c.consistent, c.mismatch)
+	return c
+}
+
+// Run checks the RAN every interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the last check of every RAN node, the inconsistent ones
+// first, and the core NFs they were compared with.
+func (c *Checker) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st := Status{
+		Interval: c.interval.String(),
+		RAN:      make([]RAN, 0, len(c.ran)),
+		Core:     slices.Clone(c.core),
+	}
+	if st.Core == nil {
+		st.Core = []Node{}
+	}
+	if !c.checked.IsZero() {
+		st.UpdatedAt = c.checked.UTC().Format(time.RFC3339)
+	}
+	for _, r := range c.ran {
+		switch {
+		case r.Error != "":
+		case r.Consistent:
+			st.Consistent++
+		default:
+			st.Inconsistent++
+		}
+		st.RAN = append(st.RAN, r)
+	}
+	sort.Slice(st.RAN, func(i, j int) bool {
+		ri, rj := st.RAN[i], st.RAN[j]
+		if ri.Consistent != rj.Consistent {
+			return !ri.Consistent
+		}
+		return ri.Container < rj.Container
+	})
+	return st
+}
+
+// Freshness returns when om_ran_config_consistent was last refreshed, for
+// exporter.Ages.
+func (c *Checker) Freshness() map[string]time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.ran) == 0 || c.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_ran_config_consistent": c.checked}
+}
+
+func (c *Checker) check(ctx context.Context) {
+	var ranTargets, coreTargets []*collector.ContainerData
+	for _, cd := range c.snap.All() {
+		if cd.State != "running" {
+			continue
+		}
+		switch {
+		case cd.NFKind == collector.KindGNB || cd.NFKind == collector.KindENB:
+			ranTargets = append(ranTargets, cd)
+		case (cd.NFKind == collector.KindAMF || cd.NFKind == collector.KindMME) && cd.Project == logaudit.ProjectOpen5GS:
+			coreTargets = append(coreTargets, cd)
+		}
+	}
+
+	var ips map[string]string // container name → IP
+	if len(ranTargets) > 0 && len(coreTargets) > 0 {
+		if ipToName, err := c.docker.GetNetworkContainerIPs(ctx, networkName); err == nil {
+			ips = make(map[string]string, len(ipToName))
+			for ip, name := range ipToName {
+				ips[name] = ip
+			}
+		}
+	}
+	cores := make([]Node, 0, len(coreTargets))
+	for _, cd := range coreTargets {
+		cores = append(cores, c.readCore(ctx, cd, ips[cd.Name]))
+	}
+	sort.Slice(cores, func(i, j int) bool { return cores[i].Container < cores[j].Container })
+	checked := make(map[string]RAN, len(ranTargets))
+	for _, cd := range ranTargets {
+		n := c.readRAN(ctx, cd)
+		if ctx.Err() != nil {
+			return
+		}
+		checked[cd.Name] = compare(n, cores)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, prev := range c.ran {
+		if _, ok := checked[name]; !ok || checked[name].Generation != prev.Generation {
+			c.forget(prev)
+		}
+	}
+	for name, r := range checked {
+		if r.Error != "" {
+			c.forget(r)
+			continue
+		}
+		if prev, ok := c.ran[name]; !r.Consistent && (!ok || prev.Consistent || prev.Error != "") {
+			msgs := make([]string, len(r.Mismatches))
+			for i, m := range r.Mismatches {
+				msgs[i] = m.Message
+			}
+			log.Printf("⚠️  %s is not configured for its core: %s", name, strings.Join(msgs, "; "))
+		}
+		c.consistent.WithLabelValues(name, r.Generation).Set(boolValue(r.Consistent))
+		for _, check := range Checks {
+			found := slices.ContainsFunc(r.Mismatches, func(m Mismatch) bool { return m.Check == check })
+			c.mismatch.WithLabelValues(name, r.Generation, check).Set(boolValue(found))
+		}
+	}
+	c.ran = checked
+	c.core = cores
+	c.checked = time.Now()
+}
+
+func (c *Checker) forget(r RAN) {
+	c.consistent.DeleteLabelValues(r.Container, r.Generation)
+	for _, check := range Checks {
+		c.mismatch.DeleteLabelValues(r.Container, r.Generation, check)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// compare checks a RAN node against the core NFs of its generation.
+func compare(n Node, cores []Node) RAN {
+	r := RAN{Node: n, Mismatches: []Mismatch{}, CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+	if r.Error != "" {
+		return r
+	}
+	coreName, iface, setup := "AMF", "NGAP", "NG Setup"
+	if n.Generation == "4g" {
+		coreName, iface, setup = "MME", "S1AP", "S1 Setup"
+	}
+	var candidates []Node
+	for _, core := range cores {
+		if core.Generation == n.Generation && core.Error == "" {
+			candidates = append(candidates, core)
+		}
+	}
+	if len(candidates) == 0 {
+		r.Error = fmt.Sprintf("no running %s to compare with", coreName)
+		return r
+	}
+
+	// The node is compared with the core NF at its address; without one,
+	// with all of them, so PLMN and TAC are still checked.
+	var want []string
+	for _, core := range candidates {
+		if r.Core == "" && slices.ContainsFunc(n.Addresses, func(a string) bool { return a == core.Container || slices.Contains(core.Addresses, a) }) {
+			r.Core = core.Container
+			candidates = []Node{core}
+			break
+		}
+		want = append(want, core.Addresses...)
+	}
+	if r.Core == "" {
+		r.Mismatches = append(r.Mismatches, Mismatch{
+			Check: CheckCoreAddress, Got: strings.Join(n.Addresses, ", "), Want: distinct(want),
+			Message: fmt.Sprintf("connects to the %s at %s, but no running %s serves %s there (%s): the %s is never answered",
+				coreName, orNone(n.Addresses), coreName, iface, orNone(distinct(want)), setup),
+		})
+	}
+
+	var tais []TAI
+	var coreSlices []Slice
+	for _, core := range candidates {
+		tais = append(tais, core.TAIs...)
+		coreSlices = append(coreSlices, core.Slices...)
+	}
+	plmns := make([]string, 0, len(tais))
+	for _, t := range tais {
+		plmns = append(plmns, t.PLMN)
+	}
+	plmns = distinct(plmns)
+	reported := make(map[string]bool)
+	for _, t := range n.TAIs {
+		switch {
+		case !slices.Contains(plmns, t.PLMN):
+			if !reported[t.PLMN] {
+				reported[t.PLMN] = true
+				r.Mismatches = append(r.Mismatches, Mismatch{
+					Check: CheckPLMN, Got: t.PLMN, Want: plmns,
+					Message: fmt.Sprintf("serves PLMN %s, which the %s does not (%s): the %s is rejected", t.PLMN, coreName, strings.Join(plmns, ", "), setup),
+				})
+			}
+		case !slices.Contains(tais, t):
+			var tacs []string
+			for _, ct := range tais {
+				if ct.PLMN == t.PLMN {
+					tacs = append(tacs, strconv.FormatUint(uint64(ct.TAC), 10))
+				}
+			}
+			tacs = distinct(tacs)
+			r.Mismatches = append(r.Mismatches, Mismatch{
+				Check: CheckTAC, Got: strconv.FormatUint(uint64(t.TAC), 10), Want: tacs,
+				Message: fmt.Sprintf("serves TAC %d in PLMN %s, which is not in the tracking areas of the %s (TAC %s): the %s is rejected",
+					t.TAC, t.PLMN, coreName, strings.Join(tacs, ", "), setup),
+			})
+		}
+	}
+	for _, s := range n.Slices {
+		if !slices.Contains(plmns, s.PLMN) || slices.ContainsFunc(coreSlices, func(cs Slice) bool { return cs == s }) {
+			continue
+		}
+		var served []string
+		for _, cs := range coreSlices {
+			if cs.PLMN == s.PLMN {
+				served = append(served, cs.String())
+			}
+		}
+		r.Mismatches = append(r.Mismatches, Mismatch{
+			Check: CheckSlice, Got: s.String(), Want: served,
+			Message: fmt.Sprintf("supports the S-NSSAI %s in PLMN %s, which the %s does not (%s): the %s is rejected",
+				s, s.PLMN, coreName, orNone(served), setup),
+		})
+	}
+	for i := range r.Mismatches {
+		r.Mismatches[i].Hint = hints[r.Mismatches[i].Check]
+		if r.Mismatches[i].Want == nil {
+			r.Mismatches[i].Want = []string{}
+		}
+	}
+	r.Consistent = len(r.Mismatches) == 0
+	return r
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+func distinct(values []string) []string {
+	out := slices.Clone(values)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// readCore reads the configuration of an AMF or MME container: the file
+// its Open5GS daemon, PID 1, was started with. ip is its IP on the lab
+// network, "" when unknown.
+func (c *Checker) readCore(ctx context.Context, cd *collector.ContainerData, ip string) Node {
+	n := Node{Container: cd.Name, Kind: string(cd.NFKind), Project: cd.Project, Generation: cd.NFKind.Generation(), Addresses: []string{}, TAIs: []TAI{}}
+	out, _, err := c.docker.Exec(ctx, cd.Name, []string{"sh", "-c", procScript})
+	parts := strings.Split(out, marker+"\n")
+	if err != nil || len(parts) != 2 {
+		if err == nil {
+			err = fmt.Errorf("unexpected output %q", out)
+		}
+		n.Error = fmt.Sprintf("inspect PID 1: %v", err)
+		return n
+	}
+	config, ok := logaudit.ConfigPath(lines(parts[0]), strings.TrimSpace(parts[1]))
+	if !ok {
+		n.Error = "PID 1 is not an Open5GS daemon"
+		return n
+	}
+	n.Config = config
+	data, code, err := c.docker.Exec(ctx, cd.Name, []string{"cat", config})
+	if err != nil || code != 0 {
+		n.Error = fmt.Sprintf("read %s: exit %d, %v", config, code, err)
+		return n
+	}
+	if err := parseOpen5GS([]byte(data), &n); err != nil {
+		n.Error = fmt.Sprintf("%s: %v", config, err)
+	}
+	if ip != "" && !slices.Contains(n.Addresses, ip) {
+		n.Addresses = append(n.Addresses, ip)
+	}
+	return n
+}
@@ -0,0 +1,228 @@
+// Package readiness orders the module's startup after the services it
+// depends on: Docker, Loki, Prometheus and Grafana are polled until they
+// answer (or a deadline passes) before the subsystems that use them are
+// enabled, so a `docker compose up` of the whole stack no longer races them.
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Dependency states.
+const (
+	StateWaiting     = "waiting"
+	StateReady       = "ready"
+	StateUnavailable = "unavailable" // deadline passed; its subsystems are off
+	StateSkipped     = "skipped"     // not waited for; its subsystems start anyway
+)
+
+// pollInterval is the pause between two checks of a dependency that is not
+// ready yet; each check is bounded by checkTimeout.
+const (
+	pollInterval = 2 * time.Second
+	checkTimeout = 5 * time.Second
+)
+
+// Dependency is a service to wait for.
+type Dependency struct {
+	Name       string
+	Target     string                          // URL or socket, for the status
+	Check      func(ctx context.Context) error // nil error means ready
+	Skip       bool
+	Subsystems []string // what is enabled only when the dependency is ready
+}
+
+// Status is the startup state of one dependency.
+type Status struct {
+	Name          string   `json:"name"`
+	Target        string   `json:"target"`
+	State         string   `json:"state"`
+	Subsystems    []string `json:"subsystems"`
+	WaitedSeconds float64  `json:"waited_seconds"`
+	// Late is set when the dependency became ready after the deadline; its
+	// subsystems stay off until the module is restarted.
+	Late  bool   `json:"late,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report holds the outcome of the readiness phase and keeps checking the
+// dependencies that missed the deadline.
+type Report struct {
+	deps  []Dependency
+	start time.Time
+	ready *prometheus.GaugeVec
+
+	mu     sync.RWMutex
+	status []Status
+}
+
+// HTTPCheck returns a check that passes when GET url answers 200.
+func HTTPCheck(url string) func(ctx context.Context) error {
+	client := httpclient.New("readiness", 0)
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// Wait checks every dependency concurrently until it is ready or timeout
+// has passed, and returns the report. The om_dependency_ready metric is
+// registered on reg.
+func Wait(ctx context.Context, reg prometheus.Registerer, timeout time.Duration, deps []Dependency) *Report {
+	r := &Report{
+		deps:  deps,
+		start: time.Now(),
+		ready: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "dependency", Name: "ready",
+			Help: "1 if the dependency answered its readiness check, 0 otherwise. Skipped dependencies are not exported.",
+		}, []string{"dependency"}),
+		status: make([]Status, len(deps)),
+	}
+	reg.MustRegister(r.ready)
+
+	var wg sync.WaitGroup
+	for i, d := range deps {
+		r.status[i] = Status{Name: d.Name, Target: d.Target, State: StateWaiting, Subsystems: d.Subsystems}
+		if d.Subsystems == nil {
+			r.status[i].Subsystems = []string{}
+		}
+		if d.Skip {
+			r.status[i].State = StateSkipped
+			log.Printf("⚠️  Not waiting for %s (skipped)", d.Name)
+			continue
+		}
+		r.ready.WithLabelValues(d.Name).Set(0)
+		wg.Add(1)
+		go func(i int, d Dependency) {
+			defer wg.Done()
+			waitCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			r.poll(waitCtx, i, d)
+		}(i, d)
+	}
+	wg.Wait()
+
+	for _, s := range r.Status() {
+		switch {
+		case s.State == StateReady:
+			log.Printf("✅ %s ready (%.1fs)", s.Name, s.WaitedSeconds)
+		case s.State == StateUnavailable && len(s.Subsystems) > 0:
+			log.Printf("⚠️  %s not ready after %s (%s) — starting without: %s",
+				s.Name, timeout, s.Error, strings.Join(s.Subsystems, ", "))
+		case s.State == StateUnavailable:
+			log.Printf("⚠️  %s not ready after %s (%s)", s.Name, timeout, s.Error)
+		}
+	}
+	return r
+}
+
+// poll checks d until it is ready or ctx ends, and records the outcome.
+func (r *Report) poll(ctx context.Context, i int, d Dependency) bool {
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := d.Check(checkCtx)
+		cancel()
+
+		r.mu.Lock()
+		s := &r.status[i]
+		if err == nil {
+			late := s.State == StateUnavailable
+			s.State, s.Late, s.Error = StateReady, late, ""
+			s.WaitedSeconds = time.Since(r.start).Seconds()
+			r.mu.Unlock()
+			r.ready.WithLabelValues(d.Name).Set(1)
+			return true
+		}
+		s.Error = err.Error()
+		r.mu.Unlock()
+
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			r.mu.Lock()
+			if r.status[i].State == StateWaiting {
+				r.status[i].State = StateUnavailable
+				r.status[i].WaitedSeconds = time.Since(r.start).Seconds()
+			}
+			r.mu.Unlock()
+			return false
+		}
+	}
+}
+
+// Run keeps checking the dependencies that missed the deadline, so the
+// status shows when they come up. It returns once they are all ready, or
+// when ctx is cancelled: start it as a task, not a subsystem.
+func (r *Report) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, s := range r.Status() {
+		if s.State != StateUnavailable {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, d Dependency) {
+			defer wg.Done()
+			if !r.poll(ctx, i, d) {
+				return
+			}
+			if len(d.Subsystems) == 0 {
+				log.Printf("✅ %s ready after the startup deadline", d.Name)
+				return
+			}
+			log.Printf("✅ %s ready after the startup deadline — restart the module to enable: %s",
+				d.Name, strings.Join(d.Subsystems, ", "))
+		}(i, r.deps[i])
+	}
+	wg.Wait()
+}
+
+// Ready reports whether the subsystems depending on name may be enabled:
+// the dependency was ready at startup, skipped, or not configured at all.
+func (r *Report) Ready(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.status {
+		if s.Name == name {
+			return s.State == StateReady && !s.Late || s.State == StateSkipped
+		}
+	}
+	return true
+}
+
+// Partial reports whether the module started with subsystems disabled
+// because a dependency was not ready in time.
+func (r *Report) Partial() bool {
+	for _, s := range r.Status() {
+		if s.State == StateUnavailable || s.Late {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns a copy of the dependency states, in startup order.
+func (r *Report) Status() []Status {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Status(nil), r.status...)
+}
@@ -0,0 +1,271 @@
+// Package redact removes secrets and personal data from upstream log lines
+// before the module hands them out: incident reviews, the NEF invocations
+// of /exposure and the module log of debug bundles. The rules come from a
+// YAML file (REDACTION_FILE), each either a regular expression or the name
+// of a field whose value is replaced wherever the line writes it as
+// field=value, field: value, "field": "value" or field[value]:
+//
+//	rules:
+//	  - name: msisdn
+//	    field: msisdn
+//	  - name: email
+//	    pattern: '[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}'
+//	  - name: bearer
+//	    pattern: '(?i)bearer\s+([A-Za-z0-9._~+/=-]+)'
+//
+// A pattern with a group replaces the first group only, so the words
+// around a secret stay readable. In dry-run mode lines pass unchanged and
+// the redactor only counts, and shows, what it would have replaced.
+package redact
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+// maxExamples bounds the dry-run examples kept per rule.
+const maxExamples = 5
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Rule is one redaction rule of the file.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern,omitempty"`
+	Field   string `yaml:"field,omitempty"`
+	// Replacement defaults to <redacted:name>.
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
+// File is the redaction file.
+type File struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Load reads and checks the redaction file at p.
+func Load(p string) (*File, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", p)
+	}
+	return f, nil
+}
+
+type compiled struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// fieldRe matches the value of field in the usual key-value notations of
+// log lines; group 1 is the value.
+func fieldRe(field string) string {
+	k := regexp.QuoteMeta(field)
+	return `(?i)(?:\b` + k + `"?\s*[:=]\s*"?|\b` + k + `\[)([^\s"',[\]{}&;]+)`
+}
+
+// Example is a dry-run hit: what a rule would have replaced, in its line.
+type Example struct {
+	Match string `json:"match"`
+	Line  string `json:"line"`
+}
+
+// RuleStatus is the API view of one rule.
+type RuleStatus struct {
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"` // pattern | field
+	Match    string    `json:"match"`
+	Hits     uint64    `json:"hits"`
+	Examples []Example `json:"examples,omitempty"` // dry run only, newest last
+}
+
+// Status is the API view of the redactor.
+type Status struct {
+	DryRun   bool         `json:"dry_run"`
+	Lines    uint64       `json:"lines"`    // lines checked
+	Redacted uint64       `json:"redacted"` // lines with at least one hit
+	Rules    []RuleStatus `json:"rules"`
+}
+
+// Redactor applies the rules of a file. A nil Redactor leaves lines as
+// they are, so callers need not check whether redaction is enabled.
+type Redactor struct {
+	rules  []compiled
+	dryRun bool
+	hits   *prometheus.CounterVec
+
+	mu       sync.Mutex
+	lines    uint64
+	redacted uint64
+	counts   map[string]uint64
+	examples map[string][]Example
+}
+
+// New compiles the rules of f and registers om_log_redactions_total on reg.
+func New(reg prometheus.Registerer, f *File, dryRun bool) (*Redactor, error) {
+	r := &Redactor{
+		dryRun:   dryRun,
+		counts:   make(map[string]uint64),
+		examples: make(map[string][]Example),
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "log", Name: "redactions_total",
+			Help: "Values replaced by each redaction rule in log lines the module hands out; mode dry_run counts what would have been replaced.",
+		}, []string{"rule", "mode"}),
+	}
+	seen := make(map[string]bool)
+	for i, rule := range f.Rules {
+		if !nameRe.MatchString(rule.Name) || seen[rule.Name] {
+			return nil, fmt.Errorf("rule %d: name %q must be unique lower-case letters, digits and _", i+1, rule.Name)
+		}
+		seen[rule.Name] = true
+		expr := rule.Pattern
+		switch {
+		case rule.Pattern != "" && rule.Field != "":
+			return nil, fmt.Errorf("rule %s: pattern and field are exclusive", rule.Name)
+		case rule.Field != "":
+			expr = fieldRe(rule.Field)
+		case rule.Pattern == "":
+			return nil, fmt.Errorf("rule %s: needs a pattern or a field", rule.Name)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "<redacted:" + rule.Name + ">"
+		}
+		r.rules = append(r.rules, compiled{Rule: rule, re: re})
+	}
+	reg.MustRegister(r.hits)
+	return r, nil
+}
+
+// Line returns line with every rule applied, or line itself in dry-run
+// mode. Hits are counted either way.
+func (r *Redactor) Line(line string) string {
+	if r == nil {
+		return line
+	}
+	out := line
+	hit := false
+	for _, c := range r.rules {
+		matches := c.re.FindAllStringSubmatchIndex(out, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		hit = true
+		r.count(c, out, matches)
+		if !r.dryRun {
+			out = replace(out, c.Replacement, matches)
+		}
+	}
+	r.mu.Lock()
+	r.lines++
+	if hit {
+		r.redacted++
+	}
+	r.mu.Unlock()
+	return out
+}
+
+// Text applies Line to every line of text.
+func (r *Redactor) Text(text []byte) []byte {
+	if r == nil {
+		return text
+	}
+	lines := strings.SplitAfter(string(text), "\n")
+	for i, l := range lines {
+		body := strings.TrimSuffix(l, "\n")
+		if body != "" {
+			lines[i] = r.Line(body) + l[len(body):]
+		}
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+// replace substitutes repl for each match, or for its first group when the
+// rule has one.
+func replace(s, repl string, matches [][]int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := span(m)
+		b.WriteString(s[last:start])
+		b.WriteString(repl)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// span is what a match replaces: its first group if it has one that
+// matched, else the whole match.
+func span(m []int) (int, int) {
+	if len(m) >= 4 && m[2] >= 0 {
+		return m[2], m[3]
+	}
+	return m[0], m[1]
+}
+
+func (r *Redactor) count(c compiled, line string, matches [][]int) {
+	mode := "applied"
+	if r.dryRun {
+		mode = "dry_run"
+	}
+	r.hits.WithLabelValues(c.Name, mode).Add(float64(len(matches)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[c.Name] += uint64(len(matches))
+	if r.dryRun {
+		start, end := span(matches[0])
+		ex := append(r.examples[c.Name], Example{Match: line[start:end], Line: line})
+		if len(ex) > maxExamples {
+			ex = ex[len(ex)-maxExamples:]
+		}
+		r.examples[c.Name] = ex
+	}
+}
+
+// Status returns the hits of every rule, in file order.
+func (r *Redactor) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Status{DryRun: r.dryRun, Lines: r.lines, Redacted: r.redacted, Rules: make([]RuleStatus, 0, len(r.rules))}
+	for _, c := range r.rules {
+		rs := RuleStatus{Name: c.Name, Kind: "pattern", Match: c.Pattern, Hits: r.counts[c.Name]}
+		if c.Field != "" {
+			rs.Kind, rs.Match = "field", c.Field
+		}
+		rs.Examples = append([]Example(nil), r.examples[c.Name]...)
+		s.Rules = append(s.Rules, rs)
+	}
+	return s
+}
+
+// Summary describes the rules for the startup log.
+func (r *Redactor) Summary() string {
+	names := make([]string, 0, len(r.rules))
+	for _, c := range r.rules {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	mode := ""
+	if r.dryRun {
+		mode = " (dry run)"
+	}
+	return fmt.Sprintf("%d rules: %s%s", len(names), strings.Join(names, ", "), mode)
+}
@@ -0,0 +1,130 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The rules of redaction.yaml at the root of the module.
+func testFile(t *testing.T) *File {
+	t.Helper()
+	f, err := Load("../../redaction.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestLine(t *testing.T) {
+	r, err := New(prometheus.NewRegistry(), testFile(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, line, want string
+	}{
+		{
+			name: "nothing to redact",
+			line: "[amf] INFO: [imsi-001011234567895] Registration complete",
+			want: "[amf] INFO: [imsi-001011234567895] Registration complete",
+		},
+		{
+			name: "field equals",
+			line: "subscriber k=465B5CE8B199B49FAA5F0A2EE238A6BC opc=E8ED289DEBA952E4283B54E88E6183CA",
+			want: "subscriber k=<redacted:key> opc=<redacted:opc>",
+		},
+		{
+			name: "field colon and json",
+			line: `{"msisdn": "34600000001", "op" : "abc"}`,
+			want: `{"msisdn": "<redacted:msisdn>", "op" : "<redacted:op>"}`,
+		},
+		{
+			name: "field brackets",
+			line: "added subscriber msisdn[34600000001]",
+			want: "added subscriber msisdn[<redacted:msisdn>]",
+		},
+		{
+			name: "field is a whole word",
+			line: "kpi=3 stop=1",
+			want: "kpi=3 stop=1",
+		},
+		{
+			name: "pattern group only",
+			line: "Authorization: Bearer eyJhbGciOi.x-y_z== sent",
+			want: "Authorization: Bearer <redacted:bearer> sent",
+		},
+		{
+			name: "pattern several matches",
+			line: "gpsi msisdn-34600000001 and msisdn-34600000002",
+			want: "gpsi msisdn-<redacted:gpsi_msisdn> and msisdn-<redacted:gpsi_msisdn>",
+		},
+		{
+			name: "whole match",
+			line: "owner ana.lab@example.org",
+			want: "owner <redacted:email>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Line(tt.line); got != tt.want {
+				t.Errorf("Line(%q)\n got %q\nwant %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	r, err := New(prometheus.NewRegistry(), testFile(t), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "k=abc\nplain\nmsisdn-34600000001 msisdn-34600000002\n"
+	if got := string(r.Text([]byte(text))); got != text {
+		t.Errorf("dry run changed the text to %q", got)
+	}
+	s := r.Status()
+	if s.Lines != 3 || s.Redacted != 2 {
+		t.Errorf("lines %d, redacted %d, want 3 and 2", s.Lines, s.Redacted)
+	}
+	hits := make(map[string]RuleStatus)
+	for _, rs := range s.Rules {
+		hits[rs.Name] = rs
+	}
+	if h := hits["key"]; h.Hits != 1 || len(h.Examples) != 1 || h.Examples[0].Match != "abc" {
+		t.Errorf("key rule: %+v", h)
+	}
+	if h := hits["gpsi_msisdn"]; h.Hits != 2 || h.Examples[0].Match != "34600000001" {
+		t.Errorf("gpsi_msisdn rule: %+v", h)
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		err   string
+	}{
+		{"bad name", []Rule{{Name: "Key", Field: "k"}}, "name"},
+		{"duplicate name", []Rule{{Name: "k", Field: "k"}, {Name: "k", Field: "op"}}, "unique"},
+		{"pattern and field", []Rule{{Name: "k", Field: "k", Pattern: "x"}}, "exclusive"},
+		{"neither", []Rule{{Name: "k"}}, "needs a pattern"},
+		{"bad pattern", []Rule{{Name: "k", Pattern: "("}}, "missing closing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(prometheus.NewRegistry(), &File{Rules: tt.rules}, false)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New: %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNilRedactor(t *testing.T) {
+	var r *Redactor
+	if got := r.Line("k=abc"); got != "k=abc" {
+		t.Errorf("nil redactor changed the line to %q", got)
+	}
+}
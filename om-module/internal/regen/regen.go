@@ -0,0 +1,320 @@
+// Package regen schedules the regeneration of files derived from the
+// topology. A compose up starts, restarts and health-checks dozens of
+// containers within a minute, and every one of those changes would rewrite
+// the generated files. The scheduler coalesces triggers, waits until they
+// stop for a quiet period and runs the jobs one at a time from a queue, so
+// each job runs at most once per stabilisation window. A job whose inputs
+// hash to the same value as its last successful run is skipped.
+//
+// Each run is a transaction: the job stages its files in an output.Txn,
+// they are validated, committed together with atomic renames, and the
+// consumer (Grafana, Prometheus) is told to reload. A failure at any step
+// leaves, or puts back, the files of the previous run, and the status
+// names the step that failed.
+package regen
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configure the scheduler.
+type Options struct {
+	// Quiet is how long triggers must stop before a pending job runs.
+	Quiet time.Duration
+	// MaxDelay bounds the wait under continuous churn: a pending job runs
+	// at most MaxDelay after its first trigger. Zero waits for quiet only.
+	MaxDelay time.Duration
+	// Manifest records the files committed by each run.
+	Manifest *output.Manifest
+}
+
+// Steps of a run, as reported in JobStatus.FailedStep.
+const (
+	StepStage    = "stage"
+	StepValidate = "validate"
+	StepCommit   = "commit"
+	StepReload   = "reload"
+)
+
+// Job is one generator.
+type Job struct {
+	Name string
+	// Inputs returns everything the output is generated from. When its
+	// hash equals that of the last successful run the job is skipped. A
+	// nil Inputs, or an error from it, always runs the job.
+	Inputs func() ([]byte, error)
+	// Run stages the output in tx. Nothing it stages is written when it
+	// returns an error.
+	Run func(ctx context.Context, tx *output.Txn) error
+	// Validate checks the staged output before it is committed. Optional.
+	Validate func(tx *output.Txn) error
+	// Reload makes the consumer of the files pick up the committed ones.
+	// When it fails the previous files are restored and Reload is called
+	// once more so the consumer goes back to them. Optional.
+	Reload func(ctx context.Context) error
+}
+
+// JobStatus is the API view of one job.
+type JobStatus struct {
+	Name    string `json:"name"`
+	Pending bool   `json:"pending"`
+	// Triggers counts every trigger; Coalesced those that arrived while
+	// the job was already pending and were merged into that run.
+	Triggers  uint64 `json:"triggers"`
+	Coalesced uint64 `json:"coalesced"`
+	Runs      uint64 `json:"runs"`
+	Skipped   uint64 `json:"skipped"` // inputs unchanged
+	Failures  uint64 `json:"failures"`
+	LastRun   string `json:"last_run,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	// FailedStep is the step of the last run that failed (stage, validate,
+	// commit or reload); empty after a successful run.
+	FailedStep string `json:"failed_step,omitempty"`
+	// RolledBack is set when the last run committed its files but put the
+	// previous ones back because the reload failed.
+	RolledBack bool `json:"rolled_back,omitempty"`
+	// InputsHash is the hash of the inputs of the last successful run.
+	InputsHash string `json:"inputs_hash,omitempty"`
+}
+
+type job struct {
+	Job
+	status       JobStatus
+	firstTrigger time.Time
+	lastTrigger  time.Time
+}
+
+// Scheduler debounces triggers and runs the jobs they concern.
+type Scheduler struct {
+	opts     Options
+	triggers *prometheus.CounterVec
+	runs     *prometheus.CounterVec
+	failures *prometheus.CounterVec
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	wake chan struct{}
+}
+
+// New registers the regeneration counters on reg and returns an empty
+// scheduler.
+func New(reg prometheus.Registerer, opts Options) *Scheduler {
+	s := &Scheduler{
+		opts: opts,
+		triggers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "regen", Name: "triggers_total",
+			Help: "Regeneration triggers per job, including those coalesced into a pending run.",
+		}, []string{"job"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "regen", Name: "runs_total",
+			Help: "Regeneration runs per job and result (run, skipped when the inputs were unchanged, failed).",
+		}, []string{"job", "result"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "regen", Name: "failures_total",
+			Help: "Failed regeneration runs per job and step (stage, validate, commit, reload); the previous files are kept.",
+		}, []string{"job", "step"}),
+		jobs: make(map[string]*job),
+		wake: make(chan struct{}, 1),
+	}
+	reg.MustRegister(s.triggers, s.runs, s.failures)
+	return s
+}
+
+// Add registers a job. Must be called before Run.
+func (s *Scheduler) Add(j Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[j.Name] = &job{Job: j, status: JobStatus{Name: j.Name}}
+}
+
+// Trigger marks the named jobs, or every job when none is named, for
+// regeneration once triggers have been quiet for Options.Quiet. It never
+// blocks.
+func (s *Scheduler) Trigger(names ...string) {
+	now := time.Now()
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		if len(names) > 0 && !contains(names, j.Name) {
+			continue
+		}
+		j.status.Triggers++
+		s.triggers.WithLabelValues(j.Name).Inc()
+		if j.status.Pending {
+			j.status.Coalesced++
+		} else {
+			j.status.Pending = true
+			j.firstTrigger = now
+		}
+		j.lastTrigger = now
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes due jobs until ctx is cancelled. Jobs run one at a time, in
+// name order when several become due together.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		due, wait := s.due(time.Now())
+		for _, j := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			s.execute(ctx, j)
+		}
+		if len(due) > 0 {
+			continue // triggers may have arrived while running
+		}
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-s.wake:
+		case <-timer:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// due dequeues the pending jobs whose quiet period (or maximum delay) has
+// elapsed and returns how long until the next one is due, or 0 if none is
+// pending.
+func (s *Scheduler) due(now time.Time) ([]*job, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var queue []*job
+	var wait time.Duration
+	for _, j := range s.jobs {
+		if !j.status.Pending {
+			continue
+		}
+		at := j.lastTrigger.Add(s.opts.Quiet)
+		if limit := j.firstTrigger.Add(s.opts.MaxDelay); s.opts.MaxDelay > 0 && limit.Before(at) {
+			at = limit
+		}
+		if !at.After(now) {
+			j.status.Pending = false
+			queue = append(queue, j)
+		} else if d := at.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	sort.Slice(queue, func(a, b int) bool { return queue[a].Name < queue[b].Name })
+	return queue, wait
+}
+
+func (s *Scheduler) execute(ctx context.Context, j *job) {
+	var hash string
+	if j.Inputs != nil {
+		if b, err := j.Inputs(); err == nil {
+			sum := sha256.Sum256(b)
+			hash = hex.EncodeToString(sum[:])
+		}
+	}
+
+	s.mu.Lock()
+	unchanged := hash != "" && hash == j.status.InputsHash
+	if unchanged {
+		j.status.Skipped++
+	}
+	s.mu.Unlock()
+	if unchanged {
+		s.runs.WithLabelValues(j.Name, "skipped").Inc()
+		return
+	}
+
+	tx := output.NewTxn()
+	step, rolledBack, err := s.transact(ctx, j, tx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.LastRun = time.Now().UTC().Format(time.RFC3339)
+	j.status.RolledBack = rolledBack
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		j.status.FailedStep = step
+		s.runs.WithLabelValues(j.Name, "failed").Inc()
+		s.failures.WithLabelValues(j.Name, step).Inc()
+		log.Printf("⚠️  Regeneration of %s failed at %s, previous files kept: %v", j.Name, step, err)
+		return
+	}
+	for _, p := range tx.Files() {
+		s.opts.Manifest.Add(p)
+	}
+	j.status.Runs++
+	j.status.LastError = ""
+	j.status.FailedStep = ""
+	j.status.InputsHash = hash
+	s.runs.WithLabelValues(j.Name, "run").Inc()
+}
+
+// transact runs the steps of one run and returns the step that failed and
+// whether the committed files were put back.
+func (s *Scheduler) transact(ctx context.Context, j *job, tx *output.Txn) (string, bool, error) {
+	if err := j.Run(ctx, tx); err != nil {
+		return StepStage, false, err
+	}
+	if j.Validate != nil {
+		if err := j.Validate(tx); err != nil {
+			return StepValidate, false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		// Commit restores what it had replaced itself.
+		return StepCommit, false, err
+	}
+	if j.Reload == nil {
+		return "", false, nil
+	}
+	err := j.Reload(ctx)
+	if err == nil {
+		return "", false, nil
+	}
+	if rbErr := tx.Rollback(); rbErr != nil {
+		return StepReload, true, fmt.Errorf("%w (rollback: %v)", err, rbErr)
+	}
+	// Best effort: the consumer may already have read the new files.
+	if reErr := j.Reload(ctx); reErr != nil {
+		log.Printf("⚠️  Regeneration of %s: reload after rollback failed: %v", j.Name, reErr)
+	}
+	return StepReload, true, err
+}
+
+// Status returns every job, by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.status)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Name < out[b].Name })
+	return out
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
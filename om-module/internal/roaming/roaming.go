@@ -0,0 +1,345 @@
+// Package roaming makes the SEPPs of a roaming lab observable. A SEPP
+// (Security Edge Protection Proxy) sits at the border of each PLMN: the
+// visited and the home network exchange SBI messages only through their
+// SEPPs, over N32. N32-c is the control handshake in which the SEPPs agree
+// on a security capability (TLS or PRINS); N32-f carries the forwarded SBI
+// messages protected with it.
+//
+// The Prober health-checks the SBI and N32 ports of every running SEPP
+// container (om.nf "sepp") and reports how N32 is protected.
+package roaming
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const networkName = "docker_open5gs_default"
+
+// NFSEPP is the om.nf label of SEPP containers.
+const NFSEPP = "sepp"
+
+// SBIPort is the SBI port of every Open5GS NF in docker_open5gs.
+const SBIPort = 7777
+
+// Spec lists the specifications behind the N32 explanations.
+const Spec = "3GPP TS 29.573 (N32 interface), TS 33.501 §5.9.3 and §13 (SEPP, N32 security: TLS and PRINS)"
+
+// Security is how the N32 port of a SEPP is protected, as seen by the probe.
+const (
+	SecurityTLS       = "tls"
+	SecurityPlaintext = "plaintext"
+)
+
+// securityExplanations describe each N32 protection for the lab.
+var securityExplanations = map[string]string{
+	SecurityTLS: "N32 is protected hop by hop with TLS: the SEPPs authenticate each other with certificates and N32-f messages travel inside the tunnel, " +
+		"so an IPX provider in between can neither read nor modify them. This is the security capability the SEPPs negotiate in the N32-c handshake " +
+		"when they are directly connected.",
+	SecurityPlaintext: "N32 is not protected: the SEPPs exchange N32-c and N32-f messages over cleartext HTTP/2 (no_tls in the SEPP configuration). " +
+		"Acceptable in a lab, never between operators. With PRINS the SEPPs would instead protect each N32-f message at the application layer " +
+		"(JWE/JWS), letting IPX providers modify only the fields the home operator allows.",
+}
+
+// Explain returns the explanation of an N32 security value.
+func Explain(security string) string {
+	return securityExplanations[security]
+}
+
+// Endpoint is the last check of one SEPP port.
+type Endpoint struct {
+	Target     string  `json:"target"`
+	Up         bool    `json:"up"`
+	RTTSeconds float64 `json:"rtt_seconds,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// N32Endpoint is the last check of the N32 port, with what the TLS
+// handshake revealed.
+type N32Endpoint struct {
+	Endpoint
+	Security   string `json:"security,omitempty"` // tls | plaintext
+	TLSVersion string `json:"tls_version,omitempty"`
+	// ClientAuth is set when the SEPP asked for a client certificate
+	// (mutual TLS, as between two operators).
+	ClientAuth   bool   `json:"client_auth,omitempty"`
+	CertSubject  string `json:"cert_subject,omitempty"`
+	CertNotAfter string `json:"cert_not_after,omitempty"`
+	Explanation  string `json:"explanation,omitempty"`
+}
+
+// Result is the last check of one SEPP container.
+type Result struct {
+	Container string      `json:"container"`
+	SBI       Endpoint    `json:"sbi"`
+	N32       N32Endpoint `json:"n32"`
+	CheckedAt string      `json:"checked_at"`
+}
+
+// Prober health-checks the SBI port of running SEPP containers with a TCP
+// connection and their N32 port with a TLS handshake. A SEPP whose N32
+// port answers the handshake with something that is not TLS runs N32 in
+// cleartext.
+type Prober struct {
+	docker   *dockerclient.Client
+	snap     *collector.Snapshot
+	interval time.Duration
+	timeout  time.Duration
+	n32Port  int
+
+	up         *prometheus.GaugeVec
+	rtt        *prometheus.GaugeVec
+	tlsEnabled *prometheus.GaugeVec
+	certExpiry *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	results map[string]Result // keyed by container name
+	probed  time.Time         // end of the last complete probe round
+}
+
+// NewProber registers the roaming metrics on reg. n32Port is the N32 port
+// of the SEPP containers; timeout bounds each check.
+func NewProber(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, interval, timeout time.Duration, n32Port int) *Prober {
+	p := &Prober{
+		docker:   docker,
+		snap:     snap,
+		interval: interval,
+		timeout:  timeout,
+		n32Port:  n32Port,
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "roaming", Name: "sepp_up",
+			Help: "1 if the SEPP port (interface sbi or n32) accepted the last check, 0 otherwise.",
+		}, []string{"container", "interface"}),
+		rtt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "roaming", Name: "probe_rtt_seconds",
+			Help: "Duration of the last successful check of the SEPP port (TCP connect for sbi, TLS handshake for n32).",
+		}, []string{"container", "interface"}),
+		tlsEnabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "roaming", Name: "n32_tls",
+			Help: "1 if the N32 port of the SEPP speaks TLS, 0 if it runs in cleartext.",
+		}, []string{"container"}),
+		certExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "roaming", Name: "n32_cert_expiry_timestamp_seconds",
+			Help: "Expiry (Unix time) of the certificate the SEPP presents on N32.",
+		}, []string{"container"}),
+		results: make(map[string]Result),
+	}
+	reg.MustRegister(p.up, p.rtt, p.tlsEnabled, p.certExpiry)
+	return p
+}
+
+// Run probes every interval until ctx is cancelled. Labs without SEPP
+// containers cost one snapshot scan per interval.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probeAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Results returns the last check of every SEPP, sorted by container name.
+func (p *Prober) Results() []Result {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]Result, 0, len(p.results))
+	for _, r := range p.results {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+// Freshness returns when the om_roaming_* metrics were last refreshed by a
+// complete probe round, for exporter.Ages. Without SEPP containers there
+// are no such metrics and it returns nil.
+func (p *Prober) Freshness() map[string]time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.results) == 0 || p.probed.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{
+		"om_roaming_sepp_up":                           p.probed,
+		"om_roaming_probe_rtt_seconds":                 p.probed,
+		"om_roaming_n32_tls":                           p.probed,
+		"om_roaming_n32_cert_expiry_timestamp_seconds": p.probed,
+	}
+}
+
+func (p *Prober) probeAll(ctx context.Context) {
+	targets := make(map[string]bool)
+	for name, cd := range p.snap.All() {
+		if cd.NF == NFSEPP && cd.State == "running" {
+			targets[name] = true
+		}
+	}
+
+	p.mu.Lock()
+	for name := range p.results {
+		if !targets[name] {
+			delete(p.results, name)
+			p.forget(name)
+		}
+	}
+	p.mu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	ipToName, err := p.docker.GetNetworkContainerIPs(ctx, networkName)
+	if err != nil {
+		log.Printf("⚠️  SEPP probe: %v", err)
+		return
+	}
+	nameToIP := make(map[string]string, len(ipToName))
+	for ip, name := range ipToName {
+		nameToIP[name] = ip
+	}
+
+	for name := range targets {
+		r := Result{Container: name, CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+		ip, ok := nameToIP[name]
+		if !ok {
+			r.SBI.Error = "no IP on " + networkName
+			r.N32.Error = r.SBI.Error
+		} else {
+			r.SBI = p.checkSBI(ctx, net.JoinHostPort(ip, strconv.Itoa(SBIPort)))
+			r.N32 = p.checkN32(ctx, net.JoinHostPort(ip, strconv.Itoa(p.n32Port)))
+		}
+		p.record(r)
+	}
+
+	p.mu.Lock()
+	p.probed = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *Prober) forget(name string) {
+	for _, iface := range []string{"sbi", "n32"} {
+		p.up.DeleteLabelValues(name, iface)
+		p.rtt.DeleteLabelValues(name, iface)
+	}
+	p.tlsEnabled.DeleteLabelValues(name)
+	p.certExpiry.DeleteLabelValues(name)
+}
+
+func (p *Prober) record(r Result) {
+	p.mu.Lock()
+	prev, seen := p.results[r.Container]
+	p.results[r.Container] = r
+	p.mu.Unlock()
+
+	if seen && prev.N32.Up && !r.N32.Up {
+		log.Printf("⚠️  Roaming: %s stopped answering on N32: %s", r.Container, r.N32.Error)
+	}
+	for iface, e := range map[string]Endpoint{"sbi": r.SBI, "n32": r.N32.Endpoint} {
+		if e.Up {
+			p.up.WithLabelValues(r.Container, iface).Set(1)
+			p.rtt.WithLabelValues(r.Container, iface).Set(e.RTTSeconds)
+		} else {
+			p.up.WithLabelValues(r.Container, iface).Set(0)
+		}
+	}
+	switch r.N32.Security {
+	case SecurityTLS:
+		p.tlsEnabled.WithLabelValues(r.Container).Set(1)
+	case SecurityPlaintext:
+		p.tlsEnabled.WithLabelValues(r.Container).Set(0)
+	}
+	if t, err := time.Parse(time.RFC3339, r.N32.CertNotAfter); err == nil {
+		p.certExpiry.WithLabelValues(r.Container).Set(float64(t.Unix()))
+	} else {
+		p.certExpiry.DeleteLabelValues(r.Container)
+	}
+}
+
+func (p *Prober) checkSBI(ctx context.Context, target string) Endpoint {
+	e := Endpoint{Target: target}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	conn.Close()
+	e.Up, e.RTTSeconds = true, time.Since(start).Seconds()
+	return e
+}
+
+// checkN32 opens a TLS handshake on the N32 port. The certificate is not
+// verified: the probe only wants to know how N32 is protected.
+func (p *Prober) checkN32(ctx context.Context, target string) N32Endpoint {
+	e := N32Endpoint{Endpoint: Endpoint{Target: target}}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	start := time.Now()
+	raw, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	defer raw.Close()
+
+	conn := tls.Client(raw, &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2"},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) > 0 {
+				c := cs.PeerCertificates[0]
+				e.CertSubject = c.Subject.String()
+				e.CertNotAfter = c.NotAfter.UTC().Format(time.RFC3339)
+			}
+			return nil
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			e.ClientAuth = true
+			return &tls.Certificate{}, nil
+		},
+	})
+	err = conn.HandshakeContext(ctx)
+	rtt := time.Since(start)
+
+	var header tls.RecordHeaderError
+	switch {
+	case err == nil:
+		e.Security = SecurityTLS
+		e.TLSVersion = tls.VersionName(conn.ConnectionState().Version)
+	case errors.As(err, &header):
+		// The SEPP answered, but not with TLS: cleartext HTTP/2.
+		e.Security = SecurityPlaintext
+	case e.ClientAuth:
+		// A TLS 1.2 server rejects the empty client certificate during the
+		// handshake; it still speaks TLS.
+		e.Security = SecurityTLS
+	default:
+		e.Error = err.Error()
+		return e
+	}
+	e.Up, e.RTTSeconds = true, rtt.Seconds()
+	e.Explanation = Explain(e.Security)
+	return e
+}
@@ -0,0 +1,294 @@
+// Package runtimestats watches the module's own resource usage: goroutines
+// per subsystem, heap and open file descriptors. Subsystems are told apart
+// with pprof goroutine labels, which goroutines inherit from the one that
+// started them, so every goroutine a subsystem spawns (HTTP connections,
+// tshark readers, span emitters) is counted against it.
+//
+// The subsystems are also supervised (Go, Task): one that panics, or whose
+// loop returns while the module runs, is restarted with a backoff until its
+// restarts run out, and its state (starting, running, failed, stopped) is
+// served by /status and exported as om_subsystem_state.
+package runtimestats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// labelKey is the pprof label that names a goroutine's subsystem.
+	labelKey = "subsystem"
+
+	// Unlabelled goroutines (runtime, main, library internals) are reported
+	// under this name.
+	unlabelled = "other"
+
+	// A resource is suspected of leaking when it has not decreased over
+	// leakWindow samples and grew by at least leakMinGrowth in that time.
+	leakWindow    = 10
+	leakMinGrowth = 10
+)
+
+// Sample is one measurement of the module's resource usage.
+type Sample struct {
+	Time           string         `json:"time"`
+	Goroutines     int            `json:"goroutines"`
+	BySubsystem    map[string]int `json:"goroutines_by_subsystem"`
+	HeapInuseBytes uint64         `json:"heap_inuse_bytes"`
+	HeapObjects    uint64         `json:"heap_objects"`
+	GCCycles       uint32         `json:"gc_cycles"`
+	OpenFDs        int            `json:"open_fds"` // -1 when /proc is not available
+	Suspects       []Suspect      `json:"leak_suspects"`
+}
+
+// Suspect is a resource whose count has grown monotonically.
+type Suspect struct {
+	Resource  string `json:"resource"` // "goroutines:<subsystem>" or "open_fds"
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Samples   int    `json:"samples"`
+	FirstSeen string `json:"first_seen"`
+}
+
+// Monitor samples resource usage every interval, exports it as metrics and
+// logs a warning when a count keeps growing.
+type Monitor struct {
+	interval time.Duration
+
+	goroutines *prometheus.GaugeVec
+	heapInuse  prometheus.Gauge
+	heapObjs   prometheus.Gauge
+	openFDs    prometheus.Gauge
+	suspected  *prometheus.GaugeVec
+
+	mu       sync.Mutex
+	last     Sample
+	lastAt   time.Time
+	history  map[string][]int
+	suspects map[string]*Suspect
+}
+
+// New registers the runtime metrics on reg and returns the monitor.
+func New(reg prometheus.Registerer, interval time.Duration) *Monitor {
+	m := &Monitor{
+		interval: interval,
+		goroutines: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om",
+			Name:      "runtime_goroutines",
+			Help:      "Goroutines of the O&M module by subsystem (pprof label; \"other\" for unlabelled).",
+		}, []string{"subsystem"}),
+		heapInuse: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om",
+			Name:      "runtime_heap_inuse_bytes",
+			Help:      "Bytes in in-use heap spans of the O&M module.",
+		}),
+		heapObjs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om",
+			Name:      "runtime_heap_objects",
+			Help:      "Allocated heap objects of the O&M module.",
+		}),
+		openFDs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om",
+			Name:      "runtime_open_fds",
+			Help:      "Open file descriptors of the O&M module (sockets, pipes to tshark, log files).",
+		}),
+		suspected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om",
+			Name:      "runtime_leak_suspected",
+			Help:      "1 while a resource count has grown monotonically over the last samples.",
+		}, []string{"resource"}),
+		history:  make(map[string][]int),
+		suspects: make(map[string]*Suspect),
+	}
+	reg.MustRegister(m.goroutines, m.heapInuse, m.heapObjs, m.openFDs, m.suspected)
+	return m
+}
+
+// Run samples until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.sample()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the most recent sample.
+func (m *Monitor) Last() Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.last
+	s.BySubsystem = make(map[string]int, len(m.last.BySubsystem))
+	for k, v := range m.last.BySubsystem {
+		s.BySubsystem[k] = v
+	}
+	s.Suspects = append([]Suspect{}, m.last.Suspects...)
+	return s
+}
+
+// Freshness returns when the om_runtime_* metrics were last sampled, for
+// exporter.Ages.
+func (m *Monitor) Freshness() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lastAt.IsZero() {
+		return nil
+	}
+	out := make(map[string]time.Time, 5)
+	for _, f := range []string{"goroutines", "heap_inuse_bytes", "heap_objects", "open_fds", "leak_suspected"} {
+		out["om_runtime_"+f] = m.lastAt
+	}
+	return out
+}
+
+// Read measures the module's resource usage now, without leak suspects.
+func Read() Sample {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Sample{
+		Time:           time.Now().UTC().Format(time.RFC3339),
+		Goroutines:     runtime.NumGoroutine(),
+		BySubsystem:    goroutinesBySubsystem(),
+		HeapInuseBytes: ms.HeapInuse,
+		HeapObjects:    ms.HeapObjects,
+		GCCycles:       ms.NumGC,
+		OpenFDs:        openFDs(),
+	}
+}
+
+func (m *Monitor) sample() {
+	now := time.Now()
+	s := Read()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.goroutines.Reset()
+	for sub, n := range s.BySubsystem {
+		m.goroutines.WithLabelValues(sub).Set(float64(n))
+		m.track("goroutines:"+sub, n, now)
+	}
+	// Subsystems whose goroutines all exited still need a zero sample.
+	for res := range m.history {
+		if sub, ok := strings.CutPrefix(res, "goroutines:"); ok {
+			if _, seen := s.BySubsystem[sub]; !seen {
+				m.track(res, 0, now)
+			}
+		}
+	}
+	m.heapInuse.Set(float64(s.HeapInuseBytes))
+	m.heapObjs.Set(float64(s.HeapObjects))
+	if s.OpenFDs >= 0 {
+		m.openFDs.Set(float64(s.OpenFDs))
+		m.track("open_fds", s.OpenFDs, now)
+	}
+
+	s.Suspects = make([]Suspect, 0, len(m.suspects))
+	for _, sus := range m.suspects {
+		s.Suspects = append(s.Suspects, *sus)
+	}
+	sort.Slice(s.Suspects, func(i, j int) bool { return s.Suspects[i].Resource < s.Suspects[j].Resource })
+	m.last = s
+	m.lastAt = now
+}
+
+// track appends v to the history of resource and updates its leak state.
+// Callers hold m.mu.
+func (m *Monitor) track(resource string, v int, now time.Time) {
+	h := append(m.history[resource], v)
+	if len(h) > leakWindow {
+		h = h[len(h)-leakWindow:]
+	}
+	m.history[resource] = h
+
+	growing := len(h) == leakWindow && h[len(h)-1]-h[0] >= leakMinGrowth
+	for i := 1; growing && i < len(h); i++ {
+		growing = h[i] >= h[i-1]
+	}
+
+	sus, flagged := m.suspects[resource]
+	switch {
+	case growing && !flagged:
+		m.suspects[resource] = &Suspect{
+			Resource: resource, From: h[0], To: v, Samples: len(h),
+			FirstSeen: now.UTC().Format(time.RFC3339),
+		}
+		m.suspected.WithLabelValues(resource).Set(1)
+		log.Printf("⚠️  Possible leak: %s grew from %d to %d over the last %d samples (%s)",
+			resource, h[0], v, len(h), time.Duration(len(h)-1)*m.interval)
+	case growing:
+		sus.To = v
+	case flagged && v < sus.To:
+		delete(m.suspects, resource)
+		m.suspected.WithLabelValues(resource).Set(0)
+		log.Printf("✅ %s back down to %d — no longer suspected of leaking", resource, v)
+	}
+}
+
+var labelsLine = regexp.MustCompile(`"` + labelKey + `":"([^"]*)"`)
+
+// goroutinesBySubsystem counts goroutines per pprof subsystem label from the
+// text goroutine profile, where each stack group starts with its count and
+// is optionally followed by a "# labels: {…}" line.
+func goroutinesBySubsystem() map[string]int {
+	var buf bytes.Buffer
+	out := make(map[string]int)
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		out[unlabelled] = runtime.NumGoroutine()
+		return out
+	}
+
+	sc := bufio.NewScanner(&buf)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	count := 0 // goroutines in the current group, not yet attributed
+	flush := func(sub string) {
+		if count > 0 {
+			out[sub] += count
+			count = 0
+		}
+	}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "# labels:"):
+			sub := unlabelled
+			if m := labelsLine.FindStringSubmatch(line); m != nil {
+				sub = m[1]
+			}
+			flush(sub)
+		case line != "" && line[0] >= '0' && line[0] <= '9':
+			flush(unlabelled)
+			n, _, _ := strings.Cut(line, " ")
+			count, _ = strconv.Atoi(n)
+		}
+	}
+	flush(unlabelled)
+	return out
+}
+
+// openFDs counts the entries of /proc/self/fd, or returns -1 off Linux.
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries) - 1 // the descriptor ReadDir itself opened
+}
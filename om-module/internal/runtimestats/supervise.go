@@ -0,0 +1,206 @@
+package runtimestats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// States of a supervised subsystem.
+const (
+	StateStarting = "starting" // scheduled, or waiting to be restarted
+	StateRunning  = "running"
+	StateFailed   = "failed" // out of restarts
+	StateStopped  = "stopped"
+)
+
+var states = []string{StateStarting, StateRunning, StateFailed, StateStopped}
+
+// Reasons of a failure.
+const (
+	ReasonPanic  = "panic"
+	ReasonExited = "exited" // returned while the module was running
+)
+
+// Policy is how a failed subsystem is restarted.
+type Policy struct {
+	// MaxRestarts is how many times a subsystem is restarted before it is
+	// left failed.
+	MaxRestarts int
+	// Backoff is the wait before the first restart, doubled for every
+	// next one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultPolicy is the policy until Supervise.
+func DefaultPolicy() Policy {
+	return Policy{MaxRestarts: 5, Backoff: time.Second, MaxBackoff: time.Minute}
+}
+
+// Subsystem is the supervision state of one subsystem.
+type Subsystem struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Since is when the subsystem entered State.
+	Since       string `json:"since"`
+	Restarts    int    `json:"restarts"`
+	Failures    int    `json:"failures"`
+	LastError   string `json:"last_error,omitempty"`
+	LastFailure string `json:"last_failure,omitempty"`
+}
+
+var (
+	supMu      sync.Mutex
+	policy     = DefaultPolicy()
+	subsystems = make(map[string]*Subsystem)
+
+	stateGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "om", Subsystem: "subsystem", Name: "state",
+		Help: "1 for the current state of each subsystem of the module (starting, running, failed, stopped).",
+	}, []string{"subsystem", "state"})
+	failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "om", Subsystem: "subsystem", Name: "failures_total",
+		Help: "Failures of the subsystems of the module, by reason (panic, or exited while the module runs).",
+	}, []string{"subsystem", "reason"})
+	restarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "om", Subsystem: "subsystem", Name: "restarts_total",
+		Help: "Restarts of the subsystems of the module after a failure.",
+	}, []string{"subsystem"})
+)
+
+// Supervise sets the restart policy of the subsystems started from now on.
+func Supervise(p Policy) {
+	supMu.Lock()
+	defer supMu.Unlock()
+	policy = p
+}
+
+// Instrument registers the om_subsystem_* metrics on reg. Subsystems
+// started before keep their counts.
+func Instrument(reg prometheus.Registerer) {
+	reg.MustRegister(stateGauge, failures, restarts)
+}
+
+// Go runs fn, a subsystem that runs until ctx is cancelled, in a new
+// goroutine labelled with subsystem; goroutines fn starts carry the same
+// label. When fn panics, or returns while ctx is live, it is restarted
+// under the policy. The returned channel is closed once fn stopped for
+// good: ctx was cancelled or the restarts ran out.
+func Go(ctx context.Context, subsystem string, fn func(ctx context.Context)) <-chan struct{} {
+	return supervise(ctx, subsystem, fn, true)
+}
+
+// Task runs fn, a one-shot job, like Go but without restarts: it is
+// stopped when fn returns, and failed when it panics.
+func Task(ctx context.Context, subsystem string, fn func(ctx context.Context)) <-chan struct{} {
+	return supervise(ctx, subsystem, fn, false)
+}
+
+func supervise(ctx context.Context, name string, fn func(ctx context.Context), restart bool) <-chan struct{} {
+	supMu.Lock()
+	p := policy
+	supMu.Unlock()
+	setState(name, StateStarting)
+
+	done := make(chan struct{})
+	go pprof.Do(ctx, pprof.Labels(labelKey, name), func(ctx context.Context) {
+		defer close(done)
+		wait := p.Backoff
+		for attempt := 0; ; attempt++ {
+			setState(name, StateRunning)
+			reason, err := run(ctx, fn)
+			switch {
+			case ctx.Err() != nil:
+				setState(name, StateStopped)
+				return
+			case reason == "" && !restart:
+				setState(name, StateStopped)
+				return
+			case reason == "":
+				reason, err = ReasonExited, fmt.Errorf("returned while the module runs")
+			}
+			recordFailure(name, reason, err)
+			if !restart || attempt >= p.MaxRestarts {
+				setState(name, StateFailed)
+				log.Printf("❌ Subsystem %s failed for good after %d restart(s): %v", name, attempt, err)
+				return
+			}
+
+			setState(name, StateStarting)
+			log.Printf("⚠️  Subsystem %s failed (%s): %v — restarting in %s (%d/%d)", name, reason, err, wait, attempt+1, p.MaxRestarts)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				setState(name, StateStopped)
+				return
+			}
+			wait = min(2*wait, p.MaxBackoff)
+			restarts.WithLabelValues(name).Inc()
+			supMu.Lock()
+			subsystems[name].Restarts++
+			supMu.Unlock()
+		}
+	})
+	return done
+}
+
+// run calls fn and turns a panic into a failure.
+func run(ctx context.Context, fn func(ctx context.Context)) (reason string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			reason, err = ReasonPanic, fmt.Errorf("%v", r)
+			log.Printf("⚠️  Panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	fn(ctx)
+	return "", nil
+}
+
+func setState(name, state string) {
+	supMu.Lock()
+	defer supMu.Unlock()
+	s := subsystems[name]
+	if s == nil {
+		s = &Subsystem{Name: name}
+		subsystems[name] = s
+	}
+	s.State = state
+	s.Since = time.Now().UTC().Format(time.RFC3339)
+	for _, st := range states {
+		v := 0.0
+		if st == state {
+			v = 1
+		}
+		stateGauge.WithLabelValues(name, st).Set(v)
+	}
+}
+
+func recordFailure(name, reason string, err error) {
+	failures.WithLabelValues(name, reason).Inc()
+	supMu.Lock()
+	defer supMu.Unlock()
+	s := subsystems[name]
+	s.Failures++
+	s.LastError = err.Error()
+	s.LastFailure = time.Now().UTC().Format(time.RFC3339)
+}
+
+// Subsystems returns the state of every subsystem started, by name.
+func Subsystems() []Subsystem {
+	supMu.Lock()
+	defer supMu.Unlock()
+	out := make([]Subsystem, 0, len(subsystems))
+	for _, s := range subsystems {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
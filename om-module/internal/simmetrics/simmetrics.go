@@ -0,0 +1,556 @@
+// Package simmetrics keeps a class going when part of the testbed is
+// broken: when an Open5GS NF the module has samples for (metrics_endpoints,
+// the expositions of a working lab) has no reachable metrics endpoint, the
+// Fallback serves those samples for it on /metrics/simulated, labelled
+// container=<its container> and data_source="simulated", until the real
+// endpoint answers again. Prometheus scrapes them with honor_labels, so the
+// dashboards keep their panels, and the om_metrics_simulated gauge drives a
+// banner on every dashboard that queries them.
+//
+// The samples are counters and gauges. Gauges keep their value; counters
+// grow linearly from it, so rate() panels do not flatten to zero. The
+// simulation is behind the "simulated" feature flag, off by default.
+//
+// The samples come from Open5GS as they are, so they are checked once,
+// when loaded: a series with an invalid name or label, repeated, or of a
+// metric another sample exposes with another type is dropped there and
+// listed in the status. The collector then only sends series that are
+// known to be valid, and never registers anything after start.
+package simmetrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const networkName = "docker_open5gs_default"
+
+// Label is the label, with value Simulated, that marks simulated series.
+const (
+	Label     = "data_source"
+	Simulated = "simulated"
+)
+
+// growthPeriod is how long a simulated counter takes to add its sampled
+// value again.
+const growthPeriod = 10 * time.Minute
+
+// Template is the sampled exposition of one NF.
+type Template struct {
+	NF         string
+	Generation string // 4g | 5g, or "" when the file does not say
+	File       string
+	// Dropped lists the series of the file left out, as "metric: reason".
+	Dropped []string
+	series  []sample
+}
+
+// sample is one series of a template, without the container and
+// data_source labels Collect adds.
+type sample struct {
+	name   string
+	desc   *prometheus.Desc
+	kind   prometheus.ValueType
+	value  float64
+	labels []string
+}
+
+// NF is the last check of one container of an NF with a template.
+type NF struct {
+	Container  string `json:"container"`
+	NF         string `json:"nf"`
+	Generation string `json:"generation,omitempty"`
+	Template   string `json:"template"`
+	// Endpoint is the address probed; empty when the container exposes
+	// no metrics (no prometheus.scrape label) or is not on the network.
+	Endpoint  string `json:"endpoint,omitempty"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+	// Simulated is true while its samples are served in its place.
+	Simulated      bool   `json:"simulated"`
+	SimulatedSince string `json:"simulated_since,omitempty"`
+	Series         int    `json:"series"`
+}
+
+// Status is the API view of the fallback.
+type Status struct {
+	Dir      string `json:"dir"`
+	Interval string `json:"interval"`
+	// Active is false while the "simulated" flag is off: the endpoints are
+	// still checked, nothing is simulated.
+	Active    bool     `json:"active"`
+	Templates []string `json:"templates"`
+	// Dropped are the series of the templates left out when loaded, as
+	// "file: metric: reason".
+	Dropped   []string `json:"dropped,omitempty"`
+	Simulated int      `json:"simulated"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	NFs       []NF     `json:"nfs"`
+}
+
+// Options configure the fallback.
+type Options struct {
+	// Dir holds the templates: <nf>.txt or <nf>_<generation>.txt, in
+	// per-generation subdirectories (4g/, 5g/) or not.
+	Dir      string
+	Interval time.Duration
+}
+
+// Fallback serves the templates of the NFs whose metrics endpoint does not
+// answer.
+type Fallback struct {
+	docker    *dockerclient.Client
+	snap      *collector.Snapshot
+	opts      Options
+	templates []*Template
+	http      *http.Client
+	gate      func() bool
+	handler   http.Handler
+
+	simulated *prometheus.GaugeVec
+
+	mu      sync.RWMutex
+	nfs     map[string]NF        // keyed by container name
+	since   map[string]time.Time // simulated containers, since when
+	checked time.Time
+	err     string
+}
+
+// LoadTemplates reads the *.txt expositions under dir and its
+// subdirectories. Go runtime and process metrics are left out: those of
+// the lab that was sampled say nothing about this one. The series are
+// checked here (see compiler); a file the text parser rejects fails the
+// load.
+func LoadTemplates(dir string) ([]*Template, error) {
+	var files []string
+	for _, pattern := range []string{"*.txt", "*/*.txt"} {
+		found, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.txt expositions in %s", dir)
+	}
+	sort.Strings(files)
+
+	var out []*Template
+	c := newCompiler()
+	for _, f := range files {
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		parser := expfmt.NewTextParser(model.UTF8Validation)
+		parsed, err := parser.TextToMetricFamilies(bytes.NewReader(withoutRepeats(raw)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		rel, _ := filepath.Rel(dir, f)
+		t := &Template{File: rel}
+		t.NF, t.Generation = templateName(rel)
+		for _, name := range sortedNames(parsed) {
+			mf := parsed[name]
+			if strings.HasPrefix(name, "go_") || strings.HasPrefix(name, "process_") {
+				continue
+			}
+			if mf.GetType() != dto.MetricType_COUNTER && mf.GetType() != dto.MetricType_GAUGE {
+				continue
+			}
+			c.add(t, mf)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// compiler turns the metric families of the templates into their series.
+// The descriptors are shared by every template, so two samples of the
+// same metric (the SMF of each generation) are one family on
+// /metrics/simulated, with the help of the first.
+type compiler struct {
+	kinds map[string]prometheus.ValueType // by metric name
+	descs map[string]*prometheus.Desc     // by metric and label names
+}
+
+func newCompiler() *compiler {
+	return &compiler{kinds: make(map[string]prometheus.ValueType), descs: make(map[string]*prometheus.Desc)}
+}
+
+// add appends to t the series of mf that are valid, and the others to
+// t.Dropped.
+func (c *compiler) add(t *Template, mf *dto.MetricFamily) {
+	name := mf.GetName()
+	kind := prometheus.GaugeValue
+	if mf.GetType() == dto.MetricType_COUNTER {
+		kind = prometheus.CounterValue
+	}
+	if k, ok := c.kinds[name]; ok && k != kind {
+		t.Dropped = append(t.Dropped, name+": another sample exposes it with another type")
+		return
+	}
+	c.kinds[name] = kind
+
+	seen := make(map[string]bool)
+	for _, m := range mf.GetMetric() {
+		names := []string{"container", Label}
+		var values []string
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == "container" || lp.GetName() == Label {
+				continue
+			}
+			names = append(names, lp.GetName())
+			values = append(values, lp.GetValue())
+		}
+		key := strings.Join(append([]string{name}, names...), "\xff")
+		desc, ok := c.descs[key]
+		if !ok {
+			desc = prometheus.NewDesc(name, mf.GetHelp(), names, nil)
+			c.descs[key] = desc
+		}
+		value := m.GetGauge().GetValue()
+		if kind == prometheus.CounterValue {
+			value = m.GetCounter().GetValue()
+		}
+		// The container name is only known when collecting; a valid
+		// one cannot make an otherwise valid series invalid.
+		if _, err := prometheus.NewConstMetric(desc, kind, value, append([]string{"x", Simulated}, values...)...); err != nil {
+			t.Dropped = append(t.Dropped, name+": "+err.Error())
+			continue
+		}
+		id := key + "\xff" + strings.Join(values, "\xff")
+		if seen[id] {
+			t.Dropped = append(t.Dropped, name+": repeated series")
+			continue
+		}
+		seen[id] = true
+		t.series = append(t.series, sample{name: name, desc: desc, kind: kind, value: value, labels: values})
+	}
+}
+
+// withoutRepeats drops the metric blocks of raw whose metric was already
+// exposed above: Open5GS repeats some (the PCRF exposes gx_rx_unknown
+// twice), which the text parser rejects.
+func withoutRepeats(raw []byte) []byte {
+	var out bytes.Buffer
+	seen := make(map[string]bool)
+	current, skip := "", false
+	for _, line := range strings.SplitAfter(string(raw), "\n") {
+		if f := strings.Fields(line); len(f) >= 3 && f[0] == "#" && (f[1] == "HELP" || f[1] == "TYPE") && f[2] != current {
+			current, skip = f[2], seen[f[2]]
+			seen[f[2]] = true
+		}
+		if !skip {
+			out.WriteString(line)
+		}
+	}
+	return out.Bytes()
+}
+
+// templateName returns the NF and generation of the template at rel:
+// "5g/smf_5g.txt" and "smf_5g.txt" are the 5G SMF, "5g/amf.txt" the AMF.
+func templateName(rel string) (nf, generation string) {
+	base := strings.TrimSuffix(filepath.Base(rel), ".txt")
+	if dir := filepath.Dir(rel); dir == "4g" || dir == "5g" {
+		generation = dir
+	}
+	if n, g, ok := strings.Cut(base, "_"); ok && (g == "4g" || g == "5g") {
+		return n, g
+	}
+	return base, generation
+}
+
+func sortedNames(m map[string]*dto.MetricFamily) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// New registers om_metrics_simulated on reg. The simulated series
+// themselves are served by Handler, away from /metrics.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, snap *collector.Snapshot, templates []*Template, opts Options) *Fallback {
+	f := &Fallback{
+		docker:    docker,
+		snap:      snap,
+		opts:      opts,
+		templates: templates,
+		http:      httpclient.New("simmetrics", 2*time.Second),
+		simulated: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "metrics", Name: "simulated",
+			Help: "1 while the metrics of the container are simulated from samples (data_source=\"simulated\") because its endpoint does not answer.",
+		}, []string{"container", "nf"}),
+		nfs:   make(map[string]NF),
+		since: make(map[string]time.Time),
+	}
+	sim := prometheus.NewRegistry()
+	sim.MustRegister(f)
+	// Whatever the checks at load miss loses its series rather than
+	// failing the whole scrape.
+	f.handler = promhttp.HandlerFor(sim, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+	reg.MustRegister(f.simulated)
+	return f
+}
+
+// SetGate makes the fallback simulate only while gate returns true (the
+// "simulated" feature flag).
+func (f *Fallback) SetGate(gate func() bool) {
+	f.gate = gate
+}
+
+func (f *Fallback) active() bool {
+	return f.gate == nil || f.gate()
+}
+
+// Handler serves the simulated series, for /metrics/simulated.
+func (f *Fallback) Handler() http.Handler { return f.handler }
+
+// Metrics returns the names of the metrics the templates can simulate, for
+// the dashboard banner.
+func (f *Fallback) Metrics() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range f.templates {
+		for _, s := range t.series {
+			if !seen[s.name] {
+				seen[s.name] = true
+				out = append(out, s.name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Run checks the metrics endpoints every interval until ctx is cancelled.
+func (f *Fallback) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.opts.Interval)
+	defer ticker.Stop()
+	for {
+		f.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// template returns the template for an NF of the given generation,
+// preferring one of the same generation.
+func (f *Fallback) template(nf, generation string) *Template {
+	var other *Template
+	for _, t := range f.templates {
+		if t.NF != nf {
+			continue
+		}
+		if t.Generation == generation {
+			return t
+		}
+		if other == nil {
+			other = t
+		}
+	}
+	return other
+}
+
+func (f *Fallback) check(ctx context.Context) {
+	ips, err := f.containerIPs(ctx)
+	active := f.active()
+	now := time.Now()
+
+	nfs := make(map[string]NF)
+	for name, cd := range f.snap.All() {
+		t := f.template(cd.NF, cd.Generation)
+		if t == nil {
+			continue
+		}
+		n := NF{Container: name, NF: cd.NF, Generation: cd.Generation, Template: t.File}
+		n.Reachable, n.Endpoint, n.Error = f.probe(ctx, cd, ips, err)
+		nfs[name] = n
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, n := range nfs {
+		_, was := f.since[name]
+		switch {
+		case !n.Reachable && active:
+			if !was {
+				f.since[name] = now
+				log.Printf("⚠️  Metrics of %s simulated from %s: %s", name, n.Template, n.Error)
+			}
+			n.Simulated, n.SimulatedSince = true, f.since[name].UTC().Format(time.RFC3339)
+			n.Series = series(f.template(n.NF, n.Generation))
+		case was:
+			delete(f.since, name)
+			if n.Reachable {
+				log.Printf("✅ Metrics of %s real again", name)
+			}
+		}
+		v := 0.0
+		if n.Simulated {
+			v = 1
+		}
+		f.simulated.WithLabelValues(name, n.NF).Set(v)
+		nfs[name] = n
+	}
+	for name, n := range f.nfs {
+		if _, ok := nfs[name]; !ok {
+			delete(f.since, name)
+			f.simulated.DeleteLabelValues(name, n.NF)
+		}
+	}
+	f.nfs, f.checked = nfs, now
+	f.err = ""
+	if err != nil {
+		f.err = err.Error()
+	}
+}
+
+// probe asks the metrics endpoint of cd. ipErr is the error listing the
+// container addresses, if any.
+func (f *Fallback) probe(ctx context.Context, cd *collector.ContainerData, ips map[string]string, ipErr error) (bool, string, string) {
+	switch {
+	case cd.State != "running":
+		return false, "", "container " + cd.State
+	case cd.MetricsAddress == "":
+		return false, "", "no metrics endpoint (prometheus.scrape label)"
+	case ipErr != nil:
+		return false, "", ipErr.Error()
+	}
+	ip, ok := ips[cd.Name]
+	if !ok {
+		return false, "", "not on " + networkName
+	}
+	_, port, _ := strings.Cut(cd.MetricsAddress, ":")
+	endpoint := net.JoinHostPort(ip, port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+endpoint+"/metrics", nil)
+	if err != nil {
+		return false, endpoint, err.Error()
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return false, endpoint, err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, endpoint, resp.Status
+	}
+	return true, endpoint, ""
+}
+
+// containerIPs maps container names to their address on the lab network.
+func (f *Fallback) containerIPs(ctx context.Context) (map[string]string, error) {
+	ipToName, err := f.docker.GetNetworkContainerIPs(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(ipToName))
+	for ip, name := range ipToName {
+		out[name] = ip
+	}
+	return out, nil
+}
+
+func series(t *Template) int {
+	return len(t.series)
+}
+
+// Describe sends nothing: the simulated series change with the containers
+// being simulated, so the collector is unchecked.
+func (f *Fallback) Describe(chan<- *prometheus.Desc) {}
+
+// Collect sends the series of the templates of the simulated containers.
+func (f *Fallback) Collect(ch chan<- prometheus.Metric) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	now := time.Now()
+	for name, since := range f.since {
+		n := f.nfs[name]
+		t := f.template(n.NF, n.Generation)
+		if t == nil {
+			continue
+		}
+		growth := 1 + now.Sub(since).Seconds()/growthPeriod.Seconds()
+		for _, s := range t.series {
+			value := s.value
+			if s.kind == prometheus.CounterValue {
+				value *= growth
+			}
+			metric, err := prometheus.NewConstMetric(s.desc, s.kind, value, append([]string{name, Simulated}, s.labels...)...)
+			if err == nil {
+				ch <- metric
+			}
+		}
+	}
+}
+
+// Status returns the last check of every container with a template.
+func (f *Fallback) Status() Status {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	st := Status{
+		Dir:      f.opts.Dir,
+		Interval: f.opts.Interval.String(),
+		Active:   f.active(),
+		Error:    f.err,
+		NFs:      make([]NF, 0, len(f.nfs)),
+	}
+	for _, t := range f.templates {
+		st.Templates = append(st.Templates, t.File)
+		for _, d := range t.Dropped {
+			st.Dropped = append(st.Dropped, t.File+": "+d)
+		}
+	}
+	if !f.checked.IsZero() {
+		st.UpdatedAt = f.checked.UTC().Format(time.RFC3339)
+	}
+	for _, n := range f.nfs {
+		if n.Simulated {
+			st.Simulated++
+		}
+		st.NFs = append(st.NFs, n)
+	}
+	sort.Slice(st.NFs, func(i, j int) bool {
+		if st.NFs[i].Simulated != st.NFs[j].Simulated {
+			return st.NFs[i].Simulated
+		}
+		return st.NFs[i].Container < st.NFs[j].Container
+	})
+	return st
+}
+
+// Freshness returns when om_metrics_simulated was last refreshed, for
+// exporter.Ages. Before the first check it returns nil.
+func (f *Fallback) Freshness() map[string]time.Time {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.checked.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_metrics_simulated": f.checked}
+}
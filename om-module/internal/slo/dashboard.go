@@ -0,0 +1,183 @@
+package slo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DashboardUID and DashboardFile identify the generated SLO overview.
+const (
+	DashboardUID  = "slo-overview"
+	DashboardFile = "slo_overview.json"
+)
+
+var prometheusDS = map[string]any{"type": "prometheus", "uid": "PBFA97CFB590B2093"}
+
+// grafanaUnits maps KPI units to Grafana field units.
+var grafanaUnits = map[string]string{
+	"ratio":   "percentunit",
+	"seconds": "s",
+	"bytes/s": "Bps",
+	"count":   "none",
+}
+
+// Dashboard returns the Grafana model of the SLO overview of f: per
+// objective, the error budget left as a gauge, the SLI against its target
+// and the burn rate of both windows against the alert threshold. Alerts
+// are shown as annotations.
+func (f *File) Dashboard() ([]byte, error) {
+	title := "🎯 Objetivos de nivel de servicio (SLO)"
+	if f.Lab != "" {
+		title += " — " + f.Lab
+	}
+	long := windowName(f.LongWindow)
+
+	id := 0
+	next := func() int { id++; return id }
+	panels := []any{map[string]any{
+		"collapsed": false, "gridPos": grid(24, 1, 0, 0), "id": next(), "panels": []any{},
+		"title": fmt.Sprintf("🎯 Presupuesto de error (%s) y tasa de consumo (%s / %s)", long, windowName(f.ShortWindow), long),
+		"type":  "row",
+	}}
+	for i, o := range f.Objectives {
+		y := 1 + i*8
+		sel := fmt.Sprintf(`objective=%q`, o.Name)
+		unit := grafanaUnits[o.Unit()]
+		if unit == "" {
+			unit = "none"
+		}
+
+		panels = append(panels, map[string]any{
+			"datasource":  prometheusDS,
+			"description": fmt.Sprintf("Parte del presupuesto de error de %s que queda en la ventana de %s: 100 %% sin fallos, 0 %% agotado", o.Name, long),
+			"fieldConfig": map[string]any{"defaults": map[string]any{
+				"unit": "percentunit", "min": 0, "max": 1,
+				"color":      map[string]any{"mode": "thresholds"},
+				"thresholds": steps("red", 0.25, "orange", 0.5, "green"),
+			}, "overrides": []any{}},
+			"gridPos": grid(6, 8, 0, y),
+			"id":      next(),
+			"options": map[string]any{
+				"reduceOptions":        reduceLast(),
+				"showThresholdLabels":  false,
+				"showThresholdMarkers": true,
+			},
+			"targets": []any{target("A", fmt.Sprintf("om_slo_error_budget_remaining{%s}", sel), "")},
+			"title":   o.Title + " — presupuesto restante",
+			"type":    "gauge",
+		})
+
+		thresholds := steps("red", o.Target, "green")
+		if o.Op() == "<=" {
+			thresholds = steps("green", o.Target, "red")
+		}
+		panels = append(panels, map[string]any{
+			"datasource":  prometheusDS,
+			"description": fmt.Sprintf("%s en la ventana de %s. Objetivo: %s %s %g", o.KPI, long, o.KPI, o.Op(), o.Target),
+			"fieldConfig": map[string]any{"defaults": map[string]any{
+				"unit":       unit,
+				"color":      map[string]any{"mode": "thresholds"},
+				"thresholds": thresholds,
+				"noValue":    "sin datos",
+			}, "overrides": []any{}},
+			"gridPos": grid(6, 8, 6, y),
+			"id":      next(),
+			"options": map[string]any{
+				"colorMode": "background", "graphMode": "area", "justifyMode": "center",
+				"orientation": "auto", "reduceOptions": reduceLast(), "textMode": "auto",
+			},
+			"targets": []any{target("A", fmt.Sprintf(`om_slo_sli{%s, window=%q}`, sel, long), "")},
+			"title":   fmt.Sprintf("%s — SLI (objetivo %s %g)", o.Title, o.Op(), o.Target),
+			"type":    "stat",
+		})
+
+		panels = append(panels, map[string]any{
+			"datasource":  prometheusDS,
+			"description": fmt.Sprintf("Velocidad a la que se consume el presupuesto: 1 lo gasta justo al ritmo permitido. Con las dos ventanas por encima de %g el objetivo entra en alerta", f.AlertBurnRate),
+			"fieldConfig": map[string]any{"defaults": map[string]any{
+				"unit":       "none",
+				"min":        0,
+				"color":      map[string]any{"mode": "palette-classic"},
+				"custom":     map[string]any{"drawStyle": "line", "fillOpacity": 10, "lineWidth": 2, "thresholdsStyle": map[string]any{"mode": "line+area"}},
+				"thresholds": steps("green", 1, "yellow", f.AlertBurnRate, "red"),
+			}, "overrides": []any{}},
+			"gridPos": grid(12, 8, 12, y),
+			"id":      next(),
+			"options": map[string]any{
+				"legend":  map[string]any{"displayMode": "list", "placement": "bottom", "showLegend": true},
+				"tooltip": map[string]any{"mode": "multi", "sort": "desc"},
+			},
+			"targets": []any{target("A", fmt.Sprintf("om_slo_burn_rate{%s}", sel), "{{window}}")},
+			"title":   o.Title + " — tasa de consumo",
+			"type":    "timeseries",
+		})
+	}
+
+	m := map[string]any{
+		"annotations": map[string]any{"list": []any{
+			map[string]any{
+				"builtIn": 1, "datasource": map[string]any{"type": "grafana", "uid": "-- Grafana --"},
+				"enable": true, "hide": true, "iconColor": "rgba(0, 211, 255, 1)",
+				"name": "Annotations & Alerts", "type": "dashboard",
+			},
+			map[string]any{
+				"datasource": map[string]any{"type": "grafana", "uid": "-- Grafana --"},
+				"enable":     true, "hide": false, "iconColor": "#F2495C",
+				"name":   "🔥 Alertas SLO",
+				"target": map[string]any{"limit": 100, "matchAny": false, "tags": []string{"slo"}, "type": "tags"},
+			},
+		}},
+		"description":          "Generado por el módulo O&M a partir de SLO_FILE: presupuesto de error y tasa de consumo de cada objetivo del laboratorio",
+		"editable":             false,
+		"fiscalYearStartMonth": 0,
+		"graphTooltip":         1,
+		"id":                   nil,
+		"links":                []any{},
+		"panels":               panels,
+		"refresh":              "30s",
+		"schemaVersion":        40,
+		"tags":                 []string{"slo", "error-budget", "observability"},
+		"templating":           map[string]any{"list": []any{}},
+		"time":                 map[string]any{"from": "now-6h", "to": "now"},
+		"timepicker":           map[string]any{},
+		"timezone":             "browser",
+		"title":                title,
+		"uid":                  DashboardUID,
+		"version":              1,
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func grid(w, h, x, y int) map[string]any {
+	return map[string]any{"h": h, "w": w, "x": x, "y": y}
+}
+
+func reduceLast() map[string]any {
+	return map[string]any{"calcs": []string{"lastNotNull"}, "fields": "", "values": false}
+}
+
+func target(ref, expr, legend string) map[string]any {
+	t := map[string]any{"datasource": prometheusDS, "expr": expr, "refId": ref}
+	if legend != "" {
+		t["legendFormat"] = legend
+	}
+	return t
+}
+
+// steps returns absolute thresholds from alternating colors and values:
+// steps("red", 0.5, "green") is red below 0.5 and green from it.
+func steps(first string, rest ...any) map[string]any {
+	list := []any{map[string]any{"color": first, "value": nil}}
+	for i := 0; i+1 < len(rest); i += 2 {
+		list = append(list, map[string]any{"color": rest[i+1], "value": rest[i]})
+	}
+	return map[string]any{"mode": "absolute", "steps": list}
+}
@@ -0,0 +1,458 @@
+// Package slo evaluates the service level objectives an instructor sets
+// for a lab (SLO_FILE): a target for a live KPI, such as attach success
+// ≥ 99 %, attach latency ≤ 200 ms or NF availability ≥ 99.5 %. Every
+// interval each objective is measured over a short and a long window, and
+// its error budget burn rate is exported as om_slo_* metrics for the SLO
+// overview dashboard the package also generates. When both windows burn
+// the budget faster than the alert threshold, the objective alerts: a
+// Grafana annotation tagged "slo" and a log line, like the alerts of an
+// SRE team.
+//
+// The burn rate says how fast the objective spends its budget, 1 being
+// exactly on target:
+//
+//   - ratio ≥ target: (1 − SLI) / (1 − target), the share of failures
+//     over the share allowed;
+//   - other value ≥ target: target / SLI;
+//   - value ≤ target: SLI / target.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.yaml.in/yaml/v2"
+)
+
+// Defaults of the file.
+const (
+	DefaultShortWindow   = 5 * time.Minute
+	DefaultLongWindow    = time.Hour
+	DefaultAlertBurnRate = 2.0
+)
+
+var nameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Objective is one SLO of the file.
+type Objective struct {
+	Name       string  `yaml:"name" json:"name"`
+	Title      string  `yaml:"title" json:"title"`
+	KPI        string  `yaml:"kpi" json:"kpi"` // a kpi.LiveDefinitions name
+	Target     float64 `yaml:"target" json:"target"`
+	Generation string  `yaml:"generation" json:"generation,omitempty"` // "" = the running core
+
+	def kpi.LiveDefinition
+}
+
+// Op is ">=" or "<=": whether the KPI must stay above or below Target.
+func (o Objective) Op() string {
+	if o.def.HigherIsBetter {
+		return ">="
+	}
+	return "<="
+}
+
+// Unit is the unit of the KPI.
+func (o Objective) Unit() string { return o.def.Unit }
+
+// File is the SLO file of a lab.
+type File struct {
+	Lab           string        `yaml:"lab"`
+	ShortWindow   time.Duration `yaml:"short_window"`
+	LongWindow    time.Duration `yaml:"long_window"`
+	AlertBurnRate float64       `yaml:"alert_burn_rate"`
+	Objectives    []Objective   `yaml:"objectives"`
+}
+
+// Load reads and checks the SLO file at p.
+func Load(p string) (*File, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{ShortWindow: DefaultShortWindow, LongWindow: DefaultLongWindow, AlertBurnRate: DefaultAlertBurnRate}
+	if err := yaml.UnmarshalStrict(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	if err := f.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return f, nil
+}
+
+func (f *File) check() error {
+	if f.ShortWindow < kpi.MinWindow || f.LongWindow > kpi.MaxWindow || f.ShortWindow >= f.LongWindow {
+		return fmt.Errorf("windows must satisfy %s ≤ short_window < long_window ≤ %s", kpi.MinWindow, kpi.MaxWindow)
+	}
+	if f.AlertBurnRate <= 0 {
+		return fmt.Errorf("alert_burn_rate must be positive")
+	}
+	if len(f.Objectives) == 0 {
+		return fmt.Errorf("no objectives")
+	}
+	seen := make(map[string]bool)
+	for i := range f.Objectives {
+		o := &f.Objectives[i]
+		if !nameRe.MatchString(o.Name) || seen[o.Name] {
+			return fmt.Errorf("objective %d: name %q must be unique lower-case letters, digits and _", i+1, o.Name)
+		}
+		seen[o.Name] = true
+		def, ok := kpi.LookupLive(o.KPI)
+		if !ok {
+			return fmt.Errorf("objective %s: unknown kpi %q (see /api/kpi)", o.Name, o.KPI)
+		}
+		o.def = def
+		if o.Generation != "" {
+			if _, ok := def.Query(o.Generation, f.ShortWindow); !ok {
+				return fmt.Errorf("objective %s: kpi %s has no %q query", o.Name, o.KPI, o.Generation)
+			}
+		}
+		if o.Target <= 0 || (def.Unit == "ratio" && o.Target >= 1) {
+			return fmt.Errorf("objective %s: target %g out of range", o.Name, o.Target)
+		}
+		if o.Title == "" {
+			o.Title = o.Name
+		}
+	}
+	return nil
+}
+
+// Window is an objective measured over one window.
+type Window struct {
+	Window string `json:"window"`
+	// SLI is nil without data in the window (nothing happened, or the KPI
+	// is not collected in this lab); the burn rate is then 0.
+	SLI      *float64 `json:"sli"`
+	BurnRate float64  `json:"burn_rate"`
+}
+
+// Result is the state of one objective.
+type Result struct {
+	Objective
+	Op         string   `json:"op"`
+	Unit       string   `json:"unit"`
+	Generation string   `json:"generation"`
+	Windows    []Window `json:"windows"` // short, long
+	// Remaining is the share of the long-window budget left, from 1 to 0.
+	Remaining float64 `json:"remaining"`
+	Met       bool    `json:"met"` // the long-window SLI meets the target
+	Alerting  bool    `json:"alerting"`
+	Since     string  `json:"since,omitempty"` // start of the current alert
+	Error     string  `json:"error,omitempty"`
+}
+
+// Status is the API view of the engine.
+type Status struct {
+	Lab           string   `json:"lab,omitempty"`
+	Interval      string   `json:"interval"`
+	AlertBurnRate float64  `json:"alert_burn_rate"`
+	UpdatedAt     string   `json:"updated_at,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	Objectives    []Result `json:"objectives"`
+}
+
+// Evaluator measures the objectives of a File.
+type Evaluator struct {
+	file       *File
+	prom       *kpi.Prometheus
+	generation func() string
+	grafana    *grafana.Client
+	interval   time.Duration
+
+	sli       *prometheus.GaugeVec
+	target    *prometheus.GaugeVec
+	burnRate  *prometheus.GaugeVec
+	remaining *prometheus.GaugeVec
+	alerting  *prometheus.GaugeVec
+	alerts    *prometheus.CounterVec
+
+	mu      sync.RWMutex
+	results []Result
+	updated time.Time
+	lastErr string
+}
+
+// New registers the om_slo_* metrics on reg and returns an evaluator of f.
+// generation returns the running core, for objectives without one;
+// grafanaClient may be nil.
+func New(reg prometheus.Registerer, f *File, prom *kpi.Prometheus, generation func() string, grafanaClient *grafana.Client, interval time.Duration) *Evaluator {
+	e := &Evaluator{
+		file:       f,
+		prom:       prom,
+		generation: generation,
+		grafana:    grafanaClient,
+		interval:   interval,
+		sli: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "slo", Name: "sli",
+			Help: "Measured value of the objective's KPI over the window; absent without data.",
+		}, []string{"objective", "window"}),
+		target: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "slo", Name: "target",
+			Help: "Target of the objective (SLO_FILE).",
+		}, []string{"objective", "op"}),
+		burnRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "slo", Name: "burn_rate",
+			Help: "Error budget burn rate of the objective over the window: 1 spends it exactly, above 1 faster than the target allows.",
+		}, []string{"objective", "window"}),
+		remaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "slo", Name: "error_budget_remaining",
+			Help: "Share of the objective's long-window error budget left (1 = untouched, 0 = exhausted).",
+		}, []string{"objective"}),
+		alerting: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "slo", Name: "alerting",
+			Help: "1 while both windows of the objective burn faster than the alert threshold.",
+		}, []string{"objective"}),
+		alerts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "slo", Name: "alerts_total",
+			Help: "Burn rate alerts raised per objective.",
+		}, []string{"objective"}),
+	}
+	for _, o := range f.Objectives {
+		e.target.WithLabelValues(o.Name, o.Op()).Set(o.Target)
+		e.alerting.WithLabelValues(o.Name).Set(0)
+		e.alerts.WithLabelValues(o.Name)
+	}
+	reg.MustRegister(e.sli, e.target, e.burnRate, e.remaining, e.alerting, e.alerts)
+	return e
+}
+
+// File returns the objectives being evaluated.
+func (e *Evaluator) File() *File { return e.file }
+
+// Run evaluates the objectives every interval until ctx is cancelled.
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.update(ctx); err != nil && ctx.Err() == nil {
+			e.mu.Lock()
+			first := e.lastErr == ""
+			e.lastErr = err.Error()
+			e.mu.Unlock()
+			if first {
+				log.Printf("⚠️  SLOs: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the objectives, alerting and most burnt first.
+func (e *Evaluator) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s := Status{
+		Lab:           e.file.Lab,
+		Interval:      e.interval.String(),
+		AlertBurnRate: e.file.AlertBurnRate,
+		Error:         e.lastErr,
+		Objectives:    append([]Result{}, e.results...),
+	}
+	if !e.updated.IsZero() {
+		s.UpdatedAt = e.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the om_slo_* metrics were last recomputed, for
+// exporter.Ages.
+func (e *Evaluator) Freshness() map[string]time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.updated.IsZero() {
+		return nil
+	}
+	out := make(map[string]time.Time, 4)
+	for _, f := range []string{"sli", "burn_rate", "error_budget_remaining", "alerting"} {
+		out["om_slo_"+f] = e.updated
+	}
+	return out
+}
+
+// update measures every objective. Objectives that cannot be measured
+// keep an error of their own; update fails only when none could.
+func (e *Evaluator) update(ctx context.Context) error {
+	e.mu.RLock()
+	previous := make(map[string]Result, len(e.results))
+	for _, r := range e.results {
+		previous[r.Name] = r
+	}
+	e.mu.RUnlock()
+
+	now := time.Now()
+	results := make([]Result, 0, len(e.file.Objectives))
+	failed := 0
+	var lastErr error
+	for _, o := range e.file.Objectives {
+		r, err := e.measure(ctx, o)
+		if err != nil {
+			failed++
+			lastErr = err
+			r.Error = err.Error()
+		}
+		prev, seen := previous[o.Name]
+		switch {
+		case r.Alerting && !prev.Alerting:
+			r.Since = now.UTC().Format(time.RFC3339)
+			e.alerts.WithLabelValues(o.Name).Inc()
+			e.raise(ctx, r, now)
+		case r.Alerting:
+			r.Since = prev.Since
+		case seen && prev.Alerting && err == nil:
+			log.Printf("✅ SLO %s back within budget (burn rate %.3g over %s)", o.Name, r.Windows[1].BurnRate, r.Windows[1].Window)
+		}
+		if err != nil && seen {
+			// A failed query keeps the alert state it had.
+			r.Alerting, r.Since = prev.Alerting, prev.Since
+		}
+		results = append(results, r)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Alerting != results[j].Alerting {
+			return results[i].Alerting
+		}
+		return results[i].Remaining < results[j].Remaining
+	})
+
+	e.export(results)
+	e.mu.Lock()
+	e.results, e.updated = results, now
+	if failed < len(results) {
+		e.lastErr = ""
+	}
+	e.mu.Unlock()
+	if failed == len(results) && lastErr != nil {
+		return lastErr
+	}
+	return nil
+}
+
+// measure evaluates o over both windows.
+func (e *Evaluator) measure(ctx context.Context, o Objective) (Result, error) {
+	r := Result{Objective: o, Op: o.Op(), Unit: o.Unit(), Generation: o.Generation, Remaining: 1, Met: true}
+	if r.Generation == "" {
+		r.Generation = e.generation()
+	}
+	windows := []time.Duration{e.file.ShortWindow, e.file.LongWindow}
+	for _, w := range windows {
+		r.Windows = append(r.Windows, Window{Window: windowName(w)})
+	}
+	if r.Generation == "" {
+		return r, fmt.Errorf("%s: no generation set and no single core running", o.Name)
+	}
+	for i, w := range windows {
+		v, err := e.prom.Eval(ctx, o.def, r.Generation, w)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", o.Name, err)
+		}
+		r.Windows[i].SLI = v.Value
+		if v.Value != nil {
+			r.Windows[i].BurnRate = burnRate(o, *v.Value)
+		}
+	}
+	long := r.Windows[1]
+	r.Remaining = max(0, 1-long.BurnRate)
+	if long.SLI != nil {
+		r.Met = meets(o, *long.SLI)
+	}
+	r.Alerting = r.Windows[0].SLI != nil && long.SLI != nil &&
+		r.Windows[0].BurnRate >= e.file.AlertBurnRate && long.BurnRate >= e.file.AlertBurnRate
+	return r, nil
+}
+
+// burnRate is how fast value spends the budget of o (see the package doc).
+func burnRate(o Objective, value float64) float64 {
+	var b float64
+	switch {
+	case o.Op() == ">=" && o.Unit() == "ratio":
+		b = (1 - value) / (1 - o.Target)
+	case o.Op() == ">=":
+		if value <= 0 {
+			return math.Inf(1)
+		}
+		b = o.Target / value
+	default:
+		b = value / o.Target
+	}
+	return math.Round(max(0, b)*1000) / 1000
+}
+
+// windowName is the label of a window: "5m", "1h", "1h30m".
+func windowName(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func meets(o Objective, value float64) bool {
+	if o.Op() == ">=" {
+		return value >= o.Target
+	}
+	return value <= o.Target
+}
+
+// raise announces a new alert of r.
+func (e *Evaluator) raise(ctx context.Context, r Result, now time.Time) {
+	text := fmt.Sprintf("SLO %s: burning its error budget %.3g× (%s) and %.3g× (%s) — alert above %g×. Target %s %s %g.",
+		r.Title, r.Windows[0].BurnRate, r.Windows[0].Window, r.Windows[1].BurnRate, r.Windows[1].Window,
+		e.file.AlertBurnRate, r.KPI, r.Op, r.Target)
+	log.Printf("🔥 %s", text)
+	if e.grafana == nil {
+		return
+	}
+	ann := grafana.Annotation{Time: now.UnixMilli(), Tags: []string{"slo", r.Name}, Text: text}
+	if _, err := e.grafana.CreateAnnotation(ctx, ann); err != nil {
+		log.Printf("⚠️  SLO alert annotation failed: %v", err)
+	}
+}
+
+// export replaces the per-window gauges, so windows without data drop out.
+func (e *Evaluator) export(results []Result) {
+	e.sli.Reset()
+	e.burnRate.Reset()
+	for _, r := range results {
+		for _, w := range r.Windows {
+			if w.SLI != nil {
+				e.sli.WithLabelValues(r.Name, w.Window).Set(*w.SLI)
+			}
+			if !math.IsInf(w.BurnRate, 0) {
+				e.burnRate.WithLabelValues(r.Name, w.Window).Set(w.BurnRate)
+			}
+		}
+		e.remaining.WithLabelValues(r.Name).Set(r.Remaining)
+		alerting := 0.0
+		if r.Alerting {
+			alerting = 1
+		}
+		e.alerting.WithLabelValues(r.Name).Set(alerting)
+	}
+}
+
+// Summary describes the objectives for the startup log.
+func (f *File) Summary() string {
+	parts := make([]string, 0, len(f.Objectives))
+	for _, o := range f.Objectives {
+		parts = append(parts, fmt.Sprintf("%s %s %g", o.KPI, o.Op(), o.Target))
+	}
+	return strings.Join(parts, ", ")
+}
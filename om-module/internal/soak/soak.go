@@ -0,0 +1,644 @@
+// Package soak runs long soak tests of the module itself. For hours it
+// samples the module's heap, goroutines (per subsystem) and open file
+// descriptors, how late every poller refreshes its data, the series the
+// module exports, the series in Prometheus' head block and the label values
+// in Loki, then writes a report of what kept growing. Leaks that only show
+// after an overnight lab run — a goroutine per reconnect, a series per UE,
+// a Loki label per container restart — surface in a single run.
+package soak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/exporter"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/Parz1val02/OM_module/internal/runtimestats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Finding kinds.
+const (
+	KindLeak  = "leak"  // a resource grew from the start to the end of the run
+	KindDrift = "drift" // a poller refreshes later and later
+	KindStale = "stale" // a poller stopped refreshing for a while
+)
+
+// Resource kinds, which set the smallest growth reported.
+const (
+	kindHeapBytes   = "heap_bytes"
+	kindHeapObjects = "heap_objects"
+	kindGoroutines  = "goroutines"
+	kindFDs         = "fds"
+	kindSeries      = "series"
+	kindLabelValues = "label_values"
+)
+
+// floors are the smallest absolute growth reported per resource kind, so a
+// few goroutines or kilobytes of heap do not make a finding.
+var floors = map[string]float64{
+	kindHeapBytes:   16 << 20,
+	kindHeapObjects: 100000,
+	kindGoroutines:  10,
+	kindFDs:         10,
+	kindSeries:      200,
+	kindLabelValues: 20,
+}
+
+const (
+	// lokiWindow is the range Loki label values are counted over; a fixed
+	// window keeps the counts of successive samples comparable.
+	lokiWindow = 15 * time.Minute
+	// queryTimeout bounds each Prometheus and Loki request.
+	queryTimeout = 10 * time.Second
+	// The start and the end of a run are the mean of edgeShare of its
+	// samples, and at least minEdge samples, at each end.
+	edgeShare = 0.1
+	minEdge   = 3
+)
+
+// Options configure the soak tests.
+type Options struct {
+	Interval time.Duration // between samples
+	// GrowthThreshold is the growth, in percent from the start to the end
+	// of the run, above which a resource is reported as leaking.
+	GrowthThreshold float64
+	PrometheusURL   string // empty: head series not sampled
+	LokiURL         string // empty: label values not sampled
+	ReportDir       string // where soak-<time>.json is written; empty: not saved
+	// Manifest records the reports written.
+	Manifest *output.Manifest
+}
+
+// Finding is a problem the soak test found.
+type Finding struct {
+	Kind     string `json:"kind"`
+	Resource string `json:"resource"`
+	Message  string `json:"message"`
+}
+
+// Trend is how one resource evolved over the run. Start and End are the
+// means of the first and last samples.
+type Trend struct {
+	Resource      string   `json:"resource"`
+	Start         float64  `json:"start"`
+	End           float64  `json:"end"`
+	Min           float64  `json:"min"`
+	Max           float64  `json:"max"`
+	GrowthPercent *float64 `json:"growth_percent,omitempty"` // nil when Start is 0
+	PerHour       float64  `json:"per_hour"`                 // least-squares slope
+	Growing       bool     `json:"growing"`
+}
+
+// Drift is how late one component refreshed its data over the run: the age
+// of its oldest metric family at each sample.
+type Drift struct {
+	Component       string  `json:"component"`
+	Interval        string  `json:"interval"`
+	StartAgeSeconds float64 `json:"start_age_seconds"`
+	EndAgeSeconds   float64 `json:"end_age_seconds"`
+	MaxAgeSeconds   float64 `json:"max_age_seconds"`
+	StaleSamples    int     `json:"stale_samples"`
+	Drifting        bool    `json:"drifting"`
+}
+
+// Report is the result of a soak test, or its progress while it runs.
+type Report struct {
+	StartedAt       string  `json:"started_at"`
+	EndedAt         string  `json:"ended_at,omitempty"`
+	Planned         string  `json:"planned"`
+	Elapsed         string  `json:"elapsed"`
+	Completed       bool    `json:"completed"` // ran for the planned duration
+	Samples         int     `json:"samples"`
+	GrowthThreshold float64 `json:"growth_threshold_percent"`
+	// Findings are the leaks, drifts and stalls, worst first.
+	Findings []Finding `json:"findings"`
+	// Resources lists the totals, and the subsystems, metric families and
+	// Loki labels that grew.
+	Resources  []Trend `json:"resources"`
+	Collectors []Drift `json:"collectors"`
+	// Errors are the sources that could not be sampled, with their last
+	// error.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Status is the API view of the soak tests.
+type Status struct {
+	Running    bool    `json:"running"`
+	Interval   string  `json:"interval"`
+	EndsAt     string  `json:"ends_at,omitempty"`
+	Current    *Report `json:"current,omitempty"` // the run in progress
+	Last       *Report `json:"last,omitempty"`    // the last finished run
+	LastReport string  `json:"last_report,omitempty"`
+}
+
+// Soak runs one soak test at a time, started with Start.
+type Soak struct {
+	opts     Options
+	ages     *exporter.Ages      // nil: poller drift not sampled
+	gatherer prometheus.Gatherer // the module's registry
+	client   *http.Client
+	start    chan time.Duration
+
+	mu         sync.Mutex
+	running    bool
+	endsAt     time.Time
+	rec        *recorder
+	last       *Report
+	lastReport string
+}
+
+// New returns a Soak that samples gatherer and ages. Call Run to serve
+// Start.
+func New(gatherer prometheus.Gatherer, ages *exporter.Ages, opts Options) *Soak {
+	return &Soak{
+		opts:     opts,
+		ages:     ages,
+		gatherer: gatherer,
+		client:   httpclient.New("soak", 0),
+		start:    make(chan time.Duration, 1),
+	}
+}
+
+// Start begins a soak test of duration d. It returns false if one is
+// already running.
+func (s *Soak) Start(d time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return false
+	}
+	s.running = true
+	s.endsAt = time.Now().Add(d)
+	s.start <- d
+	return true
+}
+
+// Run runs the soak tests Start asks for until ctx is cancelled. A test
+// cut short by the shutdown is reported as not completed.
+func (s *Soak) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-s.start:
+			s.soak(ctx, d)
+		}
+	}
+}
+
+// Status returns the progress of the running test and the last report.
+func (s *Soak) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{Running: s.running, Interval: s.opts.Interval.String(), Last: s.last, LastReport: s.lastReport}
+	if s.running {
+		st.EndsAt = s.endsAt.UTC().Format(time.RFC3339)
+	}
+	if s.running && s.rec != nil {
+		r := s.rec.report(time.Now(), false, s.opts.GrowthThreshold)
+		st.Current = &r
+	}
+	return st
+}
+
+func (s *Soak) soak(ctx context.Context, d time.Duration) {
+	log.Printf("🧪 Soak test started (%s, sampling every %s)", d, s.opts.Interval)
+	rec := newRecorder(time.Now(), d)
+	s.mu.Lock()
+	s.rec = rec
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+
+	completed := false
+	s.sample(ctx, rec)
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline.C:
+			s.sample(ctx, rec)
+			completed = true
+			break loop
+		case <-ticker.C:
+			s.sample(ctx, rec)
+		}
+	}
+
+	s.mu.Lock()
+	r := rec.report(time.Now(), completed, s.opts.GrowthThreshold)
+	s.mu.Unlock()
+
+	path := ""
+	if s.opts.ReportDir != "" {
+		path = filepath.Join(s.opts.ReportDir, "soak-"+rec.started.UTC().Format("20060102-150405")+".json")
+		if err := writeReport(path, r); err != nil {
+			log.Printf("⚠️  Soak report not saved: %v", err)
+			path = ""
+		} else {
+			s.opts.Manifest.Add(path)
+		}
+	}
+	switch {
+	case len(r.Findings) == 0:
+		log.Printf("✅ Soak test finished after %s: no growth or drift found", r.Elapsed)
+	default:
+		log.Printf("⚠️  Soak test finished after %s: %d findings", r.Elapsed, len(r.Findings))
+		for _, f := range r.Findings {
+			log.Printf("⚠️    %s", f.Message)
+		}
+	}
+
+	s.mu.Lock()
+	s.running = false
+	s.rec = nil
+	s.last = &r
+	if path != "" {
+		s.lastReport = path
+	}
+	s.mu.Unlock()
+}
+
+// sample takes one sample of every source into rec.
+func (s *Soak) sample(ctx context.Context, rec *recorder) {
+	now := time.Now()
+	values := make(map[string]resourceValue)
+	errs := make(map[string]string)
+
+	rt := runtimestats.Read()
+	values["runtime:heap_inuse_bytes"] = resourceValue{kindHeapBytes, float64(rt.HeapInuseBytes)}
+	values["runtime:heap_objects"] = resourceValue{kindHeapObjects, float64(rt.HeapObjects)}
+	values["runtime:goroutines"] = resourceValue{kindGoroutines, float64(rt.Goroutines)}
+	for sub, n := range rt.BySubsystem {
+		values["runtime:goroutines:"+sub] = resourceValue{kindGoroutines, float64(n)}
+	}
+	if rt.OpenFDs >= 0 {
+		values["runtime:open_fds"] = resourceValue{kindFDs, float64(rt.OpenFDs)}
+	}
+
+	if families, err := s.gatherer.Gather(); err != nil {
+		errs["module"] = err.Error()
+	} else {
+		total := 0
+		for _, mf := range families {
+			n := len(mf.GetMetric())
+			total += n
+			values["module:series:"+mf.GetName()] = resourceValue{kindSeries, float64(n)}
+		}
+		values["module:series"] = resourceValue{kindSeries, float64(total)}
+	}
+
+	if s.opts.PrometheusURL != "" {
+		if n, err := s.headSeries(ctx); err != nil {
+			errs["prometheus"] = err.Error()
+		} else {
+			values["prometheus:head_series"] = resourceValue{kindSeries, n}
+		}
+	}
+
+	if s.opts.LokiURL != "" {
+		if counts, err := s.lokiLabelValues(ctx); err != nil {
+			errs["loki"] = err.Error()
+		} else {
+			total := 0
+			for label, n := range counts {
+				total += n
+				values["loki:label_values:"+label] = resourceValue{kindLabelValues, float64(n)}
+			}
+			values["loki:label_values"] = resourceValue{kindLabelValues, float64(total)}
+		}
+	}
+
+	var ages []exporter.Age
+	if s.ages != nil {
+		ages = s.ages.Snapshot()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.add(now, values, ages, errs)
+}
+
+// headSeries reads the number of series in Prometheus' head block.
+func (s *Soak) headSeries(ctx context.Context) (float64, error) {
+	var body struct {
+		Data struct {
+			HeadStats struct {
+				NumSeries float64 `json:"numSeries"`
+			} `json:"headStats"`
+		} `json:"data"`
+	}
+	err := s.get(ctx, strings.TrimRight(s.opts.PrometheusURL, "/")+"/api/v1/status/tsdb", &body)
+	return body.Data.HeadStats.NumSeries, err
+}
+
+// lokiLabelValues counts the values of every Loki label over lokiWindow.
+func (s *Soak) lokiLabelValues(ctx context.Context) (map[string]int, error) {
+	base := strings.TrimRight(s.opts.LokiURL, "/") + "/loki/api/v1/"
+	q := url.Values{"since": {lokiWindow.String()}}.Encode()
+	var labels struct {
+		Data []string `json:"data"`
+	}
+	if err := s.get(ctx, base+"labels?"+q, &labels); err != nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(labels.Data))
+	for _, label := range labels.Data {
+		var values struct {
+			Data []string `json:"data"`
+		}
+		if err := s.get(ctx, base+"label/"+url.PathEscape(label)+"/values?"+q, &values); err != nil {
+			return nil, err
+		}
+		out[label] = len(values.Data)
+	}
+	return out, nil
+}
+
+func (s *Soak) get(ctx context.Context, target string, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", req.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func writeReport(path string, r Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tx := output.NewTxn()
+	tx.WriteFile(path, append(data, '\n'), 0o644)
+	return tx.Commit()
+}
+
+// --- recording and analysis ----------------------------------------------
+
+type resourceValue struct {
+	kind  string
+	value float64
+}
+
+// samples is the history of one resource; resources that appear during the
+// run (a new subsystem, metric family or label) start late.
+type samples struct {
+	kind   string
+	times  []time.Time
+	values []float64
+}
+
+// ageSamples is the history of one component's oldest family age.
+type ageSamples struct {
+	interval time.Duration
+	ages     []float64 // seconds
+	stale    int
+}
+
+type recorder struct {
+	started   time.Time
+	planned   time.Duration
+	samples   int
+	resources map[string]*samples
+	ages      map[string]*ageSamples
+	errors    map[string]string
+}
+
+func newRecorder(started time.Time, planned time.Duration) *recorder {
+	return &recorder{
+		started:   started,
+		planned:   planned,
+		resources: make(map[string]*samples),
+		ages:      make(map[string]*ageSamples),
+		errors:    make(map[string]string),
+	}
+}
+
+func (r *recorder) add(now time.Time, values map[string]resourceValue, ages []exporter.Age, errs map[string]string) {
+	r.samples++
+	for name, v := range values {
+		s := r.resources[name]
+		if s == nil {
+			s = &samples{kind: v.kind}
+			r.resources[name] = s
+		}
+		s.times = append(s.times, now)
+		s.values = append(s.values, v.value)
+	}
+
+	oldest := make(map[string]exporter.Age)
+	for _, a := range ages {
+		if o, ok := oldest[a.Component]; !ok || a.Age > o.Age {
+			oldest[a.Component] = a
+		}
+	}
+	for comp, a := range oldest {
+		s := r.ages[comp]
+		if s == nil {
+			s = &ageSamples{interval: a.Interval}
+			r.ages[comp] = s
+		}
+		s.ages = append(s.ages, a.Age.Seconds())
+		if a.Stale() {
+			s.stale++
+		}
+	}
+	for src, msg := range errs {
+		r.errors[src] = msg
+	}
+}
+
+func (r *recorder) report(now time.Time, completed bool, threshold float64) Report {
+	rep := Report{
+		StartedAt:       r.started.UTC().Format(time.RFC3339),
+		Planned:         r.planned.String(),
+		Elapsed:         now.Sub(r.started).Round(time.Second).String(),
+		Completed:       completed,
+		Samples:         r.samples,
+		GrowthThreshold: threshold,
+		Findings:        []Finding{},
+		Resources:       []Trend{},
+		Collectors:      []Drift{},
+	}
+	if completed || now.Sub(r.started) >= r.planned {
+		rep.EndedAt = now.UTC().Format(time.RFC3339)
+	}
+
+	names := make([]string, 0, len(r.resources))
+	for name := range r.resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := trend(name, r.resources[name], threshold)
+		// Per-subsystem, per-family and per-label resources are listed
+		// only when they grew; the totals always are.
+		if strings.Count(name, ":") > 1 && !t.Growing {
+			continue
+		}
+		rep.Resources = append(rep.Resources, t)
+		if t.Growing {
+			rep.Findings = append(rep.Findings, Finding{
+				Kind: KindLeak, Resource: name,
+				Message: fmt.Sprintf("%s grew from %s to %s (+%s/h)", name,
+					format(r.resources[name].kind, t.Start), format(r.resources[name].kind, t.End), format(r.resources[name].kind, t.PerHour)),
+			})
+		}
+	}
+
+	comps := make([]string, 0, len(r.ages))
+	for comp := range r.ages {
+		comps = append(comps, comp)
+	}
+	sort.Strings(comps)
+	for _, comp := range comps {
+		d := drift(comp, r.ages[comp])
+		rep.Collectors = append(rep.Collectors, d)
+		if d.Drifting {
+			rep.Findings = append(rep.Findings, Finding{
+				Kind: KindDrift, Resource: comp,
+				Message: fmt.Sprintf("%s refreshes later and later: data %.0fs old at the start, %.0fs at the end (interval %s)",
+					comp, d.StartAgeSeconds, d.EndAgeSeconds, d.Interval),
+			})
+		}
+		if d.StaleSamples > 0 {
+			rep.Findings = append(rep.Findings, Finding{
+				Kind: KindStale, Resource: comp,
+				Message: fmt.Sprintf("%s missed 3 refreshes in %d of %d samples (up to %.0fs old)",
+					comp, d.StaleSamples, len(r.ages[comp].ages), d.MaxAgeSeconds),
+			})
+		}
+	}
+	sort.SliceStable(rep.Findings, func(i, j int) bool {
+		return findingOrder(rep.Findings[i].Kind) < findingOrder(rep.Findings[j].Kind)
+	})
+
+	if len(r.errors) > 0 {
+		rep.Errors = make(map[string]string, len(r.errors))
+		for src, msg := range r.errors {
+			rep.Errors[src] = msg
+		}
+	}
+	return rep
+}
+
+// trend compares the start and the end of a resource's history. It grows
+// when the end is above the start by the threshold and the resource's floor
+// and the fitted slope is positive; runs too short to have a distinct start
+// and end never do.
+func trend(name string, s *samples, threshold float64) Trend {
+	n := len(s.values)
+	edge := max(minEdge, int(float64(n)*edgeShare))
+	t := Trend{Resource: name, Min: s.values[0], Max: s.values[0]}
+	for _, v := range s.values {
+		t.Min = math.Min(t.Min, v)
+		t.Max = math.Max(t.Max, v)
+	}
+	if n < 2*edge {
+		t.Start, t.End = s.values[0], s.values[n-1]
+	} else {
+		t.Start, t.End = mean(s.values[:edge]), mean(s.values[n-edge:])
+	}
+	if t.Start != 0 {
+		g := (t.End - t.Start) / t.Start * 100
+		t.GrowthPercent = &g
+	}
+	t.PerHour = slope(s.times, s.values) * 3600
+	t.Growing = n >= 2*edge && t.PerHour > 0 &&
+		t.End-t.Start >= floors[s.kind] &&
+		(t.GrowthPercent == nil || *t.GrowthPercent >= threshold)
+	return t
+}
+
+// drift compares how old a component's data was at the start and the end
+// of the run. It drifts when the age grew by half an interval or more.
+func drift(comp string, s *ageSamples) Drift {
+	n := len(s.ages)
+	edge := max(minEdge, int(float64(n)*edgeShare))
+	d := Drift{Component: comp, Interval: s.interval.String(), StaleSamples: s.stale}
+	for _, a := range s.ages {
+		d.MaxAgeSeconds = math.Max(d.MaxAgeSeconds, a)
+	}
+	if n < 2*edge {
+		d.StartAgeSeconds, d.EndAgeSeconds = s.ages[0], s.ages[n-1]
+		return d
+	}
+	d.StartAgeSeconds, d.EndAgeSeconds = mean(s.ages[:edge]), mean(s.ages[n-edge:])
+	d.Drifting = d.EndAgeSeconds-d.StartAgeSeconds >= s.interval.Seconds()/2
+	return d
+}
+
+func mean(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
+
+// slope is the least-squares slope of values over times, per second.
+func slope(times []time.Time, values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	var sx, sy, sxx, sxy float64
+	for i, v := range values {
+		x := times[i].Sub(times[0]).Seconds()
+		sx += x
+		sy += v
+		sxx += x * x
+		sxy += x * v
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
+
+// format renders a value of a resource of kind: heap sizes in MiB, counts
+// without decimals.
+func format(kind string, v float64) string {
+	if kind == kindHeapBytes {
+		return fmt.Sprintf("%.1f MiB", v/(1<<20))
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+func findingOrder(kind string) int {
+	switch kind {
+	case KindLeak:
+		return 0
+	case KindDrift:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,472 @@
+// Package subscribers watches the Open5GS subscriber database for drift: bulk
+// changes between two checks (many subscribers deleted, inserted or re-keyed
+// at once), duplicate IMSIs and subscribers whose IMSI or keys are
+// malformed. On a shared lab database these are the traces of a student
+// script run against the wrong container or a WebUI import gone wrong, and
+// they break every other group's attach at once.
+//
+// The collection is read with mongosh in the mongo container, like the
+// synthetic test provisions its subscriber. Only IMSIs ever leave the
+// module; the keys are compared, never exported.
+package subscribers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event kinds.
+const (
+	KindMassDeletion = "mass_deletion"
+	KindBulkInsert   = "bulk_insert"
+	KindBulkUpdate   = "bulk_update"
+	KindDuplicate    = "duplicate_imsi"
+	KindMalformed    = "malformed"
+)
+
+const (
+	// maxRecent is how many events Status keeps.
+	maxRecent = 50
+	// maxListed is how many IMSIs an event names.
+	maxListed = 10
+)
+
+// dump prints every subscriber as {imsi, k, opc, op, amf, synthetic}. Values
+// keep their BSON type, so an IMSI stored as a number shows up as one.
+const dump = `const s = db.getSiblingDB('open5gs').subscribers.find({}, {_id: 0, imsi: 1, security: 1, om_synthetic: 1}).toArray();
+print(JSON.stringify(s.map(d => ({imsi: d.imsi, k: d.security && d.security.k, opc: d.security && d.security.opc,
+  op: d.security && d.security.op, amf: d.security && d.security.amf, synthetic: d.om_synthetic === true}))));`
+
+// digest prints every subscriber but the synthetic test's as {imsi, doc},
+// doc being the whole document as canonical Extended JSON.
+const digest = `const s = db.getSiblingDB('open5gs').subscribers.find({om_synthetic: {$ne: true}}, {_id: 0}).toArray();
+print(JSON.stringify(s.map(d => ({imsi: d.imsi, doc: EJSON.stringify(d, {relaxed: false})}))));`
+
+var (
+	imsiRe = regexp.MustCompile(`^[0-9]{6,15}$`)
+	keyRe  = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	amfRe  = regexp.MustCompile(`^[0-9a-fA-F]{4}$`)
+)
+
+// Duplicate is an IMSI stored more than once.
+type Duplicate struct {
+	IMSI  string `json:"imsi"`
+	Count int    `json:"count"`
+}
+
+// Malformed is a subscriber with fields Open5GS cannot use: "imsi" (not 6–15
+// digits), "k" (not 32 hex digits), "opc" (neither OPc nor OP is 32 hex
+// digits) or "amf" (not 4 hex digits).
+type Malformed struct {
+	IMSI   string   `json:"imsi"`
+	Fields []string `json:"fields"`
+}
+
+// Event is a drift the watcher reported.
+type Event struct {
+	Time    string   `json:"time"`
+	Kind    string   `json:"kind"`
+	Count   int      `json:"count"`
+	IMSIs   []string `json:"imsis"` // at most 10
+	Message string   `json:"message"`
+}
+
+// Status is the API view of the watcher.
+type Status struct {
+	Container   string      `json:"container"`
+	Interval    string      `json:"interval"`
+	Threshold   int         `json:"threshold"`
+	UpdatedAt   string      `json:"updated_at,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Subscribers int         `json:"subscribers"`
+	Duplicates  []Duplicate `json:"duplicates"`
+	Malformed   []Malformed `json:"malformed"`
+	Recent      []Event     `json:"recent"` // newest first
+}
+
+// record is one subscriber as dumped by mongosh.
+type record struct {
+	IMSI      any  `json:"imsi"`
+	K         any  `json:"k"`
+	OPc       any  `json:"opc"`
+	OP        any  `json:"op"`
+	AMF       any  `json:"amf"`
+	Synthetic bool `json:"synthetic"`
+}
+
+// Watcher checks the subscriber collection every interval.
+type Watcher struct {
+	docker    *dockerclient.Client
+	grafana   *grafana.Client
+	container string
+	interval  time.Duration
+	threshold int
+
+	count     prometheus.Gauge
+	dups      prometheus.Gauge
+	malformed *prometheus.GaugeVec
+	changes   *prometheus.CounterVec
+	events    *prometheus.CounterVec
+
+	mu         sync.RWMutex
+	keys       map[string]string // IMSI → hash of its keys, at the last check
+	total      int
+	primed     bool
+	duplicates []Duplicate
+	bad        []Malformed
+	recent     []Event
+	updated    time.Time
+	lastErr    string
+}
+
+// New registers the om_subscribers_* metrics on reg. A bulk change is
+// threshold or more subscribers deleted, inserted or re-keyed between two
+// checks. grafanaClient may be nil, in which case events are not annotated.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, grafanaClient *grafana.Client, container string, interval time.Duration, threshold int) *Watcher {
+	w := &Watcher{
+		docker:    docker,
+		grafana:   grafanaClient,
+		container: container,
+		interval:  interval,
+		threshold: threshold,
+		count: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "subscribers", Name: "count",
+			Help: "Subscribers in the Open5GS database, without the synthetic test subscriber.",
+		}),
+		dups: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "subscribers", Name: "duplicate_imsis",
+			Help: "IMSIs stored more than once in the Open5GS subscriber database.",
+		}),
+		malformed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "subscribers", Name: "malformed",
+			Help: "Subscribers with a malformed field (imsi | k | opc | amf).",
+		}, []string{"field"}),
+		changes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "subscribers", Name: "changes_total",
+			Help: "Subscribers added, removed or re-keyed between two checks of the database.",
+		}, []string{"change"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "subscribers", Name: "drift_events_total",
+			Help: "Subscriber database drift events (mass_deletion | bulk_insert | bulk_update | duplicate_imsi | malformed).",
+		}, []string{"kind"}),
+	}
+	for _, f := range []string{"imsi", "k", "opc", "amf"} {
+		w.malformed.WithLabelValues(f)
+	}
+	reg.MustRegister(w.count, w.dups, w.malformed, w.changes, w.events)
+	return w
+}
+
+// Run checks every interval until ctx is cancelled. Changes are counted from
+// the first successful check on, so the database the module finds at start
+// is the baseline; duplicates and malformed subscribers already there are
+// reported.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(ctx); err != nil && ctx.Err() == nil {
+			w.mu.Lock()
+			first := w.lastErr == ""
+			w.lastErr = err.Error()
+			w.mu.Unlock()
+			if first {
+				log.Printf("⚠️  Subscriber watch: %v", err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the state found by the last check and the latest events.
+func (w *Watcher) Status() Status {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	s := Status{
+		Container:   w.container,
+		Interval:    w.interval.String(),
+		Threshold:   w.threshold,
+		Error:       w.lastErr,
+		Subscribers: w.total,
+		Duplicates:  append([]Duplicate{}, w.duplicates...),
+		Malformed:   append([]Malformed{}, w.bad...),
+		Recent:      append([]Event{}, w.recent...),
+	}
+	if !w.updated.IsZero() {
+		s.UpdatedAt = w.updated.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+// Freshness returns when the database was last read, for exporter.Ages.
+func (w *Watcher) Freshness() map[string]time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.updated.IsZero() {
+		return nil
+	}
+	return map[string]time.Time{"om_subscribers_count": w.updated}
+}
+
+func (w *Watcher) check(ctx context.Context) error {
+	out, code, err := w.docker.Exec(ctx, w.container, []string{"mongosh", "--quiet", "--eval", dump})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("mongosh exited with %d: %s", code, lastLine(out))
+	}
+	var records []record
+	if err := json.Unmarshal([]byte(lastLine(out)), &records); err != nil {
+		return fmt.Errorf("unexpected mongosh output: %w", err)
+	}
+	now := time.Now()
+
+	total := 0
+	keys := make(map[string]string, len(records))
+	seen := make(map[string]int, len(records))
+	var bad []Malformed
+	badFields := map[string]int{"imsi": 0, "k": 0, "opc": 0, "amf": 0}
+	for _, r := range records {
+		if r.Synthetic {
+			continue
+		}
+		imsi := text(r.IMSI)
+		total++
+		seen[imsi]++
+		keys[imsi] = keyHash(r)
+		if fields := validate(r); len(fields) > 0 {
+			bad = append(bad, Malformed{IMSI: imsi, Fields: fields})
+			for _, f := range fields {
+				badFields[f]++
+			}
+		}
+	}
+	var dups []Duplicate
+	for imsi, n := range seen {
+		if n > 1 {
+			dups = append(dups, Duplicate{IMSI: imsi, Count: n})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].IMSI < dups[j].IMSI })
+	sort.Slice(bad, func(i, j int) bool { return bad[i].IMSI < bad[j].IMSI })
+
+	w.mu.Lock()
+	var events []Event
+	event := func(kind string, imsis []string, format string, args ...any) {
+		sort.Strings(imsis)
+		ev := Event{Time: now.UTC().Format(time.RFC3339), Kind: kind, Count: len(imsis), IMSIs: imsis}
+		if len(ev.IMSIs) > maxListed {
+			ev.IMSIs = ev.IMSIs[:maxListed]
+		}
+		ev.Message = fmt.Sprintf(format, args...) + " (" + strings.Join(ev.IMSIs, ", ")
+		if len(imsis) > maxListed {
+			ev.Message += ", …"
+		}
+		ev.Message += ")"
+		events = append(events, ev)
+	}
+
+	if w.primed {
+		var added, removed, rekeyed []string
+		for imsi, h := range keys {
+			prev, ok := w.keys[imsi]
+			switch {
+			case !ok:
+				added = append(added, imsi)
+			case prev != h:
+				rekeyed = append(rekeyed, imsi)
+			}
+		}
+		for imsi := range w.keys {
+			if _, ok := keys[imsi]; !ok {
+				removed = append(removed, imsi)
+			}
+		}
+		w.changes.WithLabelValues("added").Add(float64(len(added)))
+		w.changes.WithLabelValues("removed").Add(float64(len(removed)))
+		w.changes.WithLabelValues("rekeyed").Add(float64(len(rekeyed)))
+		since := now.Sub(w.updated).Round(time.Second)
+		if len(removed) >= w.threshold {
+			event(KindMassDeletion, removed, "%d of %d subscribers deleted in %s", len(removed), len(w.keys), since)
+		}
+		if len(added) >= w.threshold {
+			event(KindBulkInsert, added, "%d subscribers inserted in %s", len(added), since)
+		}
+		if len(rekeyed) >= w.threshold {
+			event(KindBulkUpdate, rekeyed, "keys of %d subscribers changed in %s", len(rekeyed), since)
+		}
+	}
+
+	// Duplicates and malformed subscribers are reported when they appear.
+	known := make(map[string]bool, len(w.duplicates))
+	for _, d := range w.duplicates {
+		known[d.IMSI] = true
+	}
+	var newDups []string
+	for _, d := range dups {
+		if !known[d.IMSI] {
+			newDups = append(newDups, d.IMSI)
+		}
+	}
+	if len(newDups) > 0 {
+		event(KindDuplicate, newDups, "%d IMSI(s) stored more than once", len(newDups))
+	}
+	known = make(map[string]bool, len(w.bad))
+	for _, m := range w.bad {
+		known[m.IMSI+"/"+strings.Join(m.Fields, ",")] = true
+	}
+	var newBad []string
+	fields := map[string]bool{}
+	for _, m := range bad {
+		if !known[m.IMSI+"/"+strings.Join(m.Fields, ",")] {
+			newBad = append(newBad, m.IMSI)
+			for _, f := range m.Fields {
+				fields[f] = true
+			}
+		}
+	}
+	if len(newBad) > 0 {
+		var names []string
+		for f := range fields {
+			names = append(names, f)
+		}
+		sort.Strings(names)
+		event(KindMalformed, newBad, "%d malformed subscriber(s), bad %s", len(newBad), strings.Join(names, "/"))
+	}
+
+	w.keys, w.total, w.primed = keys, total, true
+	w.duplicates, w.bad = dups, bad
+	w.recent = append(append([]Event{}, events...), w.recent...)
+	if len(w.recent) > maxRecent {
+		w.recent = w.recent[:maxRecent]
+	}
+	w.updated, w.lastErr = now, ""
+	w.mu.Unlock()
+
+	w.count.Set(float64(total))
+	w.dups.Set(float64(len(dups)))
+	for f, n := range badFields {
+		w.malformed.WithLabelValues(f).Set(float64(n))
+	}
+	for _, ev := range events {
+		w.events.WithLabelValues(ev.Kind).Inc()
+		log.Printf("⚠️  Subscriber database: %s", ev.Message)
+		if w.grafana != nil {
+			w.annotate(ctx, now, ev)
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) annotate(ctx context.Context, at time.Time, ev Event) {
+	a := grafana.Annotation{
+		Time: at.UnixMilli(),
+		Tags: []string{"subscribers", ev.Kind},
+		Text: "🗄️ Subscriber database: " + ev.Message,
+	}
+	if _, err := w.grafana.CreateAnnotation(ctx, a); err != nil {
+		log.Printf("⚠️  Subscriber watch: Grafana annotation failed: %v", err)
+	}
+}
+
+// validate returns the malformed fields of r.
+func validate(r record) []string {
+	var fields []string
+	if s, ok := r.IMSI.(string); !ok || !imsiRe.MatchString(s) {
+		fields = append(fields, "imsi")
+	}
+	if s, ok := r.K.(string); !ok || !keyRe.MatchString(s) {
+		fields = append(fields, "k")
+	}
+	opc, _ := r.OPc.(string)
+	op, _ := r.OP.(string)
+	if !keyRe.MatchString(opc) && !keyRe.MatchString(op) {
+		fields = append(fields, "opc")
+	}
+	if s, ok := r.AMF.(string); !ok || !amfRe.MatchString(s) {
+		fields = append(fields, "amf")
+	}
+	return fields
+}
+
+// Digest reads the subscriber collection in container and returns a hash of
+// every subscriber document by IMSI, for `om-module snapshot` to record and
+// `om-module verify` to compare. A change to any field, keys included,
+// changes the hash; the documents themselves never leave the module. An IMSI
+// stored more than once is reported with its copies numbered.
+func Digest(ctx context.Context, docker *dockerclient.Client, container string) (map[string]string, error) {
+	out, code, err := docker.Exec(ctx, container, []string{"mongosh", "--quiet", "--eval", digest})
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("mongosh exited with %d: %s", code, lastLine(out))
+	}
+	var docs []struct {
+		IMSI any    `json:"imsi"`
+		Doc  string `json:"doc"`
+	}
+	if err := json.Unmarshal([]byte(lastLine(out)), &docs); err != nil {
+		return nil, fmt.Errorf("unexpected mongosh output: %w", err)
+	}
+	sums := make(map[string]string, len(docs))
+	for _, d := range docs {
+		imsi := text(d.IMSI)
+		name := imsi
+		for n := 2; ; n++ {
+			if _, dup := sums[name]; !dup {
+				break
+			}
+			name = fmt.Sprintf("%s#%d", imsi, n)
+		}
+		sum := sha256.Sum256([]byte(d.Doc))
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+	return sums, nil
+}
+
+// keyHash identifies the credentials of r without keeping them.
+func keyHash(r record) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{text(r.K), text(r.OPc), text(r.OP), text(r.AMF)}, "/")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// text renders a JSON value as the string it was stored as; numbers and
+// other types keep their JSON form.
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
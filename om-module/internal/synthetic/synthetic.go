@@ -0,0 +1,461 @@
+// Package synthetic runs an active end-to-end health test of the 5G lab: a
+// throw-away subscriber is provisioned in the Open5GS MongoDB, a second
+// UERANSIM nr-ue instance in the UE simulator container registers with it,
+// and the module checks that the attach was visible everywhere a student
+// would look for it — NAS result, captured signalling and Loki logs — before
+// deregistering and deleting the subscriber again.
+//
+// Container liveness says the NFs are up; this says a UE can actually attach.
+package synthetic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/qos"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Log lines nr-ue prints when the procedures complete.
+const (
+	registrationOK = "Initial Registration is successful"
+	pduSessionOK   = "PDU Session establishment is successful"
+)
+
+// ueConfigCopy is where the synthetic UE's config is written inside the UE
+// container.
+const ueConfigCopy = "/tmp/om-synthetic-ue.yaml"
+
+// lokiWait bounds how long the logs check waits for promtail to ship the
+// attach lines.
+const lokiWait = 20 * time.Second
+
+// Config describes where the test runs.
+type Config struct {
+	MongoContainer string        // Open5GS subscriber database
+	UEContainer    string        // UERANSIM UE container the test nr-ue runs in
+	UEConfig       string        // UE config inside UEContainer used as template
+	IMSI           string        // test subscriber; derived from MCC/MNC when empty
+	AttachWindow   time.Duration // how long the test UE stays attached
+	LokiURL        string        // empty skips the logs check
+	LokiTimeout    time.Duration
+}
+
+// Check is one verification step of a run.
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Result is the outcome of one test run.
+type Result struct {
+	IMSI            string  `json:"imsi"`
+	StartedAt       string  `json:"started_at"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Passed          bool    `json:"passed"`
+	Checks          []Check `json:"checks"`
+	Error           string  `json:"error,omitempty"` // set when the run aborted before the checks
+}
+
+// Runner executes test runs, one at a time, on demand or every interval.
+type Runner struct {
+	docker   *dockerclient.Client
+	qos      *qos.Tracker
+	cfg      Config
+	interval time.Duration
+	client   *http.Client
+	trigger  chan struct{}
+
+	passed   prometheus.Gauge
+	checks   *prometheus.GaugeVec
+	duration prometheus.Gauge
+	lastRun  prometheus.Gauge
+	runs     *prometheus.CounterVec
+
+	mu      sync.Mutex
+	running bool
+	last    *Result
+}
+
+// New registers the synthetic test metrics on reg. qosTracker may be nil,
+// in which case the capture check is skipped. interval 0 runs only on demand.
+func New(reg prometheus.Registerer, docker *dockerclient.Client, qosTracker *qos.Tracker, cfg Config, interval time.Duration) *Runner {
+	r := &Runner{
+		docker:   docker,
+		qos:      qosTracker,
+		cfg:      cfg,
+		interval: interval,
+		client:   httpclient.New("synthetic", 0),
+		trigger:  make(chan struct{}, 1),
+		passed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "synthetic", Name: "test_passed",
+			Help: "1 if the last synthetic subscriber test passed every check, 0 otherwise.",
+		}),
+		checks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "synthetic", Name: "check_passed",
+			Help: "Result of each check of the last synthetic subscriber test.",
+		}, []string{"check"}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "synthetic", Name: "test_duration_seconds",
+			Help: "Duration of the last synthetic subscriber test.",
+		}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "om", Subsystem: "synthetic", Name: "last_run_timestamp_seconds",
+			Help: "Unix time the last synthetic subscriber test finished.",
+		}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "om", Subsystem: "synthetic", Name: "runs_total",
+			Help: "Synthetic subscriber test runs by result (pass|fail).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(r.passed, r.checks, r.duration, r.lastRun, r.runs)
+	return r
+}
+
+// Run executes triggered runs, and one every interval when set, until ctx
+// is cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			if !r.start() {
+				continue
+			}
+		case <-r.trigger:
+		}
+		r.finish(r.run(ctx))
+	}
+}
+
+// Trigger asks for a run. It returns false when a run is already in progress.
+func (r *Runner) Trigger() bool {
+	if !r.start() {
+		return false
+	}
+	r.trigger <- struct{}{}
+	return true
+}
+
+// Status returns whether a run is in progress and the last result, if any.
+func (r *Runner) Status() (bool, *Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.last == nil {
+		return r.running, nil
+	}
+	last := *r.last
+	last.Checks = append([]Check{}, r.last.Checks...)
+	return r.running, &last
+}
+
+func (r *Runner) start() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return false
+	}
+	r.running = true
+	return true
+}
+
+func (r *Runner) finish(res Result) {
+	r.mu.Lock()
+	r.running = false
+	r.last = &res
+	r.mu.Unlock()
+
+	r.checks.Reset()
+	for _, c := range res.Checks {
+		r.checks.WithLabelValues(c.Name).Set(boolValue(c.Passed))
+	}
+	r.passed.Set(boolValue(res.Passed))
+	r.duration.Set(res.DurationSeconds)
+	r.lastRun.SetToCurrentTime()
+	if res.Passed {
+		r.runs.WithLabelValues("pass").Inc()
+		log.Printf("✅ Synthetic test passed for imsi-%s in %.1fs", res.IMSI, res.DurationSeconds)
+		return
+	}
+	r.runs.WithLabelValues("fail").Inc()
+	var failed []string
+	for _, c := range res.Checks {
+		if !c.Passed {
+			failed = append(failed, c.Name+": "+c.Detail)
+		}
+	}
+	if res.Error != "" {
+		failed = append(failed, res.Error)
+	}
+	log.Printf("⚠️  Synthetic test failed for imsi-%s: %s", res.IMSI, strings.Join(failed, "; "))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ueEnv is the part of the UE container's environment the test needs.
+type ueEnv struct {
+	mcc, mnc, ki, op, amf string
+}
+
+func (r *Runner) run(ctx context.Context) Result {
+	start := time.Now()
+	res := Result{StartedAt: start.UTC().Format(time.RFC3339), Checks: []Check{}}
+	defer func() { res.DurationSeconds = time.Since(start).Seconds() }()
+
+	env, err := r.ueEnv(ctx)
+	if err != nil {
+		res.Error = "UE simulator: " + err.Error()
+		res.IMSI = r.cfg.IMSI
+		return res
+	}
+	res.IMSI = r.cfg.IMSI
+	if res.IMSI == "" {
+		msin := strings.Repeat("9", 15-len(env.mcc)-len(env.mnc))
+		res.IMSI = env.mcc + env.mnc + msin
+	}
+	add := func(name string, passed bool, detail string) {
+		res.Checks = append(res.Checks, Check{Name: name, Passed: passed, Detail: detail})
+	}
+
+	if err := r.provision(ctx, res.IMSI, env); err != nil {
+		add("provision", false, err.Error())
+		return res
+	}
+	add("provision", true, "subscriber inserted in "+r.cfg.MongoContainer)
+
+	// The capture check samples the QoS table while the UE is attached.
+	seen := make(chan bool, 1)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	go func() { seen <- r.watchQoS(watchCtx, res.IMSI) }()
+
+	out, err := r.attach(ctx, res.IMSI)
+	stopWatch()
+	sawFlow := <-seen
+	switch {
+	case err != nil:
+		add("registration", false, err.Error())
+	case strings.Contains(out, registrationOK):
+		add("registration", true, registrationOK)
+	default:
+		add("registration", false, "nr-ue did not report a successful registration: "+lastLine(out))
+	}
+	if err == nil {
+		if strings.Contains(out, pduSessionOK) {
+			add("pdu_session", true, pduSessionOK)
+		} else {
+			add("pdu_session", false, "nr-ue did not report a PDU session")
+		}
+	}
+	if r.qos != nil {
+		if sawFlow {
+			add("capture", true, "QoS flow observed in the capture")
+		} else {
+			add("capture", false, "no QoS flow for the subscriber in the capture")
+		}
+	}
+	if r.cfg.LokiURL != "" {
+		if n, err := r.waitForLogs(ctx, res.IMSI, start); err != nil {
+			add("logs", false, err.Error())
+		} else {
+			add("logs", true, fmt.Sprintf("%d core log lines in Loki", n))
+		}
+	}
+
+	// Cleanup runs on a fresh context so a shutdown mid-test still removes
+	// the subscriber.
+	cleanCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := r.deprovision(cleanCtx, res.IMSI); err != nil {
+		add("cleanup", false, err.Error())
+	} else {
+		add("cleanup", true, "subscriber deleted")
+	}
+
+	res.Passed = true
+	for _, c := range res.Checks {
+		res.Passed = res.Passed && c.Passed
+	}
+	return res
+}
+
+// ueEnv reads the PLMN and credentials the lab's UEs use from the UE
+// container, so the test subscriber matches whatever .env configures.
+func (r *Runner) ueEnv(ctx context.Context) (ueEnv, error) {
+	out, code, err := r.docker.Exec(ctx, r.cfg.UEContainer, []string{"printenv", "MCC", "MNC", "UE1_KI", "UE1_OP", "UE1_AMF"})
+	if err != nil {
+		return ueEnv{}, err
+	}
+	lines := strings.Fields(out)
+	if code != 0 || len(lines) != 5 {
+		return ueEnv{}, fmt.Errorf("MCC, MNC, UE1_KI, UE1_OP or UE1_AMF not set in %s", r.cfg.UEContainer)
+	}
+	return ueEnv{mcc: lines[0], mnc: lines[1], ki: lines[2], op: lines[3], amf: lines[4]}, nil
+}
+
+// provision upserts a 5G subscriber with the default slice (SST 1, SD
+// 000001) and DNN "internet", like scripts/mongo_insert.sh.
+func (r *Runner) provision(ctx context.Context, imsi string, env ueEnv) error {
+	js := fmt.Sprintf(`db = db.getSiblingDB('open5gs');
+db.subscribers.replaceOne({imsi: '%[1]s'}, {
+  imsi: '%[1]s', msisdn: [], mme_host: [], mme_realm: [], purge_flag: [],
+  access_restriction_data: 32, subscriber_status: 0, operator_determined_barring: 0,
+  network_access_mode: 0, subscribed_rau_tau_timer: 12, schema_version: 1, __v: 0,
+  ambr: {downlink: {value: 1, unit: 3}, uplink: {value: 1, unit: 3}},
+  security: {k: '%[2]s', amf: '%[3]s', op: '%[4]s', opc: null, sqn: NumberLong('0')},
+  slice: [{sst: 1, sd: '000001', default_indicator: true, session: [{
+    name: 'internet', type: 3,
+    qos: {index: 9, arp: {priority_level: 8, pre_emption_capability: 1, pre_emption_vulnerability: 1}},
+    ambr: {downlink: {value: 1, unit: 3}, uplink: {value: 1, unit: 3}}, pcc_rule: []}]}],
+  om_synthetic: true
+}, {upsert: true});`, imsi, env.ki, env.amf, env.op)
+	return r.mongo(ctx, js)
+}
+
+func (r *Runner) deprovision(ctx context.Context, imsi string) error {
+	return r.mongo(ctx, fmt.Sprintf(`db.getSiblingDB('open5gs').subscribers.deleteOne({imsi: '%s', om_synthetic: true});`, imsi))
+}
+
+func (r *Runner) mongo(ctx context.Context, js string) error {
+	out, code, err := r.docker.Exec(ctx, r.cfg.MongoContainer, []string{"mongosh", "--quiet", "--eval", js})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("mongosh exited with %d: %s", code, lastLine(out))
+	}
+	return nil
+}
+
+// attach starts a second nr-ue with the test SUPI next to the lab's UE,
+// keeps it attached for the attach window, deregisters it and returns its
+// log.
+func (r *Runner) attach(ctx context.Context, imsi string) (string, error) {
+	secs := strconv.Itoa(int(r.cfg.AttachWindow.Seconds()))
+	script := strings.Join([]string{
+		`sed 's|^supi:.*|supi: "imsi-` + imsi + `"|' ` + r.cfg.UEConfig + ` > ` + ueConfigCopy,
+		`cd /UERANSIM/build`,
+		`./nr-ue -c ` + ueConfigCopy + ` > /tmp/om-synthetic-ue.log 2>&1 & pid=$!`,
+		`sleep ` + secs,
+		`./nr-cli imsi-` + imsi + ` -e 'deregister switch-off' >/dev/null 2>&1`,
+		`sleep 2; kill $pid 2>/dev/null; wait $pid 2>/dev/null`,
+		`cat /tmp/om-synthetic-ue.log; rm -f ` + ueConfigCopy + ` /tmp/om-synthetic-ue.log`,
+	}, "\n")
+	out, code, err := r.docker.Exec(ctx, r.cfg.UEContainer, []string{"bash", "-c", script})
+	if err != nil {
+		return out, err
+	}
+	if code != 0 && out == "" {
+		return out, fmt.Errorf("nr-ue could not be started in %s (exit %d)", r.cfg.UEContainer, code)
+	}
+	return out, nil
+}
+
+// watchQoS reports whether a QoS flow of imsi appeared before ctx ends.
+func (r *Runner) watchQoS(ctx context.Context, imsi string) bool {
+	if r.qos == nil {
+		return false
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		for _, f := range r.qos.Flows() {
+			if f.IMSI == imsi {
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForLogs polls Loki until core log lines labelled with imsi show up.
+func (r *Runner) waitForLogs(ctx context.Context, imsi string, since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, lokiWait)
+	defer cancel()
+	var lastErr error
+	for {
+		n, err := r.countLogLines(ctx, imsi, since)
+		if err == nil && n > 0 {
+			return n, nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return 0, lastErr
+			}
+			return 0, fmt.Errorf(`no {imsi="%s"} lines in Loki after %s`, imsi, lokiWait)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (r *Runner) countLogLines(ctx context.Context, imsi string, since time.Time) (int, error) {
+	window := time.Since(since).Round(time.Second) + time.Minute
+	q := url.Values{}
+	q.Set("query", fmt.Sprintf(`sum(count_over_time({job="open5gs", imsi="%s"}[%s]))`, imsi, window))
+	target := strings.TrimRight(r.cfg.LokiURL, "/") + "/loki/api/v1/query?" + q.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.LokiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("loki query: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Value [2]any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, err
+	}
+	if len(body.Data.Result) == 0 {
+		return 0, nil
+	}
+	s, _ := body.Data.Result[0].Value[1].(string)
+	n, _ := strconv.Atoi(s)
+	return n, nil
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
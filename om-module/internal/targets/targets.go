@@ -0,0 +1,342 @@
+// Package targets compares the scrape targets the module intends
+// Prometheus to have with the ones Prometheus actually scrapes. The
+// intended targets come from the rendered Prometheus configuration and the
+// containers the collector discovers:
+//
+//   - every target of a static_configs block;
+//   - for a docker_sd job keeping __meta_docker_container_label_prometheus_scrape,
+//     every running container whose prometheus.scrape and prometheus.port
+//     labels ask for it (the docker-services job);
+//   - for a docker_sd job keeping an om.nf label and a port ("cadvisor;8080"),
+//     every running container with that om.nf label, on that port (the
+//     standard exporters and the monitoring stack).
+//
+// Prometheus' /api/v1/targets gives the scrape state of each. A target is
+// ok, down (its last scrape failed), stale (not scraped for more than twice
+// its interval), missing (intended but not among the active targets, or
+// dropped by relabelling) or unexpected (scraped but not intended).
+package targets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/prometheus/common/model"
+	"go.yaml.in/yaml/v2"
+)
+
+// States of a target.
+const (
+	StateOK         = "ok"
+	StateDown       = "down"
+	StateStale      = "stale"
+	StateMissing    = "missing"
+	StateUnexpected = "unexpected"
+)
+
+// States lists the states, the ones that need looking at first.
+var States = []string{StateMissing, StateDown, StateStale, StateUnexpected, StateOK}
+
+// Target is one scrape target, intended, scraped or both.
+type Target struct {
+	Job     string `json:"job"`
+	Address string `json:"address"` // intended address, or Prometheus' instance label
+	// Container is the container the target belongs to, when known.
+	Container string `json:"container,omitempty"`
+	// Source tells why the target is intended: static_configs,
+	// prometheus.scrape label or om.nf label; "" for unexpected targets.
+	Source string `json:"source,omitempty"`
+	State  string `json:"state"`
+	// Reason explains a state other than ok.
+	Reason string `json:"reason,omitempty"`
+
+	// Scrape state reported by Prometheus; empty for missing targets.
+	Health         string     `json:"health,omitempty"` // up | down | unknown
+	ScrapeURL      string     `json:"scrape_url,omitempty"`
+	ScrapeInterval string     `json:"scrape_interval,omitempty"`
+	LastScrape     *time.Time `json:"last_scrape,omitempty"`
+	LastDuration   float64    `json:"last_scrape_duration_seconds,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// Options configures the Checker.
+type Options struct {
+	PrometheusURL string
+	// ConfigFile is the Prometheus configuration the module rendered and
+	// Prometheus reads.
+	ConfigFile string
+	Timeout    time.Duration
+}
+
+// Checker builds target reports.
+type Checker struct {
+	opts   Options
+	client *http.Client
+}
+
+// New returns a Checker.
+func New(opts Options) *Checker {
+	return &Checker{opts: opts, client: httpclient.New("targets", 0)}
+}
+
+// ConfigFile returns the configuration the intended targets are read from.
+func (c *Checker) ConfigFile() string { return c.opts.ConfigFile }
+
+// Check returns every intended and every scraped target, sorted by job and
+// address. The intended targets are those of the configuration file and
+// the containers of view.
+func (c *Checker) Check(ctx context.Context, view *collector.View) ([]Target, error) {
+	data, err := os.ReadFile(c.opts.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	intended, err := Intended(data, view.All())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.opts.ConfigFile, err)
+	}
+	scraped, err := c.scraped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return merge(intended, scraped, time.Now()), nil
+}
+
+type scrapeConfig struct {
+	JobName         string          `yaml:"job_name"`
+	StaticConfigs   []staticConfig  `yaml:"static_configs"`
+	DockerSDConfigs []yaml.MapSlice `yaml:"docker_sd_configs"`
+	RelabelConfigs  []relabelConfig `yaml:"relabel_configs"`
+}
+
+type staticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+type relabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Regex        string   `yaml:"regex"`
+	Action       string   `yaml:"action"`
+}
+
+// nfPortRe is the keep regex of a job scraping one om.nf on one port.
+var nfPortRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+);([0-9]+)$`)
+
+// Intended returns the targets the Prometheus configuration config intends
+// for the containers of data, with their State unset.
+func Intended(config []byte, data map[string]*collector.ContainerData) ([]Target, error) {
+	var cfg struct {
+		ScrapeConfigs []scrapeConfig `yaml:"scrape_configs"`
+	}
+	if err := yaml.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out []Target
+	for _, job := range cfg.ScrapeConfigs {
+		for _, sc := range job.StaticConfigs {
+			for _, addr := range sc.Targets {
+				out = append(out, Target{Job: job.JobName, Address: addr, Source: "static_configs"})
+			}
+		}
+		if len(job.DockerSDConfigs) == 0 {
+			continue
+		}
+		for _, rc := range job.RelabelConfigs {
+			if rc.Action != "keep" || len(rc.SourceLabels) == 0 {
+				continue
+			}
+			switch {
+			case rc.SourceLabels[0] == "__meta_docker_container_label_prometheus_scrape":
+				for _, name := range names {
+					if cd := data[name]; cd.State == "running" && cd.MetricsAddress != "" {
+						out = append(out, Target{Job: job.JobName, Address: cd.MetricsAddress, Container: name, Source: "prometheus.scrape label"})
+					}
+				}
+			case rc.SourceLabels[0] == "__meta_docker_container_label_om_nf" && nfPortRe.MatchString(rc.Regex):
+				m := nfPortRe.FindStringSubmatch(rc.Regex)
+				for _, name := range names {
+					if cd := data[name]; cd.State == "running" && cd.NF == m[1] {
+						out = append(out, Target{Job: job.JobName, Address: name + ":" + m[2], Container: name, Source: "om.nf label"})
+					}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// scrapedTarget is an active or dropped target of /api/v1/targets.
+type scrapedTarget struct {
+	DiscoveredLabels   map[string]string `json:"discoveredLabels"`
+	Labels             map[string]string `json:"labels"`
+	ScrapePool         string            `json:"scrapePool"`
+	ScrapeURL          string            `json:"scrapeUrl"`
+	ScrapeInterval     string            `json:"scrapeInterval"`
+	LastError          string            `json:"lastError"`
+	LastScrape         time.Time         `json:"lastScrape"`
+	LastScrapeDuration float64           `json:"lastScrapeDuration"`
+	Health             string            `json:"health"`
+}
+
+// scrapeReport is what Prometheus reports of its targets.
+type scrapeReport struct {
+	Active  []scrapedTarget `json:"activeTargets"`
+	Dropped []scrapedTarget `json:"droppedTargets"`
+}
+
+func (c *Checker) scraped(ctx context.Context) (scrapeReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	var body struct {
+		Data scrapeReport `json:"data"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.opts.PrometheusURL, "/")+"/api/v1/targets?state=any", nil)
+	if err != nil {
+		return body.Data, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return body.Data, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return body.Data, fmt.Errorf("prometheus targets: unexpected status %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	return body.Data, err
+}
+
+// pool returns the job of a target, which Prometheus reports as the scrape
+// pool of active targets and the job label of dropped ones.
+func (t scrapedTarget) pool() string {
+	if t.ScrapePool != "" {
+		return t.ScrapePool
+	}
+	return t.DiscoveredLabels["job"]
+}
+
+// matches reports whether Prometheus' target t is the intended target i:
+// same job, and the same container for a discovered target or the same
+// address, before or after relabelling, for a static one.
+func (t scrapedTarget) matches(i Target) bool {
+	if t.pool() != i.Job {
+		return false
+	}
+	if i.Container != "" {
+		return t.DiscoveredLabels["__meta_docker_container_name"] == "/"+i.Container
+	}
+	return t.DiscoveredLabels["__address__"] == i.Address || t.Labels["instance"] == i.Address
+}
+
+// merge matches the intended targets with what Prometheus scrapes and sets
+// the state of each; scraped targets nothing intended are added as
+// unexpected. now is the time staleness is measured at.
+func merge(intended []Target, scraped scrapeReport, now time.Time) []Target {
+	used := make([]bool, len(scraped.Active))
+	out := make([]Target, 0, len(intended)+len(scraped.Active))
+	for _, t := range intended {
+		found := false
+		for i, s := range scraped.Active {
+			if used[i] || !s.matches(t) {
+				continue
+			}
+			used[i], found = true, true
+			t = withScrape(t, s, now)
+			break
+		}
+		if !found {
+			t.State, t.Reason = StateMissing, "not among the active targets of Prometheus"
+			for _, s := range scraped.Dropped {
+				if s.matches(t) {
+					t.Reason = "discovered but dropped by relabel_configs"
+					break
+				}
+			}
+		}
+		out = append(out, t)
+	}
+	for i, s := range scraped.Active {
+		if used[i] {
+			continue
+		}
+		t := Target{Job: s.pool(), Address: s.Labels["instance"], Container: s.Labels["container"]}
+		t = withScrape(t, s, now)
+		t.State, t.Reason = StateUnexpected, "scraped but not intended by the module"
+		out = append(out, t)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Job != out[j].Job {
+			return out[i].Job < out[j].Job
+		}
+		return out[i].Address < out[j].Address
+	})
+	return out
+}
+
+// withScrape copies the scrape state of s into t and sets its state.
+func withScrape(t Target, s scrapedTarget, now time.Time) Target {
+	t.Health, t.ScrapeURL, t.ScrapeInterval = s.Health, s.ScrapeURL, s.ScrapeInterval
+	t.LastDuration, t.LastError = s.LastScrapeDuration, s.LastError
+	if !s.LastScrape.IsZero() {
+		last := s.LastScrape
+		t.LastScrape = &last
+	}
+	interval, _ := model.ParseDuration(s.ScrapeInterval)
+	switch {
+	case s.Health == "down":
+		t.State, t.Reason = StateDown, "last scrape failed"
+	case t.LastScrape == nil:
+		t.State, t.Reason = StateStale, "never scraped"
+	case interval > 0 && now.Sub(*t.LastScrape) > 2*time.Duration(interval):
+		t.State, t.Reason = StateStale, fmt.Sprintf("last scraped %s ago, every %s", now.Sub(*t.LastScrape).Round(time.Second), s.ScrapeInterval)
+	default:
+		t.State = StateOK
+	}
+	return t
+}
+
+// Filter returns the targets of job (all when "") in state (all when "")
+// whose job, address or container contains q.
+func Filter(all []Target, job, state, q string) []Target {
+	q = strings.ToLower(q)
+	out := make([]Target, 0, len(all))
+	for _, t := range all {
+		if job != "" && t.Job != job {
+			continue
+		}
+		if state != "" && t.State != state {
+			continue
+		}
+		if q != "" && !strings.Contains(strings.ToLower(t.Job+" "+t.Address+" "+t.Container), q) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Count returns the number of targets in each state.
+func Count(all []Target) map[string]int {
+	out := make(map[string]int, len(States))
+	for _, s := range States {
+		out[s] = 0
+	}
+	for _, t := range all {
+		out[t.State]++
+	}
+	return out
+}
@@ -0,0 +1,556 @@
+package troubleshoot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+)
+
+// maxFlowsScanned bounds the flows whose steps a check reads.
+const maxFlowsScanned = 20
+
+// ran describes the RAN side of a generation.
+type ran struct {
+	name       string // gNB | eNB
+	core       collector.NFKind
+	metric     string // gauge of connected RAN nodes on the AMF/MME
+	accepted   string // Open5GS log line of an accepted RAN node
+	setup      string // NGSetup | S1Setup, as the cause analyzer names it
+	setupLayer string // NGAP | S1AP
+	milestone  string
+	hint       string
+}
+
+var rans = map[string]ran{
+	"5g": {
+		name: "gNB", core: collector.KindAMF, metric: "gnb", accepted: "gNB-N2 accepted",
+		setup: "NGSetup", setupLayer: "NGAP", milestone: milestone.FirstGNBConnected,
+		hint: "Check that the gNB points at the NGAP address of the AMF and that its PLMN (MCC/MNC) and TAC match the guami and tai of amf.yaml.",
+	},
+	"4g": {
+		name: "eNB", core: collector.KindMME, metric: "enb", accepted: "eNB-S1 accepted",
+		setup: "S1Setup", setupLayer: "S1AP", milestone: milestone.FirstENBConnected,
+		hint: "Check that the eNB points at the S1AP address of the MME and that its PLMN (MCC/MNC) and TAC match the gummei and tai of mme.yaml.",
+	},
+}
+
+// running checks that a container of every kind of the generation runs. A
+// kind without containers fails, or is unknown when optional: a RAN may
+// live outside the lab.
+func running(kinds map[string][]collector.NFKind, optional bool) func(context.Context, *Engine, query) outcome {
+	return func(_ context.Context, e *Engine, q query) outcome {
+		if e.src.Snapshot == nil {
+			return outcome{result: ResultUnknown, finding: "The container snapshot is not available."}
+		}
+		byKind := make(map[collector.NFKind][]*collector.ContainerData)
+		for _, c := range e.src.Snapshot.All() {
+			if inGeneration(c, q.generation) {
+				byKind[c.NFKind] = append(byKind[c.NFKind], c)
+			}
+		}
+
+		var out outcome
+		var up, down, missing []string
+		for _, k := range kinds[q.generation] {
+			cs := byKind[k]
+			if len(cs) == 0 {
+				missing = append(missing, string(k))
+				continue
+			}
+			sort.Slice(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+			var names []string
+			anyUp := false
+			for _, c := range cs {
+				names = append(names, c.Name)
+				anyUp = anyUp || c.State == "running"
+				out.evidence = append(out.evidence, Evidence{Source: "containers", Detail: c.Name + ": " + c.State, Path: "/topology"})
+			}
+			if anyUp {
+				up = append(up, string(k))
+			} else {
+				down = append(down, strings.Join(names, ", "))
+			}
+		}
+
+		switch {
+		case len(down) > 0:
+			out.result = ResultFailed
+			out.finding = "Not running: " + strings.Join(down, ", ") + "."
+			out.hint = "Start it with docker compose up -d and read the end of its log (docker logs) for why it stopped."
+		case len(missing) > 0 && optional:
+			out.result = ResultUnknown
+			out.finding = "No " + strings.Join(missing, ", ") + " container in the lab; a node outside it cannot be checked from here."
+		case len(missing) > 0:
+			out.result = ResultFailed
+			out.finding = "No " + strings.Join(missing, ", ") + " container in the " + q.generation + " lab."
+			out.hint = "Deploy it: the compose file of the " + q.generation + " core must define it."
+		default:
+			out.result = ResultOK
+			out.finding = "Running: " + strings.Join(up, ", ") + "."
+		}
+		return out
+	}
+}
+
+// inGeneration reports whether c belongs to the core of generation: by its
+// om.generation label, or by its kind when the label does not say.
+func inGeneration(c *collector.ContainerData, generation string) bool {
+	switch c.Generation {
+	case "4g", "5g":
+		return c.Generation == generation
+	}
+	g := c.NFKind.Generation()
+	return g == "" || g == generation
+}
+
+// containerSelector matches the Prometheus series of the running
+// containers of the kinds in generation, or is "" when there are none.
+func (e *Engine) containerSelector(generation string, kinds ...collector.NFKind) string {
+	if e.src.Snapshot == nil {
+		return ""
+	}
+	var names []string
+	for _, c := range e.src.Snapshot.All() {
+		for _, k := range kinds {
+			if c.NFKind == k && inGeneration(c, generation) && c.State == "running" {
+				names = append(names, regexp.QuoteMeta(c.Name))
+			}
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(`{container=~%q}`, strings.Join(names, "|"))
+}
+
+func checkRANConnected(ctx context.Context, e *Engine, q query) outcome {
+	r := rans[q.generation]
+	var out outcome
+
+	// A setup failure in the window explains a missing RAN best.
+	cause, causeEv := e.recentCause(q, "", r.setupLayer, r.setup)
+	hint := r.hint
+	if cause != nil {
+		hint = fmt.Sprintf("%s failure: %s. %s", r.setup, cause.Name, cause.Hint)
+	}
+
+	expr := "sum(" + r.metric + e.containerSelector(q.generation, r.core) + ")"
+	n, ok, ev := e.measure(ctx, DatasourcePrometheus, expr, r.name+"s connected")
+	out.evidence = append(out.evidence, ev...)
+	if ok {
+		out.evidence = append(out.evidence, causeEv...)
+		if n > 0 {
+			out.result, out.finding = ResultOK, fmt.Sprintf("%g %s connected to the %s.", n, r.name, strings.ToUpper(string(r.core)))
+			return out
+		}
+		out.result, out.finding, out.hint = ResultFailed, fmt.Sprintf("No %s is connected to the %s.", r.name, strings.ToUpper(string(r.core))), hint
+		return out
+	}
+
+	expr = e.logCount(logSelector(q.generation, string(r.core)), contains(r.accepted))
+	n, logsOK, ev := e.measure(ctx, DatasourceLoki, expr, fmt.Sprintf("%q lines", r.accepted))
+	out.evidence = append(out.evidence, ev...)
+	out.evidence = append(out.evidence, causeEv...)
+	if logsOK && n > 0 {
+		out.result, out.finding = ResultOK, fmt.Sprintf("The %s accepted a %s in the last %s.", strings.ToUpper(string(r.core)), r.name, e.opts.Window)
+		return out
+	}
+	if cause != nil {
+		out.result, out.finding, out.hint = ResultFailed, fmt.Sprintf("The %s rejected the %s: %s failure.", strings.ToUpper(string(r.core)), r.name, r.setup), hint
+		return out
+	}
+	if e.src.Milestones != nil {
+		m, achieved := e.milestone(r.milestone, q.generation, "")
+		out.evidence = append(out.evidence, Evidence{Source: "milestones", Detail: milestoneDetail(r.milestone, m, achieved), Path: "/milestones"})
+		if achieved {
+			out.result, out.finding = ResultOK, fmt.Sprintf("A %s connected at %s.", r.name, m.AchievedAt)
+			return out
+		}
+		out.result, out.finding, out.hint = ResultFailed, fmt.Sprintf("No %s has connected in this session.", r.name), hint
+		return out
+	}
+	out.result = ResultUnknown
+	out.finding = fmt.Sprintf("No %s connection in the data available; Prometheus would tell whether one is connected now.", r.name)
+	return out
+}
+
+func checkUEReachesCore(ctx context.Context, e *Engine, q query) outcome {
+	var out outcome
+	known := false
+
+	if e.src.Flows != nil {
+		known = true
+		flows := e.recentFlows(q)
+		out.evidence = append(out.evidence, Evidence{
+			Source: "flows",
+			Detail: fmt.Sprintf("%d signalling flows in the last %s", len(flows), e.opts.Window),
+			Path:   flowsPath(q),
+		})
+		if len(flows) > 0 {
+			out.result, out.finding = ResultOK, fmt.Sprintf("%d signalling flows of the UE in the last %s.", len(flows), e.opts.Window)
+			return out
+		}
+	}
+
+	sel, filter, what := logSelector(q.generation, string(rans[q.generation].core)), contains("InitialUEMessage"), "InitialUEMessage lines"
+	if q.imsi != "" {
+		sel, filter, what = fmt.Sprintf(`{job="open5gs",generation=%q,imsi=%q}`, q.generation, q.imsi), "", "log lines of "+q.imsi
+	}
+	n, ok, ev := e.measure(ctx, DatasourceLoki, e.logCount(sel, filter), what)
+	out.evidence = append(out.evidence, ev...)
+	if ok {
+		known = true
+		if n > 0 {
+			out.result, out.finding = ResultOK, fmt.Sprintf("%g %s in the last %s.", n, what, e.opts.Window)
+			return out
+		}
+	}
+
+	if !known {
+		out.result, out.finding = ResultUnknown, "Neither the capture flows nor Loki are available."
+		return out
+	}
+	out.result = ResultFailed
+	out.finding = fmt.Sprintf("No message of the UE reached the core in the last %s.", e.opts.Window)
+	out.hint = "Check that the UE is camped on the cell (RAN and UE logs) and that its PLMN matches the one the gNB/eNB broadcasts."
+	if q.imsi != "" {
+		out.hint += " Check as well that " + q.imsi + " is the IMSI the UE is configured with."
+	}
+	return out
+}
+
+func checkAuthentication(ctx context.Context, e *Engine, q query) outcome {
+	var out outcome
+	known := false
+	var failed []pipeline.Flow
+	passed := 0
+
+	if e.src.Flows != nil {
+		known = true
+		scanned := e.flowSteps(q)
+		for _, f := range scanned {
+			switch {
+			case hasStep(f, "Authentication Reject", "Authentication Failure"):
+				failed = append(failed, f)
+			case hasStep(f, "Security Mode Command"):
+				passed++
+			}
+		}
+		out.evidence = append(out.evidence, Evidence{
+			Source: "flows",
+			Detail: fmt.Sprintf("%d flows read: %d with an authentication failure, %d that reached security mode", len(scanned), len(failed), passed),
+			Path:   flowsPath(q),
+		})
+		for i, f := range failed {
+			if i == 3 {
+				break
+			}
+			out.evidence = append(out.evidence, flowEvidence(f, "authentication failure"))
+		}
+	}
+
+	authNFs := []string{"amf", "ausf", "udm"}
+	if q.generation == "4g" {
+		authNFs = []string{"mme", "hss"}
+	}
+	n, ok, ev := e.measure(ctx, DatasourceLoki, e.logCount(logSelector(q.generation, authNFs...), `|~ "(?i)authentication (failure|reject)"`), "authentication failures logged")
+	out.evidence = append(out.evidence, ev...)
+	known = known || ok
+
+	switch {
+	case len(failed) > 0 && passed == 0, ok && n > 0 && passed == 0:
+		out.result = ResultFailed
+		out.finding = "The UE fails authentication."
+		out.hint = "The keys of the USIM do not match the subscriber: compare K, OPc (or OP) and AMF of the UE with the subscriber in the WebUI. A synch failure instead points at the SQN."
+	case passed > 0:
+		out.result, out.finding = ResultOK, fmt.Sprintf("%d flows passed authentication and reached security mode.", passed)
+	case known:
+		out.result, out.finding = ResultOK, fmt.Sprintf("No authentication failure in the last %s.", e.opts.Window)
+	default:
+		out.result, out.finding = ResultUnknown, "Neither the capture flows nor Loki are available."
+	}
+	return out
+}
+
+func checkRegistration(ctx context.Context, e *Engine, q query) outcome {
+	var out outcome
+	layer, message, accept, reject, done := "5GMM", "RegistrationReject", "Registration Accept", "Registration Reject", milestone.FirstUERegistered
+	logLine := "Registration complete"
+	if q.generation == "4g" {
+		layer, message, accept, reject, done = "EMM", "AttachReject", "Attach Accept", "Attach Reject", milestone.FirstUEAttached
+		logLine = "Attach complete"
+	}
+
+	cause, causeEv := e.recentCause(q, q.imsi, layer, message)
+	out.evidence = append(out.evidence, causeEv...)
+
+	var accepted, rejected []pipeline.Flow
+	if e.src.Flows != nil {
+		for _, f := range e.flowSteps(q) {
+			switch {
+			case hasStep(f, accept):
+				accepted = append(accepted, f)
+			case hasStep(f, reject):
+				rejected = append(rejected, f)
+			}
+		}
+		if len(accepted) > 0 {
+			out.evidence = append(out.evidence, flowEvidence(accepted[0], "accepted"))
+		}
+		if len(rejected) > 0 {
+			out.evidence = append(out.evidence, flowEvidence(rejected[0], "rejected"))
+		}
+	}
+
+	switch {
+	case len(accepted) > 0:
+		out.result, out.finding = ResultOK, fmt.Sprintf("The core sent a %s.", accept)
+		return out
+	case cause != nil:
+		out.result = ResultFailed
+		out.finding = fmt.Sprintf("The core rejects the UE: %s, cause %s (%s).", reject, cause.Code, cause.Name)
+		out.hint = cause.Hint
+		return out
+	case len(rejected) > 0:
+		out.result, out.finding = ResultFailed, fmt.Sprintf("The core sent a %s.", reject)
+		out.hint = "Open the flow for the cause; GET /causes explains each one."
+		return out
+	}
+
+	if e.src.Milestones != nil {
+		m, achieved := e.milestone(done, q.generation, q.imsi)
+		out.evidence = append(out.evidence, Evidence{Source: "milestones", Detail: milestoneDetail(done, m, achieved), Path: "/milestones"})
+		if achieved {
+			out.result, out.finding = ResultOK, "A UE completed it at "+m.AchievedAt+"."
+			return out
+		}
+	}
+	n, ok, ev := e.measure(ctx, DatasourceLoki, e.logCount(logSelector(q.generation, string(rans[q.generation].core)), contains(logLine)), fmt.Sprintf("%q lines", logLine))
+	out.evidence = append(out.evidence, ev...)
+	if ok && n > 0 {
+		out.result, out.finding = ResultOK, fmt.Sprintf("%g %s lines in the last %s.", n, logLine, e.opts.Window)
+		return out
+	}
+	out.result, out.finding = ResultUnknown, "Neither an accept nor a reject in the data available."
+	return out
+}
+
+func checkPFCPAssociated(ctx context.Context, e *Engine, q query) outcome {
+	var out outcome
+	control := []collector.NFKind{collector.KindSMF}
+	if q.generation == "4g" {
+		control = append(control, collector.KindSGWC)
+	}
+	sel := e.containerSelector(q.generation, control...)
+	expr := "min(sum by (container) (pfcp_peers_active" + sel + "))"
+	n, ok, ev := e.measure(ctx, DatasourcePrometheus, expr, "PFCP peers of the least associated control plane")
+	out.evidence = append(out.evidence, ev...)
+	hint := "Check the pfcp addresses of smf.yaml and upf.yaml (and sgwc.yaml / sgwu.yaml in 4G) and that UDP 8805 passes between them; the user plane must run before the control plane associates."
+	if ok {
+		if n > 0 {
+			out.result, out.finding = ResultOK, "Every control plane has an active PFCP association."
+			return out
+		}
+		out.result, out.finding, out.hint = ResultFailed, "A control plane has no PFCP association with its user plane.", hint
+		return out
+	}
+
+	nfs := []string{"smf"}
+	if q.generation == "4g" {
+		nfs = append(nfs, "sgwc")
+	}
+	n, ok, ev = e.measure(ctx, DatasourceLoki, e.logCount(logSelector(q.generation, nfs...), contains("PFCP associated")), `"PFCP associated" lines`)
+	out.evidence = append(out.evidence, ev...)
+	if ok && n > 0 {
+		out.result, out.finding = ResultOK, fmt.Sprintf("PFCP associated in the last %s.", e.opts.Window)
+		return out
+	}
+	out.result = ResultUnknown
+	out.finding = "No association in the data available; the association is logged once, at start, so Prometheus tells best."
+	return out
+}
+
+func checkSessionEstablished(_ context.Context, e *Engine, q query) outcome {
+	var out outcome
+	layer, message, setup, done := "5GSM", "PDUSessionEstablishmentReject", "PDUSessionResourceSetup", milestone.FirstPDUSession
+	if q.generation == "4g" {
+		layer, message, setup, done = "ESM", "", "Attach Accept", milestone.FirstDefaultPDN
+	}
+
+	cause, causeEv := e.recentCause(q, q.imsi, layer, message)
+	out.evidence = append(out.evidence, causeEv...)
+	if cause != nil {
+		out.result = ResultFailed
+		out.finding = fmt.Sprintf("The core rejects the session: %s, cause %s (%s).", cause.Message, cause.Code, cause.Name)
+		out.hint = cause.Hint
+		return out
+	}
+
+	if e.src.Flows != nil {
+		for _, f := range e.flowSteps(q) {
+			if hasStep(f, setup) {
+				out.evidence = append(out.evidence, flowEvidence(f, "session set up"))
+				out.result, out.finding = ResultOK, "A session was set up in the last "+e.opts.Window.String()+"."
+				return out
+			}
+		}
+	}
+	if e.src.Milestones != nil {
+		m, achieved := e.milestone(done, q.generation, q.imsi)
+		out.evidence = append(out.evidence, Evidence{Source: "milestones", Detail: milestoneDetail(done, m, achieved), Path: "/milestones"})
+		if achieved {
+			out.result, out.finding = ResultOK, "A session was established at "+m.AchievedAt+"."
+			return out
+		}
+	}
+	out.result = ResultUnknown
+	out.finding = "No session setup nor reject in the data available."
+	return out
+}
+
+func checkDNReachable(_ context.Context, e *Engine, _ query) outcome {
+	var out outcome
+	if e.src.N6 == nil {
+		out.result, out.finding = ResultUnknown, "The N6 probe is disabled."
+		return out
+	}
+	results := e.src.N6.Results()
+	if len(results) == 0 {
+		out.result, out.finding = ResultUnknown, "The N6 probe has not checked any UPF yet."
+		return out
+	}
+
+	var unreachable []string
+	for _, r := range results {
+		for _, f := range r.Findings {
+			out.evidence = append(out.evidence, Evidence{Source: "n6", Detail: r.Container + ": " + f.Problem, Path: "/n6"})
+			if out.result == "" {
+				out.result, out.finding, out.hint = ResultFailed, r.Container+": "+f.Problem, f.Hint
+			}
+		}
+		for _, p := range r.Pings {
+			state := "reachable"
+			if !p.Reachable {
+				state = "unreachable"
+				unreachable = append(unreachable, p.Target)
+			}
+			out.evidence = append(out.evidence, Evidence{Source: "n6", Detail: fmt.Sprintf("%s → %s (%s): %s", p.Source, p.Target, p.Kind, state), Path: "/n6"})
+		}
+	}
+	switch {
+	case out.result != "":
+	case len(unreachable) > 0:
+		out.result, out.finding = ResultFailed, "Unreachable from the UPF: "+strings.Join(unreachable, ", ")+"."
+		out.hint = "Check the default route and the DNS of the UPF container, and that the host lets it out."
+	default:
+		out.result, out.finding = ResultOK, "The UPF forwards and reaches the data network."
+	}
+	return out
+}
+
+// recentFlows returns the flows of the query started within the window.
+func (e *Engine) recentFlows(q query) []pipeline.Flow {
+	var out []pipeline.Flow
+	for _, f := range e.src.Flows.Flows(q.generation, q.imsi) {
+		started, err := time.Parse(time.RFC3339, f.StartedAt)
+		if err == nil && q.now.Sub(started) > e.opts.Window {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// flowSteps returns the newest recent flows with their steps.
+func (e *Engine) flowSteps(q query) []pipeline.Flow {
+	recent := e.recentFlows(q)
+	if len(recent) > maxFlowsScanned {
+		recent = recent[:maxFlowsScanned]
+	}
+	out := make([]pipeline.Flow, 0, len(recent))
+	for _, f := range recent {
+		if full, ok := e.src.Flows.Flow(f.ID); ok {
+			out = append(out, full)
+		}
+	}
+	return out
+}
+
+// hasStep reports whether a message of f contains any of names.
+func hasStep(f pipeline.Flow, names ...string) bool {
+	for _, s := range f.Steps {
+		for _, name := range names {
+			if strings.Contains(s.Message, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func flowEvidence(f pipeline.Flow, what string) Evidence {
+	return Evidence{
+		Source: "flows",
+		Detail: fmt.Sprintf("flow %s (%s, %s): %s", f.ID, f.IMSI, f.StartedAt, what),
+		Path:   "/flows/" + url.PathEscape(f.ID) + "?format=svg",
+	}
+}
+
+func flowsPath(q query) string {
+	v := url.Values{}
+	v.Set("generation", q.generation)
+	if q.imsi != "" {
+		v.Set("imsi", q.imsi)
+	}
+	return "/flows?" + v.Encode()
+}
+
+// recentCause returns the most frequent cause of layer (and message, when
+// given) seen within the window, for imsi when given.
+func (e *Engine) recentCause(q query, imsi, layer, message string) (*pipeline.CauseSummary, []Evidence) {
+	if e.src.Causes == nil {
+		return nil, nil
+	}
+	for _, c := range e.src.Causes.Summary(q.generation) {
+		if c.Layer != layer || (message != "" && c.Message != message) || (imsi != "" && c.LastIMSI != imsi) {
+			continue
+		}
+		seen, err := time.Parse(time.RFC3339, c.LastSeen)
+		if err != nil || q.now.Sub(seen) > e.opts.Window {
+			continue
+		}
+		return &c, []Evidence{{
+			Source: "causes",
+			Detail: fmt.Sprintf("%s %s cause %s (%s) seen %d times, last at %s", c.Layer, c.Message, c.Code, c.Name, c.Count, c.LastSeen),
+			Path:   "/causes?generation=" + q.generation,
+		}}
+	}
+	return nil, nil
+}
+
+// milestone returns milestone id of generation, achieved by imsi when
+// given.
+func (e *Engine) milestone(id, generation, imsi string) (milestone.Milestone, bool) {
+	for _, m := range e.src.Milestones.Status().Achieved {
+		if m.ID == id && (m.Generation == "" || m.Generation == generation) && (imsi == "" || m.IMSI == imsi) {
+			return m, true
+		}
+	}
+	return milestone.Milestone{}, false
+}
+
+func milestoneDetail(id string, m milestone.Milestone, achieved bool) string {
+	if achieved {
+		return id + " achieved at " + m.AchievedAt
+	}
+	return id + " not achieved in this session"
+}
@@ -0,0 +1,133 @@
+package troubleshoot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Datasources of the evidence queries.
+const (
+	DatasourcePrometheus = "prometheus"
+	DatasourceLoki       = "loki"
+)
+
+// errNoSource is returned by sum when the datasource is not configured.
+var errNoSource = errors.New("not configured")
+
+// sum evaluates expr, an instant query whose result is at most one series,
+// on Prometheus or Loki. found is false when the result is empty.
+func (e *Engine) sum(ctx context.Context, datasource, expr string) (value float64, found bool, err error) {
+	var target string
+	switch datasource {
+	case DatasourcePrometheus:
+		if e.opts.PrometheusURL == "" {
+			return 0, false, errNoSource
+		}
+		target = strings.TrimRight(e.opts.PrometheusURL, "/") + "/api/v1/query"
+	case DatasourceLoki:
+		if e.opts.LokiURL == "" {
+			return 0, false, errNoSource
+		}
+		target = strings.TrimRight(e.opts.LokiURL, "/") + "/loki/api/v1/query"
+	}
+	q := url.Values{}
+	q.Set("query", expr)
+
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+"?"+q.Encode(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("%s query: unexpected status %s", datasource, resp.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Result []struct {
+				Value [2]any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, false, err
+	}
+	for _, r := range body.Data.Result {
+		s, _ := r.Value[1].(string)
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) {
+			continue
+		}
+		value, found = value+f, true
+	}
+	return value, found, nil
+}
+
+// measure runs expr and records it as evidence, what naming the value. ok
+// is false when the query failed, or when Prometheus returned no series; an
+// empty Loki count is a count of zero. ev is nil when the datasource is not
+// configured.
+func (e *Engine) measure(ctx context.Context, datasource, expr, what string) (n float64, ok bool, ev []Evidence) {
+	n, found, err := e.sum(ctx, datasource, expr)
+	if errors.Is(err, errNoSource) {
+		return 0, false, nil
+	}
+	item := Evidence{Source: datasource, Datasource: datasource, Query: expr}
+	switch {
+	case err != nil:
+		item.Detail = what + ": query failed: " + err.Error()
+	case !found && datasource == DatasourcePrometheus:
+		item.Detail = what + ": no series"
+	default:
+		ok = true
+		item.Detail = fmt.Sprintf("%s: %g", what, n)
+	}
+	return n, ok, []Evidence{item}
+}
+
+// logCount is the LogQL that counts the lines of selector that pass filter
+// (a line filter such as |= "text", or "") over the window.
+func (e *Engine) logCount(selector, filter string) string {
+	if filter != "" {
+		selector += " " + filter
+	}
+	return fmt.Sprintf("sum(count_over_time(%s [%s]))", selector, promDuration(e.opts.Window))
+}
+
+// contains is the line filter for text.
+func contains(text string) string {
+	return "|= " + strconv.Quote(text)
+}
+
+// logSelector selects the Open5GS lines of generation, of the NFs nfs when
+// given.
+func logSelector(generation string, nfs ...string) string {
+	sel := fmt.Sprintf(`{job="open5gs",generation=%q`, generation)
+	switch len(nfs) {
+	case 0:
+	case 1:
+		sel += fmt.Sprintf(",nf=%q", nfs[0])
+	default:
+		sel += fmt.Sprintf(",nf=~%q", strings.Join(nfs, "|"))
+	}
+	return sel + "}"
+}
+
+// promDuration formats d as a PromQL duration in whole seconds.
+func promDuration(d time.Duration) string {
+	return strconv.Itoa(int(d.Seconds())) + "s"
+}
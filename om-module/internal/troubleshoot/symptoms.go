@@ -0,0 +1,181 @@
+package troubleshoot
+
+import (
+	"context"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+)
+
+// Symptoms the engine can diagnose.
+const (
+	SymptomUECannotAttach  = "ue_cannot_attach"
+	SymptomUENoInternet    = "ue_no_internet"
+	SymptomRANNotConnected = "ran_not_connected"
+)
+
+// Symptom is one entry of the catalogue, with the checks of its tree in
+// the order they run.
+type Symptom struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Checks      []string `json:"checks"`
+
+	tree []node
+}
+
+// query is what a diagnosis is about.
+type query struct {
+	generation string
+	imsi       string
+	now        time.Time
+}
+
+// outcome is what a check found.
+type outcome struct {
+	result   string
+	finding  string
+	hint     string
+	evidence []Evidence
+}
+
+// node is one check of a tree; then runs only when it did not fail.
+type node struct {
+	id       string
+	question string
+	check    func(ctx context.Context, e *Engine, q query) outcome
+	then     []node
+}
+
+// Kinds of each generation the checks look for.
+var (
+	controlKinds = map[string][]collector.NFKind{
+		"5g": {collector.KindAMF},
+		"4g": {collector.KindMME},
+	}
+	subscriberKinds = map[string][]collector.NFKind{
+		"5g": {collector.KindAUSF, collector.KindUDM, collector.KindUDR, collector.KindMongo},
+		"4g": {collector.KindHSS, collector.KindMongo},
+	}
+	sessionKinds = map[string][]collector.NFKind{
+		"5g": {collector.KindSMF, collector.KindUPF},
+		"4g": {collector.KindSGWC, collector.KindSGWU, collector.KindSMF, collector.KindUPF},
+	}
+	ranKinds = map[string][]collector.NFKind{
+		"5g": {collector.KindGNB},
+		"4g": {collector.KindENB},
+	}
+)
+
+// ranConnectedNode is shared by the trees that need a connected RAN.
+func ranConnectedNode(then ...node) node {
+	return node{
+		id:       "ran_connected",
+		question: "Is a gNB/eNB connected to the core (NG Setup / S1 Setup)?",
+		check:    checkRANConnected,
+		then:     then,
+	}
+}
+
+var symptoms = []Symptom{
+	{
+		ID:          SymptomUECannotAttach,
+		Title:       "The UE cannot attach",
+		Description: "The UE never registers (5G) or attaches (4G): from the core NFs up to the accept.",
+		tree: []node{{
+			id:       "control_running",
+			question: "Is the AMF (5G) or MME (4G) running?",
+			check:    running(controlKinds, false),
+			then: []node{ranConnectedNode(
+				node{
+					id:       "ue_reaches_core",
+					question: "Does the UE reach the core (InitialUEMessage)?",
+					check:    checkUEReachesCore,
+					then: []node{{
+						id:       "subscriber_running",
+						question: "Are the NFs holding the subscriber running (AUSF, UDM, UDR, MongoDB / HSS, MongoDB)?",
+						check:    running(subscriberKinds, false),
+						then: []node{{
+							id:       "authentication",
+							question: "Does the UE pass authentication?",
+							check:    checkAuthentication,
+							then: []node{{
+								id:       "registration",
+								question: "Is the registration / attach accepted?",
+								check:    checkRegistration,
+							}},
+						}},
+					}},
+				},
+			)},
+		}},
+	},
+	{
+		ID:          SymptomUENoInternet,
+		Title:       "The UE is attached but has no internet",
+		Description: "The UE is registered but its traffic does not leave: the user plane, the session and the N6 side of the UPF.",
+		tree: []node{{
+			id:       "session_running",
+			question: "Are the SMF and UPF (and SGW-C/SGW-U in 4G) running?",
+			check:    running(sessionKinds, false),
+			then: []node{{
+				id:       "pfcp_associated",
+				question: "Are the control and user planes associated over PFCP?",
+				check:    checkPFCPAssociated,
+				then: []node{{
+					id:       "session_established",
+					question: "Was a PDU session / default bearer established?",
+					check:    checkSessionEstablished,
+					then: []node{{
+						id:       "dn_reachable",
+						question: "Can the UPF reach the data network (forwarding, NAT, pings)?",
+						check:    checkDNReachable,
+					}},
+				}},
+			}},
+		}},
+	},
+	{
+		ID:          SymptomRANNotConnected,
+		Title:       "The gNB/eNB does not connect to the core",
+		Description: "NG Setup (5G) or S1 Setup (4G) never completes.",
+		tree: []node{{
+			id:       "control_running",
+			question: "Is the AMF (5G) or MME (4G) running?",
+			check:    running(controlKinds, false),
+			then: []node{{
+				id:       "ran_running",
+				question: "Is the gNB/eNB container running?",
+				check:    running(ranKinds, true),
+				then:     []node{ranConnectedNode()},
+			}},
+		}},
+	},
+}
+
+// Symptoms returns the catalogue.
+func Symptoms() []Symptom {
+	out := make([]Symptom, len(symptoms))
+	for i, s := range symptoms {
+		s.Checks = checkIDs(s.tree, nil)
+		out[i] = s
+	}
+	return out
+}
+
+func lookup(id string) (Symptom, bool) {
+	for _, s := range symptoms {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return Symptom{}, false
+}
+
+func checkIDs(nodes []node, out []string) []string {
+	for _, n := range nodes {
+		out = checkIDs(n.then, append(out, n.id))
+	}
+	return out
+}
@@ -0,0 +1,193 @@
+// Package troubleshoot turns the "what to check when it fails" text of the
+// dashboards into decision trees run over the live lab. A symptom such as
+// "the UE cannot attach" is a tree of checks, most basic first: is the AMF
+// running, is a gNB connected, does the UE reach the core, does it pass
+// authentication, is it accepted. Each check reads what the module already
+// has — the container snapshot, the per-UE flows and causes of the
+// capture, the milestones, the N6 probe — and Prometheus and Loki when
+// they are there, and returns its finding with the evidence behind it. The
+// checks under a failed one are skipped, so the first failure in tree
+// order is the diagnosis.
+package troubleshoot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/pipeline"
+)
+
+// Results of a check.
+const (
+	ResultOK      = "ok"
+	ResultFailed  = "failed"
+	ResultUnknown = "unknown" // no data to decide
+	ResultSkipped = "skipped" // a check above it failed
+)
+
+// Verdicts of a diagnosis.
+const (
+	VerdictFound        = "found"        // a check failed
+	VerdictClear        = "clear"        // every check passed
+	VerdictInconclusive = "inconclusive" // none failed, some could not decide
+)
+
+// Evidence is what a check looked at: a module endpoint (Path), a PromQL
+// or LogQL query (Query, on Datasource), or both.
+type Evidence struct {
+	Source     string `json:"source"`
+	Detail     string `json:"detail"`
+	Path       string `json:"path,omitempty"`
+	Datasource string `json:"datasource,omitempty"` // prometheus | loki
+	Query      string `json:"query,omitempty"`
+	// URL opens Query in Grafana Explore; the API fills it in, as only it
+	// knows how Grafana is reached.
+	URL string `json:"url,omitempty"`
+}
+
+// Step is one check of a diagnosis.
+type Step struct {
+	ID       string     `json:"id"`
+	Question string     `json:"question"`
+	Depth    int        `json:"depth"`
+	Result   string     `json:"result"`
+	Finding  string     `json:"finding"`
+	Hint     string     `json:"hint,omitempty"`
+	Evidence []Evidence `json:"evidence"`
+}
+
+// Diagnosis is the outcome of running the tree of a symptom.
+type Diagnosis struct {
+	Symptom    string `json:"symptom"`
+	Title      string `json:"title"`
+	Generation string `json:"generation"`
+	IMSI       string `json:"imsi,omitempty"`
+	Window     string `json:"window"`
+	Checked    string `json:"checked"`
+	Verdict    string `json:"verdict"`
+	// Cause is the first failed step, Hint what to do about it.
+	Cause string `json:"cause,omitempty"`
+	Hint  string `json:"hint,omitempty"`
+	Steps []Step `json:"steps"`
+}
+
+// Sources are the module's own data the checks read. Any of them may be
+// nil when its subsystem is disabled; the checks that need it then report
+// unknown.
+type Sources struct {
+	Snapshot   *collector.Snapshot
+	Milestones *milestone.Engine
+	Flows      *pipeline.FlowRecorder
+	Causes     *pipeline.CauseAnalyzer
+	N6         *n6.Prober
+}
+
+// Options configure the engine. Without PrometheusURL or LokiURL the
+// checks rely on the module's own data.
+type Options struct {
+	PrometheusURL string
+	LokiURL       string
+	Timeout       time.Duration // per query
+	// Window is how far back the logs, flows and causes are looked at.
+	Window time.Duration
+}
+
+// Engine runs the symptom trees.
+type Engine struct {
+	src    Sources
+	opts   Options
+	client *http.Client
+}
+
+// New returns an engine over src.
+func New(src Sources, opts Options) *Engine {
+	return &Engine{src: src, opts: opts, client: httpclient.New("troubleshoot", 0)}
+}
+
+// Generation returns generation, or the generation of the running core
+// when it is empty. It fails for anything but "4g" and "5g", and when no
+// single core runs.
+func (e *Engine) Generation(generation string) (string, error) {
+	if generation == "" && e.src.Snapshot != nil {
+		generation = e.src.Snapshot.ActiveGeneration()
+	}
+	switch generation {
+	case "4g", "5g":
+		return generation, nil
+	case "":
+		return "", fmt.Errorf("no single core generation is running: give generation=4g|5g")
+	}
+	return "", fmt.Errorf("generation must be 4g or 5g")
+}
+
+// Diagnose runs the tree of symptom for generation ("" for the running
+// core) and, when given, one IMSI.
+func (e *Engine) Diagnose(ctx context.Context, symptom, generation, imsi string) (Diagnosis, error) {
+	s, ok := lookup(symptom)
+	if !ok {
+		return Diagnosis{}, fmt.Errorf("unknown symptom %q", symptom)
+	}
+	gen, err := e.Generation(generation)
+	if err != nil {
+		return Diagnosis{}, err
+	}
+	q := query{generation: gen, imsi: imsi, now: time.Now()}
+	d := Diagnosis{
+		Symptom:    s.ID,
+		Title:      s.Title,
+		Generation: gen,
+		IMSI:       imsi,
+		Window:     e.opts.Window.String(),
+		Checked:    q.now.UTC().Format(time.RFC3339),
+		Steps:      []Step{},
+	}
+	e.walk(ctx, s.tree, q, 0, &d.Steps)
+
+	d.Verdict = VerdictClear
+	for _, st := range d.Steps {
+		if st.Result == ResultFailed {
+			d.Verdict, d.Cause, d.Hint = VerdictFound, st.ID, st.Hint
+			break
+		}
+		if st.Result == ResultUnknown {
+			d.Verdict = VerdictInconclusive
+		}
+	}
+	return d, nil
+}
+
+// walk runs nodes in order; the children of a node run unless it failed.
+func (e *Engine) walk(ctx context.Context, nodes []node, q query, depth int, steps *[]Step) {
+	for _, n := range nodes {
+		out := n.check(ctx, e, q)
+		if out.evidence == nil {
+			out.evidence = []Evidence{}
+		}
+		*steps = append(*steps, Step{
+			ID: n.id, Question: n.question, Depth: depth,
+			Result: out.result, Finding: out.finding, Hint: out.hint, Evidence: out.evidence,
+		})
+		if out.result == ResultFailed {
+			skip(n.then, n.id, depth+1, steps)
+			continue
+		}
+		e.walk(ctx, n.then, q, depth+1, steps)
+	}
+}
+
+// skip records nodes, and everything under them, as not checked.
+func skip(nodes []node, failed string, depth int, steps *[]Step) {
+	for _, n := range nodes {
+		*steps = append(*steps, Step{
+			ID: n.id, Question: n.question, Depth: depth,
+			Result: ResultSkipped, Finding: "Not checked: " + failed + " failed.", Evidence: []Evidence{},
+		})
+		skip(n.then, failed, depth+1, steps)
+	}
+}
@@ -2,42 +2,336 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/Parz1val02/OM_module/api"
 	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/artifacts"
 	"github.com/Parz1val02/OM_module/internal/capture"
+	"github.com/Parz1val02/OM_module/internal/cardinality"
+	"github.com/Parz1val02/OM_module/internal/cluster"
 	"github.com/Parz1val02/OM_module/internal/collector"
+	"github.com/Parz1val02/OM_module/internal/dashboards"
+	"github.com/Parz1val02/OM_module/internal/demo"
 	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/educontent"
+	"github.com/Parz1val02/OM_module/internal/errorbudget"
 	"github.com/Parz1val02/OM_module/internal/exporter"
+	"github.com/Parz1val02/OM_module/internal/exposure"
+	"github.com/Parz1val02/OM_module/internal/featureflags"
+	"github.com/Parz1val02/OM_module/internal/grafana"
+	"github.com/Parz1val02/OM_module/internal/health"
+	"github.com/Parz1val02/OM_module/internal/httpclient"
+	"github.com/Parz1val02/OM_module/internal/ims"
+	"github.com/Parz1val02/OM_module/internal/incident"
+	"github.com/Parz1val02/OM_module/internal/insights"
+	"github.com/Parz1val02/OM_module/internal/kpi"
+	"github.com/Parz1val02/OM_module/internal/labevents"
+	"github.com/Parz1val02/OM_module/internal/labsession"
+	"github.com/Parz1val02/OM_module/internal/lease"
+	"github.com/Parz1val02/OM_module/internal/logaudit"
+	"github.com/Parz1val02/OM_module/internal/logbuffer"
+	"github.com/Parz1val02/OM_module/internal/logexport"
+	"github.com/Parz1val02/OM_module/internal/logsampling"
+	"github.com/Parz1val02/OM_module/internal/logschema"
+	"github.com/Parz1val02/OM_module/internal/logtime"
+	"github.com/Parz1val02/OM_module/internal/metricbuffer"
+	"github.com/Parz1val02/OM_module/internal/metricnames"
+	"github.com/Parz1val02/OM_module/internal/milestone"
+	"github.com/Parz1val02/OM_module/internal/n6"
+	"github.com/Parz1val02/OM_module/internal/output"
+	"github.com/Parz1val02/OM_module/internal/ownership"
 	"github.com/Parz1val02/OM_module/internal/pipeline"
+	"github.com/Parz1val02/OM_module/internal/promconfig"
+	"github.com/Parz1val02/OM_module/internal/promrules"
+	"github.com/Parz1val02/OM_module/internal/promtail"
+	"github.com/Parz1val02/OM_module/internal/qos"
+	"github.com/Parz1val02/OM_module/internal/querylint"
+	"github.com/Parz1val02/OM_module/internal/ranconfig"
+	"github.com/Parz1val02/OM_module/internal/readiness"
+	"github.com/Parz1val02/OM_module/internal/redact"
+	"github.com/Parz1val02/OM_module/internal/regen"
+	"github.com/Parz1val02/OM_module/internal/roaming"
+	"github.com/Parz1val02/OM_module/internal/runtimestats"
+	"github.com/Parz1val02/OM_module/internal/simmetrics"
+	"github.com/Parz1val02/OM_module/internal/slo"
+	"github.com/Parz1val02/OM_module/internal/soak"
+	"github.com/Parz1val02/OM_module/internal/subscribers"
+	"github.com/Parz1val02/OM_module/internal/synthetic"
+	"github.com/Parz1val02/OM_module/internal/targets"
 	"github.com/Parz1val02/OM_module/internal/tracing"
+	"github.com/Parz1val02/OM_module/internal/troubleshoot"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	cfg := config.Load()
+	// --profile and --set apply to every subcommand; see config/profile.go.
+	profile, overrides, args, err := config.ParseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "om-module: %v\n", err)
+		os.Exit(2)
+	}
+	cfg, err := config.Load(profile, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "om-module: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Recent log lines are kept for debug bundles (/api/debug/bundle).
+	moduleLogs := logbuffer.New(5000)
+	log.SetOutput(io.MultiWriter(os.Stderr, moduleLogs))
+
+	if len(args) > 0 && args[0] == "cleanup" {
+		os.Exit(runCleanup(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "bootstrap" {
+		os.Exit(runBootstrap(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "compare" {
+		os.Exit(runCompare(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "snapshot" {
+		os.Exit(runSnapshot(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "verify" {
+		os.Exit(runVerify(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "bench" {
+		os.Exit(runBench(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "import" {
+		os.Exit(runImport(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "session" {
+		os.Exit(runSession(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "env" {
+		os.Exit(runEnv(cfg, args[1:]))
+	}
+	if len(args) > 0 && args[0] == "corpus" {
+		os.Exit(runCorpus(cfg, args[1:]))
+	}
+
+	// `om-module --takeover` starts even if another module holds the
+	// instance lease.
+	flags := flag.NewFlagSet("om-module", flag.ExitOnError)
+	takeover := flags.Bool("takeover", cfg.LeaseTakeover, "take the instance lease over from another module sharing OUTPUT_DIR")
+	_ = flags.Parse(args)
+
+	edu, err := api.ParseEducationOptions(cfg.EducationalFeatures)
+	if err != nil {
+		log.Fatalf("Cannot parse EDUCATIONAL_FEATURES: %v", err)
+	}
+	course, err := educontent.Load(cfg.EducationalProviders)
+	if err != nil {
+		log.Printf("⚠️  Educational providers ignored: %v", err)
+	}
+	logLoc, err := logtime.Load(cfg.LogTimezone)
+	if err != nil {
+		log.Fatalf("Cannot load LOG_TIMEZONE: %v", err)
+	}
 
 	log.Printf("╔══════════════════════════════════════════╗")
 	log.Printf("║   O&M Module — 4G/5G Educational Testbed ║")
 	log.Printf("╚══════════════════════════════════════════╝")
+	if cfg.Profile != "" {
+		log.Printf("Profile           : %s", cfg.Profile)
+	}
 	log.Printf("Port              : %s", cfg.Port)
 	log.Printf("Docker socket     : %s", cfg.DockerSocket)
 	log.Printf("Compose project   : %s", cfg.ComposeProject)
 	log.Printf("Tempo endpoint    : %s", cfg.TempoEndpoint)
+	if cfg.CollectAdaptive {
+		log.Printf("Collect interval  : adaptive %s–%s", cfg.CollectMinInterval, cfg.CollectMaxInterval)
+	} else {
+		log.Printf("Collect interval  : %s", cfg.CollectInterval)
+	}
+	log.Printf("HTTP connections  : %d per host (%d idle, closed after %s)", cfg.HTTPMaxConnsPerHost, cfg.HTTPMaxIdleConnsPerHost, cfg.HTTPIdleConnTimeout)
+	log.Printf("Subsystem restarts: %d (backoff %s–%s)", cfg.SubsystemMaxRestarts, cfg.SubsystemBackoff, cfg.SubsystemMaxBackoff)
+	log.Printf("Exporter detect   : %v", cfg.ExporterDetectionEnabled)
+	log.Printf("cAdvisor compat   : %v", cfg.CAdvisorCompatEnabled)
 	log.Printf("Capture enabled   : %v", cfg.CaptureEnabled)
 	log.Printf("Capture interface : %s", cfg.CaptureInterface)
+	log.Printf("SBI analyzer      : %v", cfg.SBIAnalyzerEnabled)
+	log.Printf("Cause analytics   : %v", cfg.CauseAnalyticsEnabled)
+	log.Printf("Milestones        : %v", cfg.MilestonesEnabled)
+	log.Printf("QoS tracking      : %v", cfg.QoSTrackingEnabled)
+	log.Printf("NAS security      : %v", cfg.NASSecurityEnabled)
+	log.Printf("Handover analytics: %v", cfg.HandoverAnalyticsEnabled)
+	log.Printf("Session flows     : %v", cfg.SessionFlowsEnabled)
+	log.Printf("IMS awareness     : %v", cfg.IMSEnabled)
+	log.Printf("Educational aids  : %s", edu)
+	if names := course.Names(); len(names) > 0 {
+		log.Printf("Course content    : %s", strings.Join(names, ", "))
+	}
+	log.Printf("Dashboards dir    : %s", cfg.DashboardsDir)
+	log.Printf("Dashboard copies  : %s", cfg.DashboardRenderDir)
+	log.Printf("Dashboard provider: %s (%s, folder %q)", cfg.DashboardProvisioningDir, cfg.DashboardProviderPath, cfg.DashboardFolder)
+	log.Printf("Dashboard lint    : %s", cfg.DashboardLintReport)
+	log.Printf("Loki datasource   : %s (%s)", cfg.DatasourceProvisioningDir, cfg.LokiDatasourceURL)
+	log.Printf("Owners file       : %s", cfg.OwnersFile)
+	log.Printf("Runtime stats     : %v (every %s)", cfg.RuntimeStatsEnabled, cfg.RuntimeStatsInterval)
+	if cfg.SoakDuration > 0 {
+		log.Printf("Soak test         : %s from startup (every %s, growth ≥ %g%%)", cfg.SoakDuration, cfg.SoakInterval, cfg.SoakGrowthThreshold)
+	}
+	log.Printf("Output dir        : %s", cfg.OutputDir)
+	if cfg.LeaseFile != "" {
+		log.Printf("Instance lease    : %s (ttl %s, takeover %v)", cfg.LeaseFile, cfg.LeaseTTL, *takeover)
+	}
+	log.Printf("Educational copy  : %s", cfg.EducationalOutputDir)
+	log.Printf("State dumps       : %s (SIGUSR1)", cfg.DumpDir)
+	if cfg.PrometheusExternalLabels != "" {
+		log.Printf("Prometheus configs: %s (labels %s)", cfg.PrometheusConfigDir, cfg.PrometheusExternalLabels)
+	} else {
+		log.Printf("Prometheus configs: %s", cfg.PrometheusConfigDir)
+	}
+	log.Printf("Regeneration      : quiet %s, max delay %s", cfg.RegenQuietPeriod, cfg.RegenMaxDelay)
+	log.Printf("Artifact store    : %s", cfg.ArtifactStore)
+	if cfg.ErrorBudgetEnabled {
+		log.Printf("Log error budget  : %g errors/1000 lines (every %s)", cfg.ErrorBudgetPer1000, cfg.ErrorBudgetInterval)
+	}
+	if cfg.SLOFile != "" {
+		log.Printf("Lab SLOs          : %s (every %s)", cfg.SLOFile, cfg.SLOInterval)
+	}
+	if cfg.RedactionFile != "" {
+		log.Printf("Log redaction     : %s (dry run %v)", cfg.RedactionFile, cfg.RedactionDryRun)
+	}
+	if cfg.CardinalityEnabled {
+		log.Printf("Cardinality       : %d series/family, %d values/label, warn at %g, %s (every %s)",
+			cfg.CardinalitySeriesBudget, cfg.CardinalityLabelBudget, cfg.CardinalityWarnRatio, cfg.CardinalityAction, cfg.CardinalityInterval)
+	}
+	if cfg.MetricBufferEnabled {
+		log.Printf("Metric buffer     : %s (up to %d MB, %s, every %s)", cfg.MetricBufferDir, cfg.MetricBufferMaxMB, cfg.MetricBufferMaxAge, cfg.MetricBufferInterval)
+	}
+	if cfg.HealthSLOEnabled {
+		log.Printf("Health SLOs       : SBI p95 ≤ %s, success ≥ %g over %s (every %s)",
+			cfg.HealthSLOResponseTime, cfg.HealthSLOSuccessRate, cfg.HealthSLOWindow, cfg.HealthSLOInterval)
+	}
+	if cfg.HealthChecksFile != "" {
+		log.Printf("Health checks     : %s (every %s, timeout %s)", cfg.HealthChecksFile, cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+	}
+	if cfg.LogSamplingEnabled {
+		log.Printf("Log rate limits   : error %g/s (burst %g), warning %g/s (burst %g), info %g/s (burst %g)",
+			cfg.LogLimitErrorRate, cfg.LogLimitErrorBurst, cfg.LogLimitWarningRate, cfg.LogLimitWarningBurst,
+			cfg.LogLimitInfoRate, cfg.LogLimitInfoBurst)
+	}
+	if cfg.LogAuditEnabled {
+		log.Printf("Logger audit      : %s (every %s, fix %v at %s)", cfg.LogAuditDir, cfg.LogAuditInterval, cfg.LogAuditFix, cfg.LogAuditLevel)
+	}
+	if cfg.RANConfigEnabled {
+		log.Printf("RAN config check  : every %s", cfg.RANConfigInterval)
+	}
+	if cfg.LogExportEnabled {
+		log.Printf("Log export        : %s → %s (%d MB × %d files, every %s)", cfg.LogExportSourceDir, cfg.LogExportDir, cfg.LogExportMaxMB, cfg.LogExportMaxFiles, cfg.LogExportInterval)
+	}
+	if cfg.TroubleshootEnabled {
+		log.Printf("Troubleshooting   : window %s", cfg.TroubleshootWindow)
+	}
+	if cfg.SessionEnabled {
+		log.Printf("Lab sessions      : %s (label %s, default %s, gate %v)", cfg.SessionDir, cfg.SessionLabel, cfg.SessionDefaultDuration, cfg.SessionGate)
+	}
+	if cfg.EventsEnabled {
+		log.Printf("Lab events        : %s", cfg.EventsDir)
+	}
+	if cfg.SimulatedMetricsDir != "" {
+		log.Printf("Simulated metrics : %s (every %s)", cfg.SimulatedMetricsDir, cfg.SimulatedMetricsInterval)
+	}
+	log.Printf("Log time zone     : %s", logLoc)
+	if cfg.DependencySkip != "" {
+		log.Printf("Dependency wait   : %s (skip %s)", cfg.DependencyTimeout, cfg.DependencySkip)
+	} else {
+		log.Printf("Dependency wait   : %s", cfg.DependencyTimeout)
+	}
+	if cfg.SyntheticTestEnabled {
+		if cfg.SyntheticInterval > 0 {
+			log.Printf("Synthetic test    : %s → %s (every %s)", cfg.SyntheticUEContainer, cfg.SyntheticMongoContainer, cfg.SyntheticInterval)
+		} else {
+			log.Printf("Synthetic test    : %s → %s (on demand)", cfg.SyntheticUEContainer, cfg.SyntheticMongoContainer)
+		}
+	}
+	if cfg.SubscriberWatchEnabled {
+		log.Printf("Subscriber watch  : %s (every %s, bulk change ≥ %d)", cfg.SubscriberMongoContainer, cfg.SubscriberWatchInterval, cfg.SubscriberDriftThreshold)
+	}
+	if cfg.InsightsEnabled {
+		log.Printf("Insights          : every %s, window %s", cfg.InsightsInterval, cfg.InsightsWindow)
+	}
+	if cfg.FeatureFlags != "" {
+		log.Printf("Feature flags     : %s", cfg.FeatureFlags)
+	}
+	if cfg.DemoScenario != "" {
+		log.Printf("Demo scenario     : %s", cfg.DemoScenario)
+	}
 	log.Printf("MCC/MNC           : %s/%s", cfg.MCC, cfg.MNC)
+	if cfg.ClusterPeers != "" {
+		log.Printf("Cluster peers     : %s (every %s)", cfg.ClusterPeers, cfg.ClusterPollInterval)
+	}
+
+	// Subsystems that fail are restarted under this policy, from the lease
+	// on.
+	runtimestats.Supervise(runtimestats.Policy{
+		MaxRestarts: cfg.SubsystemMaxRestarts,
+		Backoff:     cfg.SubsystemBackoff,
+		MaxBackoff:  cfg.SubsystemMaxBackoff,
+	})
+
+	// --- Instance lease ---
+	// Held before the first generated file is written, so that a second
+	// module sharing the volumes stops here instead of overwriting them.
+	var held *lease.Lease
+	if cfg.LeaseFile != "" {
+		held, err = lease.Acquire(lease.Options{Path: cfg.LeaseFile, TTL: cfg.LeaseTTL, Takeover: *takeover})
+		if err != nil {
+			log.Fatalf("❌ Cannot start: %v", err)
+		}
+		if prev := held.TakenOver(); prev != nil {
+			log.Printf("⚠️  Instance lease taken over from %s", prev)
+		} else {
+			log.Printf("✅ Instance lease held: %s", cfg.LeaseFile)
+		}
+	}
+
+	// Files written under OUTPUT_DIR (and the artifact store) are listed at
+	// shutdown.
+	written := output.NewManifest()
+
+	// --- Prometheus configuration variants (optional) ---
+	// Rendered before anything else: Prometheus waits for the module to be
+	// healthy and then reads them.
+	var promOpts promconfig.Options
+	if cfg.PrometheusConfigDir != "" {
+		promOpts = prometheusOptions(cfg)
+		renderPrometheusConfigs(cfg, promOpts, written)
+	}
 
 	// --- Context with graceful shutdown ---
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// A module that takes the lease over stops this one.
+	if held != nil {
+		runtimestats.Go(ctx, "lease", func(ctx context.Context) {
+			if err := held.Run(ctx); err != nil {
+				log.Printf("🛑 %v — stopping", err)
+				stop()
+			}
+		})
+	}
+
 	// --- Distributed tracing → Grafana Tempo ---
 	shutdownTracing, err := tracing.Init(ctx, cfg.TempoEndpoint)
 	if err != nil {
@@ -63,61 +357,1059 @@ func main() {
 			log.Printf("⚠️  Docker client close error: %v", err)
 		}
 	}()
-	log.Printf("✅ Connected to Docker daemon")
-
-	// --- Container collector ---
-	coll := collector.New(dockerClient, cfg.ComposeProject, 15*time.Second)
-	go coll.Run(ctx)
 
 	// --- Prometheus registry ---
 	reg := prometheus.NewRegistry()
+	// The module's own CPU, memory and Docker API load, for `om-module bench`.
+	reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	dockerClient.Instrument(reg)
+	// Every subsystem's outbound HTTP shares one pooled transport.
+	httpclient.Configure(httpclient.Options{
+		MaxConnsPerHost:     cfg.HTTPMaxConnsPerHost,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.HTTPIdleConnTimeout,
+	})
+	httpclient.Instrument(reg)
+	runtimestats.Instrument(reg)
+
+	// --- Feature flags — gate subsystems while they run ---
+	flagsLab := cfg.FeatureFlagsLab
+	if flagsLab == "" {
+		flagsLab = cfg.ComposeProject
+	}
+	featureFlags, err := featureflags.New(reg, cfg.FeatureFlags, flagsLab)
+	if err != nil {
+		log.Fatalf("Cannot parse FEATURE_FLAGS: %v", err)
+	}
+	log.Printf("✅ Feature flags: %s", featureFlags)
+
+	// --- Dependency readiness — subsystems start after what they use ---
+	deps := waitDependencies(ctx, cfg, reg, dockerClient)
+	// Run returns once the late dependencies are up: a task, not a
+	// subsystem the supervisor would restart.
+	runtimestats.Task(ctx, "readiness", deps.Run)
+	dockerReady := deps.Ready(depDocker)
+	if dockerReady {
+		log.Printf("✅ Connected to Docker daemon")
+	}
+
+	// --- Container collector — keeps retrying if Docker is late ---
+	coll := collector.New(dockerClient, cfg.ComposeProject, cfg.CollectInterval)
+	if cfg.CollectAdaptive {
+		coll.EnableAdaptive(cfg.CollectMinInterval, cfg.CollectMaxInterval)
+	}
+	if cfg.ExporterDetectionEnabled {
+		coll.DetectExporters()
+	}
+	if cfg.OwnersFile != "" {
+		owners, err := ownership.Load(cfg.OwnersFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("⚠️  No owners file at %s — components have no owner", cfg.OwnersFile)
+		case err != nil:
+			log.Printf("⚠️  Owners file ignored: %v", err)
+		default:
+			coll.SetOwners(owners)
+			log.Printf("✅ Component owners loaded (%d entries)", owners.Len())
+		}
+	}
+	// Files generated from the topology are rewritten once it settles.
+	regenSched := regen.New(reg, regen.Options{Quiet: cfg.RegenQuietPeriod, MaxDelay: cfg.RegenMaxDelay, Manifest: written})
+	coll.OnTopologyChange(func() { regenSched.Trigger() })
+	runtimestats.Go(ctx, "collector", coll.Run)
+
 	exporter.New(coll.Snapshot(), cfg.ComposeProject, reg)
 	log.Printf("✅ Prometheus exporter registered")
 
+	// The cAdvisor-compatible series reuse names of the ones above with
+	// other labels, so they get a registry of their own.
+	var cadvisorMetrics http.Handler
+	if cfg.CAdvisorCompatEnabled {
+		cadvisorReg := prometheus.NewRegistry()
+		exporter.NewCAdvisor(coll.Snapshot(), cadvisorReg)
+		cadvisorMetrics = promhttp.HandlerFor(cadvisorReg, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+		log.Printf("✅ cAdvisor-compatible exporter registered (/metrics/cadvisor)")
+	}
+
+	// Ages of the re-exported data, so dashboards can tell stale values
+	// from real zeros. Pollers register their freshness as they start.
+	ages := exporter.NewAges(reg)
+	statsInterval := cfg.CollectInterval
+	if cfg.CollectAdaptive {
+		statsInterval = cfg.CollectMaxInterval
+	}
+	ages.Add("collector", statsInterval, exporter.ContainerFreshness(coll.Snapshot()))
+
+	// --- Grafana API client (optional) ---
+	var grafanaClient *grafana.Client
+	if deps.Ready(depGrafana) {
+		grafanaClient = newGrafanaClient(cfg)
+	}
+	if grafanaClient != nil {
+		runtimestats.Task(ctx, "grafana", func(ctx context.Context) { checkGrafanaDatasources(ctx, grafanaClient) })
+	}
+
+	// --- Lab sessions (optional) ---
+	// The session label is rendered into the Prometheus configuration, so
+	// opening or ending a session refreshes it. With SESSION_GATE the
+	// capture and the insights only run during a session.
+	var labSessions *labsession.Manager
+	if cfg.SessionEnabled {
+		m, err := labsession.New(reg, labsession.Options{
+			Dir:             cfg.SessionDir,
+			Label:           cfg.SessionLabel,
+			DefaultDuration: cfg.SessionDefaultDuration,
+			Gate:            cfg.SessionGate,
+			Interval:        5 * time.Second,
+			Grafana:         grafanaClient,
+		})
+		if err != nil {
+			log.Printf("⚠️  Lab sessions disabled: %v", err)
+		} else {
+			labSessions = m
+			labSessions.OnChange(func() { regenSched.Trigger("prometheus") })
+		}
+	}
+	// sessionGate narrows a feature flag gate to the lab sessions.
+	sessionGate := func(gate func() bool) func() bool {
+		if labSessions == nil {
+			return gate
+		}
+		return labSessions.Gate(gate)
+	}
+
+	// --- Lab events (optional) ---
+	// Events the lab scripts report are tagged with the open session and
+	// forwarded to Loki and Grafana in the background.
+	var labEvents *labevents.Recorder
+	if cfg.EventsEnabled {
+		opts := labevents.Options{Dir: cfg.EventsDir, LokiTimeout: cfg.LokiTimeout, Grafana: grafanaClient}
+		if cfg.LokiURL != "" && deps.Ready(depLoki) {
+			opts.LokiURL = cfg.LokiURL
+		}
+		if labSessions != nil {
+			opts.Session = func() string {
+				s, _ := labSessions.Current()
+				return s.ID
+			}
+		}
+		r, err := labevents.New(reg, opts)
+		if err != nil {
+			log.Printf("⚠️  Lab events disabled: %v", err)
+		} else {
+			labEvents = r
+			runtimestats.Go(ctx, "labevents", labEvents.Run)
+			log.Printf("✅ Lab events enabled (%s)", cfg.EventsDir)
+		}
+	}
+
+	// --- Demo scenario (optional) — replaces the capture as packet source ---
+	var demoGen *demo.Generator
+	if cfg.DemoScenario != "" {
+		scenario, err := demo.LoadScenario(cfg.DemoScenario)
+		if err != nil {
+			log.Fatalf("Cannot load demo scenario: %v", err)
+		}
+		demoGen = demo.New(scenario, cfg.MCC, cfg.MNC, cfg.DemoLogDir, logLoc)
+	}
+
 	// --- Capture manager and pipeline (optional) ---
 	var capManager *capture.Manager
+	var sbiAnalyzer *pipeline.SBIAnalyzer
+	var causeAnalyzer *pipeline.CauseAnalyzer
+	var milestones *milestone.Engine
+	var qosTracker *qos.Tracker
+	var securityAnalyzer *pipeline.SecurityAnalyzer
+	var handoverAnalyzer *pipeline.HandoverAnalyzer
+	var flowRecorder *pipeline.FlowRecorder
+	var imsAnalyzer *ims.Analyzer
 
-	if cfg.CaptureEnabled {
-		capManager = capture.NewManager(
-			dockerClient,
-			coll.Snapshot(),
-			cfg.MCC,
-			cfg.MNC,
-			cfg.CaptureInterface,
-		)
+	if cfg.CaptureEnabled && demoGen == nil && !dockerReady {
+		log.Printf("⚠️  Capture pipeline disabled (Docker not ready)")
+	} else if cfg.CaptureEnabled || demoGen != nil {
+		if demoGen == nil {
+			capManager = capture.NewManager(
+				dockerClient,
+				coll.Snapshot(),
+				cfg.MCC,
+				cfg.MNC,
+				cfg.CaptureInterface,
+			)
+		}
 
 		pipeMetrics := pipeline.NewMetrics(reg)
-		pipe := pipeline.New(cfg.MCC, cfg.MNC, dockerClient, coll.Snapshot(), pipeMetrics)
 
-		// Start capture manager — self-retries until generation detected.
-		go capManager.Run(ctx)
+		// Attach outcomes are the KPIs `om-module compare` reports on, so
+		// they are measured whenever packets are analysed.
+		observers := []pipeline.Observer{pipeline.NewAttachAnalyzer(reg)}
+		if cfg.SBIAnalyzerEnabled {
+			sbiAnalyzer = pipeline.NewSBIAnalyzer(reg)
+			observers = append(observers, sbiAnalyzer)
+			log.Printf("✅ SBI analyzer enabled")
+		}
+		if cfg.CauseAnalyticsEnabled {
+			causeAnalyzer = pipeline.NewCauseAnalyzer(reg)
+			observers = append(observers, causeAnalyzer)
+			log.Printf("✅ Cause analytics enabled")
+		}
+		if cfg.MilestonesEnabled {
+			milestones = milestone.New(ctx, reg, grafanaClient, cfg.MilestoneWebhookURL, cfg.WebhookTimeout)
+			observers = append(observers, milestones)
+			log.Printf("✅ Milestone engine enabled")
+		}
+		if cfg.QoSTrackingEnabled {
+			qosTracker = qos.New(reg, cfg.MCC, cfg.MNC)
+			observers = append(observers, qosTracker)
+			log.Printf("✅ QoS flow tracking enabled")
+		}
+		if cfg.NASSecurityEnabled {
+			securityAnalyzer = pipeline.NewSecurityAnalyzer(reg)
+			observers = append(observers, securityAnalyzer)
+			log.Printf("✅ NAS security tracking enabled")
+		}
+		if cfg.HandoverAnalyticsEnabled {
+			handoverAnalyzer = pipeline.NewHandoverAnalyzer(reg)
+			observers = append(observers, handoverAnalyzer)
+			log.Printf("✅ Handover analytics enabled")
+		}
+		if cfg.SessionFlowsEnabled {
+			flowRecorder = pipeline.NewFlowRecorder(cfg.MCC, cfg.MNC)
+			observers = append(observers, flowRecorder)
+			log.Printf("✅ Session flow recording enabled")
+		}
+		if cfg.IMSEnabled {
+			imsAnalyzer = ims.NewAnalyzer(reg)
+			observers = append(observers, imsAnalyzer)
+			log.Printf("✅ IMS/SIP flow tracking enabled")
+		}
+
+		pipe := pipeline.New(cfg.MCC, cfg.MNC, dockerClient, coll.Snapshot(), pipeMetrics, observers...)
+
+		var packets <-chan capture.Packet
+		if demoGen != nil {
+			pipe.AddStaticNFs(demoGen.NFs())
+			runtimestats.Go(ctx, "demo", demoGen.Run)
+			packets = demoGen.Packets()
+		} else {
+			// Start capture manager — self-retries until generation detected.
+			capManager.SetGate(sessionGate(featureFlags.Gate(featureflags.Capture)))
+			featureFlags.SetAvailable(featureflags.Capture)
+			runtimestats.Go(ctx, "capture", capManager.Run)
+			packets = capManager.Packets()
+		}
 
-		// Start pipeline — reads from capture manager and emits one span per packet.
-		go func() {
+		// Start pipeline — reads packets and emits one span per packet.
+		runtimestats.Go(ctx, "pipeline", func(ctx context.Context) {
 			for {
-				pipe.Run(ctx, capManager.Packets())
+				pipe.Run(ctx, packets)
 				if ctx.Err() != nil {
 					return
 				}
 				time.Sleep(time.Second)
 			}
-		}()
+		})
 
-		log.Printf("✅ Capture pipeline started (interface=%s)", cfg.CaptureInterface)
+		if demoGen != nil {
+			log.Printf("🎬 Demo mode — synthetic packets replace the capture (scenario=%s)", cfg.DemoScenario)
+		} else {
+			log.Printf("✅ Capture pipeline started (interface=%s)", cfg.CaptureInterface)
+		}
 	} else {
 		log.Printf("⚠️  Capture pipeline disabled (CAPTURE_ENABLED=false)")
 	}
 
+	// --- IMS SIP health checks (optional) ---
+	var imsProber *ims.Prober
+	if cfg.IMSEnabled && dockerReady {
+		imsProber = ims.NewProber(reg, dockerClient, coll.Snapshot(), cfg.IMSProbeInterval, cfg.SIPProbeTimeout)
+		runtimestats.Go(ctx, "ims", imsProber.Run)
+		ages.Add("ims", cfg.IMSProbeInterval, imsProber.Freshness)
+	}
+
+	// --- SEPP / N32 health checks (optional) ---
+	var seppProber *roaming.Prober
+	if cfg.RoamingEnabled && dockerReady {
+		seppProber = roaming.NewProber(reg, dockerClient, coll.Snapshot(), cfg.RoamingProbeInterval, cfg.SEPPProbeTimeout, cfg.SEPPN32Port)
+		runtimestats.Go(ctx, "roaming", seppProber.Run)
+		ages.Add("roaming", cfg.RoamingProbeInterval, seppProber.Freshness)
+	}
+
+	// --- UPF path to the data network, N6/SGi (optional) ---
+	var n6Prober *n6.Prober
+	if cfg.N6Enabled && dockerReady {
+		n6Prober = n6.NewProber(reg, dockerClient, coll.Snapshot(), cfg.N6Interval, cfg.N6Timeout, cfg.N6Target)
+		runtimestats.Go(ctx, "n6", n6Prober.Run)
+		ages.Add("n6", cfg.N6Interval, n6Prober.Freshness)
+	}
+
+	// --- Log redaction (optional) ---
+	// Before the exposure watcher and the incident reviews, which hand out
+	// upstream log lines.
+	var redactor *redact.Redactor
+	if cfg.RedactionFile != "" {
+		file, err := redact.Load(cfg.RedactionFile)
+		if err == nil {
+			redactor, err = redact.New(reg, file, cfg.RedactionDryRun)
+		}
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("⚠️  No redaction file at %s — log lines handed out as logged", cfg.RedactionFile)
+		case err != nil:
+			log.Printf("⚠️  Redaction file ignored: %v", err)
+		default:
+			log.Printf("✅ Log redaction enabled: %s", redactor.Summary())
+		}
+	}
+
+	// --- NEF exposure APIs (optional) ---
+	var exposureWatch *exposure.Watcher
+	if cfg.ExposureEnabled && cfg.LokiURL != "" && deps.Ready(depLoki) {
+		exposureWatch = exposure.New(reg, coll.Snapshot(), exposure.Options{
+			LokiURL:  cfg.LokiURL,
+			Timeout:  cfg.LokiTimeout,
+			Interval: cfg.ExposureInterval,
+			Redact:   redactor,
+		})
+		runtimestats.Go(ctx, "exposure", exposureWatch.Run)
+		ages.Add("exposure", cfg.ExposureInterval, exposureWatch.Freshness)
+	}
+
+	// --- Promtail container management (optional) ---
+	var promtailMgr *promtail.Manager
+	if cfg.PromtailManaged && dockerReady {
+		promtailMgr = promtail.New(reg, dockerClient, coll.Snapshot(), promtail.Options{
+			ConfigDir:    cfg.PromtailConfigDir,
+			Interval:     cfg.PromtailInterval,
+			ReadyTimeout: cfg.PromtailReadyTimeout,
+		})
+		runtimestats.Go(ctx, "promtail", promtailMgr.Run)
+		ages.Add("promtail", cfg.PromtailInterval, promtailMgr.Freshness)
+		if cfg.PromtailConfigDir != "" {
+			log.Printf("✅ Promtail containers managed (restart on changes to %s)", cfg.PromtailConfigDir)
+		} else {
+			log.Printf("✅ Promtail containers managed (configuration watch off)")
+		}
+	}
+
+	// --- Open5GS logger audit (optional) ---
+	var logAuditor *logaudit.Auditor
+	if cfg.LogAuditEnabled && !logaudit.ValidLevel(cfg.LogAuditLevel) {
+		log.Fatalf("LOG_AUDIT_LEVEL must be one of %s", strings.Join(logaudit.Levels, ", "))
+	}
+	if cfg.LogAuditEnabled && dockerReady {
+		logAuditor = logaudit.New(reg, dockerClient, coll.Snapshot(), logaudit.Options{
+			LogDir:   cfg.LogAuditDir,
+			Interval: cfg.LogAuditInterval,
+			AutoFix:  cfg.LogAuditFix,
+			Level:    cfg.LogAuditLevel,
+		})
+		runtimestats.Go(ctx, "logaudit", logAuditor.Run)
+		ages.Add("logaudit", cfg.LogAuditInterval, logAuditor.Freshness)
+		log.Printf("✅ Open5GS logger audit enabled (every %s)", cfg.LogAuditInterval)
+	}
+
+	// --- RAN configuration check (optional) ---
+	var ranConfig *ranconfig.Checker
+	if cfg.RANConfigEnabled && dockerReady {
+		ranConfig = ranconfig.New(reg, dockerClient, coll.Snapshot(), cfg.RANConfigInterval)
+		runtimestats.Go(ctx, "ranconfig", ranConfig.Run)
+		ages.Add("ranconfig", cfg.RANConfigInterval, ranConfig.Freshness)
+		log.Printf("✅ RAN configuration check enabled (every %s)", cfg.RANConfigInterval)
+	}
+
+	// --- Local log export (optional) ---
+	var logExporter *logexport.Exporter
+	if cfg.LogExportEnabled {
+		exp, err := logexport.New(reg, logexport.Options{
+			SourceDir: cfg.LogExportSourceDir,
+			Dir:       cfg.LogExportDir,
+			MaxBytes:  int64(cfg.LogExportMaxMB) << 20,
+			MaxFiles:  cfg.LogExportMaxFiles,
+			Interval:  cfg.LogExportInterval,
+			Stamps:    logtime.New(logLoc),
+			Redact:    redactor,
+		})
+		if err != nil {
+			log.Printf("⚠️  Log export disabled: %v", err)
+		} else {
+			logExporter = exp
+			runtimestats.Go(ctx, "logexport", logExporter.Run)
+			ages.Add("logexport", cfg.LogExportInterval, logExporter.Freshness)
+			log.Printf("✅ Log export enabled: %s → %s", cfg.LogExportSourceDir, cfg.LogExportDir)
+		}
+	}
+
+	// --- Simulated metrics fallback (optional) ---
+	// Behind the "simulated" flag, the samples of the NFs whose metrics
+	// endpoint does not answer stand in for them, so a class can go on
+	// with part of the testbed broken.
+	var simFallback *simmetrics.Fallback
+	if cfg.SimulatedMetricsDir != "" && dockerReady {
+		templates, err := simmetrics.LoadTemplates(cfg.SimulatedMetricsDir)
+		if err != nil {
+			log.Printf("⚠️  Simulated metrics disabled: %v", err)
+		} else {
+			simFallback = simmetrics.New(reg, dockerClient, coll.Snapshot(), templates, simmetrics.Options{
+				Dir:      cfg.SimulatedMetricsDir,
+				Interval: cfg.SimulatedMetricsInterval,
+			})
+			simFallback.SetGate(featureFlags.Gate(featureflags.Simulated))
+			featureFlags.SetAvailable(featureflags.Simulated)
+			runtimestats.Go(ctx, "simmetrics", simFallback.Run)
+			ages.Add("simmetrics", cfg.SimulatedMetricsInterval, simFallback.Freshness)
+			log.Printf("✅ Simulated metrics fallback ready (%d samples, %d series dropped, active: %t)", len(templates), len(simFallback.Status().Dropped), featureFlags.Enabled(featureflags.Simulated))
+		}
+	}
+
+	// --- Classroom aggregator (optional) ---
+	var aggregator *cluster.Aggregator
+	if peers := cluster.ParsePeers(cfg.ClusterPeers); len(peers) > 0 {
+		aggregator = cluster.New(reg, peers, cfg.ClusterPollInterval, cfg.ClusterPeerTimeout)
+		runtimestats.Go(ctx, "cluster", aggregator.Run)
+		ages.Add("cluster", cfg.ClusterPollInterval, aggregator.Freshness)
+	}
+
+	// --- Runtime introspection (optional) ---
+	var runtimeMon *runtimestats.Monitor
+	if cfg.RuntimeStatsEnabled {
+		runtimeMon = runtimestats.New(reg, cfg.RuntimeStatsInterval)
+		runtimestats.Go(ctx, "runtimestats", runtimeMon.Run)
+		ages.Add("runtimestats", cfg.RuntimeStatsInterval, runtimeMon.Freshness)
+	}
+
+	// --- Soak tests (on request, or for SOAK_DURATION from startup) ---
+	soakOpts := soak.Options{
+		Interval:        cfg.SoakInterval,
+		GrowthThreshold: cfg.SoakGrowthThreshold,
+		ReportDir:       output.Dir(cfg.OutputDir, output.Reports),
+		Manifest:        written,
+	}
+	if cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		soakOpts.PrometheusURL = cfg.PrometheusURL
+	}
+	if cfg.LokiURL != "" && deps.Ready(depLoki) {
+		soakOpts.LokiURL = cfg.LokiURL
+	}
+	soakRunner := soak.New(reg, ages, soakOpts)
+	soakDone := runtimestats.Go(ctx, "soak", soakRunner.Run)
+	if cfg.SoakDuration > 0 {
+		soakRunner.Start(cfg.SoakDuration)
+	}
+
+	// --- Synthetic subscriber test (optional) ---
+	var synthRunner *synthetic.Runner
+	if cfg.SyntheticTestEnabled && dockerReady {
+		lokiURL := cfg.LokiURL
+		if !deps.Ready(depLoki) {
+			lokiURL = ""
+		}
+		synthRunner = synthetic.New(reg, dockerClient, qosTracker, synthetic.Config{
+			MongoContainer: cfg.SyntheticMongoContainer,
+			UEContainer:    cfg.SyntheticUEContainer,
+			UEConfig:       cfg.SyntheticUEConfig,
+			IMSI:           cfg.SyntheticIMSI,
+			AttachWindow:   cfg.SyntheticAttachWindow,
+			LokiURL:        lokiURL,
+			LokiTimeout:    cfg.LokiTimeout,
+		}, cfg.SyntheticInterval)
+		runtimestats.Go(ctx, "synthetic", synthRunner.Run)
+		log.Printf("✅ Synthetic subscriber test enabled")
+	}
+
+	// --- Subscriber database watch (optional) ---
+	var subscriberWatch *subscribers.Watcher
+	if cfg.SubscriberWatchEnabled && dockerReady {
+		subscriberWatch = subscribers.New(reg, dockerClient, grafanaClient, cfg.SubscriberMongoContainer,
+			cfg.SubscriberWatchInterval, cfg.SubscriberDriftThreshold)
+		runtimestats.Go(ctx, "subscribers", subscriberWatch.Run)
+		ages.Add("subscribers", cfg.SubscriberWatchInterval, subscriberWatch.Freshness)
+		log.Printf("✅ Subscriber database watch enabled")
+	}
+
+	// --- Anomaly learning cards (optional) ---
+	incidents := newIncidentQuerier(cfg, deps, logtime.New(logLoc), redactor)
+	var insightEngine *insights.Engine
+	if cfg.InsightsEnabled && incidents.HasPrometheus() {
+		insightEngine = insights.New(reg, incidents, grafanaClient, cfg.InsightsInterval, cfg.InsightsWindow)
+		insightEngine.SetGate(sessionGate(featureFlags.Gate(featureflags.Insights)))
+		featureFlags.SetAvailable(featureflags.Insights)
+		runtimestats.Go(ctx, "insights", insightEngine.Run)
+		ages.Add("insights", cfg.InsightsInterval, insightEngine.Freshness)
+		log.Printf("✅ Anomaly learning cards enabled (every %s, window %s)", cfg.InsightsInterval, cfg.InsightsWindow)
+	}
+
+	// --- Guided troubleshooting (optional) ---
+	// Besides the module's own data, the symptom trees query the Prometheus
+	// and Loki that were ready at startup, as the incident reviews do.
+	var troubleshooter *troubleshoot.Engine
+	if cfg.TroubleshootEnabled {
+		opts := troubleshoot.Options{Timeout: cfg.PrometheusTimeout, Window: cfg.TroubleshootWindow}
+		if incidents.HasPrometheus() {
+			opts.PrometheusURL = cfg.PrometheusURL
+		}
+		if incidents.HasLoki() {
+			opts.LokiURL = cfg.LokiURL
+		}
+		troubleshooter = troubleshoot.New(troubleshoot.Sources{
+			Snapshot:   coll.Snapshot(),
+			Milestones: milestones,
+			Flows:      flowRecorder,
+			Causes:     causeAnalyzer,
+			N6:         n6Prober,
+		}, opts)
+		log.Printf("✅ Guided troubleshooting enabled (window %s)", cfg.TroubleshootWindow)
+	}
+
+	// --- Health rollup: up / degraded / down ---
+	healthOpts := health.Options{
+		SLOs:              cfg.HealthSLOEnabled,
+		PrometheusTimeout: cfg.PrometheusTimeout,
+		Interval:          cfg.HealthSLOInterval,
+		ResponseTime:      cfg.HealthSLOResponseTime,
+		SuccessRate:       cfg.HealthSLOSuccessRate,
+		Window:            cfg.HealthSLOWindow,
+	}
+	if cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		healthOpts.PrometheusURL = cfg.PrometheusURL
+	}
+	healthEval := health.New(reg, coll.Snapshot(), healthOpts)
+	if cfg.HealthSLOEnabled && healthOpts.PrometheusURL != "" {
+		runtimestats.Go(ctx, "health", healthEval.Run)
+		ages.Add("health", cfg.HealthSLOInterval, healthEval.Freshness)
+		log.Printf("✅ Health SLOs enabled")
+	}
+	var healthProber *health.Prober
+	if cfg.HealthChecksFile != "" && dockerReady {
+		file, err := health.LoadChecks(cfg.HealthChecksFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("⚠️  No health checks file at %s — generated checks only", cfg.HealthChecksFile)
+		case err != nil:
+			log.Printf("⚠️  Health checks file ignored: %v", err)
+			file = nil
+		}
+		healthProber = health.NewProber(reg, dockerClient, coll.Snapshot(), file, cfg.HealthCheckInterval, cfg.HealthCheckTimeout)
+		healthEval.SetProber(healthProber)
+		runtimestats.Go(ctx, "healthcheck", healthProber.Run)
+		log.Printf("✅ Active health checks enabled: %s", file.Summary())
+	}
+
+	// --- Log error budgets (optional) ---
+	var errorBudgets *errorbudget.Tracker
+	if cfg.ErrorBudgetEnabled && cfg.LokiURL != "" && deps.Ready(depLoki) {
+		errorBudgets = errorbudget.New(reg, errorbudget.Options{
+			LokiURL:  cfg.LokiURL,
+			Timeout:  cfg.LokiTimeout,
+			Interval: cfg.ErrorBudgetInterval,
+			Per1000:  cfg.ErrorBudgetPer1000,
+		})
+		runtimestats.Go(ctx, "errorbudget", errorBudgets.Run)
+		ages.Add("errorbudget", cfg.ErrorBudgetInterval, errorBudgets.Freshness)
+		log.Printf("✅ Log error budgets enabled")
+	}
+
+	// --- Lab SLOs (optional) ---
+	var sloEval *slo.Evaluator
+	if cfg.SLOFile != "" && cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		file, err := slo.Load(cfg.SLOFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("⚠️  No SLO file at %s — lab SLOs disabled", cfg.SLOFile)
+		case err != nil:
+			log.Printf("⚠️  SLO file ignored: %v", err)
+		default:
+			sloEval = slo.New(reg, file, kpi.NewPrometheus(cfg.PrometheusURL, cfg.PrometheusTimeout),
+				coll.Snapshot().ActiveGeneration, grafanaClient, cfg.SLOInterval)
+			runtimestats.Go(ctx, "slo", sloEval.Run)
+			ages.Add("slo", cfg.SLOInterval, sloEval.Freshness)
+			log.Printf("✅ Lab SLOs enabled: %s", file.Summary())
+		}
+	}
+
+	// --- Metric buffer (optional) ---
+	// Not tied to Prometheus being ready at startup: the buffer exists for
+	// the times it is not.
+	var metricBuf *metricbuffer.Buffer
+	if cfg.MetricBufferEnabled && cfg.PrometheusURL != "" {
+		buf, err := metricbuffer.New(reg, metricbuffer.Options{
+			PrometheusURL: cfg.PrometheusURL,
+			Dir:           cfg.MetricBufferDir,
+			MaxBytes:      int64(cfg.MetricBufferMaxMB) << 20,
+			MaxAge:        cfg.MetricBufferMaxAge,
+			Interval:      cfg.MetricBufferInterval,
+			Timeout:       cfg.PrometheusTimeout,
+		})
+		if err != nil {
+			log.Printf("⚠️  Metric buffer disabled: %v", err)
+		} else {
+			metricBuf = buf
+			runtimestats.Go(ctx, "metricbuffer", metricBuf.Run)
+			ages.Add("metricbuffer", cfg.MetricBufferInterval, metricBuf.Freshness)
+			log.Printf("✅ Metric buffer enabled: %s (up to %d MB, %s)", cfg.MetricBufferDir, cfg.MetricBufferMaxMB, cfg.MetricBufferMaxAge)
+		}
+	}
+
+	// --- Cardinality budgets (optional) ---
+	// Like the buffer, not tied to Prometheus being ready at startup: the
+	// budgets matter most while a lab is being brought up.
+	var cardinalityMgr *cardinality.Manager
+	if cfg.CardinalityEnabled && !cardinality.ValidAction(cfg.CardinalityAction) {
+		log.Fatalf("CARDINALITY_ACTION must be one of %s", strings.Join(cardinality.Actions, ", "))
+	}
+	if cfg.CardinalityEnabled && cfg.PrometheusURL != "" {
+		var protected []string
+		for _, name := range strings.Split(cfg.CardinalityProtectedLabels, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				protected = append(protected, name)
+			}
+		}
+		cardinalityMgr = cardinality.New(reg, cardinality.Options{
+			PrometheusURL: cfg.PrometheusURL,
+			Timeout:       cfg.PrometheusTimeout,
+			Interval:      cfg.CardinalityInterval,
+			SeriesBudget:  cfg.CardinalitySeriesBudget,
+			LabelBudget:   cfg.CardinalityLabelBudget,
+			WarnRatio:     cfg.CardinalityWarnRatio,
+			Action:        cfg.CardinalityAction,
+			HashBuckets:   cfg.CardinalityHashBuckets,
+			Protected:     protected,
+		})
+		runtimestats.Go(ctx, "cardinality", cardinalityMgr.Run)
+		ages.Add("cardinality", cfg.CardinalityInterval, cardinalityMgr.Freshness)
+		if cfg.PrometheusConfigDir == "" && cfg.CardinalityAction != cardinality.ActionReport {
+			log.Printf("⚠️  Cardinality budgets only reported: labels are limited through the rendered Prometheus configuration (PROMETHEUS_CONFIG_DIR)")
+		}
+		log.Printf("✅ Cardinality budgets enabled: %d series per family, %d values per label (%s)", cfg.CardinalitySeriesBudget, cfg.CardinalityLabelBudget, cfg.CardinalityAction)
+	}
+
+	// --- Log sampling summaries (optional) ---
+	var logSampling *logsampling.Reporter
+	if cfg.LogSamplingEnabled && cfg.LokiURL != "" && deps.Ready(depLoki) && cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		logSampling = logsampling.New(reg, logsampling.Options{
+			PrometheusURL:     cfg.PrometheusURL,
+			PrometheusTimeout: cfg.PrometheusTimeout,
+			LokiURL:           cfg.LokiURL,
+			LokiTimeout:       cfg.LokiTimeout,
+			Interval:          cfg.LogSamplingInterval,
+			Rules: logsampling.Rules(cfg.LogLimitErrorRate, cfg.LogLimitErrorBurst,
+				cfg.LogLimitWarningRate, cfg.LogLimitWarningBurst,
+				cfg.LogLimitInfoRate, cfg.LogLimitInfoBurst),
+		})
+		runtimestats.Go(ctx, "logsampling", logSampling.Run)
+		ages.Add("logsampling", cfg.LogSamplingInterval, logSampling.Freshness)
+		log.Printf("✅ Log sampling summaries enabled")
+	}
+
+	// --- Artifact store (optional) ---
+	var artifactStore artifacts.Store
+	if cfg.ArtifactStore != "" {
+		store, err := artifacts.Open(cfg.ArtifactStore, artifacts.S3Options{
+			Endpoint:  cfg.ArtifactS3Endpoint,
+			Region:    cfg.ArtifactS3Region,
+			AccessKey: cfg.ArtifactS3AccessKey,
+			SecretKey: cfg.ArtifactS3SecretKey,
+		})
+		if err != nil {
+			log.Printf("⚠️  Artifact store disabled: %v", err)
+		} else {
+			artifactStore = store
+			log.Printf("✅ Artifact store: %s", store)
+		}
+	}
+
+	// --- Dashboard inventory (optional) ---
+	var dashboardInv *dashboards.Inventory
+	if cfg.DashboardsDir != "" {
+		dashboardInv = dashboards.NewInventory(cfg.DashboardsDir)
+	}
+
+	// --- Dashboard query lint (optional) ---
+	// Dry-runs every panel query against the Prometheus and Loki that were
+	// ready at startup and writes the report next to the compare reports.
+	var lint *querylint.Linter
+	if dashboardInv != nil && cfg.DashboardLintReport != "" {
+		lintOpts := querylint.Options{PrometheusTimeout: cfg.PrometheusTimeout, LokiTimeout: cfg.LokiTimeout}
+		if cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+			lintOpts.PrometheusURL = cfg.PrometheusURL
+		}
+		if cfg.LokiURL != "" && deps.Ready(depLoki) {
+			lintOpts.LokiURL = cfg.LokiURL
+		}
+		lint = querylint.New(reg, dashboardInv, lintOpts)
+	}
+
+	// --- Live KPIs and scrape targets (need Prometheus) ---
+	var kpis *kpi.Prometheus
+	var scrapeTargets *targets.Checker
+	if cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		kpis = kpi.NewPrometheus(cfg.PrometheusURL, cfg.PrometheusTimeout)
+		// The rendered copy is what Prometheus reads; without rendering,
+		// the variant itself.
+		promFile := filepath.Join(cfg.PrometheusConfigSource, cfg.PrometheusConfig)
+		if cfg.PrometheusConfigDir != "" {
+			promFile = filepath.Join(cfg.PrometheusConfigDir, cfg.PrometheusConfig)
+		}
+		scrapeTargets = targets.New(targets.Options{
+			PrometheusURL: cfg.PrometheusURL,
+			ConfigFile:    promFile,
+			Timeout:       cfg.PrometheusTimeout,
+		})
+	}
+
+	// The Grafana folder of the provisioned dashboards.
+	dashboardFolder := cfg.DashboardFolder
+	if dashboardFolder == "" {
+		dashboardFolder = cfg.DashboardFolderUID
+	}
+
+	configFiles := map[string]string{}
+	if cfg.OwnersFile != "" {
+		configFiles["owners.json"] = cfg.OwnersFile
+	}
+	if cfg.MetricNamesFile != "" {
+		configFiles["metric-names.yaml"] = cfg.MetricNamesFile
+	}
+	if sloEval != nil {
+		configFiles["slo.yaml"] = cfg.SLOFile
+	}
+	if redactor != nil {
+		configFiles["redaction.yaml"] = cfg.RedactionFile
+	}
+
 	// --- HTTP server ---
 	mux := http.NewServeMux()
-	handlers := api.New(
-		coll.Snapshot(),
-		cfg.ComposeProject,
-		reg,
-		capManager,
-	)
+	handlers := api.New(api.Options{
+		Snapshot:  coll.Snapshot(),
+		Project:   cfg.ComposeProject,
+		Registry:  reg,
+		Deps:      deps,
+		Education: edu,
+
+		Capture:      capManager,
+		SBI:          sbiAnalyzer,
+		Causes:       causeAnalyzer,
+		Milestones:   milestones,
+		QoS:          qosTracker,
+		Security:     securityAnalyzer,
+		Handovers:    handoverAnalyzer,
+		Flows:        flowRecorder,
+		Cluster:      aggregator,
+		IMS:          imsAnalyzer,
+		IMSProber:    imsProber,
+		Runtime:      runtimeMon,
+		Synthetic:    synthRunner,
+		Artifacts:    artifactStore,
+		ErrorBudgets: errorBudgets,
+
+		Dashboards:      dashboardInv,
+		DashboardLint:   lint,
+		Grafana:         grafanaClient,
+		DashboardFolder: grafana.Folder{UID: cfg.DashboardFolderUID, Title: dashboardFolder},
+
+		Manifest:       written,
+		Regen:          regenSched,
+		MetricNames:    loadMetricNames(cfg.MetricNamesFile),
+		Incidents:      incidents,
+		Troubleshooter: troubleshooter,
+		KPIs:           kpis,
+		Targets:        scrapeTargets,
+
+		Roaming:     seppProber,
+		N6:          n6Prober,
+		Exposure:    exposureWatch,
+		Subscribers: subscriberWatch,
+		RANConfig:   ranConfig,
+
+		Promtail:    promtailMgr,
+		LogSampling: logSampling,
+		LogAudit:    logAuditor,
+		LogExport:   logExporter,
+		Redactor:    redactor,
+
+		Insights: insightEngine,
+		Course:   course,
+
+		Health:           healthEval,
+		HealthChecks:     healthProber,
+		SLO:              sloEval,
+		MetricBuffer:     metricBuf,
+		Cardinality:      cardinalityMgr,
+		SimulatedMetrics: simFallback,
+		CAdvisorMetrics:  cadvisorMetrics,
+
+		FeatureFlags: featureFlags,
+		Lease:        held,
+		Soak:         soakRunner,
+		Sessions:     labSessions,
+		LabEvents:    labEvents,
+
+		DebugConfig: cfg.Redacted(),
+		ModuleLogs:  moduleLogs,
+		ConfigFiles: configFiles,
+	})
 	handlers.Register(mux)
 
+	// --- Scheduled session bundles (optional) ---
+	var bundlesDone <-chan struct{}
+	if artifactStore != nil && cfg.ArtifactInterval > 0 {
+		bundlesDone = runtimestats.Go(ctx, "artifacts", func(ctx context.Context) {
+			writeBundles(ctx, handlers, cfg.ArtifactInterval)
+		})
+	}
+
+	// --- Lab session reports (optional) ---
+	// A session starts the milestones over; its report is written when it
+	// ends, with a bundle of the module when artifacts are enabled.
+	if labSessions != nil {
+		labSessions.SetReporter(handlers.SessionReport)
+		if milestones != nil {
+			labSessions.OnStart(func(labsession.Session) { milestones.Reset() })
+		}
+		runtimestats.Go(ctx, "labsession", labSessions.Run)
+		ages.Add("labsession", 5*time.Second, labSessions.Freshness)
+		log.Printf("✅ Lab sessions enabled (%s, label %s)", cfg.SessionDir, cfg.SessionLabel)
+	}
+
+	// --- Offline copy of the educational page (optional) ---
+	// Offline pages link to Grafana on localhost, where students open them.
+	if cfg.EducationalOutputDir != "" {
+		regenSched.Add(regen.Job{
+			Name: "educational",
+			Inputs: func() ([]byte, error) {
+				return handlers.EducationalInputs("http://localhost:3000")
+			},
+			Run: func(_ context.Context, tx *output.Txn) error {
+				return handlers.WriteEducational(tx, cfg.EducationalOutputDir, "http://localhost:3000")
+			},
+		})
+		runtimestats.Go(ctx, "educational", func(ctx context.Context) {
+			refreshJob(ctx, regenSched, "educational")
+		})
+	}
+
+	// Grafana also re-reads its dashboard directories every
+	// updateIntervalSeconds; without admin rights that has to do.
+	reloadDashboards := func(ctx context.Context) error {
+		if grafanaClient == nil {
+			return nil
+		}
+		if err := grafanaClient.ReloadDashboardProvisioning(ctx); err != nil && !grafana.IsForbidden(err) {
+			return err
+		}
+		return nil
+	}
+
+	// --- Dashboard provider (optional) ---
+	// The provider file goes to Grafana's provisioning directory and points
+	// at the directory of the dashboards, as Grafana sees it.
+	if cfg.DashboardProvisioningDir != "" && cfg.DashboardProviderPath != "" {
+		provider := dashboards.Provider{
+			Name:      cfg.DashboardProviderName,
+			Path:      cfg.DashboardProviderPath,
+			Folder:    dashboardFolder,
+			FolderUID: cfg.DashboardFolderUID,
+		}
+		regenSched.Add(regen.Job{
+			Name: "dashboard-provider",
+			Inputs: func() ([]byte, error) {
+				return dashboards.ProvisioningYAML(provider)
+			},
+			Run: func(_ context.Context, tx *output.Txn) error {
+				return dashboards.GenerateProvisioning(tx, cfg.DashboardProvisioningDir, provider)
+			},
+			Reload: reloadDashboards,
+		})
+		regenSched.Trigger("dashboard-provider")
+		log.Printf("✅ Dashboard provider %q for Grafana: %s → %s", provider.Name, filepath.Join(cfg.DashboardProvisioningDir, dashboards.ProvisioningFile), provider.Path)
+	}
+
+	// --- Loki datasource (optional) ---
+	// Grafana reads datasource files only at startup and on the reload
+	// API, so an edited loki.yml needs an admin user or a restart.
+	if cfg.DatasourceProvisioningDir != "" && cfg.LokiDatasourceURL != "" {
+		lokiDS := dashboards.LokiDatasource{URL: cfg.LokiDatasourceURL, MaxLines: 5000}
+		regenSched.Add(regen.Job{
+			Name: "loki-datasource",
+			Inputs: func() ([]byte, error) {
+				return dashboards.DatasourceYAML(lokiDS)
+			},
+			Run: func(_ context.Context, tx *output.Txn) error {
+				return dashboards.GenerateDatasource(tx, cfg.DatasourceProvisioningDir, lokiDS)
+			},
+			Reload: func(ctx context.Context) error {
+				if grafanaClient == nil {
+					return nil
+				}
+				if err := grafanaClient.ReloadDatasourceProvisioning(ctx); err != nil && !grafana.IsForbidden(err) {
+					return err
+				}
+				return nil
+			},
+		})
+		regenSched.Trigger("loki-datasource")
+		log.Printf("✅ Loki datasource for Grafana: %s (%d derived fields)", filepath.Join(cfg.DatasourceProvisioningDir, dashboards.DatasourceFile), len(dashboards.DerivedFields))
+	}
+
+	// --- Rendered dashboards (optional) ---
+	// Grafana provisions from the rendered copies; without Loki their
+	// Loki panels become placeholders instead of datasource errors.
+	if cfg.DashboardsDir != "" && cfg.DashboardRenderDir != "" {
+		renderOpts := dashboards.RenderOptions{Loki: cfg.LokiURL != "" && deps.Ready(depLoki)}
+		if len(course.Names()) > 0 {
+			renderOpts.Notes = func(uid string) string {
+				items := course.Content(educontent.Topic{Kind: educontent.TopicDashboard, Name: uid})
+				return educontent.Markdown("📚 Material del curso", edu.Course(items))
+			}
+		}
+		if simFallback != nil {
+			renderOpts.Simulated = simFallback.Metrics()
+		}
+		renderOpts.Extra = make(map[string][]byte)
+		if model, err := dashboards.TopologyDashboard(); err != nil {
+			log.Printf("⚠️  Topology dashboard not generated: %v", err)
+		} else {
+			renderOpts.Extra[dashboards.TopologyFile] = model
+		}
+		if sloEval != nil {
+			if model, err := sloEval.File().Dashboard(); err != nil {
+				log.Printf("⚠️  SLO dashboard not generated: %v", err)
+			} else {
+				renderOpts.Extra[slo.DashboardFile] = model
+			}
+		}
+		// The 4G/5G comparison follows the cores of the lab, so it is
+		// generated on every run instead of once.
+		withCompare := func() dashboards.RenderOptions {
+			o := renderOpts
+			o.Extra = maps.Clone(renderOpts.Extra)
+			if model, err := dashboards.CompareDashboard(coll.Snapshot().View().Deployment()); err != nil {
+				log.Printf("⚠️  4G/5G comparison dashboard not generated: %v", err)
+			} else {
+				o.Extra[dashboards.CompareFile] = model
+			}
+			return o
+		}
+		regenSched.Add(regen.Job{
+			Name: "dashboards",
+			Inputs: func() ([]byte, error) {
+				return dashboards.RenderInputs(cfg.DashboardsDir, withCompare())
+			},
+			Run: func(_ context.Context, tx *output.Txn) error {
+				replaced, err := dashboards.Generate(tx, cfg.DashboardsDir, cfg.DashboardRenderDir, withCompare())
+				if err != nil {
+					return err
+				}
+				if replaced > 0 {
+					log.Printf("⚠️  Loki not configured — %d Loki panels of the dashboards replaced with placeholders", replaced)
+				}
+				return nil
+			},
+			Validate: dashboards.ValidateRendered,
+			Reload:   reloadDashboards,
+		})
+		runtimestats.Go(ctx, "dashboards", func(ctx context.Context) {
+			refreshJob(ctx, regenSched, "dashboards")
+		})
+		log.Printf("✅ Dashboard copies for Grafana: %s (Loki panels kept: %t)", cfg.DashboardRenderDir, renderOpts.Loki)
+	}
+	if sloEval != nil && (cfg.DashboardsDir == "" || cfg.DashboardRenderDir == "") {
+		log.Printf("⚠️  SLO dashboard not generated: it is written with the dashboard copies (DASHBOARDS_DIR, DASHBOARD_RENDER_DIR)")
+	}
+
+	// --- Dashboard query lint (optional) ---
+	if lint != nil {
+		regenSched.Add(regen.Job{
+			Name: "dashboard-lint",
+			Inputs: func() ([]byte, error) {
+				return lint.Inputs(ctx, logschema.Build(coll.Snapshot().Services()))
+			},
+			Run: func(ctx context.Context, tx *output.Txn) error {
+				rep, err := lint.Run(ctx, logschema.Build(coll.Snapshot().Services()))
+				if err != nil {
+					return err
+				}
+				if err := querylint.Write(tx, cfg.DashboardLintReport, rep); err != nil {
+					return err
+				}
+				if rep.Errors > 0 {
+					log.Printf("⚠️  Dashboard query lint: %d broken queries, %d warnings (%s)", rep.Errors, rep.Warnings, cfg.DashboardLintReport)
+				}
+				return nil
+			},
+		})
+		runtimestats.Go(ctx, "dashboard-lint", func(ctx context.Context) {
+			refreshJob(ctx, regenSched, "dashboard-lint")
+		})
+		log.Printf("✅ Dashboard query lint enabled (report %s)", cfg.DashboardLintReport)
+	}
+
+	// --- Prometheus configuration refresh (optional) ---
+	// Edits to the variants are rendered again and Prometheus reloaded; a
+	// configuration it rejects is rolled back. The labels limited by the
+	// cardinality budgets are part of the inputs, so a new limit is
+	// rendered on the next refresh.
+	if cfg.PrometheusConfigDir != "" {
+		currentPromOpts := func() promconfig.Options {
+			opts := promOpts
+			if cardinalityMgr != nil {
+				opts.LabelLimits = cardinalityMgr.Limits()
+			}
+			if labSessions != nil {
+				opts.SeriesLabels = labSessions.Labels()
+			}
+			return opts
+		}
+		regenSched.Add(regen.Job{
+			Name: "prometheus",
+			Inputs: func() ([]byte, error) {
+				return promconfig.Inputs(cfg.PrometheusConfigSource, currentPromOpts())
+			},
+			Run: func(_ context.Context, tx *output.Txn) error {
+				return promconfig.Generate(tx, cfg.PrometheusConfigSource, cfg.PrometheusConfigDir, currentPromOpts())
+			},
+			Validate: promconfig.Validate,
+			Reload: func(ctx context.Context) error {
+				if cfg.PrometheusURL == "" {
+					return nil
+				}
+				return promconfig.Reload(ctx, cfg.PrometheusURL, cfg.PrometheusTimeout)
+			},
+		})
+		runtimestats.Go(ctx, "prometheus-config", func(ctx context.Context) {
+			refreshJob(ctx, regenSched, "prometheus")
+		})
+
+		// The starter rules follow the metrics Prometheus scrapes; the
+		// minute refresh rewrites them when an NF starts or stops.
+		if cfg.PrometheusRulesEnabled {
+			rules := promrules.New(cfg.PrometheusURL, cfg.PrometheusTimeout, cfg.PrometheusConfigDir)
+			regenSched.Add(regen.Job{
+				Name:     "prometheus-rules",
+				Inputs:   rules.Inputs,
+				Run:      rules.Generate,
+				Validate: promrules.Validate,
+				Reload: func(ctx context.Context) error {
+					if cfg.PrometheusURL == "" {
+						return nil
+					}
+					return promconfig.Reload(ctx, cfg.PrometheusURL, cfg.PrometheusTimeout)
+				},
+			})
+			runtimestats.Go(ctx, "prometheus-rules", func(ctx context.Context) {
+				refreshJob(ctx, regenSched, "prometheus-rules")
+			})
+			log.Printf("✅ Prometheus starter rules enabled (%s)", filepath.Join(cfg.PrometheusConfigDir, promrules.Dir, promrules.File))
+		}
+	}
+	runtimestats.Go(ctx, "regen", regenSched.Run)
+
+	// --- Runtime state dumps on SIGUSR1 (optional) ---
+	if cfg.DumpDir != "" {
+		runtimestats.Go(ctx, "statedump", func(ctx context.Context) {
+			dumpOnSignal(ctx, handlers, cfg.DumpDir)
+		})
+	}
+
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      mux,
@@ -125,24 +1417,363 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	go func() {
-		log.Printf("🚀 HTTP server listening on :%s", cfg.Port)
-		log.Printf("   GET /metrics                           → Prometheus scrape endpoint")
-		log.Printf("   GET /topology                          → Testbed topology + health (JSON)")
-		log.Printf("   GET /ping                              → Liveness probe")
-		log.Printf("   GET /capture/status                    → Capture pipeline health")
+	log.Printf("🚀 HTTP server listening on :%s", cfg.Port)
+	log.Printf("   GET /metrics                           → Prometheus scrape endpoint")
+	log.Printf("   GET /metrics/simulated                 → Sampled metrics of the NFs whose endpoint does not answer")
+	log.Printf("   GET /metrics/cadvisor                  → Container stats under cAdvisor's names (CADVISOR_COMPAT_ENABLED)")
+	log.Printf("   GET /topology                          → Testbed topology + health (JSON)")
+	log.Printf("   GET /ping                              → Liveness probe")
+	log.Printf("   GET /status                            → Startup state: dependencies, disabled and failed subsystems")
+	log.Printf("   GET /api/health                        → Health rollup: up / degraded (SLOs) / down per component")
+	log.Printf("   GET /api/health/checks                 → Active health checks: generated + health-checks.yaml, last results")
+	log.Printf("   GET /api/kpi/{name}?window=5m          → Live KPI as a flat JSON value (GET /api/kpi lists them)")
+	log.Printf("   GET /api/targets?state=missing         → Intended vs. actual Prometheus scrape targets (job, q, limit, offset)")
+	log.Printf("   GET /api/version                       → Module build, container images and feature flags")
+	log.Printf("   GET /api/openapi.json                  → OpenAPI 3 description of the API, for client generators")
+	log.Printf("   GET /api/docs                          → Swagger UI on the OpenAPI description")
+	log.Printf("   GET /api/flags                         → Feature flags: state, source (default/config/rollout/api)")
+	log.Printf("   POST /api/flags/{name}?enabled=false   → Override a flag until restart (DELETE drops the override)")
+	log.Printf("   GET /api/slo                           → Lab SLOs: error budgets, burn rates, alerts")
+	log.Printf("   GET /capture/status                    → Capture pipeline health")
+	log.Printf("   GET /capture/sbi                       → SBI summary per NF pair")
+	log.Printf("   GET /causes?generation=4g|5g           → NAS/NGAP/S1AP causes with explanations")
+	log.Printf("   GET /milestones                        → Lab milestones (achieved / pending)")
+	log.Printf("   POST /milestones/reset                 → Start a new lab session")
+	log.Printf("   GET /qos                               → Per-UE QoS flows / EPS bearers")
+	log.Printf("   GET /nas/security?generation=4g|5g     → Authentication and NAS security mode per UE")
+	log.Printf("   GET /handovers?generation=4g|5g        → Handover attempts and source/target cell matrix")
+	log.Printf("   GET /flows?generation=4g|5g&imsi=      → Per-UE signalling flows (NGAP/S1AP, GTPv2, PFCP, Diameter, SBI)")
+	log.Printf("   GET /flows/{id}?format=svg             → Sequence diagram of a flow (json, mermaid, plantuml)")
+	log.Printf("   GET /educational/                      → Student lab guide (HTML)")
+	log.Printf("   GET /educational/insights              → Learning cards for the latest metric anomalies, live KPIs in words")
+	log.Printf("   POST /educational/mode?level=advanced  → Switch the default educational aids")
+	log.Printf("   GET /cluster                           → Classroom overview of peer benches")
+	log.Printf("   GET /ims                               → IMS components, SIP health, registrations and calls")
+	log.Printf("   GET /roaming                           → SEPP components, SBI/N32 health and N32 security")
+	log.Printf("   GET /exposure                          → NEF northbound API invocations and subscriptions")
+	log.Printf("   GET /n6                                → UPF path to the data network: forwarding, NAT, reachability")
+	log.Printf("   GET /logging/status                    → Promtail containers: state, /ready, restarts")
+	log.Printf("   POST /logging/start                    → Start the stopped Promtail containers")
+	log.Printf("   POST /logging/stop                     → Stop the Promtail containers")
+	log.Printf("   POST /logging/restart                  → Restart the Promtail containers")
+	log.Printf("   POST /logging/reload                   → Re-read the Promtail configuration now")
+	log.Printf("   GET /api/dashboards                    → Dashboard files: uid, datasources, checksum")
+	log.Printf("   GET /api/dashboards/{uid}              → One dashboard vs. the copy Grafana runs")
+	log.Printf("   POST /api/dashboards/{uid}/reload      → Push one dashboard file to Grafana")
+	log.Printf("   GET /api/dashboards/lint               → Dry run of every panel query against Prometheus/Loki")
+	log.Printf("   GET /api/exporters                     → Detected cAdvisor/node_exporter + scrape config")
+	log.Printf("   GET /api/metrics/catalog?q=&category=  → Exported metrics: type, help, labels, components")
+	log.Printf("   GET /api/metrics/names?nf=&metric=     → Friendly titles of raw Open5GS metric names")
+	log.Printf("   GET /api/metrics/buffer                → Samples buffered during a Prometheus outage, backfill")
+	log.Printf("   GET /api/metrics/cardinality           → Series per metric family, labels over budget and limited")
+	log.Printf("   GET /api/metrics/simulated             → NFs served from samples (data_source=\"simulated\")")
+	log.Printf("   GET /api/logs/error-budget             → Log error budgets and burn rates per NF")
+	log.Printf("   GET /api/logs/sampling                 → Log rate limits and suppressed lines per NF")
+	log.Printf("   GET /api/logs/redaction                → Redaction rules and their hits (dry-run examples)")
+	log.Printf("   GET /api/logs/audit                    → Open5GS logger configurations: logs that will not reach Loki")
+	log.Printf("   POST /api/logs/audit/fix?container=    → Log to the shared volume at ?level= and restart the NF")
+	log.Printf("   GET /api/config/consistency            → PLMN, TAC, S-NSSAI and core address of the RAN vs the core")
+	log.Printf("   GET /api/logs/files                    → Open5GS logs exported as rotated JSONL files")
+	log.Printf("   GET /api/logs/files/{gen}/{file}       → Download one exported log file")
+	log.Printf("   POST /api/logs/parse                   → Run the Promtail stages over a batch of lines (?generation=)")
+	log.Printf("   GET /api/lease                         → Instance lease on the shared output volume")
+	log.Printf("   GET /api/subscribers/drift             → Subscriber database drift: bulk changes, duplicate/malformed IMSIs")
+	log.Printf("   GET /api/incident/review               → Incident review of a time window (?at=14:32, ?format=md)")
+	log.Printf("   GET /api/troubleshoot                  → Guided diagnosis of a symptom (?symptom=ue_cannot_attach&imsi=)")
+	log.Printf("   GET /api/sessions                      → Open and past lab sessions")
+	log.Printf("   POST /api/sessions?name=&duration=     → Open a lab session (&groups=g1,g2)")
+	log.Printf("   POST /api/sessions/close               → End the open lab session and write its report")
+	log.Printf("   GET /api/sessions/{id}/report          → Report of a past lab session (markdown)")
+	log.Printf("   POST /api/events                       → Report a lab script event (UE started, SDR attached, …)")
+	log.Printf("   GET /api/events?type=&source=          → Events reported by the lab scripts")
+	log.Printf("   GET /api/regen                         → Regeneration jobs: triggers coalesced, runs, skips")
+	log.Printf("   GET /api/soak                          → Soak test progress and last report: leaks, poller drift")
+	log.Printf("   POST /api/soak/start?duration=8h       → Start a soak test of the module")
+	log.Printf("   GET /api/loki/labels                   → Loki label contract (loki-labels.json)")
+	log.Printf("   GET /api/loki/labels/check?expr=       → Check LogQL / dashboard queries against it")
+	log.Printf("   GET /api/artifacts                     → Archived session bundles")
+	log.Printf("   POST /api/artifacts                    → Archive the current session as a bundle")
+	log.Printf("   GET /api/artifacts/{id}/bundle.tar.gz  → Download one bundle")
+	log.Printf("   GET /synthetic                         → Last synthetic subscriber test (pass/fail per check)")
+	log.Printf("   POST /synthetic/run                    → Start a synthetic subscriber test")
+	log.Printf("   GET /api/debug/bundle                  → Support bundle: logs, config, status, health history, versions")
+	log.Printf("   GET /api/debug/state                   → Runtime state dump (also written on SIGUSR1)")
+	log.Printf("   GET /internal/debug                    → Goroutines per subsystem, heap, fds, leak suspects")
+
+	// A port still held by the module being replaced is retried under the
+	// subsystem policy; a server that never binds stops the module.
+	httpDone := runtimestats.Go(ctx, "http", func(context.Context) {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("HTTP server error: %v", err)
+			log.Printf("⚠️  HTTP server error: %v", err)
 		}
-	}()
+	})
 
-	<-ctx.Done()
-	log.Printf("🛑 Shutdown signal received — stopping gracefully...")
+	httpFailed := false
+	select {
+	case <-ctx.Done():
+		log.Printf("🛑 Shutdown signal received — stopping gracefully...")
+	case <-httpDone:
+		httpFailed = true
+		log.Printf("🛑 HTTP server cannot serve on :%s — stopping...", cfg.Port)
+		stop()
+	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	if bundlesDone != nil {
+		<-bundlesDone
+	}
+	<-soakDone
+	written.Log("server")
+	if held != nil {
+		held.Release()
+	}
+	if httpFailed {
+		log.Fatalf("❌ O&M Module stopped: no HTTP server on :%s", cfg.Port)
+	}
 	log.Printf("✅ O&M Module stopped cleanly")
 }
+
+// refreshJob asks for one regeneration job every minute: the offline copy
+// of the educational page, the rendered dashboards, the dashboard query
+// lint, the Prometheus configurations. The scheduler merges
+// these requests with topology changes and skips the run when its inputs
+// would not change.
+func refreshJob(ctx context.Context, s *regen.Scheduler, name string) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		s.Trigger(name)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeBundles archives a session bundle every interval, and a last one when
+// the module shuts down so the end state of a session is kept.
+func writeBundles(ctx context.Context, h *api.Handlers, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if m, err := h.WriteBundle(ctx, "scheduled"); err != nil {
+				log.Printf("⚠️  Session bundle failed: %v", err)
+			} else {
+				log.Printf("📦 Session bundle %s archived (%d files)", m.ID, len(m.Files))
+			}
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if m, err := h.WriteBundle(finalCtx, "shutdown"); err != nil {
+				log.Printf("⚠️  Final session bundle failed: %v", err)
+			} else {
+				log.Printf("📦 Final session bundle %s archived", m.ID)
+			}
+			return
+		}
+	}
+}
+
+// Dependency names, as accepted by DEPENDENCY_SKIP.
+const (
+	depDocker     = "docker"
+	depLoki       = "loki"
+	depPrometheus = "prometheus"
+	depGrafana    = "grafana"
+)
+
+// waitDependencies blocks until Docker, Loki, Prometheus and Grafana are
+// ready or DEPENDENCY_TIMEOUT has passed. Endpoints switched off in the
+// config are not waited for.
+func waitDependencies(ctx context.Context, cfg *config.Config, reg prometheus.Registerer, docker *dockerclient.Client) *readiness.Report {
+	skip := make(map[string]bool)
+	for _, name := range strings.Split(cfg.DependencySkip, ",") {
+		skip[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	deps := []readiness.Dependency{{
+		Name:       depDocker,
+		Target:     cfg.DockerSocket,
+		Check:      docker.Ping,
+		Skip:       skip[depDocker],
+		Subsystems: []string{"capture", "ims-probe", "synthetic", "promtail", "n6"},
+	}}
+	if cfg.LokiURL != "" {
+		deps = append(deps, readiness.Dependency{
+			Name:       depLoki,
+			Target:     cfg.LokiURL,
+			Check:      readiness.HTTPCheck(strings.TrimRight(cfg.LokiURL, "/") + "/ready"),
+			Skip:       skip[depLoki],
+			Subsystems: []string{"synthetic log check", "log error budgets", "exposure APIs"},
+		})
+	}
+	if cfg.PrometheusURL != "" {
+		// Nothing in the module queries Prometheus; it is waited for so the
+		// status tells whether /metrics is being scraped.
+		deps = append(deps, readiness.Dependency{
+			Name:   depPrometheus,
+			Target: cfg.PrometheusURL,
+			Check:  readiness.HTTPCheck(strings.TrimRight(cfg.PrometheusURL, "/") + "/-/ready"),
+			Skip:   skip[depPrometheus],
+		})
+	}
+	if cfg.GrafanaURL != "" {
+		deps = append(deps, readiness.Dependency{
+			Name:       depGrafana,
+			Target:     cfg.GrafanaURL,
+			Check:      readiness.HTTPCheck(strings.TrimRight(cfg.GrafanaURL, "/") + "/api/health"),
+			Skip:       skip[depGrafana],
+			Subsystems: []string{"milestone annotations", "dashboard reload", "datasource check"},
+		})
+	}
+
+	log.Printf("⏳ Waiting for dependencies (up to %s)...", cfg.DependencyTimeout)
+	return readiness.Wait(ctx, reg, cfg.DependencyTimeout, deps)
+}
+
+// dumpOnSignal writes a runtime state dump to dir on every SIGUSR1 until ctx
+// is cancelled.
+func dumpOnSignal(ctx context.Context, h *api.Handlers, dir string) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-sig:
+			if path, err := h.WriteStateDump(ctx, dir, "SIGUSR1"); err != nil {
+				log.Printf("⚠️  State dump failed: %v", err)
+			} else {
+				log.Printf("📄 State dump written to %s", path)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadMetricNames returns the embedded metric name mapping extended with
+// path, or the embedded one alone when path is empty, missing or invalid.
+func loadMetricNames(path string) *metricnames.Map {
+	if path == "" {
+		return metricnames.Default()
+	}
+	m, err := metricnames.Load(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return metricnames.Default()
+	case err != nil:
+		log.Printf("⚠️  Metric names file ignored: %v", err)
+		return metricnames.Default()
+	}
+	log.Printf("✅ Metric names loaded (%d entries)", m.Len())
+	return m
+}
+
+// prometheusOptions returns what the Prometheus configuration variants are
+// rendered with: the configured external labels and remote endpoints.
+// Invalid settings are fatal: Prometheus would otherwise start without the
+// remote endpoints it was meant to have.
+func prometheusOptions(cfg *config.Config) promconfig.Options {
+	labels, err := promconfig.ParseLabels(cfg.PrometheusExternalLabels)
+	if err != nil {
+		log.Fatalf("Cannot parse PROMETHEUS_EXTERNAL_LABELS: %v", err)
+	}
+	opts := promconfig.Options{
+		ExternalLabels: labels,
+		RemoteWrite:    promconfig.RemoteURLs(cfg.PrometheusRemoteWriteURL),
+		RemoteRead:     promconfig.RemoteURLs(cfg.PrometheusRemoteReadURL),
+		Stack:          promconfig.MonitoringStack,
+	}
+	if cfg.MetricBufferEnabled {
+		opts.OutOfOrderWindow = cfg.MetricBufferMaxAge
+	}
+	if cfg.PrometheusRulesEnabled {
+		opts.RuleFiles = []string{promrules.Glob}
+	}
+	if cfg.PrometheusRemoteFile != "" {
+		write, read, err := promconfig.LoadRemote(cfg.PrometheusRemoteFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			log.Fatalf("Cannot load PROMETHEUS_REMOTE_FILE: %v", err)
+		default:
+			opts.RemoteWrite = append(opts.RemoteWrite, write...)
+			opts.RemoteRead = append(opts.RemoteRead, read...)
+		}
+	}
+	return opts
+}
+
+// renderPrometheusConfigs renders the Prometheus configuration variants
+// with opts. The variants are validated and replaced together; on failure
+// the previous ones stay.
+func renderPrometheusConfigs(cfg *config.Config, opts promconfig.Options, written *output.Manifest) {
+	tx := output.NewTxn()
+	err := promconfig.Generate(tx, cfg.PrometheusConfigSource, cfg.PrometheusConfigDir, opts)
+	if err == nil {
+		err = promconfig.Validate(tx)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("⚠️  Prometheus configs not rendered: %v", err)
+		return
+	}
+	for _, p := range tx.Files() {
+		written.Add(p)
+	}
+	log.Printf("✅ Prometheus configs rendered (%d variants, %d external labels, %d remote_write, %d remote_read)",
+		len(tx.Files()), len(opts.ExternalLabels), len(opts.RemoteWrite), len(opts.RemoteRead))
+}
+
+// newIncidentQuerier returns the querier of incident reviews, with the Loki
+// and Prometheus that were ready at startup. Error lines are dated by their
+// own time stamps and redacted by redactor.
+func newIncidentQuerier(cfg *config.Config, deps *readiness.Report, stamps *logtime.Inferrer, redactor *redact.Redactor) *incident.Querier {
+	opts := incident.Options{LokiTimeout: cfg.LokiTimeout, PrometheusTimeout: cfg.PrometheusTimeout, Stamps: stamps, Redact: redactor}
+	if cfg.LokiURL != "" && deps.Ready(depLoki) {
+		opts.LokiURL = cfg.LokiURL
+	}
+	if cfg.PrometheusURL != "" && deps.Ready(depPrometheus) {
+		opts.PrometheusURL = cfg.PrometheusURL
+	}
+	return incident.New(opts)
+}
+
+// newGrafanaClient returns a Grafana API client, or nil when GRAFANA_URL is off.
+func newGrafanaClient(cfg *config.Config) *grafana.Client {
+	if cfg.GrafanaURL == "" {
+		return nil
+	}
+	return grafana.New(cfg.GrafanaURL, grafana.Auth{
+		Token:    cfg.GrafanaToken,
+		User:     cfg.GrafanaUser,
+		Password: cfg.GrafanaPassword,
+	}, cfg.GrafanaTimeout)
+}
+
+// checkGrafanaDatasources warns once at startup when a datasource the shipped
+// dashboards rely on is not provisioned in Grafana.
+func checkGrafanaDatasources(ctx context.Context, c *grafana.Client) {
+	if err := c.Health(ctx); err != nil {
+		log.Printf("⚠️  Grafana not reachable at %s: %v", c.URL(), err)
+		return
+	}
+	missing, err := c.CheckDatasources(ctx, grafana.DashboardDatasources...)
+	switch {
+	case err != nil:
+		log.Printf("⚠️  Grafana datasource check failed: %v", err)
+	case len(missing) > 0:
+		log.Printf("⚠️  Grafana is missing datasources used by the dashboards: %s", strings.Join(missing, ", "))
+	default:
+		log.Printf("✅ Grafana reachable, dashboard datasources provisioned")
+	}
+}
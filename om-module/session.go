@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Parz1val02/OM_module/config"
+	"github.com/Parz1val02/OM_module/internal/labsession"
+)
+
+// runSession implements `om-module session open|close|status`: it drives the
+// lab sessions of the running module through /api/sessions.
+//
+//	om-module session open -name "Lab 3" -duration 2h -groups g1,g2
+//	om-module session close
+//	om-module session status
+//
+// The exit code is 1 when the module refuses or cannot be reached, 2 on
+// usage errors.
+func runSession(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: om-module session open|close|status [flags]")
+		return 2
+	}
+
+	var method, path string
+	switch args[0] {
+	case "open":
+		fs := flag.NewFlagSet("session open", flag.ExitOnError)
+		name := fs.String("name", "", "name of the session")
+		duration := fs.Duration("duration", 0, "length of the session (default SESSION_DEFAULT_DURATION)")
+		groups := fs.String("groups", "", "comma-separated groups taking part")
+		_ = fs.Parse(args[1:])
+		if *name == "" {
+			fmt.Fprintln(os.Stderr, "session open: -name is required")
+			return 2
+		}
+		q := url.Values{"name": {*name}}
+		if *duration > 0 {
+			q.Set("duration", duration.String())
+		}
+		if *groups != "" {
+			q.Set("groups", *groups)
+		}
+		method, path = http.MethodPost, "/api/sessions?"+q.Encode()
+	case "close":
+		method, path = http.MethodPost, "/api/sessions/close"
+	case "status":
+		method, path = http.MethodGet, "/api/sessions"
+	default:
+		fmt.Fprintf(os.Stderr, "session: unknown command %q (open, close, status)\n", args[0])
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, moduleTimeout)
+	defer cancel()
+
+	target := "http://localhost:" + cfg.Port + path
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("⚠️  module not reachable on :%s: %v", cfg.Port, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		log.Printf("⚠️  %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+
+	switch args[0] {
+	case "open":
+		var s labsession.Session
+		if err := json.Unmarshal(body, &s); err != nil {
+			log.Printf("⚠️  %v", err)
+			return 1
+		}
+		log.Printf("🎓 Lab session %s opened until %s", s.ID, s.EndsAt)
+	case "close":
+		var s labsession.Session
+		if err := json.Unmarshal(body, &s); err != nil {
+			log.Printf("⚠️  %v", err)
+			return 1
+		}
+		log.Printf("🎓 Lab session %s closed; its report follows (GET /api/sessions/%s/report)", s.ID, s.ID)
+	case "status":
+		_, _ = os.Stdout.Write(body)
+	}
+	return 0
+}
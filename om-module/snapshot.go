@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Parz1val02/OM_module/config"
+	dockerclient "github.com/Parz1val02/OM_module/internal/docker"
+	"github.com/Parz1val02/OM_module/internal/integrity"
+	"github.com/Parz1val02/OM_module/internal/output"
+)
+
+// snapshotTimeout bounds the collection of a manifest; reading the
+// subscriber database is the slow part.
+const snapshotTimeout = 2 * time.Minute
+
+// runSnapshot implements `om-module snapshot`: it records the checksum
+// manifest of the environment (container images, the project's configs, the
+// files the module generates and the subscriber database). With -baseline
+// the manifest is saved to BASELINE_FILE for `om-module verify`; otherwise
+// it is printed as JSON.
+func runSnapshot(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	baseline := fs.Bool("baseline", false, "save the manifest as the baseline verify compares against")
+	out := fs.String("o", cfg.BaselineFile, "file the baseline is saved to")
+	project := fs.String("project", cfg.ProjectDir, "path of the lab repository")
+	_ = fs.Parse(args)
+
+	m := collectManifest(cfg, *project)
+	for kind, reason := range m.Errors {
+		log.Printf("⚠️  %s not recorded: %s", kind, reason)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return 1
+	}
+	data = append(data, '\n')
+	if !*baseline {
+		_, _ = os.Stdout.Write(data)
+		return 0
+	}
+
+	written := output.NewManifest()
+	defer written.Log("snapshot")
+	txn := output.NewTxn()
+	txn.WriteFile(*out, data, 0o644)
+	if err := txn.Commit(); err != nil {
+		log.Printf("⚠️  Baseline not saved: %v", err)
+		return 1
+	}
+	written.Add(*out)
+	log.Printf("✅ Baseline of %d entries saved to %s", len(m.Entries), *out)
+	return 0
+}
+
+// runVerify implements `om-module verify`: it collects the manifest of the
+// environment again and lists every image, config, generated file and
+// subscriber that was added, removed or modified since the baseline. The
+// exit code is 0 when nothing changed, 1 when something did and 2 when the
+// baseline cannot be read.
+func runVerify(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	path := fs.String("baseline", cfg.BaselineFile, "baseline written by `om-module snapshot -baseline`")
+	project := fs.String("project", cfg.ProjectDir, "path of the lab repository")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	reports := fs.String("reports", output.Dir(cfg.OutputDir, output.Reports), "directory the report is saved to as JSON (empty = not saved)")
+	_ = fs.Parse(args)
+
+	baseline, err := integrity.Load(*path)
+	if err != nil {
+		log.Printf("⚠️  Baseline: %v (take one with `om-module snapshot -baseline`)", err)
+		return 2
+	}
+	r := integrity.Verify(baseline, collectManifest(cfg, *project))
+
+	written := output.NewManifest()
+	defer written.Log("verify")
+	if *reports != "" {
+		path, err := saveVerifyReport(*reports, r)
+		if err != nil {
+			log.Printf("⚠️  Report not saved: %v", err)
+		} else {
+			written.Add(path)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(r)
+	} else {
+		printVerifyReport(os.Stdout, r)
+	}
+	if !r.Clean() {
+		return 1
+	}
+	return 0
+}
+
+// collectManifest reads the environment from the sources in cfg. Without
+// Docker only the files are recorded.
+func collectManifest(cfg *config.Config, project string) integrity.Manifest {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	src := integrity.Sources{ProjectDir: project}
+	for _, dir := range []string{cfg.PrometheusConfigDir, cfg.DashboardRenderDir} {
+		if dir != "" {
+			src.ArtifactDirs = append(src.ArtifactDirs, dir)
+		}
+	}
+	docker, err := dockerclient.New(cfg.DockerSocket)
+	if err != nil {
+		log.Printf("⚠️  Docker client: %v — images and subscribers not recorded", err)
+	} else {
+		defer docker.Close()
+		src.Docker = docker
+		src.MongoContainer = cfg.SubscriberMongoContainer
+	}
+	return integrity.Collect(ctx, src)
+}
+
+// saveVerifyReport writes r as verify-<time>.json in dir.
+func saveVerifyReport(dir string, r integrity.Report) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "verify-"+time.Now().UTC().Format("20060102-150405")+".json")
+	return path, os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printVerifyReport(w io.Writer, r integrity.Report) {
+	fmt.Fprintf(w, "Baseline: %s\n", r.BaselineAt)
+	fmt.Fprintf(w, "Checked : %s (%d entries)\n", r.CheckedAt, r.Checked)
+	skipped := make([]string, 0, len(r.Skipped))
+	for kind := range r.Skipped {
+		skipped = append(skipped, kind)
+	}
+	sort.Strings(skipped)
+	for _, kind := range skipped {
+		fmt.Fprintf(w, "Skipped : %s — %s\n", kind, r.Skipped[kind])
+	}
+	fmt.Fprintln(w)
+	if r.Clean() {
+		fmt.Fprintln(w, "✅ No changes since the baseline")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAME\tCHANGE\tBASELINE\tCURRENT")
+	for _, c := range r.Changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Kind, c.Name, c.Change, dash(c.Baseline), dash(c.Current))
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\n⚠️  %d changes since the baseline\n", len(r.Changes))
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}